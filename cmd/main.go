@@ -37,6 +37,6 @@ func main() {
 
 	// 执行根命令
 	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
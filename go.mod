@@ -8,13 +8,17 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/hybridgroup/yzma v1.7.0
 	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/ncruces/go-sqlite3 v0.20.0
 	github.com/spf13/cobra v1.10.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/ebitengine/purego v0.9.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jupiterrider/ffi v0.5.1 // indirect
+	github.com/ncruces/julianday v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
 	golang.org/x/sys v0.40.0 // indirect
 )
@@ -2,13 +2,19 @@ package cmd
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/i18n"
 	"github.com/dyike/mmq/pkg/llm"
 	"github.com/dyike/mmq/pkg/memory"
+	"github.com/dyike/mmq/pkg/mmq"
 	"github.com/dyike/mmq/pkg/rag"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
@@ -19,6 +25,20 @@ var (
 	chatNoMemory bool
 	chatNoRAG    bool
 	chatModel    string
+	chatSanitize string
+	chatVerify   string
+	chatRewrite  bool
+	chatDebug    bool
+	chatPersona  string
+	chatCache    bool
+	chatPrompt   string
+	chatTopic    float64
+	chatBudget   time.Duration
+
+	// activePersona --persona 选择的人设（零值表示默认助手）
+	activePersona mmq.Persona
+	// chatBasePrompt 自定义的 system prompt 说明（未展开模板变量），空表示内置说明
+	chatBasePrompt string
 )
 
 var chatCmd = &cobra.Command{
@@ -35,7 +55,41 @@ Examples:
   mmq chat                           # 交互式模式
   mmq chat "A股今天怎么样"             # 单轮问答
   mmq chat --session my-session      # 恢复指定会话
-  mmq chat --no-memory "你好"         # 不使用记忆`,
+  mmq chat --no-memory "你好"         # 不使用记忆
+  mmq chat --verify embedding "..."  # 核查回答是否有文档依据
+  mmq chat --debug                   # 显示追问改写后的检索查询
+  mmq chat --persona coder           # 使用配置文件中的人设
+  mmq chat --cache "..."             # 相似问题复用缓存的回答
+  mmq chat --system-prompt @prompt.md # 自定义 system prompt
+
+Personas (config "personas") bundle a system prompt, a memory namespace,
+allowed collections and retrieval defaults, so one install can serve several
+differently-configured assistants.
+
+Follow-up questions are rewritten into standalone queries using recent turns
+before retrieval (disable with --rewrite=false). When a message is far from
+the previous one (embedding distance above --topic-shift), it is treated as a
+new topic: documents are retrieved for the message as is, without folding in
+the earlier conversation, and the number of new sources is shown.
+
+To shorten the time to the first token, the memory part of the system prompt
+is assembled while you type, and the message embedding is computed once and
+shared by topic detection, memory recall and retrieval. With
+--retrieval-budget, the answer starts as soon as the budget has passed and
+full-text results are in; slower stages (vector search, query expansion,
+reranking) are skipped for that turn. --debug prints the time to the first
+token of every answer.
+
+With --cache (or answer_cache.enabled in the config), a question whose
+embedding is close enough to a recently answered one, asked with the same
+model and persona while the indexed documents are unchanged, gets the cached
+answer back without calling the API. Cached answers are marked on stderr.
+
+The system prompt instructions come from --system-prompt (text, or @file to
+read a file), then the persona's system_prompt, then prompt.system in the
+config. Custom prompts may use {{date}} (today's date), {{pinned}} (documents
+pinned with /pin) and {{collections}} (collections the chat may search); they
+are filled in again on every turn.`,
 	RunE: runChat,
 }
 
@@ -44,6 +98,15 @@ func init() {
 	chatCmd.Flags().BoolVar(&chatNoMemory, "no-memory", false, "Disable memory injection")
 	chatCmd.Flags().BoolVar(&chatNoRAG, "no-rag", false, "Disable RAG context retrieval")
 	chatCmd.Flags().StringVar(&chatModel, "model", "", "Override model name")
+	chatCmd.Flags().StringVar(&chatSanitize, "sanitize", "", "Filter injected memories/documents: off, flag or strip (default from config)")
+	chatCmd.Flags().StringVar(&chatVerify, "verify", "", "Check each answer sentence against retrieved documents: embedding or judge")
+	chatCmd.Flags().BoolVar(&chatRewrite, "rewrite", true, "Rewrite follow-up questions into standalone queries before retrieval")
+	chatCmd.Flags().BoolVar(&chatDebug, "debug", false, "Print retrieval details such as the rewritten query")
+	chatCmd.Flags().StringVar(&chatPersona, "persona", "", "Use a persona profile from the config file")
+	chatCmd.Flags().BoolVar(&chatCache, "cache", false, "Reuse cached answers to similar questions (default from config answer_cache.enabled)")
+	chatCmd.Flags().Float64Var(&chatTopic, "topic-shift", memory.DefaultTopicShiftThreshold, "Cosine distance between consecutive messages that counts as a new topic (0 disables)")
+	chatCmd.Flags().DurationVar(&chatBudget, "retrieval-budget", 0, "Start answering once this long has passed and full-text results are in, skipping slower retrieval stages (0 waits for all)")
+	chatCmd.Flags().StringVar(&chatPrompt, "system-prompt", "", "Custom system prompt instructions, or @file to read them from a file")
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
@@ -61,24 +124,55 @@ func runChat(cmd *cobra.Command, args []string) error {
 	}
 
 	if !apiClient.IsConfigured() {
-		fmt.Println("⚠️  未配置 API Key，将尝试连接本地 Ollama")
-		fmt.Println("   设置环境变量 DEEPSEEK_API_KEY 或 OPENAI_API_KEY 来使用云端 API")
+		fmt.Println(i18n.T("chat.no_api_key"))
+		fmt.Println(i18n.T("chat.api_key_hint"))
 	}
 
-	fmt.Printf("🤖 MMQ Chat (provider: %s, model: %s)\n", apiClient.Provider(), apiClient.Model)
+	fmt.Println(i18n.T("chat.banner", apiClient.Provider(), apiClient.Model))
 
 	// 3. 会话管理
 	sessionID := chatSession
 	if sessionID == "" {
 		sessionID = uuid.New().String()[:8]
 	}
-	fmt.Printf("📝 Session: %s\n", sessionID)
+	fmt.Println(i18n.T("chat.session", sessionID))
+	m.SetActor(cliActor("chat"))
+	if !cmd.Flags().Changed("cache") {
+		chatCache = m.GetConfig().AnswerCache
+	}
 
 	// 4. 准备记忆和 RAG 组件
 	mgr := m.GetMemoryManager()
-	promptBuilder := memory.NewPromptBuilder(mgr)
+	session := memory.NewSession(sessionID, mgr, apiClient)
+
+	sanitizeSetting := m.GetConfig().SanitizeLevel
+	if chatSanitize != "" {
+		sanitizeSetting = chatSanitize
+	}
+	sanitizeLevel, err := rag.ParseSanitizeLevel(sanitizeSetting)
+	if err != nil {
+		return err
+	}
+	session.SetSanitizeLevel(sanitizeLevel)
+	session.SetTopicShiftThreshold(chatTopic)
 	convMem := memory.NewConversationMemory(mgr)
-	extractor := memory.NewExtractor(apiClient, mgr)
+
+	// llm 类型的护栏规则使用对话模型判断
+	m.SetGuardrailJudge(func(prompt string) (string, error) {
+		return apiClient.Chat([]llm.ChatMessage{{Role: "user", Content: prompt}}, 0, 32)
+	})
+
+	if chatPersona != "" {
+		activePersona, err = m.GetConfig().GetPersona(chatPersona)
+		if err != nil {
+			return err
+		}
+		session.SetNamespace(activePersona.MemoryNamespace)
+		fmt.Println(i18n.T("chat.persona", activePersona.Name))
+	}
+	if chatBasePrompt, err = resolveSystemPrompt(m.GetConfig().SystemPrompt); err != nil {
+		return err
+	}
 
 	// 构建 RAG retriever
 	var retriever *rag.Retriever
@@ -86,22 +180,38 @@ func runChat(cmd *cobra.Command, args []string) error {
 		retriever = rag.NewRetriever(m.GetStore(), m.GetLLM(), m.GetEmbedding())
 	}
 
-	// 5. 维护对话消息历史（用于发送给 API）
-	var messages []llm.ChatMessage
+	// 答案核查
+	var verifier *rag.GroundingVerifier
+	switch rag.GroundingMethod(chatVerify) {
+	case "":
+	case rag.GroundingEmbedding:
+		verifier = rag.NewGroundingVerifier(m.GetEmbedding())
+	case rag.GroundingJudge:
+		verifier = rag.NewGroundingVerifier(m.GetEmbedding())
+		verifier.SetJudge(func(prompt string) (string, error) {
+			return apiClient.Chat([]llm.ChatMessage{{Role: "user", Content: prompt}}, 0, 1024)
+		})
+	default:
+		return fmt.Errorf("invalid --verify %q (use embedding or judge)", chatVerify)
+	}
 
 	// 单轮模式
 	if len(args) > 0 {
 		userMsg := strings.Join(args, " ")
-		return chatOnce(apiClient, promptBuilder, convMem, extractor, retriever, messages, sessionID, userMsg)
+		return chatOnce(m, apiClient, session, convMem, retriever, verifier, userMsg)
 	}
 
 	// 6. 交互式 REPL
-	fmt.Println("💬 输入消息开始对话 (输入 /quit 退出, /help 查看命令)")
+	fmt.Println(i18n.T("chat.start"))
 	fmt.Println()
 
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
-		fmt.Print("你: ")
+		if !chatNoMemory {
+			// 等待输入时提前组装 system prompt 中与问题无关的部分
+			go session.Prepare()
+		}
+		fmt.Print(i18n.T("chat.you"))
 		if !scanner.Scan() {
 			break
 		}
@@ -110,10 +220,11 @@ func runChat(cmd *cobra.Command, args []string) error {
 		if input == "" {
 			continue
 		}
+		latency := &turnLatency{start: time.Now()}
 
 		// 处理斜杠命令
 		if strings.HasPrefix(input, "/") {
-			if handleSlashCmd(input, convMem, sessionID, &messages) {
+			if handleSlashCmd(m, input, convMem, session) {
 				break // /quit
 			}
 			continue
@@ -121,60 +232,102 @@ func runChat(cmd *cobra.Command, args []string) error {
 
 		// 构建 system prompt（含记忆）
 		var ragContexts []rag.Context
-		if retriever != nil && !chatNoRAG && shouldUseRAG(input) {
-			ragContexts, _ = retriever.Retrieve(input, rag.RetrieveOptions{
-				Limit:       3,
-				Strategy:    rag.StrategyHybrid,
-				ExpandQuery: false,
-			})
+		query := input
+		shifted := false
+		if retriever != nil && !chatNoRAG {
+			// 换了话题时直接用新问题检索，不借助旧话题改写
+			if shifted = detectTopicShift(session, input); !shifted {
+				query = rewriteForRetrieval(apiClient, input, turnsFromMessages(session.Messages()))
+			}
+		}
+		query, err = guardQuery(m, query)
+		if err != nil {
+			fmt.Printf("⛔ %v\n\n", err)
+			continue
+		}
+
+		// 相似问题命中缓存时直接复用回答；本轮有 /use 选择的文档时不使用缓存
+		injected := session.TakeInjectedDocs()
+		var hit *mmq.CachedAnswer
+		if len(injected) == 0 {
+			hit = lookupAnswer(m, apiClient, session, query)
+		}
+		if hit != nil {
+			fmt.Printf("\n🤖: %s\n", hit.Answer)
+			printCachedAnswer(hit)
+			fmt.Println()
+			session.AppendMessages(
+				llm.ChatMessage{Role: "user", Content: input},
+				llm.ChatMessage{Role: "assistant", Content: hit.Answer},
+			)
+			storeCachedTurn(convMem, session.ID, input, hit.Answer)
+			continue
+		}
+
+		if retriever != nil && !chatNoRAG && shouldUseRAG(query) {
+			ragContexts = retrieveForChat(retriever, query, sharedEmbedding(session, input, query))
+			recordContextAccess(m, ragContexts)
+		}
+		ragContexts = withChosenDocs(m, append(session.PinnedDocs(), injected...), ragContexts)
+		latency.retrieved = time.Now()
+		if fresh := session.RecordSources(rag.SourceDocIDs(ragContexts)); shifted && fresh > 0 {
+			fmt.Fprintln(os.Stderr, i18n.T("chat.topic_shift", fresh))
 		}
 
 		var systemPrompt string
+		var sanitizeReport rag.SanitizeReport
 		if !chatNoMemory {
-			systemPrompt = promptBuilder.BuildSystemPrompt(sessionID, input, ragContexts)
+			session.SetInstructions(chatInstructions(m, session))
+			systemPrompt, sanitizeReport = session.BuildSystemPrompt(input, ragContexts)
 		} else {
-			systemPrompt = "你是一个智能助手。"
+			systemPrompt = plainSystemPrompt(m, session)
 			if len(ragContexts) > 0 {
-				systemPrompt += "\n\n[相关文档]\n"
+				systemPrompt += "\n\n" + i18n.T("chat.related_docs") + "\n"
 				for i, ctx := range ragContexts {
-					systemPrompt += fmt.Sprintf("[%d] %s\n", i+1, truncateForChat(ctx.Text, 500))
+					text, report := rag.Sanitize(ctx.Text, sanitizeLevel)
+					sanitizeReport.Merge(report)
+					systemPrompt += fmt.Sprintf("[%d] %s\n", i+1, truncateForChat(text, memory.ContextSnippetLimit(ctx)))
 				}
 			}
 		}
+		printSanitizeReport(sanitizeReport)
+		latency.prompted = time.Now()
 
 		// 组装消息
 		apiMessages := []llm.ChatMessage{
 			{Role: "system", Content: systemPrompt},
 		}
 		// 添加对话历史
-		apiMessages = append(apiMessages, messages...)
+		apiMessages = append(apiMessages, session.Messages()...)
 		// 添加当前用户消息
 		apiMessages = append(apiMessages, llm.ChatMessage{Role: "user", Content: input})
 
 		// 流式输出
 		fmt.Print("\n🤖: ")
-		reply, err := apiClient.ChatStream(apiMessages, 0.7, 4096, func(chunk string) {
-			fmt.Print(chunk)
-		})
+		reply, err := generateReply(m, apiClient, apiMessages, latency)
 		fmt.Println()
 		fmt.Println()
+		latency.print()
 
+		if mmq.IsVetoed(err) {
+			fmt.Printf("⛔ %v\n\n", err)
+			continue
+		}
 		if err != nil {
-			fmt.Printf("❌ API 错误: %v\n\n", err)
+			fmt.Printf("%s\n\n", i18n.T("chat.api_error", err))
 			continue
 		}
+		printGroundingReport(verifier, reply, ragContexts)
+		if len(injected) == 0 {
+			cacheAnswer(m, apiClient, session, query, reply)
+		}
 
-		// 更新消息历史
-		messages = append(messages,
+		// 更新消息历史（会话只保留最近 10 轮）
+		session.AppendMessages(
 			llm.ChatMessage{Role: "user", Content: input},
 			llm.ChatMessage{Role: "assistant", Content: reply},
 		)
 
-		// 保持最近 10 轮对话在上下文中
-		if len(messages) > 20 {
-			messages = messages[len(messages)-20:]
-		}
-
 		// 存储对话轮次到记忆
 		if !chatNoMemory {
 			turn := memory.ConversationTurn{
@@ -182,65 +335,469 @@ func runChat(cmd *cobra.Command, args []string) error {
 				Assistant: reply,
 				SessionID: sessionID,
 				Timestamp: time.Now(),
+				Sources:   rag.SourceDocIDs(ragContexts),
+				Metadata:  chatTurnMetadata(),
 			}
 			_ = convMem.StoreTurn(turn)
 
 			// 自动提取记忆（后台执行，不阻塞对话）
 			go func() {
-				if n, err := extractor.ExtractFromTurn(turn); err == nil && n > 0 {
-					fmt.Fprintf(os.Stderr, "[记忆] 自动提取了 %d 条新记忆\n", n)
+				if n, err := session.Extract(turn); err == nil && n > 0 {
+					fmt.Fprintln(os.Stderr, i18n.T("chat.memories_added", n))
 				}
 			}()
 		}
 	}
 
-	fmt.Println("\n👋 再见!")
+	fmt.Println("\n" + i18n.T("chat.bye"))
 	return nil
 }
 
+// retrieveForChat 检索注入对话的文档，使用人设的检索默认值和允许的集合
+// queryEmbedding 为会话已为该查询生成的嵌入（可为空）
+func retrieveForChat(retriever *rag.Retriever, query string, queryEmbedding []float32) []rag.Context {
+	opts := rag.RetrieveOptions{
+		Limit:          3,
+		Strategy:       rag.StrategyHybrid,
+		ExpandQuery:    false,
+		Timeout:        chatBudget,
+		QueryEmbedding: queryEmbedding,
+	}
+	if activePersona.Limit > 0 {
+		opts.Limit = activePersona.Limit
+	}
+	if activePersona.Strategy != "" {
+		opts.Strategy = rag.RetrievalStrategy(activePersona.Strategy)
+	}
+	opts.MinScore = activePersona.MinScore
+	opts.ExpandQuery = activePersona.ExpandQuery
+	opts.Rerank = activePersona.Rerank
+
+	if len(activePersona.Collections) == 0 {
+		contexts, _ := retriever.Retrieve(query, opts)
+		return contexts
+	}
+
+	// 每个允许的集合分别检索，按相关度合并
+	var contexts []rag.Context
+	for _, collection := range activePersona.Collections {
+		opts.Collection = collection
+		results, err := retriever.Retrieve(query, opts)
+		if err != nil {
+			continue
+		}
+		contexts = append(contexts, results...)
+	}
+	sort.SliceStable(contexts, func(i, j int) bool {
+		return contexts[i].Relevance > contexts[j].Relevance
+	})
+	if len(contexts) > opts.Limit {
+		contexts = contexts[:opts.Limit]
+	}
+	return contexts
+}
+
+// recordContextAccess 记录注入对话的文档被查看（mmq recent）
+func recordContextAccess(m *mmq.MMQ, contexts []rag.Context) {
+	for _, c := range contexts {
+		collection, _ := c.Metadata["collection"].(string)
+		path, _ := c.Metadata["path"].(string)
+		if collection != "" && path != "" {
+			m.RecordDocumentAccess(collection, path)
+		}
+	}
+}
+
+// withChosenDocs 把用户指定的文档（/pin 固定、/use 选择，collection/path）放在检索结果之前，
+// 并去掉重复的检索结果；指定后被删除的文档跳过
+func withChosenDocs(m *mmq.MMQ, docs []string, contexts []rag.Context) []rag.Context {
+	if len(docs) == 0 {
+		return contexts
+	}
+	merged := make([]rag.Context, 0, len(docs)+len(contexts))
+	seen := make(map[string]bool)
+	for _, path := range docs {
+		doc, err := m.GetDocumentByPath(path)
+		if err != nil {
+			continue
+		}
+		source := doc.Collection + "/" + doc.Path
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		merged = append(merged, rag.Context{
+			Text:      doc.Content,
+			Source:    source,
+			Relevance: 1,
+			Metadata: map[string]interface{}{
+				"title":      doc.Title,
+				"hash":       doc.Hash,
+				"collection": doc.Collection,
+				"path":       doc.Path,
+				"pinned":     true,
+			},
+		})
+	}
+	for _, c := range contexts {
+		if !seen[c.Source] {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// resolveSystemPrompt 按 --system-prompt、人设、配置文件的顺序选择自定义说明
+func resolveSystemPrompt(configured string) (string, error) {
+	if strings.HasPrefix(chatPrompt, "@") {
+		data, err := os.ReadFile(chatPrompt[1:])
+		if err != nil {
+			return "", fmt.Errorf("failed to read system prompt: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if chatPrompt != "" {
+		return chatPrompt, nil
+	}
+	if activePersona.SystemPrompt != "" {
+		return activePersona.SystemPrompt, nil
+	}
+	return configured, nil
+}
+
+// chatInstructions 展开模板变量后的自定义说明，没有自定义说明时为空
+func chatInstructions(m *mmq.MMQ, session *memory.Session) string {
+	if chatBasePrompt == "" {
+		return ""
+	}
+	return memory.ExpandPrompt(chatBasePrompt, memory.PromptVars{
+		Date:        time.Now(),
+		Pinned:      session.PinnedDocs(),
+		Collections: chatCollections(m),
+	})
+}
+
+// chatCollections 对话可检索的集合：人设限制的集合，否则为全部集合
+func chatCollections(m *mmq.MMQ) []string {
+	if len(activePersona.Collections) > 0 {
+		return activePersona.Collections
+	}
+	collections, err := m.ListCollections()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(collections))
+	for i, c := range collections {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// plainSystemPrompt 不使用记忆时的 system prompt
+func plainSystemPrompt(m *mmq.MMQ, session *memory.Session) string {
+	if instructions := chatInstructions(m, session); instructions != "" {
+		return instructions
+	}
+	return i18n.T("chat.system_prompt")
+}
+
+// chatTurnMetadata 对话轮次的附加元数据（人设的记忆命名空间）
+func chatTurnMetadata() map[string]interface{} {
+	if activePersona.MemoryNamespace == "" {
+		return nil
+	}
+	return map[string]interface{}{memory.MetadataNamespace: activePersona.MemoryNamespace}
+}
+
+// guardQuery 执行 pre_retrieval 护栏钩子，返回（可能被改写的）检索查询
+func guardQuery(m *mmq.MMQ, query string) (string, error) {
+	payload := &mmq.HookPayload{Query: query}
+	if err := m.RunHooks(mmq.HookPreRetrieval, payload); err != nil {
+		return "", err
+	}
+	return payload.Query, nil
+}
+
+// generateReply 执行生成前后的护栏钩子并生成回答
+// 有 post_generation 钩子时先完整生成、检查后再输出，否则流式输出
+func generateReply(m *mmq.MMQ, apiClient *llm.APIClient, apiMessages []llm.ChatMessage, latency *turnLatency) (string, error) {
+	system, user := &apiMessages[0], &apiMessages[len(apiMessages)-1]
+	payload := &mmq.HookPayload{Query: user.Content, Prompt: system.Content}
+	if err := m.RunHooks(mmq.HookPreGeneration, payload); err != nil {
+		return "", err
+	}
+	system.Content, user.Content = payload.Prompt, payload.Query
+
+	if !m.HasHooks(mmq.HookPostGeneration) {
+		return apiClient.ChatStream(apiMessages, 0.7, 4096, func(chunk string) {
+			latency.firstToken()
+			fmt.Print(chunk)
+		})
+	}
+
+	reply, err := apiClient.Chat(apiMessages, 0.7, 4096)
+	if err != nil {
+		return "", err
+	}
+	payload.Output = reply
+	if err := m.RunHooks(mmq.HookPostGeneration, payload); err != nil {
+		return "", err
+	}
+	latency.firstToken()
+	fmt.Print(payload.Output)
+	return payload.Output, nil
+}
+
+// turnLatency 一轮对话各阶段完成的时间，--debug 时输出首字延迟
+type turnLatency struct {
+	start     time.Time // 读到用户消息
+	retrieved time.Time // 话题检测、改写和检索完成
+	prompted  time.Time // system prompt 组装完成
+	first     time.Time // 收到第一段回答
+}
+
+// firstToken 记录收到第一段回答的时间（nil 安全）
+func (l *turnLatency) firstToken() {
+	if l != nil && l.first.IsZero() {
+		l.first = time.Now()
+	}
+}
+
+// print --debug 时输出首字延迟及各阶段耗时
+func (l *turnLatency) print() {
+	if !chatDebug || l == nil || l.first.IsZero() {
+		return
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	fmt.Fprintln(os.Stderr, i18n.T("chat.latency", round(l.first.Sub(l.start)),
+		round(l.retrieved.Sub(l.start)), round(l.prompted.Sub(l.retrieved)), round(l.first.Sub(l.prompted))))
+}
+
+// sharedEmbedding 检索查询就是用户消息时，返回会话为该消息生成的嵌入，供记忆召回和检索共用
+func sharedEmbedding(session *memory.Session, input, query string) []float32 {
+	if query != input {
+		return nil
+	}
+	embedding, err := session.EmbedQuery(input)
+	if err != nil {
+		return nil
+	}
+	return embedding
+}
+
+// answerCacheScope 回答缓存的范围：模型、人设、记忆/RAG 开关和固定文档不同的回答互不复用
+// 未启用缓存时返回空
+func answerCacheScope(apiClient *llm.APIClient, session *memory.Session) string {
+	if !chatCache {
+		return ""
+	}
+	return fmt.Sprintf("chat|%s|%s|persona=%s|memory=%t|rag=%t|pins=%s|prompt=%.6x",
+		apiClient.Provider(), apiClient.Model, activePersona.Name, !chatNoMemory, !chatNoRAG,
+		strings.Join(session.PinnedDocs(), ","), sha256.Sum256([]byte(chatBasePrompt)))
+}
+
+// lookupAnswer 查找相似问题的缓存回答，未启用、未命中或出错时返回 nil
+func lookupAnswer(m *mmq.MMQ, apiClient *llm.APIClient, session *memory.Session, query string) *mmq.CachedAnswer {
+	scope := answerCacheScope(apiClient, session)
+	if scope == "" {
+		return nil
+	}
+	hit, err := m.LookupAnswer(query, scope)
+	if err != nil && chatDebug {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.cache_failed", err))
+	}
+	return hit
+}
+
+// cacheAnswer 缓存本轮回答（未启用缓存时忽略）
+func cacheAnswer(m *mmq.MMQ, apiClient *llm.APIClient, session *memory.Session, query, reply string) {
+	scope := answerCacheScope(apiClient, session)
+	if scope == "" || strings.TrimSpace(reply) == "" {
+		return
+	}
+	if err := m.CacheAnswer(query, scope, reply); err != nil && chatDebug {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.cache_failed", err))
+	}
+}
+
+// printCachedAnswer 标记回答来自缓存
+func printCachedAnswer(hit *mmq.CachedAnswer) {
+	fmt.Fprintln(os.Stderr, i18n.T("chat.cached", format.InZone(hit.CreatedAt).Format("2006-01-02 15:04"), hit.Similarity))
+	if chatDebug {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.cached_question", hit.Question))
+	}
+}
+
+// storeCachedTurn 存储复用缓存回答的对话轮次（记忆已从原回答中提取过，不再提取）
+func storeCachedTurn(convMem *memory.ConversationMemory, sessionID, input, reply string) {
+	if chatNoMemory {
+		return
+	}
+	_ = convMem.StoreTurn(memory.ConversationTurn{
+		User:      input,
+		Assistant: reply,
+		SessionID: sessionID,
+		Timestamp: time.Now(),
+		Metadata:  chatTurnMetadata(),
+	})
+}
+
+// printSanitizeReport 提示注入前被过滤的可疑内容
+func printSanitizeReport(report rag.SanitizeReport) {
+	if report.Empty() {
+		return
+	}
+	fmt.Fprintln(os.Stderr, i18n.T("chat.suspicious", report))
+}
+
+// rewriteForRetrieval 把追问改写为独立的检索查询，失败时退回原输入
+func rewriteForRetrieval(apiClient *llm.APIClient, input string, history []rag.Turn) string {
+	if !chatRewrite || len(history) == 0 {
+		return input
+	}
+
+	query, err := rag.RewriteQuery(input, history, func(prompt string) (string, error) {
+		return apiClient.Chat([]llm.ChatMessage{{Role: "user", Content: prompt}}, 0, 128)
+	})
+	if chatDebug {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("chat.rewrite_failed", err))
+		} else if query != input {
+			fmt.Fprintln(os.Stderr, i18n.T("chat.rewritten", query))
+		}
+	}
+	return query
+}
+
+// detectTopicShift 检测用户消息是否换了话题，检测失败时按未切换处理
+func detectTopicShift(session *memory.Session, input string) bool {
+	shifted, err := session.DetectTopicShift(input)
+	if err != nil && chatDebug {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.topic_failed", err))
+	}
+	return shifted
+}
+
+// turnsFromMessages 从消息历史中还原对话轮次
+func turnsFromMessages(messages []llm.ChatMessage) []rag.Turn {
+	var turns []rag.Turn
+	for i := 0; i+1 < len(messages); i += 2 {
+		if messages[i].Role == "user" && messages[i+1].Role == "assistant" {
+			turns = append(turns, rag.Turn{User: messages[i].Content, Assistant: messages[i+1].Content})
+		}
+	}
+	return turns
+}
+
+// printGroundingReport 核查回答，列出在检索文档中找不到依据的句子
+func printGroundingReport(verifier *rag.GroundingVerifier, reply string, contexts []rag.Context) {
+	if verifier == nil {
+		return
+	}
+	if len(contexts) == 0 {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.verify_no_docs"))
+		return
+	}
+
+	report, err := verifier.Verify(reply, contexts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.verify_failed", err))
+		return
+	}
+	if report.Unsupported == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", i18n.T("chat.verify_ok", report.Supported))
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, i18n.T("chat.verify_unsupported", report.Unsupported, len(report.Sentences)))
+	for _, s := range report.Sentences {
+		if !s.Supported {
+			fmt.Fprintf(os.Stderr, "  ⚠️  %s\n", truncateForChat(s.Sentence, 80))
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
 // chatOnce 单轮问答模式
 func chatOnce(
+	m *mmq.MMQ,
 	apiClient *llm.APIClient,
-	promptBuilder *memory.PromptBuilder,
+	session *memory.Session,
 	convMem *memory.ConversationMemory,
-	extractor *memory.Extractor,
 	retriever *rag.Retriever,
-	messages []llm.ChatMessage,
-	sessionID, userMsg string,
+	verifier *rag.GroundingVerifier,
+	userMsg string,
 ) error {
+	sessionID := session.ID
+
 	// RAG 检索（仅对内容相关的查询）
 	var ragContexts []rag.Context
-	if retriever != nil && shouldUseRAG(userMsg) {
-		ragContexts, _ = retriever.Retrieve(userMsg, rag.RetrieveOptions{
-			Limit:       3,
-			Strategy:    rag.StrategyHybrid,
-			ExpandQuery: false,
-		})
+	query := userMsg
+	shifted := false
+	if retriever != nil && chatSession != "" {
+		// 恢复的会话可以借助历史轮次改写追问，换了话题时不改写
+		if turns, err := convMem.GetHistory(sessionID, 3); err == nil {
+			history := make([]rag.Turn, len(turns))
+			for i, t := range turns {
+				// GetHistory 按时间倒序返回
+				history[len(turns)-1-i] = rag.Turn{User: t.User, Assistant: t.Assistant}
+			}
+			if len(turns) > 0 {
+				detectTopicShift(session, turns[0].User)
+			}
+			if shifted = detectTopicShift(session, userMsg); !shifted {
+				query = rewriteForRetrieval(apiClient, userMsg, history)
+			}
+		}
+	}
+	query, err := guardQuery(m, query)
+	if err != nil {
+		return err
+	}
+	if hit := lookupAnswer(m, apiClient, session, query); hit != nil {
+		fmt.Println(hit.Answer)
+		printCachedAnswer(hit)
+		storeCachedTurn(convMem, sessionID, userMsg, hit.Answer)
+		return nil
+	}
+	if retriever != nil && shouldUseRAG(query) {
+		ragContexts = retrieveForChat(retriever, query, sharedEmbedding(session, userMsg, query))
+		recordContextAccess(m, ragContexts)
+	}
+	ragContexts = withChosenDocs(m, session.PinnedDocs(), ragContexts)
+	if shifted && len(ragContexts) > 0 {
+		fmt.Fprintln(os.Stderr, i18n.T("chat.topic_shift", len(ragContexts)))
 	}
 
 	// 构建 prompt
 	var systemPrompt string
 	if !chatNoMemory {
-		systemPrompt = promptBuilder.BuildSystemPrompt(sessionID, userMsg, ragContexts)
+		var report rag.SanitizeReport
+		session.SetInstructions(chatInstructions(m, session))
+		systemPrompt, report = session.BuildSystemPrompt(userMsg, ragContexts)
+		printSanitizeReport(report)
 	} else {
-		systemPrompt = "你是一个智能助手。"
+		systemPrompt = plainSystemPrompt(m, session)
 	}
 
 	apiMessages := []llm.ChatMessage{
 		{Role: "system", Content: systemPrompt},
 	}
-	apiMessages = append(apiMessages, messages...)
+	apiMessages = append(apiMessages, session.Messages()...)
 	apiMessages = append(apiMessages, llm.ChatMessage{Role: "user", Content: userMsg})
 
 	// 流式输出
-	reply, err := apiClient.ChatStream(apiMessages, 0.7, 4096, func(chunk string) {
-		fmt.Print(chunk)
-	})
+	reply, err := generateReply(m, apiClient, apiMessages, nil)
 	fmt.Println()
 
+	if mmq.IsVetoed(err) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("API error: %w", err)
 	}
+	printGroundingReport(verifier, reply, ragContexts)
+	cacheAnswer(m, apiClient, session, query, reply)
 
 	// 存储对话 + 自动提取
 	if !chatNoMemory {
@@ -249,18 +806,61 @@ func chatOnce(
 			Assistant: reply,
 			SessionID: sessionID,
 			Timestamp: time.Now(),
+			Sources:   rag.SourceDocIDs(ragContexts),
+			Metadata:  chatTurnMetadata(),
 		}
 		_ = convMem.StoreTurn(turn)
-		if n, _ := extractor.ExtractFromTurn(turn); n > 0 {
-			fmt.Fprintf(os.Stderr, "[记忆] 自动提取了 %d 条新记忆\n", n)
+		if n, _ := session.Extract(turn); n > 0 {
+			fmt.Fprintln(os.Stderr, i18n.T("chat.memories_added", n))
 		}
 	}
 
 	return nil
 }
 
+// /get 在对话中显示的文档长度（字符），完整内容用 mmq get 查看
+const chatDocDisplayLimit = 2000
+
+// chatLastResults 最近一次 /search 的结果，/get 和 /use 可以用序号引用
+var chatLastResults []mmq.SearchResult
+
+// chatSearch /search 的检索：混合检索前 5 个结果，人设限制集合时只检索允许的集合
+func chatSearch(m *mmq.MMQ, query string) ([]mmq.SearchResult, error) {
+	opts := mmq.SearchOptions{Limit: 5, Strategy: mmq.StrategyHybrid}
+	if len(activePersona.Collections) == 0 {
+		return m.Search(query, opts)
+	}
+	var results []mmq.SearchResult
+	for _, collection := range activePersona.Collections {
+		opts.Collection = collection
+		r, err := m.Search(query, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// resolveChatDoc 按 /search 结果序号、docid 或 collection/path 获取文档
+func resolveChatDoc(m *mmq.MMQ, ref string) (*mmq.DocumentDetail, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(chatLastResults) {
+			return nil, fmt.Errorf("no result %d (the last /search returned %d)", n, len(chatLastResults))
+		}
+		r := chatLastResults[n-1]
+		return m.GetDocumentByPath(r.Collection + "/" + r.Path)
+	}
+	return getDocumentDetail(m, ref)
+}
+
 // handleSlashCmd 处理斜杠命令，返回 true 表示退出
-func handleSlashCmd(input string, convMem *memory.ConversationMemory, sessionID string, messages *[]llm.ChatMessage) bool {
+func handleSlashCmd(m *mmq.MMQ, input string, convMem *memory.ConversationMemory, session *memory.Session) bool {
+	sessionID := session.ID
 	parts := strings.Fields(input)
 	cmd := parts[0]
 
@@ -269,28 +869,22 @@ func handleSlashCmd(input string, convMem *memory.ConversationMemory, sessionID
 		return true
 
 	case "/help", "/h":
-		fmt.Println("Available commands:")
-		fmt.Println("  /quit, /q        退出")
-		fmt.Println("  /clear           清除当前对话上下文")
-		fmt.Println("  /history         查看当前会话历史")
-		fmt.Println("  /sessions        查看所有会话")
-		fmt.Println("  /memory          切换记忆开关")
-		fmt.Println("  /rag             切换 RAG 开关")
+		fmt.Println(i18n.T("chat.help"))
 		fmt.Println()
 
 	case "/clear":
-		*messages = nil
-		fmt.Println("✓ 对话上下文已清除")
+		session.ClearMessages()
+		fmt.Println(i18n.T("chat.cleared"))
 		fmt.Println()
 
 	case "/history":
 		turns, err := convMem.GetHistory(sessionID, 10)
 		if err != nil || len(turns) == 0 {
-			fmt.Println("暂无历史对话")
+			fmt.Println(i18n.T("chat.no_history"))
 		} else {
-			fmt.Printf("── 会话 %s 历史 (%d轮) ──\n", sessionID, len(turns))
+			fmt.Println(i18n.T("chat.history_header", sessionID, len(turns)))
 			for _, turn := range turns {
-				fmt.Printf("  [%s] 你: %s\n", turn.Timestamp.Format("15:04"), truncateForChat(turn.User, 60))
+				fmt.Printf("  [%s] %s%s\n", format.InZone(turn.Timestamp).Format("15:04"), i18n.T("chat.you"), truncateForChat(turn.User, 60))
 				fmt.Printf("         🤖: %s\n", truncateForChat(turn.Assistant, 60))
 			}
 		}
@@ -299,16 +893,16 @@ func handleSlashCmd(input string, convMem *memory.ConversationMemory, sessionID
 	case "/sessions":
 		sessions, err := convMem.GetSessionIDs()
 		if err != nil || len(sessions) == 0 {
-			fmt.Println("暂无会话")
+			fmt.Println(i18n.T("chat.no_sessions"))
 		} else {
-			fmt.Printf("所有会话 (%d):\n", len(sessions))
+			fmt.Println(i18n.T("chat.sessions_header", len(sessions)))
 			for _, s := range sessions {
 				count, _ := convMem.CountBySession(s)
 				marker := ""
 				if s == sessionID {
-					marker = " ← 当前"
+					marker = i18n.T("chat.current")
 				}
-				fmt.Printf("  %s (%d轮)%s\n", s, count, marker)
+				fmt.Println(i18n.T("chat.session_line", s, count, marker))
 			}
 		}
 		fmt.Println()
@@ -316,23 +910,186 @@ func handleSlashCmd(input string, convMem *memory.ConversationMemory, sessionID
 	case "/memory":
 		chatNoMemory = !chatNoMemory
 		if chatNoMemory {
-			fmt.Println("📴 记忆已关闭")
+			fmt.Println(i18n.T("chat.memory_off"))
 		} else {
-			fmt.Println("📡 记忆已开启")
+			fmt.Println(i18n.T("chat.memory_on"))
 		}
 		fmt.Println()
 
 	case "/rag":
 		chatNoRAG = !chatNoRAG
 		if chatNoRAG {
-			fmt.Println("📴 RAG 已关闭")
+			fmt.Println(i18n.T("chat.rag_off"))
+		} else {
+			fmt.Println(i18n.T("chat.rag_on"))
+		}
+		fmt.Println()
+
+	case "/tools":
+		tools := m.Tools()
+		if len(tools) == 0 {
+			fmt.Println(i18n.T("chat.no_tools"))
+		}
+		for _, t := range tools {
+			fmt.Printf("  %-16s %s\n", t.Name, t.Description)
+		}
+		fmt.Println()
+
+	case "/tool":
+		if len(parts) < 2 {
+			fmt.Println(i18n.T("chat.tool_usage"))
+			fmt.Println()
+			break
+		}
+		name := parts[1]
+		toolInput := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(input, cmd)), name))
+		output, err := m.RunTool(name, toolInput)
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.tool_failed", err))
+			break
+		}
+		fmt.Printf("🔧 %s:\n%s\n\n", name, output)
+		session.AppendMessages(llm.ChatMessage{
+			Role:    "user",
+			Content: i18n.T("chat.tool_output", name) + "\n" + output,
+		})
+
+	case "/search":
+		query := strings.TrimSpace(strings.TrimPrefix(input, cmd))
+		if query == "" {
+			fmt.Println(i18n.T("chat.search_usage"))
+			fmt.Println()
+			break
+		}
+		results, err := chatSearch(m, query)
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.search_failed", err))
+			break
+		}
+		chatLastResults = results
+		if len(results) == 0 {
+			fmt.Println(i18n.T("chat.no_results"))
+			fmt.Println()
+			break
+		}
+		for i, r := range results {
+			fmt.Printf("  [%d] #%s %s/%s  %s (%s)\n", i+1, r.DocID, r.Collection, r.Path, r.Title, format.Decimal(r.Score, 2))
+			if r.Snippet != "" {
+				fmt.Printf("      %s\n", truncateForChat(r.Snippet, 120))
+			}
+		}
+		fmt.Println(i18n.T("chat.search_hint"))
+		fmt.Println()
+
+	case "/get":
+		if len(parts) < 2 {
+			fmt.Println(i18n.T("chat.get_usage"))
+			fmt.Println()
+			break
+		}
+		doc, err := resolveChatDoc(m, parts[1])
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.get_failed", parts[1], err))
+			break
+		}
+		m.RecordDocumentAccess(doc.Collection, doc.Path)
+		fmt.Printf("📄 %s\n   %s/%s #%s\n\n", doc.Title, doc.Collection, doc.Path, doc.DocID)
+		content := []rune(doc.Content)
+		if len(content) > chatDocDisplayLimit {
+			fmt.Println(string(content[:chatDocDisplayLimit]))
+			fmt.Println(i18n.T("chat.doc_truncated", len(content)-chatDocDisplayLimit, doc.DocID))
+		} else {
+			fmt.Println(doc.Content)
+		}
+		fmt.Println()
+
+	case "/use":
+		if len(parts) < 2 {
+			fmt.Println(i18n.T("chat.use_usage"))
+			fmt.Println()
+			break
+		}
+		doc, err := resolveChatDoc(m, parts[1])
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.get_failed", parts[1], err))
+			break
+		}
+		session.InjectDoc(doc.Collection + "/" + doc.Path)
+		fmt.Println(i18n.T("chat.injected", doc.Collection+"/"+doc.Path))
+		fmt.Println()
+
+	case "/export":
+		path := "chat-" + sessionID + ".md"
+		if len(parts) > 1 {
+			path = parts[1]
+		}
+		transcript, err := m.SessionTranscript(sessionID)
+		if err == nil {
+			err = writeTranscriptFile(m, path, transcript)
+		}
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.export_failed", err))
+			break
+		}
+		fmt.Println(i18n.T("chat.exported", len(transcript.Turns), path))
+		fmt.Println()
+
+	case "/pin":
+		if len(parts) < 2 {
+			fmt.Println(i18n.T("chat.pin_usage"))
+			fmt.Println()
+			break
+		}
+		doc, err := getDocumentDetail(m, parts[1])
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("chat.pin_failed", parts[1], err))
+			break
+		}
+		// 按路径固定，文档内容更新后 docid 会变
+		pin := doc.Collection + "/" + doc.Path
+		if session.PinDoc(pin) {
+			m.RecordDocumentAccess(doc.Collection, doc.Path)
+			fmt.Println(i18n.T("chat.pinned", pin, doc.Title))
+		} else {
+			fmt.Println(i18n.T("chat.already_pinned", pin))
+		}
+		fmt.Println()
+
+	case "/pins":
+		pins := session.PinnedDocs()
+		if len(pins) == 0 {
+			fmt.Println(i18n.T("chat.no_pins"))
+		} else {
+			fmt.Println(i18n.T("chat.pins_header", len(pins)))
+			for _, pin := range pins {
+				if doc, err := m.GetDocumentByPath(pin); err == nil {
+					fmt.Printf("  #%s %s  %s\n", doc.DocID, pin, doc.Title)
+				} else {
+					fmt.Printf("  %s (%v)\n", pin, err)
+				}
+			}
+		}
+		fmt.Println()
+
+	case "/unpin":
+		if len(parts) < 2 {
+			fmt.Println(i18n.T("chat.unpinned_all", session.ClearPins()))
+			fmt.Println()
+			break
+		}
+		pin := parts[1]
+		if doc, err := getDocumentDetail(m, pin); err == nil {
+			pin = doc.Collection + "/" + doc.Path
+		}
+		if session.UnpinDoc(pin) {
+			fmt.Println(i18n.T("chat.unpinned", pin))
 		} else {
-			fmt.Println("📡 RAG 已开启")
+			fmt.Println(i18n.T("chat.not_pinned", parts[1]))
 		}
 		fmt.Println()
 
 	default:
-		fmt.Printf("未知命令: %s (输入 /help 查看)\n\n", cmd)
+		fmt.Printf("%s\n\n", i18n.T("chat.unknown_command", cmd))
 	}
 
 	return false
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+// chunks 命令 - 查看文档的分块和嵌入情况
+var chunksCmd = &cobra.Command{
+	Use:   "chunks <docid|collection/path>",
+	Short: "Show how a document is chunked and embedded",
+	Long: `Show how a document is split into chunks with the current chunk size and
+overlap: each chunk's byte range, size, overlap with the previous chunk, and
+which embedding models have a vector for it.
+
+Vectors stored for a different chunking (e.g. after changing chunk_size) are
+counted as stale. Use --full to print every chunk's text.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runChunks,
+}
+
+var chunksFull bool
+
+func init() {
+	chunksCmd.Flags().BoolVar(&chunksFull, "full", false, "Print the text of every chunk")
+	rootCmd.AddCommand(chunksCmd)
+}
+
+func runChunks(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	report, err := m.DocumentChunks(args[0])
+	if err != nil {
+		return err
+	}
+	return format.OutputChunkReport(report, format.Format(outputFormat), chunksFull)
+}
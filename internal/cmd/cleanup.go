@@ -10,10 +10,10 @@ import (
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Clean up cache, orphaned data, and vacuum database",
-	Long: `Remove LLM cache, inactive documents, orphaned content/vectors, and compact the database.
+	Long: `Remove LLM and answer caches, inactive documents, orphaned content/vectors, and compact the database.
 
 Operations performed:
-  1. Delete LLM cache entries
+  1. Delete LLM cache and cached answers
   2. Delete inactive (soft-deleted) documents
   3. Remove orphaned content (not referenced by any document)
   4. Remove orphaned vectors (not referenced by any content)
@@ -40,7 +40,7 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println()
-	fmt.Printf("  LLM cache entries deleted:    %d\n", result.CacheDeleted)
+	fmt.Printf("  Cache entries deleted:        %d\n", result.CacheDeleted)
 	fmt.Printf("  Inactive documents deleted:   %d\n", result.InactiveDocsDeleted)
 	fmt.Printf("  Orphaned content removed:     %d\n", result.OrphanedContentDeleted)
 	fmt.Printf("  Orphaned vectors removed:     %d\n", result.OrphanedVectorsDeleted)
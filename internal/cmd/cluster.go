@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// cluster 命令 - 按嵌入对集合中的文档聚类
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Cluster a collection's documents by embedding",
+	Long: `Group a collection's documents into topics with k-means over their
+embeddings (the average of each document's chunk vectors), label each cluster
+with the generate model, and store every document's cluster so results can be
+filtered with --cluster on search, vsearch and query.
+
+Re-running replaces the previous clusters of the collection.
+
+Examples:
+  mmq cluster -c notes -k 20
+  mmq cluster -c notes --no-label     # keyword labels, no LLM
+  mmq cluster list -c notes
+  mmq cluster show 3 -c notes
+  mmq cluster label 3 "release notes" -c notes
+  mmq query "deploy" -c notes --cluster 3`,
+	Args: cobra.NoArgs,
+	RunE: runCluster,
+}
+
+var clusterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored clusters (all collections unless -c is given)",
+	Args:  cobra.NoArgs,
+	RunE:  runClusterList,
+}
+
+var clusterShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the documents of a cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runClusterShow,
+}
+
+var clusterLabelCmd = &cobra.Command{
+	Use:   "label <id> <label>",
+	Short: "Rename a cluster",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runClusterLabel,
+}
+
+var (
+	clusterK       int
+	clusterIters   int
+	clusterNoLabel bool
+)
+
+func init() {
+	clusterCmd.Flags().IntVarP(&clusterK, "k", "k", 0, "Number of clusters (default: √(documents/2), 2-50)")
+	clusterCmd.Flags().IntVar(&clusterIters, "iterations", 50, "Maximum k-means iterations")
+	clusterCmd.Flags().BoolVar(&clusterNoLabel, "no-label", false, "Label clusters with frequent keywords instead of the LLM")
+	clusterCmd.AddCommand(clusterListCmd)
+	clusterCmd.AddCommand(clusterShowCmd)
+	clusterCmd.AddCommand(clusterLabelCmd)
+	rootCmd.AddCommand(clusterCmd)
+}
+
+func runCluster(cmd *cobra.Command, args []string) error {
+	if collectionFlag == "" {
+		return fmt.Errorf("a collection is required (-c <name>)")
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	clusters, err := m.ClusterCollection(mmq.ClusterOptions{
+		Collection: collectionFlag,
+		K:          clusterK,
+		Iterations: clusterIters,
+		NoLLM:      clusterNoLabel,
+	})
+	if err != nil {
+		return fmt.Errorf("clustering failed: %w", err)
+	}
+
+	if f := format.Format(outputFormat); f != format.FormatText {
+		return format.OutputClusters(clusters, f, 3)
+	}
+	docs := 0
+	for _, c := range clusters {
+		docs += c.Size
+	}
+	fmt.Printf("✓ Clustered %d document(s) in '%s' into %d cluster(s)\n\n", docs, collectionFlag, len(clusters))
+	return format.OutputClusters(clusters, format.FormatText, 3)
+}
+
+func runClusterList(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	clusters, err := m.ListClusters(collectionFlag)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 && format.Format(outputFormat) == format.FormatText {
+		fmt.Println("No clusters (run 'mmq cluster -c <collection>' first)")
+		return nil
+	}
+	return format.OutputClusters(clusters, format.Format(outputFormat), 0)
+}
+
+func runClusterShow(cmd *cobra.Command, args []string) error {
+	id, err := clusterArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	cluster, err := m.GetCluster(collectionFlag, id)
+	if err != nil {
+		return err
+	}
+	return format.OutputCluster(cluster, format.Format(outputFormat))
+}
+
+func runClusterLabel(cmd *cobra.Command, args []string) error {
+	id, err := clusterArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	label := strings.Join(args[1:], " ")
+	if err := m.SetClusterLabel(collectionFlag, id, label); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Cluster %d in '%s' labeled %q\n", id, collectionFlag, label)
+	return nil
+}
+
+// clusterArg 解析聚类 id，show 和 label 需要 -c
+func clusterArg(s string) (int, error) {
+	if collectionFlag == "" {
+		return 0, usageError(fmt.Errorf("a collection is required (-c <name>)"))
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, usageError(fmt.Errorf("invalid cluster id %q", s))
+	}
+	return id, nil
+}
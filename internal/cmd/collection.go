@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/dyike/mmq/internal/format"
 	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/dyike/mmq/pkg/s3"
 	"github.com/spf13/cobra"
 )
 
@@ -18,8 +20,26 @@ var collectionCmd = &cobra.Command{
 var collectionAddCmd = &cobra.Command{
 	Use:   "add <path>",
 	Short: "Add a new collection",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runCollectionAdd,
+	Long: `Add a collection from a local directory or an S3-compatible bucket
+(s3://bucket/prefix). Bucket credentials come from AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN (anonymous if unset); set
+AWS_ENDPOINT_URL_S3 or "s3.endpoint" in the config for MinIO, R2 and other
+S3-compatible stores. On 'mmq update' only objects whose ETag changed are
+downloaded again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCollectionAdd,
+}
+
+var collectionAddRemoteCmd = &cobra.Command{
+	Use:   "add-remote <name> <remote>",
+	Short: "Add a collection backed by another mmq database",
+	Long: `Add a collection whose documents live in another mmq database (a path or
+file:// URL, e.g. a team corpus on a shared drive). The remote is searched in
+place on every search/query and its results are fused with local results;
+'mmq update' skips it. Vectors in the remote database must come from the same
+embedding model.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCollectionAddRemote,
 }
 
 var collectionListCmd = &cobra.Command{
@@ -31,8 +51,12 @@ var collectionListCmd = &cobra.Command{
 var collectionRemoveCmd = &cobra.Command{
 	Use:   "remove <name>",
 	Short: "Remove a collection",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runCollectionRemove,
+	Long: `Remove a collection from the index. By default documents are only
+deactivated and their content is kept; --hard deletes the documents, content
+and embeddings no other collection uses, and the collection's contexts in a
+single transaction.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCollectionRemove,
 }
 
 var collectionRenameCmd = &cobra.Command{
@@ -46,6 +70,11 @@ var (
 	collectionName string
 	collectionMask string
 	indexNow       bool
+	collectionPII  string
+	collectionMode string
+
+	remoteCollectionName string
+	hardRemove           bool
 )
 
 func init() {
@@ -53,10 +82,17 @@ func init() {
 	collectionAddCmd.Flags().StringVarP(&collectionName, "name", "n", "", "Collection name (required)")
 	collectionAddCmd.Flags().StringVarP(&collectionMask, "mask", "m", "**/*.md", "File glob pattern")
 	collectionAddCmd.Flags().BoolVar(&indexNow, "index", false, "Index documents immediately")
+	collectionAddCmd.Flags().StringVar(&collectionPII, "pii", "", "PII policy when indexing: off, flag or redact (default from config)")
+	collectionAddCmd.Flags().StringVar(&collectionMode, "mode", "", "Index mode: full or summary (store only model-written summaries)")
 	collectionAddCmd.MarkFlagRequired("name")
 
+	collectionRemoveCmd.Flags().BoolVar(&hardRemove, "hard", false, "Delete documents, unused content, embeddings and contexts instead of deactivating")
+
+	collectionAddRemoteCmd.Flags().StringVar(&remoteCollectionName, "remote-collection", "", "Collection name in the remote database (default: same as <name>)")
+
 	// 添加子命令
 	collectionCmd.AddCommand(collectionAddCmd)
+	collectionCmd.AddCommand(collectionAddRemoteCmd)
 	collectionCmd.AddCommand(collectionListCmd)
 	collectionCmd.AddCommand(collectionRemoveCmd)
 	collectionCmd.AddCommand(collectionRenameCmd)
@@ -65,15 +101,18 @@ func init() {
 func runCollectionAdd(cmd *cobra.Command, args []string) error {
 	path := args[0]
 
-	// 展开路径
-	if path[:2] == "~/" {
-		homeDir, _ := os.UserHomeDir()
-		path = homeDir + path[1:]
-	}
+	// 对象存储（s3://bucket/prefix）在索引时列出对象
+	if !s3.IsURL(path) {
+		// 展开路径
+		if strings.HasPrefix(path, "~/") {
+			homeDir, _ := os.UserHomeDir()
+			path = homeDir + path[1:]
+		}
 
-	// 检查路径是否存在
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("path does not exist: %s", path)
+		// 检查路径是否存在
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return fmt.Errorf("path does not exist: %s", path)
+		}
 	}
 
 	m, err := getMMQ()
@@ -84,7 +123,9 @@ func runCollectionAdd(cmd *cobra.Command, args []string) error {
 
 	// 创建集合
 	err = m.CreateCollection(collectionName, path, mmq.CollectionOptions{
-		Mask: collectionMask,
+		Mask:      collectionMask,
+		PIIPolicy: collectionPII,
+		IndexMode: collectionMode,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
@@ -95,11 +136,12 @@ func runCollectionAdd(cmd *cobra.Command, args []string) error {
 	// 如果指定了索引，立即索引
 	if indexNow {
 		fmt.Println("Indexing documents...")
-		err = m.IndexDirectory(path, mmq.IndexOptions{
+		summary, err := m.IndexDirectory(path, mmq.IndexOptions{
 			Collection: collectionName,
 			Mask:       collectionMask,
 			Recursive:  true,
 		})
+		printIndexSummary(summary)
 		if err != nil {
 			return fmt.Errorf("failed to index documents: %w", err)
 		}
@@ -116,6 +158,27 @@ func runCollectionAdd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runCollectionAddRemote(cmd *cobra.Command, args []string) error {
+	name, remote := args[0], args[1]
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.AddRemoteCollection(name, remote, remoteCollectionName); err != nil {
+		return fmt.Errorf("failed to add remote collection: %w", err)
+	}
+
+	coll, err := m.GetCollection(name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added remote collection '%s' → %s (%s)\n", name, coll.Remote, coll.RemoteCollection)
+	return nil
+}
+
 func runCollectionList(cmd *cobra.Command, args []string) error {
 	m, err := getMMQ()
 	if err != nil {
@@ -155,6 +218,9 @@ func runCollectionRemove(cmd *cobra.Command, args []string) error {
 	// 确认删除
 	fmt.Printf("Remove collection '%s' (%s)?\n", name, coll.Path)
 	fmt.Printf("This will remove %d documents from the index.\n", coll.DocCount)
+	if hardRemove {
+		fmt.Println("Content, embeddings and contexts used only by this collection are deleted permanently.")
+	}
 	fmt.Print("Continue? (y/N): ")
 
 	var confirm string
@@ -165,6 +231,20 @@ func runCollectionRemove(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if hardRemove {
+		result, err := m.HardRemoveCollection(name)
+		if err != nil {
+			return fmt.Errorf("failed to remove collection: %w", err)
+		}
+		fmt.Printf("Removed collection '%s'\n", name)
+		fmt.Printf("  Documents deleted:  %d\n", result.Documents)
+		fmt.Printf("  Content deleted:    %d\n", result.Content)
+		fmt.Printf("  Vectors deleted:    %d\n", result.Vectors)
+		fmt.Printf("  Contexts deleted:   %d\n", result.Contexts)
+		fmt.Printf("  Space freed:        %s (run 'mmq cleanup' to shrink the database file)\n", formatBytes(result.FreedBytes))
+		return nil
+	}
+
 	err = m.RemoveCollection(name)
 	if err != nil {
 		return fmt.Errorf("failed to remove collection: %w", err)
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchQueries int
+	benchSeed    int64
+)
+
+// collection bench 子命令
+var collectionBenchCmd = &cobra.Command{
+	Use:   "bench <name>",
+	Short: "Benchmark full-text vs vector search and record a default strategy",
+	Long: `Run a small self-benchmark on a collection: synthetic queries are built
+from document titles and headings, and full-text and vector search are scored
+by how high they rank the source document (MRR over the top 10).
+
+The recommended strategy (fts, vector, or hybrid when both perform alike) is
+recorded for the collection and used by 'mmq query --auto' and by library
+callers passing StrategyAuto. Run it after 'mmq embed', or use 'mmq embed --bench'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCollectionBench,
+}
+
+func init() {
+	collectionBenchCmd.Flags().IntVarP(&benchQueries, "queries", "n", 20, "Number of synthetic queries")
+	collectionBenchCmd.Flags().Int64Var(&benchSeed, "seed", 0, "Sampling seed (default 1, reproducible)")
+	collectionCmd.AddCommand(collectionBenchCmd)
+}
+
+func runCollectionBench(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	bench, err := m.BenchCollection(args[0], mmq.BenchOptions{Queries: benchQueries, Seed: benchSeed})
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindStrategyBench, bench)
+	}
+	printStrategyBench(bench)
+	return nil
+}
+
+// printStrategyBench 输出自测结果
+func printStrategyBench(b *mmq.StrategyBench) {
+	fmt.Printf("Collection: %s (%d queries)\n", b.Collection, b.Queries)
+	fmt.Printf("  fts     MRR %s  hits %d/%d\n", format.Decimal(b.FTS.MRR, 2), b.FTS.Hits, b.Queries)
+	fmt.Printf("  vector  MRR %s  hits %d/%d\n", format.Decimal(b.Vector.MRR, 2), b.Vector.Hits, b.Queries)
+	fmt.Printf("  Recommended: %s (%s)\n", b.Recommended, b.Reason)
+}
+
+// benchAllCollections 对所有本地集合自测（embed --bench）
+func benchAllCollections(m *mmq.MMQ) error {
+	collections, err := m.ListCollections()
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	fmt.Println()
+	for _, c := range collections {
+		if c.Remote != "" || c.DocCount == 0 {
+			continue
+		}
+		bench, err := m.BenchCollection(c.Name, mmq.BenchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to benchmark %s: %w", c.Name, err)
+		}
+		printStrategyBench(bench)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeInto       string
+	mergeOnConflict string
+)
+
+// collection clone 子命令
+var collectionCloneCmd = &cobra.Command{
+	Use:   "clone <src> <dst>",
+	Short: "Copy a collection's documents, contexts and embeddings",
+	Long: `Create <dst> as a copy of <src>: documents, contexts and collection settings.
+Embeddings are shared by content hash, so nothing needs to be re-embedded.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCollectionClone,
+}
+
+// collection merge 子命令
+var collectionMergeCmd = &cobra.Command{
+	Use:   "merge <collection>... --into <name>",
+	Short: "Merge collections into one",
+	Long: `Copy the documents and contexts of one or more collections into --into,
+creating it if needed. Source collections are left untouched.
+
+Duplicate paths are resolved with --on-conflict:
+  skip      - keep the document already in the target (default)
+  overwrite - replace it with the source document
+  newer     - keep whichever was modified last
+  rename    - keep both, storing the source as name.<source>.ext
+
+Examples:
+  mmq collection merge work personal --into notes
+  mmq collection merge a b --into c --on-conflict newer`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCollectionMerge,
+}
+
+func init() {
+	collectionMergeCmd.Flags().StringVar(&mergeInto, "into", "", "Target collection (required)")
+	collectionMergeCmd.Flags().StringVar(&mergeOnConflict, "on-conflict", "skip", "Duplicate path handling: skip, overwrite, newer, rename")
+	collectionMergeCmd.MarkFlagRequired("into")
+
+	collectionCmd.AddCommand(collectionCloneCmd)
+	collectionCmd.AddCommand(collectionMergeCmd)
+}
+
+func runCollectionClone(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	result, err := m.CloneCollection(args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to clone collection: %w", err)
+	}
+
+	fmt.Printf("Cloned '%s' to '%s': %d documents, %d contexts\n",
+		args[0], args[1], result.Documents, result.Contexts)
+	return nil
+}
+
+func runCollectionMerge(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	result, err := m.MergeCollections(args, mergeInto, mmq.MergeConflict(mergeOnConflict))
+	if err != nil {
+		return fmt.Errorf("failed to merge collections: %w", err)
+	}
+
+	fmt.Printf("Merged %d collection(s) into '%s': %d documents, %d contexts\n",
+		len(args), mergeInto, result.Documents, result.Contexts)
+	if result.Conflicts > 0 {
+		fmt.Printf("Conflicts: %d (%d skipped, %d renamed)\n", result.Conflicts, result.Skipped, result.Renamed)
+	}
+	fmt.Println("Source collections were kept; remove them with 'mmq collection remove' if no longer needed.")
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// collection embed-model 子命令
+var collectionEmbedModelCmd = &cobra.Command{
+	Use:   "embed-model <name> [model|default]",
+	Short: "Show or set a collection's embedding model",
+	Long: `Show or set the embedding model used for a collection, e.g. a code-specific
+embedder for a source code collection. Use 'default' to go back to the
+configured embedding model.
+
+Each model keeps its own vectors. Searches across collections with different
+models embed the query once per model and fuse the results.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runCollectionEmbedModel,
+}
+
+func init() {
+	collectionCmd.AddCommand(collectionEmbedModelCmd)
+}
+
+func runCollectionEmbedModel(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	name := args[0]
+
+	if len(args) == 2 {
+		model := args[1]
+		if model == "default" {
+			model = ""
+		}
+		if err := m.SetCollectionEmbedModel(name, model); err != nil {
+			return err
+		}
+	}
+
+	model, err := m.CollectionEmbedModel(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Collection '%s' embedding model: %s\n", name, model)
+	if len(args) == 2 {
+		fmt.Println("Run 'mmq embed' to generate embeddings with the new model")
+	}
+	return nil
+}
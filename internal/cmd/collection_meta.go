@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+var metaJSONValues bool
+
+// collection meta 子命令
+var collectionMetaCmd = &cobra.Command{
+	Use:   "meta <name> [key=value|key=|key]...",
+	Short: "Show or edit a collection's metadata",
+	Long: `Show or edit free-form key/value metadata attached to a collection,
+such as a description, owner, icon or sync cursor.
+
+  key=value  set a key
+  key=       remove a key
+  key        print a single value
+
+Examples:
+  mmq collection meta notes
+  mmq collection meta notes description="Team notes" owner=alice
+  mmq collection meta notes cursor='{"page":3}' --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCollectionMeta,
+}
+
+func init() {
+	collectionMetaCmd.Flags().BoolVar(&metaJSONValues, "json", false, "Parse values as JSON instead of plain strings")
+	collectionCmd.AddCommand(collectionMetaCmd)
+}
+
+func runCollectionMeta(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	name := args[0]
+
+	// 解析 key=value 参数
+	updates := make(map[string]interface{})
+	var lookups []string
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if key == "" {
+			return fmt.Errorf("invalid metadata argument: %s", arg)
+		}
+		switch {
+		case !ok:
+			lookups = append(lookups, key)
+		case value == "":
+			updates[key] = nil
+		case metaJSONValues:
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				return fmt.Errorf("invalid JSON value for %s: %w", key, err)
+			}
+			updates[key] = v
+		default:
+			updates[key] = value
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := m.SetCollectionMetadata(name, updates); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := m.GetCollectionMetadata(name)
+	if err != nil {
+		return err
+	}
+
+	if len(lookups) > 0 {
+		for _, key := range lookups {
+			v, ok := metadata[key]
+			if !ok {
+				return fmt.Errorf("metadata key '%s' not set on collection '%s'", key, name)
+			}
+			fmt.Println(formatMetaValue(v))
+		}
+		return nil
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindCollectionMeta, metadata)
+	}
+
+	if len(metadata) == 0 {
+		fmt.Printf("Collection '%s' has no metadata\n", name)
+		return nil
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s=%s\n", k, formatMetaValue(metadata[k]))
+	}
+	return nil
+}
+
+// formatMetaValue 字符串原样输出，其他类型输出 JSON
+func formatMetaValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// collection mode 子命令
+var collectionModeCmd = &cobra.Command{
+	Use:   "mode <name> [full|summary]",
+	Short: "Show or set a collection's index mode",
+	Long: `Show or set how a collection's documents are stored:
+  full    - store the full text (default)
+  summary - store only a summary written by the generation model, plus its
+            embeddings; search results point to the source file, which stays
+            the only copy of the full text
+
+Use summary for sensitive collections. The generation model reads each new or
+changed file once while indexing; unchanged files keep their summary. After
+switching an existing collection, run 'mmq update' and then 'mmq cleanup' to
+drop the full text stored before.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runCollectionMode,
+}
+
+func init() {
+	collectionCmd.AddCommand(collectionModeCmd)
+}
+
+func runCollectionMode(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	name := args[0]
+
+	if len(args) == 2 {
+		if err := m.SetCollectionIndexMode(name, args[1]); err != nil {
+			return err
+		}
+	}
+
+	mode, err := m.CollectionIndexMode(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Collection '%s' index mode: %s\n", name, mode)
+	if len(args) == 2 {
+		fmt.Println("Run 'mmq update' to re-index with the new mode")
+		if mode == mmq.IndexModeSummary {
+			fmt.Println("Then run 'mmq cleanup' to remove full text stored by earlier indexing")
+		}
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/mmq"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +27,12 @@ Examples:
   mmq context add "Global context"              # Add to current directory
   mmq context add / "Global context"            # Add global context
   mmq context add mmq://docs "Documentation"    # Add for collection
-  mmq context add mmq://docs/api "API docs"     # Add for path`,
+  mmq context add mmq://docs/api "API docs"     # Add for path
+  mmq context add 'mmq://docs/**/api/*.md' "API reference page"  # Glob pattern
+
+When several contexts match a document, more specific ones take priority:
+literal path segments outweigh partial globs (*.md), which outweigh * and **;
+on a tie an exact path wins over a pattern.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runContextAdd,
 }
@@ -39,6 +49,36 @@ var contextCheckCmd = &cobra.Command{
 	RunE:  runContextCheck,
 }
 
+var contextTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the context hierarchy and coverage",
+	Long: `Show stored contexts as a tree (global → collection → path → document)
+with the number of documents each context is injected into.
+
+Collections without a context are marked, and "uncovered" counts documents
+that get no collection, path or document context.`,
+	RunE: runContextTree,
+}
+
+var contextSuggestCmd = &cobra.Command{
+	Use:   "suggest <collection>",
+	Short: "Draft a collection context from sampled documents",
+	Long: `Sample documents from a collection and ask a model to draft a
+collection-level context (mmq://<collection>). The draft can be accepted,
+edited or regenerated before it is saved.
+
+Uses the external API when DEEPSEEK_API_KEY or OPENAI_API_KEY is set,
+otherwise the local generate model (--local forces the local model).`,
+	Args: cobra.ExactArgs(1),
+	RunE: runContextSuggest,
+}
+
+var (
+	suggestSamples int
+	suggestYes     bool
+	suggestLocal   bool
+)
+
 var contextRmCmd = &cobra.Command{
 	Use:   "rm <path>",
 	Short: "Remove context",
@@ -50,6 +90,12 @@ func init() {
 	contextCmd.AddCommand(contextAddCmd)
 	contextCmd.AddCommand(contextListCmd)
 	contextCmd.AddCommand(contextCheckCmd)
+	contextCmd.AddCommand(contextTreeCmd)
+	contextCmd.AddCommand(contextSuggestCmd)
+
+	contextSuggestCmd.Flags().IntVar(&suggestSamples, "samples", 8, "Number of documents to sample")
+	contextSuggestCmd.Flags().BoolVarP(&suggestYes, "yes", "y", false, "Save the draft without asking")
+	contextSuggestCmd.Flags().BoolVar(&suggestLocal, "local", false, "Use the local generate model even if an API key is set")
 	contextCmd.AddCommand(contextRmCmd)
 }
 
@@ -128,6 +174,84 @@ func runContextCheck(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runContextTree(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	root, err := m.ContextTree()
+	if err != nil {
+		return fmt.Errorf("failed to build context tree: %w", err)
+	}
+
+	return format.OutputContextTree(root, format.Format(outputFormat))
+}
+
+func runContextSuggest(cmd *cobra.Command, args []string) error {
+	collection := args[0]
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	opts := mmq.SuggestContextOptions{Samples: suggestSamples}
+	if apiClient := llm.NewAPIClient(); !suggestLocal && apiClient.IsConfigured() {
+		opts.Generator = func(prompt string) (string, error) {
+			return apiClient.Chat([]llm.ChatMessage{{Role: "user", Content: prompt}}, 0.3, 256)
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		suggestion, err := m.SuggestContext(collection, opts)
+		if err != nil {
+			return fmt.Errorf("failed to suggest context: %w", err)
+		}
+
+		fmt.Printf("Sampled %d documents from '%s'\n", len(suggestion.Samples), collection)
+		if suggestion.Existing != "" {
+			fmt.Printf("\nCurrent context:\n  %s\n", suggestion.Existing)
+		}
+		fmt.Printf("\nSuggested context for %s:\n  %s\n", suggestion.Path, suggestion.Content)
+
+		content := suggestion.Content
+		if !suggestYes {
+			fmt.Print("\n[a]ccept  [e]dit  [r]egenerate  [q]uit > ")
+			if !scanner.Scan() {
+				return nil
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "a", "accept", "y", "yes":
+			case "e", "edit":
+				fmt.Print("New content (empty to cancel): ")
+				if !scanner.Scan() {
+					return nil
+				}
+				content = strings.TrimSpace(scanner.Text())
+				if content == "" {
+					fmt.Println("Cancelled")
+					return nil
+				}
+			case "r", "regenerate":
+				continue
+			default:
+				fmt.Println("Cancelled")
+				return nil
+			}
+		}
+
+		if err := m.AddContext(suggestion.Path, content); err != nil {
+			return fmt.Errorf("failed to add context: %w", err)
+		}
+		fmt.Printf("Added context for '%s'\n", suggestion.Path)
+		return nil
+	}
+}
+
 func runContextRm(cmd *cobra.Command, args []string) error {
 	path := args[0]
 
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// db 命令组 - 数据库文件维护
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the database file",
+}
+
+var dbStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show page, free-list, WAL and per-table sizes with maintenance advice",
+	Long: `Report how the database file is used: page size and count, free-list
+pages (reclaimed by VACUUM), WAL size, estimated sizes of documents,
+content, full-text index, vectors, memories and the LLM cache, and data
+left behind by removed documents.
+
+Based on these numbers it recommends maintenance: 'mmq purge' for stale
+vectors, 'mmq cleanup' for inactive documents and free pages, moving a
+large LLM cache out of the database, or rebuilding a bloated full-text
+index. Table sizes are estimated from column data and exclude page
+overhead and indexes. Scans every table, so it can take a while.`,
+	Args: cobra.NoArgs,
+	RunE: runDBStats,
+}
+
+func init() {
+	dbCmd.AddCommand(dbStatsCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+func runDBStats(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	stats, err := m.DBStats()
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindDBStats, stats)
+	}
+
+	fmt.Printf("Database: %s\n", stats.Path)
+	fmt.Printf("File size: %s (%s pages × %s)\n", mmq.FormatSize(stats.FileSize), format.Number(stats.PageCount), mmq.FormatSize(stats.PageSize))
+	fmt.Printf("Free pages: %s (%s, %.1f%%)\n", format.Number(stats.FreelistCount), mmq.FormatSize(stats.FreeBytes), stats.Fragmentation*100)
+	fmt.Printf("WAL size: %s\n", mmq.FormatSize(stats.WALSize))
+
+	fmt.Println()
+	fmt.Printf("%-10s %12s %12s  %s\n", "Data", "Rows", "Size", "Tables")
+	for _, t := range stats.Tables {
+		tables := strings.Join(t.Tables, ", ")
+		if len(t.Tables) > 4 {
+			tables = fmt.Sprintf("%s, … (%d tables)", strings.Join(t.Tables[:3], ", "), len(t.Tables))
+		}
+		fmt.Printf("%-10s %12s %12s  %s\n", t.Name, format.Number(t.Rows), mmq.FormatSize(t.Bytes), tables)
+	}
+
+	fmt.Println()
+	fmt.Printf("Inactive documents: %s\n", format.Number(int64(stats.InactiveDocuments)))
+	fmt.Printf("Orphaned content:   %s\n", format.Number(int64(stats.OrphanedContent)))
+	fmt.Printf("Stale vectors:      %s\n", format.Number(int64(stats.StaleVectors)))
+	fmt.Printf("Stale FTS rows:     %s\n", format.Number(int64(stats.StaleFTSRows)))
+	fmt.Printf("LLM cache entries:  %s\n", format.Number(int64(stats.CacheEntries)))
+
+	fmt.Println()
+	if len(stats.Recommendations) == 0 {
+		fmt.Println("✓ No maintenance needed")
+		return nil
+	}
+	fmt.Println("Recommendations:")
+	for _, r := range stats.Recommendations {
+		fmt.Printf("  • %s\n", r.Reason)
+		if r.Command != "" {
+			fmt.Printf("    run: %s\n", r.Command)
+		}
+	}
+	return nil
+}
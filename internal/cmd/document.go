@@ -19,7 +19,9 @@ Examples:
   mmq ls                    # List all documents
   mmq ls docs               # List documents in 'docs' collection
   mmq ls docs/api           # List documents in 'docs/api' path
-  mmq ls mmq://docs/2024    # List using mmq:// URI`,
+  mmq ls mmq://docs/2024    # List using mmq:// URI
+  mmq ls docs --sort words --desc --min-words 5000   # Largest documents first
+  mmq ls --sort reading --max-words 300              # Quick reads`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runLs,
 }
@@ -64,9 +66,20 @@ var (
 	lineNumbers bool
 	maxLines    int
 	maxBytes    int
+
+	lsSort     string
+	lsDesc     bool
+	lsMinWords int
+	lsMaxWords int
 )
 
 func init() {
+	// ls 标志
+	lsCmd.Flags().StringVar(&lsSort, "sort", "path", "Sort by path, title, modified, words, chars or reading")
+	lsCmd.Flags().BoolVar(&lsDesc, "desc", false, "Sort in descending order")
+	lsCmd.Flags().IntVar(&lsMinWords, "min-words", 0, "Only documents with at least this many words")
+	lsCmd.Flags().IntVar(&lsMaxWords, "max-words", 0, "Only documents with at most this many words (0=no limit)")
+
 	// get 标志
 	getCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
 	getCmd.Flags().BoolVar(&lineNumbers, "line-numbers", false, "Add line numbers")
@@ -81,6 +94,11 @@ func init() {
 func runLs(cmd *cobra.Command, args []string) error {
 	var coll, path string
 
+	sortField, err := mmq.ParseDocumentSortField(lsSort)
+	if err != nil {
+		return err
+	}
+
 	if len(args) > 0 {
 		// 解析 collection[/path]
 		arg := strings.TrimPrefix(strings.TrimPrefix(args[0], "mmq://"), "qmd://")
@@ -112,6 +130,21 @@ func runLs(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if lsMinWords > 0 || lsMaxWords > 0 {
+		filtered := docs[:0]
+		for _, d := range docs {
+			if d.WordCount >= lsMinWords && (lsMaxWords <= 0 || d.WordCount <= lsMaxWords) {
+				filtered = append(filtered, d)
+			}
+		}
+		docs = filtered
+		if len(docs) == 0 {
+			fmt.Println("No documents match the word count filter")
+			return nil
+		}
+	}
+	mmq.SortDocuments(docs, sortField, lsDesc)
+
 	return format.OutputDocumentList(docs, format.Format(outputFormat))
 }
 
@@ -124,28 +157,23 @@ func runGet(cmd *cobra.Command, args []string) error {
 	}
 	defer m.Close()
 
-	var doc *mmq.DocumentDetail
-
-	// 判断是 docid 还是路径
-	if strings.HasPrefix(identifier, "#") || !strings.Contains(identifier, "/") {
-		// 按 docid 获取
-		d, err := m.GetDocumentByID(identifier)
-		if err != nil {
-			return fmt.Errorf("failed to get document: %w", err)
-		}
-		doc = d
-	} else {
-		// 按路径获取
-		d, err := m.GetDocumentByPath(identifier)
-		if err != nil {
-			return fmt.Errorf("failed to get document: %w", err)
-		}
-		doc = d
+	doc, err := getDocumentDetail(m, identifier)
+	if err != nil {
+		return fmt.Errorf("failed to get document: %w", err)
 	}
+	m.RecordDocumentAccess(doc.Collection, doc.Path) // 供 mmq recent 使用，失败不影响输出
 
 	return format.OutputDocumentDetail(doc, format.Format(outputFormat), fullContent, lineNumbers)
 }
 
+// getDocumentDetail 按 docid（#abc123 或 abc123）或 collection/path 获取文档
+func getDocumentDetail(m *mmq.MMQ, identifier string) (*mmq.DocumentDetail, error) {
+	if strings.HasPrefix(identifier, "#") || !strings.Contains(identifier, "/") {
+		return m.GetDocumentByID(identifier)
+	}
+	return m.GetDocumentByPath(identifier)
+}
+
 func runMultiGet(cmd *cobra.Command, args []string) error {
 	pattern := args[0]
 
@@ -164,6 +192,9 @@ func runMultiGet(cmd *cobra.Command, args []string) error {
 		fmt.Println("No documents found")
 		return nil
 	}
+	for _, d := range docs {
+		m.RecordDocumentAccess(d.Collection, d.Path)
+	}
 
 	// 限制行数
 	if maxLines > 0 {
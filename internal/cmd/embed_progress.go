@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// embedProgressUI mmq embed 的进度显示
+// 终端上原地刷新一行进度条；输出被重定向时每 10% 或每 10 秒打印一行
+type embedProgressUI struct {
+	w         io.Writer
+	tty       bool
+	drawn     bool // 终端上当前行是进度条
+	lastPrint time.Time
+	lastStep  int
+}
+
+func newEmbedProgressUI(w io.Writer) *embedProgressUI {
+	return &embedProgressUI{w: w, tty: isTerminal(w), lastStep: -1}
+}
+
+// isTerminal w 是否为终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (u *embedProgressUI) update(p mmq.EmbedProgress) {
+	switch p.Stage {
+	case mmq.EmbedStageLoading:
+		u.println(fmt.Sprintf("Loading embedding model %s...", p.Model))
+	case mmq.EmbedStageLoaded:
+		u.println(fmt.Sprintf("Model %s ready (%s)", p.Model, p.LoadTime.Round(100*time.Millisecond)))
+	case mmq.EmbedStageFailed:
+		u.println(fmt.Sprintf("  Failed: %s", p.Path))
+	}
+
+	if u.tty {
+		fmt.Fprintf(u.w, "\r\033[K%s", progressLine(p, true))
+		u.drawn = true
+		return
+	}
+
+	// 非终端：每 10% 或每 10 秒一行
+	step := 0
+	if p.TotalChunks > 0 {
+		step = p.Chunks * 10 / p.TotalChunks
+	}
+	if step != u.lastStep || time.Since(u.lastPrint) >= 10*time.Second {
+		u.lastStep = step
+		u.lastPrint = time.Now()
+		fmt.Fprintln(u.w, progressLine(p, false))
+	}
+}
+
+// println 在进度条上方输出一行
+func (u *embedProgressUI) println(line string) {
+	if u.drawn {
+		fmt.Fprint(u.w, "\r\033[K")
+		u.drawn = false
+	}
+	fmt.Fprintln(u.w, line)
+}
+
+// interrupt 提示收到中断
+func (u *embedProgressUI) interrupt() {
+	u.println("Interrupted: stopping after the current chunk (press Ctrl-C again to quit immediately)")
+}
+
+// finish 结束进度条所在行
+func (u *embedProgressUI) finish() {
+	if u.drawn {
+		fmt.Fprintln(u.w)
+		u.drawn = false
+	}
+}
+
+// interruptContext 返回在 Ctrl-C 或 SIGTERM 时取消的 context，并提示在当前块完成后停止
+// 第一次信号后恢复默认处理，再按一次立即退出；stop 停止监听
+func (u *embedProgressUI) interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sigCh; !ok {
+			return
+		}
+		signal.Stop(sigCh)
+		u.interrupt()
+		cancel()
+	}()
+	return ctx, func() {
+		signal.Stop(sigCh)
+		close(sigCh)
+		cancel()
+	}
+}
+
+// progressLine 进度行：进度条、百分比、块数、文档数、速率和剩余时间
+func progressLine(p mmq.EmbedProgress, bar bool) string {
+	pct := 100.0
+	if p.TotalChunks > 0 {
+		pct = float64(p.Chunks) * 100 / float64(p.TotalChunks)
+	}
+
+	var b strings.Builder
+	if bar {
+		const width = 24
+		filled := int(pct / 100 * width)
+		b.WriteString("[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "] ")
+	}
+	fmt.Fprintf(&b, "%5.1f%%  %d/%d chunks  %d/%d docs", pct, p.Chunks, p.TotalChunks, p.Documents, p.TotalDocs)
+	if p.Rate > 0 {
+		fmt.Fprintf(&b, "  %.1f chunks/s", p.Rate)
+	}
+	if p.ETA > 0 {
+		fmt.Fprintf(&b, "  ETA %s", p.ETA.Round(time.Second))
+	}
+	return b.String()
+}
+
+// printEmbedSummary 输出嵌入报告：嵌入、跳过、失败的块数和失败原因
+func printEmbedSummary(report *mmq.EmbedReport) {
+	fmt.Println("Embedding summary:")
+	fmt.Printf("  Documents: %d/%d embedded", report.Embedded, report.Documents)
+	if report.Resumed > 0 {
+		fmt.Printf(" (%d resumed)", report.Resumed)
+	}
+	fmt.Println()
+	fmt.Printf("  Chunks:    %d embedded, %d skipped (already embedded), %d failed\n",
+		report.Chunks, report.SkippedChunks, report.FailedChunks)
+	fmt.Printf("  Time:      %s", report.Elapsed.Round(time.Second))
+	if secs := report.Elapsed.Seconds(); report.Chunks > 0 && secs > 0 {
+		fmt.Printf(" (%.1f chunks/s)", float64(report.Chunks)/secs)
+	}
+	fmt.Println()
+
+	if report.Interrupted {
+		fmt.Printf("\nInterrupted with %d documents not processed. Progress is saved;\n", report.Pending)
+		fmt.Println("run 'mmq embed --resume' to continue from the last embedded chunk.")
+	}
+
+	if len(report.Failed) > 0 {
+		fmt.Printf("\n%d documents failed:\n", len(report.Failed))
+		for _, f := range report.Failed {
+			fmt.Printf("  %s (chunk %d): %s\n", f.Path, f.Chunk, f.Error)
+		}
+		fmt.Println("\nRun 'mmq embed --resume' to retry them from the failed chunk.")
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/i18n"
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// 错误类别和对应的退出码
+const (
+	ErrCodeInternal = "internal"  // 其他错误
+	ErrCodeUsage    = "usage"     // 参数、标志或子命令错误
+	ErrCodeNotFound = "not_found" // 集合、文档、记忆等不存在
+	ErrCodeConfig   = "config"    // 配置文件或配置项错误
+	ErrCodeModel    = "model"     // 推理库或模型不可用、后端不支持该能力
+	ErrCodeQuota    = "quota"     // 超出数据库或集合配额
+)
+
+var exitCodes = map[string]int{
+	ErrCodeInternal: 1,
+	ErrCodeUsage:    2,
+	ErrCodeNotFound: 3,
+	ErrCodeConfig:   4,
+	ErrCodeModel:    5,
+	ErrCodeQuota:    6,
+}
+
+// classError 明确了类别的错误
+type classError struct {
+	code string
+	err  error
+}
+
+func (e *classError) Error() string { return e.err.Error() }
+func (e *classError) Unwrap() error { return e.err }
+
+// usageError 标记参数或标志错误
+func usageError(err error) error {
+	return &classError{code: ErrCodeUsage, err: err}
+}
+
+// configError 标记配置错误
+func configError(err error) error {
+	return &classError{code: ErrCodeConfig, err: err}
+}
+
+// ErrorCode 返回错误的类别
+func ErrorCode(err error) string {
+	var ce *classError
+	switch {
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, mmq.ErrQuotaExceeded):
+		return ErrCodeQuota
+	case errors.Is(err, llm.ErrUnsupported):
+		return ErrCodeModel
+	case errors.Is(err, mmq.ErrCollectionNotFound), errors.Is(err, sql.ErrNoRows):
+		return ErrCodeNotFound
+	}
+
+	// 没有哨兵错误的情况按消息归类
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "unknown command"), strings.HasPrefix(msg, "unknown flag"),
+		strings.HasPrefix(msg, "unknown shorthand flag"), strings.HasPrefix(msg, "required flag"):
+		return ErrCodeUsage
+	case strings.Contains(msg, "invalid config"):
+		return ErrCodeConfig
+	case strings.Contains(msg, "yzma library not found"), strings.Contains(msg, "failed to create LLM"):
+		return ErrCodeModel
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "does not exist"):
+		return ErrCodeNotFound
+	}
+	return ErrCodeInternal
+}
+
+// ExitCode 返回错误对应的进程退出码（nil 为 0）
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return exitCodes[ErrorCode(err)]
+}
+
+// ErrorOutput JSON 模式下的错误输出
+type ErrorOutput struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody 错误的类别、消息和细节
+type ErrorBody struct {
+	Code     string                 `json:"code"`
+	Message  string                 `json:"message"`
+	ExitCode int                    `json:"exit_code"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// reportError 输出命令错误：JSON 格式下向标准输出写结构化错误，否则向标准错误打印文本
+func reportError(cmd *cobra.Command, err error) {
+	code := ErrorCode(err)
+	if !jsonOutputRequested() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", i18n.T("error"), localizeError(err))
+		if code == ErrCodeUsage && cmd != nil {
+			cmd.Usage()
+		}
+		return
+	}
+
+	body := ErrorBody{Code: code, Message: err.Error(), ExitCode: exitCodes[code]}
+	if cmd != nil {
+		body.Details = map[string]interface{}{"command": cmd.CommandPath()}
+	}
+	// 最内层的原因，便于按底层错误判断
+	cause := err
+	for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+		cause = e
+	}
+	if cause.Error() != body.Message {
+		if body.Details == nil {
+			body.Details = map[string]interface{}{}
+		}
+		body.Details["cause"] = cause.Error()
+	}
+	if err := format.OutputJSON(format.KindError, ErrorOutput{Error: body}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", body.Message)
+	}
+}
+
+// localizedErrors 文本输出中按当前语言显示的库哨兵错误（JSON 输出保持英文，便于程序判断）
+var localizedErrors = []struct {
+	err error
+	key string
+}{
+	{mmq.ErrCollectionNotFound, "err.collection_not_found"},
+	{mmq.ErrQuotaExceeded, "err.quota_exceeded"},
+	{mmq.ErrRebuildVerification, "err.rebuild_verification"},
+}
+
+// localizeError 把错误消息中的哨兵错误文本替换为当前语言
+func localizeError(err error) string {
+	msg := err.Error()
+	for _, le := range localizedErrors {
+		if errors.Is(err, le.err) {
+			msg = strings.Replace(msg, le.err.Error(), i18n.T(le.key), 1)
+		}
+	}
+	return msg
+}
+
+// jsonOutputRequested 是否要求 JSON 输出；标志解析失败时 outputFormat 可能未设置，再检查命令行参数
+func jsonOutputRequested() bool {
+	if isJSONFormat(outputFormat) {
+		return true
+	}
+	args := os.Args[1:]
+	for i, arg := range args {
+		switch {
+		case (arg == "--format" || arg == "-f") && i+1 < len(args):
+			if isJSONFormat(args[i+1]) {
+				return true
+			}
+		case strings.HasPrefix(arg, "--format="):
+			if isJSONFormat(strings.TrimPrefix(arg, "--format=")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isJSONFormat(f string) bool {
+	return format.Format(f) == format.FormatJSON || format.Format(f) == format.FormatJSONL
+}
+
+// markUsageErrors 把各命令的参数个数校验和标志解析错误标记为 usage 类别
+func markUsageErrors(c *cobra.Command) {
+	c.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return usageError(err)
+	})
+	if args := c.Args; args != nil {
+		c.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return usageError(err)
+			}
+			return nil
+		}
+	}
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -54,6 +54,11 @@ func runExpand(cmd *cobra.Command, args []string) error {
 	config := llm.DefaultModelConfig()
 	config.CacheDir = modelsDir
 	config.LibPath = os.Getenv("YZMA_LIB")
+	if config.LibPath == "" {
+		if cfg, err := loadConfig(); err == nil {
+			config.LibPath = cfg.LibPath
+		}
+	}
 
 	llmImpl, err := llm.NewLLM(config)
 	if err != nil {
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// feedback 命令 - 记录搜索结果的相关性反馈，并据此学习重排混合权重
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Record relevance feedback and tune rerank blending",
+	Long: `Reranked results blend the retrieval (RRF) position score with the
+reranker score, trusting retrieval more for the top ranks:
+
+  rank 1-3: 75% RRF   rank 4-10: 60% RRF   rank 11+: 40% RRF
+
+Record which results were relevant for your queries ('mmq feedback add',
+or :good/:bad in 'mmq repl'), then run 'mmq feedback tune' to learn blend
+weights for this database from them. Tuned weights are stored in the database
+and used by 'mmq query' from then on; "retrieval.rerank_blend" in the config
+file ({"top": 0.75, "mid": 0.6, "tail": 0.4}) overrides them.`,
+}
+
+var feedbackAddCmd = &cobra.Command{
+	Use:   "add <query> <doc>",
+	Short: "Mark a document as relevant (or --irrelevant) for a query",
+	Long: `Mark a document as relevant for a query. <doc> is collection/path,
+mmq://collection/path or a #docid.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runFeedbackAdd,
+}
+
+var feedbackListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded feedback",
+	Args:  cobra.NoArgs,
+	RunE:  runFeedbackList,
+}
+
+var feedbackTuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "Learn rerank blend weights from feedback or an eval file",
+	Long: `Run each judged query through hybrid retrieval and the reranker, then
+pick the blend weights that maximize mean average precision of the documents
+judged relevant. Uses the recorded feedback, or --eval with one JSON object
+per line:
+
+  {"query": "goroutine leak", "doc": "notes/go/leaks.md"}
+  {"query": "goroutine leak", "doc": "#a1b2c3", "relevant": false}
+
+"relevant" defaults to true. Requires a reranking model.`,
+	Args: cobra.NoArgs,
+	RunE: runFeedbackTune,
+}
+
+var feedbackResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Forget tuned weights (and recorded feedback with --all)",
+	Args:  cobra.NoArgs,
+	RunE:  runFeedbackReset,
+}
+
+var (
+	feedbackIrrelevant bool
+	feedbackEvalFile   string
+	feedbackDryRun     bool
+	feedbackResetAll   bool
+)
+
+func init() {
+	feedbackAddCmd.Flags().BoolVar(&feedbackIrrelevant, "irrelevant", false, "Mark the document as not relevant")
+	feedbackTuneCmd.Flags().StringVar(&feedbackEvalFile, "eval", "", "Judgments file (JSONL: query, doc, relevant) instead of recorded feedback")
+	feedbackTuneCmd.Flags().BoolVar(&feedbackDryRun, "dry-run", false, "Report the learned weights without saving them")
+	feedbackResetCmd.Flags().BoolVar(&feedbackResetAll, "all", false, "Also delete recorded feedback")
+
+	feedbackCmd.AddCommand(feedbackAddCmd, feedbackListCmd, feedbackTuneCmd, feedbackResetCmd)
+	rootCmd.AddCommand(feedbackCmd)
+}
+
+func runFeedbackAdd(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.RecordFeedback(args[0], args[1], !feedbackIrrelevant); err != nil {
+		return err
+	}
+	label := "relevant"
+	if feedbackIrrelevant {
+		label = "not relevant"
+	}
+	infof("✓ %s marked %s for %q", args[1], label, args[0])
+	return nil
+}
+
+func runFeedbackList(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	list, err := m.ListFeedback()
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindFeedback, list)
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No feedback recorded")
+		return nil
+	}
+	for _, f := range list {
+		mark := "+"
+		if !f.Relevant {
+			mark = "-"
+		}
+		fmt.Printf("%s %s/%s  %q  (%s)\n", mark, f.Collection, f.Path, f.Query, f.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	w := m.RerankBlend()
+	fmt.Printf("\nCurrent blend: top=%s mid=%s tail=%s\n", format.Decimal(w.Top, 2), format.Decimal(w.Mid, 2), format.Decimal(w.Tail, 2))
+	return nil
+}
+
+func runFeedbackTune(cmd *cobra.Command, args []string) error {
+	var judgments []mmq.RelevanceJudgment
+	if feedbackEvalFile != "" {
+		var err error
+		if judgments, err = readJudgments(feedbackEvalFile); err != nil {
+			return usageError(err)
+		}
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	tuning, err := m.TuneRerankBlend(mmq.BlendTuneOptions{
+		Judgments:  judgments,
+		Collection: collectionFlag,
+		DryRun:     feedbackDryRun,
+	})
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindRerankBlend, tuning)
+	}
+
+	fmt.Printf("Evaluated %d queries (%d judgments)\n", tuning.Queries, tuning.Judgments)
+	fmt.Printf("  current: top=%s mid=%s tail=%s  MAP %s\n",
+		format.Decimal(tuning.Previous.Top, 2), format.Decimal(tuning.Previous.Mid, 2), format.Decimal(tuning.Previous.Tail, 2), format.Decimal(tuning.PreviousScore, 4))
+	fmt.Printf("  learned: top=%s mid=%s tail=%s  MAP %s\n",
+		format.Decimal(tuning.Weights.Top, 2), format.Decimal(tuning.Weights.Mid, 2), format.Decimal(tuning.Weights.Tail, 2), format.Decimal(tuning.Score, 4))
+	switch {
+	case tuning.Saved && m.GetConfig().RerankBlend != nil:
+		fmt.Println("Saved, but retrieval.rerank_blend in the config file takes precedence")
+	case tuning.Saved:
+		fmt.Println("✓ Saved; 'mmq query' now uses the learned weights")
+	default:
+		fmt.Println("Dry run: nothing saved")
+	}
+	return nil
+}
+
+func runFeedbackReset(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.ResetRerankBlend(); err != nil {
+		return err
+	}
+	infof("✓ Tuned rerank blend weights removed")
+	if feedbackResetAll {
+		n, err := m.ClearFeedback()
+		if err != nil {
+			return err
+		}
+		infof("✓ Deleted %d feedback entries", n)
+	}
+	return nil
+}
+
+// readJudgments 读取 JSONL 格式的相关性标注，relevant 缺省为 true
+func readJudgments(path string) ([]mmq.RelevanceJudgment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var judgments []mmq.RelevanceJudgment
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var j struct {
+			Query    string `json:"query"`
+			Doc      string `json:"doc"`
+			Relevant *bool  `json:"relevant"`
+		}
+		if err := json.Unmarshal([]byte(text), &j); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if j.Query == "" || j.Doc == "" {
+			return nil, fmt.Errorf("%s:%d: query and doc are required", path, line)
+		}
+		judgments = append(judgments, mmq.RelevanceJudgment{Query: j.Query, Doc: j.Doc, Relevant: j.Relevant == nil || *j.Relevant})
+	}
+	return judgments, scanner.Err()
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initMask       string
+	initSkipModels bool
+	initNoEmbed    bool
+	initYes        bool
+)
+
+// init 命令 - 初始化工作区
+var initCmd = &cobra.Command{
+	Use:   "init [[name=]path...]",
+	Short: "Create the config, choose a database and index the first collections",
+	Long: `Set up a new mmq workspace in one step:
+
+1. Create the config file (--config, $MMQ_CONFIG or ~/.mmq/config.json).
+2. Choose the database location and save it as database.path.
+3. Optionally install the inference library and download models (mmq setup).
+4. Create a collection for each path (name defaults to the folder name).
+5. Index the collections and generate embeddings.
+
+When run in a terminal without --yes, each step asks for confirmation and
+folders can be entered interactively. Existing collections are reused.
+
+Examples:
+  mmq init
+  mmq init ~/notes docs=~/work/docs --yes
+  mmq init ~/notes --skip-models --no-embed`,
+	RunE: runInit,
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initMask, "mask", "m", "**/*.md", "File glob pattern for new collections")
+	initCmd.Flags().BoolVar(&initSkipModels, "skip-models", false, "Do not install the inference library or download models")
+	initCmd.Flags().BoolVar(&initNoEmbed, "no-embed", false, "Index only; run 'mmq embed' later")
+	initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Accept defaults without prompting")
+	rootCmd.AddCommand(initCmd)
+}
+
+// initPrompter 交互式提问；非交互时直接返回默认值
+type initPrompter struct {
+	scanner     *bufio.Scanner
+	interactive bool
+}
+
+// ask 提问并返回输入，空输入返回 def
+func (p *initPrompter) ask(question, def string) string {
+	if !p.interactive {
+		return def
+	}
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	if !p.scanner.Scan() {
+		fmt.Println()
+		return def
+	}
+	if answer := strings.TrimSpace(p.scanner.Text()); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// confirm 是/否提问
+func (p *initPrompter) confirm(question string, def bool) bool {
+	if !p.interactive {
+		return def
+	}
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		switch strings.ToLower(p.ask(fmt.Sprintf("%s (%s)", question, hint), "")) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		fmt.Println("  Answer y or n")
+	}
+}
+
+// initCollection 待创建的集合
+type initCollection struct {
+	name string
+	path string
+}
+
+// parseInitCollection 解析 [name=]path，name 默认取目录名
+func parseInitCollection(arg string) (initCollection, error) {
+	name, path := "", arg
+	if i := strings.Index(arg, "="); i > 0 {
+		name, path = arg[:i], arg[i+1:]
+	}
+	path, err := filepath.Abs(expandHome(path))
+	if err != nil {
+		return initCollection{}, fmt.Errorf("invalid path %s: %w", arg, err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return initCollection{}, fmt.Errorf("not a directory: %s", path)
+	}
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	return initCollection{name: name, path: path}, nil
+}
+
+// expandHome 展开 ~/ 前缀
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	var collections []initCollection
+	for _, arg := range args {
+		c, err := parseInitCollection(arg)
+		if err != nil {
+			return usageError(err)
+		}
+		collections = append(collections, c)
+	}
+
+	p := &initPrompter{
+		scanner:     bufio.NewScanner(os.Stdin),
+		interactive: !initYes && isTerminal(os.Stdin) && isTerminal(os.Stdout),
+	}
+
+	// Step 1: 配置文件和数据库位置
+	cfgFile := configPath
+	if cfgFile == "" {
+		cfgFile = mmq.DefaultConfigPath()
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Step 1: Config and database ===")
+	fmt.Printf("  Config file: %s\n", cfgFile)
+	location, err := filepath.Abs(expandHome(p.ask("  Database location", cfg.DBPath)))
+	if err != nil {
+		return usageError(fmt.Errorf("invalid database location: %w", err))
+	}
+	if err := mmq.SetFileValue(cfgFile, "database.path", location); err != nil {
+		return configError(err)
+	}
+	// 之后打开的就是选定的数据库（即使 --db 或 MMQ_DB 指向别处）
+	dbPath = location
+	if err := rootCmd.PersistentFlags().Set("db", location); err != nil {
+		return err
+	}
+	fmt.Printf("  Database: %s (saved as database.path)\n", location)
+	fmt.Println()
+
+	// Step 2: 推理库和模型（配置了远程嵌入接口时不需要）
+	embed := !initNoEmbed
+	switch {
+	case cfg.EmbeddingAPIURL != "":
+		fmt.Printf("Using the remote embedding API at %s; skipping model download.\n\n", cfg.EmbeddingAPIURL)
+	case !llm.LocalInference:
+		fmt.Println("This binary has no local model support; skipping model download and embedding.")
+		fmt.Println("Set MMQ_EMBED_BASE_URL and MMQ_EMBED_MODEL to embed with a remote API.")
+		fmt.Println()
+		embed = false
+	case initSkipModels || !p.confirm("Install the inference library and download models now?", true):
+		fmt.Println("Skipping model download; run 'mmq setup' later.")
+		fmt.Println()
+	default:
+		if err := runSetup(cmd, nil); err != nil {
+			return fmt.Errorf("setup failed: %w", err)
+		}
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	// Step 3: 集合
+	if len(collections) == 0 && p.interactive {
+		fmt.Println("Add folders to index (empty line to finish).")
+		for {
+			folder := p.ask("  Folder", "")
+			if folder == "" {
+				break
+			}
+			c, err := parseInitCollection(folder)
+			if err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+			c.name = p.ask("  Collection name", c.name)
+			collections = append(collections, c)
+		}
+		fmt.Println()
+	}
+	if len(collections) == 0 {
+		fmt.Println("No collections to create; add one later with 'mmq collection add <path> --name <name>'.")
+		return nil
+	}
+
+	fmt.Println("=== Step 2: Collections ===")
+	for _, c := range collections {
+		if existing, err := m.GetCollection(c.name); err == nil && existing != nil {
+			fmt.Printf("  %s: already exists at %s\n", c.name, existing.Path)
+			continue
+		}
+		if err := m.CreateCollection(c.name, c.path, mmq.CollectionOptions{Mask: initMask}); err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", c.name, err)
+		}
+		fmt.Printf("  %s: created at %s (mask: %s)\n", c.name, c.path, initMask)
+	}
+	fmt.Println()
+
+	// Step 4: 索引
+	fmt.Println("=== Step 3: Index ===")
+	for _, c := range collections {
+		coll, err := m.GetCollection(c.name)
+		if err != nil {
+			return fmt.Errorf("failed to get collection %s: %w", c.name, err)
+		}
+		fmt.Printf("Collection: %s\n", coll.Name)
+		summary, err := m.IndexDirectory(coll.Path, mmq.IndexOptions{
+			Collection: coll.Name,
+			Mask:       coll.Mask,
+			Recursive:  true,
+		})
+		printIndexSummary(summary)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", coll.Name, err)
+		}
+	}
+	fmt.Println()
+
+	// Step 5: 嵌入；失败不影响已完成的初始化
+	if embed {
+		fmt.Println("=== Step 4: Embeddings ===")
+		ui := newEmbedProgressUI(incidentalWriter())
+		ctx, stop := ui.interruptContext()
+		report, err := m.EmbedDocuments(mmq.EmbedOptions{Context: ctx, Progress: ui.update})
+		ui.finish()
+		stop()
+		switch {
+		case err != nil:
+			fmt.Printf("Warning: failed to generate embeddings: %v\n", err)
+			fmt.Println("Run 'mmq embed' once models are available.")
+		default:
+			printEmbedSummary(report)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("✓ Workspace ready. Try:")
+	fmt.Println("  mmq search \"keyword\"   # full-text search")
+	if embed {
+		fmt.Println("  mmq query \"question\"   # hybrid search with reranking")
+	} else {
+		fmt.Println("  mmq embed              # generate embeddings for vector search")
+	}
+	return nil
+}
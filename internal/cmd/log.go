@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/dyike/mmq/internal/format"
+)
+
+// 机器模式：进度、模型加载等附带提示不能混进管道输出
+// --quiet 丢弃这些提示；非 text 格式（json、csv 等）时写到标准错误；否则照常打印到标准输出
+
+// machineMode 输出是否供程序解析
+func machineMode() bool {
+	return format.Format(outputFormat) != format.FormatText || compactOut
+}
+
+// incidentalWriter 附带提示的输出位置
+func incidentalWriter() io.Writer {
+	switch {
+	case quiet:
+		return io.Discard
+	case machineMode():
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// incidentalLogger 传给 pkg/llm 和 pkg/mmq 的 logger；普通模式返回 nil，保持直接打印
+func incidentalLogger() *slog.Logger {
+	if !quiet && !machineMode() {
+		return nil
+	}
+	return slog.New(&messageHandler{w: incidentalWriter()})
+}
+
+// infof 输出附带提示
+func infof(format string, args ...interface{}) {
+	fmt.Fprintf(incidentalWriter(), format+"\n", args...)
+}
+
+// messageHandler 只输出消息文本的 slog 处理器，格式与直接打印相同
+type messageHandler struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (h *messageHandler) Enabled(context.Context, slog.Level) bool { return h.w != io.Discard }
+
+func (h *messageHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, r.Message)
+	return err
+}
+
+func (h *messageHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *messageHandler) WithGroup(string) slog.Handler      { return h }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dyike/mmq/internal/format"
 	"github.com/dyike/mmq/pkg/mmq"
@@ -12,7 +13,14 @@ import (
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show index status",
-	RunE:  runStatus,
+	Long: `Show index status.
+
+With --since, report what changed since a point in time or corpus version
+instead: documents added, updated and removed per collection, memories
+stored and embeddings generated. --since accepts a corpus version (the
+cursor printed by the previous run), YYYY-MM-DD, an RFC3339 time or a
+duration like 24h or 7d, so cron jobs can pass the last cursor each run.`,
+	RunE: runStatus,
 }
 
 // update 命令
@@ -27,16 +35,33 @@ var updateCmd = &cobra.Command{
 var embedCmd = &cobra.Command{
 	Use:   "embed",
 	Short: "Generate vector embeddings",
-	Long:  "Generate vector embeddings for all documents that need them",
-	RunE:  runEmbed,
+	Long: `Generate vector embeddings for all documents that need them.
+
+Shows a progress bar with rate and ETA, and the load status of each
+embedding model. Progress is saved after every chunk: Ctrl-C stops after
+the current chunk and prints a summary. Documents that fail are reported
+with the reason and skipped; use --resume to continue interrupted or
+failed documents from their last embedded chunk instead of starting them over.
+
+With --bench, each collection is benchmarked afterwards to record the
+strategy used by 'mmq query --auto' (see 'mmq collection bench').`,
+	RunE: runEmbed,
 }
 
 var (
-	gitPull bool
+	gitPull       bool
+	embedResume   bool
+	embedBench    bool
+	statusVerbose bool
+	statusSince   string
 )
 
 func init() {
+	statusCmd.Flags().BoolVarP(&statusVerbose, "verbose", "v", false, "Show vector index statistics")
+	statusCmd.Flags().StringVar(&statusSince, "since", "", "Report changes since a corpus version, date, RFC3339 time or duration (24h, 7d)")
 	updateCmd.Flags().BoolVar(&gitPull, "pull", false, "Git pull before indexing")
+	embedCmd.Flags().BoolVar(&embedResume, "resume", false, "Continue from the last embedded chunk of each document")
+	embedCmd.Flags().BoolVar(&embedBench, "bench", false, "Benchmark each collection afterwards and record its default strategy for --auto")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -46,14 +71,74 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	}
 	defer m.Close()
 
+	if statusSince != "" {
+		return runStatusSince(m)
+	}
+
 	status, err := m.Status()
 	if err != nil {
 		return fmt.Errorf("failed to get status: %w", err)
 	}
 
+	if status.Freshness, err = m.CollectionFreshness(); err != nil {
+		return fmt.Errorf("failed to check collection freshness: %w", err)
+	}
+	if statusVerbose {
+		if status.Vectors, err = m.VectorStats(); err != nil {
+			return fmt.Errorf("failed to get vector stats: %w", err)
+		}
+	}
+
 	return format.OutputStatus(status, format.Format(outputFormat))
 }
 
+// runStatusSince 输出 --since 之后的变更
+func runStatusSince(m *mmq.MMQ) error {
+	since, version, err := mmq.ParseSince(statusSince, time.Now())
+	if err != nil {
+		return err
+	}
+	var changes *mmq.StatusChanges
+	if since.IsZero() {
+		changes, err = m.ChangesSinceVersion(version)
+	} else {
+		changes, err = m.ChangesSince(since)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to collect changes: %w", err)
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindStatusChanges, changes)
+	}
+
+	if changes.SinceVersion > 0 {
+		fmt.Printf("Changes since corpus version %d (%s)\n", changes.SinceVersion, format.InZone(changes.Since).Format(time.RFC3339))
+	} else if !changes.Since.IsZero() {
+		fmt.Printf("Changes since %s\n", format.InZone(changes.Since).Format(time.RFC3339))
+	} else {
+		fmt.Println("All changes")
+	}
+	if changes.Empty() {
+		fmt.Println("\nNo changes")
+	} else {
+		d := changes.Documents
+		fmt.Printf("\nDocuments: %d added, %d updated, %d removed\n", d.Added, d.Updated, d.Removed)
+		for _, c := range changes.Collections {
+			fmt.Printf("  %-20s %d added, %d updated, %d removed\n", c.Name, c.Added, c.Updated, c.Removed)
+		}
+		if changes.Renamed > 0 {
+			fmt.Printf("Collections renamed: %d\n", changes.Renamed)
+		}
+		mem := changes.Memories
+		fmt.Printf("Memories: %d stored, %d updated, %d deleted\n", mem.Stored, mem.Updated, mem.Deleted)
+		fmt.Printf("Embeddings: %d documents (%d chunks)\n", changes.Embeddings.Documents, changes.Embeddings.Chunks)
+	}
+
+	fmt.Printf("\nCorpus version: %d\n", changes.CorpusVersion)
+	fmt.Printf("Cursor: %d (pass --since %d next time)\n", changes.Cursor, changes.Cursor)
+	return nil
+}
+
 func runUpdate(cmd *cobra.Command, args []string) error {
 	m, err := getMMQ()
 	if err != nil {
@@ -79,14 +164,27 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	for _, coll := range collections {
 		fmt.Printf("Collection: %s\n", coll.Name)
 
+		// 远端集合在原数据库中索引，搜索时直接查询
+		if coll.Remote != "" {
+			fmt.Printf("  Skipped: remote collection (searched in place)\n\n")
+			continue
+		}
+
+		// 自动创建的集合没有源目录，文档只能通过 API 写入
+		if coll.Path == "" {
+			fmt.Printf("  Skipped: no source path\n\n")
+			continue
+		}
+
 		// TODO: 如果 gitPull，在这里执行 git pull
 
 		// 索引文档
-		err = m.IndexDirectory(coll.Path, mmq.IndexOptions{
+		summary, err := m.IndexDirectory(coll.Path, mmq.IndexOptions{
 			Collection: coll.Name,
 			Mask:       coll.Mask,
 			Recursive:  true,
 		})
+		printIndexSummary(summary)
 
 		if err != nil {
 			fmt.Printf("  Error: %v\n\n", err)
@@ -114,6 +212,25 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printIndexSummary 打印目录索引结果
+func printIndexSummary(s *mmq.IndexSummary) {
+	if s == nil {
+		return
+	}
+
+	fmt.Printf("  Added: %d, updated: %d, unchanged: %d, removed: %d, skipped: %d, errors: %d\n",
+		s.Added, s.Updated, s.Unchanged, s.Removed, s.Skipped, len(s.Errors))
+	for _, e := range s.Errors {
+		fmt.Printf("  Warning: failed to index %s: %s\n", e.Path, e.Error)
+	}
+	for _, f := range s.PIIFiles {
+		fmt.Printf("  PII in %s: %s (%s)\n", f.Path, f.Summary, s.PIIPolicy)
+	}
+	if s.IndexMode == mmq.IndexModeSummary && s.Added+s.Updated > 0 {
+		fmt.Printf("  Summarized %d document(s); full text was not stored\n", s.Added+s.Updated)
+	}
+}
+
 func runEmbed(cmd *cobra.Command, args []string) error {
 	m, err := getMMQ()
 	if err != nil {
@@ -129,18 +246,42 @@ func runEmbed(cmd *cobra.Command, args []string) error {
 
 	if status.NeedsEmbedding == 0 {
 		fmt.Println("All documents already have embeddings")
+		if embedBench {
+			return benchAllCollections(m)
+		}
 		return nil
 	}
 
 	fmt.Printf("Generating embeddings for %d documents...\n", status.NeedsEmbedding)
-	fmt.Println("This may take a while...")
 	fmt.Println()
 
-	err = m.GenerateEmbeddings()
+	// Ctrl-C 在当前块完成后停止，已生成的块和进度都已保存；再按一次立即退出
+	ui := newEmbedProgressUI(incidentalWriter())
+	ctx, stop := ui.interruptContext()
+	defer stop()
+
+	report, err := m.EmbedDocuments(mmq.EmbedOptions{
+		Resume:   embedResume,
+		Context:  ctx,
+		Progress: ui.update,
+	})
+	ui.finish()
 	if err != nil {
 		return fmt.Errorf("failed to generate embeddings: %w", err)
 	}
 
-	fmt.Println("\n✓ Embeddings generated successfully")
+	fmt.Println()
+	printEmbedSummary(report)
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d documents failed to embed", len(report.Failed))
+	}
+	if report.Interrupted {
+		return fmt.Errorf("embedding interrupted, %d documents pending", report.Pending)
+	}
+
+	fmt.Println("✓ Embeddings generated successfully")
+	if embedBench {
+		return benchAllCollections(m)
+	}
 	return nil
 }
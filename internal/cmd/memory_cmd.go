@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/i18n"
 	"github.com/dyike/mmq/pkg/mmq"
 	"github.com/spf13/cobra"
 )
@@ -22,66 +26,91 @@ var memoryCmd = &cobra.Command{
 
 // --- memory list ---
 
-var memoryListType string
+var (
+	memoryListType   string
+	memoryListSort   string
+	memoryListLimit  int
+	memoryListOffset int
+)
 
 var memoryListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List stored memories",
-	RunE:  runMemoryList,
+	Long: `List stored memories as a compact table with per-type totals.
+
+Examples:
+  mmq memory list --sort importance
+  mmq memory list --type fact --limit 20 --offset 20
+  mmq memory list --sort access -o json`,
+	RunE: runMemoryList,
 }
 
 func runMemoryList(cmd *cobra.Command, args []string) error {
+	switch mmq.MemorySort(memoryListSort) {
+	case mmq.MemorySortRecency, mmq.MemorySortImportance, mmq.MemorySortAccess:
+	default:
+		return fmt.Errorf("invalid sort: %s (use recency, importance or access)", memoryListSort)
+	}
+	if memoryListLimit < 0 || memoryListOffset < 0 {
+		return fmt.Errorf("--limit and --offset must not be negative")
+	}
+
 	m, err := getMMQ()
 	if err != nil {
 		return err
 	}
 	defer m.Close()
 
-	if memoryListType != "" {
-		// 按类型列出
-		memories, err := m.ListMemoriesByType(mmq.MemoryType(memoryListType))
-		if err != nil {
-			return fmt.Errorf("failed to list memories: %w", err)
-		}
-
-		if len(memories) == 0 {
-			fmt.Printf("No memories of type '%s' found\n", memoryListType)
-			return nil
-		}
-
-		outputMemoryList(memories)
-		return nil
+	memories, err := m.ListMemories(mmq.MemoryListOptions{
+		Type:   mmq.MemoryType(memoryListType),
+		SortBy: mmq.MemorySort(memoryListSort),
+		Limit:  memoryListLimit,
+		Offset: memoryListOffset,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
 	}
 
-	// 列出所有类型
-	types := []string{"conversation", "fact", "preference", "episodic"}
-	totalCount := 0
+	counts, err := m.CountMemoriesGroupedByType()
+	if err != nil {
+		return fmt.Errorf("failed to count memories: %w", err)
+	}
 
-	for _, t := range types {
-		memories, err := m.ListMemoriesByType(mmq.MemoryType(t))
-		if err != nil {
+	list := format.MemoryList{
+		Memories: memories,
+		Offset:   memoryListOffset,
+	}
+	for _, t := range []mmq.MemoryType{
+		mmq.MemoryTypeConversation, mmq.MemoryTypeFact,
+		mmq.MemoryTypePreference, mmq.MemoryTypeEpisodic,
+	} {
+		if counts[t] == 0 {
 			continue
 		}
-		if len(memories) > 0 {
-			fmt.Printf("── %s (%d) ──\n", t, len(memories))
-			outputMemoryList(memories)
-			fmt.Println()
-			totalCount += len(memories)
+		list.Counts = append(list.Counts, format.MemoryTypeCount{Type: t, Count: counts[t]})
+		if memoryListType == "" || mmq.MemoryType(memoryListType) == t {
+			list.Total += counts[t]
 		}
 	}
 
-	if totalCount == 0 {
-		fmt.Println("No memories stored yet. Use 'mmq memory add' to create one.")
-	} else {
-		fmt.Printf("Total: %d memories\n", totalCount)
+	if list.Total == 0 && format.Format(outputFormat) == format.FormatText {
+		if memoryListType != "" {
+			fmt.Printf("No memories of type '%s' found\n", memoryListType)
+		} else {
+			fmt.Println("No memories stored yet. Use 'mmq memory add' to create one.")
+		}
+		return nil
 	}
 
-	return nil
+	return format.OutputMemoryList(list, format.Format(outputFormat))
 }
 
 // --- memory recall ---
 
-var memoryRecallLimit int
+var (
+	memoryRecallLimit   int
+	memoryRecallSession string
+)
 
 var memoryRecallCmd = &cobra.Command{
 	Use:   "recall [query]",
@@ -103,6 +132,7 @@ func runMemoryRecall(cmd *cobra.Command, args []string) error {
 		ApplyDecay:         true,
 		DecayHalflife:      30 * 24 * time.Hour,
 		WeightByImportance: true,
+		SessionID:          memoryRecallSession,
 	})
 	if err != nil {
 		return fmt.Errorf("recall failed: %w", err)
@@ -120,7 +150,7 @@ func runMemoryRecall(cmd *cobra.Command, args []string) error {
 		if len(mem.Tags) > 0 {
 			fmt.Printf("    Tags: %s\n", strings.Join(mem.Tags, ", "))
 		}
-		fmt.Printf("    Time: %s\n\n", mem.Timestamp.Format("2006-01-02 15:04"))
+		fmt.Printf("    Time: %s\n\n", format.DateTime(mem.Timestamp, "2006-01-02 15:04"))
 	}
 
 	return nil
@@ -132,14 +162,21 @@ var (
 	memoryAddType       string
 	memoryAddImportance float64
 	memoryAddTags       string
+	memoryAddTTL        string
 )
 
 var memoryAddCmd = &cobra.Command{
 	Use:   "add [content]",
 	Short: "Add a memory manually",
-	Long:  "Add a memory. Types: conversation, fact, preference, episodic",
-	Args:  cobra.MinimumNArgs(1),
-	RunE:  runMemoryAdd,
+	Long: `Add a memory. Types: conversation, fact, preference, episodic
+
+Examples:
+  mmq memory add "User prefers Go" --type preference
+  mmq memory add "Working on the retry bug" --ttl session  # expires in 1 day
+  mmq memory add "Sprint goal: ship sync" --ttl sprint     # expires in 2 weeks
+  mmq memory add "Deploy freeze until Friday" --ttl 3d`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMemoryAdd,
 }
 
 func runMemoryAdd(cmd *cobra.Command, args []string) error {
@@ -151,27 +188,296 @@ func runMemoryAdd(cmd *cobra.Command, args []string) error {
 
 	content := strings.Join(args, " ")
 
-	var tags []string
-	if memoryAddTags != "" {
-		tags = strings.Split(memoryAddTags, ",")
-		for i := range tags {
-			tags[i] = strings.TrimSpace(tags[i])
-		}
+	ttl, err := mmq.ParseTTL(memoryAddTTL)
+	if err != nil {
+		return err
 	}
 
 	mem := mmq.Memory{
 		Type:       mmq.MemoryType(memoryAddType),
 		Content:    content,
-		Tags:       tags,
+		Tags:       parseTags(memoryAddTags),
 		Timestamp:  time.Now(),
 		Importance: memoryAddImportance,
+		TTL:        ttl,
 	}
 
 	if err := m.StoreMemory(mem); err != nil {
 		return fmt.Errorf("failed to store memory: %w", err)
 	}
 
-	fmt.Printf("✓ Memory stored (type=%s, importance=%.1f)\n", memoryAddType, memoryAddImportance)
+	if ttl > 0 {
+		fmt.Println(i18n.T("memory.stored_expires", memoryAddType, memoryAddImportance, formatHalflife(ttl)))
+	} else {
+		fmt.Println(i18n.T("memory.stored", memoryAddType, memoryAddImportance))
+	}
+	return nil
+}
+
+// --- memory update ---
+
+var (
+	memoryUpdateContent    string
+	memoryUpdateImportance float64
+	memoryUpdateTags       string
+	memoryUpdateExpires    string
+)
+
+var memoryUpdateCmd = &cobra.Command{
+	Use:   "update [id]",
+	Short: "Update fields of a memory",
+	Long: `Update a memory by ID (or ID prefix). Only the flags given are changed.
+
+Examples:
+  mmq memory update 1a2b3c4d --importance 0.9
+  mmq memory update 1a2b3c4d --tags go,sqlite
+  mmq memory update 1a2b3c4d --expires 7d          # expire 7 days from now
+  mmq memory update 1a2b3c4d --expires 2026-12-31  # expire on a date
+  mmq memory update 1a2b3c4d --expires never       # clear expiration`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMemoryUpdate,
+}
+
+func runMemoryUpdate(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if !flags.Changed("content") && !flags.Changed("importance") &&
+		!flags.Changed("tags") && !flags.Changed("expires") {
+		return fmt.Errorf("nothing to update: specify --content, --importance, --tags or --expires")
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, err := m.ResolveMemoryID(args[0])
+	if err != nil {
+		return err
+	}
+
+	mem, err := m.GetMemoryByID(id)
+	if err != nil {
+		return fmt.Errorf("memory not found: %w", err)
+	}
+
+	if flags.Changed("content") {
+		if strings.TrimSpace(memoryUpdateContent) == "" {
+			return fmt.Errorf("content cannot be empty")
+		}
+		mem.Content = memoryUpdateContent
+	}
+
+	if flags.Changed("importance") {
+		if memoryUpdateImportance < 0 || memoryUpdateImportance > 1 {
+			return fmt.Errorf("importance must be between 0.0 and 1.0")
+		}
+		mem.Importance = memoryUpdateImportance
+	}
+
+	if flags.Changed("tags") {
+		mem.Tags = parseTags(memoryUpdateTags)
+	}
+
+	if flags.Changed("expires") {
+		expiresAt, err := parseExpires(memoryUpdateExpires)
+		if err != nil {
+			return err
+		}
+		mem.ExpiresAt = expiresAt
+	}
+
+	if err := m.UpdateMemory(id, *mem); err != nil {
+		return fmt.Errorf("failed to update memory: %w", err)
+	}
+
+	fmt.Println(i18n.T("memory.updated", id[:8]))
+	return nil
+}
+
+// --- memory review ---
+
+var memoryReviewLimit int
+
+var memoryReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Interactively review auto-extracted memories",
+	Long: `Walk through memories extracted automatically from chat and keep, edit or
+delete each one. Reviewed memories are not shown again, and the keep/delete
+ratio adjusts the importance given to future auto-extracted memories.`,
+	RunE: runMemoryReview,
+}
+
+func runMemoryReview(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	memories, err := m.PendingMemoryReviews(memoryReviewLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load memories: %w", err)
+	}
+
+	if len(memories) == 0 {
+		fmt.Println("No auto-extracted memories waiting for review")
+		return nil
+	}
+
+	fmt.Printf("Reviewing %d auto-extracted memories\n", len(memories))
+	fmt.Println("[k]eep (Enter)  [e]dit  [d]elete  [s]kip  [q]uit")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	tally := make(map[mmq.MemoryReviewAction]int)
+
+review:
+	for i, mem := range memories {
+		fmt.Printf("\n(%d/%d) [%s] %s · %s\n", i+1, len(memories), mem.ID[:8], mem.Type, formatAge(time.Since(mem.Timestamp)))
+		fmt.Printf("  %s\n", mem.Content)
+
+		for {
+			fmt.Print("> ")
+			if !scanner.Scan() {
+				break review
+			}
+
+			var action mmq.MemoryReviewAction
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "k", "keep", "":
+				action = mmq.MemoryReviewKeep
+			case "e", "edit":
+				fmt.Print("New content (empty to cancel): ")
+				if !scanner.Scan() {
+					break review
+				}
+				content := strings.TrimSpace(scanner.Text())
+				if content == "" {
+					continue
+				}
+				mem.Content = content
+				action = mmq.MemoryReviewEdit
+			case "d", "delete":
+				action = mmq.MemoryReviewDelete
+			case "s", "skip":
+				continue review
+			case "q", "quit":
+				break review
+			default:
+				fmt.Println("  Choose k, e, d, s or q")
+				continue
+			}
+
+			if err := m.ReviewMemory(mem, action); err != nil {
+				fmt.Printf("  ✗ %v\n", err)
+			} else {
+				tally[action]++
+			}
+			continue review
+		}
+	}
+
+	fmt.Println("\n" + i18n.T("memory.review_done",
+		tally[mmq.MemoryReviewKeep], tally[mmq.MemoryReviewEdit], tally[mmq.MemoryReviewDelete]))
+	fmt.Printf("  Auto-extracted importance: fact=%.2f, preference=%.2f\n",
+		m.AutoMemoryImportance(mmq.MemoryTypeFact), m.AutoMemoryImportance(mmq.MemoryTypePreference))
+	return nil
+}
+
+// --- memory dedupe ---
+
+var (
+	memoryDedupeThreshold float64
+	memoryDedupeType      string
+	memoryDedupeYes       bool
+)
+
+var memoryDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find and merge near-duplicate memories",
+	Long: `Compare every memory's embedding with the others of the same type and
+namespace and propose merges for near-duplicates. A merge keeps the memory
+with the highest importance, takes the union of tags, adds up access counts
+and deletes the rest.
+
+Each proposal is confirmed interactively unless --yes is given. With
+--format json the proposals are printed without merging (add --yes to merge).`,
+	Args: cobra.NoArgs,
+	RunE: runMemoryDedupe,
+}
+
+func runMemoryDedupe(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	groups, err := m.FindDuplicateMemories(mmq.MemoryDedupeOptions{
+		Threshold: memoryDedupeThreshold,
+		Type:      memoryTypeFromString(memoryDedupeType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %w", err)
+	}
+
+	if outputFormat == "json" && !memoryDedupeYes {
+		return format.OutputJSON(format.KindDuplicates, groups)
+	}
+	if len(groups) == 0 {
+		fmt.Println("No near-duplicate memories found")
+		return nil
+	}
+
+	fmt.Printf("Found %d group(s) of near-duplicate memories\n", len(groups))
+	if !memoryDedupeYes {
+		fmt.Println("[m]erge (Enter)  [s]kip  [a]ll remaining  [q]uit")
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	mergeAll := memoryDedupeYes
+	merged, removed := 0, 0
+
+dedupe:
+	for i, g := range groups {
+		fmt.Printf("\n(%d/%d) keep [%s] %s · importance %.2f\n", i+1, len(groups), g.Keep.ID[:8], g.Keep.Type, g.Importance)
+		fmt.Printf("  %s\n", g.Keep.Content)
+		for j, mem := range g.Merge {
+			fmt.Printf("  - [%s] %.3f  %s\n", mem.ID[:8], g.Similarity[j], truncate(mem.Content, 80))
+		}
+		if len(g.Tags) > 0 {
+			fmt.Printf("  tags: %s\n", strings.Join(g.Tags, ", "))
+		}
+
+		for !mergeAll {
+			fmt.Print("> ")
+			if !scanner.Scan() {
+				break dedupe
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "m", "merge", "y", "yes", "":
+			case "a", "all":
+				mergeAll = true
+			case "s", "skip", "n", "no":
+				continue dedupe
+			case "q", "quit":
+				break dedupe
+			default:
+				fmt.Println("  Choose m, s, a or q")
+				continue
+			}
+			break
+		}
+
+		if err := m.MergeDuplicateMemories(g); err != nil {
+			fmt.Printf("  ✗ %v\n", err)
+			continue
+		}
+		merged++
+		removed += len(g.Merge)
+	}
+
+	fmt.Println("\n" + i18n.T("memory.dedupe_done", merged, removed))
 	return nil
 }
 
@@ -195,7 +501,7 @@ func runMemoryDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to delete memory: %w", err)
 	}
 
-	fmt.Printf("✓ Memory %s deleted\n", args[0])
+	fmt.Println(i18n.T("memory.deleted", args[0]))
 	return nil
 }
 
@@ -219,7 +525,7 @@ func runMemoryStats(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to count memories: %w", err)
 	}
 
-	fmt.Printf("📊 Memory Statistics\n\n")
+	fmt.Printf("%s\n\n", i18n.T("memory.stats_header"))
 	fmt.Printf("  Total: %d\n", total)
 
 	types := []string{"conversation", "fact", "preference", "episodic"}
@@ -233,6 +539,105 @@ func runMemoryStats(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if missing, err := m.CountMemoriesMissingEmbedding(); err == nil && missing > 0 {
+		fmt.Println("\n" + i18n.T("memory.missing_embed", missing))
+	}
+
+	return nil
+}
+
+// --- memory decay ---
+
+var memoryDecayCmd = &cobra.Command{
+	Use:   "decay",
+	Short: "Explain per-type memory decay curves",
+	Long: `Show the decay half-life configured for each memory type and how much
+relevance a memory retains at various ages. Configure via the config file:
+
+  {"memory": {"decay_halflife": {"conversation": "7d", "fact": "0"}}}`,
+	RunE: runMemoryDecay,
+}
+
+func runMemoryDecay(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	halflives := m.MemoryDecayHalflives()
+	ages := []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour, 90 * 24 * time.Hour, 365 * 24 * time.Hour}
+
+	if outputFormat == "json" {
+		type curve struct {
+			Type      string             `json:"type"`
+			Halflife  string             `json:"halflife"`
+			Retention map[string]float64 `json:"retention"`
+		}
+		var curves []curve
+		for _, t := range []string{"conversation", "fact", "preference", "episodic"} {
+			c := curve{Type: t, Halflife: formatHalflife(halflives[mmq.MemoryType(t)]), Retention: map[string]float64{}}
+			for _, age := range ages {
+				c.Retention[formatHalflife(age)] = m.MemoryDecayFactor(mmq.MemoryType(t), age)
+			}
+			curves = append(curves, c)
+		}
+		return format.OutputJSON(format.KindDecayCurves, curves)
+	}
+
+	fmt.Printf("%-14s %-10s", "TYPE", "HALF-LIFE")
+	for _, age := range ages {
+		fmt.Printf(" %8s", formatHalflife(age))
+	}
+	fmt.Println()
+
+	for _, t := range []string{"conversation", "fact", "preference", "episodic"} {
+		fmt.Printf("%-14s %-10s", t, formatHalflife(halflives[mmq.MemoryType(t)]))
+		for _, age := range ages {
+			fmt.Printf(" %7.0f%%", m.MemoryDecayFactor(mmq.MemoryType(t), age)*100)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// --- memory reembed ---
+
+var memoryReembedAll bool
+
+var memoryReembedCmd = &cobra.Command{
+	Use:   "reembed",
+	Short: "Backfill missing memory embeddings",
+	Long:  "Generate embeddings for memories stored without a usable vector (e.g. while no embedding model was available)",
+	RunE:  runMemoryReembed,
+}
+
+func runMemoryReembed(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if !memoryReembedAll {
+		missing, err := m.CountMemoriesMissingEmbedding()
+		if err != nil {
+			return fmt.Errorf("failed to check embeddings: %w", err)
+		}
+		if missing == 0 {
+			fmt.Println("All memories already have embeddings")
+			return nil
+		}
+		fmt.Printf("Backfilling embeddings for %d memories...\n", missing)
+	}
+
+	count, err := m.ReembedMemories(memoryReembedAll)
+	if err != nil {
+		return fmt.Errorf("reembed failed after %d memories: %w", count, err)
+	}
+
+	fmt.Println(i18n.T("memory.reembedded", count))
 	return nil
 }
 
@@ -259,7 +664,7 @@ func runMemoryCleanup(cmd *cobra.Command, args []string) error {
 	if count == 0 {
 		fmt.Println("No expired memories to clean up")
 	} else {
-		fmt.Printf("✓ Cleaned up %d expired memories\n", count)
+		fmt.Println(i18n.T("memory.cleaned", count))
 	}
 
 	return nil
@@ -287,21 +692,19 @@ func runMemoryGet(cmd *cobra.Command, args []string) error {
 	}
 
 	if outputFormat == "json" {
-		data, _ := json.MarshalIndent(mem, "", "  ")
-		fmt.Println(string(data))
-		return nil
+		return format.OutputJSON(format.KindMemory, mem)
 	}
 
 	fmt.Printf("ID:         %s\n", mem.ID)
 	fmt.Printf("Type:       %s\n", mem.Type)
 	fmt.Printf("Content:    %s\n", mem.Content)
 	fmt.Printf("Importance: %.2f\n", mem.Importance)
-	fmt.Printf("Timestamp:  %s\n", mem.Timestamp.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Timestamp:  %s\n", format.DateTime(mem.Timestamp, "2006-01-02 15:04:05"))
 	if len(mem.Tags) > 0 {
 		fmt.Printf("Tags:       %s\n", strings.Join(mem.Tags, ", "))
 	}
 	if mem.ExpiresAt != nil {
-		fmt.Printf("Expires:    %s\n", mem.ExpiresAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Expires:    %s\n", format.DateTime(*mem.ExpiresAt, "2006-01-02 15:04:05"))
 	}
 	if len(mem.Metadata) > 0 {
 		metaJSON, _ := json.MarshalIndent(mem.Metadata, "            ", "  ")
@@ -311,34 +714,119 @@ func runMemoryGet(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// --- memory sources ---
+
+var memorySourcesCmd = &cobra.Command{
+	Use:   "sources [id]",
+	Short: "Show the documents a memory was extracted from",
+	Long: `Show the documents that were retrieved in the conversation a memory was
+extracted from, so facts remain traceable to their evidence. Documents whose
+content changed since then are reported as missing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMemorySources,
+}
+
+func runMemorySources(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, err := m.ResolveMemoryID(args[0])
+	if err != nil {
+		return err
+	}
+	sources, err := m.MemorySources(id)
+	if err != nil {
+		return fmt.Errorf("failed to get memory sources: %w", err)
+	}
+
+	if outputFormat == "json" {
+		return format.OutputJSON(format.KindMemorySources, sources)
+	}
+
+	if len(sources) == 0 {
+		fmt.Println("No source documents recorded for this memory")
+		return nil
+	}
+
+	for _, s := range sources {
+		if s.Missing {
+			fmt.Printf("#%s  (missing: deleted or changed since extraction)\n", s.DocID)
+			continue
+		}
+		for _, doc := range s.Documents {
+			fmt.Printf("#%s  %s/%s", s.DocID, doc.Collection, doc.Path)
+			if doc.Title != "" {
+				fmt.Printf("  %s", doc.Title)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
 // --- init ---
 
 func init() {
 	// memory list
 	memoryListCmd.Flags().StringVar(&memoryListType, "type", "", "Filter by type (conversation|fact|preference|episodic)")
+	memoryListCmd.Flags().StringVar(&memoryListSort, "sort", "recency", "Sort by recency, importance or access")
+	memoryListCmd.Flags().IntVar(&memoryListLimit, "limit", 50, "Max memories to show (0 for all)")
+	memoryListCmd.Flags().IntVar(&memoryListOffset, "offset", 0, "Skip the first N memories")
 	memoryCmd.AddCommand(memoryListCmd)
 
 	// memory recall
 	memoryRecallCmd.Flags().IntVar(&memoryRecallLimit, "limit", 10, "Max results")
+	memoryRecallCmd.Flags().StringVar(&memoryRecallSession, "session", "", "Boost memories from this session")
 	memoryCmd.AddCommand(memoryRecallCmd)
 
 	// memory add
 	memoryAddCmd.Flags().StringVar(&memoryAddType, "type", "fact", "Memory type (conversation|fact|preference|episodic)")
 	memoryAddCmd.Flags().Float64Var(&memoryAddImportance, "importance", 0.5, "Importance weight 0.0-1.0")
 	memoryAddCmd.Flags().StringVar(&memoryAddTags, "tags", "", "Comma-separated tags")
+	memoryAddCmd.Flags().StringVar(&memoryAddTTL, "ttl", "", "Time to live: duration (12h, 7d, 2w) or policy (session, sprint)")
 	memoryCmd.AddCommand(memoryAddCmd)
 
+	// memory update
+	memoryUpdateCmd.Flags().StringVar(&memoryUpdateContent, "content", "", "New content (re-embeds the memory)")
+	memoryUpdateCmd.Flags().Float64Var(&memoryUpdateImportance, "importance", 0.5, "Importance weight 0.0-1.0")
+	memoryUpdateCmd.Flags().StringVar(&memoryUpdateTags, "tags", "", "Comma-separated tags (empty to clear)")
+	memoryUpdateCmd.Flags().StringVar(&memoryUpdateExpires, "expires", "", "Expiration: duration (7d, 12h), date (2006-01-02), RFC3339, or 'never'")
+	memoryCmd.AddCommand(memoryUpdateCmd)
+
+	// memory review
+	memoryReviewCmd.Flags().IntVar(&memoryReviewLimit, "limit", 20, "Max memories to review (0 for all)")
+	memoryCmd.AddCommand(memoryReviewCmd)
+
+	// memory dedupe
+	memoryDedupeCmd.Flags().Float64Var(&memoryDedupeThreshold, "threshold", mmq.DefaultDuplicateThreshold, "Cosine similarity at or above which memories count as duplicates")
+	memoryDedupeCmd.Flags().StringVar(&memoryDedupeType, "type", "", "Only check this type (conversation|fact|preference|episodic)")
+	memoryDedupeCmd.Flags().BoolVarP(&memoryDedupeYes, "yes", "y", false, "Merge every proposal without asking")
+	memoryCmd.AddCommand(memoryDedupeCmd)
+
 	// memory delete
 	memoryCmd.AddCommand(memoryDeleteCmd)
 
 	// memory get
 	memoryCmd.AddCommand(memoryGetCmd)
 
+	// memory sources
+	memoryCmd.AddCommand(memorySourcesCmd)
+
 	// memory stats
 	memoryCmd.AddCommand(memoryStatsCmd)
 
 	// memory cleanup
 	memoryCmd.AddCommand(memoryCleanupCmd)
+
+	// memory decay
+	memoryCmd.AddCommand(memoryDecayCmd)
+
+	// memory reembed
+	memoryReembedCmd.Flags().BoolVar(&memoryReembedAll, "all", false, "Re-embed all memories, not only those missing vectors")
+	memoryCmd.AddCommand(memoryReembedCmd)
 }
 
 // --- helpers ---
@@ -358,14 +846,37 @@ func memoryTypeFromString(s string) mmq.MemoryType {
 	}
 }
 
-func outputMemoryList(memories []mmq.Memory) {
-	for _, mem := range memories {
-		snippet := truncate(mem.Content, 80)
-		age := time.Since(mem.Timestamp)
-		ageStr := formatAge(age)
+// parseTags 解析逗号分隔的标签
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseExpires 解析过期时间：相对时长、日期、RFC3339 或 never
+func parseExpires(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "never" || s == "none" {
+		return nil, nil
+	}
 
-		fmt.Printf("  [%s] %.1f★ %s (%s)\n", mem.ID[:8], mem.Importance, snippet, ageStr)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
 	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return &t, nil
+	}
+	if d, err := mmq.ParseTTL(s); err == nil && d > 0 {
+		t := time.Now().Add(d)
+		return &t, nil
+	}
+
+	return nil, fmt.Errorf("invalid expiration: %s (use a duration like 7d, a date like 2006-01-02, or 'never')", s)
 }
 
 func truncate(s string, maxLen int) string {
@@ -377,6 +888,16 @@ func truncate(s string, maxLen int) string {
 	return strings.ReplaceAll(string(runes[:maxLen]), "\n", " ") + "..."
 }
 
+func formatHalflife(d time.Duration) string {
+	if d <= 0 {
+		return "never"
+	}
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return d.String()
+}
+
 func formatAge(d time.Duration) string {
 	switch {
 	case d < time.Hour:
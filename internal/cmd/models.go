@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Manage local model files",
+}
+
+// models verify 命令 - 按固定的校验和重新校验本地模型
+var modelsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify local models against pinned SHA256 checksums",
+	Long: `Re-hash every model file in the model cache and compare it with the
+checksums pinned in the config file:
+
+  "models": {
+    "pins": {"embeddinggemma-300M-Q8_0.gguf": "<sha256>"}
+  }
+
+Pinned models whose checksum differs are never downloaded or loaded. Print
+the current checksums with 'mmq models verify' and copy them into the config
+to pin the models you have.`,
+	Args: cobra.NoArgs,
+	RunE: runModelsVerify,
+}
+
+func init() {
+	modelsCmd.AddCommand(modelsVerifyCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
+
+func runModelsVerify(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	checks, err := mmq.VerifyModels(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to verify models: %w", err)
+	}
+
+	var failed int
+	for _, c := range checks {
+		if c.Status == mmq.ModelMismatch || c.Error != "" {
+			failed++
+		}
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		if err := format.OutputJSON(format.KindModels, checks); err != nil {
+			return err
+		}
+	} else {
+		if len(checks) == 0 {
+			fmt.Printf("No models found in %s\n", cfg.CacheDir)
+		}
+		for _, c := range checks {
+			fmt.Printf("%-9s %s\n", c.Status, c.File)
+			switch {
+			case c.Error != "":
+				fmt.Printf("          error:    %s\n", c.Error)
+			case c.Status == mmq.ModelMismatch:
+				fmt.Printf("          expected: %s\n          actual:   %s\n", c.Expected, c.SHA256)
+			case c.SHA256 != "":
+				fmt.Printf("          sha256:   %s\n", c.SHA256)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d model(s) failed verification", failed)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanPIIDocsOnly     bool
+	scanPIIMemoriesOnly bool
+	scanPIIShow         bool
+)
+
+// scan-pii 命令 - 审计已索引内容中的 PII
+var scanPIICmd = &cobra.Command{
+	Use:   "scan-pii",
+	Short: "Audit indexed documents and memories for PII",
+	Long: `Scan indexed documents and stored memories for personal data (emails, phone
+numbers, ID/SSN/credit card numbers, and custom patterns from the config file).
+Nothing is modified; use a collection PII policy to redact on the next index.
+
+Examples:
+  mmq scan-pii
+  mmq scan-pii -c notes --documents
+  mmq scan-pii --memories --show`,
+	RunE: runScanPII,
+}
+
+// collection pii 子命令 - 查看或设置集合的 PII 策略
+var collectionPIICmd = &cobra.Command{
+	Use:   "pii <name> [off|flag|redact|default]",
+	Short: "Show or set a collection's PII policy",
+	Long: `Show or set the PII policy applied when a collection is indexed:
+  off     - no scanning
+  flag    - index as-is and report files containing PII
+  redact  - replace PII with [REDACTED:kind] before indexing
+  default - use the policy from the config file`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runCollectionPII,
+}
+
+func init() {
+	scanPIICmd.Flags().BoolVar(&scanPIIDocsOnly, "documents", false, "Only scan documents")
+	scanPIICmd.Flags().BoolVar(&scanPIIMemoriesOnly, "memories", false, "Only scan memories")
+	scanPIICmd.Flags().BoolVar(&scanPIIShow, "show", false, "Show matched values unmasked")
+	rootCmd.AddCommand(scanPIICmd)
+
+	collectionCmd.AddCommand(collectionPIICmd)
+}
+
+func runScanPII(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	opts := mmq.PIIScanOptions{
+		Collection: collectionFlag,
+		Documents:  !scanPIIMemoriesOnly,
+		Memories:   !scanPIIDocsOnly && collectionFlag == "",
+	}
+
+	findings, err := m.ScanPII(opts)
+	if err != nil {
+		return fmt.Errorf("PII scan failed: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		if !scanPIIShow {
+			for i := range findings {
+				for j := range findings[i].Matches {
+					findings[i].Matches[j].Value = maskPII(findings[i].Matches[j].Value)
+				}
+			}
+		}
+		return format.OutputJSON(format.KindPIIFindings, findings)
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("✓ No PII found")
+		return nil
+	}
+
+	var all []pii.Match
+	for _, f := range findings {
+		all = append(all, f.Matches...)
+
+		if f.Source == "document" {
+			fmt.Printf("%s %s/%s: %s\n", f.ID, f.Collection, f.Path, pii.Summarize(f.Matches))
+		} else {
+			fmt.Printf("memory [%s]: %s\n", f.ID[:8], pii.Summarize(f.Matches))
+		}
+
+		for _, match := range f.Matches {
+			value := match.Value
+			if !scanPIIShow {
+				value = maskPII(value)
+			}
+			fmt.Printf("  %-12s %s\n", match.Kind, value)
+		}
+	}
+
+	fmt.Printf("\n%d items contain PII (%s)\n", len(findings), pii.Summarize(all))
+	return nil
+}
+
+func runCollectionPII(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	name := args[0]
+
+	if len(args) == 2 {
+		policy := args[1]
+		if policy == "default" {
+			policy = ""
+		}
+		if err := m.SetCollectionPIIPolicy(name, policy); err != nil {
+			return err
+		}
+	}
+
+	policy, err := m.CollectionPIIPolicy(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Collection '%s' PII policy: %s\n", name, policy)
+	if len(args) == 2 {
+		fmt.Println("Run 'mmq update' to re-index with the new policy")
+	}
+	return nil
+}
+
+// maskPII 遮盖 PII 值，只保留首尾字符
+func maskPII(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:2]) + strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-2:])
+}
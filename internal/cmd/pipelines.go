@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+// pipelines 命令 - 列出流水线目录中的检索流水线
+var pipelinesCmd = &cobra.Command{
+	Use:   "pipelines",
+	Short: "List retrieval pipelines",
+	Long: `List retrieval pipelines loaded from the pipeline directory (default
+~/.mmq/pipelines, configurable with "pipelines.dir"). Each *.yaml file
+defines one pipeline, named after the file:
+
+  # ~/.mmq/pipelines/precise.yaml
+  description: expansion + hybrid + rerank
+  steps:
+    - type: expand
+    - type: fts
+    - type: vector
+      weight: 1.5        # RRF weight of this leg (default 1)
+      candidates: 40     # candidates per leg (default -n × candidate multiplier)
+    - type: fuse
+      k: 60
+    - type: filter
+      min_score: 0.01
+      limit: 30
+    - type: rerank
+      limit: 30          # candidates sent to the reranker
+    - type: compress
+      max_chars: 1200    # keep the first N characters of each document
+
+Run one with 'mmq search --pipeline precise "query"' (also vsearch/query).
+The pipeline replaces the strategy, expansion, rerank and --min-score;
+-n, -c, --lang and the date filters still apply.`,
+	Args: cobra.NoArgs,
+	RunE: runPipelines,
+}
+
+func init() {
+	rootCmd.AddCommand(pipelinesCmd)
+}
+
+func runPipelines(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	pipelines := m.Pipelines()
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindPipelines, pipelines)
+	}
+
+	if len(pipelines) == 0 {
+		fmt.Printf("No pipelines found in %s\n", m.GetConfig().PipelineDir)
+		return nil
+	}
+
+	for _, p := range pipelines {
+		fmt.Printf("%-20s %s", p.Name, strings.Join(p.Steps, " → "))
+		if p.Description != "" {
+			fmt.Printf("  (%s)", p.Description)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+// plugins 命令 - 列出插件目录中的插件
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List installed plugins",
+	Long: `List plugins discovered in the plugin directory (default ~/.mmq/plugins,
+configurable with "plugins.dir"). Each plugin lives in its own subdirectory
+with a plugin.json manifest:
+
+  {
+    "name": "pdf",
+    "kind": "extractor",          // extractor, postprocessor or tool
+    "description": "Extract text from PDF files",
+    "command": ["./pdf-extract"],
+    "extensions": [".pdf"]        // extractor only
+  }
+
+The command is started once per call with a JSON request on stdin,
+{"method": "extract|postprocess|run", "params": {...}}, and must print
+{"result": {...}} or {"error": "..."} on stdout.
+
+  extractor      extract {path}               → {text, title}
+  postprocessor  postprocess {query, results} → {results}
+  tool           run {input}                  → {output}   (chat: /tool <name> <input>)`,
+	Args: cobra.NoArgs,
+	RunE: runPlugins,
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+func runPlugins(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	plugins := m.Plugins()
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindPlugins, plugins)
+	}
+
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins found in %s\n", m.GetConfig().PluginDir)
+		return nil
+	}
+
+	for _, p := range plugins {
+		fmt.Printf("%-20s %-14s", p.Name, p.Kind)
+		switch {
+		case p.Error != "":
+			fmt.Printf(" invalid: %s", p.Error)
+		case p.Description != "":
+			fmt.Printf(" %s", p.Description)
+		}
+		if len(p.Extensions) > 0 {
+			fmt.Printf(" (%s)", strings.Join(p.Extensions, ", "))
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cpuProfile string
+	memProfile string
+)
+
+func init() {
+	// 耗时较长的命令支持采集 pprof 文件，用 `go tool pprof` 分析
+	for _, c := range []*cobra.Command{updateCmd, embedCmd, searchCmd, vsearchCmd, queryCmd} {
+		c.Flags().StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+		c.Flags().StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+		c.RunE = withProfiling(c.RunE)
+	}
+}
+
+// withProfiling 按 --cpuprofile/--memprofile 在命令执行期间采集 pprof 文件
+func withProfiling(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if cpuProfile != "" {
+			f, err := os.Create(cpuProfile)
+			if err != nil {
+				return fmt.Errorf("failed to create CPU profile: %w", err)
+			}
+			defer f.Close()
+			if err := pprof.StartCPUProfile(f); err != nil {
+				return fmt.Errorf("failed to start CPU profile: %w", err)
+			}
+			defer pprof.StopCPUProfile()
+		}
+
+		runErr := run(cmd, args)
+
+		if memProfile != "" {
+			if err := writeHeapProfile(memProfile); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		return runErr
+	}
+}
+
+// writeHeapProfile 写入堆内存 profile
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer f.Close()
+
+	runtime.GC() // 获取最新的存活对象统计
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return nil
+}
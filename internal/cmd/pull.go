@@ -47,10 +47,16 @@ Use --refresh to force re-download even if models already exist.`,
 		fmt.Printf("Pulling models to: %s\n", cacheDir)
 		fmt.Println()
 
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
 		// Setup downloader options
 		opts := llm.DefaultDownloadOptions()
 		opts.CacheDir = cacheDir
 		opts.ForceDownload = pullRefresh
+		opts.Pins = cfg.ModelPins
 
 		// Models to download
 		models := map[string]llm.HFRef{
@@ -65,6 +71,10 @@ Use --refresh to force re-download even if models already exist.`,
 			localPath := filepath.Join(cacheDir, ref.Filename)
 			if !pullRefresh {
 				if _, err := os.Stat(localPath); err == nil {
+					// Cached files must still match their pinned checksum
+					if err := llm.VerifyModel(localPath, opts.Pins); err != nil {
+						return fmt.Errorf("%s model: %w (re-download with --refresh)", name, err)
+					}
 					// File exists, check if up-to-date
 					fmt.Printf("✓ %s model: %s (cached)\n", name, ref.Filename)
 					info, _ := os.Stat(localPath)
@@ -85,7 +95,7 @@ Use --refresh to force re-download even if models already exist.`,
 				if total > 0 {
 					pct := int(downloaded * 100 / total)
 					if pct != lastPct && pct%10 == 0 {
-						fmt.Printf("  Progress: %d%% (%s / %s)\n", pct, formatBytes(downloaded), formatBytes(total))
+						infof("  Progress: %d%% (%s / %s)", pct, formatBytes(downloaded), formatBytes(total))
 						lastPct = pct
 					}
 				}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// purge 命令 - 删除非活跃文档残留的向量和全文索引
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove vectors and full-text rows left behind by removed documents",
+	Long: `Delete embeddings of content no longer referenced by any active document,
+vectors without metadata, and full-text index rows of inactive documents.
+
+Removing documents, removing collections and re-indexing clean these up
+automatically; run purge once to repair databases created by older versions,
+where leftover vectors slow down vector search. Unlike cleanup, purge keeps
+the LLM cache and inactive document records.`,
+	Args: cobra.NoArgs,
+	RunE: runPurge,
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+}
+
+func runPurge(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	result, err := m.GetStore().PurgeInactive()
+	if err != nil {
+		return fmt.Errorf("purge failed: %w", err)
+	}
+
+	if result.Vectors == 0 && result.FTSRows == 0 {
+		fmt.Println("Nothing to purge.")
+		return nil
+	}
+	fmt.Printf("✓ Removed %d vector(s) and %d full-text row(s) of inactive documents\n", result.Vectors, result.FTSRows)
+	fmt.Println("  Run 'mmq cleanup' to reclaim disk space (VACUUM)")
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recentLimit int
+	recentClear bool
+)
+
+// recent 命令 - 最近查看过的文档
+var recentCmd = &cobra.Command{
+	Use:   "recent",
+	Short: "List recently viewed documents",
+	Long: `List documents you recently viewed with 'mmq get', 'mmq multi-get',
+:open in 'mmq repl', or that were cited in 'mmq chat', most recent first.
+
+Set "retrieval.access_boost" in the config file (e.g. 0.2) to rank recently
+viewed documents higher in search results; the boost halves every
+"retrieval.access_halflife" (default 7d).
+
+Examples:
+  mmq recent
+  mmq recent -c notes -n 5
+  mmq recent --clear`,
+	Args: cobra.NoArgs,
+	RunE: runRecent,
+}
+
+func init() {
+	recentCmd.Flags().IntVarP(&recentLimit, "num", "n", 20, "Number of documents")
+	recentCmd.Flags().BoolVar(&recentClear, "clear", false, "Forget all viewing history")
+	rootCmd.AddCommand(recentCmd)
+}
+
+func runRecent(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if recentClear {
+		n, err := m.ClearDocumentAccess()
+		if err != nil {
+			return err
+		}
+		infof("✓ Cleared viewing history of %d documents", n)
+		return nil
+	}
+
+	docs, err := m.RecentDocuments(collectionFlag, recentLimit)
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindRecent, docs)
+	}
+
+	if len(docs) == 0 {
+		fmt.Println("No recently viewed documents")
+		return nil
+	}
+	for _, d := range docs {
+		views := "1 view"
+		if d.AccessCount != 1 {
+			views = fmt.Sprintf("%s views", format.Number(int64(d.AccessCount)))
+		}
+		fmt.Printf("%-16s  %s  %s/%s", format.ListTime(d.LastAccessedAt, time.DateTime), d.DocID, d.Collection, d.Path)
+		if d.Title != "" {
+			fmt.Printf("  %s", d.Title)
+		}
+		fmt.Printf("  (%s)\n", views)
+	}
+	return nil
+}
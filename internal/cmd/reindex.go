@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reindexAll        bool
+	reindexNoEmbed    bool
+	reindexForce      bool
+	reindexDryRun     bool
+	reindexSpotChecks int
+)
+
+// reindex 命令 - 停机全量重建
+var reindexCmd = &cobra.Command{
+	Use:   "reindex --all",
+	Short: "Rebuild all indexes into a fresh database and swap it in",
+	Long: `Rebuild the full-text index, re-chunk and re-embed every document into a
+staging copy of the database, verify row counts and spot-check retrieval
+parity against the current index, then atomically replace the database
+file. The previous database is kept as <db>.bak.
+
+Use this to recover from index corruption or after changing the chunk
+size or embedding model. Stop other mmq processes (serve, watch) first.
+If verification fails the database is left unchanged.
+
+Examples:
+  mmq reindex --all
+  mmq reindex --all --dry-run
+  mmq reindex --all --no-embed`,
+	Args: cobra.NoArgs,
+	RunE: runReindex,
+}
+
+func init() {
+	reindexCmd.Flags().BoolVar(&reindexAll, "all", false, "Rebuild every collection (required)")
+	reindexCmd.Flags().BoolVar(&reindexNoEmbed, "no-embed", false, "Only rebuild the full-text index; clear vectors for a later 'mmq embed'")
+	reindexCmd.Flags().BoolVar(&reindexForce, "force", false, "Swap in the rebuilt database even if verification fails")
+	reindexCmd.Flags().BoolVar(&reindexDryRun, "dry-run", false, "Build and verify without swapping; keep the staging database")
+	reindexCmd.Flags().IntVar(&reindexSpotChecks, "spot-checks", 20, "Documents to spot-check for retrieval parity (0 to skip)")
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	if !reindexAll {
+		return usageError(fmt.Errorf("reindex rebuilds everything; pass --all to confirm"))
+	}
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	// Ctrl-C 在当前块完成后放弃重建，数据库不变；再按一次立即退出
+	ui := newEmbedProgressUI(incidentalWriter())
+	ctx, stop := ui.interruptContext()
+	defer stop()
+
+	spotChecks := reindexSpotChecks
+	if spotChecks == 0 {
+		spotChecks = -1
+	}
+	report, err := m.Rebuild(mmq.RebuildOptions{
+		Context: ctx,
+		Progress: func(p mmq.RebuildProgress) {
+			ui.println(fmt.Sprintf("[%s] %s", p.Elapsed.Round(time.Second), reindexStageText(p.Stage)))
+		},
+		EmbedProgress: ui.update,
+		SkipEmbed:     reindexNoEmbed,
+		SpotChecks:    spotChecks,
+		Force:         reindexForce,
+		DryRun:        reindexDryRun,
+	})
+	ui.finish()
+	if report == nil {
+		return fmt.Errorf("reindex failed: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		if jerr := format.OutputJSON(format.KindRebuild, report); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+	printRebuildReport(report)
+	if errors.Is(err, mmq.ErrRebuildVerification) {
+		return fmt.Errorf("%w (use --force to swap anyway)", err)
+	}
+	return err
+}
+
+// reindexStageText 阶段说明
+func reindexStageText(stage string) string {
+	switch stage {
+	case mmq.RebuildStageSnapshot:
+		return "Copying database to staging file..."
+	case mmq.RebuildStageFTS:
+		return "Rebuilding full-text index..."
+	case mmq.RebuildStageEmbed:
+		return "Re-chunking and re-embedding documents..."
+	case mmq.RebuildStageVerify:
+		return "Verifying counts and retrieval parity..."
+	case mmq.RebuildStageSwap:
+		return "Swapping in the rebuilt database..."
+	}
+	return stage
+}
+
+// printRebuildReport 输出重建前后行数和核对结果
+func printRebuildReport(r *mmq.RebuildReport) {
+	if r.Embed != nil {
+		fmt.Println()
+		printEmbedSummary(r.Embed)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-12s %12s %12s\n", "", "Before", "After")
+	rows := []struct {
+		name          string
+		before, after int
+	}{
+		{"Collections", r.Before.Collections, r.After.Collections},
+		{"Documents", r.Before.Documents, r.After.Documents},
+		{"Contents", r.Before.Contents, r.After.Contents},
+		{"FTS rows", r.Before.FTSRows, r.After.FTSRows},
+		{"Chunks", r.Before.Chunks, r.After.Chunks},
+		{"Memories", r.Before.Memories, r.After.Memories},
+		{"Contexts", r.Before.Contexts, r.After.Contexts},
+	}
+	for _, row := range rows {
+		fmt.Printf("%-12s %12s %12s\n", row.name, format.Number(int64(row.before)), format.Number(int64(row.after)))
+	}
+
+	if n := len(r.SpotChecks); n > 0 {
+		matched := 0
+		for _, c := range r.SpotChecks {
+			if c.FTSMatch {
+				matched++
+			}
+		}
+		fmt.Printf("\nSpot checks: %d/%d full-text results identical\n", matched, n)
+	}
+	for _, w := range r.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	for _, p := range r.Problems {
+		fmt.Printf("Problem: %s\n", p)
+	}
+
+	fmt.Println()
+	switch {
+	case r.Swapped:
+		fmt.Printf("✓ Reindexed in %s; previous database saved as %s\n", r.Elapsed.Round(time.Second), r.Backup)
+	case r.Staging != "":
+		fmt.Printf("Dry run: rebuilt database left at %s (not swapped)\n", r.Staging)
+	}
+}
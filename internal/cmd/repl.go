@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/i18n"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/dyike/mmq/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Interactive search REPL",
+	Long: `Start an interactive search session: type a query to search, and use
+colon commands to switch strategy, change filters and preview documents
+without re-running the CLI.
+
+` + replHelp + `
+History is kept in repl_history next to the database.`,
+	Args: cobra.NoArgs,
+	RunE: runRepl,
+}
+
+func init() {
+	replCmd.Flags().IntVarP(&numResults, "num", "n", 10, "Number of results")
+	replCmd.Flags().BoolVar(&showTiming, "timing", false, "Print per-stage timings after each search")
+	rootCmd.AddCommand(replCmd)
+}
+
+// replHelp REPL 命令说明（:help 和 --help 共用）
+const replHelp = `Commands:
+  :fts | :vec | :hybrid    switch retrieval strategy
+  :rerank | :expand        toggle reranking / query expansion
+  :c [name]                filter by collection (no name clears)
+  :lang [code]             filter by language
+  :after [date]            only documents dated on or after YYYY-MM-DD
+  :before [date]           only documents dated before YYYY-MM-DD
+  :n <num>                 number of results
+  :min <score>             minimum score
+  :filters                 show current settings
+  :reset                   reset strategy and filters
+  :open <n> [full] | :<n>  preview result n of the last search
+  :good <n> | :bad <n>     record result n as relevant / not relevant (mmq feedback)
+  :history                 list previous queries
+  !! | !<n>                re-run the last / n-th query
+  :help | :quit`
+
+// replHistoryLimit 历史文件保留的最大条数
+const replHistoryLimit = 500
+
+// replState REPL 当前的检索设置和上次结果
+type replState struct {
+	opts    mmq.SearchOptions
+	results []mmq.SearchResult
+	history []string
+}
+
+// defaultReplOptions 初始检索设置：混合检索，集合取 -c
+func defaultReplOptions() mmq.SearchOptions {
+	return mmq.SearchOptions{
+		Limit:      numResults,
+		Collection: collectionFlag,
+		Strategy:   mmq.StrategyHybrid,
+	}
+}
+
+func runRepl(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	historyPath := filepath.Join(filepath.Dir(dbPath), "repl_history")
+	st := &replState{opts: defaultReplOptions(), history: loadReplHistory(historyPath)}
+
+	fmt.Println(i18n.T("repl.start"))
+	fmt.Println()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Printf("%s> ", st.prompt())
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+
+		// 历史重放
+		if strings.HasPrefix(input, "!") {
+			query, err := st.recall(input)
+			if err != nil {
+				fmt.Printf("%v\n\n", err)
+				continue
+			}
+			fmt.Println(query)
+			input = query
+		}
+
+		if strings.HasPrefix(input, ":") {
+			if st.handle(m, input) {
+				break
+			}
+			continue
+		}
+
+		st.history = append(st.history, input)
+		appendReplHistory(historyPath, input)
+		st.search(m, input)
+	}
+
+	return nil
+}
+
+// prompt 提示符显示当前策略和过滤条件
+func (st *replState) prompt() string {
+	parts := []string{string(st.opts.Strategy)}
+	if st.opts.Rerank {
+		parts = append(parts, "rerank")
+	}
+	if st.opts.ExpandQuery {
+		parts = append(parts, "expand")
+	}
+	if st.opts.Collection != "" {
+		parts = append(parts, "c="+st.opts.Collection)
+	}
+	if st.opts.Language != "" {
+		parts = append(parts, "lang="+st.opts.Language)
+	}
+	return strings.Join(parts, " ")
+}
+
+// search 执行查询并输出结果列表
+func (st *replState) search(m *mmq.MMQ, query string) {
+	start := time.Now()
+	var timings *mmq.QueryTimings
+	var results []mmq.SearchResult
+	var err error
+	if showTiming {
+		var res *mmq.QueryResult
+		res, err = m.Query(query, mmq.QueryOptions{SearchOptions: st.opts})
+		if err == nil {
+			results, timings = res.Results, &res.Timings
+		}
+	} else {
+		results, err = m.Search(query, st.opts)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	st.results = results
+	if len(results) == 0 {
+		fmt.Printf("No results (%s)\n\n", formatDuration(time.Since(start)))
+		return
+	}
+
+	fmt.Printf("Found %d result(s) in %s\n\n", len(results), formatDuration(time.Since(start)))
+	for i, r := range results {
+		fmt.Printf("[%d] %s  %s/%s  %s\n", i+1, format.Decimal(r.Score, 4), r.Collection, r.Path, r.Title)
+		if r.Snippet != "" {
+			fmt.Printf("    %s\n", truncateForChat(r.Snippet, 120))
+		}
+	}
+	fmt.Println()
+	printTimings(timings)
+}
+
+// handle 处理冒号命令，返回 true 表示退出
+func (st *replState) handle(m *mmq.MMQ, input string) bool {
+	parts := strings.Fields(input)
+	cmd, arg := parts[0], ""
+	if len(parts) > 1 {
+		arg = parts[1]
+	}
+
+	// :<n> 是 :open <n> 的简写
+	if n, err := strconv.Atoi(strings.TrimPrefix(cmd, ":")); err == nil {
+		st.open(m, n, false)
+		return false
+	}
+
+	switch cmd {
+	case ":quit", ":q", ":exit":
+		return true
+
+	case ":help", ":h":
+		fmt.Println(replHelp)
+		fmt.Println()
+
+	case ":fts":
+		st.opts.Strategy = mmq.StrategyFTS
+		fmt.Println("✓ strategy: fts")
+		fmt.Println()
+
+	case ":vec", ":vector":
+		st.opts.Strategy = mmq.StrategyVector
+		fmt.Println("✓ strategy: vector")
+		fmt.Println()
+
+	case ":hybrid":
+		st.opts.Strategy = mmq.StrategyHybrid
+		fmt.Println("✓ strategy: hybrid")
+		fmt.Println()
+
+	case ":rerank":
+		st.opts.Rerank = !st.opts.Rerank
+		fmt.Printf("✓ rerank: %v\n\n", st.opts.Rerank)
+
+	case ":expand":
+		st.opts.ExpandQuery = !st.opts.ExpandQuery
+		fmt.Printf("✓ expand: %v\n\n", st.opts.ExpandQuery)
+
+	case ":c", ":collection":
+		st.opts.Collection = arg
+		st.printFilters()
+
+	case ":lang":
+		st.opts.Language = arg
+		st.printFilters()
+
+	case ":after", ":before":
+		var t time.Time
+		if arg != "" {
+			var err error
+			if t, err = time.Parse(store.DateLayout, arg); err != nil {
+				fmt.Printf("invalid date %q: want YYYY-MM-DD\n\n", arg)
+				break
+			}
+		}
+		if cmd == ":after" {
+			st.opts.After = t
+		} else {
+			st.opts.Before = t
+		}
+		st.printFilters()
+
+	case ":n":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			fmt.Println(i18n.T("repl.usage", ":n <num>"))
+			fmt.Println()
+			break
+		}
+		st.opts.Limit = n
+		st.printFilters()
+
+	case ":min":
+		score, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			fmt.Println(i18n.T("repl.usage", ":min <score>"))
+			fmt.Println()
+			break
+		}
+		st.opts.MinScore = score
+		st.printFilters()
+
+	case ":filters":
+		st.printFilters()
+
+	case ":reset":
+		st.opts = defaultReplOptions()
+		st.printFilters()
+
+	case ":open":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Println(i18n.T("repl.usage", ":open <n> [full]"))
+			fmt.Println()
+			break
+		}
+		st.open(m, n, len(parts) > 2 && parts[2] == "full")
+
+	case ":good", ":bad":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Printf("%s\n\n", i18n.T("repl.usage", cmd+" <n>"))
+			break
+		}
+		st.feedback(m, n, cmd == ":good")
+
+	case ":history":
+		start := 0
+		if len(st.history) > 20 {
+			start = len(st.history) - 20
+		}
+		for i := start; i < len(st.history); i++ {
+			fmt.Printf("  %4d  %s\n", i+1, st.history[i])
+		}
+		fmt.Println()
+
+	default:
+		fmt.Printf("%s\n\n", i18n.T("repl.unknown_command", cmd))
+	}
+
+	return false
+}
+
+// printFilters 输出当前检索设置
+func (st *replState) printFilters() {
+	o := st.opts
+	fmt.Printf("strategy=%s rerank=%v expand=%v n=%d min=%s", o.Strategy, o.Rerank, o.ExpandQuery, o.Limit, format.Decimal(o.MinScore, 2))
+	if o.Collection != "" {
+		fmt.Printf(" collection=%s", o.Collection)
+	}
+	if o.Language != "" {
+		fmt.Printf(" lang=%s", o.Language)
+	}
+	if !o.After.IsZero() {
+		fmt.Printf(" after=%s", o.After.Format(store.DateLayout))
+	}
+	if !o.Before.IsZero() {
+		fmt.Printf(" before=%s", o.Before.Format(store.DateLayout))
+	}
+	fmt.Println()
+	fmt.Println()
+}
+
+// feedback 记录上次查询第 n 个结果的相关性
+func (st *replState) feedback(m *mmq.MMQ, n int, relevant bool) {
+	if n < 1 || n > len(st.results) || len(st.history) == 0 {
+		fmt.Printf("%s\n\n", i18n.T("repl.no_result", n, len(st.results)))
+		return
+	}
+	r := st.results[n-1]
+	if err := m.RecordFeedback(st.history[len(st.history)-1], r.Collection+"/"+r.Path, relevant); err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	fmt.Printf("✓ feedback recorded for %s/%s\n\n", r.Collection, r.Path)
+}
+
+// open 预览上次结果中的第 n 个文档
+func (st *replState) open(m *mmq.MMQ, n int, full bool) {
+	if n < 1 || n > len(st.results) {
+		fmt.Printf("%s\n\n", i18n.T("repl.no_result", n, len(st.results)))
+		return
+	}
+	r := st.results[n-1]
+	doc, err := m.GetDocumentByPath(r.Collection + "/" + r.Path)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	m.RecordDocumentAccess(doc.Collection, doc.Path) // 失败不影响预览
+	format.OutputDocumentDetail(doc, format.FormatText, full, false)
+	fmt.Println()
+}
+
+// recall 解析 !! 和 !<n>
+func (st *replState) recall(input string) (string, error) {
+	if len(st.history) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if input == "!!" {
+		return st.history[len(st.history)-1], nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(input, "!"))
+	if err != nil || n < 1 || n > len(st.history) {
+		return "", fmt.Errorf("no history entry %s (see :history)", input)
+	}
+	return st.history[n-1], nil
+}
+
+// loadReplHistory 读取历史文件（不存在时为空）
+func loadReplHistory(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > replHistoryLimit {
+		history = history[len(history)-replHistoryLimit:]
+	}
+	return history
+}
+
+// appendReplHistory 追加一条历史，写入失败时忽略
+func appendReplHistory(path, query string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strings.ReplaceAll(query, "\n", " "))
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/spf13/cobra"
+)
+
+var resultsDelete bool
+
+// results 命令 - 已保存的命名结果集
+var resultsCmd = &cobra.Command{
+	Use:   "results [name]",
+	Short: "List or show saved result sets",
+	Long: `List result sets saved with 'mmq search/vsearch/query --save <name>', or
+show the documents of one set. Follow-up queries with --within <name> only
+search those documents, so a broad search can be narrowed step by step:
+
+  mmq query "vector databases" -n 50 --save r1
+  mmq search "benchmark" --within r1 --save r2
+  mmq query "memory usage" --within r2
+
+Result sets are temporary: sets older than 7 days are removed whenever a
+new set is saved. Saving under an existing name replaces it.
+
+Examples:
+  mmq results
+  mmq results r1
+  mmq results r1 --delete`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runResults,
+}
+
+func init() {
+	resultsCmd.Flags().BoolVar(&resultsDelete, "delete", false, "Delete the named result set")
+	rootCmd.AddCommand(resultsCmd)
+}
+
+func runResults(cmd *cobra.Command, args []string) error {
+	if resultsDelete && len(args) == 0 {
+		return usageError(fmt.Errorf("--delete needs a result set name"))
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if len(args) == 0 {
+		sets, err := m.ListResultSets()
+		if err != nil {
+			return err
+		}
+		if format.Format(outputFormat) == format.FormatJSON {
+			return format.OutputJSON(format.KindResultSets, sets)
+		}
+		if len(sets) == 0 {
+			fmt.Println("No saved result sets (save one with --save <name>)")
+			return nil
+		}
+		for _, rs := range sets {
+			fmt.Printf("%-16s  %-16s  %4d docs  %s\n", rs.Name, format.ListTime(rs.CreatedAt, time.DateTime), rs.Size, rs.Query)
+		}
+		return nil
+	}
+
+	if resultsDelete {
+		if err := m.DeleteResultSet(args[0]); err != nil {
+			return err
+		}
+		infof("✓ Deleted result set '%s'", args[0])
+		return nil
+	}
+
+	rs, err := m.GetResultSet(args[0])
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindResultSet, rs)
+	}
+	fmt.Printf("%s: %q (%s, %d docs)\n\n", rs.Name, rs.Query, format.ListTime(rs.CreatedAt, time.DateTime), rs.Size)
+	for i, d := range rs.Docs {
+		fmt.Printf("%3d. %s/%s", i+1, d.Collection, d.Path)
+		if d.Title != "" {
+			fmt.Printf("  %s", d.Title)
+		}
+		fmt.Printf("  (%.3f)\n", d.Score)
+	}
+	return nil
+}
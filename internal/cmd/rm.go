@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmPath   string
+	rmDryRun bool
+	rmYes    bool
+)
+
+// rmListLimit 文本输出最多列出的文档数，完整列表用 --format json
+const rmListLimit = 50
+
+// rm 命令 - 按条件批量删除文档
+var rmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove documents matching a collection, path pattern or date",
+	Long: `Remove every document matching the filter in one transaction, instead of
+one path at a time. Filters combine: --collection, --path (a glob such as
+'old/**'; a plain path also matches everything below it), --before and
+--after (the document date, else the modification time).
+
+The matching documents are listed and confirmed before removal; --dry-run
+only lists them, --yes skips the prompt. Removed documents leave the index
+like 'mmq collection remove' does: embeddings no longer used by other
+documents are deleted, and re-indexing the source adds them back.
+
+Examples:
+  mmq rm --collection web --path 'old/**' --dry-run
+  mmq rm --collection web --path 'old/**' --before 2023-01-01
+  mmq rm -c notes --path drafts --yes`,
+	Args: cobra.NoArgs,
+	RunE: runRm,
+}
+
+func init() {
+	rmCmd.Flags().StringVar(&rmPath, "path", "", "Path glob within the collection (e.g. 'old/**')")
+	rmCmd.Flags().StringVar(&beforeDate, "before", "", "Only documents dated before this day (YYYY-MM-DD)")
+	rmCmd.Flags().StringVar(&afterDate, "after", "", "Only documents dated on or after this day (YYYY-MM-DD)")
+	rmCmd.Flags().BoolVar(&rmDryRun, "dry-run", false, "List the matching documents without removing them")
+	rmCmd.Flags().BoolVarP(&rmYes, "yes", "y", false, "Remove without asking")
+	rootCmd.AddCommand(rmCmd)
+}
+
+func runRm(cmd *cobra.Command, args []string) error {
+	after, before, err := parseDateFlags()
+	if err != nil {
+		return usageError(err)
+	}
+	filter := mmq.DeleteFilter{Collection: collectionFlag, Path: rmPath, After: after, Before: before}
+	if filter.Collection == "" && filter.Path == "" && after.IsZero() && before.IsZero() {
+		return usageError(fmt.Errorf("specify at least one of --collection, --path, --before or --after"))
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	// 先预览；JSON 输出未加 --yes 时只输出预览
+	filter.DryRun = true
+	preview, err := m.DeleteDocumentsWhere(filter)
+	if err != nil {
+		return fmt.Errorf("failed to match documents: %w", err)
+	}
+	jsonOut := format.Format(outputFormat) == format.FormatJSON
+	if rmDryRun || (jsonOut && !rmYes) || len(preview.Documents) == 0 {
+		if jsonOut {
+			return format.OutputJSON(format.KindDeletion, preview)
+		}
+		printDeletion(preview)
+		return nil
+	}
+
+	if !rmYes {
+		printDeletion(preview)
+		fmt.Print("Continue? (y/N): ")
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" {
+			fmt.Println("Cancelled")
+			return nil
+		}
+	}
+
+	filter.DryRun = false
+	result, err := m.DeleteDocumentsWhere(filter)
+	if err != nil {
+		return fmt.Errorf("failed to remove documents: %w", err)
+	}
+	if jsonOut {
+		return format.OutputJSON(format.KindDeletion, result)
+	}
+	fmt.Printf("✓ Removed %d document(s)\n", len(result.Documents))
+	return nil
+}
+
+// printDeletion 列出命中的文档
+func printDeletion(r *mmq.DeleteResult) {
+	if len(r.Documents) == 0 {
+		fmt.Println("No matching documents.")
+		return
+	}
+	for i, d := range r.Documents {
+		if i == rmListLimit {
+			fmt.Printf("… and %d more (use --format json for the full list)\n", len(r.Documents)-i)
+			break
+		}
+		fmt.Printf("%-10s  %s  %s/%s\n", format.ListTime(d.ModifiedAt, time.DateOnly), d.DocID, d.Collection, d.Path)
+	}
+	if r.DryRun {
+		fmt.Printf("\n%d document(s) would be removed\n", len(r.Documents))
+	}
+}
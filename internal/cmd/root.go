@@ -6,6 +6,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/i18n"
+	"github.com/dyike/mmq/pkg/llm"
 	"github.com/dyike/mmq/pkg/mmq"
 	"github.com/spf13/cobra"
 )
@@ -22,8 +25,11 @@ var (
 
 	// 全局标志
 	dbPath         string
+	configPath     string
 	collectionFlag string
 	outputFormat   string
+	schemaVersion  int
+	quiet          bool
 )
 
 // printUsageTree 从 cobra 命令树自动生成usage
@@ -71,18 +77,44 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		printUsageTree(cmd)
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		version := schemaVersion
+		if !cmd.Flags().Changed("schema-version") {
+			if env := os.Getenv("MMQ_SCHEMA_VERSION"); env != "" {
+				if _, err := fmt.Sscanf(env, "%d", &version); err != nil {
+					return configError(fmt.Errorf("invalid MMQ_SCHEMA_VERSION %q", env))
+				}
+			}
+		}
+		if err := format.SetSchemaVersion(version); err != nil {
+			return usageError(err)
+		}
+		llm.SetLogger(incidentalLogger())
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// 错误由 reportError 统一输出（--format json 时为结构化错误），用 ExitCode 取退出码
 func Execute() error {
-	return rootCmd.Execute()
+	markUsageErrors(rootCmd)
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		reportError(cmd, err)
+	}
+	return err
 }
 
 func init() {
 	// 全局标志
 	rootCmd.PersistentFlags().StringVarP(&dbPath, "db", "d", DefaultDBPath, "Database path")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file path (default: ~/.mmq/config.json or $MMQ_CONFIG)")
 	rootCmd.PersistentFlags().StringVarP(&collectionFlag, "collection", "c", "", "Collection filter")
-	rootCmd.PersistentFlags().StringVarP(&outputFormat, "format", "f", "text", "Output format (text|json|csv|md|xml)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "format", "f", "text", "Output format (text|json|jsonl|csv|md|xml|compact)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress and model-loading messages (with a non-text --format they go to stderr)")
+	rootCmd.PersistentFlags().IntVar(&schemaVersion, "schema-version", 0, fmt.Sprintf("Wrap JSON output in a versioned envelope (0: legacy bare output, %d: current)", mmq.SchemaVersion))
 
 	// 添加子命令
 	rootCmd.AddCommand(collectionCmd)
@@ -100,19 +132,64 @@ func init() {
 	rootCmd.AddCommand(chatCmd)
 
 	// 版本模板
-	rootCmd.SetVersionTemplate(fmt.Sprintf("mmq version %s (built %s)\n", Version, BuildTime))
+	rootCmd.SetVersionTemplate(fmt.Sprintf("mmq version %s (built %s, output format %d, JSON schema %d)\n", Version, BuildTime, mmq.FormatVersion, mmq.SchemaVersion))
 
 }
 
 // getMMQ 获取MMQ实例（辅助函数）
+// cliActor 变更事件的执行者：MMQ_ACTOR 优先（如 agent 调用时设置），否则为 def
+func cliActor(def string) string {
+	if actor := os.Getenv("MMQ_ACTOR"); actor != "" {
+		return actor
+	}
+	return def
+}
+
+// loadConfig 返回默认配置叠加配置文件（不存在时忽略）
+func loadConfig() (mmq.Config, error) {
+	cfg := mmq.DefaultConfig()
+	cfg.Actor = cliActor("cli")
+
+	cfgFile := configPath
+	if cfgFile == "" {
+		cfgFile = mmq.DefaultConfigPath()
+	}
+	if err := cfg.LoadFile(cfgFile); err != nil {
+		return cfg, configError(err)
+	}
+	// --db 和 MMQ_DB 优先于配置文件的 database.path
+	switch {
+	case rootCmd.PersistentFlags().Changed("db"):
+		cfg.DBPath = dbPath
+	case os.Getenv("MMQ_DB") != "":
+		cfg.DBPath = os.Getenv("MMQ_DB")
+	}
+	dbPath = cfg.DBPath
+
+	// 输出的时区、日期和数字格式
+	locale, err := format.NewLocale(cfg.DisplayTimezone, cfg.DateFormat, cfg.DisplayLocale, cfg.RelativeTimes)
+	if err != nil {
+		return cfg, configError(err)
+	}
+	format.SetLocale(locale)
+	if err := i18n.Set(cfg.Language); err != nil {
+		return cfg, configError(err)
+	}
+	return cfg, nil
+}
+
 func getMMQ() (*mmq.MMQ, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	// 确保数据库目录存在
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create db directory: %w", err)
 	}
 
-	m, err := mmq.NewWithDB(dbPath)
+	m, err := mmq.NewFromConfig(cfg, mmq.WithLogger(incidentalLogger()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
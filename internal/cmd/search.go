@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/dyike/mmq/internal/format"
 	"github.com/dyike/mmq/pkg/mmq"
@@ -14,7 +19,7 @@ var searchCmd = &cobra.Command{
 	Use:   "search <query>",
 	Short: "BM25 full-text search",
 	Long:  "Search documents using BM25 keyword search",
-	Args:  cobra.ExactArgs(1),
+	Args:  queryArgs,
 	RunE:  runSearch,
 }
 
@@ -23,7 +28,7 @@ var vsearchCmd = &cobra.Command{
 	Use:   "vsearch <query>",
 	Short: "Vector semantic search",
 	Long:  "Search documents using vector similarity (requires embeddings)",
-	Args:  cobra.ExactArgs(1),
+	Args:  queryArgs,
 	RunE:  runVSearch,
 }
 
@@ -32,7 +37,7 @@ var queryCmd = &cobra.Command{
 	Use:   "query <query>",
 	Short: "Hybrid search with reranking",
 	Long:  "Search using hybrid strategy (BM25 + Vector + LLM reranking) for best quality",
-	Args:  cobra.ExactArgs(1),
+	Args:  queryArgs,
 	RunE:  runQuery,
 }
 
@@ -40,6 +45,28 @@ var (
 	numResults int
 	minScore   float64
 	showAll    bool
+	langFilter string
+	compactOut bool
+	fieldsFlag string
+	candidates float64
+	rerankMax  int
+	normalize  string
+	showTiming bool
+	timeout    time.Duration
+	afterDate  string
+	beforeDate string
+	pipelineFl string
+	groupBy    string
+	batchFile  string
+	clusterID  int
+	incrSearch bool
+	traceFile  string
+	instructFl string
+	filterExpr string
+	pinVersion int64
+	autoStrat  bool
+	saveSet    string
+	withinSet  string
 )
 
 func init() {
@@ -48,22 +75,90 @@ func init() {
 	searchCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	searchCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	searchCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	searchCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	searchCmd.Flags().BoolVar(&compactOut, "compact", false, "Compact single-line JSON for LLM tool results (same as --format compact)")
+	searchCmd.Flags().StringVar(&fieldsFlag, "fields", "", "Fields for compact output (default: docid,title,snippet,score)")
+	searchCmd.Flags().Float64Var(&candidates, "candidates", 0, "Candidates per retrieval leg as a multiple of -n (default from config: 2)")
+	searchCmd.Flags().StringVar(&normalize, "normalize", "", "Score normalization before --min-score: raw, minmax or calibrated (default from config)")
+	searchCmd.Flags().BoolVar(&showTiming, "timing", false, "Print per-stage timings (embed, fts, vector, fusion, rerank) to stderr")
+	searchCmd.Flags().StringVar(&afterDate, "after", "", "Only documents dated on or after this day (YYYY-MM-DD; path/frontmatter date, else mtime)")
+	searchCmd.Flags().StringVar(&beforeDate, "before", "", "Only documents dated before this day (YYYY-MM-DD)")
+	searchCmd.Flags().StringVar(&pipelineFl, "pipeline", "", "Run a named retrieval pipeline from the pipeline directory (see 'mmq pipelines')")
+	searchCmd.Flags().IntVar(&clusterID, "cluster", 0, "Only documents in this cluster of the -c collection (see 'mmq cluster')")
+	searchCmd.Flags().StringVar(&groupBy, "group-by", "", "Group results: doc (nest chunk hits under each document) or collection")
+	searchCmd.Flags().StringVar(&batchFile, "batch", "", "Run every query in a file (one per line, # comments; - for stdin); use --format jsonl for one row per query")
+	searchCmd.Flags().StringVar(&traceFile, "trace", "", "Write the full retrieval trace (expansions, per-leg candidates and scores, fusion table, rerank scores, settings) to a JSON file for bug reports")
+	searchCmd.Flags().StringVar(&filterExpr, "filter", "", "Keep only results matching an expression applied after fusion, e.g. 'score>0.4 && collection!=\"web\"' (fields: score, collection, path, title, language, date, source, docid, text)")
+	searchCmd.Flags().Int64Var(&pinVersion, "pin", 0, "Pin retrieval to a corpus version (corpus_version of earlier results): documents changed since then are left out")
+	searchCmd.Flags().StringVar(&saveSet, "save", "", "Save the results as a named result set for follow-up queries with --within (kept 7 days)")
+	searchCmd.Flags().StringVar(&withinSet, "within", "", "Only search the documents of a saved result set (see --save and 'mmq results')")
 
 	// vsearch 标志
 	vsearchCmd.Flags().IntVarP(&numResults, "num", "n", 10, "Number of results")
 	vsearchCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	vsearchCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	vsearchCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	vsearchCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	vsearchCmd.Flags().BoolVar(&compactOut, "compact", false, "Compact single-line JSON for LLM tool results (same as --format compact)")
+	vsearchCmd.Flags().StringVar(&fieldsFlag, "fields", "", "Fields for compact output (default: docid,title,snippet,score)")
+	vsearchCmd.Flags().Float64Var(&candidates, "candidates", 0, "Candidates per retrieval leg as a multiple of -n (default from config: 2)")
+	vsearchCmd.Flags().StringVar(&normalize, "normalize", "", "Score normalization before --min-score: raw, minmax or calibrated (default from config)")
+	vsearchCmd.Flags().BoolVar(&showTiming, "timing", false, "Print per-stage timings (embed, fts, vector, fusion, rerank) to stderr")
+	vsearchCmd.Flags().StringVar(&afterDate, "after", "", "Only documents dated on or after this day (YYYY-MM-DD; path/frontmatter date, else mtime)")
+	vsearchCmd.Flags().StringVar(&beforeDate, "before", "", "Only documents dated before this day (YYYY-MM-DD)")
+	vsearchCmd.Flags().StringVar(&pipelineFl, "pipeline", "", "Run a named retrieval pipeline from the pipeline directory (see 'mmq pipelines')")
+	vsearchCmd.Flags().IntVar(&clusterID, "cluster", 0, "Only documents in this cluster of the -c collection (see 'mmq cluster')")
+	vsearchCmd.Flags().StringVar(&groupBy, "group-by", "", "Group results: doc (nest chunk hits under each document) or collection")
+	vsearchCmd.Flags().StringVar(&batchFile, "batch", "", "Run every query in a file (one per line, # comments; - for stdin); use --format jsonl for one row per query")
+	vsearchCmd.Flags().StringVar(&traceFile, "trace", "", "Write the full retrieval trace (expansions, per-leg candidates and scores, fusion table, rerank scores, settings) to a JSON file for bug reports")
+	vsearchCmd.Flags().StringVar(&filterExpr, "filter", "", "Keep only results matching an expression applied after fusion, e.g. 'score>0.4 && collection!=\"web\"' (fields: score, collection, path, title, language, date, source, docid, text)")
+	vsearchCmd.Flags().Int64Var(&pinVersion, "pin", 0, "Pin retrieval to a corpus version (corpus_version of earlier results): documents changed since then are left out")
+	vsearchCmd.Flags().StringVar(&saveSet, "save", "", "Save the results as a named result set for follow-up queries with --within (kept 7 days)")
+	vsearchCmd.Flags().StringVar(&withinSet, "within", "", "Only search the documents of a saved result set (see --save and 'mmq results')")
+	vsearchCmd.Flags().StringVar(&instructFl, "instruction", "", "Embedding instruction for this query, replacing the model's default retrieval instruction (e.g. \"Identify the topic of the text\")")
 
 	// query 标志
 	queryCmd.Flags().IntVarP(&numResults, "num", "n", 10, "Number of results")
 	queryCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	queryCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	queryCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	queryCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	queryCmd.Flags().BoolVar(&compactOut, "compact", false, "Compact single-line JSON for LLM tool results (same as --format compact)")
+	queryCmd.Flags().StringVar(&fieldsFlag, "fields", "", "Fields for compact output (default: docid,title,snippet,score)")
+	queryCmd.Flags().Float64Var(&candidates, "candidates", 0, "Candidates per retrieval leg as a multiple of -n (default from config: 2)")
+	queryCmd.Flags().StringVar(&normalize, "normalize", "", "Score normalization before --min-score: raw, minmax or calibrated (default from config)")
+	queryCmd.Flags().BoolVar(&showTiming, "timing", false, "Print per-stage timings (embed, fts, vector, fusion, rerank) to stderr")
+	queryCmd.Flags().StringVar(&afterDate, "after", "", "Only documents dated on or after this day (YYYY-MM-DD; path/frontmatter date, else mtime)")
+	queryCmd.Flags().StringVar(&beforeDate, "before", "", "Only documents dated before this day (YYYY-MM-DD)")
+	queryCmd.Flags().StringVar(&pipelineFl, "pipeline", "", "Run a named retrieval pipeline from the pipeline directory (see 'mmq pipelines')")
+	queryCmd.Flags().IntVar(&clusterID, "cluster", 0, "Only documents in this cluster of the -c collection (see 'mmq cluster')")
+	queryCmd.Flags().StringVar(&groupBy, "group-by", "", "Group results: doc (nest chunk hits under each document) or collection")
+	queryCmd.Flags().StringVar(&batchFile, "batch", "", "Run every query in a file (one per line, # comments; - for stdin); use --format jsonl for one row per query")
+	queryCmd.Flags().IntVar(&rerankMax, "rerank-limit", 0, "Maximum candidates sent to the reranker (default from config: 40)")
+	queryCmd.Flags().DurationVar(&timeout, "timeout", 0, "Retrieval time budget (e.g. 2s); expansion, the vector leg and rerank are skipped when exceeded")
+	queryCmd.Flags().BoolVar(&incrSearch, "incremental", false, "Print fast FTS results first, then hybrid and reranked results as each stage finishes (one JSON line per stage with --format jsonl)")
+	queryCmd.Flags().StringVar(&traceFile, "trace", "", "Write the full retrieval trace (expansions, per-leg candidates and scores, fusion table, rerank scores, settings) to a JSON file for bug reports")
+	queryCmd.Flags().StringVar(&filterExpr, "filter", "", "Keep only results matching an expression applied after fusion, e.g. 'score>0.4 && collection!=\"web\"' (fields: score, collection, path, title, language, date, source, docid, text)")
+	queryCmd.Flags().Int64Var(&pinVersion, "pin", 0, "Pin retrieval to a corpus version (corpus_version of earlier results): documents changed since then are left out")
+	queryCmd.Flags().StringVar(&saveSet, "save", "", "Save the results as a named result set for follow-up queries with --within (kept 7 days)")
+	queryCmd.Flags().StringVar(&withinSet, "within", "", "Only search the documents of a saved result set (see --save and 'mmq results')")
+	queryCmd.Flags().StringVar(&instructFl, "instruction", "", "Embedding instruction for this query, replacing the model's default retrieval instruction (e.g. \"Identify the topic of the text\")")
+	queryCmd.Flags().BoolVar(&autoStrat, "auto", false, "Use the -c collection's benchmarked strategy (see 'mmq collection bench') instead of hybrid")
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	if err := mmq.ValidateGroupBy(groupBy); err != nil {
+		return err
+	}
+
+	after, before, err := parseDateFlags()
+	if err != nil {
+		return err
+	}
+	postFilter, err := parseFilterFlag()
+	if err != nil {
+		return err
+	}
 
 	m, err := getMMQ()
 	if err != nil {
@@ -76,28 +171,58 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		limit = 0 // 0 表示不限制
 	}
 
-	results, err := m.Search(query, mmq.SearchOptions{
+	opts := mmq.SearchOptions{
 		Limit:      limit,
 		MinScore:   minScore,
 		Collection: collectionFlag,
+		Language:   langFilter,
 		Strategy:   mmq.StrategyFTS,
-	})
 
+		CandidateMultiplier: candidates,
+		Normalize:           normalize,
+		After:               after,
+		Before:              before,
+		Pipeline:            pipelineFl,
+		Cluster:             clusterID,
+		PostFilter:          postFilter,
+		CorpusVersion:       pinVersion,
+		Within:              withinSet,
+	}
+	if err := checkSaveFlag(); err != nil {
+		return err
+	}
+	if batchFile != "" {
+		return runBatch(m, opts)
+	}
+
+	results, groups, timings, err := timedSearch(m, args[0], opts)
 	if err != nil {
 		return fmt.Errorf("search failed: %w", err)
 	}
-
-	if len(results) == 0 {
-		fmt.Println("No results found")
-		return nil
+	if err := saveResults(m, args[0], results); err != nil {
+		return err
 	}
 
-	fmt.Printf("Found %d result(s)\n\n", len(results))
-	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
+	if err := outputSearchResults(results, groups, ""); err != nil {
+		return err
+	}
+	printTimings(timings)
+	return nil
 }
 
 func runVSearch(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	if err := mmq.ValidateGroupBy(groupBy); err != nil {
+		return err
+	}
+
+	after, before, err := parseDateFlags()
+	if err != nil {
+		return err
+	}
+	postFilter, err := parseFilterFlag()
+	if err != nil {
+		return err
+	}
 
 	m, err := getMMQ()
 	if err != nil {
@@ -106,8 +231,8 @@ func runVSearch(cmd *cobra.Command, args []string) error {
 	defer m.Close()
 
 	// 检查索引健康状态
-	if health, err := m.GetStore().CheckIndexHealth(); err == nil {
-		store.PrintIndexHealthWarnings(health)
+	if health, err := m.GetStore().CheckIndexHealth(); err == nil && !isCompactOutput() {
+		store.WriteIndexHealthWarnings(incidentalWriter(), health)
 	}
 
 	limit := numResults
@@ -115,29 +240,59 @@ func runVSearch(cmd *cobra.Command, args []string) error {
 		limit = 0
 	}
 
-	results, err := m.Search(query, mmq.SearchOptions{
+	opts := mmq.SearchOptions{
 		Limit:      limit,
 		MinScore:   minScore,
 		Collection: collectionFlag,
+		Language:   langFilter,
 		Strategy:   mmq.StrategyVector,
-	})
 
+		CandidateMultiplier: candidates,
+		Normalize:           normalize,
+		After:               after,
+		Before:              before,
+		Pipeline:            pipelineFl,
+		Cluster:             clusterID,
+		PostFilter:          postFilter,
+		CorpusVersion:       pinVersion,
+		Instruction:         instructFl,
+		Within:              withinSet,
+	}
+	if err := checkSaveFlag(); err != nil {
+		return err
+	}
+	if batchFile != "" {
+		return runBatch(m, opts)
+	}
+
+	results, groups, timings, err := timedSearch(m, args[0], opts)
 	if err != nil {
 		return fmt.Errorf("vector search failed: %w", err)
 	}
-
-	if len(results) == 0 {
-		fmt.Println("No results found")
-		fmt.Println("Make sure documents have embeddings (run 'mmq embed')")
-		return nil
+	if err := saveResults(m, args[0], results); err != nil {
+		return err
 	}
 
-	fmt.Printf("Found %d result(s)\n\n", len(results))
-	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
+	if err := outputSearchResults(results, groups, "", "Make sure documents have embeddings (run 'mmq embed')"); err != nil {
+		return err
+	}
+	printTimings(timings)
+	return nil
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	if err := mmq.ValidateGroupBy(groupBy); err != nil {
+		return err
+	}
+
+	after, before, err := parseDateFlags()
+	if err != nil {
+		return err
+	}
+	postFilter, err := parseFilterFlag()
+	if err != nil {
+		return err
+	}
 
 	m, err := getMMQ()
 	if err != nil {
@@ -146,8 +301,8 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	defer m.Close()
 
 	// 检查索引健康状态
-	if health, err := m.GetStore().CheckIndexHealth(); err == nil {
-		store.PrintIndexHealthWarnings(health)
+	if health, err := m.GetStore().CheckIndexHealth(); err == nil && !isCompactOutput() {
+		store.WriteIndexHealthWarnings(incidentalWriter(), health)
 	}
 
 	limit := numResults
@@ -156,25 +311,288 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	}
 
 	// 使用混合检索策略 + 查询扩展 + 重排
-	results, err := m.Search(query, mmq.SearchOptions{
+	opts := mmq.SearchOptions{
 		Limit:       limit,
 		MinScore:    minScore,
 		Collection:  collectionFlag,
+		Language:    langFilter,
 		Strategy:    mmq.StrategyHybrid,
 		Rerank:      true,
 		ExpandQuery: true,
-	})
 
+		CandidateMultiplier: candidates,
+		Normalize:           normalize,
+		RerankLimit:         rerankMax,
+		Timeout:             timeout,
+		After:               after,
+		Before:              before,
+		Pipeline:            pipelineFl,
+		Cluster:             clusterID,
+		PostFilter:          postFilter,
+		CorpusVersion:       pinVersion,
+		Instruction:         instructFl,
+		Within:              withinSet,
+	}
+	if autoStrat {
+		opts.Strategy = mmq.StrategyAuto
+	}
+	if err := checkSaveFlag(); err != nil {
+		return err
+	}
+	if batchFile != "" {
+		return runBatch(m, opts)
+	}
+	if incrSearch {
+		if traceFile != "" {
+			return usageError(fmt.Errorf("--trace cannot be combined with --incremental"))
+		}
+		return runIncremental(m, args[0], opts)
+	}
+
+	results, groups, timings, err := timedSearch(m, args[0], opts)
 	if err != nil {
 		return fmt.Errorf("hybrid search failed: %w", err)
 	}
+	if err := saveResults(m, args[0], results); err != nil {
+		return err
+	}
+
+	if err := outputSearchResults(results, groups, " using hybrid search"); err != nil {
+		return err
+	}
+	printTimings(timings)
+	return nil
+}
+
+// checkSaveFlag --save 只能保存单次查询的最终结果
+func checkSaveFlag() error {
+	if saveSet != "" && (batchFile != "" || incrSearch) {
+		return usageError(fmt.Errorf("--save cannot be combined with --batch or --incremental"))
+	}
+	return nil
+}
+
+// saveResults 按 --save 把结果保存为命名结果集
+func saveResults(m *mmq.MMQ, query string, results []mmq.SearchResult) error {
+	if saveSet == "" {
+		return nil
+	}
+	if err := m.SaveResultSet(saveSet, query, results); err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	infof("✓ Saved %d result(s) as '%s' (refine with --within %s)", len(results), saveSet, saveSet)
+	return nil
+}
+
+// runIncremental 逐阶段输出 --incremental 查询的结果
+func runIncremental(m *mmq.MMQ, query string, opts mmq.SearchOptions) error {
+	for u := range m.SearchIncremental(context.Background(), query, opts) {
+		if u.Err != nil {
+			return u.Err
+		}
+		if isJSONFormat(outputFormat) {
+			if err := format.OutputJSONLine(format.KindSearchUpdate, u); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("== %s (%s) ==\n", u.Stage, u.Elapsed.Round(time.Millisecond))
+		if err := outputSearchResults(u.Results, nil, ""); err != nil {
+			return err
+		}
+		if !u.Final {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// queryArgs 需要一个查询参数，使用 --batch 时不接受参数
+func queryArgs(cmd *cobra.Command, args []string) error {
+	if batchFile != "" {
+		return cobra.NoArgs(cmd, args)
+	}
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
+// runBatch 依次执行 --batch 文件中的查询，共享模型加载和缓存
+func runBatch(m *mmq.MMQ, opts mmq.SearchOptions) error {
+	if traceFile != "" {
+		return usageError(fmt.Errorf("--trace cannot be combined with --batch"))
+	}
+	in := os.Stdin
+	if batchFile != "-" {
+		f, err := os.Open(batchFile)
+		if err != nil {
+			return fmt.Errorf("failed to open batch file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	queries, err := mmq.ReadQueries(in)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	failed := 0
+	out := format.NewBatchOutput(format.Format(outputFormat), fullContent)
+	err = m.BatchSearch(queries, opts, func(r mmq.BatchResult) error {
+		if r.Error != "" {
+			failed++
+		}
+		return out.Write(r)
+	})
+	if err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Ran %d queries in %s (%d failed)\n", len(queries), formatDuration(time.Since(start)), failed)
+	return nil
+}
+
+// timedSearch 执行搜索；启用 --timing、--timeout 或 --group-by 时通过 Query 收集耗时、降级信息和分组
+func timedSearch(m *mmq.MMQ, query string, opts mmq.SearchOptions) ([]mmq.SearchResult, []mmq.ResultGroup, *mmq.QueryTimings, error) {
+	if traceFile != "" {
+		return tracedSearch(m, query, opts)
+	}
+	if !showTiming && opts.Timeout == 0 && groupBy == "" {
+		results, err := m.Search(query, opts)
+		return results, nil, nil, err
+	}
+
+	res, err := m.Query(query, mmq.QueryOptions{SearchOptions: opts, GroupBy: groupBy})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if res.Degraded {
+		fmt.Fprintf(os.Stderr, "Warning: retrieval exceeded --timeout %s, skipped: %s\n", opts.Timeout, strings.Join(res.Skipped, ", "))
+	}
+	if !showTiming {
+		return res.Results, res.Groups, nil, nil
+	}
+	return res.Results, res.Groups, &res.Timings, nil
+}
+
+// tracedSearch 搜索并把完整检索过程写入 --trace 文件
+func tracedSearch(m *mmq.MMQ, query string, opts mmq.SearchOptions) ([]mmq.SearchResult, []mmq.ResultGroup, *mmq.QueryTimings, error) {
+	trace, err := m.TraceSearch(query, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	trace.Version = Version
+
+	data, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := os.WriteFile(traceFile, append(data, '\n'), 0644); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to write trace: %w", err)
+	}
+	infof("✓ Retrieval trace written to %s", traceFile)
+
+	groups, err := mmq.GroupResults(trace.Results, groupBy)
+	if err != nil || !showTiming {
+		return trace.Results, groups, nil, err
+	}
+	timings := trace.Timings()
+	return trace.Results, groups, &timings, nil
+}
+
+// printTimings 输出各阶段耗时到 stderr（不影响 stdout 的 JSON 输出）
+func printTimings(t *mmq.QueryTimings) {
+	if t == nil {
+		return
+	}
+
+	stages := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"expand", t.Expand},
+		{"embed", t.Embed},
+		{"fts", t.FTS},
+		{"vector", t.Vector},
+		{"fusion", t.Fusion},
+		{"rerank", t.Rerank},
+	}
+	var parts []string
+	for _, s := range stages {
+		if s.d > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", s.name, formatDuration(s.d)))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\nTiming: %s (total %s)\n", strings.Join(parts, ", "), formatDuration(t.Total))
+}
+
+// formatDuration 毫秒精度的耗时
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// parseDateFlags 解析 --after/--before
+func parseDateFlags() (after, before time.Time, err error) {
+	if afterDate != "" {
+		if after, err = time.Parse(store.DateLayout, afterDate); err != nil {
+			return after, before, fmt.Errorf("invalid --after %q: want YYYY-MM-DD", afterDate)
+		}
+	}
+	if beforeDate != "" {
+		if before, err = time.Parse(store.DateLayout, beforeDate); err != nil {
+			return after, before, fmt.Errorf("invalid --before %q: want YYYY-MM-DD", beforeDate)
+		}
+	}
+	return after, before, nil
+}
+
+// parseFilterFlag 解析 --filter 表达式，未指定时返回 nil
+func parseFilterFlag() (func(mmq.Context) bool, error) {
+	if filterExpr == "" {
+		return nil, nil
+	}
+	fn, err := mmq.ParseFilter(filterExpr)
+	if err != nil {
+		return nil, usageError(err)
+	}
+	return fn, nil
+}
+
+// isCompactOutput 是否输出供工具调用的紧凑 JSON
+func isCompactOutput() bool {
+	return compactOut || format.Format(outputFormat) == format.FormatToolJSON
+}
+
+// outputSearchResults 输出搜索结果；紧凑模式只输出 JSON，不打印提示
+// groups 非空时（--group-by）按组输出，紧凑模式仍输出扁平结果
+func outputSearchResults(results []mmq.SearchResult, groups []mmq.ResultGroup, via string, emptyHints ...string) error {
+	if isCompactOutput() {
+		fields, err := format.ParseToolFields(fieldsFlag)
+		if err != nil {
+			return err
+		}
+		return format.OutputToolJSON(results, fields)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSONL {
+		return format.OutputSearchResults(results, format.FormatJSONL, fullContent)
+	}
 
-	// 转换为 SearchResult
 	if len(results) == 0 {
 		fmt.Println("No results found")
+		for _, hint := range emptyHints {
+			fmt.Println(hint)
+		}
 		return nil
 	}
 
-	fmt.Printf("Found %d result(s) using hybrid search\n\n", len(results))
+	if groups != nil {
+		fmt.Printf("Found %d result(s) in %d group(s)%s\n\n", len(results), len(groups), via)
+		return format.OutputResultGroups(groups, format.Format(outputFormat), fullContent)
+	}
+
+	fmt.Printf("Found %d result(s)%s\n\n", len(results), via)
 	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
 }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var sessionExportOutput string
+
+// sessions 命令 - 对话会话
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage chat sessions",
+}
+
+// sessions export 子命令
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Export a chat session as a markdown transcript",
+	Long: `Render a chat session into a markdown transcript for archiving in notes:
+every turn, the documents injected into each answer as footnote citations,
+and the memories extracted from the conversation.
+
+Only turns stored while memory was on are included. Use '/export' inside
+'mmq chat' to export the current session.
+
+Examples:
+  mmq sessions export 3f2a9c1d -o chat.md
+  mmq sessions export 3f2a9c1d --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsExport,
+}
+
+func init() {
+	sessionsExportCmd.Flags().StringVarP(&sessionExportOutput, "output", "o", "-", "Markdown output file (- for stdout)")
+	sessionsCmd.AddCommand(sessionsExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+func runSessionsExport(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	transcript, err := m.SessionTranscript(args[0])
+	if err != nil {
+		return err
+	}
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindTranscript, transcript)
+	}
+	if sessionExportOutput == "-" {
+		return format.WriteTranscriptMarkdown(os.Stdout, transcript)
+	}
+	if err := writeTranscriptFile(m, sessionExportOutput, transcript); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Wrote %d turn(s) to %s\n", len(transcript.Turns), sessionExportOutput)
+	return nil
+}
+
+// writeTranscriptFile 把对话记录写入 Markdown 文件，路径匹配内容转换时加密后写入
+func writeTranscriptFile(m *mmq.MMQ, path string, t *mmq.Transcript) error {
+	var buf bytes.Buffer
+	if err := format.WriteTranscriptMarkdown(&buf, t); err != nil {
+		return err
+	}
+	return m.WriteFile(path, buf.Bytes(), 0644)
+}
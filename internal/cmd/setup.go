@@ -2,20 +2,40 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/mmq"
 	"github.com/spf13/cobra"
 )
 
 // fallback 版本号，当 yzma 无法自动获取 latest 时使用
 const llamaCppFallbackVersion = "b7974"
 
+// llamaCppRepo 从源码构建时使用的 llama.cpp 仓库
+const llamaCppRepo = "https://github.com/ggml-org/llama.cpp"
+
+var (
+	setupProcessor    string
+	setupBuild        bool
+	setupLlamaVersion string
+	setupSkipModels   bool
+	setupNoSave       bool
+)
+
 func init() {
 	rootCmd.AddCommand(setupCmd)
+	setupCmd.Flags().StringVar(&setupProcessor, "processor", "", "Inference backend: cpu, cuda, metal, vulkan (default: detected from OS/arch)")
+	setupCmd.Flags().BoolVar(&setupBuild, "build", false, "Build llama.cpp from source instead of downloading a prebuilt library")
+	setupCmd.Flags().StringVar(&setupLlamaVersion, "llama-version", "", "llama.cpp release tag, e.g. "+llamaCppFallbackVersion+" (default: latest, or "+llamaCppFallbackVersion+" when building)")
+	setupCmd.Flags().BoolVar(&setupSkipModels, "skip-models", false, "Only install the inference library")
+	setupCmd.Flags().BoolVar(&setupNoSave, "no-save", false, "Do not write the library path to the config file")
 }
 
 var setupCmd = &cobra.Command{
@@ -23,15 +43,71 @@ var setupCmd = &cobra.Command{
 	Short: "Install yzma library and download models",
 	Long: `Setup the local inference environment:
 
-1. Check/install yzma library (llama.cpp via purego FFI)
-2. Download embedding models from HuggingFace
+1. Detect OS/arch and install the llama.cpp shared library (via purego FFI).
+   Prebuilt libraries are downloaded where available; otherwise
+   (e.g. Linux ARM64, Intel macOS) llama.cpp is built from source,
+   which requires git, cmake and a C/C++ compiler.
+2. Verify the library loads and save its path to the config file
+   (models.lib_path), so YZMA_LIB no longer needs to be set.
+3. Download embedding models from HuggingFace
 
-After setup, set YZMA_LIB to the library path:
-  export YZMA_LIB=~/.cache/mmq/lib`,
+YZMA_LIB still takes precedence over the saved path when set.`,
 	RunE: runSetup,
 }
 
+// llamaPlatform 安装推理库的目标平台
+type llamaPlatform struct {
+	OS        string
+	Arch      string
+	Processor string
+}
+
+// detectPlatform 检测当前平台，未指定 processor 时 Apple Silicon 用 metal，其他用 cpu
+func detectPlatform(processor string) (llamaPlatform, error) {
+	p := llamaPlatform{OS: runtime.GOOS, Arch: runtime.GOARCH, Processor: processor}
+	if p.Processor == "" {
+		p.Processor = "cpu"
+		if p.OS == "darwin" && p.Arch == "arm64" {
+			p.Processor = "metal"
+		}
+	}
+	switch p.Processor {
+	case "cpu", "cuda", "metal", "vulkan":
+	default:
+		return p, usageError(fmt.Errorf("invalid processor %q (expected cpu, cuda, metal or vulkan)", p.Processor))
+	}
+	if p.Processor == "metal" && p.OS != "darwin" {
+		return p, usageError(fmt.Errorf("processor metal is only available on macOS"))
+	}
+	return p, nil
+}
+
+// hasPrebuilt 是否有 llama.cpp 官方预编译库（与 yzma install 支持的组合一致）
+func (p llamaPlatform) hasPrebuilt() bool {
+	switch p.OS {
+	case "darwin":
+		return p.Arch == "arm64" && p.Processor == "metal"
+	case "linux":
+		if p.Arch == "arm64" {
+			// 没有 Linux ARM64 的 CPU/CUDA 预编译库
+			return p.Processor == "vulkan"
+		}
+		return p.Arch == "amd64" && p.Processor != "metal"
+	case "windows":
+		if p.Arch == "arm64" {
+			return p.Processor == "cpu"
+		}
+		return p.Arch == "amd64"
+	}
+	return false
+}
+
 func runSetup(cmd *cobra.Command, args []string) error {
+	if !llm.LocalInference {
+		return fmt.Errorf("this mmq binary was built without local model support (-tags purego); "+
+			"set MMQ_EMBED_BASE_URL and MMQ_EMBED_MODEL (or models.embedding_api in the config) to use a remote embedding API: %w", llm.ErrUnsupported)
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %w", err)
@@ -40,65 +116,64 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	libDir := filepath.Join(homeDir, ".cache", "mmq", "lib")
 	modelsDir := filepath.Join(homeDir, ".cache", "mmq", "models")
 
-	// Step 1: 检查 YZMA_LIB
-	fmt.Println("=== Step 1: Check yzma library ===")
-	libPath := os.Getenv("YZMA_LIB")
-	if libPath != "" {
-		if hasLlamaLib(libPath) {
-			fmt.Printf("  YZMA_LIB is set: %s\n", libPath)
-		} else {
-			fmt.Printf("  YZMA_LIB is set but no library found at: %s\n", libPath)
-			libPath = ""
-		}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
 	}
-
-	if libPath == "" && hasLlamaLib(libDir) {
-		libPath = libDir
-		fmt.Printf("  Found library at: %s\n", libPath)
+	platform, err := detectPlatform(setupProcessor)
+	if err != nil {
+		return err
 	}
 
-	if libPath == "" {
-		fmt.Println("  Library not found. Installing yzma...")
+	// Step 1: 检查已有的库（YZMA_LIB、配置文件、默认目录）
+	fmt.Println("=== Step 1: Install llama.cpp library ===")
+	fmt.Printf("  Platform: %s/%s (processor: %s)\n", platform.OS, platform.Arch, platform.Processor)
 
-		// 检查 yzma 命令是否可用
-		yzmaBin, err := exec.LookPath("yzma")
-		if err != nil {
-			fmt.Println("  Installing yzma CLI tool...")
-			installCmd := exec.Command("go", "install", "github.com/hybridgroup/yzma/cmd/yzma@latest")
-			installCmd.Stdout = os.Stdout
-			installCmd.Stderr = os.Stderr
-			if err := installCmd.Run(); err != nil {
-				return fmt.Errorf("failed to install yzma: %w\n\nManual install:\n  go install github.com/hybridgroup/yzma/cmd/yzma@latest", err)
+	libPath := ""
+	if !setupBuild {
+		for _, candidate := range []struct{ source, path string }{
+			{"YZMA_LIB", os.Getenv("YZMA_LIB")},
+			{"config", cfg.LibPath},
+			{"default", libDir},
+		} {
+			if candidate.path == "" {
+				continue
+			}
+			if hasLlamaLib(candidate.path) {
+				libPath = candidate.path
+				fmt.Printf("  Found library (%s): %s\n", candidate.source, libPath)
+				break
+			}
+			if candidate.source != "default" {
+				fmt.Printf("  %s is set but no library found at: %s\n", candidate.source, candidate.path)
 			}
-			yzmaBin = "yzma"
 		}
+	}
 
-		// 使用 yzma install 下载 llama.cpp 库
-		fmt.Printf("  Downloading llama.cpp library to %s...\n", libDir)
+	if libPath == "" {
 		if err := os.MkdirAll(libDir, 0755); err != nil {
 			return fmt.Errorf("failed to create lib directory: %w", err)
 		}
 
-		// 检测 processor（macOS 优先 metal）
-		processor := "cpu"
-		if runtime.GOOS == "darwin" {
-			processor = "metal"
-		}
-
-		// 先尝试不指定版本（自动获取 latest）
-		installArgs := []string{"install", "--lib", libDir, "--processor", processor}
-		installCmd := exec.Command(yzmaBin, installArgs...)
-		installCmd.Stdout = os.Stdout
-		installCmd.Stderr = os.Stderr
-		if err := installCmd.Run(); err != nil {
-			// fallback：指定版本号（GitHub API rate limit 时）
-			fmt.Printf("  Auto-detect failed, trying fallback version %s...\n", llamaCppFallbackVersion)
-			installArgs = append(installArgs, "--version", llamaCppFallbackVersion)
-			installCmd = exec.Command(yzmaBin, installArgs...)
-			installCmd.Stdout = os.Stdout
-			installCmd.Stderr = os.Stderr
-			if err := installCmd.Run(); err != nil {
-				return fmt.Errorf("failed to download llama.cpp library: %w", err)
+		installed := false
+		if !setupBuild && platform.hasPrebuilt() {
+			if err := installPrebuiltLib(libDir, platform, setupLlamaVersion); err != nil {
+				fmt.Printf("  Prebuilt install failed: %v\n", err)
+				fmt.Println("  Falling back to building from source...")
+			} else {
+				installed = true
+			}
+		} else if !setupBuild {
+			fmt.Printf("  No prebuilt library for %s/%s %s, building from source...\n", platform.OS, platform.Arch, platform.Processor)
+		}
+
+		if !installed {
+			version := setupLlamaVersion
+			if version == "" {
+				version = llamaCppFallbackVersion
+			}
+			if err := buildLlamaLib(libDir, platform, version); err != nil {
+				return fmt.Errorf("failed to build llama.cpp library: %w", err)
 			}
 		}
 
@@ -108,9 +183,30 @@ func runSetup(cmd *cobra.Command, args []string) error {
 		libPath = libDir
 	}
 
+	// 校验库能被加载（架构不匹配、缺少依赖等在这里暴露）
+	if err := llm.VerifyLibrary(libPath); err != nil {
+		return fmt.Errorf("%w\n\nRe-run with --build to compile llama.cpp for this machine", err)
+	}
 	fmt.Printf("  Library ready: %s\n", libPath)
+
+	// 保存到配置文件，之后无需设置 YZMA_LIB
+	if !setupNoSave && libPath != cfg.LibPath {
+		cfgFile := configPath
+		if cfgFile == "" {
+			cfgFile = mmq.DefaultConfigPath()
+		}
+		if err := mmq.SetFileValue(cfgFile, "models.lib_path", libPath); err != nil {
+			return configError(err)
+		}
+		fmt.Printf("  Saved models.lib_path to %s\n", cfgFile)
+	}
 	fmt.Println()
 
+	if setupSkipModels {
+		fmt.Println("=== Setup complete! (models skipped) ===")
+		return nil
+	}
+
 	// Step 2: 下载模型
 	fmt.Println("=== Step 2: Download models ===")
 	opts := llm.DefaultDownloadOptions()
@@ -147,9 +243,11 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	// Step 3: 输出配置指引
 	fmt.Println("=== Setup complete! ===")
 	fmt.Println()
-	fmt.Println("Add to your shell profile:")
-	fmt.Printf("  export YZMA_LIB=%s\n", libPath)
-	fmt.Println()
+	if setupNoSave {
+		fmt.Println("Add to your shell profile:")
+		fmt.Printf("  export YZMA_LIB=%s\n", libPath)
+		fmt.Println()
+	}
 	fmt.Println("Then run:")
 	fmt.Println("  mmq update    # index documents")
 	fmt.Println("  mmq embed     # generate embeddings")
@@ -158,6 +256,146 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// installPrebuiltLib 用 yzma CLI 下载预编译的 llama.cpp 库
+func installPrebuiltLib(libDir string, platform llamaPlatform, version string) error {
+	// 检查 yzma 命令是否可用
+	yzmaBin, err := exec.LookPath("yzma")
+	if err != nil {
+		fmt.Println("  Installing yzma CLI tool...")
+		if err := runStreaming("go", "install", "github.com/hybridgroup/yzma/cmd/yzma@latest"); err != nil {
+			return fmt.Errorf("failed to install yzma: %w\n\nManual install:\n  go install github.com/hybridgroup/yzma/cmd/yzma@latest", err)
+		}
+		yzmaBin = "yzma"
+	}
+
+	fmt.Printf("  Downloading llama.cpp library to %s...\n", libDir)
+	installArgs := []string{"install", "--lib", libDir, "--processor", platform.Processor}
+	if version != "" {
+		return runStreaming(yzmaBin, append(installArgs, "--version", version)...)
+	}
+
+	// 先尝试不指定版本（自动获取 latest）
+	if err := runStreaming(yzmaBin, installArgs...); err != nil {
+		// fallback：指定版本号（GitHub API rate limit 时）
+		fmt.Printf("  Auto-detect failed, trying fallback version %s...\n", llamaCppFallbackVersion)
+		if err := runStreaming(yzmaBin, append(installArgs, "--version", llamaCppFallbackVersion)...); err != nil {
+			return fmt.Errorf("failed to download llama.cpp library: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildLlamaLib 从源码构建 llama.cpp 动态库并复制到 libDir
+// 源码检出到 ~/.cache/mmq/src/llama.cpp-<version>，重复运行时复用
+func buildLlamaLib(libDir string, platform llamaPlatform, version string) error {
+	for _, tool := range []string{"git", "cmake"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("%s is required to build llama.cpp from source", tool)
+		}
+	}
+
+	srcDir := filepath.Join(filepath.Dir(libDir), "src", "llama.cpp-"+version)
+	if _, err := os.Stat(filepath.Join(srcDir, "CMakeLists.txt")); err != nil {
+		fmt.Printf("  Cloning llama.cpp %s...\n", version)
+		os.RemoveAll(srcDir)
+		if err := runStreaming("git", "clone", "--depth", "1", "--branch", version, llamaCppRepo, srcDir); err != nil {
+			return fmt.Errorf("failed to clone llama.cpp: %w", err)
+		}
+	}
+
+	buildDir := filepath.Join(srcDir, "build")
+	cmakeArgs := []string{
+		"-S", srcDir, "-B", buildDir,
+		"-DCMAKE_BUILD_TYPE=Release",
+		"-DBUILD_SHARED_LIBS=ON",
+		"-DLLAMA_CURL=OFF",
+		"-DLLAMA_BUILD_TESTS=OFF",
+		"-DLLAMA_BUILD_EXAMPLES=OFF",
+		"-DLLAMA_BUILD_SERVER=OFF",
+	}
+	switch platform.Processor {
+	case "cuda":
+		cmakeArgs = append(cmakeArgs, "-DGGML_CUDA=ON")
+	case "vulkan":
+		cmakeArgs = append(cmakeArgs, "-DGGML_VULKAN=ON")
+	case "metal":
+		cmakeArgs = append(cmakeArgs, "-DGGML_METAL=ON")
+	default:
+		if platform.OS == "darwin" {
+			cmakeArgs = append(cmakeArgs, "-DGGML_METAL=OFF")
+		}
+	}
+
+	fmt.Printf("  Configuring llama.cpp (%s)...\n", platform.Processor)
+	if err := runStreaming("cmake", cmakeArgs...); err != nil {
+		return fmt.Errorf("cmake configure failed: %w", err)
+	}
+	fmt.Println("  Building llama.cpp (this can take several minutes)...")
+	if err := runStreaming("cmake", "--build", buildDir, "--config", "Release", "-j", strconv.Itoa(runtime.NumCPU())); err != nil {
+		return fmt.Errorf("cmake build failed: %w", err)
+	}
+
+	n, err := copySharedLibs(buildDir, libDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  Installed %d libraries to %s\n", n, libDir)
+	return nil
+}
+
+// copySharedLibs 把构建目录下的动态库平铺复制到 dst，返回复制的文件数
+func copySharedLibs(buildDir, dst string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(buildDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "CMakeFiles" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isSharedLib(d.Name()) {
+			return nil
+		}
+		// 符号链接（libllama.so → libllama.so.0）按目标内容复制
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, d.Name()), data, 0755); err != nil {
+			return fmt.Errorf("failed to install %s: %w", d.Name(), err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to copy libraries: %w", err)
+	}
+	return count, nil
+}
+
+// isSharedLib 是否为当前平台的动态库文件名
+func isSharedLib(name string) bool {
+	switch runtime.GOOS {
+	case "darwin":
+		return strings.HasSuffix(name, ".dylib")
+	case "windows":
+		return strings.HasSuffix(name, ".dll")
+	default:
+		return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")
+	}
+}
+
+// runStreaming 运行外部命令，输出直接显示给用户
+func runStreaming(name string, args ...string) error {
+	c := exec.Command(name, args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
 // hasLlamaLib 检查目录中是否存在 libllama 动态库
 func hasLlamaLib(dir string) bool {
 	var pattern string
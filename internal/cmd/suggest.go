@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	suggestLimit int
+	suggestKinds string
+)
+
+// suggest 命令 - 按前缀补全集合名、最近查询和文档
+var suggestCmd = &cobra.Command{
+	Use:   "suggest <prefix>",
+	Short: "Suggest collections, recent queries and documents by prefix",
+	Long: `Suggest completions for a prefix: collection names, recent search queries,
+and documents whose title or path starts with it.
+
+Examples:
+  mmq suggest api
+  mmq suggest notes/ --kind document
+  mmq suggest "" --kind query -n 20   # recent queries`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSuggest,
+}
+
+func init() {
+	suggestCmd.Flags().IntVarP(&suggestLimit, "num", "n", 10, "Maximum number of suggestions")
+	suggestCmd.Flags().StringVar(&suggestKinds, "kind", "", "Only these kinds, comma-separated (collection,query,document)")
+	rootCmd.AddCommand(suggestCmd)
+
+	// get 的参数补全使用文档建议
+	getCmd.ValidArgsFunction = completeDocuments
+}
+
+func runSuggest(cmd *cobra.Command, args []string) error {
+	opts := mmq.SuggestOptions{Limit: suggestLimit}
+	for _, k := range strings.Split(suggestKinds, ",") {
+		switch kind := mmq.SuggestionKind(strings.TrimSpace(k)); kind {
+		case "":
+		case mmq.SuggestCollection, mmq.SuggestQuery, mmq.SuggestDocument:
+			opts.Kinds = append(opts.Kinds, kind)
+		default:
+			return fmt.Errorf("unknown suggestion kind %q (want collection, query or document)", kind)
+		}
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	suggestions, err := m.Suggest(args[0], opts)
+	if err != nil {
+		return fmt.Errorf("suggest failed: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindSuggestions, suggestions)
+	}
+
+	for _, s := range suggestions {
+		if s.Title != "" && s.Title != s.Text {
+			fmt.Printf("%-10s  %s  (%s)\n", s.Kind, s.Text, s.Title)
+		} else {
+			fmt.Printf("%-10s  %s\n", s.Kind, s.Text)
+		}
+	}
+	return nil
+}
+
+// completeDocuments shell 补全：按前缀返回文档路径
+func completeDocuments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer m.Close()
+
+	suggestions, err := m.Suggest(toComplete, mmq.SuggestOptions{
+		Limit: 50,
+		Kinds: []mmq.SuggestionKind{mmq.SuggestDocument},
+	})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	completions := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		completions = append(completions, s.Text+"\t"+s.Title)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncConflict string
+	syncDryRun   bool
+)
+
+// sync 命令 - 与另一个 mmq 数据库双向同步
+var syncCmd = &cobra.Command{
+	Use:   "sync <remote>",
+	Short: "Two-way sync documents and memories with another mmq database",
+	Long: `Push and pull changes between this database and another mmq database, so a
+personal index can live on several machines (e.g. a laptop and a server).
+
+The remote is the path of the other database or a file:// URL, for example
+on a mounted or synced drive. The first sync compares everything; later syncs
+exchange only the changes recorded in the event log (see 'mmq log') since the
+last sync.
+
+When the same document or memory changed on both sides, --conflict decides:
+  lww     the later change wins (default)
+  manual  list conflicts and leave them untouched; rerun with local or remote
+  local   keep this database's version
+  remote  take the remote version
+
+Pulled documents need embeddings: run 'mmq embed' afterwards.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncConflict, "conflict", "lww", "Conflict resolution: lww, manual, local or remote")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Show what would be synced without writing")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	result, err := m.Sync(args[0], mmq.SyncOptions{Conflict: syncConflict, DryRun: syncDryRun})
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindSync, result)
+	}
+
+	verb := "Synced"
+	if result.DryRun {
+		verb = "Would sync"
+	}
+	fmt.Printf("%s with %s: %d pulled, %d pushed", verb, result.Remote, result.Pulled, result.Pushed)
+	if result.Renamed > 0 {
+		fmt.Printf(", %d collection renames", result.Renamed)
+	}
+	if result.Initial {
+		fmt.Print(" (first sync)")
+	}
+	fmt.Println()
+
+	for _, c := range result.Conflicts {
+		winner := c.Winner
+		if winner == "" {
+			winner = "unresolved"
+		}
+		fmt.Printf("  conflict %-40s local %s, remote %s → %s\n", c.Key,
+			format.DateTime(c.LocalTime.Local(), "2006-01-02 15:04"), format.DateTime(c.RemoteTime.Local(), "2006-01-02 15:04"), winner)
+	}
+	if result.Unresolved > 0 {
+		fmt.Printf("%d conflicts left untouched; rerun with --conflict local or --conflict remote\n", result.Unresolved)
+	}
+	if result.Pulled > 0 && !result.DryRun {
+		fmt.Println("Run 'mmq embed' to generate embeddings for pulled documents")
+	}
+	return nil
+}
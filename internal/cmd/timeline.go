@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/dyike/mmq/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	timelineSince       string
+	timelineUntil       string
+	timelineMemoryTypes string
+	timelineNoMemories  bool
+	timelineLimit       int
+)
+
+// timeline 命令 - 按时间顺序合并显示文档和情景记忆
+var timelineCmd = &cobra.Command{
+	Use:   "timeline",
+	Short: "Show documents and episodic memories in chronological order",
+	Long: `Show documents and episodic memories merged chronologically with snippets,
+a unified "what happened when" view across notes and conversation history.
+
+Documents are placed by their document date (parsed from the path or
+frontmatter), falling back to the file modification time. --since and --until
+accept YYYY, YYYY-MM or YYYY-MM-DD; --until includes the whole period.
+
+Examples:
+  mmq timeline --collection journals --since 2024-01
+  mmq timeline --since 2024 --until 2024-06 --no-memories
+  mmq timeline --memory-type episodic,conversation -n 20`,
+	Args: cobra.NoArgs,
+	RunE: runTimeline,
+}
+
+func init() {
+	timelineCmd.Flags().StringVar(&timelineSince, "since", "", "Start date (YYYY, YYYY-MM or YYYY-MM-DD)")
+	timelineCmd.Flags().StringVar(&timelineUntil, "until", "", "End date, inclusive (YYYY, YYYY-MM or YYYY-MM-DD)")
+	timelineCmd.Flags().StringVar(&timelineMemoryTypes, "memory-type", "", "Memory types to include, comma-separated (default: episodic)")
+	timelineCmd.Flags().BoolVar(&timelineNoMemories, "no-memories", false, "Only show documents")
+	timelineCmd.Flags().IntVarP(&timelineLimit, "num", "n", 50, "Show only the most recent N entries (0: all)")
+	rootCmd.AddCommand(timelineCmd)
+}
+
+func runTimeline(cmd *cobra.Command, args []string) error {
+	opts := mmq.TimelineOptions{
+		Collection: collectionFlag,
+		NoMemories: timelineNoMemories,
+		Limit:      timelineLimit,
+	}
+	if timelineSince != "" {
+		start, _, err := store.ParseDatePeriod(timelineSince)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		opts.Since = start
+	}
+	if timelineUntil != "" {
+		_, end, err := store.ParseDatePeriod(timelineUntil)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		opts.Until = end
+	}
+	for _, t := range strings.Split(timelineMemoryTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			opts.MemoryTypes = append(opts.MemoryTypes, mmq.MemoryType(t))
+		}
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	entries, err := m.Timeline(opts)
+	if err != nil {
+		return fmt.Errorf("failed to build timeline: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindTimeline, entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No entries found")
+		return nil
+	}
+
+	lastDay := ""
+	for _, e := range entries {
+		day := format.Date(e.Time, "2006-01-02")
+		if day != lastDay {
+			if lastDay != "" {
+				fmt.Println()
+			}
+			fmt.Println(day)
+			lastDay = day
+		}
+
+		switch e.Kind {
+		case mmq.TimelineDocument:
+			fmt.Printf("  [doc] %s/%s", e.Collection, e.Path)
+			if e.Title != "" {
+				fmt.Printf("  %s", e.Title)
+			}
+			fmt.Println()
+		default:
+			id := e.MemoryID
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			fmt.Printf("  [%s] %s %s\n", e.MemoryType, format.InZone(e.Time).Format("15:04"), id)
+		}
+		if e.Snippet != "" {
+			fmt.Printf("        %s\n", e.Snippet)
+		}
+	}
+	return nil
+}
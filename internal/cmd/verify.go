@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyQuarantine bool
+	verifyReembed    bool
+)
+
+// verifyIssueLimit 文本输出最多列出的问题数，完整列表用 --format json
+const verifyIssueLimit = 50
+
+// verify-vectors 命令 - 向量完整性检查
+var verifyVectorsCmd = &cobra.Command{
+	Use:   "verify-vectors",
+	Short: "Check that every document chunk has an intact embedding",
+	Long: `Cross-check the vector store against the active documents: every chunk
+must have an embedding from the expected model (the default model or the
+collection's own model) with the dimension of the vector index, and that
+embedding must be present in the index.
+
+Corrupt blobs are flagged as well: embeddings whose length is not a
+multiple of 4 bytes, and embeddings containing NaN or infinite values.
+
+With --quarantine, corrupt rows are moved to a quarantine table (kept for
+inspection) and the remaining vectors of the affected documents are
+dropped, so the next 'mmq embed' regenerates them. With --reembed, every
+document with a problem is embedded again right away. Without either flag
+the command only reports, and exits non-zero when problems are found.
+
+Examples:
+  mmq verify-vectors
+  mmq verify-vectors --quarantine
+  mmq verify-vectors --quarantine --reembed`,
+	Args: cobra.NoArgs,
+	RunE: runVerifyVectors,
+}
+
+func init() {
+	verifyVectorsCmd.Flags().BoolVar(&verifyQuarantine, "quarantine", false, "Move corrupt vectors to the quarantine table and drop the rest of the affected documents' vectors")
+	verifyVectorsCmd.Flags().BoolVar(&verifyReembed, "reembed", false, "Delete and regenerate the embeddings of documents with problems")
+	rootCmd.AddCommand(verifyVectorsCmd)
+}
+
+func runVerifyVectors(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	opts := mmq.VerifyVectorsOptions{Quarantine: verifyQuarantine, Reembed: verifyReembed}
+	var ui *embedProgressUI
+	if verifyReembed {
+		// Ctrl-C 在当前块完成后停止重新嵌入，已生成的块都已保存
+		ui = newEmbedProgressUI(incidentalWriter())
+		ctx, stop := ui.interruptContext()
+		defer stop()
+		opts.Embed = mmq.EmbedOptions{Context: ctx, Progress: ui.update}
+	}
+
+	report, err := m.VerifyVectors(opts)
+	if ui != nil {
+		ui.finish()
+	}
+	if report == nil {
+		return fmt.Errorf("vector verification failed: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		if jerr := format.OutputJSON(format.KindVerifyVectors, report); jerr != nil {
+			return jerr
+		}
+	} else {
+		printVectorVerification(report)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Remaining > 0 {
+		return fmt.Errorf("%d vector problem(s) remain", report.Remaining)
+	}
+	return nil
+}
+
+// printVectorVerification 输出检查结果和修复情况
+func printVectorVerification(r *mmq.VectorVerification) {
+	fmt.Printf("Checked %s vectors of %s documents\n", format.Number(int64(r.Vectors)), format.Number(int64(r.Contents)))
+	if len(r.Issues) == 0 {
+		fmt.Println("✓ All vectors are intact")
+		return
+	}
+
+	kinds := make([]string, 0, len(r.Counts))
+	for kind := range r.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	fmt.Println()
+	for _, kind := range kinds {
+		fmt.Printf("  %-12s %s\n", kind, format.Number(int64(r.Counts[kind])))
+	}
+
+	fmt.Println()
+	for i, issue := range r.Issues {
+		if i == verifyIssueLimit {
+			fmt.Printf("… and %d more (use --format json for the full list)\n", len(r.Issues)-i)
+			break
+		}
+		where := fmt.Sprintf("%s/%s %s", issue.Collection, issue.Path, issue.DocID)
+		if issue.Chunk >= 0 {
+			where += fmt.Sprintf(" chunk %d", issue.Chunk)
+		}
+		fmt.Printf("%-10s %s [%s]: %s\n", issue.Kind, where, issue.Model, issue.Detail)
+	}
+
+	if r.Quarantined > 0 {
+		fmt.Printf("\nQuarantined %d corrupt vector(s)\n", r.Quarantined)
+	}
+	if r.Embed != nil {
+		fmt.Println()
+		printEmbedSummary(r.Embed)
+	}
+	switch {
+	case r.Remaining == 0:
+		fmt.Println("\n✓ All problems repaired")
+	case r.Quarantined > 0 && r.Embed == nil:
+		fmt.Println("\nRun 'mmq embed' or 'mmq verify-vectors --reembed' to regenerate the affected documents")
+	case r.Embed == nil:
+		fmt.Println("\nRun with --quarantine or --reembed to repair")
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dyike/mmq/internal/format"
+	"github.com/dyike/mmq/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// viz 命令 - 导出嵌入的二维投影
+var vizCmd = &cobra.Command{
+	Use:   "viz",
+	Short: "Export a 2D map of chunk embeddings as interactive HTML",
+	Long: `Project chunk embeddings to 2D with PCA and write a self-contained HTML
+scatter plot colored by collection, top-level directory or document. Hover a
+point to see the chunk, click legend entries to hide groups, and use the
+outlier filter to find chunks far from the rest of their group.
+
+Examples:
+  mmq viz -o map.html
+  mmq viz -c docs --color-by dir -o docs.html
+  mmq viz -c docs --format json       # projected points as JSON`,
+	Args: cobra.NoArgs,
+	RunE: runViz,
+}
+
+var (
+	vizOutput  string
+	vizLimit   int
+	vizColorBy string
+)
+
+func init() {
+	vizCmd.Flags().StringVarP(&vizOutput, "output", "o", "mmq-map.html", "HTML output file (- for stdout)")
+	vizCmd.Flags().IntVar(&vizLimit, "limit", 5000, "Maximum chunks to plot, sampled at random (-1 for all)")
+	vizCmd.Flags().StringVar(&vizColorBy, "color-by", mmq.ColorByCollection, "Color points by collection, dir or doc")
+	rootCmd.AddCommand(vizCmd)
+}
+
+func runViz(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	embMap, err := m.EmbeddingMap(mmq.EmbeddingMapOptions{
+		Collection: collectionFlag,
+		Limit:      vizLimit,
+		ColorBy:    vizColorBy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build embedding map: %w", err)
+	}
+
+	if format.Format(outputFormat) == format.FormatJSON {
+		return format.OutputJSON(format.KindEmbeddingMap, embMap)
+	}
+	if len(embMap.Points) == 0 {
+		return fmt.Errorf("no embeddings found (run 'mmq embed' first)")
+	}
+
+	if vizOutput == "-" {
+		return format.WriteEmbeddingMapHTML(os.Stdout, embMap)
+	}
+	f, err := os.Create(vizOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", vizOutput, err)
+	}
+	if err := format.WriteEmbeddingMapHTML(f, embMap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Wrote %d chunk(s) to %s (PCA explains %.1f%% of variance)\n",
+		len(embMap.Points), vizOutput, 100*(embMap.Explained[0]+embMap.Explained[1]))
+	return nil
+}
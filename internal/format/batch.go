@@ -0,0 +1,105 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// FormatJSONL 每行一个 JSON 对象
+const FormatJSONL Format = "jsonl"
+
+// OutputJSONLine 输出单行 JSON，设置了结构版本时同样包装
+func OutputJSONLine(kind string, v interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	if SchemaVersion > 0 {
+		return encoder.Encode(mmq.Envelope{SchemaVersion: SchemaVersion, Kind: kind, Data: v})
+	}
+	return encoder.Encode(v)
+}
+
+// BatchOutput 逐条输出批量查询结果
+// jsonl、text、md、csv 边查边输出；json、xml 在 Close 时输出整个数组
+type BatchOutput struct {
+	format Format
+	full   bool
+	all    []mmq.BatchResult
+	csv    *csv.Writer
+}
+
+// NewBatchOutput 创建批量结果输出
+func NewBatchOutput(format Format, full bool) *BatchOutput {
+	b := &BatchOutput{format: format, full: full, all: []mmq.BatchResult{}}
+	if format == FormatCSV {
+		b.csv = csv.NewWriter(stdout)
+		b.csv.Write([]string{"Index", "Query", "Rank", "Score", "Collection", "Path", "Title", "Snippet", "Error"})
+	}
+	return b
+}
+
+// Write 输出一条查询的结果
+func (b *BatchOutput) Write(r mmq.BatchResult) error {
+	switch b.format {
+	case FormatJSON, FormatXML:
+		b.all = append(b.all, r)
+		return nil
+	case FormatJSONL, FormatToolJSON:
+		return OutputJSONLine(KindBatchResult, r)
+	case FormatCSV:
+		return b.writeCSV(r)
+	case FormatMD:
+		fmt.Fprintf(stdout, "# %d. %s\n\n", r.Index, r.Query)
+		if r.Error != "" {
+			fmt.Fprintf(stdout, "**Error:** %s\n\n", r.Error)
+			return nil
+		}
+		return outputSearchMarkdown(r.Results, b.full)
+	default:
+		fmt.Fprintf(stdout, "=== [%d] %s (%d result(s), %.1fms)\n", r.Index, r.Query, len(r.Results), float64(r.Took.Microseconds())/1000)
+		if r.Error != "" {
+			fmt.Fprintf(stdout, "Error: %s\n\n", r.Error)
+			return nil
+		}
+		fmt.Fprintln(stdout)
+		return outputSearchText(r.Results, b.full)
+	}
+}
+
+// Close 输出汇总的结果并刷新缓冲
+func (b *BatchOutput) Close() error {
+	switch b.format {
+	case FormatJSON:
+		return OutputJSON(KindBatchResults, b.all)
+	case FormatXML:
+		return outputXML(b.all)
+	case FormatCSV:
+		b.csv.Flush()
+		return b.csv.Error()
+	}
+	return nil
+}
+
+func (b *BatchOutput) writeCSV(r mmq.BatchResult) error {
+	index := fmt.Sprintf("%d", r.Index)
+	if r.Error != "" || len(r.Results) == 0 {
+		return b.csv.Write([]string{index, r.Query, "", "", "", "", "", "", r.Error})
+	}
+	for i, res := range r.Results {
+		if err := b.csv.Write([]string{
+			index,
+			r.Query,
+			fmt.Sprintf("%d", i+1),
+			fmt.Sprintf("%.4f", res.Score),
+			res.Collection,
+			res.Path,
+			res.Title,
+			res.Snippet,
+			"",
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,12 @@ package format
 
 import (
 	"encoding/csv"
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/dyike/mmq/pkg/mmq"
@@ -27,7 +28,7 @@ const (
 func OutputDocumentList(docs []mmq.DocumentListEntry, format Format) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(docs)
+		return OutputJSON(KindDocumentList, docs)
 	case FormatCSV:
 		return outputDocListCSV(docs)
 	case FormatMD:
@@ -43,7 +44,7 @@ func OutputDocumentList(docs []mmq.DocumentListEntry, format Format) error {
 func OutputDocumentDetail(doc *mmq.DocumentDetail, format Format, full bool, lineNumbers bool) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(doc)
+		return OutputJSON(KindDocument, doc)
 	case FormatCSV:
 		// CSV不适合单个文档，使用文本
 		return outputDocDetailText(doc, full, lineNumbers)
@@ -60,7 +61,7 @@ func OutputDocumentDetail(doc *mmq.DocumentDetail, format Format, full bool, lin
 func OutputDocumentDetails(docs []mmq.DocumentDetail, format Format, full bool, lineNumbers bool) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(docs)
+		return OutputJSON(KindDocuments, docs)
 	case FormatCSV:
 		return outputDocDetailsCSV(docs, full)
 	case FormatMD:
@@ -76,7 +77,16 @@ func OutputDocumentDetails(docs []mmq.DocumentDetail, format Format, full bool,
 func OutputSearchResults(results []mmq.SearchResult, format Format, full bool) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(results)
+		return OutputJSON(KindSearchResults, results)
+	case FormatJSONL:
+		for _, r := range results {
+			if err := OutputJSONLine(KindSearchResult, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatToolJSON:
+		return OutputToolJSON(results, DefaultToolFields)
 	case FormatCSV:
 		return outputSearchCSV(results)
 	case FormatMD:
@@ -88,11 +98,27 @@ func OutputSearchResults(results []mmq.SearchResult, format Format, full bool) e
 	}
 }
 
+// OutputResultGroups 输出分组的搜索结果
+func OutputResultGroups(groups []mmq.ResultGroup, format Format, full bool) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindResultGroups, groups)
+	case FormatCSV:
+		return outputGroupsCSV(groups)
+	case FormatMD:
+		return outputGroupsMarkdown(groups, full)
+	case FormatXML:
+		return outputXML(groups)
+	default:
+		return outputGroupsText(groups, full)
+	}
+}
+
 // OutputCollections 输出集合列表
 func OutputCollections(collections []mmq.Collection, format Format) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(collections)
+		return OutputJSON(KindCollections, collections)
 	case FormatCSV:
 		return outputCollectionsCSV(collections)
 	case FormatMD:
@@ -108,7 +134,7 @@ func OutputCollections(collections []mmq.Collection, format Format) error {
 func OutputContexts(contexts []mmq.ContextEntry, format Format) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(contexts)
+		return OutputJSON(KindContexts, contexts)
 	case FormatCSV:
 		return outputContextsCSV(contexts)
 	case FormatMD:
@@ -120,11 +146,85 @@ func OutputContexts(contexts []mmq.ContextEntry, format Format) error {
 	}
 }
 
+// OutputContextTree 输出上下文层级树
+func OutputContextTree(root *mmq.ContextNode, format Format) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindContextTree, root)
+	case FormatXML:
+		return outputXML(root)
+	default:
+		fmt.Fprintln(stdout, contextNodeLine(root))
+		printContextChildren(root.Children, "")
+		return nil
+	}
+}
+
+// printContextChildren 以树形输出子节点
+func printContextChildren(nodes []*mmq.ContextNode, prefix string) {
+	for i, n := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintln(stdout, prefix+branch+contextNodeLine(n))
+		printContextChildren(n.Children, prefix+indent)
+	}
+}
+
+// contextNodeLine 单个节点：路径、匹配文档数、上下文摘要或缺失提示
+func contextNodeLine(n *mmq.ContextNode) string {
+	line := fmt.Sprintf("%s [%s] %d docs", n.Path, n.Kind, n.Documents)
+	if n.HasContext {
+		content := strings.ReplaceAll(n.Content, "\n", " ")
+		if runes := []rune(content); len(runes) > 60 {
+			content = string(runes[:60]) + "..."
+		}
+		line += fmt.Sprintf(" ✓ %q", content)
+	} else {
+		line += " ✗ no context"
+	}
+	if n.Uncovered > 0 {
+		line += fmt.Sprintf(" (%d uncovered)", n.Uncovered)
+	}
+	return line
+}
+
+// MemoryTypeCount 单个记忆类型的数量
+type MemoryTypeCount struct {
+	Type  mmq.MemoryType `json:"type" xml:"type,attr"`
+	Count int            `json:"count" xml:"count,attr"`
+}
+
+// MemoryList 分页的记忆列表及各类型总数
+type MemoryList struct {
+	Memories []mmq.Memory      `json:"memories" xml:"memory"`
+	Total    int               `json:"total" xml:"total,attr"` // 过滤条件下的总数
+	Offset   int               `json:"offset" xml:"offset,attr"`
+	Counts   []MemoryTypeCount `json:"counts" xml:"counts>type"`
+}
+
+// OutputMemoryList 输出记忆列表
+func OutputMemoryList(list MemoryList, format Format) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindMemoryList, list)
+	case FormatCSV:
+		return outputMemoryListCSV(list)
+	case FormatMD:
+		return outputMemoryListMarkdown(list)
+	case FormatXML:
+		return outputXML(list)
+	default:
+		return outputMemoryListText(list)
+	}
+}
+
 // OutputStatus 输出状态信息
 func OutputStatus(status mmq.Status, format Format) error {
 	switch format {
 	case FormatJSON:
-		return outputJSON(status)
+		return OutputJSON(KindStatus, status)
 	case FormatMD:
 		return outputStatusMarkdown(status)
 	case FormatXML:
@@ -134,21 +234,30 @@ func OutputStatus(status mmq.Status, format Format) error {
 	}
 }
 
-// --- JSON 输出 ---
-func outputJSON(v interface{}) error {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(v)
+// OutputChunkReport 输出文档分块情况
+func OutputChunkReport(report *mmq.ChunkReport, format Format, full bool) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindChunks, report)
+	case FormatCSV:
+		return outputChunksCSV(report)
+	case FormatMD:
+		return outputChunksMarkdown(report)
+	case FormatXML:
+		return outputXML(report)
+	default:
+		return outputChunksText(report, full)
+	}
 }
 
 // --- XML 输出 ---
 func outputXML(v interface{}) error {
-	encoder := xml.NewEncoder(os.Stdout)
+	encoder := xml.NewEncoder(stdout)
 	encoder.Indent("", "  ")
 	if err := encoder.Encode(v); err != nil {
 		return err
 	}
-	fmt.Println()
+	fmt.Fprintln(stdout)
 	return nil
 }
 
@@ -156,20 +265,24 @@ func outputXML(v interface{}) error {
 
 func outputDocListText(docs []mmq.DocumentListEntry) error {
 	for _, doc := range docs {
-		fmt.Printf("%s %s/%s\n", doc.DocID, doc.Collection, doc.Path)
-		fmt.Printf("  Title: %s\n", doc.Title)
-		fmt.Printf("  Modified: %s\n", doc.ModifiedAt.Format(time.RFC3339))
-		fmt.Println()
+		fmt.Fprintf(stdout, "%s %s/%s\n", doc.DocID, doc.Collection, doc.Path)
+		fmt.Fprintf(stdout, "  Title: %s\n", doc.Title)
+		if doc.Date != "" {
+			fmt.Fprintf(stdout, "  Date: %s\n", doc.Date)
+		}
+		fmt.Fprintf(stdout, "  Modified: %s\n", ListTime(doc.ModifiedAt, time.RFC3339))
+		fmt.Fprintf(stdout, "  Length: %s\n", lengthLine(doc.WordCount, doc.CharCount, doc.ReadingSeconds))
+		fmt.Fprintln(stdout)
 	}
 	return nil
 }
 
 func outputDocListCSV(docs []mmq.DocumentListEntry) error {
-	w := csv.NewWriter(os.Stdout)
+	w := csv.NewWriter(stdout)
 	defer w.Flush()
 
 	// Header
-	w.Write([]string{"DocID", "Collection", "Path", "Title", "Modified"})
+	w.Write([]string{"DocID", "Collection", "Path", "Title", "Modified", "Words", "Chars", "ReadingSeconds"})
 
 	// Rows
 	for _, doc := range docs {
@@ -178,7 +291,10 @@ func outputDocListCSV(docs []mmq.DocumentListEntry) error {
 			doc.Collection,
 			doc.Path,
 			doc.Title,
-			doc.ModifiedAt.Format(time.RFC3339),
+			DateTime(doc.ModifiedAt, time.RFC3339),
+			strconv.Itoa(doc.WordCount),
+			strconv.Itoa(doc.CharCount),
+			strconv.Itoa(doc.ReadingSeconds),
 		})
 	}
 
@@ -186,16 +302,18 @@ func outputDocListCSV(docs []mmq.DocumentListEntry) error {
 }
 
 func outputDocListMarkdown(docs []mmq.DocumentListEntry) error {
-	fmt.Println("| DocID | Collection | Path | Title | Modified |")
-	fmt.Println("|-------|------------|------|-------|----------|")
+	fmt.Fprintln(stdout, "| DocID | Collection | Path | Title | Modified | Words | Reading |")
+	fmt.Fprintln(stdout, "|-------|------------|------|-------|----------|-------|---------|")
 
 	for _, doc := range docs {
-		fmt.Printf("| %s | %s | %s | %s | %s |\n",
+		fmt.Fprintf(stdout, "| %s | %s | %s | %s | %s | %d | %s |\n",
 			doc.DocID,
 			doc.Collection,
 			doc.Path,
 			doc.Title,
-			doc.ModifiedAt.Format("2006-01-02"),
+			Date(doc.ModifiedAt, "2006-01-02"),
+			doc.WordCount,
+			readingTime(doc.ReadingSeconds),
 		)
 	}
 
@@ -205,12 +323,16 @@ func outputDocListMarkdown(docs []mmq.DocumentListEntry) error {
 // --- 文档详情输出 ---
 
 func outputDocDetailText(doc *mmq.DocumentDetail, full bool, lineNumbers bool) error {
-	fmt.Printf("DocID: %s\n", doc.DocID)
-	fmt.Printf("Collection: %s\n", doc.Collection)
-	fmt.Printf("Path: %s\n", doc.Path)
-	fmt.Printf("Title: %s\n", doc.Title)
-	fmt.Printf("Modified: %s\n", doc.ModifiedAt.Format(time.RFC3339))
-	fmt.Println()
+	fmt.Fprintf(stdout, "DocID: %s\n", doc.DocID)
+	fmt.Fprintf(stdout, "Collection: %s\n", doc.Collection)
+	fmt.Fprintf(stdout, "Path: %s\n", doc.Path)
+	fmt.Fprintf(stdout, "Title: %s\n", doc.Title)
+	if doc.Date != "" {
+		fmt.Fprintf(stdout, "Date: %s\n", doc.Date)
+	}
+	fmt.Fprintf(stdout, "Modified: %s\n", DateTime(doc.ModifiedAt, time.RFC3339))
+	fmt.Fprintf(stdout, "Length: %s\n", lengthLine(doc.WordCount, doc.CharCount, doc.ReadingSeconds))
+	fmt.Fprintln(stdout)
 
 	content := doc.Content
 	if !full && len(content) > 500 {
@@ -220,35 +342,36 @@ func outputDocDetailText(doc *mmq.DocumentDetail, full bool, lineNumbers bool) e
 	if lineNumbers {
 		lines := strings.Split(content, "\n")
 		for i, line := range lines {
-			fmt.Printf("%4d | %s\n", i+1, line)
+			fmt.Fprintf(stdout, "%4d | %s\n", i+1, line)
 		}
 	} else {
-		fmt.Println(content)
+		fmt.Fprintln(stdout, content)
 	}
 
 	return nil
 }
 
 func outputDocDetailMarkdown(doc *mmq.DocumentDetail, full bool, lineNumbers bool) error {
-	fmt.Printf("# %s\n\n", doc.Title)
-	fmt.Printf("**DocID:** %s  \n", doc.DocID)
-	fmt.Printf("**Path:** %s/%s  \n", doc.Collection, doc.Path)
-	fmt.Printf("**Modified:** %s\n\n", doc.ModifiedAt.Format("2006-01-02 15:04:05"))
-	fmt.Printf("---\n")
+	fmt.Fprintf(stdout, "# %s\n\n", doc.Title)
+	fmt.Fprintf(stdout, "**DocID:** %s  \n", doc.DocID)
+	fmt.Fprintf(stdout, "**Path:** %s/%s  \n", doc.Collection, doc.Path)
+	fmt.Fprintf(stdout, "**Modified:** %s  \n", DateTime(doc.ModifiedAt, "2006-01-02 15:04:05"))
+	fmt.Fprintf(stdout, "**Length:** %s\n\n", lengthLine(doc.WordCount, doc.CharCount, doc.ReadingSeconds))
+	fmt.Fprintf(stdout, "---\n")
 
 	content := doc.Content
 	if !full && len(content) > 500 {
 		content = content[:500] + "\n..."
 	}
 
-	fmt.Println(content)
+	fmt.Fprintln(stdout, content)
 	return nil
 }
 
 func outputDocDetailsText(docs []mmq.DocumentDetail, full bool, lineNumbers bool) error {
 	for i, doc := range docs {
 		if i > 0 {
-			fmt.Println("\n" + strings.Repeat("=", 80) + "\n")
+			fmt.Fprintln(stdout, "\n"+strings.Repeat("=", 80)+"\n")
 		}
 		if err := outputDocDetailText(&doc, full, lineNumbers); err != nil {
 			return err
@@ -260,7 +383,7 @@ func outputDocDetailsText(docs []mmq.DocumentDetail, full bool, lineNumbers bool
 func outputDocDetailsMarkdown(docs []mmq.DocumentDetail, full bool, lineNumbers bool) error {
 	for i, doc := range docs {
 		if i > 0 {
-			fmt.Printf("\n---\n")
+			fmt.Fprintf(stdout, "\n---\n")
 		}
 		if err := outputDocDetailMarkdown(&doc, full, lineNumbers); err != nil {
 			return err
@@ -270,7 +393,7 @@ func outputDocDetailsMarkdown(docs []mmq.DocumentDetail, full bool, lineNumbers
 }
 
 func outputDocDetailsCSV(docs []mmq.DocumentDetail, full bool) error {
-	w := csv.NewWriter(os.Stdout)
+	w := csv.NewWriter(stdout)
 	defer w.Flush()
 
 	// Header
@@ -299,38 +422,41 @@ func outputDocDetailsCSV(docs []mmq.DocumentDetail, full bool) error {
 
 func outputSearchText(results []mmq.SearchResult, full bool) error {
 	for i, r := range results {
-		fmt.Printf("[%d] Score: %.4f | %s/%s\n", i+1, r.Score, r.Collection, r.Path)
-		fmt.Printf("    Title: %s\n", r.Title)
+		fmt.Fprintf(stdout, "[%d] Score: %s | %s/%s\n", i+1, Decimal(r.Score, 4), r.Collection, r.Path)
+		fmt.Fprintf(stdout, "    Title: %s\n", r.Title)
+		if r.Date != "" {
+			fmt.Fprintf(stdout, "    Date: %s\n", r.Date)
+		}
 
 		if full {
-			fmt.Printf("    Content:\n")
+			fmt.Fprintf(stdout, "    Content:\n")
 			lines := strings.Split(r.Content, "\n")
 			for _, line := range lines {
-				fmt.Printf("    %s\n", line)
+				fmt.Fprintf(stdout, "    %s\n", line)
 			}
 		} else if r.Snippet != "" {
-			fmt.Printf("    Snippet: %s\n", r.Snippet)
+			fmt.Fprintf(stdout, "    Snippet: %s\n", r.Snippet)
 		}
 
-		fmt.Println()
+		fmt.Fprintln(stdout)
 	}
 	return nil
 }
 
 func outputSearchMarkdown(results []mmq.SearchResult, full bool) error {
-	fmt.Println("# Search Results")
+	fmt.Fprintln(stdout, "# Search Results")
 
 	for i, r := range results {
-		fmt.Printf("## %d. %s (%.4f)\n\n", i+1, r.Title, r.Score)
-		fmt.Printf("**Path:** %s/%s  \n", r.Collection, r.Path)
-		fmt.Printf("**Source:** %s\n\n", r.Source)
+		fmt.Fprintf(stdout, "## %d. %s (%s)\n\n", i+1, r.Title, Decimal(r.Score, 4))
+		fmt.Fprintf(stdout, "**Path:** %s/%s  \n", r.Collection, r.Path)
+		fmt.Fprintf(stdout, "**Source:** %s\n\n", r.Source)
 
 		if full {
-			fmt.Println("```")
-			fmt.Println(r.Content)
-			fmt.Println("```")
+			fmt.Fprintln(stdout, "```")
+			fmt.Fprintln(stdout, r.Content)
+			fmt.Fprintln(stdout, "```")
 		} else if r.Snippet != "" {
-			fmt.Printf("> %s\n\n", r.Snippet)
+			fmt.Fprintf(stdout, "> %s\n\n", r.Snippet)
 		}
 	}
 
@@ -338,7 +464,7 @@ func outputSearchMarkdown(results []mmq.SearchResult, full bool) error {
 }
 
 func outputSearchCSV(results []mmq.SearchResult) error {
-	w := csv.NewWriter(os.Stdout)
+	w := csv.NewWriter(stdout)
 	defer w.Flush()
 
 	w.Write([]string{"Rank", "Score", "Collection", "Path", "Title", "Source", "Snippet"})
@@ -358,22 +484,141 @@ func outputSearchCSV(results []mmq.SearchResult) error {
 	return nil
 }
 
+// --- 分组结果输出 ---
+
+func outputGroupsText(groups []mmq.ResultGroup, full bool) error {
+	for i, g := range groups {
+		fmt.Fprintf(stdout, "[%d] Score: %s | %s (%d hit(s))\n", i+1, Decimal(g.Score, 4), g.Key, len(g.Hits))
+		if g.Title != "" {
+			fmt.Fprintf(stdout, "    Title: %s\n", g.Title)
+		}
+
+		for _, r := range g.Hits {
+			if g.Path == "" {
+				fmt.Fprintf(stdout, "    - %s  %s/%s\n", Decimal(r.Score, 4), r.Collection, r.Path)
+			} else {
+				fmt.Fprintf(stdout, "    - %s%s\n", Decimal(r.Score, 4), chunkLabel(r))
+			}
+			if full {
+				for _, line := range strings.Split(r.Content, "\n") {
+					fmt.Fprintf(stdout, "        %s\n", line)
+				}
+			} else if r.Snippet != "" {
+				fmt.Fprintf(stdout, "      %s\n", r.Snippet)
+			}
+		}
+
+		fmt.Fprintln(stdout)
+	}
+	return nil
+}
+
+func outputGroupsMarkdown(groups []mmq.ResultGroup, full bool) error {
+	fmt.Fprintln(stdout, "# Search Results")
+
+	for i, g := range groups {
+		title := g.Title
+		if title == "" {
+			title = g.Key
+		}
+		fmt.Fprintf(stdout, "## %d. %s (%s)\n\n", i+1, title, Decimal(g.Score, 4))
+		if g.Path != "" {
+			fmt.Fprintf(stdout, "**Path:** %s\n\n", g.Key)
+		}
+
+		for _, r := range g.Hits {
+			if g.Path == "" {
+				fmt.Fprintf(stdout, "### %s/%s (%s)\n\n", r.Collection, r.Path, Decimal(r.Score, 4))
+			} else {
+				fmt.Fprintf(stdout, "### Hit%s (%s)\n\n", chunkLabel(r), Decimal(r.Score, 4))
+			}
+			if full {
+				fmt.Fprintln(stdout, "```")
+				fmt.Fprintln(stdout, r.Content)
+				fmt.Fprintln(stdout, "```")
+			} else if r.Snippet != "" {
+				fmt.Fprintf(stdout, "> %s\n\n", r.Snippet)
+			}
+		}
+	}
+
+	return nil
+}
+
+func outputGroupsCSV(groups []mmq.ResultGroup) error {
+	w := csv.NewWriter(stdout)
+	defer w.Flush()
+
+	w.Write([]string{"Group", "GroupScore", "Score", "Collection", "Path", "Chunk", "Title", "Snippet"})
+
+	for _, g := range groups {
+		for _, r := range g.Hits {
+			chunk := ""
+			if c, ok := r.Metadata["chunk"]; ok {
+				chunk = fmt.Sprint(c)
+			}
+			w.Write([]string{
+				g.Key,
+				fmt.Sprintf("%.4f", g.Score),
+				fmt.Sprintf("%.4f", r.Score),
+				r.Collection,
+				r.Path,
+				chunk,
+				r.Title,
+				r.Snippet,
+			})
+		}
+	}
+
+	return nil
+}
+
+// chunkLabel 分块命中的位置说明，如 " chunk 2"
+func chunkLabel(r mmq.SearchResult) string {
+	if c, ok := r.Metadata["chunk"]; ok {
+		return fmt.Sprintf(" chunk %v", c)
+	}
+	return ""
+}
+
 // --- 集合输出 ---
 
 func outputCollectionsText(collections []mmq.Collection) error {
 	for _, c := range collections {
-		fmt.Printf("Collection: %s\n", c.Name)
-		fmt.Printf("  Path: %s\n", c.Path)
-		fmt.Printf("  Mask: %s\n", c.Mask)
-		fmt.Printf("  Documents: %d\n", c.DocCount)
-		fmt.Printf("  Updated: %s\n", c.UpdatedAt.Format(time.RFC3339))
-		fmt.Println()
+		fmt.Fprintf(stdout, "Collection: %s\n", c.Name)
+		if c.Remote != "" {
+			fmt.Fprintf(stdout, "  Remote: %s (%s)\n", c.Remote, c.RemoteCollection)
+		} else {
+			fmt.Fprintf(stdout, "  Path: %s\n", c.Path)
+			fmt.Fprintf(stdout, "  Mask: %s\n", c.Mask)
+			fmt.Fprintf(stdout, "  Documents: %s\n", Number(int64(c.DocCount)))
+		}
+		fmt.Fprintf(stdout, "  Updated: %s\n", ListTime(c.UpdatedAt, time.RFC3339))
+		if len(c.Metadata) > 0 {
+			fmt.Fprintf(stdout, "  Metadata: %s\n", metadataLine(c.Metadata))
+		}
+		fmt.Fprintln(stdout)
 	}
 	return nil
 }
 
+// metadataLine 按键排序输出 key=value 列表
+func metadataLine(metadata map[string]interface{}) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, metadata[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
 func outputCollectionsCSV(collections []mmq.Collection) error {
-	w := csv.NewWriter(os.Stdout)
+	w := csv.NewWriter(stdout)
 	defer w.Flush()
 
 	w.Write([]string{"Name", "Path", "Mask", "DocCount", "Updated"})
@@ -384,7 +629,7 @@ func outputCollectionsCSV(collections []mmq.Collection) error {
 			c.Path,
 			c.Mask,
 			fmt.Sprintf("%d", c.DocCount),
-			c.UpdatedAt.Format(time.RFC3339),
+			DateTime(c.UpdatedAt, time.RFC3339),
 		})
 	}
 
@@ -392,36 +637,127 @@ func outputCollectionsCSV(collections []mmq.Collection) error {
 }
 
 func outputCollectionsMarkdown(collections []mmq.Collection) error {
-	fmt.Println("| Name | Path | Mask | Docs | Updated |")
-	fmt.Println("|------|------|------|------|---------|")
+	fmt.Fprintln(stdout, "| Name | Path | Mask | Docs | Updated |")
+	fmt.Fprintln(stdout, "|------|------|------|------|---------|")
 
 	for _, c := range collections {
-		fmt.Printf("| %s | %s | %s | %d | %s |\n",
+		fmt.Fprintf(stdout, "| %s | %s | %s | %s | %s |\n",
 			c.Name,
 			c.Path,
 			c.Mask,
-			c.DocCount,
-			c.UpdatedAt.Format("2006-01-02"),
+			Number(int64(c.DocCount)),
+			Date(c.UpdatedAt, "2006-01-02"),
 		)
 	}
 
 	return nil
 }
 
+// --- 分块输出 ---
+
+func outputChunksText(r *mmq.ChunkReport, full bool) error {
+	fmt.Fprintf(stdout, "DocID: %s\n", r.DocID)
+	fmt.Fprintf(stdout, "Path: %s/%s\n", r.Collection, r.Path)
+	fmt.Fprintf(stdout, "Title: %s\n", r.Title)
+	fmt.Fprintf(stdout, "Length: %s bytes | chunk size %s, overlap %s | model %s\n",
+		Number(int64(r.Length)), Number(int64(r.ChunkSize)), Number(int64(r.ChunkOverlap)), r.EmbedModel)
+	fmt.Fprintf(stdout, "Chunks: %d (%d embedded)\n", len(r.Chunks), r.Embedded)
+	if r.Stale > 0 {
+		fmt.Fprintf(stdout, "Warning: %d stored vector(s) were created with a different chunk size/overlap\n", r.Stale)
+	}
+	fmt.Fprintln(stdout)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tRANGE\tSIZE\tOVERLAP\tEMBEDDED\tTEXT")
+	for _, c := range r.Chunks {
+		overlap := "-"
+		if c.Overlap > 0 {
+			overlap = Number(int64(c.Overlap))
+		}
+		fmt.Fprintf(w, "%d\t%d-%d\t%s\t%s\t%s\t%s\n",
+			c.Seq, c.Pos, c.End, Number(int64(c.Size)), overlap, chunkVectors(c), oneLine(c.Text, 50))
+	}
+	w.Flush()
+
+	if full {
+		for _, c := range r.Chunks {
+			fmt.Fprintf(stdout, "\n--- chunk %d (%d-%d) ---\n%s\n", c.Seq, c.Pos, c.End, c.Text)
+		}
+	}
+	return nil
+}
+
+func outputChunksCSV(r *mmq.ChunkReport) error {
+	w := csv.NewWriter(stdout)
+	defer w.Flush()
+
+	w.Write([]string{"Seq", "Pos", "End", "Size", "Overlap", "Models", "Text"})
+
+	for _, c := range r.Chunks {
+		var models []string
+		for _, v := range c.Vectors {
+			models = append(models, v.Model)
+		}
+		w.Write([]string{
+			fmt.Sprintf("%d", c.Seq),
+			fmt.Sprintf("%d", c.Pos),
+			fmt.Sprintf("%d", c.End),
+			fmt.Sprintf("%d", c.Size),
+			fmt.Sprintf("%d", c.Overlap),
+			strings.Join(models, ";"),
+			c.Text,
+		})
+	}
+
+	return nil
+}
+
+func outputChunksMarkdown(r *mmq.ChunkReport) error {
+	fmt.Fprintf(stdout, "# Chunks: %s/%s\n\n", r.Collection, r.Path)
+	fmt.Fprintf(stdout, "**DocID:** %s  \n", r.DocID)
+	fmt.Fprintf(stdout, "**Chunking:** size %d, overlap %d  \n", r.ChunkSize, r.ChunkOverlap)
+	fmt.Fprintf(stdout, "**Embedded:** %d/%d\n\n", r.Embedded, len(r.Chunks))
+
+	fmt.Fprintln(stdout, "| Seq | Range | Size | Overlap | Embedded |")
+	fmt.Fprintln(stdout, "|-----|-------|------|---------|----------|")
+	for _, c := range r.Chunks {
+		fmt.Fprintf(stdout, "| %d | %d-%d | %d | %d | %s |\n", c.Seq, c.Pos, c.End, c.Size, c.Overlap, chunkVectors(c))
+	}
+
+	return nil
+}
+
+// chunkVectors 分块已有向量的说明，如 embeddinggemma (768d)
+func chunkVectors(c mmq.ChunkInfo) string {
+	if len(c.Vectors) == 0 {
+		return "no"
+	}
+	var parts []string
+	for _, v := range c.Vectors {
+		if v.ChunkSize > 0 {
+			// 记录了生成时的分块参数
+			parts = append(parts, fmt.Sprintf("%s (%dd, %d/%d)", v.Model, v.Dimensions, v.ChunkSize, v.ChunkOverlap))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (%dd)", v.Model, v.Dimensions))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // --- 上下文输出 ---
 
 func outputContextsText(contexts []mmq.ContextEntry) error {
 	for _, ctx := range contexts {
-		fmt.Printf("Path: %s\n", ctx.Path)
-		fmt.Printf("  Content: %s\n", ctx.Content)
-		fmt.Printf("  Updated: %s\n", ctx.UpdatedAt.Format(time.RFC3339))
-		fmt.Println()
+		fmt.Fprintf(stdout, "Path: %s\n", ctx.Path)
+		fmt.Fprintf(stdout, "  Content: %s\n", ctx.Content)
+		fmt.Fprintf(stdout, "  Updated: %s\n", ListTime(ctx.UpdatedAt, time.RFC3339))
+		fmt.Fprintln(stdout)
 	}
 	return nil
 }
 
 func outputContextsCSV(contexts []mmq.ContextEntry) error {
-	w := csv.NewWriter(os.Stdout)
+	w := csv.NewWriter(stdout)
 	defer w.Flush()
 
 	w.Write([]string{"Path", "Content", "Updated"})
@@ -430,7 +766,7 @@ func outputContextsCSV(contexts []mmq.ContextEntry) error {
 		w.Write([]string{
 			ctx.Path,
 			ctx.Content,
-			ctx.UpdatedAt.Format(time.RFC3339),
+			DateTime(ctx.UpdatedAt, time.RFC3339),
 		})
 	}
 
@@ -438,33 +774,173 @@ func outputContextsCSV(contexts []mmq.ContextEntry) error {
 }
 
 func outputContextsMarkdown(contexts []mmq.ContextEntry) error {
-	fmt.Println("| Path | Content | Updated |")
-	fmt.Println("|------|---------|---------|")
+	fmt.Fprintln(stdout, "| Path | Content | Updated |")
+	fmt.Fprintln(stdout, "|------|---------|---------|")
 
 	for _, ctx := range contexts {
-		fmt.Printf("| %s | %s | %s |\n",
+		fmt.Fprintf(stdout, "| %s | %s | %s |\n",
 			ctx.Path,
 			ctx.Content,
-			ctx.UpdatedAt.Format("2006-01-02"),
+			Date(ctx.UpdatedAt, "2006-01-02"),
 		)
 	}
 
 	return nil
 }
 
+// --- 记忆列表输出 ---
+
+func outputMemoryListText(list MemoryList) error {
+	if len(list.Memories) > 0 {
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTYPE\tIMP\tHITS\tAGE\tCONTENT")
+		for _, mem := range list.Memories {
+			fmt.Fprintf(w, "%s\t%s\t%.1f\t%d\t%s\t%s\n",
+				shortID(mem.ID),
+				mem.Type,
+				mem.Importance,
+				mem.AccessCount,
+				formatShortAge(now().Sub(mem.Timestamp)),
+				oneLine(mem.Content, 60),
+			)
+		}
+		w.Flush()
+		fmt.Fprintln(stdout)
+	}
+
+	from, to := list.Offset+1, list.Offset+len(list.Memories)
+	if len(list.Memories) == 0 {
+		from = list.Offset
+	}
+	fmt.Fprintf(stdout, "Showing %d-%d of %s", from, to, Number(int64(list.Total)))
+
+	if len(list.Counts) > 0 {
+		parts := make([]string, len(list.Counts))
+		for i, c := range list.Counts {
+			parts[i] = fmt.Sprintf("%s: %d", c.Type, c.Count)
+		}
+		fmt.Fprintf(stdout, " (%s)", strings.Join(parts, ", "))
+	}
+	fmt.Fprintln(stdout)
+
+	return nil
+}
+
+func outputMemoryListCSV(list MemoryList) error {
+	w := csv.NewWriter(stdout)
+	defer w.Flush()
+
+	w.Write([]string{"ID", "Type", "Importance", "AccessCount", "Timestamp", "Content"})
+
+	for _, mem := range list.Memories {
+		w.Write([]string{
+			mem.ID,
+			string(mem.Type),
+			fmt.Sprintf("%.2f", mem.Importance),
+			fmt.Sprintf("%d", mem.AccessCount),
+			DateTime(mem.Timestamp, time.RFC3339),
+			mem.Content,
+		})
+	}
+
+	return nil
+}
+
+func outputMemoryListMarkdown(list MemoryList) error {
+	fmt.Fprintln(stdout, "| ID | Type | Importance | Hits | Date | Content |")
+	fmt.Fprintln(stdout, "|----|------|------------|------|------|---------|")
+
+	for _, mem := range list.Memories {
+		fmt.Fprintf(stdout, "| %s | %s | %.1f | %d | %s | %s |\n",
+			shortID(mem.ID),
+			mem.Type,
+			mem.Importance,
+			mem.AccessCount,
+			Date(mem.Timestamp, "2006-01-02"),
+			strings.ReplaceAll(oneLine(mem.Content, 80), "|", "\\|"),
+		)
+	}
+
+	fmt.Fprintf(stdout, "\n**Total:** %d\n", list.Total)
+	return nil
+}
+
+// shortID 截取ID前8位用于展示
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// oneLine 将文本压缩为单行并按 rune 截断
+func oneLine(s string, maxLen int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen]) + "..."
+}
+
+// formatShortAge 紧凑的时间间隔表示（如 5m、3h、2d）
+func formatShortAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// lengthLine 文档长度描述（如 1234 words, 5678 chars, ~6 min read）
+func lengthLine(words, chars, readingSeconds int) string {
+	return fmt.Sprintf("%s words, %s chars, %s read", Number(int64(words)), Number(int64(chars)), readingTime(readingSeconds))
+}
+
+// readingTime 预计阅读时间（如 ~6 min，不足 1 分钟显示 <1 min）
+func readingTime(seconds int) string {
+	if seconds < 60 {
+		return "<1 min"
+	}
+	minutes := (seconds + 30) / 60
+	if minutes < 60 {
+		return fmt.Sprintf("~%d min", minutes)
+	}
+	return fmt.Sprintf("~%dh %dmin", minutes/60, minutes%60)
+}
+
 // --- 状态输出 ---
 
 func outputStatusText(status mmq.Status) error {
-	fmt.Printf("Database: %s\n", status.DBPath)
-	fmt.Printf("Cache Dir: %s\n", status.CacheDir)
-	fmt.Printf("Total Documents: %d\n", status.TotalDocuments)
-	fmt.Printf("Needs Embedding: %d\n", status.NeedsEmbedding)
-	fmt.Printf("Collections: %d\n", len(status.Collections))
+	fmt.Fprintf(stdout, "Database: %s\n", status.DBPath)
+	fmt.Fprintf(stdout, "Cache Dir: %s\n", status.CacheDir)
+	fmt.Fprintf(stdout, "Total Documents: %s\n", Number(int64(status.TotalDocuments)))
+	fmt.Fprintf(stdout, "Needs Embedding: %s\n", Number(int64(status.NeedsEmbedding)))
+	fmt.Fprintf(stdout, "Corpus Version: %d\n", status.CorpusVersion)
+	fmt.Fprintf(stdout, "Collections: %d\n", len(status.Collections))
 
 	if len(status.Collections) > 0 {
-		fmt.Println("\nCollections:")
+		fmt.Fprintln(stdout, "\nCollections:")
 		for _, name := range status.Collections {
-			fmt.Printf("  - %s\n", name)
+			fmt.Fprintf(stdout, "  - %s\n", collectionStatusLine(name, status.Freshness))
+		}
+	}
+	if hint := staleHint(status.Freshness); hint != "" {
+		fmt.Fprintf(stdout, "\n%s\n", hint)
+	}
+
+	fmt.Fprintln(stdout, "\nQuota:")
+	for _, line := range quotaLines(status.Quota) {
+		fmt.Fprintf(stdout, "  %s\n", line)
+	}
+
+	if status.Vectors != nil {
+		fmt.Fprintln(stdout, "\nVectors:")
+		for _, line := range vectorStatsLines(status.Vectors) {
+			fmt.Fprintf(stdout, "  %s\n", line)
 		}
 	}
 
@@ -472,19 +948,224 @@ func outputStatusText(status mmq.Status) error {
 }
 
 func outputStatusMarkdown(status mmq.Status) error {
-	fmt.Printf("# MMQ Status\n")
-	fmt.Printf("**Database:** %s  \n", status.DBPath)
-	fmt.Printf("**Cache:** %s  \n", status.CacheDir)
-	fmt.Printf("**Documents:** %d  \n", status.TotalDocuments)
-	fmt.Printf("**Needs Embedding:** %d  \n", status.NeedsEmbedding)
-	fmt.Printf("**Collections:** %d\n\n", len(status.Collections))
+	fmt.Fprintf(stdout, "# MMQ Status\n")
+	fmt.Fprintf(stdout, "**Database:** %s  \n", status.DBPath)
+	fmt.Fprintf(stdout, "**Cache:** %s  \n", status.CacheDir)
+	fmt.Fprintf(stdout, "**Documents:** %s  \n", Number(int64(status.TotalDocuments)))
+	fmt.Fprintf(stdout, "**Needs Embedding:** %s  \n", Number(int64(status.NeedsEmbedding)))
+	fmt.Fprintf(stdout, "**Corpus Version:** %d  \n", status.CorpusVersion)
+	fmt.Fprintf(stdout, "**Collections:** %d\n\n", len(status.Collections))
 
 	if len(status.Collections) > 0 {
-		fmt.Printf("## Collections\n")
+		fmt.Fprintf(stdout, "## Collections\n")
 		for _, name := range status.Collections {
-			fmt.Printf("- %s\n", name)
+			fmt.Fprintf(stdout, "- %s\n", collectionStatusLine(name, status.Freshness))
 		}
+		fmt.Fprintln(stdout)
 	}
+	if hint := staleHint(status.Freshness); hint != "" {
+		fmt.Fprintf(stdout, "> %s\n\n", hint)
+	}
+
+	fmt.Fprintf(stdout, "## Quota\n")
+	for _, line := range quotaLines(status.Quota) {
+		fmt.Fprintf(stdout, "- %s\n", line)
+	}
+
+	if status.Vectors != nil {
+		fmt.Fprintf(stdout, "\n## Vectors\n")
+		for _, line := range vectorStatsLines(status.Vectors) {
+			fmt.Fprintf(stdout, "- %s\n", strings.TrimSpace(line))
+		}
+	}
+
+	return nil
+}
+
+// collectionStatusLine 集合名及其索引时间和磁盘变化，如 notes: indexed 12d ago, 34 files changed on disk
+func collectionStatusLine(name string, freshness []mmq.CollectionFreshness) string {
+	for _, f := range freshness {
+		if f.Collection != name {
+			continue
+		}
+		indexed := "never indexed"
+		if f.IndexedAt != nil {
+			indexed = "indexed " + relativeTime(now().Sub(*f.IndexedAt), *f.IndexedAt)
+		}
+		switch {
+		case f.Error != "":
+			return fmt.Sprintf("%s: %s, scan failed: %s", name, indexed, f.Error)
+		case !f.Scanned:
+			return fmt.Sprintf("%s: %s", name, indexed)
+		case f.Changed() == 1:
+			return fmt.Sprintf("%s: %s, 1 file changed on disk", name, indexed)
+		case f.Changed() > 1:
+			return fmt.Sprintf("%s: %s, %s files changed on disk", name, indexed, Number(int64(f.Changed())))
+		default:
+			return fmt.Sprintf("%s: %s, up to date", name, indexed)
+		}
+	}
+	return name
+}
 
+// staleHint 有集合在索引后发生变化时提示重新索引
+func staleHint(freshness []mmq.CollectionFreshness) string {
+	for _, f := range freshness {
+		if f.Changed() > 0 {
+			return "Some collections changed on disk since they were indexed. Run 'mmq update' before relying on their results."
+		}
+	}
+	return ""
+}
+
+// vectorStatsLines 格式化向量索引统计，缩进行为按集合细分
+func vectorStatsLines(v *mmq.VectorStats) []string {
+	dims := make([]string, len(v.Dimensions))
+	for i, d := range v.Dimensions {
+		dims[i] = fmt.Sprintf("%d", d)
+	}
+	if len(dims) == 0 {
+		dims = []string{"-"}
+	}
+
+	lines := []string{
+		fmt.Sprintf("Vectors: %s (dimensions: %s)", Number(int64(v.Vectors)), strings.Join(dims, ", ")),
+		fmt.Sprintf("On disk: %s", mmq.FormatSize(v.BytesOnDisk)),
+		fmt.Sprintf("Brute-force search RAM: ~%s", mmq.FormatSize(v.EstimatedRAM)),
+	}
+	for _, c := range v.Collections {
+		lines = append(lines, fmt.Sprintf("  %s: %s vectors / %s docs, %s (%s)",
+			c.Collection, Number(int64(c.Vectors)), Number(int64(c.Documents)), mmq.FormatSize(c.Bytes), c.Model))
+	}
+	if v.ANNRecommended {
+		lines = append(lines, fmt.Sprintf("Note: over %d vectors, brute-force search gets slow; consider an ANN index", mmq.ANNRecommendThreshold))
+	}
+	return lines
+}
+
+// quotaLines 格式化配额用量，达到限制 90% 时附加警告
+func quotaLines(q mmq.QuotaUsage) []string {
+	warn := func(used, limit float64) string {
+		if limit > 0 && used >= limit*0.9 {
+			return " (warning: near limit)"
+		}
+		return ""
+	}
+
+	dbLine := fmt.Sprintf("Database size: %s", mmq.FormatSize(q.DBSize))
+	if q.MaxDBSize > 0 {
+		dbLine += fmt.Sprintf(" / %s%s", mmq.FormatSize(q.MaxDBSize), warn(float64(q.DBSize), float64(q.MaxDBSize)))
+	}
+
+	diskLine := "Disk free: unknown"
+	if q.DiskFree >= 0 {
+		diskLine = fmt.Sprintf("Disk free: %s", mmq.FormatSize(q.DiskFree))
+		if q.MinFreeDisk > 0 {
+			diskLine += fmt.Sprintf(" (minimum %s)", mmq.FormatSize(q.MinFreeDisk))
+			if q.DiskFree < q.MinFreeDisk*10/9 {
+				diskLine += " (warning: low disk space)"
+			}
+		}
+	}
+
+	docsLine := "Max docs per collection: unlimited"
+	if q.MaxDocsPerCollection > 0 {
+		docsLine = fmt.Sprintf("Max docs per collection: %d", q.MaxDocsPerCollection)
+		if q.LargestCollection != "" {
+			docsLine += fmt.Sprintf(" (largest: %s, %d docs%s)", q.LargestCollection, q.LargestCollectionDocs,
+				warn(float64(q.LargestCollectionDocs), float64(q.MaxDocsPerCollection)))
+		}
+	}
+
+	return []string{dbLine, diskLine, docsLine}
+}
+
+// --- 聚类输出 ---
+
+// OutputClusters 输出聚类列表，每个聚类最多列出 docs 篇文档（0 表示不列出）
+func OutputClusters(clusters []mmq.Cluster, format Format, docs int) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindClusters, clusters)
+	case FormatCSV:
+		return outputClustersCSV(clusters)
+	case FormatMD:
+		return outputClustersMarkdown(clusters, docs)
+	case FormatXML:
+		return outputXML(clusters)
+	default:
+		return outputClustersText(clusters, docs)
+	}
+}
+
+// OutputCluster 输出单个聚类及其全部文档
+func OutputCluster(cluster *mmq.Cluster, format Format) error {
+	switch format {
+	case FormatJSON:
+		return OutputJSON(KindCluster, cluster)
+	case FormatXML:
+		return outputXML(cluster)
+	default:
+		return OutputClusters([]mmq.Cluster{*cluster}, format, len(cluster.Documents))
+	}
+}
+
+func outputClustersText(clusters []mmq.Cluster, docs int) error {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTION\tID\tSIZE\tLABEL")
+	for _, c := range clusters {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", c.Collection, c.ID, Number(int64(c.Size)), c.Label)
+		for i, d := range c.Documents {
+			if i == docs {
+				fmt.Fprintf(w, "\t\t\t  … %d more\n", len(c.Documents)-docs)
+				break
+			}
+			fmt.Fprintf(w, "\t\t\t  %s  %s (%s)\n", d.Path, oneLine(d.Title, 40), Decimal(d.Distance, 3))
+		}
+	}
+	return w.Flush()
+}
+
+func outputClustersCSV(clusters []mmq.Cluster) error {
+	w := csv.NewWriter(stdout)
+	defer w.Flush()
+
+	w.Write([]string{"Collection", "Cluster", "Label", "Size", "Path", "Title", "Distance"})
+
+	for _, c := range clusters {
+		if len(c.Documents) == 0 {
+			w.Write([]string{c.Collection, fmt.Sprintf("%d", c.ID), c.Label, fmt.Sprintf("%d", c.Size), "", "", ""})
+			continue
+		}
+		for _, d := range c.Documents {
+			w.Write([]string{
+				c.Collection,
+				fmt.Sprintf("%d", c.ID),
+				c.Label,
+				fmt.Sprintf("%d", c.Size),
+				d.Path,
+				d.Title,
+				fmt.Sprintf("%.4f", d.Distance),
+			})
+		}
+	}
+
+	return nil
+}
+
+func outputClustersMarkdown(clusters []mmq.Cluster, docs int) error {
+	for _, c := range clusters {
+		fmt.Fprintf(stdout, "## %s #%d: %s (%d)\n\n", c.Collection, c.ID, c.Label, c.Size)
+		for i, d := range c.Documents {
+			if i == docs {
+				fmt.Fprintf(stdout, "- … %d more\n", len(c.Documents)-docs)
+				break
+			}
+			fmt.Fprintf(stdout, "- `%s` %s\n", d.Path, d.Title)
+		}
+		if len(c.Documents) > 0 {
+			fmt.Fprintln(stdout)
+		}
+	}
 	return nil
 }
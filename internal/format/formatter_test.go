@@ -0,0 +1,176 @@
+package format
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// go test ./internal/format -update 重新生成快照
+// 快照有不兼容的变化（列、表头、标签、行结构）时同时递增 mmq.FormatVersion
+var update = flag.Bool("update", false, "rewrite golden files in testdata/")
+
+var (
+	snapTime = time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	allFmts  = []Format{FormatText, FormatJSON, FormatCSV, FormatMD, FormatXML}
+)
+
+// snapshot 输出与 testdata/<name>.<format>.golden 对比
+func snapshot(t *testing.T, name string, f Format, fn func() error) {
+	t.Helper()
+	got, err := Render(fn)
+	if err != nil {
+		t.Fatalf("%s (%s): %v", name, f, err)
+	}
+
+	path := filepath.Join("testdata", name+"."+string(f)+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./internal/format -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s (%s) output changed; if intended, run with -update and bump mmq.FormatVersion for incompatible changes\n--- got ---\n%s\n--- want ---\n%s", name, f, got, want)
+	}
+}
+
+// pinOutput 固定时区、区域格式、当前时间和 JSON 版本，测试结束后恢复
+func pinOutput(t *testing.T) {
+	t.Helper()
+	prevLocale, prevNow, prevSchema := locale, now, SchemaVersion
+	SetLocale(Locale{Location: time.UTC})
+	now = func() time.Time { return snapTime.Add(72 * time.Hour) }
+	SchemaVersion = 0
+	t.Cleanup(func() {
+		locale, now, SchemaVersion = prevLocale, prevNow, prevSchema
+	})
+}
+
+func TestFormatSnapshots(t *testing.T) {
+	pinOutput(t)
+
+	results := []mmq.SearchResult{
+		{
+			ID: "1", DocID: "#a1b2c3", Score: 0.8765, Title: "Go Concurrency",
+			Content: "Goroutines and channels.\nSelect statements.", Snippet: "Goroutines and **channels**",
+			Source: "hybrid", Collection: "notes", Path: "go/concurrency.md", Language: "en",
+			Date: "2024-03-01", Timestamp: snapTime,
+		},
+		{
+			ID: "2", DocID: "#d4e5f6", Score: 0.4321, Title: "Python | Data",
+			Content: "Pandas, \"quoted\" text, and commas.", Source: "fts",
+			Collection: "notes", Path: "py/data.md", Timestamp: snapTime,
+		},
+	}
+	docs := []mmq.DocumentListEntry{
+		{ID: 1, DocID: "#a1b2c3", Collection: "notes", Path: "go/concurrency.md", Title: "Go Concurrency",
+			Hash: "a1b2c3d4", CreatedAt: snapTime, ModifiedAt: snapTime, WordCount: 1234, CharCount: 5678, ReadingSeconds: 370, Date: "2024-03-01"},
+		{ID: 2, DocID: "#d4e5f6", Collection: "notes", Path: "py/data.md", Title: "Python | Data",
+			Hash: "d4e5f6a7", CreatedAt: snapTime, ModifiedAt: snapTime.Add(time.Hour), WordCount: 12, CharCount: 80, ReadingSeconds: 4},
+	}
+	detail := mmq.DocumentDetail{
+		ID: 1, DocID: "#a1b2c3", Collection: "notes", Path: "go/concurrency.md", Title: "Go Concurrency",
+		Content: "# Go Concurrency\n\nGoroutines and channels.", Hash: "a1b2c3d4",
+		CreatedAt: snapTime, ModifiedAt: snapTime, WordCount: 6, CharCount: 42, ReadingSeconds: 2,
+	}
+	groups := []mmq.ResultGroup{
+		{Key: "notes/go/concurrency.md", Collection: "notes", Path: "go/concurrency.md", Title: "Go Concurrency",
+			DocID: "#a1b2c3", Score: 0.8765, Hits: results[:1]},
+		{Key: "notes/py/data.md", Collection: "notes", Path: "py/data.md", Title: "Python | Data",
+			DocID: "#d4e5f6", Score: 0.4321, Hits: results[1:]},
+	}
+	collections := []mmq.Collection{
+		{Name: "notes", Path: "/home/me/notes", Mask: "**/*.md", CreatedAt: snapTime, UpdatedAt: snapTime, DocCount: 42,
+			Metadata: map[string]interface{}{"owner": "me"}},
+		{Name: "wiki", Path: "/srv/wiki", Mask: "**/*", CreatedAt: snapTime, UpdatedAt: snapTime, DocCount: 0},
+	}
+	contexts := []mmq.ContextEntry{
+		{Path: "/", Content: "Personal knowledge base", CreatedAt: snapTime, UpdatedAt: snapTime},
+		{Path: "mmq://notes/go", Content: "Go notes, one file per topic", CreatedAt: snapTime, UpdatedAt: snapTime},
+	}
+	memories := MemoryList{
+		Memories: []mmq.Memory{
+			{ID: "0123456789abcdef", Type: mmq.MemoryTypeFact, Content: "The user prefers tabs over spaces.",
+				Timestamp: snapTime, Importance: 0.8, AccessCount: 3},
+			{ID: "fedcba9876543210", Type: mmq.MemoryTypePreference, Content: "Answer | briefly",
+				Timestamp: snapTime.Add(70 * time.Hour), Importance: 0.5},
+		},
+		Total:  2,
+		Counts: []MemoryTypeCount{{Type: mmq.MemoryTypeFact, Count: 1}, {Type: mmq.MemoryTypePreference, Count: 1}},
+	}
+	chunks := &mmq.ChunkReport{
+		DocID: "#a1b2c3", Collection: "notes", Path: "go/concurrency.md", Title: "Go Concurrency", Hash: "a1b2c3d4",
+		Length: 60, ChunkSize: 40, ChunkOverlap: 10, EmbedModel: "test-embed",
+		Chunks: []mmq.ChunkInfo{
+			{Seq: 0, Pos: 0, End: 40, Size: 40, Chars: 40, Text: "# Go Concurrency\n\nGoroutines and chan"},
+			{Seq: 1, Pos: 30, End: 60, Size: 30, Chars: 30, Overlap: 10, Text: "and channels. Select statements."},
+		},
+	}
+	clusters := []mmq.Cluster{
+		{Collection: "notes", ID: 1, Label: "go, concurrency", Size: 2, CreatedAt: snapTime, Documents: []mmq.ClusterDocument{
+			{Path: "go/concurrency.md", Title: "Go Concurrency", Distance: 0.12},
+			{Path: "go/select.md", Distance: 0.3},
+		}},
+		{Collection: "notes", ID: 2, Label: "python", Size: 1, CreatedAt: snapTime},
+	}
+
+	for _, f := range allFmts {
+		f := f
+		snapshot(t, "search_results", f, func() error { return OutputSearchResults(results, f, false) })
+		snapshot(t, "result_groups", f, func() error { return OutputResultGroups(groups, f, false) })
+		snapshot(t, "document_list", f, func() error { return OutputDocumentList(docs, f) })
+		snapshot(t, "document_detail", f, func() error { return OutputDocumentDetail(&detail, f, true, true) })
+		snapshot(t, "collections", f, func() error { return OutputCollections(collections, f) })
+		snapshot(t, "contexts", f, func() error { return OutputContexts(contexts, f) })
+		snapshot(t, "memory_list", f, func() error { return OutputMemoryList(memories, f) })
+		snapshot(t, "chunks", f, func() error { return OutputChunkReport(chunks, f, false) })
+		snapshot(t, "clusters", f, func() error { return OutputClusters(clusters, f, 5) })
+	}
+	snapshot(t, "search_results", FormatJSONL, func() error { return OutputSearchResults(results, FormatJSONL, false) })
+	snapshot(t, "search_results", FormatToolJSON, func() error { return OutputSearchResults(results, FormatToolJSON, false) })
+}
+
+// JSON 包装在 schema_version > 0 时带版本号和数据类型
+func TestFormatEnvelopeSnapshot(t *testing.T) {
+	pinOutput(t)
+	SchemaVersion = mmq.SchemaVersion
+
+	status := mmq.Status{TotalDocuments: 42, NeedsEmbedding: 3, Collections: []string{"notes", "wiki"}, DBPath: "/tmp/mmq.db", CacheDir: "/tmp/cache"}
+	snapshot(t, "status_envelope", FormatJSON, func() error { return OutputStatus(status, FormatJSON) })
+}
+
+func TestTranscriptSnapshot(t *testing.T) {
+	pinOutput(t)
+
+	transcript := &mmq.Transcript{
+		SessionID: "3f2a9c1d",
+		Start:     snapTime,
+		End:       snapTime.Add(5 * time.Minute),
+		Turns: []mmq.TranscriptTurn{
+			{Timestamp: snapTime, User: "How do goroutines communicate?", Assistant: "Through channels.", Citations: []int{1, 2}},
+			{Timestamp: snapTime.Add(5 * time.Minute), User: "Thanks, I prefer short answers.", Assistant: "Noted."},
+		},
+		Citations: []mmq.Citation{
+			{DocID: "a1b2c3", Collection: "notes", Path: "go/concurrency.md", Title: "Go Concurrency"},
+			{DocID: "d4e5f6", Missing: true},
+		},
+		Memories: []mmq.Memory{
+			{ID: "0123456789abcdef", Type: mmq.MemoryTypePreference, Content: "The user prefers short answers.", Timestamp: snapTime},
+		},
+	}
+	snapshot(t, "transcript", FormatMD, func() error { return WriteTranscriptMarkdown(stdout, transcript) })
+}
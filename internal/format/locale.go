@@ -0,0 +1,174 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale 文本、Markdown 和 CSV 输出中日期和数字的显示方式
+// 零值保持各输出原有的格式；JSON 输出不受影响
+type Locale struct {
+	Location       *time.Location // 显示时区（nil 表示不转换）
+	DateTimeLayout string         // 日期时间格式（空表示各输出的默认格式）
+	DateLayout     string         // 仅日期的格式
+	Relative       bool           // 文本列表视图显示相对时间（如 2h ago）
+	Thousands      string         // 千分位分隔符（CSV 不使用）
+	Decimal        string         // 小数点（CSV 不使用，默认 "."）
+}
+
+// 日期格式预设：名称 → 日期时间格式、日期格式
+var datePresets = map[string][2]string{
+	"rfc3339": {time.RFC3339, "2006-01-02"},
+	"iso":     {"2006-01-02 15:04", "2006-01-02"},
+	"us":      {"01/02/2006 3:04 PM", "01/02/2006"},
+	"eu":      {"02/01/2006 15:04", "02/01/2006"},
+	"de":      {"02.01.2006 15:04", "02.01.2006"},
+}
+
+// 数字区域：名称 → 千分位分隔符、小数点、默认日期格式
+var numberLocales = map[string][3]string{
+	"en":    {",", ".", "iso"},
+	"en-us": {",", ".", "us"},
+	"en-gb": {",", ".", "eu"},
+	"de":    {".", ",", "de"},
+	"fr":    {" ", ",", "eu"},
+	"es":    {".", ",", "eu"},
+	"zh":    {",", ".", "iso"},
+	"ja":    {",", ".", "iso"},
+}
+
+var locale Locale
+
+// SetLocale 设置之后输出使用的区域格式
+func SetLocale(l Locale) {
+	locale = l
+}
+
+// NewLocale 由配置创建区域格式
+// timezone 为 IANA 时区名或 local；dateFormat 为预设名（rfc3339/iso/us/eu/de）或 Go 时间格式；
+// lang 为数字区域（如 en、de、fr、zh），同时决定未指定 dateFormat 时的日期格式
+func NewLocale(timezone, dateFormat, lang string, relative bool) (Locale, error) {
+	l := Locale{Relative: relative}
+
+	switch strings.ToLower(timezone) {
+	case "":
+	case "local":
+		l.Location = time.Local
+	default:
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return l, fmt.Errorf("invalid display timezone %q: %w", timezone, err)
+		}
+		l.Location = loc
+	}
+
+	if lang != "" {
+		key := strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+		spec, ok := numberLocales[key]
+		if !ok {
+			spec, ok = numberLocales[strings.SplitN(key, "-", 2)[0]]
+		}
+		if !ok {
+			return l, fmt.Errorf("unsupported display locale %q (use en, en-US, en-GB, de, fr, es, zh or ja)", lang)
+		}
+		l.Thousands, l.Decimal = spec[0], spec[1]
+		if dateFormat == "" {
+			dateFormat = spec[2]
+		}
+	}
+
+	if dateFormat != "" {
+		if preset, ok := datePresets[strings.ToLower(dateFormat)]; ok {
+			l.DateTimeLayout, l.DateLayout = preset[0], preset[1]
+		} else if time.Unix(0, 0).UTC().Format(dateFormat) != dateFormat {
+			// Go 时间格式，日期列也使用同一格式
+			l.DateTimeLayout, l.DateLayout = dateFormat, dateFormat
+		} else {
+			return l, fmt.Errorf("invalid date format %q (use rfc3339, iso, us, eu, de or a Go layout such as 2006-01-02 15:04)", dateFormat)
+		}
+	}
+
+	return l, nil
+}
+
+// InZone 转换到显示时区（未配置时不变）
+func InZone(t time.Time) time.Time {
+	if locale.Location != nil {
+		return t.In(locale.Location)
+	}
+	return t
+}
+
+// DateTime 格式化日期时间，未配置格式时使用 layout
+func DateTime(t time.Time, layout string) string {
+	if locale.DateTimeLayout != "" {
+		layout = locale.DateTimeLayout
+	}
+	return InZone(t).Format(layout)
+}
+
+// Date 格式化日期，未配置格式时使用 layout
+func Date(t time.Time, layout string) string {
+	if locale.DateLayout != "" {
+		layout = locale.DateLayout
+	}
+	return InZone(t).Format(layout)
+}
+
+// ListTime 列表视图中的时间，开启相对时间时显示如 2h ago
+func ListTime(t time.Time, layout string) string {
+	if locale.Relative && !t.IsZero() {
+		return relativeTime(now().Sub(t), t)
+	}
+	return DateTime(t, layout)
+}
+
+// relativeTime 一个月内显示相对时间，更早的显示日期
+func relativeTime(d time.Duration, t time.Time) string {
+	switch {
+	case d < 0:
+		return DateTime(t, time.RFC3339)
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	default:
+		return Date(t, "2006-01-02")
+	}
+}
+
+// Number 按区域的千分位格式化整数
+func Number(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if locale.Thousands == "" {
+		return s
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, ch := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(locale.Thousands)
+		}
+		b.WriteRune(ch)
+	}
+	return sign + b.String()
+}
+
+// Decimal 按区域的小数点格式化浮点数
+func Decimal(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	if locale.Decimal != "" && locale.Decimal != "." {
+		s = strings.Replace(s, ".", locale.Decimal, 1)
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package format
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// stdout 各输出函数的写入位置，默认为标准输出
+var stdout io.Writer = os.Stdout
+
+// now 计算相对时间（如记忆的 AGE 列）用的当前时间，快照测试中固定
+var now = time.Now
+
+// SetOutput 设置之后输出写入的位置（nil 恢复为标准输出）
+func SetOutput(w io.Writer) {
+	if w == nil {
+		w = os.Stdout
+	}
+	stdout = w
+}
+
+// Render 执行 fn 并返回它输出的内容，而不是写到标准输出
+// 用于快照测试和嵌入其他程序；执行期间临时替换输出位置，不要并发调用
+func Render(fn func() error) ([]byte, error) {
+	prev := stdout
+	defer func() { stdout = prev }()
+
+	var buf bytes.Buffer
+	stdout = &buf
+	err := fn()
+	return buf.Bytes(), err
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// JSON 输出的数据类型
+const (
+	KindDocumentList   = "document_list"
+	KindDocument       = "document"
+	KindDocuments      = "documents"
+	KindChunks         = "chunks"
+	KindEmbeddingMap   = "embedding_map"
+	KindClusters       = "clusters"
+	KindCluster        = "cluster"
+	KindSearchResults  = "search_results"
+	KindSearchResult   = "search_result"
+	KindSearchUpdate   = "search_update"
+	KindResultGroups   = "result_groups"
+	KindBatchResult    = "batch_result"
+	KindBatchResults   = "batch_results"
+	KindCollections    = "collections"
+	KindCollectionMeta = "collection_metadata"
+	KindContexts       = "contexts"
+	KindContextTree    = "context_tree"
+	KindMemoryList     = "memory_list"
+	KindMemory         = "memory"
+	KindMemorySources  = "memory_sources"
+	KindDuplicates     = "memory_duplicates"
+	KindDecayCurves    = "decay_curves"
+	KindStatus         = "status"
+	KindStatusChanges  = "status_changes"
+	KindPIIFindings    = "pii_findings"
+	KindSuggestions    = "suggestions"
+	KindTimeline       = "timeline"
+	KindTranscript     = "transcript"
+	KindPlugins        = "plugins"
+	KindPipelines      = "pipelines"
+	KindEvents         = "events"
+	KindSync           = "sync"
+	KindModels         = "models"
+	KindFeedback       = "feedback"
+	KindRerankBlend    = "rerank_blend"
+	KindRecent         = "recent_documents"
+	KindResultSets     = "result_sets"
+	KindResultSet      = "result_set"
+	KindRebuild        = "rebuild"
+	KindDBStats        = "db_stats"
+	KindStrategyBench  = "strategy_bench"
+	KindVerifyVectors  = "vector_verification"
+	KindDeletion       = "document_deletion"
+	KindError          = "error"
+)
+
+// SchemaVersion JSON 输出使用的结构版本
+// 0 表示旧的无版本输出（直接输出数据），>0 时包装为 {schema_version, kind, data}
+var SchemaVersion = 0
+
+// SetSchemaVersion 设置 JSON 输出版本，只接受 0 或当前支持的版本
+func SetSchemaVersion(v int) error {
+	if v < 0 || v > mmq.SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d (supported: 0-%d)", v, mmq.SchemaVersion)
+	}
+	SchemaVersion = v
+	return nil
+}
+
+// OutputJSON 按当前版本设置输出 JSON
+func OutputJSON(kind string, v interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if SchemaVersion > 0 {
+		return encoder.Encode(mmq.Envelope{SchemaVersion: SchemaVersion, Kind: kind, Data: v})
+	}
+	return encoder.Encode(v)
+}
@@ -0,0 +1,119 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// FormatToolJSON 精简的单行 JSON，供注入 LLM 工具调用结果
+const FormatToolJSON Format = "compact"
+
+// DefaultToolFields 紧凑输出的默认字段
+var DefaultToolFields = []string{"docid", "title", "snippet", "score"}
+
+// toolFields 可选字段及取值方式
+var toolFields = map[string]func(r mmq.SearchResult) interface{}{
+	"docid":      func(r mmq.SearchResult) interface{} { return r.DocID },
+	"title":      func(r mmq.SearchResult) interface{} { return r.Title },
+	"snippet":    func(r mmq.SearchResult) interface{} { return compactText(r.Snippet) },
+	"score":      func(r mmq.SearchResult) interface{} { return math.Round(r.Score*1000) / 1000 },
+	"path":       func(r mmq.SearchResult) interface{} { return r.Collection + "/" + r.Path },
+	"collection": func(r mmq.SearchResult) interface{} { return r.Collection },
+	"source":     func(r mmq.SearchResult) interface{} { return r.Source },
+	"language":   func(r mmq.SearchResult) interface{} { return r.Language },
+	"content":    func(r mmq.SearchResult) interface{} { return compactText(r.Content) },
+}
+
+// ParseToolFields 解析逗号分隔的字段列表，为空时返回默认字段
+func ParseToolFields(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultToolFields, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			continue
+		}
+		if _, ok := toolFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q (available: docid, title, snippet, score, path, collection, source, language, content)", f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// OutputToolJSON 按给定字段顺序输出紧凑 JSON 数组，空值字段省略
+func OutputToolJSON(results []mmq.SearchResult, fields []string) error {
+	data, err := MarshalToolJSON(results, fields)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, string(data))
+	return err
+}
+
+// MarshalToolJSON 生成紧凑 JSON，键顺序与 fields 一致
+func MarshalToolJSON(results []mmq.SearchResult, fields []string) ([]byte, error) {
+	if len(fields) == 0 {
+		fields = DefaultToolFields
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, r := range results {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		written := 0
+		for _, f := range fields {
+			get, ok := toolFields[f]
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", f)
+			}
+			value := get(r)
+			if s, ok := value.(string); ok && (s == "" || s == "/") {
+				continue
+			}
+
+			encoded, err := marshalNoEscape(value)
+			if err != nil {
+				return nil, err
+			}
+			if written > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%q:", f)
+			buf.Write(encoded)
+			written++
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// marshalNoEscape 编码 JSON 值，不转义 HTML 字符以节省 token
+func marshalNoEscape(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// compactText 合并连续空白
+func compactText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,57 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// transcriptTimeLayout 对话记录中的时间格式
+const transcriptTimeLayout = "2006-01-02 15:04"
+
+// WriteTranscriptMarkdown 把对话会话渲染为 Markdown：各轮问答、以脚注标注的引用文档和提取的记忆
+func WriteTranscriptMarkdown(w io.Writer, t *mmq.Transcript) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Chat session %s\n\n", t.SessionID)
+	fmt.Fprintf(&b, "- Started: %s\n", InZone(t.Start).Format(transcriptTimeLayout))
+	fmt.Fprintf(&b, "- Ended: %s\n", InZone(t.End).Format(transcriptTimeLayout))
+	fmt.Fprintf(&b, "- Turns: %d\n", len(t.Turns))
+
+	for i, turn := range t.Turns {
+		fmt.Fprintf(&b, "\n## Turn %d · %s\n\n", i+1, InZone(turn.Timestamp).Format(transcriptTimeLayout))
+		fmt.Fprintf(&b, "**You:** %s\n\n", strings.TrimSpace(turn.User))
+		fmt.Fprintf(&b, "**Assistant:** %s", strings.TrimSpace(turn.Assistant))
+		for _, n := range turn.Citations {
+			fmt.Fprintf(&b, "[^%d]", n)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(t.Memories) > 0 {
+		b.WriteString("\n## Extracted memories\n\n")
+		for _, mem := range t.Memories {
+			fmt.Fprintf(&b, "- **%s** %s\n", mem.Type, strings.TrimSpace(mem.Content))
+		}
+	}
+
+	if len(t.Citations) > 0 {
+		b.WriteString("\n")
+		for i, c := range t.Citations {
+			if c.Missing {
+				fmt.Fprintf(&b, "[^%d]: #%s (removed or changed since the conversation)\n", i+1, c.DocID)
+				continue
+			}
+			title := c.Title
+			if title == "" {
+				title = c.Path
+			}
+			fmt.Fprintf(&b, "[^%d]: %s — `%s/%s` (#%s)\n", i+1, title, c.Collection, c.Path, c.DocID)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,130 @@
+package format
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// vizTemplate 独立的 HTML 散点图（不依赖外部脚本），悬停显示分块，点击图例隐藏分组，滚轮缩放、拖动平移
+var vizTemplate = template.Must(template.New("viz").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>mmq embedding map</title>
+<style>
+body { margin: 0; font: 13px system-ui, sans-serif; display: flex; height: 100vh; }
+#plot { flex: 1; position: relative; }
+canvas { width: 100%; height: 100%; display: block; cursor: grab; }
+#side { width: 280px; overflow-y: auto; border-left: 1px solid #ddd; padding: 12px; box-sizing: border-box; }
+#side h1 { font-size: 15px; margin: 0 0 4px; }
+#side .meta { color: #666; margin-bottom: 12px; }
+.item { display: flex; align-items: center; gap: 6px; padding: 2px 0; cursor: pointer; word-break: break-all; }
+.item.off { opacity: .35; }
+.swatch { width: 10px; height: 10px; border-radius: 50%; flex: none; }
+#tip { position: absolute; pointer-events: none; background: #fff; border: 1px solid #ccc; padding: 6px 8px;
+       max-width: 360px; box-shadow: 0 2px 6px rgba(0,0,0,.15); display: none; }
+#tip b { display: block; margin-bottom: 2px; }
+</style>
+</head>
+<body>
+<div id="plot"><canvas id="c"></canvas><div id="tip"></div></div>
+<div id="side">
+  <h1>Embedding map</h1>
+  <div class="meta" id="meta"></div>
+  <label class="item"><input type="checkbox" id="outliers"> only outliers</label>
+  <div id="legend"></div>
+</div>
+<script>
+const data = {{.}};
+const points = data.points;
+const groups = [...new Set(points.map(p => p.group))].sort();
+const color = {};
+groups.forEach((g, i) => color[g] = "hsl(" + Math.round(i * 360 / Math.max(groups.length, 1) * 7 % 360) + ",65%,48%)");
+const hidden = new Set();
+const outliers = points.filter(p => p.outlier).length;
+document.getElementById("meta").textContent = points.length + " chunks · " + groups.length + " groups · " +
+  outliers + " outliers · " + data.model + " · PCA " +
+  (100 * (data.explained[0] + data.explained[1])).toFixed(1) + "% variance";
+
+const legend = document.getElementById("legend");
+groups.forEach(g => {
+  const n = points.filter(p => p.group === g).length;
+  const el = document.createElement("div");
+  el.className = "item";
+  el.innerHTML = '<span class="swatch"></span><span></span>';
+  el.firstChild.style.background = color[g];
+  el.lastChild.textContent = g + " (" + n + ")";
+  el.onclick = () => { hidden.has(g) ? hidden.delete(g) : hidden.add(g); el.classList.toggle("off"); draw(); };
+  legend.appendChild(el);
+});
+document.getElementById("outliers").onchange = draw;
+
+const canvas = document.getElementById("c"), ctx = canvas.getContext("2d"), tip = document.getElementById("tip");
+const xs = points.map(p => p.x), ys = points.map(p => p.y);
+const minX = Math.min(...xs), maxX = Math.max(...xs), minY = Math.min(...ys), maxY = Math.max(...ys);
+let scale = 1, offX = 0, offY = 0;
+
+function screen(p) {
+  const w = canvas.width, h = canvas.height, pad = 30;
+  const sx = (w - 2 * pad) / ((maxX - minX) || 1), sy = (h - 2 * pad) / ((maxY - minY) || 1), s = Math.min(sx, sy) * scale;
+  return [w / 2 + (p.x - (minX + maxX) / 2) * s + offX, h / 2 - (p.y - (minY + maxY) / 2) * s + offY];
+}
+function visible(p) {
+  return !hidden.has(p.group) && (!document.getElementById("outliers").checked || p.outlier);
+}
+function draw() {
+  canvas.width = canvas.clientWidth * devicePixelRatio;
+  canvas.height = canvas.clientHeight * devicePixelRatio;
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  const r = 3 * devicePixelRatio;
+  points.forEach(p => {
+    if (!visible(p)) return;
+    const [x, y] = screen(p);
+    ctx.beginPath();
+    ctx.arc(x, y, r, 0, 2 * Math.PI);
+    ctx.fillStyle = color[p.group];
+    ctx.globalAlpha = .75;
+    ctx.fill();
+    if (p.outlier) { ctx.globalAlpha = 1; ctx.strokeStyle = "#000"; ctx.lineWidth = devicePixelRatio; ctx.stroke(); }
+  });
+  ctx.globalAlpha = 1;
+}
+
+let drag = null;
+canvas.onmousedown = e => { drag = [e.clientX, e.clientY]; canvas.style.cursor = "grabbing"; };
+window.onmouseup = () => { drag = null; canvas.style.cursor = "grab"; };
+canvas.onmousemove = e => {
+  if (drag) {
+    offX += (e.clientX - drag[0]) * devicePixelRatio; offY += (e.clientY - drag[1]) * devicePixelRatio;
+    drag = [e.clientX, e.clientY]; draw(); return;
+  }
+  const rect = canvas.getBoundingClientRect();
+  const mx = (e.clientX - rect.left) * devicePixelRatio, my = (e.clientY - rect.top) * devicePixelRatio;
+  let best = null, bestD = 36 * devicePixelRatio * devicePixelRatio;
+  points.forEach(p => {
+    if (!visible(p)) return;
+    const [x, y] = screen(p), d = (x - mx) ** 2 + (y - my) ** 2;
+    if (d < bestD) { best = p; bestD = d; }
+  });
+  if (!best) { tip.style.display = "none"; return; }
+  tip.innerHTML = "<b></b><div></div>";
+  tip.firstChild.textContent = best.collection + "/" + best.path + " #" + best.seq + (best.outlier ? " (outlier)" : "");
+  tip.lastChild.textContent = (best.title ? best.title + " — " : "") + best.text;
+  tip.style.left = (e.clientX - rect.left + 12) + "px";
+  tip.style.top = (e.clientY - rect.top + 12) + "px";
+  tip.style.display = "block";
+};
+canvas.onwheel = e => { e.preventDefault(); scale *= e.deltaY < 0 ? 1.15 : 1 / 1.15; draw(); };
+window.onresize = draw;
+draw();
+</script>
+</body>
+</html>
+`))
+
+// WriteEmbeddingMapHTML 把嵌入地图写成可交互的 HTML 散点图
+func WriteEmbeddingMapHTML(w io.Writer, m *mmq.EmbeddingMap) error {
+	return vizTemplate.Execute(w, m)
+}
@@ -0,0 +1,99 @@
+// Package i18n 面向用户的消息目录（en、zh）
+// 语言由配置 display.language 指定，未指定时按 LC_ALL、LC_MESSAGES、LANG 环境变量选择，默认英文
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 支持的语言
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+var (
+	mu      sync.RWMutex
+	current = FromEnv()
+)
+
+// Parse 解析语言设置：en、zh 及其区域形式（如 zh_CN.UTF-8、en-US）；空或 auto 按环境变量选择
+func Parse(s string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	if key == "" || key == "auto" {
+		return FromEnv(), nil
+	}
+	if i := strings.IndexAny(key, "_-."); i > 0 {
+		key = key[:i]
+	}
+	if _, ok := catalogs[key]; !ok {
+		return "", fmt.Errorf("unsupported language %q (use en, zh or auto)", s)
+	}
+	return key, nil
+}
+
+// FromEnv 按 LC_ALL、LC_MESSAGES、LANG 选择语言，无法识别时为英文
+func FromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := strings.ToLower(os.Getenv(name))
+		if v == "" {
+			continue
+		}
+		// 第一个非空变量生效（C、POSIX 等视为英文）
+		if strings.HasPrefix(v, Chinese) {
+			return Chinese
+		}
+		return English
+	}
+	return English
+}
+
+// Set 设置之后消息使用的语言（见 Parse）
+func Set(language string) error {
+	l, err := Parse(language)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	current = l
+	mu.Unlock()
+	return nil
+}
+
+// Language 返回当前语言
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T 返回当前语言的消息，有参数时按 fmt.Sprintf 格式化
+// 当前语言缺少该消息时使用英文，英文也没有时返回 key
+func T(key string, args ...interface{}) string {
+	msg, ok := catalogs[Language()][key]
+	if !ok {
+		if msg, ok = catalogs[English][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Missing 返回英文目录中有、language 目录中缺少的消息，用于检查目录是否完整
+func Missing(language string) []string {
+	var missing []string
+	for key := range catalogs[English] {
+		if _, ok := catalogs[language][key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
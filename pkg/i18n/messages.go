@@ -0,0 +1,224 @@
+package i18n
+
+// catalogs 各语言的消息目录：消息 ID → 文本（fmt 格式）
+// 新增消息时两种语言都要添加
+var catalogs = map[string]map[string]string{
+	English: {
+		// CLI 通用
+		"error": "Error",
+
+		// 库的哨兵错误
+		"err.collection_not_found": "collection not found",
+		"err.quota_exceeded":       "quota exceeded",
+		"err.rebuild_verification": "rebuild verification failed; database unchanged",
+
+		// chat
+		"chat.no_api_key":         "⚠️  No API key configured, trying local Ollama",
+		"chat.api_key_hint":       "   Set DEEPSEEK_API_KEY or OPENAI_API_KEY to use a cloud API",
+		"chat.banner":             "🤖 MMQ Chat (provider: %s, model: %s)",
+		"chat.session":            "📝 Session: %s",
+		"chat.persona":            "🎭 Persona: %s",
+		"chat.start":              "💬 Type a message to start chatting (/quit to exit, /help for commands)",
+		"chat.you":                "You: ",
+		"chat.api_error":          "❌ API error: %v",
+		"chat.memories_added":     "[memory] extracted %d new memories",
+		"chat.bye":                "👋 Bye!",
+		"chat.suspicious":         "[security] suspicious content found in injected memories/documents (%s)",
+		"chat.cached":             "[cache] answer reused from a similar question asked %s (similarity %.2f)",
+		"chat.cached_question":    "[debug] cached question: %s",
+		"chat.cache_failed":       "[debug] answer cache unavailable: %v",
+		"chat.rewrite_failed":     "[debug] query rewrite failed: %v",
+		"chat.rewritten":          "[debug] retrieval query: %s",
+		"chat.topic_shift":        "[topic] new topic, retrieved %d new sources",
+		"chat.topic_failed":       "[debug] topic shift check failed: %v",
+		"chat.latency":            "[debug] first token after %s (retrieval %s, prompt %s, API %s)",
+		"chat.verify_no_docs":     "[verify] no documents retrieved this turn, skipping verification",
+		"chat.verify_failed":      "[verify] failed: %v",
+		"chat.verify_ok":          "[verify] all %d sentences are supported by documents",
+		"chat.verify_unsupported": "[verify] %d/%d sentences lack document support:",
+		"chat.help": `Available commands:
+  /quit, /q        Exit
+  /clear           Clear the current conversation context
+  /history         Show this session's history
+  /sessions        List all sessions
+  /memory          Toggle memory
+  /rag             Toggle RAG
+  /tools           List tool plugins
+  /tool <name> ... Run a tool plugin and add its output to the conversation
+  /pin <doc>       Always include a document (docid or collection/path) in the context
+  /pins            List pinned documents
+  /unpin [doc]     Unpin a document (all without an argument)
+  /search <query>  Search documents without leaving the conversation
+  /get <n|doc>     Show a search result or document
+  /use <n|doc>     Add a search result or document to the next question's context
+  /export [file]   Save this session as a markdown transcript (default chat-<session>.md)`,
+		"chat.cleared":         "✓ Conversation context cleared",
+		"chat.no_history":      "No conversation history yet",
+		"chat.history_header":  "── Session %s history (%d turns) ──",
+		"chat.no_sessions":     "No sessions yet",
+		"chat.sessions_header": "All sessions (%d):",
+		"chat.session_line":    "  %s (%d turns)%s",
+		"chat.current":         " ← current",
+		"chat.memory_off":      "📴 Memory off",
+		"chat.memory_on":       "📡 Memory on",
+		"chat.rag_off":         "📴 RAG off",
+		"chat.rag_on":          "📡 RAG on",
+		"chat.no_tools":        "No tool plugins (see mmq plugins)",
+		"chat.tool_usage":      "Usage: /tool <name> <input>",
+		"chat.tool_failed":     "❌ Tool call failed: %v",
+		"chat.unknown_command": "Unknown command: %s (type /help for help)",
+		"chat.pin_usage":       "Usage: /pin <docid|collection/path>",
+		"chat.pin_failed":      "❌ Cannot pin %s: %v",
+		"chat.pinned":          "📌 Pinned %s (%s)",
+		"chat.already_pinned":  "Already pinned: %s",
+		"chat.no_pins":         "No pinned documents",
+		"chat.pins_header":     "Pinned documents (%d):",
+		"chat.unpinned":        "✓ Unpinned %s",
+		"chat.unpinned_all":    "✓ Unpinned %d documents",
+		"chat.not_pinned":      "Not pinned: %s",
+		"chat.search_usage":    "Usage: /search <query>",
+		"chat.search_failed":   "❌ Search failed: %v",
+		"chat.no_results":      "No results",
+		"chat.search_hint":     "/get <n> shows a result, /use <n> adds it to the next question",
+		"chat.get_usage":       "Usage: /get <n|docid|collection/path>",
+		"chat.get_failed":      "❌ Cannot get %s: %v",
+		"chat.doc_truncated":   "… (%d more characters, see mmq get #%s)",
+		"chat.use_usage":       "Usage: /use <n|docid|collection/path>",
+		"chat.injected":        "📎 %s will be added to the next question's context",
+		"chat.exported":        "✓ Wrote %d turns to %s",
+		"chat.export_failed":   "❌ Export failed: %v",
+		// 发送给模型的文本，决定回答的语言
+		"chat.system_prompt": "You are a helpful assistant.",
+		"chat.related_docs":  "[Related documents]",
+		"chat.tool_output":   "[Output of tool %s]",
+
+		// repl
+		"repl.start":           "🔎 Type a query to search (:help for commands, :quit to exit)",
+		"repl.usage":           "Usage: %s",
+		"repl.unknown_command": "Unknown command: %s (type :help for help)",
+		"repl.no_result":       "No result %d (the last search returned %d)",
+
+		// memory
+		"memory.stored":         "✓ Memory stored (type=%s, importance=%.1f)",
+		"memory.stored_expires": "✓ Memory stored (type=%s, importance=%.1f, expires in %s)",
+		"memory.updated":        "✓ Memory %s updated",
+		"memory.deleted":        "✓ Memory %s deleted",
+		"memory.review_done":    "✓ Kept %d, edited %d, deleted %d",
+		"memory.dedupe_done":    "✓ Merged %d group(s), removed %d duplicate memories",
+		"memory.stats_header":   "📊 Memory Statistics",
+		"memory.missing_embed":  "⚠ %d memories missing embeddings. Run 'mmq memory reembed' to backfill.",
+		"memory.reembedded":     "✓ Re-embedded %d memories",
+		"memory.cleaned":        "✓ Cleaned up %d expired memories",
+
+		// 索引健康
+		"health.no_vector_index":   "⚠ Vector index not found. Run 'mmq embed' to create embeddings.",
+		"health.missing_embedding": "⚠ %d/%d documents (%d%%) missing embeddings. Run 'mmq embed' to update.",
+		"health.stale":             "⚠ Index last updated %d days ago (%s). Run 'mmq update' to refresh.",
+	},
+
+	Chinese: {
+		"error": "错误",
+
+		"err.collection_not_found": "集合不存在",
+		"err.quota_exceeded":       "超出配额",
+		"err.rebuild_verification": "重建核对未通过，数据库未改变",
+
+		"chat.no_api_key":         "⚠️  未配置 API Key，将尝试连接本地 Ollama",
+		"chat.api_key_hint":       "   设置环境变量 DEEPSEEK_API_KEY 或 OPENAI_API_KEY 来使用云端 API",
+		"chat.banner":             "🤖 MMQ Chat (提供方: %s, 模型: %s)",
+		"chat.session":            "📝 会话: %s",
+		"chat.persona":            "🎭 人设: %s",
+		"chat.start":              "💬 输入消息开始对话 (输入 /quit 退出, /help 查看命令)",
+		"chat.you":                "你: ",
+		"chat.api_error":          "❌ API 错误: %v",
+		"chat.memories_added":     "[记忆] 自动提取了 %d 条新记忆",
+		"chat.bye":                "👋 再见!",
+		"chat.suspicious":         "[安全] 注入的记忆/文档中发现可疑内容 (%s)",
+		"chat.cached":             "[缓存] 复用了 %s 相似问题的回答（相似度 %.2f）",
+		"chat.cached_question":    "[debug] 缓存的问题: %s",
+		"chat.cache_failed":       "[debug] 回答缓存不可用: %v",
+		"chat.rewrite_failed":     "[debug] 查询改写失败: %v",
+		"chat.rewritten":          "[debug] 检索查询: %s",
+		"chat.topic_shift":        "[话题] 话题已切换，检索到 %d 个新来源",
+		"chat.topic_failed":       "[debug] 话题切换检测失败: %v",
+		"chat.latency":            "[debug] 首字延迟 %s（检索 %s，组装 prompt %s，API %s）",
+		"chat.verify_no_docs":     "[核查] 本轮未检索到文档，跳过核查",
+		"chat.verify_failed":      "[核查] 失败: %v",
+		"chat.verify_ok":          "[核查] %d 句均有文档依据",
+		"chat.verify_unsupported": "[核查] %d/%d 句缺少文档依据:",
+		"chat.help": `可用命令:
+  /quit, /q        退出
+  /clear           清除当前对话上下文
+  /history         查看当前会话历史
+  /sessions        查看所有会话
+  /memory          切换记忆开关
+  /rag             切换 RAG 开关
+  /tools           列出工具插件
+  /tool <名称> ... 调用工具插件，输出加入对话上下文
+  /pin <文档>      固定文档（docid 或 集合/路径），每轮都注入上下文
+  /pins            列出固定的文档
+  /unpin [文档]    取消固定（不带参数取消全部）
+  /search <查询>   在对话中搜索文档
+  /get <序号|文档> 查看搜索结果或文档
+  /use <序号|文档> 把搜索结果或文档加入下一个问题的上下文
+  /export [文件]   把当前会话导出为 Markdown 记录（默认 chat-<会话>.md）`,
+		"chat.cleared":         "✓ 对话上下文已清除",
+		"chat.no_history":      "暂无历史对话",
+		"chat.history_header":  "── 会话 %s 历史 (%d轮) ──",
+		"chat.no_sessions":     "暂无会话",
+		"chat.sessions_header": "所有会话 (%d):",
+		"chat.session_line":    "  %s (%d轮)%s",
+		"chat.current":         " ← 当前",
+		"chat.memory_off":      "📴 记忆已关闭",
+		"chat.memory_on":       "📡 记忆已开启",
+		"chat.rag_off":         "📴 RAG 已关闭",
+		"chat.rag_on":          "📡 RAG 已开启",
+		"chat.no_tools":        "暂无工具插件（见 mmq plugins）",
+		"chat.tool_usage":      "用法: /tool <名称> <输入>",
+		"chat.tool_failed":     "❌ 工具调用失败: %v",
+		"chat.unknown_command": "未知命令: %s (输入 /help 查看)",
+		"chat.pin_usage":       "用法: /pin <docid|集合/路径>",
+		"chat.pin_failed":      "❌ 无法固定 %s: %v",
+		"chat.pinned":          "📌 已固定 %s (%s)",
+		"chat.already_pinned":  "已固定: %s",
+		"chat.no_pins":         "没有固定的文档",
+		"chat.pins_header":     "固定的文档 (%d):",
+		"chat.unpinned":        "✓ 已取消固定 %s",
+		"chat.unpinned_all":    "✓ 已取消固定 %d 个文档",
+		"chat.not_pinned":      "未固定: %s",
+		"chat.search_usage":    "用法: /search <查询>",
+		"chat.search_failed":   "❌ 搜索失败: %v",
+		"chat.no_results":      "没有结果",
+		"chat.search_hint":     "/get <序号> 查看结果，/use <序号> 加入下一个问题的上下文",
+		"chat.get_usage":       "用法: /get <序号|docid|集合/路径>",
+		"chat.get_failed":      "❌ 无法获取 %s: %v",
+		"chat.doc_truncated":   "…（还有 %d 个字符，见 mmq get #%s）",
+		"chat.use_usage":       "用法: /use <序号|docid|集合/路径>",
+		"chat.injected":        "📎 %s 将加入下一个问题的上下文",
+		"chat.exported":        "✓ 已将 %d 轮对话写入 %s",
+		"chat.export_failed":   "❌ 导出失败: %v",
+		"chat.system_prompt":   "你是一个智能助手。",
+		"chat.related_docs":    "[相关文档]",
+		"chat.tool_output":     "[工具 %s 的输出]",
+
+		"repl.start":           "🔎 输入查询开始搜索 (:help 查看命令, :quit 退出)",
+		"repl.usage":           "用法: %s",
+		"repl.unknown_command": "未知命令: %s (输入 :help 查看)",
+		"repl.no_result":       "没有第 %d 个结果（上次搜索共 %d 个）",
+
+		"memory.stored":         "✓ 已保存记忆 (类型=%s, 重要性=%.1f)",
+		"memory.stored_expires": "✓ 已保存记忆 (类型=%s, 重要性=%.1f, %s 后过期)",
+		"memory.updated":        "✓ 记忆 %s 已更新",
+		"memory.deleted":        "✓ 记忆 %s 已删除",
+		"memory.review_done":    "✓ 保留 %d 条，编辑 %d 条，删除 %d 条",
+		"memory.dedupe_done":    "✓ 合并了 %d 组，删除了 %d 条重复记忆",
+		"memory.stats_header":   "📊 记忆统计",
+		"memory.missing_embed":  "⚠ %d 条记忆缺少向量，运行 'mmq memory reembed' 补齐",
+		"memory.reembedded":     "✓ 重新嵌入了 %d 条记忆",
+		"memory.cleaned":        "✓ 清理了 %d 条过期记忆",
+
+		"health.no_vector_index":   "⚠ 未找到向量索引，运行 'mmq embed' 生成向量",
+		"health.missing_embedding": "⚠ %d/%d 个文档 (%d%%) 缺少向量，运行 'mmq embed' 更新",
+		"health.stale":             "⚠ 索引 %d 天未更新 (%s)，运行 'mmq update' 刷新",
+	},
+}
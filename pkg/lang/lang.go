@@ -0,0 +1,97 @@
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 支持识别的语言（ISO 639-1）
+const (
+	Unknown  = ""
+	English  = "en"
+	Chinese  = "zh"
+	Japanese = "ja"
+	Korean   = "ko"
+)
+
+// Detect 按字符脚本统计识别文本的主要语言
+// 汉字逐字计数，拉丁字母按单词计数，二者多者胜出；出现假名判为日语，谚文判为韩语
+func Detect(text string) string {
+	var han, kana, hangul, latinWords int
+	inWord := false
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case r < unicode.MaxLatin1 && unicode.IsLetter(r):
+			if !inWord {
+				latinWords++
+			}
+			inWord = true
+			continue
+		}
+		inWord = false
+	}
+
+	cjk := han + kana + hangul
+	switch {
+	case cjk == 0 && latinWords == 0:
+		return Unknown
+	case cjk < latinWords:
+		return English
+	case hangul > han+kana:
+		return Korean
+	case kana*10 >= cjk:
+		return Japanese
+	default:
+		return Chinese
+	}
+}
+
+// Normalize 规范化语言代码（如 "zh-CN" → "zh"），未知返回原值的小写
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// IsStopword 判断词是否为该语言的停用词
+func IsStopword(code, word string) bool {
+	set, ok := stopwords[Normalize(code)]
+	if !ok {
+		return false
+	}
+	return set[strings.ToLower(word)]
+}
+
+// QueryInstruction 返回该语言的检索指令（用于需要指令的嵌入模型）
+func QueryInstruction(code string) string {
+	switch Normalize(code) {
+	case Chinese:
+		return "给定一个搜索查询，检索能够回答该查询的相关段落"
+	default:
+		return "Given a web search query, retrieve relevant passages that answer the query"
+	}
+}
+
+var stopwords = map[string]map[string]bool{
+	English: toSet(`a an and are as at be but by for from has have how i in is it its of on or
+		that the this to was were what when where which who why will with you your`),
+	Chinese: toSet(`的 了 和 是 在 我 有 就 不 人 都 一 一个 也 很 到 说 要 去 你 会 着 没有 看 好
+		这 那 与 及 或 而 吗 呢 吧 啊 么 什么 怎么 如何 哪些 为什么`),
+}
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Capability 后端能力
+type Capability string
+
+const (
+	CapabilityEmbed       Capability = "embed"        // Embedder
+	CapabilityRerank      Capability = "rerank"       // Reranker
+	CapabilityGenerate    Capability = "generate"     // Generator
+	CapabilityExpandQuery Capability = "expand_query" // QueryExpander
+)
+
+// AllCapabilities 全部能力，按固定顺序
+var AllCapabilities = []Capability{CapabilityEmbed, CapabilityRerank, CapabilityGenerate, CapabilityExpandQuery}
+
+// ErrUnsupported 后端不支持所调用的能力
+var ErrUnsupported = errors.New("capability not supported by this LLM backend")
+
+// capabilityReporter 由 Compose 返回的组合后端实现，报告实际具备的能力
+type capabilityReporter interface {
+	Supports(c Capability) bool
+}
+
+// Supports 运行时检测 impl 是否具备能力
+// 组合后端按实际提供者判断，其他实现按是否实现对应接口判断
+func Supports(impl interface{}, c Capability) bool {
+	if r, ok := impl.(capabilityReporter); ok {
+		return r.Supports(c)
+	}
+	switch c {
+	case CapabilityEmbed:
+		_, ok := impl.(Embedder)
+		return ok
+	case CapabilityRerank:
+		_, ok := impl.(Reranker)
+		return ok
+	case CapabilityGenerate:
+		_, ok := impl.(Generator)
+		return ok
+	case CapabilityExpandQuery:
+		_, ok := impl.(QueryExpander)
+		return ok
+	}
+	return false
+}
+
+// Capabilities 返回 impl 具备的全部能力
+func Capabilities(impl interface{}) []Capability {
+	var caps []Capability
+	for _, c := range AllCapabilities {
+		if Supports(impl, c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// Compose 把各能力的提供者组合成一个 LLM
+// 每个提供者按实现的接口贡献能力，同一能力以后出现的提供者为准；
+// 缺少的能力调用时返回 ErrUnsupported，可用 Supports 提前检测
+// 例如远程嵌入服务 + 本地生成模型：Compose(remoteEmbedder, localGenerator)
+func Compose(providers ...interface{}) LLM {
+	c := &composite{}
+	for _, p := range providers {
+		if p == nil {
+			continue
+		}
+		if e, ok := p.(Embedder); ok && Supports(p, CapabilityEmbed) {
+			c.embedder = e
+		}
+		if r, ok := p.(Reranker); ok && Supports(p, CapabilityRerank) {
+			c.reranker = r
+		}
+		if g, ok := p.(Generator); ok && Supports(p, CapabilityGenerate) {
+			c.generator = g
+		}
+		if q, ok := p.(QueryExpander); ok && Supports(p, CapabilityExpandQuery) {
+			c.expander = q
+		}
+		c.providers = append(c.providers, p)
+	}
+	return c
+}
+
+// composite Compose 返回的组合后端
+type composite struct {
+	embedder  Embedder
+	reranker  Reranker
+	generator Generator
+	expander  QueryExpander
+	providers []interface{}
+}
+
+func (c *composite) Supports(capability Capability) bool {
+	switch capability {
+	case CapabilityEmbed:
+		return c.embedder != nil
+	case CapabilityRerank:
+		return c.reranker != nil
+	case CapabilityGenerate:
+		return c.generator != nil
+	case CapabilityExpandQuery:
+		return c.expander != nil
+	}
+	return false
+}
+
+func (c *composite) Embed(text string, isQuery bool) ([]float32, error) {
+	if c.embedder == nil {
+		return nil, unsupported(CapabilityEmbed)
+	}
+	return c.embedder.Embed(text, isQuery)
+}
+
+func (c *composite) EmbedBatch(texts []string, isQuery bool) ([][]float32, error) {
+	if c.embedder == nil {
+		return nil, unsupported(CapabilityEmbed)
+	}
+	return c.embedder.EmbedBatch(texts, isQuery)
+}
+
+func (c *composite) Rerank(query string, docs []Document) ([]RerankResult, error) {
+	if c.reranker == nil {
+		return nil, unsupported(CapabilityRerank)
+	}
+	return c.reranker.Rerank(query, docs)
+}
+
+func (c *composite) Generate(prompt string, opts GenerateOptions) (string, error) {
+	if c.generator == nil {
+		return "", unsupported(CapabilityGenerate)
+	}
+	return c.generator.Generate(prompt, opts)
+}
+
+func (c *composite) ExpandQuery(query string) ([]QueryExpansion, error) {
+	if c.expander == nil {
+		return nil, unsupported(CapabilityExpandQuery)
+	}
+	return c.expander.ExpandQuery(query)
+}
+
+// Close 关闭所有实现了 Close 的提供者，返回第一个错误
+func (c *composite) Close() error {
+	var first error
+	for _, p := range c.providers {
+		if closer, ok := p.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// IsLoaded 由负责该模型类型的提供者判断；提供者不报告加载状态时视为已加载
+func (c *composite) IsLoaded(modelType ModelType) bool {
+	p := c.providerFor(modelType)
+	if p == nil {
+		return false
+	}
+	if l, ok := p.(interface{ IsLoaded(ModelType) bool }); ok {
+		return l.IsLoaded(modelType)
+	}
+	return true
+}
+
+// SetModelPath 转给负责该模型类型、且支持设置路径的提供者
+func (c *composite) SetModelPath(modelType ModelType, path string) {
+	if s, ok := c.providerFor(modelType).(interface{ SetModelPath(ModelType, string) }); ok {
+		s.SetModelPath(modelType, path)
+	}
+}
+
+// providerFor 返回负责模型类型的提供者
+func (c *composite) providerFor(modelType ModelType) interface{} {
+	switch modelType {
+	case ModelTypeEmbedding:
+		if c.embedder != nil {
+			return c.embedder
+		}
+	case ModelTypeRerank:
+		if c.reranker != nil {
+			return c.reranker
+		}
+	case ModelTypeGenerate:
+		if c.generator != nil {
+			return c.generator
+		}
+	}
+	return nil
+}
+
+func unsupported(c Capability) error {
+	return fmt.Errorf("%s: %w", c, ErrUnsupported)
+}
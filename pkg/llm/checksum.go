@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumError 模型文件的 SHA256 与固定值不一致
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected sha256 %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// FileSHA256 计算文件的 SHA256（十六进制小写）
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PinFor 返回模型文件的固定校验和，pins 以文件名为键（可省略 .gguf 后缀）
+func PinFor(pins map[string]string, path string) (string, bool) {
+	base := filepath.Base(path)
+	if sum, ok := pins[base]; ok {
+		return strings.ToLower(sum), true
+	}
+	name := strings.TrimSuffix(base, ".gguf")
+	for key, sum := range pins {
+		if strings.TrimSuffix(key, ".gguf") == name {
+			return strings.ToLower(sum), true
+		}
+	}
+	return "", false
+}
+
+// VerifyModel 校验已固定的模型文件，未固定的文件直接通过
+func VerifyModel(path string, pins map[string]string) error {
+	expected, ok := PinFor(pins, path)
+	if !ok {
+		return nil
+	}
+	actual, err := FileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &ChecksumError{Path: path, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// ValidatePins 检查固定值是否为 64 位十六进制 SHA256
+func ValidatePins(pins map[string]string) error {
+	for name, sum := range pins {
+		if len(sum) != 64 {
+			return fmt.Errorf("invalid sha256 pin for %s: expected 64 hex characters", name)
+		}
+		if _, err := hex.DecodeString(sum); err != nil {
+			return fmt.Errorf("invalid sha256 pin for %s: %w", name, err)
+		}
+	}
+	return nil
+}
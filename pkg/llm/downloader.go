@@ -43,6 +43,7 @@ type DownloadOptions struct {
 	ForceDownload bool          // 强制重新下载
 	Timeout       time.Duration // 超时时间
 	ProgressFunc  func(downloaded, total int64)
+	Pins          map[string]string // 文件名 → SHA256，校验不一致的模型拒绝使用
 }
 
 // DefaultDownloadOptions 默认下载选项
@@ -75,9 +76,12 @@ func (d *Downloader) Download(ref HFRef) (string, error) {
 	// 生成本地文件路径
 	localPath := filepath.Join(d.opts.CacheDir, ref.Filename)
 
-	// 检查文件是否已存在
+	// 检查文件是否已存在（已固定的文件校验后才使用）
 	if !d.opts.ForceDownload {
 		if _, err := os.Stat(localPath); err == nil {
+			if err := VerifyModel(localPath, d.opts.Pins); err != nil {
+				return "", err
+			}
 			return localPath, nil
 		}
 	}
@@ -156,6 +160,19 @@ func (d *Downloader) downloadFile(url, localPath string) error {
 	// 关闭临时文件
 	tmpFile.Close()
 
+	// 校验和不一致时丢弃下载的文件
+	if expected, ok := PinFor(d.opts.Pins, localPath); ok {
+		actual, err := FileSHA256(tmpPath)
+		if err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		if actual != expected {
+			os.Remove(tmpPath)
+			return &ChecksumError{Path: url, Expected: expected, Actual: actual}
+		}
+	}
+
 	// 原子性重命名
 	if err := os.Rename(tmpPath, localPath); err != nil {
 		os.Remove(tmpPath)
@@ -195,7 +212,7 @@ func DownloadDefaultModels(cacheDir string, progress func(model string, download
 	}
 
 	for name, ref := range models {
-		fmt.Printf("Downloading %s model...\n", name)
+		logf("Downloading %s model...", name)
 
 		if progress != nil {
 			opts.ProgressFunc = func(downloaded, total int64) {
@@ -208,7 +225,7 @@ func DownloadDefaultModels(cacheDir string, progress func(model string, download
 			return fmt.Errorf("failed to download %s model: %w", name, err)
 		}
 
-		fmt.Printf("✓ %s model downloaded to: %s\n", name, path)
+		logf("✓ %s model downloaded to: %s", name, path)
 	}
 
 	return nil
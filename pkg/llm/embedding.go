@@ -6,12 +6,15 @@ import (
 
 // EmbeddingGenerator 嵌入生成器
 type EmbeddingGenerator struct {
-	llm  LLM
+	llm  Embedder
 	info EmbeddingInfo
+
+	// queryInstruction 返回查询的检索指令（为空表示不加指令）
+	queryInstruction func(query string) string
 }
 
 // NewEmbeddingGenerator 创建嵌入生成器
-func NewEmbeddingGenerator(llm LLM, modelName string, dimensions int) *EmbeddingGenerator {
+func NewEmbeddingGenerator(llm Embedder, modelName string, dimensions int) *EmbeddingGenerator {
 	return &EmbeddingGenerator{
 		llm: llm,
 		info: EmbeddingInfo{
@@ -22,14 +25,44 @@ func NewEmbeddingGenerator(llm LLM, modelName string, dimensions int) *Embedding
 	}
 }
 
+// SetQueryInstruction 设置查询指令函数，用于需要指令前缀的嵌入模型
+func (e *EmbeddingGenerator) SetQueryInstruction(fn func(query string) string) {
+	e.queryInstruction = fn
+}
+
+// withInstruction 为查询文本加上指令前缀；override 非空时代替默认指令
+func (e *EmbeddingGenerator) withInstruction(text string, isQuery bool, override string) string {
+	if !isQuery {
+		return text
+	}
+	inst := override
+	if inst == "" && e.queryInstruction != nil {
+		inst = e.queryInstruction(text)
+	}
+	if inst != "" {
+		return "Instruct: " + inst + "\nQuery: " + text
+	}
+	return text
+}
+
 // Generate 生成单个嵌入
 func (e *EmbeddingGenerator) Generate(text string, isQuery bool) ([]float32, error) {
+	return e.generate(text, isQuery, "")
+}
+
+// GenerateQuery 用指定的指令生成查询嵌入（如聚类、分类任务），instruction 为空时使用默认指令
+// 不论模型是否默认使用指令，非空的 instruction 都以 "Instruct: ...\nQuery: ..." 形式加在查询前
+func (e *EmbeddingGenerator) GenerateQuery(query, instruction string) ([]float32, error) {
+	return e.generate(query, true, instruction)
+}
+
+func (e *EmbeddingGenerator) generate(text string, isQuery bool, instruction string) ([]float32, error) {
 	if text == "" {
 		return nil, fmt.Errorf("empty text")
 	}
 
 	// 截断过长的文本
-	text = truncateText(text, e.info.MaxTokens)
+	text = truncateText(e.withInstruction(text, isQuery, instruction), e.info.MaxTokens)
 
 	// 生成嵌入
 	embedding, err := e.llm.Embed(text, isQuery)
@@ -58,7 +91,7 @@ func (e *EmbeddingGenerator) GenerateBatch(texts []string, isQuery bool) ([][]fl
 	// 截断过长的文本
 	truncated := make([]string, len(texts))
 	for i, text := range texts {
-		truncated[i] = truncateText(text, e.info.MaxTokens)
+		truncated[i] = truncateText(e.withInstruction(text, isQuery, ""), e.info.MaxTokens)
 	}
 
 	// 批量生成
@@ -1,5 +1,5 @@
-//go:build !llama
-// +build !llama
+//go:build !llama && !purego
+// +build !llama,!purego
 
 package llm
 
@@ -10,6 +10,9 @@ import (
 	"runtime"
 )
 
+// LocalInference 本构建是否支持本地推理（yzma / llama.cpp）
+const LocalInference = true
+
 // defaultLibDir 返回默认的 yzma 库目录 ~/.cache/mmq/lib
 func defaultLibDir() string {
 	home, err := os.UserHomeDir()
@@ -61,6 +64,6 @@ func NewLLM(cfg ModelConfig) (LLM, error) {
 	}
 
 	cfg.LibPath = libPath
-	fmt.Println("Initializing YzmaLLM (local inference via yzma)")
+	logf("Initializing YzmaLLM (local inference via yzma)")
 	return NewYzmaLLM(cfg)
 }
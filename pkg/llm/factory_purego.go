@@ -0,0 +1,20 @@
+//go:build purego
+
+package llm
+
+import "fmt"
+
+// LocalInference 本构建是否支持本地推理（yzma / llama.cpp）
+const LocalInference = false
+
+// NewLLM 纯 Go 构建没有本地推理，返回不具备任何能力的后端
+// 嵌入由远程嵌入器提供（mmq.WithEmbedder 或配置 models.embedding_api），
+// 重排序、查询扩展自动跳过，生成类功能返回 ErrUnsupported
+func NewLLM(cfg ModelConfig) (LLM, error) {
+	return Compose(), nil
+}
+
+// VerifyLibrary 纯 Go 构建不加载推理库
+func VerifyLibrary(libPath string) error {
+	return fmt.Errorf("yzma: local inference is not available in this build (built with -tags purego): %w", ErrUnsupported)
+}
@@ -5,32 +5,51 @@ import (
 	"time"
 )
 
-// LLM 大语言模型接口
+// LLM 大语言模型接口：具备全部能力的本地后端
+// 只提供部分能力的后端（如远程嵌入服务、仅生成模型）用 Compose 组合成 LLM
+// 检索会从多个 goroutine 并发调用同一实例（混合检索的各路、查询扩展的各变体），实现必须并发安全
 type LLM interface {
+	Embedder
+	Reranker
+	Generator
+	QueryExpander
+
+	// Close 关闭并释放资源
+	Close() error
+
+	// IsLoaded 检查模型是否已加载
+	IsLoaded(modelType ModelType) bool
+
+	// SetModelPath 设置模型路径
+	SetModelPath(modelType ModelType, path string)
+}
+
+// Embedder 嵌入能力
+type Embedder interface {
 	// Embed 生成文本的嵌入向量
 	// isQuery: true表示查询文本，false表示文档文本
 	Embed(text string, isQuery bool) ([]float32, error)
 
 	// EmbedBatch 批量生成嵌入向量
 	EmbedBatch(texts []string, isQuery bool) ([][]float32, error)
+}
 
+// Reranker 重排能力
+type Reranker interface {
 	// Rerank 重新排序文档
 	Rerank(query string, docs []Document) ([]RerankResult, error)
+}
 
+// Generator 文本生成能力
+type Generator interface {
 	// Generate 生成文本（用于查询扩展等）
 	Generate(prompt string, opts GenerateOptions) (string, error)
+}
 
+// QueryExpander 查询扩展能力
+type QueryExpander interface {
 	// ExpandQuery 查询扩展，生成查询变体以提高检索召回率
 	ExpandQuery(query string) ([]QueryExpansion, error)
-
-	// Close 关闭并释放资源
-	Close() error
-
-	// IsLoaded 检查模型是否已加载
-	IsLoaded(modelType ModelType) bool
-
-	// SetModelPath 设置模型路径
-	SetModelPath(modelType ModelType, path string)
 }
 
 // ModelType 模型类型
@@ -87,6 +106,8 @@ type ModelConfig struct {
 	Timeout     time.Duration // 超时时间
 	CacheDir    string        // 模型缓存目录
 	LibPath     string        // yzma 库路径（YZMA_LIB）
+
+	Pins map[string]string // 模型文件名 → SHA256，加载前校验
 }
 
 // DefaultModelConfig 默认模型配置
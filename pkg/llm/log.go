@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logger 模型加载、下载等提示的输出位置，为空时直接打印
+// yzma 推理库是进程级的，这里也使用包级设置
+var logger *slog.Logger
+
+// SetLogger 设置模型加载、下载进度等提示的输出位置（nil 恢复为直接打印）
+// 管道输出 JSON/CSV 时可设为写标准错误或丢弃的 logger
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// logf 输出提示：设置了 logger 时写入日志，否则打印到标准输出
+func logf(format string, args ...interface{}) {
+	logTo(os.Stdout, format, args...)
+}
+
+// logTo 同 logf，未设置 logger 时打印到 w
+func logTo(w io.Writer, format string, args ...interface{}) {
+	if logger != nil {
+		logger.Info(fmt.Sprintf(format, args...))
+		return
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// RemoteEmbedder 通过 OpenAI 兼容的 /embeddings 接口生成嵌入（OpenAI、Ollama、vLLM、TEI 等）
+// 不依赖本地推理库，纯 Go 构建中是唯一的嵌入来源
+type RemoteEmbedder struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+// NewRemoteEmbedder 创建远程嵌入器，baseURL 形如 http://localhost:11434/v1
+func NewRemoteEmbedder(baseURL, apiKey, model string) *RemoteEmbedder {
+	return &RemoteEmbedder{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Model:   model,
+		Client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// embeddingRequest OpenAI Embeddings 请求
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse OpenAI Embeddings 响应
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed 生成单条文本的嵌入
+func (r *RemoteEmbedder) Embed(text string, isQuery bool) ([]float32, error) {
+	vecs, err := r.EmbedBatch([]string{text}, isQuery)
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// EmbedBatch 一次请求生成多条文本的嵌入，按输入顺序返回
+func (r *RemoteEmbedder) EmbedBatch(texts []string, isQuery bool) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(embeddingRequest{Model: r.Model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", r.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+
+	resp, err := r.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var embResp embeddingResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if len(embResp.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding API returned %d vectors for %d inputs", len(embResp.Data), len(texts))
+	}
+
+	sort.Slice(embResp.Data, func(i, j int) bool { return embResp.Data[i].Index < embResp.Data[j].Index })
+	vecs := make([][]float32, len(texts))
+	for i, d := range embResp.Data {
+		vecs[i] = d.Embedding
+	}
+	return vecs, nil
+}
+
+var _ Embedder = (*RemoteEmbedder)(nil)
@@ -1,3 +1,5 @@
+//go:build !purego
+
 package llm
 
 import (
@@ -59,6 +61,20 @@ func NewYzmaLLM(cfg ModelConfig) (*YzmaLLM, error) {
 	}, nil
 }
 
+// VerifyLibrary 检查目录中的 llama.cpp 动态库能否被加载（mmq setup 安装后校验）
+// 库加载是进程级的，校验通过后同一进程内的 YzmaLLM 可以直接使用
+func VerifyLibrary(libPath string) error {
+	if libPath == "" {
+		return fmt.Errorf("yzma: library path is empty")
+	}
+	if err := llama.Load(libPath); err != nil {
+		return fmt.Errorf("yzma: failed to load library from %s: %w", libPath, err)
+	}
+	llama.Init()
+	llama.LogSet(llama.LogSilent())
+	return nil
+}
+
 // ensureLoaded 延迟加载模型
 func (y *YzmaLLM) ensureLoaded(modelType ModelType) error {
 	y.mu.Lock()
@@ -109,8 +125,9 @@ func (y *YzmaLLM) loadEmbeddingModel() error {
 			y.embeddingModelPath = modelPath
 		} else {
 			// 自动下载
-			fmt.Printf("Embedding model not found at %s, downloading...\n", modelPath)
+			logf("Embedding model not found at %s, downloading...", modelPath)
 			opts := DefaultDownloadOptions()
+			opts.Pins = y.cfg.Pins
 			if y.cacheDir != "" {
 				opts.CacheDir = y.cacheDir
 			}
@@ -125,6 +142,11 @@ func (y *YzmaLLM) loadEmbeddingModel() error {
 	}
 
 	// 加载模型
+	// 固定了校验和的模型不一致时拒绝加载
+	if err := VerifyModel(modelPath, y.cfg.Pins); err != nil {
+		return fmt.Errorf("yzma: refusing to load embedding model: %w", err)
+	}
+
 	model, err := llama.ModelLoadFromFile(modelPath, llama.ModelDefaultParams())
 	if err != nil {
 		return fmt.Errorf("yzma: failed to load embedding model %s: %w", modelPath, err)
@@ -153,7 +175,7 @@ func (y *YzmaLLM) loadEmbeddingModel() error {
 	y.nEmbd = llama.ModelNEmbd(model)
 	y.loaded[ModelTypeEmbedding] = true
 
-	fmt.Fprintf(os.Stderr, "Loaded embedding model: %s (dim=%d)\n", modelPath, y.nEmbd)
+	logTo(os.Stderr, "Loaded embedding model: %s (dim=%d)", modelPath, y.nEmbd)
 	return nil
 }
 
@@ -170,8 +192,9 @@ func (y *YzmaLLM) loadRerankModel() error {
 			modelPath = modelPath + ".gguf"
 			y.rerankModelPath = modelPath
 		} else {
-			fmt.Printf("Rerank model not found at %s, downloading...\n", modelPath)
+			logf("Rerank model not found at %s, downloading...", modelPath)
 			opts := DefaultDownloadOptions()
+			opts.Pins = y.cfg.Pins
 			if y.cacheDir != "" {
 				opts.CacheDir = y.cacheDir
 			}
@@ -186,6 +209,11 @@ func (y *YzmaLLM) loadRerankModel() error {
 	}
 
 	// 加载模型
+	// 固定了校验和的模型不一致时拒绝加载
+	if err := VerifyModel(modelPath, y.cfg.Pins); err != nil {
+		return fmt.Errorf("yzma: refusing to load rerank model: %w", err)
+	}
+
 	model, err := llama.ModelLoadFromFile(modelPath, llama.ModelDefaultParams())
 	if err != nil {
 		return fmt.Errorf("yzma: failed to load rerank model %s: %w", modelPath, err)
@@ -219,7 +247,7 @@ func (y *YzmaLLM) loadRerankModel() error {
 	y.nClsOut = int32(nClsOut)
 	y.loaded[ModelTypeRerank] = true
 
-	fmt.Printf("Loaded rerank model: %s (n_cls_out=%d)\n", modelPath, y.nClsOut)
+	logf("Loaded rerank model: %s (n_cls_out=%d)", modelPath, y.nClsOut)
 	return nil
 }
 
@@ -233,8 +261,9 @@ func (y *YzmaLLM) loadGenerateModel() error {
 	// 检查模型文件是否存在
 	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
 		// 自动下载
-		fmt.Printf("Generate model not found at %s, downloading...\n", modelPath)
+		logf("Generate model not found at %s, downloading...", modelPath)
 		opts := DefaultDownloadOptions()
+		opts.Pins = y.cfg.Pins
 		if y.cacheDir != "" {
 			opts.CacheDir = y.cacheDir
 		}
@@ -248,6 +277,11 @@ func (y *YzmaLLM) loadGenerateModel() error {
 	}
 
 	// 加载模型
+	// 固定了校验和的模型不一致时拒绝加载
+	if err := VerifyModel(modelPath, y.cfg.Pins); err != nil {
+		return fmt.Errorf("yzma: refusing to load generate model: %w", err)
+	}
+
 	model, err := llama.ModelLoadFromFile(modelPath, llama.ModelDefaultParams())
 	if err != nil {
 		return fmt.Errorf("yzma: failed to load generate model %s: %w", modelPath, err)
@@ -274,7 +308,7 @@ func (y *YzmaLLM) loadGenerateModel() error {
 	y.genVocab = llama.ModelGetVocab(model)
 	y.loaded[ModelTypeGenerate] = true
 
-	fmt.Printf("Loaded generate model: %s\n", modelPath)
+	logf("Loaded generate model: %s", modelPath)
 	return nil
 }
 
@@ -558,7 +592,7 @@ func (y *YzmaLLM) Close() error {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					fmt.Printf("Warning: yzma library cleanup recovered from panic: %v\n", r)
+					logf("Warning: yzma library cleanup recovered from panic: %v", r)
 				}
 			}()
 			llama.Close()
@@ -611,3 +645,5 @@ func cosineSimilarity(a, b []float32) float64 {
 
 	return dot / denom
 }
+
+var _ LLM = (*YzmaLLM)(nil)
@@ -12,6 +12,7 @@ type ConversationTurn struct {
 	SessionID string
 	Timestamp time.Time
 	Metadata  map[string]interface{}
+	Sources   []string // 本轮检索引用的文档 docid
 }
 
 // ConversationMemory 对话记忆管理
@@ -28,13 +29,17 @@ func NewConversationMemory(manager *Manager) *ConversationMemory {
 func (c *ConversationMemory) StoreTurn(turn ConversationTurn) error {
 	content := fmt.Sprintf("用户: %s\n助手: %s", turn.User, turn.Assistant)
 
-	metadata := turn.Metadata
-	if metadata == nil {
-		metadata = make(map[string]interface{})
+	// 复制调用方的元数据，并发会话可能共用同一个 map
+	metadata := make(map[string]interface{}, len(turn.Metadata)+4)
+	for k, v := range turn.Metadata {
+		metadata[k] = v
 	}
 	metadata["user_msg"] = turn.User
 	metadata["assistant_msg"] = turn.Assistant
 	metadata["session_id"] = turn.SessionID
+	if len(turn.Sources) > 0 {
+		metadata[MetadataSourceDocs] = turn.Sources
+	}
 
 	mem := Memory{
 		Type:       MemoryTypeConversation,
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultDuplicateThreshold 判定为近似重复的默认余弦相似度
+const DefaultDuplicateThreshold = 0.92
+
+// MetadataMergedFrom 元数据中记录被合并记忆 ID 的键
+const MetadataMergedFrom = "merged_from"
+
+// DuplicateGroup 一组近似重复的记忆及合并方案
+type DuplicateGroup struct {
+	Keep       Memory    // 保留的记忆（重要性最高，相同时取最新）
+	Merge      []Memory  // 合并后删除的记忆
+	Similarity []float64 // Merge 中各记忆与 Keep 的余弦相似度
+	Tags       []string  // 合并后的标签（并集）
+	Importance float64   // 合并后的重要性（组内最高）
+}
+
+// FindDuplicates 按嵌入相似度查找整个记忆库中的近似重复
+// 只比较类型和命名空间相同的记忆；memType 为空时检查所有类型，threshold<=0 使用默认值
+func (m *Manager) FindDuplicates(threshold float64, memType MemoryType) ([]DuplicateGroup, error) {
+	if threshold <= 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+	if threshold > 1 {
+		return nil, fmt.Errorf("similarity threshold must be between 0 and 1, got %v", threshold)
+	}
+
+	results, err := m.store.GetAllMemories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memories: %w", err)
+	}
+	embeddings, err := m.store.GetMemoryEmbeddings()
+	if err != nil {
+		return nil, err
+	}
+
+	var memories []Memory
+	for _, r := range results {
+		if memType != "" && MemoryType(r.Type) != memType {
+			continue
+		}
+		if _, ok := embeddings[r.ID]; !ok {
+			continue // 缺少嵌入的记忆先用 reembed 补齐
+		}
+		memories = append(memories, Memory{
+			ID:         r.ID,
+			Type:       MemoryType(r.Type),
+			Content:    r.Content,
+			Metadata:   r.Metadata,
+			Tags:       r.Tags,
+			Timestamp:  r.Timestamp,
+			ExpiresAt:  r.ExpiresAt,
+			Importance: r.Importance,
+		})
+	}
+
+	// 重要性高的先作为保留项，避免 A≈B、B≈C 时把不相似的 A 和 C 串到一起
+	sort.SliceStable(memories, func(i, j int) bool {
+		if memories[i].Importance != memories[j].Importance {
+			return memories[i].Importance > memories[j].Importance
+		}
+		return memories[i].Timestamp.After(memories[j].Timestamp)
+	})
+
+	merged := make([]bool, len(memories))
+	var groups []DuplicateGroup
+	for i, keep := range memories {
+		if merged[i] {
+			continue
+		}
+		group := DuplicateGroup{Keep: keep}
+		for j := i + 1; j < len(memories); j++ {
+			other := memories[j]
+			if merged[j] || other.Type != keep.Type || namespaceOf(other) != namespaceOf(keep) {
+				continue
+			}
+			sim := 1 - cosineDistance(embeddings[keep.ID], embeddings[other.ID])
+			if sim < threshold {
+				continue
+			}
+			merged[j] = true
+			group.Merge = append(group.Merge, other)
+			group.Similarity = append(group.Similarity, sim)
+		}
+		if len(group.Merge) == 0 {
+			continue
+		}
+
+		group.Importance = keep.Importance
+		group.Tags = unionTags(keep.Tags)
+		for _, mem := range group.Merge {
+			group.Tags = unionTags(group.Tags, mem.Tags...)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// MergeDuplicates 应用合并方案：保留 Keep（标签取并集、重要性取最高、访问次数累加），
+// 在元数据中记录被合并的记忆和来源文档，并删除 Merge 中的记忆
+func (m *Manager) MergeDuplicates(group DuplicateGroup) error {
+	if len(group.Merge) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]interface{}, len(group.Keep.Metadata)+1)
+	for k, v := range group.Keep.Metadata {
+		metadata[k] = v
+	}
+	mergedFrom := metadataStrings(metadata[MetadataMergedFrom])
+	sources := metadataStrings(metadata[MetadataSourceDocs])
+	ids := make([]string, len(group.Merge))
+	for i, mem := range group.Merge {
+		ids[i] = mem.ID
+		mergedFrom = unionTags(mergedFrom, append([]string{mem.ID}, metadataStrings(mem.Metadata[MetadataMergedFrom])...)...)
+		sources = unionTags(sources, metadataStrings(mem.Metadata[MetadataSourceDocs])...)
+	}
+	metadata[MetadataMergedFrom] = mergedFrom
+	if len(sources) > 0 {
+		metadata[MetadataSourceDocs] = sources
+	}
+
+	if err := m.store.MergeMemories(group.Keep.ID, group.Tags, group.Importance, metadata, ids); err != nil {
+		return fmt.Errorf("failed to merge memories: %w", err)
+	}
+	return nil
+}
+
+// namespaceOf 记忆所属的命名空间（未设置时为空）
+func namespaceOf(mem Memory) string {
+	ns, _ := mem.Metadata[MetadataNamespace].(string)
+	return ns
+}
+
+// unionTags 按出现顺序合并去重
+func unionTags(tags []string, more ...string) []string {
+	seen := make(map[string]bool, len(tags)+len(more))
+	result := make([]string, 0, len(tags)+len(more))
+	for _, t := range append(append([]string{}, tags...), more...) {
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	return result
+}
+
+// metadataStrings 读取元数据中的字符串列表（JSON 解码后为 []interface{}）
+func metadataStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// cosineDistance 余弦距离，维度不同或为零向量时返回 1
+func cosineDistance(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 1
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/pii"
 )
 
 // ExtractedMemory 从对话中提取的记忆
@@ -20,6 +21,7 @@ type ExtractedMemory struct {
 type Extractor struct {
 	apiClient *llm.APIClient
 	manager   *Manager
+	namespace string // 新记忆写入的命名空间
 }
 
 // NewExtractor 创建记忆提取器
@@ -30,6 +32,9 @@ func NewExtractor(apiClient *llm.APIClient, manager *Manager) *Extractor {
 	}
 }
 
+// SetNamespace 设置新记忆写入的命名空间（人设隔离记忆）
+func (e *Extractor) SetNamespace(namespace string) { e.namespace = namespace }
+
 // extractionPrompt 提取记忆的 prompt
 const extractionPrompt = `分析以下对话，提取用户明确**陈述**的**持久性**事实或偏好。
 
@@ -88,7 +93,7 @@ func (e *Extractor) ExtractFromTurn(turn ConversationTurn) (int, error) {
 	}
 
 	// 存储（带去重）
-	return e.storeWithDedup(extracted, turn.SessionID)
+	return e.storeWithDedup(extracted, turn.SessionID, turn.Sources)
 }
 
 // ExtractFromHistory 从多轮对话中提取记忆
@@ -125,24 +130,43 @@ func (e *Extractor) ExtractFromHistory(turns []ConversationTurn) (int, error) {
 	if len(turns) > 0 {
 		sessionID = turns[0].SessionID
 	}
-	return e.storeWithDedup(extracted, sessionID)
+	var sources []string
+	seen := make(map[string]bool)
+	for _, turn := range turns {
+		for _, id := range turn.Sources {
+			if !seen[id] {
+				seen[id] = true
+				sources = append(sources, id)
+			}
+		}
+	}
+	return e.storeWithDedup(extracted, sessionID, sources)
 }
 
-// storeWithDedup 存储提取到的记忆（跳过重复项）
-func (e *Extractor) storeWithDedup(extracted []ExtractedMemory, sessionID string) (int, error) {
+// storeWithDedup 存储提取到的记忆（跳过重复项），sources 为对话引用的文档 docid
+// 多个会话共用同一 Manager 时，读取已有记忆到写入完成之间持有提取锁
+func (e *Extractor) storeWithDedup(extracted []ExtractedMemory, sessionID string, sources []string) (int, error) {
+	e.manager.extractMu.Lock()
+	defer e.manager.extractMu.Unlock()
+
 	// 获取现有事实和偏好用于去重
 	existingFacts, _ := e.manager.GetByType(MemoryTypeFact)
 	existingPrefs, _ := e.manager.GetByType(MemoryTypePreference)
 	var existingContents []string
-	for _, m := range existingFacts {
-		existingContents = append(existingContents, strings.ToLower(m.Content))
-	}
-	for _, m := range existingPrefs {
+	for _, m := range append(existingFacts, existingPrefs...) {
+		// 设置了命名空间时只与同一空间的记忆去重
+		if ns, _ := m.Metadata[MetadataNamespace].(string); e.namespace != "" && ns != e.namespace {
+			continue
+		}
 		existingContents = append(existingContents, strings.ToLower(m.Content))
 	}
 
 	stored := 0
 	for _, mem := range extracted {
+		// PII 处理（脱敏后再去重，避免同一信息以原文和脱敏形式重复存储）
+		content, piiMatches := e.manager.applyPII(mem.Content)
+		mem.Content = content
+
 		// 去重：检查是否已存在相似内容
 		if isDuplicate(mem.Content, existingContents) {
 			continue
@@ -159,7 +183,7 @@ func (e *Extractor) storeWithDedup(extracted []ExtractedMemory, sessionID string
 		}
 
 		metadata := map[string]interface{}{
-			"source": "auto_extract",
+			"source": SourceAutoExtract,
 		}
 		if sessionID != "" {
 			metadata["session_id"] = sessionID
@@ -167,6 +191,15 @@ func (e *Extractor) storeWithDedup(extracted []ExtractedMemory, sessionID string
 		if mem.Subject != "" {
 			metadata["subject"] = mem.Subject
 		}
+		if len(sources) > 0 {
+			metadata[MetadataSourceDocs] = sources
+		}
+		if e.namespace != "" {
+			metadata[MetadataNamespace] = e.namespace
+		}
+		if len(piiMatches) > 0 {
+			metadata["pii"] = pii.Kinds(piiMatches)
+		}
 
 		m := Memory{
 			Type:       memType,
@@ -174,7 +207,7 @@ func (e *Extractor) storeWithDedup(extracted []ExtractedMemory, sessionID string
 			Metadata:   metadata,
 			Tags:       []string{"auto"},
 			Timestamp:  time.Now(),
-			Importance: 0.7,
+			Importance: e.manager.AutoImportance(memType),
 		}
 
 		if err := e.manager.Store(m); err != nil {
@@ -229,12 +229,19 @@ func (f *FactMemory) GetAllFacts() ([]Fact, error) {
 
 // SearchFacts 语义搜索事实
 func (f *FactMemory) SearchFacts(query string, limit int) ([]Fact, error) {
+	return f.searchFacts(query, limit, "", nil)
+}
+
+// searchFacts 搜索事实，namespace 非空时只搜索该命名空间，queryEmbedding 为空时按 query 生成
+func (f *FactMemory) searchFacts(query string, limit int, namespace string, queryEmbedding []float32) ([]Fact, error) {
 	opts := RecallOptions{
 		Limit:              limit,
 		MemoryTypes:        []MemoryType{MemoryTypeFact},
 		ApplyDecay:         false,
 		WeightByImportance: true,
 		MinRelevance:       0.3,
+		Namespace:          namespace,
+		QueryEmbedding:     queryEmbedding,
 	}
 
 	memories, err := f.manager.Recall(query, opts)
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/pii"
 	"github.com/dyike/mmq/pkg/store"
 )
 
@@ -23,15 +25,24 @@ const (
 
 // Memory 记忆结构
 type Memory struct {
-	ID         string
-	Type       MemoryType
-	Content    string
-	Metadata   map[string]interface{}
-	Tags       []string
-	Timestamp  time.Time
-	ExpiresAt  *time.Time
-	Importance float64 // 0.0-1.0
-	Relevance  float64 // 检索时的相关度
+	ID          string
+	Type        MemoryType
+	Content     string
+	Metadata    map[string]interface{}
+	Tags        []string
+	Timestamp   time.Time
+	ExpiresAt   *time.Time
+	Importance  float64 // 0.0-1.0
+	Relevance   float64 // 检索时的相关度
+	AccessCount int     // 被回忆的次数
+}
+
+// ListOptions 记忆列表选项
+type ListOptions struct {
+	Type   MemoryType // 为空时列出所有类型
+	SortBy string     // recency（默认）、importance、access
+	Limit  int        // 0 表示不限制
+	Offset int
 }
 
 // RecallOptions 回忆选项
@@ -39,9 +50,39 @@ type RecallOptions struct {
 	Limit              int
 	MemoryTypes        []MemoryType
 	ApplyDecay         bool
-	DecayHalflife      time.Duration
+	DecayHalflife      time.Duration                // 未配置类型时使用的半衰期
+	TypeHalflives      map[MemoryType]time.Duration // 按类型覆盖半衰期（0 表示不衰减）
 	WeightByImportance bool
 	MinRelevance       float64
+	SessionID          string  // 当前会话ID，匹配 metadata.session_id 的记忆获得加权
+	SessionBoost       float64 // 当前会话记忆的相关度乘数（0 使用管理器配置，未配置时为 DefaultSessionBoost）
+	Namespace          string  // 只回忆该命名空间的记忆（为空表示不过滤）
+
+	// QueryEmbedding 调用方已生成的查询嵌入（同一嵌入模型），为空时按 query 生成
+	QueryEmbedding []float32
+}
+
+// DefaultSessionBoost 当前会话记忆的默认相关度乘数
+const DefaultSessionBoost = 1.5
+
+// DefaultDecayHalflives 各记忆类型的默认衰减半衰期
+// 事实不衰减，对话衰减最快，情景记忆居中
+func DefaultDecayHalflives() map[MemoryType]time.Duration {
+	return map[MemoryType]time.Duration{
+		MemoryTypeConversation: 7 * 24 * time.Hour,
+		MemoryTypeEpisodic:     30 * 24 * time.Hour,
+		MemoryTypePreference:   90 * 24 * time.Hour,
+		MemoryTypeFact:         0,
+	}
+}
+
+// DecayFactor 计算给定年龄和半衰期的衰减系数（0-1），年龄每过一个半衰期减半
+// halflife <= 0 表示不衰减
+func DecayFactor(age, halflife time.Duration) float64 {
+	if halflife <= 0 || age <= 0 {
+		return 1.0
+	}
+	return math.Pow(0.5, age.Hours()/halflife.Hours())
 }
 
 // DefaultRecallOptions 默认回忆选项
@@ -58,16 +99,72 @@ func DefaultRecallOptions() RecallOptions {
 
 // Manager 记忆管理器
 type Manager struct {
-	store     *store.Store
-	embedding *llm.EmbeddingGenerator
+	store          store.MemoryStore
+	embedding      *llm.EmbeddingGenerator
+	decayHalflives map[MemoryType]time.Duration
+	sessionBoost   float64
+	piiScanner     *pii.Scanner
+	piiPolicy      pii.Policy
+
+	extractMu sync.Mutex // 串行化自动提取的去重和写入，避免并发会话写入重复记忆
 }
 
 // NewManager 创建记忆管理器
-func NewManager(st *store.Store, embedding *llm.EmbeddingGenerator) *Manager {
+func NewManager(st store.MemoryStore, embedding *llm.EmbeddingGenerator) *Manager {
 	return &Manager{
-		store:     st,
-		embedding: embedding,
+		store:          st,
+		embedding:      embedding,
+		decayHalflives: DefaultDecayHalflives(),
+	}
+}
+
+// SetDecayHalflives 设置各记忆类型的衰减半衰期（未列出的类型使用默认值）
+func (m *Manager) SetDecayHalflives(halflives map[MemoryType]time.Duration) {
+	merged := DefaultDecayHalflives()
+	for t, h := range halflives {
+		merged[t] = h
 	}
+	m.decayHalflives = merged
+}
+
+// SetSessionBoost 设置当前会话记忆的默认相关度乘数（<= 0 使用 DefaultSessionBoost）
+func (m *Manager) SetSessionBoost(boost float64) {
+	m.sessionBoost = boost
+}
+
+// DecayHalflives 返回当前生效的各类型衰减半衰期
+func (m *Manager) DecayHalflives() map[MemoryType]time.Duration {
+	result := make(map[MemoryType]time.Duration, len(m.decayHalflives))
+	for t, h := range m.decayHalflives {
+		result[t] = h
+	}
+	return result
+}
+
+// SetPIIPolicy 设置自动提取记忆时的 PII 扫描器和策略
+func (m *Manager) SetPIIPolicy(scanner *pii.Scanner, policy pii.Policy) {
+	m.piiScanner = scanner
+	m.piiPolicy = policy
+}
+
+// applyPII 按 PII 策略处理记忆内容
+func (m *Manager) applyPII(content string) (string, []pii.Match) {
+	if m.piiScanner == nil {
+		return content, nil
+	}
+	return m.piiScanner.Apply(content, m.piiPolicy)
+}
+
+// halflifeFor 返回记忆类型的衰减半衰期
+// 优先级：调用方按类型覆盖 > 管理器按类型配置 > 调用方全局半衰期
+func (m *Manager) halflifeFor(memType MemoryType, opts RecallOptions) time.Duration {
+	if h, ok := opts.TypeHalflives[memType]; ok {
+		return h
+	}
+	if h, ok := m.decayHalflives[memType]; ok {
+		return h
+	}
+	return opts.DecayHalflife
 }
 
 // Store 存储记忆
@@ -88,12 +185,25 @@ func (m *Manager) Store(mem Memory) error {
 		mem.Timestamp, mem.ExpiresAt, mem.Importance, embedding)
 }
 
+// EmbedQuery 生成查询嵌入，可通过 RecallOptions.QueryEmbedding 在多次回忆间复用
+func (m *Manager) EmbedQuery(query string) ([]float32, error) {
+	embedding, err := m.embedding.Generate(query, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+	return embedding, nil
+}
+
 // Recall 回忆记忆
 func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 	// 1. 生成查询向量
-	queryEmbedding, err := m.embedding.Generate(query, true)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	queryEmbedding := opts.QueryEmbedding
+	if queryEmbedding == nil {
+		var err error
+		queryEmbedding, err = m.EmbedQuery(query)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// 2. 向量搜索
@@ -106,15 +216,25 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 		}
 	}
 
-	results, err := m.store.SearchMemories(queryEmbedding, opts.Limit*2, memTypes)
+	candidates := opts.Limit * 2
+	if opts.Namespace != "" {
+		// 命名空间过滤在召回之后进行，多取一些候选
+		candidates = opts.Limit * 4
+	}
+	results, err := m.store.SearchMemories(queryEmbedding, candidates, memTypes)
 	if err != nil {
 		return nil, err
 	}
 
 	// 3. 转换为Memory类型
-	memories := make([]Memory, len(results))
-	for i, r := range results {
-		memories[i] = Memory{
+	memories := make([]Memory, 0, len(results))
+	for _, r := range results {
+		if opts.Namespace != "" {
+			if ns, _ := r.Metadata[MetadataNamespace].(string); ns != opts.Namespace {
+				continue
+			}
+		}
+		memories = append(memories, Memory{
 			ID:         r.ID,
 			Type:       MemoryType(r.Type),
 			Content:    r.Content,
@@ -124,12 +244,12 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 			ExpiresAt:  r.ExpiresAt,
 			Importance: r.Importance,
 			Relevance:  r.Relevance,
-		}
+		})
 	}
 
-	// 4. 应用时间衰减
+	// 4. 应用时间衰减（按类型）
 	if opts.ApplyDecay {
-		memories = m.applyTimeDecay(memories, opts.DecayHalflife)
+		memories = m.applyTimeDecay(memories, opts)
 	}
 
 	// 5. 按重要性加权
@@ -137,6 +257,11 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 		memories = m.weightByImportance(memories)
 	}
 
+	// 5.1 当前会话加权
+	if opts.SessionID != "" {
+		memories = m.boostSession(memories, opts.SessionID, opts.SessionBoost)
+	}
+
 	// 6. 重新排序
 	sort.Slice(memories, func(i, j int) bool {
 		return memories[i].Relevance > memories[j].Relevance
@@ -158,16 +283,23 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 		memories = memories[:opts.Limit]
 	}
 
+	// 9. 记录访问次数（失败不影响回忆结果）
+	ids := make([]string, len(memories))
+	for i, mem := range memories {
+		ids[i] = mem.ID
+	}
+	m.store.TouchMemories(ids)
+
 	return memories, nil
 }
 
 // applyTimeDecay 应用时间衰减
-func (m *Manager) applyTimeDecay(memories []Memory, halflife time.Duration) []Memory {
+func (m *Manager) applyTimeDecay(memories []Memory, opts RecallOptions) []Memory {
 	now := time.Now()
 
 	for i := range memories {
 		age := now.Sub(memories[i].Timestamp)
-		decayFactor := math.Exp(-age.Hours() / halflife.Hours())
+		decayFactor := DecayFactor(age, m.halflifeFor(memories[i].Type, opts))
 
 		// 调整相关性分数
 		memories[i].Relevance *= decayFactor
@@ -187,12 +319,37 @@ func (m *Manager) weightByImportance(memories []Memory) []Memory {
 	return memories
 }
 
-// Update 更新记忆
+// boostSession 提升当前会话记忆的相关度
+func (m *Manager) boostSession(memories []Memory, sessionID string, boost float64) []Memory {
+	if boost <= 0 {
+		boost = m.sessionBoost
+	}
+	if boost <= 0 {
+		boost = DefaultSessionBoost
+	}
+
+	for i := range memories {
+		if sid, ok := memories[i].Metadata["session_id"].(string); ok && sid == sessionID {
+			memories[i].Relevance *= boost
+		}
+	}
+
+	return memories
+}
+
+// Update 更新记忆；只在内容变化时重新生成嵌入
 func (m *Manager) Update(id string, mem Memory) error {
-	// 生成新的嵌入
-	embedding, err := m.embedding.Generate(mem.Content, false)
+	current, err := m.store.GetMemoryByID(id)
 	if err != nil {
-		return fmt.Errorf("failed to generate embedding: %w", err)
+		return err
+	}
+
+	var embedding []float32
+	if mem.Content != current.Content {
+		embedding, err = m.embedding.Generate(mem.Content, false)
+		if err != nil {
+			return fmt.Errorf("failed to generate embedding: %w", err)
+		}
 	}
 
 	return m.store.UpdateMemory(id, mem.Content, mem.Metadata, mem.Tags,
@@ -251,6 +408,50 @@ func (m *Manager) GetByType(memType MemoryType) ([]Memory, error) {
 	return memories, nil
 }
 
+// List 按排序方式分页列出记忆
+func (m *Manager) List(opts ListOptions) ([]Memory, error) {
+	results, err := m.store.ListMemories(store.MemoryListOptions{
+		Type:   string(opts.Type),
+		SortBy: opts.SortBy,
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memories := make([]Memory, len(results))
+	for i, r := range results {
+		memories[i] = Memory{
+			ID:          r.ID,
+			Type:        MemoryType(r.Type),
+			Content:     r.Content,
+			Metadata:    r.Metadata,
+			Tags:        r.Tags,
+			Timestamp:   r.Timestamp,
+			ExpiresAt:   r.ExpiresAt,
+			Importance:  r.Importance,
+			AccessCount: r.AccessCount,
+		}
+	}
+
+	return memories, nil
+}
+
+// CountGroupedByType 统计每种类型的记忆数量
+func (m *Manager) CountGroupedByType() (map[MemoryType]int, error) {
+	counts, err := m.store.CountMemoriesGroupedByType()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[MemoryType]int, len(counts))
+	for t, c := range counts {
+		result[MemoryType(t)] = c
+	}
+	return result, nil
+}
+
 // CleanupExpired 清理过期记忆
 func (m *Manager) CleanupExpired() (int, error) {
 	return m.store.DeleteExpiredMemories()
@@ -265,3 +466,38 @@ func (m *Manager) Count() (int, error) {
 func (m *Manager) CountByType(memType MemoryType) (int, error) {
 	return m.store.CountMemoriesByType(string(memType))
 }
+
+// CountMissingEmbeddings 统计缺少嵌入向量的记忆数量
+func (m *Manager) CountMissingEmbeddings() (int, error) {
+	return m.store.CountMemoriesMissingEmbedding()
+}
+
+// Reembed 为缺少嵌入向量的记忆补齐嵌入
+// all 为 true 时重新生成所有记忆的嵌入（如更换了嵌入模型）
+func (m *Manager) Reembed(all bool) (int, error) {
+	var results []store.MemoryResult
+	var err error
+	if all {
+		results, err = m.store.GetAllMemories()
+	} else {
+		results, err = m.store.GetMemoriesMissingEmbedding()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get memories: %w", err)
+	}
+
+	count := 0
+	for _, r := range results {
+		embedding, err := m.embedding.Generate(r.Content, false)
+		if err != nil {
+			return count, fmt.Errorf("failed to generate embedding for memory %s: %w", r.ID, err)
+		}
+
+		if err := m.store.UpdateMemoryEmbedding(r.ID, embedding); err != nil {
+			return count, fmt.Errorf("failed to update embedding for memory %s: %w", r.ID, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
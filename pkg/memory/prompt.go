@@ -14,6 +14,22 @@ type PromptBuilder struct {
 	recencyK  int // 最近 K 轮对话
 	factTopK  int // 最相关的 K 条事实
 	maxMemLen int // 记忆部分最大字符数
+
+	sanitizeLevel rag.SanitizeLevel  // 注入内容的过滤级别
+	lastReport    rag.SanitizeReport // 最近一次组装时的过滤报告
+
+	instructions string // 替换默认的开头说明（人设）
+	namespace    string // 只回忆该命名空间的事实和记忆
+
+	prepared *preparedSections // Prepare 提前组装的部分，下一次组装时使用
+}
+
+// preparedSections system prompt 中与用户问题无关、可以提前组装的部分
+type preparedSections struct {
+	sessionID string
+	history   string // 对话记忆部分（没有时为空）
+	prefs     string // 用户偏好部分（没有时为空）
+	report    rag.SanitizeReport
 }
 
 // NewPromptBuilder 创建 PromptBuilder
@@ -23,6 +39,8 @@ func NewPromptBuilder(manager *Manager) *PromptBuilder {
 		recencyK:  5,
 		factTopK:  10,
 		maxMemLen: 2000,
+
+		sanitizeLevel: rag.SanitizeStrip,
 	}
 }
 
@@ -32,17 +50,75 @@ func (b *PromptBuilder) SetRecencyK(k int) { b.recencyK = k }
 // SetFactTopK 设置相关事实数量
 func (b *PromptBuilder) SetFactTopK(k int) { b.factTopK = k }
 
-// BuildSystemPrompt 组装包含记忆的 system prompt
-func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ragContexts []rag.Context) string {
-	var parts []string
+// SetSanitizeLevel 设置记忆和文档注入前的过滤级别
+func (b *PromptBuilder) SetSanitizeLevel(level rag.SanitizeLevel) { b.sanitizeLevel = level }
 
-	parts = append(parts, `你是一个通用智能助手。请根据用户的实际问题来回答。
+// SetInstructions 替换 system prompt 开头的默认说明（为空恢复默认）
+func (b *PromptBuilder) SetInstructions(instructions string) { b.instructions = instructions }
+
+// SetNamespace 设置记忆命名空间，事实和记忆召回只使用该空间（用户偏好仍共享）
+func (b *PromptBuilder) SetNamespace(namespace string) { b.namespace = namespace }
+
+// LastSanitizeReport 返回最近一次 BuildSystemPrompt 的过滤报告
+func (b *PromptBuilder) LastSanitizeReport() rag.SanitizeReport { return b.lastReport }
+
+// sanitize 过滤将注入 prompt 的内容并累计报告
+func (b *PromptBuilder) sanitize(s string) string {
+	clean, report := rag.Sanitize(s, b.sanitizeLevel)
+	b.lastReport.Merge(report)
+	return clean
+}
+
+// defaultInstructions 默认的 system prompt 开头说明
+const defaultInstructions = `你是一个通用智能助手。请根据用户的实际问题来回答。
 注意事项：
 - 如果用户没有告诉你他的名字，你不知道他叫什么，请如实回答"我不知道"
 - 下方的"记忆"和"文档"仅供参考，不要从中推断用户的身份信息
-- 只在用户问题与文档内容相关时才引用文档，否则正常对话即可`)
+- 只在用户问题与文档内容相关时才引用文档，否则正常对话即可`
 
-	// 1. 对话历史
+// PromptVars system prompt 模板变量的取值
+type PromptVars struct {
+	Date        time.Time // {{date}}：当天日期
+	Pinned      []string  // {{pinned}}：会话固定的文档（collection/path）
+	Collections []string  // {{collections}}：可检索的集合
+}
+
+// ExpandPrompt 替换自定义 system prompt 中的 {{date}}、{{pinned}} 和 {{collections}}，列表为空时替换为 (none)
+// 其他 {{...}} 原样保留
+func ExpandPrompt(prompt string, vars PromptVars) string {
+	if !strings.Contains(prompt, "{{") {
+		return prompt
+	}
+	return strings.NewReplacer(
+		"{{date}}", vars.Date.Format("2006-01-02"),
+		"{{pinned}}", promptList(vars.Pinned),
+		"{{collections}}", promptList(vars.Collections),
+	).Replace(prompt)
+}
+
+// promptList 模板中的列表值
+func promptList(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+	return strings.Join(items, ", ")
+}
+
+// Prepare 提前组装对话记忆和用户偏好部分（如在等待用户输入时），下一次 BuildSystemPrompt 直接使用
+func (b *PromptBuilder) Prepare(sessionID string) {
+	b.prepared = b.staticSections(sessionID)
+}
+
+// staticSections 组装与用户问题无关的部分：对话记忆和用户偏好
+func (b *PromptBuilder) staticSections(sessionID string) *preparedSections {
+	p := &preparedSections{sessionID: sessionID}
+	sanitize := func(s string) string {
+		clean, report := rag.Sanitize(s, b.sanitizeLevel)
+		p.report.Merge(report)
+		return clean
+	}
+
+	// 对话历史
 	if sessionID != "" {
 		convMem := NewConversationMemory(b.manager)
 		history, err := convMem.GetHistory(sessionID, b.recencyK)
@@ -51,40 +127,79 @@ func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ra
 			for _, turn := range history {
 				convLines = append(convLines, fmt.Sprintf("用户: %s\n助手: %s", turn.User, turn.Assistant))
 			}
-			historyText := strings.Join(convLines, "\n---\n")
+			historyText := sanitize(strings.Join(convLines, "\n---\n"))
 			if len(historyText) > b.maxMemLen/2 {
 				historyText = historyText[:b.maxMemLen/2] + "..."
 			}
-			parts = append(parts, fmt.Sprintf("\n[对话记忆（最近%d轮）]\n%s", len(history), historyText))
+			p.history = fmt.Sprintf("\n[对话记忆（最近%d轮）]\n%s", len(history), historyText)
+		}
+	}
+
+	// 用户偏好
+	prefMem := NewPreferenceMemory(b.manager)
+	allPrefs, err := prefMem.GetAllPreferences()
+	if err == nil && len(allPrefs) > 0 {
+		var prefLines []string
+		for cat, kvs := range allPrefs {
+			for k, v := range kvs {
+				prefLines = append(prefLines, sanitize(fmt.Sprintf("- %s.%s = %v", cat, k, v)))
+			}
 		}
+		if len(prefLines) > 0 {
+			p.prefs = fmt.Sprintf("\n[用户偏好]\n%s", strings.Join(prefLines, "\n"))
+		}
+	}
+	return p
+}
+
+// BuildSystemPrompt 组装包含记忆的 system prompt
+func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ragContexts []rag.Context) string {
+	return b.build(sessionID, userQuery, nil, ragContexts)
+}
+
+// build 组装 system prompt；queryEmbedding 为 userQuery 已生成的嵌入，为空时生成一次，供事实和记忆召回共用
+func (b *PromptBuilder) build(sessionID string, userQuery string, queryEmbedding []float32, ragContexts []rag.Context) string {
+	var parts []string
+
+	static := b.prepared
+	b.prepared = nil
+	if static == nil || static.sessionID != sessionID {
+		static = b.staticSections(sessionID)
+	}
+	b.lastReport = static.report
+
+	instructions := b.instructions
+	if instructions == "" {
+		instructions = defaultInstructions
+	}
+	parts = append(parts, instructions)
+
+	// 1. 对话历史
+	if static.history != "" {
+		parts = append(parts, static.history)
+	}
+
+	if userQuery != "" && queryEmbedding == nil {
+		// 生成失败时由各次召回自行生成（并各自报错）
+		queryEmbedding, _ = b.manager.EmbedQuery(userQuery)
 	}
 
 	// 2. 相关事实
 	if userQuery != "" {
 		factMem := NewFactMemory(b.manager)
-		facts, err := factMem.SearchFacts(userQuery, b.factTopK)
+		facts, err := factMem.searchFacts(userQuery, b.factTopK, b.namespace, queryEmbedding)
 		if err == nil && len(facts) > 0 {
 			var factLines []string
 			for _, f := range facts {
-				factLines = append(factLines, fmt.Sprintf("- %s %s %s", f.Subject, f.Predicate, f.Object))
+				factLines = append(factLines, b.sanitize(fmt.Sprintf("- %s %s %s", f.Subject, f.Predicate, f.Object)))
 			}
 			parts = append(parts, fmt.Sprintf("\n[已知事实]\n%s", strings.Join(factLines, "\n")))
 		}
 	}
 
 	// 3. 用户偏好
-	prefMem := NewPreferenceMemory(b.manager)
-	allPrefs, err := prefMem.GetAllPreferences()
-	if err == nil && len(allPrefs) > 0 {
-		var prefLines []string
-		for cat, kvs := range allPrefs {
-			for k, v := range kvs {
-				prefLines = append(prefLines, fmt.Sprintf("- %s.%s = %v", cat, k, v))
-			}
-		}
-		if len(prefLines) > 0 {
-			parts = append(parts, fmt.Sprintf("\n[用户偏好]\n%s", strings.Join(prefLines, "\n")))
-		}
+	if static.prefs != "" {
+		parts = append(parts, static.prefs)
 	}
 
 	// 4. 通用记忆召回（补充事实和偏好以外的记忆）
@@ -96,11 +211,14 @@ func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ra
 			DecayHalflife:      30 * 24 * time.Hour,
 			WeightByImportance: true,
 			MinRelevance:       0.3,
+			SessionID:          sessionID,
+			Namespace:          b.namespace,
+			QueryEmbedding:     queryEmbedding,
 		})
 		if err == nil && len(memories) > 0 {
 			var memLines []string
 			for _, mem := range memories {
-				memLines = append(memLines, fmt.Sprintf("- [%s] %s", mem.Type, truncateStr(mem.Content, 100)))
+				memLines = append(memLines, fmt.Sprintf("- [%s] %s", mem.Type, truncateStr(b.sanitize(mem.Content), 100)))
 			}
 			parts = append(parts, fmt.Sprintf("\n[相关记忆]\n%s", strings.Join(memLines, "\n")))
 		}
@@ -114,7 +232,7 @@ func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ra
 			if ctx.Relevance < 0.3 {
 				continue
 			}
-			snippet := truncateStr(ctx.Text, 500)
+			snippet := truncateStr(b.sanitize(ctx.Text), ContextSnippetLimit(ctx))
 			ragLines = append(ragLines, fmt.Sprintf("[%d] (来源: %s, 相关度: %.2f)\n%s", i+1, ctx.Source, ctx.Relevance, snippet))
 		}
 		if len(ragLines) > 0 {
@@ -125,6 +243,20 @@ func (b *PromptBuilder) BuildSystemPrompt(sessionID string, userQuery string, ra
 	return strings.Join(parts, "\n")
 }
 
+// 注入 prompt 的文档片段长度（字符）
+const (
+	ragSnippetLimit    = 500
+	pinnedSnippetLimit = 4000 // 用户指定的文档（Session.PinDoc、InjectDoc）
+)
+
+// ContextSnippetLimit 文档上下文注入 prompt 时截断的长度，用户指定的文档（Metadata pinned）保留更多内容
+func ContextSnippetLimit(ctx rag.Context) int {
+	if pinned, _ := ctx.Metadata["pinned"].(bool); pinned {
+		return pinnedSnippetLimit
+	}
+	return ragSnippetLimit
+}
+
 func truncateStr(s string, maxLen int) string {
 	runes := []rune(s)
 	if len(runes) <= maxLen {
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReviewAction 记忆审阅动作
+type ReviewAction string
+
+const (
+	// ReviewKeep 保留记忆
+	ReviewKeep ReviewAction = "keep"
+	// ReviewEdit 修改后保留
+	ReviewEdit ReviewAction = "edit"
+	// ReviewDelete 删除记忆
+	ReviewDelete ReviewAction = "delete"
+)
+
+// SourceAutoExtract 自动提取记忆的来源标记
+const SourceAutoExtract = "auto_extract"
+
+// MetadataSourceDocs 元数据中记录来源文档 docid 列表的键
+const MetadataSourceDocs = "source_docs"
+
+// MetadataNamespace 元数据中记录记忆命名空间的键（人设隔离记忆时使用）
+const MetadataNamespace = "namespace"
+
+const (
+	// defaultAutoImportance 无审阅反馈时自动提取记忆的重要性
+	defaultAutoImportance = 0.7
+	// feedbackPriorWeight 先验权重，反馈较少时重要性靠近默认值
+	feedbackPriorWeight = 4.0
+)
+
+// PendingReview 获取尚未审阅的自动提取记忆
+func (m *Manager) PendingReview(limit int) ([]Memory, error) {
+	results, err := m.store.GetUnreviewedMemories(SourceAutoExtract, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	memories := make([]Memory, len(results))
+	for i, r := range results {
+		memories[i] = Memory{
+			ID:         r.ID,
+			Type:       MemoryType(r.Type),
+			Content:    r.Content,
+			Metadata:   r.Metadata,
+			Tags:       r.Tags,
+			Timestamp:  r.Timestamp,
+			ExpiresAt:  r.ExpiresAt,
+			Importance: r.Importance,
+		}
+	}
+
+	return memories, nil
+}
+
+// Review 应用审阅结果并记录反馈
+// edit 时使用 mem.Content 作为新内容；keep/edit 会标记为已审阅，delete 删除记忆
+func (m *Manager) Review(mem Memory, action ReviewAction) error {
+	switch action {
+	case ReviewKeep, ReviewEdit:
+		if mem.Metadata == nil {
+			mem.Metadata = make(map[string]interface{})
+		}
+		mem.Metadata["reviewed"] = time.Now().Format(time.RFC3339)
+
+		var err error
+		if action == ReviewEdit {
+			err = m.Update(mem.ID, mem)
+		} else {
+			err = m.store.UpdateMemoryMetadata(mem.ID, mem.Metadata)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update memory: %w", err)
+		}
+	case ReviewDelete:
+		if err := m.Delete(mem.ID); err != nil {
+			return fmt.Errorf("failed to delete memory: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown review action: %s", action)
+	}
+
+	return m.store.InsertMemoryFeedback(string(mem.Type), string(action))
+}
+
+// AutoImportance 根据审阅反馈计算自动提取记忆的重要性
+// 保留率越高重要性越高；反馈较少时接近默认值 0.7
+func (m *Manager) AutoImportance(memType MemoryType) float64 {
+	counts, err := m.store.CountMemoryFeedback(string(memType))
+	if err != nil {
+		return defaultAutoImportance
+	}
+
+	kept := float64(counts[string(ReviewKeep)] + counts[string(ReviewEdit)])
+	total := kept + float64(counts[string(ReviewDelete)])
+
+	importance := (kept + defaultAutoImportance*feedbackPriorWeight) / (total + feedbackPriorWeight)
+	if importance < 0.1 {
+		importance = 0.1
+	}
+	return importance
+}
@@ -0,0 +1,452 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// DefaultMaxSessionMessages 会话保留在上下文中的消息数（最近 10 轮）
+const DefaultMaxSessionMessages = 20
+
+// DefaultTopicShiftThreshold 相邻两条用户消息嵌入的余弦距离超过该值视为换了话题
+const DefaultTopicShiftThreshold = 0.5
+
+// Session 一个对话会话的独立状态：消息历史、prompt 组装器和记忆提取器
+// 多个会话共用同一 Manager，各自的历史、人设命名空间和过滤报告互不影响
+type Session struct {
+	ID string
+
+	mu          sync.Mutex
+	messages    []llm.ChatMessage
+	maxMessages int
+	builder     *PromptBuilder
+	extractor   *Extractor
+	pinned      []string // 固定的文档（collection/path 或 docid），每轮都注入 RAG 上下文
+	injected    []string // 只注入下一轮的文档
+	lastUsed    time.Time
+
+	manager        *Manager
+	topic          []float32 // 上一条用户消息的嵌入
+	topicThreshold float64   // 话题切换的余弦距离阈值（<= 0 不检测）
+	sources        []string  // 上一轮注入的文档 docid
+
+	queryText   string    // 最近一次生成嵌入的用户消息
+	queryVector []float32 // queryText 的嵌入，话题检测、记忆召回和检索共用
+	generation  int       // 已组装的 system prompt 数，用于丢弃过期的 Prepare 结果
+}
+
+// NewSession 创建会话，apiClient 为 nil 时不自动提取记忆
+func NewSession(id string, manager *Manager, apiClient *llm.APIClient) *Session {
+	return &Session{
+		ID:          id,
+		maxMessages: DefaultMaxSessionMessages,
+		builder:     NewPromptBuilder(manager),
+		extractor:   NewExtractor(apiClient, manager),
+		lastUsed:    time.Now(),
+
+		manager:        manager,
+		topicThreshold: DefaultTopicShiftThreshold,
+	}
+}
+
+// SetMaxMessages 设置保留的消息数（<= 0 不限制）
+func (s *Session) SetMaxMessages(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxMessages = n
+	s.trim()
+}
+
+// SetNamespace 设置会话的记忆命名空间（召回和提取都使用该空间）
+func (s *Session) SetNamespace(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builder.SetNamespace(namespace)
+	s.extractor.SetNamespace(namespace)
+}
+
+// SetInstructions 替换会话 system prompt 的开头说明（人设）
+func (s *Session) SetInstructions(instructions string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builder.SetInstructions(instructions)
+}
+
+// SetSanitizeLevel 设置注入内容的过滤级别
+func (s *Session) SetSanitizeLevel(level rag.SanitizeLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builder.SetSanitizeLevel(level)
+}
+
+// Messages 返回消息历史的副本
+func (s *Session) Messages() []llm.ChatMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	return append([]llm.ChatMessage(nil), s.messages...)
+}
+
+// AppendMessages 追加消息并只保留最近的 maxMessages 条
+func (s *Session) AppendMessages(messages ...llm.ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, messages...)
+	s.trim()
+	s.lastUsed = time.Now()
+}
+
+// ClearMessages 清空消息历史和话题状态（已存储的对话记忆不受影响）
+func (s *Session) ClearMessages() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	s.topic = nil
+	s.sources = nil
+	s.lastUsed = time.Now()
+}
+
+// PinDoc 把文档固定到会话，之后每轮都注入 RAG 上下文，不受检索分数影响；已固定时返回 false
+// doc 由调用方解析，建议用 collection/path（docid 随内容变化）
+func (s *Session) PinDoc(doc string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	for _, id := range s.pinned {
+		if id == doc {
+			return false
+		}
+	}
+	s.pinned = append(s.pinned, doc)
+	return true
+}
+
+// UnpinDoc 取消固定文档，返回文档是否曾被固定
+func (s *Session) UnpinDoc(doc string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	for i, id := range s.pinned {
+		if id == doc {
+			s.pinned = append(s.pinned[:i:i], s.pinned[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearPins 取消固定所有文档，返回取消的数量
+func (s *Session) ClearPins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.pinned)
+	s.pinned = nil
+	return n
+}
+
+// PinnedDocs 返回固定的文档（按固定顺序）
+func (s *Session) PinnedDocs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.pinned...)
+}
+
+// InjectDoc 把文档加入下一轮的 RAG 上下文（只生效一轮）
+func (s *Session) InjectDoc(doc string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	for _, id := range s.injected {
+		if id == doc {
+			return
+		}
+	}
+	s.injected = append(s.injected, doc)
+}
+
+// TakeInjectedDocs 取出并清空下一轮要注入的文档
+func (s *Session) TakeInjectedDocs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := s.injected
+	s.injected = nil
+	return docs
+}
+
+// SetTopicShiftThreshold 设置话题切换的余弦距离阈值（<= 0 不检测）
+func (s *Session) SetTopicShiftThreshold(threshold float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topicThreshold = threshold
+}
+
+// DetectTopicShift 比较用户消息与上一条消息的嵌入，距离超过阈值时返回 true
+// 第一条消息、未启用检测或没有嵌入模型时返回 false；嵌入失败时保留上一条消息的嵌入
+func (s *Session) DetectTopicShift(input string) (bool, error) {
+	s.mu.Lock()
+	threshold := s.topicThreshold
+	s.mu.Unlock()
+	if threshold <= 0 || s.manager == nil || s.manager.embedding == nil {
+		return false, nil
+	}
+
+	embedding, err := s.EmbedQuery(input)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.topic
+	s.topic = embedding
+	return previous != nil && cosineDistance(previous, embedding) > threshold, nil
+}
+
+// EmbedQuery 生成用户消息的查询嵌入并缓存，同一消息再次调用（记忆召回、检索）时直接复用
+func (s *Session) EmbedQuery(input string) ([]float32, error) {
+	if cached := s.QueryEmbedding(input); cached != nil {
+		return cached, nil
+	}
+	if s.manager == nil || s.manager.embedding == nil {
+		return nil, fmt.Errorf("no embedding model")
+	}
+
+	// 嵌入调用不持有会话锁
+	embedding, err := s.manager.EmbedQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queryText, s.queryVector = input, embedding
+	return embedding, nil
+}
+
+// QueryEmbedding 返回 EmbedQuery 为该消息缓存的嵌入，没有时返回 nil
+func (s *Session) QueryEmbedding(input string) []float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queryVector == nil || s.queryText != input {
+		return nil
+	}
+	return s.queryVector
+}
+
+// Prepare 提前组装 system prompt 中与问题无关的部分（对话记忆、用户偏好），应在存储本轮对话后、等待下一条消息时调用
+// 下一次 BuildSystemPrompt 直接使用，缩短首字延迟；组装期间已开始新一轮时丢弃结果
+func (s *Session) Prepare() {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+	s.prepare(generation, false)
+}
+
+// prepare 按 generation 时的会话状态提前组装，会话已组装过新的 prompt 时丢弃
+// refresh 为 true 时只重新组装已有的提前组装结果
+func (s *Session) prepare(generation int, refresh bool) {
+	s.mu.Lock()
+	if s.generation != generation || (refresh && s.builder.prepared == nil) {
+		s.mu.Unlock()
+		return
+	}
+	builder := *s.builder
+	s.mu.Unlock()
+
+	// 读取记忆不持有会话锁
+	prepared := builder.staticSections(s.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation == generation {
+		s.builder.prepared = prepared
+	}
+}
+
+// RecordSources 记录本轮注入的文档 docid，返回上一轮没有的文档数
+func (s *Session) RecordSources(docIDs []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]bool, len(s.sources))
+	for _, id := range s.sources {
+		previous[id] = true
+	}
+	fresh := 0
+	for _, id := range docIDs {
+		if !previous[id] {
+			fresh++
+		}
+	}
+	s.sources = append([]string(nil), docIDs...)
+	return fresh
+}
+
+// trim 丢弃超出上限的旧消息，调用方持有锁
+func (s *Session) trim() {
+	if s.maxMessages > 0 && len(s.messages) > s.maxMessages {
+		s.messages = append([]llm.ChatMessage(nil), s.messages[len(s.messages)-s.maxMessages:]...)
+	}
+}
+
+// BuildSystemPrompt 组装包含记忆的 system prompt，并返回本次的过滤报告
+func (s *Session) BuildSystemPrompt(userQuery string, ragContexts []rag.Context) (string, rag.SanitizeReport) {
+	queryEmbedding := s.QueryEmbedding(userQuery)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	s.generation++
+	prompt := s.builder.build(s.ID, userQuery, queryEmbedding, ragContexts)
+	return prompt, s.builder.LastSanitizeReport()
+}
+
+// Extract 从一轮对话中提取记忆；LLM 调用不持有会话锁，写入由 Manager 串行化
+func (s *Session) Extract(turn ConversationTurn) (int, error) {
+	s.mu.Lock()
+	extractor := *s.extractor
+	s.lastUsed = time.Now()
+	generation := s.generation
+	s.mu.Unlock()
+
+	if turn.SessionID == "" {
+		turn.SessionID = s.ID
+	}
+	n, err := extractor.ExtractFromTurn(turn)
+	if n > 0 {
+		// 新提取的偏好加入已提前组装的部分
+		s.prepare(generation, true)
+	}
+	return n, err
+}
+
+// LastUsed 最近一次使用时间
+func (s *Session) LastUsed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed
+}
+
+// SessionRegistry 并发会话注册表，按 ID 复用会话并回收空闲会话
+type SessionRegistry struct {
+	manager   *Manager
+	apiClient *llm.APIClient
+	idleTTL   time.Duration
+	setup     func(*Session)
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionRegistry 创建会话注册表，idleTTL <= 0 表示不回收空闲会话
+func NewSessionRegistry(manager *Manager, apiClient *llm.APIClient, idleTTL time.Duration) *SessionRegistry {
+	return &SessionRegistry{
+		manager:   manager,
+		apiClient: apiClient,
+		idleTTL:   idleTTL,
+		sessions:  make(map[string]*Session),
+	}
+}
+
+// SetSetup 设置新会话的初始化函数（人设、过滤级别等）
+func (r *SessionRegistry) SetSetup(setup func(*Session)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setup = setup
+}
+
+// Open 返回指定 ID 的会话，不存在时创建
+func (r *SessionRegistry) Open(id string) *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.sessions[id]; ok {
+		s.mu.Lock()
+		s.lastUsed = time.Now()
+		s.mu.Unlock()
+		return s
+	}
+	s := NewSession(id, r.manager, r.apiClient)
+	if r.setup != nil {
+		r.setup(s)
+	}
+	r.sessions[id] = s
+	return s
+}
+
+// Get 返回已打开的会话
+func (r *SessionRegistry) Get(id string) (*Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	return s, ok
+}
+
+// Close 关闭会话，返回会话是否存在
+func (r *SessionRegistry) Close(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.sessions[id]
+	delete(r.sessions, id)
+	return ok
+}
+
+// IDs 返回已打开会话的 ID（按字母序）
+func (r *SessionRegistry) IDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.sessions))
+	for id := range r.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Expire 关闭空闲超过 idleTTL 的会话，返回被关闭的会话 ID
+func (r *SessionRegistry) Expire() []string {
+	if r.idleTTL <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-r.idleTTL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var expired []string
+	for id, s := range r.sessions {
+		if s.LastUsed().Before(cutoff) {
+			delete(r.sessions, id)
+			expired = append(expired, id)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// StartExpiry 在后台按 interval 定期回收空闲会话，返回停止函数
+func (r *SessionRegistry) StartExpiry(interval time.Duration) (stop func()) {
+	if r.idleTTL <= 0 || interval <= 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.Expire()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
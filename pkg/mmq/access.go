@@ -0,0 +1,79 @@
+package mmq
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// RecentDocument 最近查看过的文档
+type RecentDocument struct {
+	ID             int       `json:"id"`
+	DocID          string    `json:"docid"`
+	Collection     string    `json:"collection"`
+	Path           string    `json:"path"`
+	Title          string    `json:"title"`
+	AccessCount    int       `json:"access_count"`
+	LastAccessedAt time.Time `json:"last_accessed_at"`
+}
+
+// RecordDocumentAccess 记录文档被查看一次（get 或对话引用），供 RecentDocuments 和查看加成使用
+func (m *MMQ) RecordDocumentAccess(collection, path string) error {
+	return m.store.RecordDocumentAccess(collection, path)
+}
+
+// RecentDocuments 返回最近查看过的文档，最近的在前；collection 为空时不限集合
+func (m *MMQ) RecentDocuments(collection string, limit int) ([]RecentDocument, error) {
+	list, err := m.store.RecentDocuments(collection, limit)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]RecentDocument, len(list))
+	for i, d := range list {
+		docs[i] = RecentDocument{
+			ID:             d.ID,
+			DocID:          shortDocID(d.Hash),
+			Collection:     d.Collection,
+			Path:           d.Path,
+			Title:          d.Title,
+			AccessCount:    d.AccessCount,
+			LastAccessedAt: d.LastAccessedAt,
+		}
+	}
+	return docs, nil
+}
+
+// ClearDocumentAccess 清除所有文档的查看记录，返回清除的文档数
+func (m *MMQ) ClearDocumentAccess() (int64, error) {
+	return m.store.ClearDocumentAccess()
+}
+
+// boostAccessed 按最近查看时间提升结果分数并重新排序（配置 AccessBoost 为 0 时不变）
+// 加成按 AccessHalflife 指数衰减，超过 8 个半衰期（不足 0.4%）的查看忽略
+func (m *MMQ) boostAccessed(results []SearchResult) []SearchResult {
+	boost, halflife := m.cfg.AccessBoost, m.cfg.AccessHalflife
+	if boost <= 0 || halflife <= 0 || len(results) == 0 {
+		return results
+	}
+	now := time.Now()
+	accessed, err := m.store.DocumentAccessTimes(now.Add(-8 * halflife))
+	if err != nil || len(accessed) == 0 {
+		return results // 读取失败不影响搜索
+	}
+
+	for i, r := range results {
+		t, ok := accessed[r.Collection+"/"+r.Path]
+		if !ok {
+			continue
+		}
+		age := now.Sub(t)
+		if age < 0 {
+			age = 0
+		}
+		results[i].Score = r.Score * (1 + boost*math.Exp2(-float64(age)/float64(halflife)))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
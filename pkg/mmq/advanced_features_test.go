@@ -4,18 +4,24 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
+	"sync"
 	"testing"
 
 	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/memory"
 	"github.com/dyike/mmq/pkg/rag"
 	"github.com/dyike/mmq/pkg/store"
 )
 
 // testLLM 测试用的 LLM 实现（仅用于单元测试）
+// 检索的各路并发调用同一实例，与真实后端一样需要并发安全
 type testLLM struct {
 	dimensions int
-	loaded     map[llm.ModelType]bool
+
+	mu     sync.Mutex
+	loaded map[llm.ModelType]bool
 }
 
 func newTestLLM(dimensions int) *testLLM {
@@ -29,7 +35,7 @@ func (m *testLLM) Embed(text string, isQuery bool) ([]float32, error) {
 	if text == "" {
 		return nil, fmt.Errorf("empty text")
 	}
-	m.loaded[llm.ModelTypeEmbedding] = true
+	m.markLoaded(llm.ModelTypeEmbedding)
 
 	embedding := make([]float32, m.dimensions)
 	seed := uint32(0)
@@ -68,7 +74,7 @@ func (m *testLLM) EmbedBatch(texts []string, isQuery bool) ([][]float32, error)
 }
 
 func (m *testLLM) Rerank(query string, docs []llm.Document) ([]llm.RerankResult, error) {
-	m.loaded[llm.ModelTypeRerank] = true
+	m.markLoaded(llm.ModelTypeRerank)
 	results := make([]llm.RerankResult, len(docs))
 
 	queryWords := splitTestWords(query)
@@ -97,12 +103,12 @@ func (m *testLLM) Rerank(query string, docs []llm.Document) ([]llm.RerankResult,
 }
 
 func (m *testLLM) Generate(prompt string, opts llm.GenerateOptions) (string, error) {
-	m.loaded[llm.ModelTypeGenerate] = true
+	m.markLoaded(llm.ModelTypeGenerate)
 	return fmt.Sprintf("Test generated response for: %s", prompt), nil
 }
 
 func (m *testLLM) ExpandQuery(query string) ([]llm.QueryExpansion, error) {
-	m.loaded[llm.ModelTypeGenerate] = true
+	m.markLoaded(llm.ModelTypeGenerate)
 	return []llm.QueryExpansion{
 		{Type: "lex", Text: query, Weight: 1.0},
 		{Type: "vec", Text: query + " explanation", Weight: 0.8},
@@ -110,17 +116,59 @@ func (m *testLLM) ExpandQuery(query string) ([]llm.QueryExpansion, error) {
 	}, nil
 }
 
+// markLoaded 记录模型已被使用
+func (m *testLLM) markLoaded(modelType llm.ModelType) {
+	m.mu.Lock()
+	m.loaded[modelType] = true
+	m.mu.Unlock()
+}
+
 func (m *testLLM) Close() error {
+	m.mu.Lock()
 	m.loaded = make(map[llm.ModelType]bool)
+	m.mu.Unlock()
 	return nil
 }
 
 func (m *testLLM) IsLoaded(modelType llm.ModelType) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.loaded[modelType]
 }
 
 func (m *testLLM) SetModelPath(modelType llm.ModelType, path string) {}
 
+// newTestMMQ 使用测试 LLM 构建 MMQ 实例（不依赖本地推理库）
+func newTestMMQ(t *testing.T) *MMQ {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.CacheDir = t.TempDir()
+
+	st, err := store.New(cfg.DBPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	tLLM := newTestLLM(300)
+	embGen := llm.NewEmbeddingGenerator(tLLM, "test-embed", 300)
+
+	m := &MMQ{
+		store:         st,
+		contexts:      st,
+		llm:           tLLM,
+		embedding:     embGen,
+		retriever:     rag.NewRetriever(st, tLLM, embGen),
+		memoryManager: memory.NewManager(st, embGen),
+		cfg:           cfg,
+	}
+	m.retriever.SetEmbedderResolver(m.embedderFor)
+	t.Cleanup(func() { m.Close() })
+
+	return m
+}
+
 // splitTestWords 简单分词（测试用）
 func splitTestWords(text string) []string {
 	var words []string
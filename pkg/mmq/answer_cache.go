@@ -0,0 +1,82 @@
+package mmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+	"github.com/dyike/mmq/pkg/vectordb"
+)
+
+// CachedAnswer 命中的缓存回答
+type CachedAnswer struct {
+	Question      string    `json:"question"` // 缓存时的原始问题
+	Answer        string    `json:"answer"`
+	Similarity    float64   `json:"similarity"` // 与当前问题的余弦相似度
+	CorpusVersion int64     `json:"corpus_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LookupAnswer 查找与 question 语义相近、同一范围且语料版本未变的缓存回答
+// scope 区分影响回答的设置（模型、人设等）；未命中返回 nil
+func (m *MMQ) LookupAnswer(question, scope string) (*CachedAnswer, error) {
+	version, err := m.store.CorpusVersion()
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := m.store.AnswerCandidates(scope, version, time.Now().Add(-m.cfg.AnswerCacheTTL))
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	vec, err := m.embedding.Generate(question, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	var best *store.AnswerCacheEntry
+	bestSim := m.cfg.AnswerCacheThreshold
+	for i := range candidates {
+		sim, err := vectordb.CosineSim(vec, candidates[i].Embedding)
+		if err != nil || sim < bestSim {
+			continue
+		}
+		best, bestSim = &candidates[i], sim
+	}
+	if best == nil {
+		return nil, nil
+	}
+	if err := m.store.RecordAnswerHit(best.ID); err != nil {
+		return nil, err
+	}
+	return &CachedAnswer{
+		Question:      best.Question,
+		Answer:        best.Answer,
+		Similarity:    bestSim,
+		CorpusVersion: version,
+		CreatedAt:     best.CreatedAt,
+	}, nil
+}
+
+// CacheAnswer 缓存问题的回答，供之后的相似问题复用
+func (m *MMQ) CacheAnswer(question, scope, answer string) error {
+	version, err := m.store.CorpusVersion()
+	if err != nil {
+		return err
+	}
+	vec, err := m.embedding.Generate(question, true)
+	if err != nil {
+		return fmt.Errorf("failed to embed question: %w", err)
+	}
+	return m.store.PutAnswer(store.AnswerCacheEntry{
+		Scope:         scope,
+		CorpusVersion: version,
+		Question:      question,
+		Embedding:     vec,
+		Answer:        answer,
+	}, m.cfg.AnswerCacheMaxEntries)
+}
+
+// ClearAnswerCache 清空回答缓存，返回删除的条目数
+func (m *MMQ) ClearAnswerCache() (int, error) {
+	return m.store.ClearAnswerCache()
+}
@@ -0,0 +1,57 @@
+package mmq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// BatchResult 批量查询中一条查询的结果
+type BatchResult struct {
+	Index   int            `json:"index"` // 查询在输入中的序号（从 1 开始）
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+	Error   string         `json:"error,omitempty"`
+	Took    time.Duration  `json:"took"`
+}
+
+// ReadQueries 读取查询文件：每行一条查询，忽略空行和 # 开头的注释行
+func ReadQueries(r io.Reader) ([]string, error) {
+	var queries []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+	return queries, nil
+}
+
+// BatchSearch 用同一组选项依次执行多条查询，每完成一条调用一次 fn
+// 模型和缓存在查询之间共享；单条查询失败记录在 BatchResult.Error 中，不中断批量，
+// fn 返回错误时停止
+func (m *MMQ) BatchSearch(queries []string, opts SearchOptions, fn func(BatchResult) error) error {
+	for i, q := range queries {
+		start := time.Now()
+		results, err := m.Search(q, opts)
+		res := BatchResult{Index: i + 1, Query: q, Results: results, Took: time.Since(start)}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		if res.Results == nil {
+			res.Results = []SearchResult{}
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
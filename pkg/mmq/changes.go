@@ -0,0 +1,150 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// StatusChanges 某个时间点或语料版本之后的变更（status --since）
+type StatusChanges struct {
+	Since        time.Time `json:"since"`                   // 起点；以版本为起点时为该版本的时间
+	SinceVersion int64     `json:"since_version,omitempty"` // 以版本为起点时的版本
+
+	Documents   DocumentChanges     `json:"documents"`
+	Collections []CollectionChanges `json:"collections,omitempty"` // 有文档变更的集合
+	Renamed     int                 `json:"collections_renamed,omitempty"`
+	Memories    MemoryChanges       `json:"memories"`
+	Embeddings  EmbeddingChanges    `json:"embeddings"`
+
+	CorpusVersion int64 `json:"corpus_version"` // 当前语料版本
+	Cursor        int64 `json:"cursor"`         // 当前最大的事件 ID，下次传给 --since 只统计之后的变更
+}
+
+// DocumentChanges 文档变更数
+type DocumentChanges struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+}
+
+// Total 变更总数
+func (d DocumentChanges) Total() int { return d.Added + d.Updated + d.Removed }
+
+// CollectionChanges 单个集合的文档变更数
+type CollectionChanges struct {
+	Name string `json:"name"`
+	DocumentChanges
+}
+
+// MemoryChanges 记忆变更数
+type MemoryChanges struct {
+	Stored  int `json:"stored"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// EmbeddingChanges 新生成的嵌入
+type EmbeddingChanges struct {
+	Documents int `json:"documents"` // 生成了嵌入的文档内容数
+	Chunks    int `json:"chunks"`    // 生成的分块向量数
+}
+
+// Empty 没有任何变更
+func (c *StatusChanges) Empty() bool {
+	return c.Documents.Total() == 0 && c.Renamed == 0 &&
+		c.Memories == (MemoryChanges{}) && c.Embeddings == (EmbeddingChanges{})
+}
+
+// ParseSince 解析 status --since 的起点：整数为语料版本（或上次输出的 cursor），
+// 否则为时间：YYYY-MM-DD、RFC3339，或 24h、7d 这样相对 now 的时长
+func ParseSince(s string, now time.Time) (since time.Time, version int64, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, 0, fmt.Errorf("empty --since")
+	}
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if v < 0 {
+			return time.Time{}, 0, fmt.Errorf("corpus version must not be negative")
+		}
+		return time.Time{}, v, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, 0, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, 0, nil
+	}
+	if d, err := ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), 0, nil
+	}
+	return time.Time{}, 0, fmt.Errorf("invalid --since %q (use a corpus version, YYYY-MM-DD, an RFC3339 time or a duration like 24h or 7d)", s)
+}
+
+// ChangesSince 统计 since 之后的文档、记忆和嵌入变更
+func (m *MMQ) ChangesSince(since time.Time) (*StatusChanges, error) {
+	counts, err := m.store.CountChangesSince(0, since)
+	if err != nil {
+		return nil, err
+	}
+	return m.statusChanges(counts, since, 0)
+}
+
+// ChangesSinceVersion 统计语料版本（事件 ID）version 之后的变更；嵌入按该版本的时间统计，0 表示从头统计
+func (m *MMQ) ChangesSinceVersion(version int64) (*StatusChanges, error) {
+	since, err := m.store.EventTime(version)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := m.store.CountChangesSince(version, since)
+	if err != nil {
+		return nil, err
+	}
+	if version > counts.LastEventID {
+		return nil, fmt.Errorf("corpus version %d does not exist yet (latest is %d)", version, counts.LastEventID)
+	}
+	return m.statusChanges(counts, since, version)
+}
+
+// statusChanges 汇总计数
+func (m *MMQ) statusChanges(counts *store.ChangeCounts, since time.Time, version int64) (*StatusChanges, error) {
+	corpus, err := m.store.CorpusVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &StatusChanges{
+		Since:        since,
+		SinceVersion: version,
+		Documents: DocumentChanges{
+			Added:   counts.Events[store.EventDocAdded],
+			Updated: counts.Events[store.EventDocUpdated],
+			Removed: counts.Events[store.EventDocRemoved],
+		},
+		Renamed: counts.Events[store.EventCollectionRenamed],
+		Memories: MemoryChanges{
+			Stored:  counts.Events[store.EventMemoryStored],
+			Updated: counts.Events[store.EventMemoryUpdated],
+			Deleted: counts.Events[store.EventMemoryDeleted],
+		},
+		Embeddings:    EmbeddingChanges{Documents: counts.EmbeddedDocs, Chunks: counts.EmbeddedRows},
+		CorpusVersion: corpus,
+		Cursor:        counts.LastEventID,
+	}
+	for name, byType := range counts.Collections {
+		c.Collections = append(c.Collections, CollectionChanges{
+			Name: name,
+			DocumentChanges: DocumentChanges{
+				Added:   byType[store.EventDocAdded],
+				Updated: byType[store.EventDocUpdated],
+				Removed: byType[store.EventDocRemoved],
+			},
+		})
+	}
+	sort.Slice(c.Collections, func(i, j int) bool { return c.Collections[i].Name < c.Collections[j].Name })
+	return c, nil
+}
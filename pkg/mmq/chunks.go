@@ -0,0 +1,129 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// ChunkReport 文档的分块情况，用于排查特定文档检索效果差的原因
+type ChunkReport struct {
+	DocID        string      `json:"docid"`
+	Collection   string      `json:"collection"`
+	Path         string      `json:"path"`
+	Title        string      `json:"title"`
+	Hash         string      `json:"hash"`
+	Length       int         `json:"length"` // 文档字节数
+	ChunkSize    int         `json:"chunk_size"`
+	ChunkOverlap int         `json:"chunk_overlap"`
+	EmbedModel   string      `json:"embed_model"` // 集合使用的嵌入模型
+	Chunks       []ChunkInfo `json:"chunks"`
+	Embedded     int         `json:"embedded"`        // 有向量的分块数
+	Stale        int         `json:"stale,omitempty"` // 与当前分块不对应的向量数（分块参数改变后未重新嵌入）
+}
+
+// ChunkInfo 单个分块：边界、大小、与前一块的重叠以及已有的向量
+type ChunkInfo struct {
+	Seq     int             `json:"seq"`
+	Pos     int             `json:"pos"` // 起始字节位置
+	End     int             `json:"end"` // 结束字节位置（不含）
+	Size    int             `json:"size"`
+	Chars   int             `json:"chars"`
+	Overlap int             `json:"overlap"` // 与前一块重叠的字节数
+	Text    string          `json:"text"`
+	Vectors []ChunkEmbedded `json:"vectors,omitempty"`
+}
+
+// ChunkEmbedded 分块的一个向量
+type ChunkEmbedded struct {
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	EmbeddedAt time.Time `json:"embedded_at"`
+	// 生成向量时的分块参数（旧向量未记录时为 0）
+	ChunkSize    int `json:"chunk_size,omitempty"`
+	ChunkOverlap int `json:"chunk_overlap,omitempty"`
+}
+
+// chunkDocument 按路径对应的分块参数（见 Config.ChunkSettings）切分文档
+func (m *MMQ) chunkDocument(path, content string) []store.Chunk {
+	size, overlap := m.cfg.ChunkSettings(path)
+	return store.ChunkDocument(content, size, overlap)
+}
+
+// DocumentChunks 按当前分块参数切分文档，并标出每块已有的向量
+// ref 为短 docid（#abc123 或 abc123）或 collection/path
+func (m *MMQ) DocumentChunks(ref string) (*ChunkReport, error) {
+	var doc *DocumentDetail
+	var err error
+	if strings.HasPrefix(ref, "#") || !strings.Contains(ref, "/") {
+		doc, err = m.GetDocumentByID(ref)
+	} else {
+		doc, err = m.GetDocumentByPath(ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	report := &ChunkReport{
+		DocID:      doc.DocID,
+		Collection: doc.Collection,
+		Path:       doc.Path,
+		Title:      doc.Title,
+		Hash:       doc.Hash,
+		Length:     len(doc.Content),
+		EmbedModel: m.cfg.EmbeddingModel,
+		Chunks:     []ChunkInfo{},
+	}
+	report.ChunkSize, report.ChunkOverlap = m.cfg.ChunkSettings(doc.Path)
+	if model, err := m.store.GetCollectionEmbedModel(doc.Collection); err == nil && model != "" {
+		report.EmbedModel = model
+	}
+
+	chunks := store.ChunkDocument(doc.Content, report.ChunkSize, report.ChunkOverlap)
+	for i, c := range chunks {
+		info := ChunkInfo{
+			Seq:   i,
+			Pos:   c.Pos,
+			End:   c.Pos + len(c.Text),
+			Size:  len(c.Text),
+			Chars: utf8.RuneCountInString(c.Text),
+			Text:  c.Text,
+		}
+		if i > 0 {
+			if prevEnd := report.Chunks[i-1].End; prevEnd > c.Pos {
+				info.Overlap = prevEnd - c.Pos
+			}
+		}
+		report.Chunks = append(report.Chunks, info)
+	}
+
+	vectors, err := m.store.GetChunkVectors(doc.Hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vectors {
+		// seq 超出当前分块数或起始位置不一致的向量来自旧的分块参数
+		if v.Seq >= len(report.Chunks) || report.Chunks[v.Seq].Pos != v.Pos {
+			report.Stale++
+			continue
+		}
+		c := &report.Chunks[v.Seq]
+		c.Vectors = append(c.Vectors, ChunkEmbedded{
+			Model:        v.Model,
+			Dimensions:   v.Dimensions,
+			EmbeddedAt:   v.EmbeddedAt,
+			ChunkSize:    v.ChunkSize,
+			ChunkOverlap: v.ChunkOverlap,
+		})
+	}
+	for _, c := range report.Chunks {
+		if len(c.Vectors) > 0 {
+			report.Embedded++
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,444 @@
+package mmq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// ClusterOptions 文档聚类选项
+type ClusterOptions struct {
+	Collection string                              // 要聚类的集合（必填）
+	K          int                                 // 聚类数（默认 √(文档数/2)，2-50）
+	Iterations int                                 // k-means 最大迭代次数（默认 50）
+	NoLLM      bool                                // 不调用生成模型，用高频词作为标签
+	Generator  func(prompt string) (string, error) // 生成标签的模型，为空时使用本地 generate 模型
+}
+
+// Cluster 一个文档聚类
+type Cluster struct {
+	Collection string            `json:"collection"`
+	ID         int               `json:"id"`
+	Label      string            `json:"label"`
+	Size       int               `json:"size"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Documents  []ClusterDocument `json:"documents,omitempty"`
+}
+
+// ClusterDocument 聚类中的文档，Distance 为与聚类中心的余弦距离
+type ClusterDocument struct {
+	Path     string  `json:"path"`
+	Title    string  `json:"title,omitempty"`
+	Distance float64 `json:"distance"`
+}
+
+// clusterLabelPrompt 生成聚类标签的 prompt
+const clusterLabelPrompt = `以下是同一主题聚类中的几篇文档（标题和开头）。
+请用 2-5 个词概括它们的共同主题，作为聚类标签。
+- 使用与文档相同的语言
+- 只输出标签本身，不要加前缀、引号或标点
+
+%s
+标签：`
+
+// ClusterCollection 按文档嵌入（各分块向量的平均）对集合做 k-means 聚类，
+// 为每个聚类生成标签，并保存文档的聚类归属（替换上次的结果）
+// 聚类 id 按大小从 1 开始编号
+func (m *MMQ) ClusterCollection(opts ClusterOptions) ([]Cluster, error) {
+	if opts.Collection == "" {
+		return nil, fmt.Errorf("collection is required for clustering")
+	}
+	if opts.K < 0 {
+		return nil, fmt.Errorf("k must not be negative")
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = 50
+	}
+
+	model, err := m.store.GetCollectionEmbedModel(opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := m.store.GetChunkEmbeddings(opts.Collection, model, 0)
+	if err != nil {
+		return nil, err
+	}
+	docs := documentVectors(chunks)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("collection '%s' has no embedded documents (run 'mmq embed' first)", opts.Collection)
+	}
+
+	k := opts.K
+	if k == 0 {
+		k = int(math.Round(math.Sqrt(float64(len(docs)) / 2)))
+		k = max(2, min(k, 50))
+	}
+	k = min(k, len(docs))
+
+	vectors := make([][]float64, len(docs))
+	for i, d := range docs {
+		vectors[i] = d.vector
+	}
+	assign, centroids := kmeans(vectors, k, opts.Iterations)
+
+	// 按大小重新编号，去掉空聚类
+	members := make([][]int, k)
+	for i, c := range assign {
+		members[c] = append(members[c], i)
+	}
+	order := make([]int, 0, k)
+	for c := range members {
+		if len(members[c]) > 0 {
+			order = append(order, c)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(members[order[i]]) > len(members[order[j]]) })
+
+	generate := opts.Generator
+	if generate == nil && !opts.NoLLM && llm.Supports(m.llm, llm.CapabilityGenerate) {
+		generate = func(prompt string) (string, error) {
+			genOpts := llm.DefaultGenerateOptions()
+			genOpts.Temperature = 0.2
+			genOpts.MaxTokens = 32
+			return m.llm.Generate(prompt, genOpts)
+		}
+	}
+
+	var clusters []Cluster
+	var storeClusters []store.Cluster
+	var assignments []store.ClusterAssignment
+	now := time.Now()
+	for n, c := range order {
+		cluster := Cluster{Collection: opts.Collection, ID: n + 1, Size: len(members[c]), CreatedAt: now}
+		for _, i := range members[c] {
+			dist := 1 - dot(vectors[i], centroids[c])
+			cluster.Documents = append(cluster.Documents, ClusterDocument{Path: docs[i].path, Title: docs[i].title, Distance: dist})
+			assignments = append(assignments, store.ClusterAssignment{Path: docs[i].path, Cluster: cluster.ID, Distance: dist})
+		}
+		sort.SliceStable(cluster.Documents, func(a, b int) bool { return cluster.Documents[a].Distance < cluster.Documents[b].Distance })
+
+		cluster.Label = clusterLabel(generate, docs, members[c], vectors, centroids[c])
+		clusters = append(clusters, cluster)
+		storeClusters = append(storeClusters, store.Cluster{ID: cluster.ID, Label: cluster.Label, Size: cluster.Size})
+	}
+
+	if err := m.store.ReplaceClusters(opts.Collection, storeClusters, assignments); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+// ListClusters 返回已保存的聚类（collection 为空时返回全部集合）
+func (m *MMQ) ListClusters(collection string) ([]Cluster, error) {
+	storeClusters, err := m.store.ListClusters(collection)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]Cluster, len(storeClusters))
+	for i, c := range storeClusters {
+		clusters[i] = Cluster{Collection: c.Collection, ID: c.ID, Label: c.Label, Size: c.Size, CreatedAt: c.CreatedAt}
+	}
+	return clusters, nil
+}
+
+// GetCluster 返回聚类及其文档（按与中心的距离排序）
+func (m *MMQ) GetCluster(collection string, id int) (*Cluster, error) {
+	clusters, err := m.ListClusters(collection)
+	if err != nil {
+		return nil, err
+	}
+	var cluster *Cluster
+	for i := range clusters {
+		if clusters[i].ID == id {
+			cluster = &clusters[i]
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %d not found in collection '%s' (run 'mmq cluster' first)", id, collection)
+	}
+
+	assignments, err := m.store.ClusterAssignments(collection, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assignments {
+		cluster.Documents = append(cluster.Documents, ClusterDocument{Path: a.Path, Title: a.Title, Distance: a.Distance})
+	}
+	return cluster, nil
+}
+
+// SetClusterLabel 手动修改聚类标签
+func (m *MMQ) SetClusterLabel(collection string, id int, label string) error {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return fmt.Errorf("cluster label must not be empty")
+	}
+	return m.store.SetClusterLabel(collection, id, label)
+}
+
+// filterByCluster 只保留属于指定聚类的结果，并在元数据中标出聚类
+func (m *MMQ) filterByCluster(results []SearchResult, collection string, cluster, limit int) ([]SearchResult, error) {
+	inCluster, err := m.clusterMembers(collection, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		if r.Collection != collection || !inCluster[r.Path] {
+			continue
+		}
+		metadata := make(map[string]interface{}, len(r.Metadata)+1)
+		for k, v := range r.Metadata {
+			metadata[k] = v
+		}
+		metadata["cluster"] = cluster
+		r.Metadata = metadata
+		filtered = append(filtered, r)
+		if len(filtered) == limit {
+			break
+		}
+	}
+	return filtered, nil
+}
+
+// filterContextsByCluster 只保留属于该聚类的上下文，最多 limit 个
+func (m *MMQ) filterContextsByCluster(contexts []Context, collection string, cluster, limit int) ([]Context, error) {
+	inCluster, err := m.clusterMembers(collection, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]Context, 0, len(contexts))
+	for _, c := range contexts {
+		if getMetadataString(c.Metadata, "collection") != collection || !inCluster[getMetadataString(c.Metadata, "path")] {
+			continue
+		}
+		filtered = append(filtered, c)
+		if len(filtered) == limit {
+			break
+		}
+	}
+	return filtered, nil
+}
+
+// clusterMembers 聚类中文档路径的集合
+func (m *MMQ) clusterMembers(collection string, cluster int) (map[string]bool, error) {
+	assignments, err := m.store.ClusterAssignments(collection, cluster)
+	if err != nil {
+		return nil, err
+	}
+	inCluster := make(map[string]bool, len(assignments))
+	for _, a := range assignments {
+		inCluster[a.Path] = true
+	}
+	return inCluster, nil
+}
+
+// clusterCandidates 按聚类过滤时多召回一些候选，过滤后仍能凑够 limit 个
+func clusterCandidates(limit int) int {
+	return max(limit*5, 50)
+}
+
+// docVector 文档的平均嵌入
+type docVector struct {
+	path   string
+	title  string
+	text   string // 第一个分块的开头
+	vector []float64
+}
+
+// documentVectors 把分块向量按文档平均并归一化
+func documentVectors(chunks []store.ChunkEmbedding) []docVector {
+	var docs []docVector
+	index := make(map[string]int)
+	for _, c := range chunks {
+		if len(c.Embedding) == 0 {
+			continue
+		}
+		i, ok := index[c.Path]
+		if !ok {
+			i = len(docs)
+			index[c.Path] = i
+			docs = append(docs, docVector{path: c.Path, title: c.Title, vector: make([]float64, len(c.Embedding))})
+		}
+		d := &docs[i]
+		if len(c.Embedding) != len(d.vector) {
+			continue
+		}
+		if c.Seq == 0 {
+			d.text = c.Text
+		}
+		for j, x := range unitVector(c.Embedding) {
+			d.vector[j] += x
+		}
+	}
+	for i := range docs {
+		normalize(docs[i].vector)
+	}
+	return docs
+}
+
+// kmeans 球面 k-means（余弦距离，k-means++ 初始化，固定随机种子保证结果可复现）
+// 返回每个点的聚类和各聚类的单位中心向量
+func kmeans(vectors [][]float64, k, iterations int) ([]int, [][]float64) {
+	rng := rand.New(rand.NewSource(1))
+	n := len(vectors)
+
+	centroids := [][]float64{append([]float64(nil), vectors[rng.Intn(n)]...)}
+	dist := make([]float64, n)
+	for len(centroids) < k {
+		var total float64
+		for i, v := range vectors {
+			dist[i] = math.Inf(1)
+			for _, c := range centroids {
+				dist[i] = math.Min(dist[i], 1-dot(v, c))
+			}
+			dist[i] = math.Max(dist[i], 0)
+			dist[i] *= dist[i]
+			total += dist[i]
+		}
+		next := rng.Intn(n)
+		if total > 0 {
+			r := rng.Float64() * total
+			for i, d := range dist {
+				if r -= d; r <= 0 {
+					next = i
+					break
+				}
+			}
+		}
+		centroids = append(centroids, append([]float64(nil), vectors[next]...))
+	}
+
+	assign := make([]int, n)
+	for i := range assign {
+		assign[i] = -1
+	}
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, v := range vectors {
+			best, bestSim := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if sim := dot(v, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assign[i] != best {
+				assign[i], changed = best, true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		for c := range centroids {
+			sum := make([]float64, len(centroids[c]))
+			count := 0
+			for i, a := range assign {
+				if a != c {
+					continue
+				}
+				count++
+				for j, x := range vectors[i] {
+					sum[j] += x
+				}
+			}
+			if count > 0 && normalize(sum) > 0 {
+				centroids[c] = sum
+			}
+		}
+	}
+	return assign, centroids
+}
+
+// clusterLabel 用生成模型概括离中心最近的几篇文档；模型不可用或输出为空时使用高频词
+func clusterLabel(generate func(string) (string, error), docs []docVector, members []int, vectors [][]float64, centroid []float64) string {
+	nearest := append([]int(nil), members...)
+	sort.SliceStable(nearest, func(a, b int) bool {
+		return dot(vectors[nearest[a]], centroid) > dot(vectors[nearest[b]], centroid)
+	})
+	if len(nearest) > 6 {
+		nearest = nearest[:6]
+	}
+
+	if generate != nil {
+		var samples strings.Builder
+		for i, idx := range nearest {
+			fmt.Fprintf(&samples, "[%d] %s\n%s\n\n", i+1, docs[idx].title, truncateRunes(collapseSpaces(docs[idx].text), 200))
+		}
+		if out, err := generate(fmt.Sprintf(clusterLabelPrompt, samples.String())); err == nil {
+			if label := cleanClusterLabel(out); label != "" {
+				return label
+			}
+		}
+	}
+	return keywordLabel(docs, members)
+}
+
+// cleanClusterLabel 取模型输出的第一行，去掉前缀、引号和结尾标点
+func cleanClusterLabel(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		for _, prefix := range []string{"标签：", "标签:", "Label:", "label:"} {
+			line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+		}
+		line = strings.Trim(line, "\"'“”「」*`。.，,")
+		runes := []rune(line)
+		if len(runes) > 60 {
+			line = string(runes[:60])
+		}
+		return strings.TrimSpace(line)
+	}
+	return ""
+}
+
+// keywordLabel 聚类中文档标题和开头的三个高频词
+func keywordLabel(docs []docVector, members []int) string {
+	counts := make(map[string]int)
+	for _, i := range members {
+		seen := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(docs[i].title+" "+docs[i].text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(w)) < 3 || labelStopWords[w] || seen[w] {
+				continue
+			}
+			seen[w] = true
+			counts[w]++
+		}
+	}
+
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > 3 {
+		words = words[:3]
+	}
+	return strings.Join(words, " ")
+}
+
+// labelStopWords 生成关键词标签时忽略的常见英文词
+var labelStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"are": true, "was": true, "from": true, "you": true, "your": true, "not": true,
+	"but": true, "have": true, "has": true, "can": true, "will": true, "about": true,
+	"into": true, "how": true, "what": true, "when": true, "which": true, "all": true,
+}
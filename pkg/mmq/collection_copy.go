@@ -0,0 +1,67 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// MergeConflict 合并时路径冲突的处理方式
+type MergeConflict string
+
+const (
+	MergeSkip      MergeConflict = "skip"      // 保留目标集合中已有的文档（默认）
+	MergeOverwrite MergeConflict = "overwrite" // 用来源文档覆盖
+	MergeNewer     MergeConflict = "newer"     // 保留修改时间较新的文档
+	MergeRename    MergeConflict = "rename"    // 保留两者，来源文档改名为 name.<来源集合>.ext
+)
+
+// CollectionCopyResult 克隆/合并结果
+type CollectionCopyResult struct {
+	Documents int `json:"documents"`
+	Conflicts int `json:"conflicts"`
+	Skipped   int `json:"skipped"`
+	Renamed   int `json:"renamed"`
+	Contexts  int `json:"contexts"`
+}
+
+// CloneCollection 克隆集合的文档、上下文和设置，嵌入按内容共享无需重新生成
+func (m *MMQ) CloneCollection(src, dst string) (*CollectionCopyResult, error) {
+	if dst == "" {
+		return nil, fmt.Errorf("destination collection name is required")
+	}
+
+	stats, err := m.store.CloneCollection(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	return convertCopyStats(stats), nil
+}
+
+// MergeCollections 将多个集合合并到 into（不存在时创建），来源集合保持不变
+func (m *MMQ) MergeCollections(sources []string, into string, onConflict MergeConflict) (*CollectionCopyResult, error) {
+	if len(sources) == 0 || into == "" {
+		return nil, fmt.Errorf("at least one source and a destination collection are required")
+	}
+
+	policy, err := store.ParseConflictPolicy(string(onConflict))
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := m.store.MergeCollections(sources, into, policy)
+	if err != nil {
+		return nil, err
+	}
+	return convertCopyStats(stats), nil
+}
+
+func convertCopyStats(s *store.CopyStats) *CollectionCopyResult {
+	return &CollectionCopyResult{
+		Documents: s.Documents,
+		Conflicts: s.Conflicts,
+		Skipped:   s.Skipped,
+		Renamed:   s.Renamed,
+		Contexts:  s.Contexts,
+	}
+}
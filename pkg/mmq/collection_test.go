@@ -1,10 +1,23 @@
 package mmq
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
 )
 
 func TestCreateCollection(t *testing.T) {
@@ -204,7 +217,7 @@ func TestIndexDirectory(t *testing.T) {
 	}
 
 	// 索引目录
-	err = m.IndexDirectory(testDir, IndexOptions{
+	_, err = m.IndexDirectory(testDir, IndexOptions{
 		Collection: "test-docs",
 		Mask:       "**/*.md",
 		Recursive:  true,
@@ -348,3 +361,1178 @@ func TestCollectionWithDocuments(t *testing.T) {
 
 	t.Logf("Collection: %s has %d documents", found.Name, found.DocCount)
 }
+
+func TestCollectionPIIPolicy(t *testing.T) {
+	m := newTestMMQ(t)
+
+	testDir := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "contact.md"),
+		[]byte("# Contact\nEmail alice@example.com or call 13812345678."), 0644)
+	os.WriteFile(filepath.Join(testDir, "plain.md"), []byte("# Plain\nNothing personal here."), 0644)
+
+	if err := m.CreateCollection("notes", testDir, CollectionOptions{PIIPolicy: "bogus"}); err == nil {
+		t.Fatal("Expected error for invalid PII policy")
+	}
+	if err := m.CreateCollection("notes", testDir, CollectionOptions{PIIPolicy: "redact"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.IndexCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := m.GetDocumentByPath("notes/contact.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(doc.Content, "alice@example.com") || strings.Contains(doc.Content, "13812345678") {
+		t.Errorf("Expected PII to be redacted, got: %s", doc.Content)
+	}
+	if !strings.Contains(doc.Content, "[REDACTED:email]") || !strings.Contains(doc.Content, "[REDACTED:phone]") {
+		t.Errorf("Expected redaction markers, got: %s", doc.Content)
+	}
+
+	// 切换为 flag 后重新索引，原文保留，审计可发现
+	if err := m.SetCollectionPIIPolicy("notes", "flag"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IndexCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := m.ScanPII(PIIScanOptions{Collection: "notes", Documents: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Path != "contact.md" {
+		t.Fatalf("Expected one finding in contact.md, got %+v", findings)
+	}
+
+	kinds := map[string]bool{}
+	for _, match := range findings[0].Matches {
+		kinds[match.Kind] = true
+	}
+	if !kinds["email"] || !kinds["phone"] {
+		t.Errorf("Expected email and phone matches, got %+v", findings[0].Matches)
+	}
+}
+
+// summaryLLM 把生成请求记为一次摘要调用，返回固定摘要
+type summaryLLM struct {
+	*testLLM
+	calls int
+}
+
+func (l *summaryLLM) Generate(prompt string, opts llm.GenerateOptions) (string, error) {
+	l.calls++
+	return "A note about account recovery.", nil
+}
+
+func TestSummaryIndexMode(t *testing.T) {
+	m := newTestMMQ(t)
+	gen := &summaryLLM{testLLM: newTestLLM(300)}
+	m.llm = gen
+
+	testDir := filepath.Join(t.TempDir(), "vault")
+	os.MkdirAll(testDir, 0755)
+	secret := "---\ndate: 2023-04-05\n---\n# Bank\nPIN 4821, security answer: Rosebud."
+	os.WriteFile(filepath.Join(testDir, "bank.md"), []byte(secret), 0644)
+
+	if err := m.CreateCollection("vault", testDir, CollectionOptions{IndexMode: "bogus"}); err == nil {
+		t.Fatal("Expected error for invalid index mode")
+	}
+	if err := m.CreateCollection("vault", testDir, CollectionOptions{IndexMode: IndexModeSummary}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := m.IndexCollection("vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 1 || summary.IndexMode != IndexModeSummary || gen.calls != 1 {
+		t.Fatalf("Expected one summarized document, got %+v (%d calls)", summary, gen.calls)
+	}
+
+	doc, err := m.GetDocumentByPath("vault/bank.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "A note about account recovery." || doc.Title != "Bank" || doc.Date != "2023-04-05" {
+		t.Errorf("Expected summary with title and date from the source, got %+v", doc)
+	}
+	var leaked int
+	m.GetStore().DB().QueryRow("SELECT COUNT(*) FROM content WHERE doc LIKE '%Rosebud%'").Scan(&leaked)
+	if leaked != 0 {
+		t.Error("Expected the full text not to be stored")
+	}
+
+	// 原文未变化时沿用摘要，不再调用生成模型
+	summary, err = m.IndexCollection("vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unchanged != 1 || gen.calls != 1 {
+		t.Errorf("Expected the unchanged file to keep its summary, got %+v (%d calls)", summary, gen.calls)
+	}
+	os.WriteFile(filepath.Join(testDir, "bank.md"), []byte(secret+"\nNew card ordered."), 0644)
+	if summary, err = m.IndexCollection("vault"); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated != 1 || gen.calls != 2 {
+		t.Errorf("Expected the changed file to be summarized again, got %+v (%d calls)", summary, gen.calls)
+	}
+
+	// 切回全文模式后保存原文
+	if err := m.SetCollectionIndexMode("vault", IndexModeFull); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IndexCollection("vault"); err != nil {
+		t.Fatal(err)
+	}
+	if doc, _ = m.GetDocumentByPath("vault/bank.md"); !strings.Contains(doc.Content, "Rosebud") {
+		t.Errorf("Expected full text after switching back, got %q", doc.Content)
+	}
+}
+
+func TestReindexSnapshot(t *testing.T) {
+	m := newTestMMQ(t)
+
+	testDir := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "alpha.md"), []byte("# Alpha\nold zebra content"), 0644)
+	os.WriteFile(filepath.Join(testDir, "beta.md"), []byte("# Beta\nold zebra notes"), 0644)
+
+	if err := m.CreateCollection("notes", testDir, CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IndexCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	countHits := func(query string) int {
+		t.Helper()
+		results, err := m.Search(query, SearchOptions{Limit: 10, Collection: "notes"})
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", query, err)
+		}
+		return len(results)
+	}
+
+	if got := countHits("zebra"); got != 2 {
+		t.Fatalf("Expected 2 hits before reindex, got %d", got)
+	}
+
+	// 暂存新版本：alpha 改写，beta 删除
+	gen, err := m.store.BeginReindex("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.stageDocument(gen, store.Document{Collection: "notes", Path: "alpha.md", Title: "Alpha", Content: "new giraffe content"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 提交前搜索仍看到完整的旧语料
+	if got := countHits("zebra"); got != 2 {
+		t.Errorf("Expected old corpus during reindex, got %d zebra hits", got)
+	}
+	if got := countHits("giraffe"); got != 0 {
+		t.Errorf("Expected staged content to be invisible, got %d giraffe hits", got)
+	}
+
+	stats, err := m.store.CommitReindex("notes", gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Updated != 1 || stats.Deactivated != 1 {
+		t.Errorf("Unexpected reindex stats: %+v", stats)
+	}
+
+	if got := countHits("zebra"); got != 0 {
+		t.Errorf("Expected no old content after commit, got %d zebra hits", got)
+	}
+	if got := countHits("giraffe"); got != 1 {
+		t.Errorf("Expected new content after commit, got %d giraffe hits", got)
+	}
+
+	// 重新索引目录时，已删除的文件会被停用
+	os.Remove(filepath.Join(testDir, "beta.md"))
+	if _, err := m.IndexCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+	coll, err := m.GetCollection("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.DocCount != 1 {
+		t.Errorf("Expected 1 document after removing beta.md, got %d", coll.DocCount)
+	}
+}
+
+func TestReindexKeepsUnreadableFiles(t *testing.T) {
+	m := newTestMMQ(t)
+
+	testDir := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(testDir, 0755)
+	for _, name := range []string{"alpha.md", "beta.md", "gamma.md"} {
+		os.WriteFile(filepath.Join(testDir, name), []byte("# "+name+"\nzebra notes"), 0644)
+	}
+	opts := IndexOptions{Collection: "notes"}
+	if _, err := m.IndexDirectory(testDir, opts); err != nil {
+		t.Fatal(err)
+	}
+	// 直接写入的文档不在目录中
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "inbox/api.md", Title: "API", Content: "zebra via api"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二次索引时 beta.md 读取失败，gamma.md 已删除
+	if err := m.AddContentTransform(ContentTransform{
+		Name:  "locked",
+		Match: "beta.md",
+		Decode: func(path string, data []byte) ([]byte, error) {
+			return nil, fmt.Errorf("open %s: %w", path, fs.ErrPermission)
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(testDir, "gamma.md"))
+
+	summary, err := m.IndexDirectory(testDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Path != "beta.md" || summary.Removed != 1 {
+		t.Errorf("Expected one read error and one removed file, got %+v", summary)
+	}
+
+	for _, path := range []string{"notes/alpha.md", "notes/beta.md", "notes/inbox/api.md"} {
+		if _, err := m.GetDocumentByPath(path); err != nil {
+			t.Errorf("Expected %s to survive the re-index: %v", path, err)
+		}
+	}
+	if _, err := m.GetDocumentByPath("notes/gamma.md"); err == nil {
+		t.Error("Expected deleted gamma.md to be removed")
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NeedsEmbedding != 0 {
+		t.Errorf("Expected surviving documents to keep their vectors, %d need embedding", status.NeedsEmbedding)
+	}
+}
+
+func TestIndexDirectorySummary(t *testing.T) {
+	m := newTestMMQ(t)
+
+	testDir := filepath.Join(t.TempDir(), "docs")
+	os.MkdirAll(filepath.Join(testDir, "sub"), 0755)
+	for i := 0; i < 20; i++ {
+		os.WriteFile(filepath.Join(testDir, "sub", fmt.Sprintf("doc%02d.md", i)),
+			[]byte(fmt.Sprintf("# Doc %d\nbody %d", i, i)), 0644)
+	}
+	os.WriteFile(filepath.Join(testDir, "notes.txt"), []byte("not markdown"), 0644)
+
+	opts := IndexOptions{Collection: "docs", Mask: "**/*.md", Workers: 4}
+	summary, err := m.IndexDirectory(testDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 20 || summary.Skipped != 1 || summary.Indexed() != 20 || len(summary.Errors) != 0 {
+		t.Errorf("Unexpected first summary: %+v", summary)
+	}
+
+	// 修改一个、删除一个，其余不变
+	os.WriteFile(filepath.Join(testDir, "sub", "doc03.md"), []byte("# Doc 3\nrewritten"), 0644)
+	os.Remove(filepath.Join(testDir, "sub", "doc07.md"))
+
+	summary, err = m.IndexDirectory(testDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 0 || summary.Updated != 1 || summary.Unchanged != 18 || summary.Removed != 1 {
+		t.Errorf("Unexpected second summary: %+v", summary)
+	}
+
+	doc, err := m.GetDocumentByPath("docs/sub/doc03.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc.Content, "rewritten") {
+		t.Errorf("Expected updated content, got %q", doc.Content)
+	}
+}
+
+func TestCollectionFreshness(t *testing.T) {
+	m := newTestMMQ(t)
+
+	testDir := filepath.Join(t.TempDir(), "notes")
+	os.MkdirAll(filepath.Join(testDir, ".git"), 0755)
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		os.WriteFile(filepath.Join(testDir, name), []byte("# "+name), 0644)
+	}
+	if _, err := m.IndexDirectory(testDir, IndexOptions{Collection: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IndexDocument(Document{Collection: "inbox", Path: "x.md", Title: "X", Content: "no source dir"}); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := func() map[string]CollectionFreshness {
+		t.Helper()
+		list, err := m.CollectionFreshness()
+		if err != nil {
+			t.Fatalf("CollectionFreshness failed: %v", err)
+		}
+		result := make(map[string]CollectionFreshness)
+		for _, f := range list {
+			result[f.Collection] = f
+		}
+		return result
+	}
+
+	fresh := byName()
+	notes := fresh["notes"]
+	if notes.IndexedAt == nil || !notes.Scanned || notes.Changed() != 0 || notes.Error != "" {
+		t.Errorf("Expected freshly indexed collection to be up to date, got %+v", notes)
+	}
+	if inbox := fresh["inbox"]; inbox.Scanned || inbox.IndexedAt != nil {
+		t.Errorf("Expected collection without source dir to be skipped, got %+v", inbox)
+	}
+
+	// 修改一个、新增一个、删除一个；隐藏目录和不匹配 mask 的文件不计入
+	later := time.Now().Add(time.Hour)
+	os.WriteFile(filepath.Join(testDir, "a.md"), []byte("# a.md changed"), 0644)
+	os.Chtimes(filepath.Join(testDir, "a.md"), later, later)
+	os.WriteFile(filepath.Join(testDir, "d.md"), []byte("# d.md"), 0644)
+	os.Chtimes(filepath.Join(testDir, "d.md"), later, later)
+	os.Remove(filepath.Join(testDir, "c.md"))
+	os.WriteFile(filepath.Join(testDir, ".git", "e.md"), []byte("# hidden"), 0644)
+	os.WriteFile(filepath.Join(testDir, "f.txt"), []byte("not markdown"), 0644)
+
+	notes = byName()["notes"]
+	if notes.Modified != 1 || notes.Added != 1 || notes.Removed != 1 || notes.Changed() != 3 {
+		t.Errorf("Expected 1 modified, 1 added and 1 removed file, got %+v", notes)
+	}
+
+	if _, err := m.IndexCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+	if notes = byName()["notes"]; notes.Changed() != 0 {
+		t.Errorf("Expected no changes after re-indexing, got %+v", notes)
+	}
+}
+
+func TestIndexDocumentAutoCreatesCollection(t *testing.T) {
+	m := newTestMMQ(t)
+
+	doc := Document{Collection: "inbox", Path: "a.md", Title: "A", Content: "auto created"}
+	if err := m.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := m.GetCollection("inbox")
+	if err != nil {
+		t.Fatalf("Expected collection to be auto-created: %v", err)
+	}
+	if coll.DocCount != 1 || coll.Mask != "**/*.md" {
+		t.Errorf("Unexpected auto-created collection: %+v", coll)
+	}
+	if _, err := m.IndexCollection("inbox"); err == nil {
+		t.Error("Expected IndexCollection to fail for a collection without source path")
+	}
+
+	m.cfg.StrictCollections = true
+	err = m.IndexDocument(Document{Collection: "unknown", Path: "b.md", Title: "B", Content: "strict"})
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	if err := m.IndexDocument(Document{Collection: "inbox", Path: "c.md", Title: "C", Content: "existing"}); err != nil {
+		t.Errorf("Expected indexing into an existing collection to succeed: %v", err)
+	}
+}
+
+func TestCloneAndMergeCollections(t *testing.T) {
+	m := newTestMMQ(t)
+
+	old := time.Now().Add(-time.Hour)
+	now := time.Now()
+	docs := []Document{
+		{Collection: "a", Path: "shared.md", Title: "A shared", Content: "from a", ModifiedAt: old},
+		{Collection: "a", Path: "only-a.md", Title: "Only A", Content: "only in a", ModifiedAt: old},
+		{Collection: "b", Path: "shared.md", Title: "B shared", Content: "from b", ModifiedAt: now},
+		{Collection: "b", Path: "only-b.md", Title: "Only B", Content: "only in b", ModifiedAt: now},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddContext("mmq://a", "collection a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 克隆：文档和上下文都复制，嵌入按哈希共享
+	result, err := m.CloneCollection("a", "a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Documents != 2 || result.Contexts != 1 {
+		t.Errorf("Unexpected clone result: %+v", result)
+	}
+	pending, err := m.store.GetDocumentsNeedingEmbedding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected clone to reuse embeddings, %d documents need embedding", len(pending))
+	}
+	if contexts, _ := m.GetDocumentContexts("a2", "shared.md"); len(contexts) == 0 {
+		t.Error("Expected cloned collection context")
+	}
+	if _, err := m.CloneCollection("a", "b"); err == nil {
+		t.Error("Expected clone into an existing collection to fail")
+	}
+
+	// 合并：skip 保留先写入的版本
+	result, err = m.MergeCollections([]string{"a", "b"}, "skip", MergeSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Documents != 3 || result.Conflicts != 1 || result.Skipped != 1 {
+		t.Errorf("Unexpected skip merge result: %+v", result)
+	}
+	doc, err := m.GetDocumentByPath("skip/shared.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "from a" {
+		t.Errorf("Expected skip to keep the first version, got %q", doc.Content)
+	}
+
+	// 合并：newer 保留较新的版本
+	if _, err := m.MergeCollections([]string{"a", "b"}, "newer", MergeNewer); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := m.GetDocumentByPath("newer/shared.md"); err != nil || doc.Content != "from b" {
+		t.Errorf("Expected newer to keep b's version, got %+v (%v)", doc, err)
+	}
+
+	// 合并：rename 保留两者
+	result, err = m.MergeCollections([]string{"a", "b"}, "renamed", MergeRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Documents != 4 || result.Renamed != 1 {
+		t.Errorf("Unexpected rename merge result: %+v", result)
+	}
+	if doc, err := m.GetDocumentByPath("renamed/shared.b.md"); err != nil || doc.Content != "from b" {
+		t.Errorf("Expected renamed copy of b's document, got %+v (%v)", doc, err)
+	}
+
+	if _, err := m.MergeCollections([]string{"a"}, "bad", MergeConflict("keep")); err == nil {
+		t.Error("Expected invalid conflict policy to fail")
+	}
+}
+
+func TestCollectionMetadata(t *testing.T) {
+	m := newTestMMQ(t)
+
+	if err := m.CreateCollection("notes", t.TempDir(), CollectionOptions{Mask: "**/*.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.SetCollectionMetadata("notes", map[string]interface{}{
+		"description": "Team notes",
+		"owner":       "alice",
+		"cursor":      map[string]interface{}{"page": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 合并写入：删除 owner，其余保留
+	if err := m.SetCollectionMetadata("notes", map[string]interface{}{"owner": nil, "icon": "📓"}); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := m.GetCollectionMetadata("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["description"] != "Team notes" || metadata["icon"] != "📓" {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+	if _, ok := metadata["owner"]; ok {
+		t.Error("Expected owner to be removed")
+	}
+	if cursor, ok := metadata["cursor"].(map[string]interface{}); !ok || cursor["page"] != float64(3) {
+		t.Errorf("Expected nested cursor to round-trip, got %v", metadata["cursor"])
+	}
+
+	// 列表、重命名和克隆都保留元数据
+	if err := m.RenameCollection("notes", "team"); err != nil {
+		t.Fatal(err)
+	}
+	coll, err := m.GetCollection("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.Metadata["description"] != "Team notes" {
+		t.Errorf("Expected metadata after rename, got %v", coll.Metadata)
+	}
+	if _, err := m.CloneCollection("team", "team2"); err != nil {
+		t.Fatal(err)
+	}
+	colls, err := m.ListCollections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range colls {
+		if c.Metadata["icon"] != "📓" {
+			t.Errorf("Expected metadata on %s, got %v", c.Name, c.Metadata)
+		}
+	}
+
+	if err := m.SetCollectionMetadata("missing", map[string]interface{}{"a": "b"}); err == nil {
+		t.Error("Expected error for unknown collection")
+	}
+}
+
+// writeTestPlugin 在插件目录中创建一个输出固定 JSON 的脚本插件
+func writeTestPlugin(t *testing.T, dir, name, manifest, response string) {
+	t.Helper()
+	pluginDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ncat >/dev/null\necho '" + response + "'\n"
+	if err := os.WriteFile(filepath.Join(pluginDir, "run.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test plugins are shell scripts")
+	}
+	m := newTestMMQ(t)
+
+	pluginDir := t.TempDir()
+	writeTestPlugin(t, pluginDir, "pdf",
+		`{"name": "pdf", "kind": "extractor", "command": ["./run.sh"], "extensions": [".pdf"]}`,
+		`{"result": {"text": "quarterly revenue grew", "title": "Q3 Report"}}`)
+	writeTestPlugin(t, pluginDir, "boost",
+		`{"name": "boost", "kind": "postprocessor", "command": ["./run.sh"]}`,
+		`{"result": {"results": [{"collection": "docs", "path": "report.pdf", "score": 0.99}, {"collection": "docs", "path": "ghost.md", "score": 1}]}}`)
+	writeTestPlugin(t, pluginDir, "calc",
+		`{"name": "calc", "kind": "tool", "description": "Calculator", "command": ["./run.sh"]}`,
+		`{"result": {"output": "42"}}`)
+	writeTestPlugin(t, pluginDir, "broken", `{"name": "broken", "kind": "magic", "command": ["x"]}`, `{}`)
+
+	m.cfg.PluginDir = pluginDir
+	if err := m.loadPlugins(); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins := m.Plugins()
+	if len(plugins) != 4 {
+		t.Fatalf("Expected 4 plugins, got %d", len(plugins))
+	}
+	for _, p := range plugins {
+		if (p.Name == "broken") != (p.Error != "") {
+			t.Errorf("Unexpected plugin status: %+v", p)
+		}
+	}
+
+	testDir := filepath.Join(t.TempDir(), "docs")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "report.pdf"), []byte("%PDF-1.4 binary"), 0644)
+	os.WriteFile(filepath.Join(testDir, "notes.md"), []byte("# Notes\nquarterly planning"), 0644)
+	if _, err := m.IndexDirectory(testDir, IndexOptions{Collection: "docs", Mask: "**/*.{md,pdf}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := m.GetDocumentByPath("docs/report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "quarterly revenue grew" || doc.Title != "Q3 Report" {
+		t.Errorf("Expected extractor output to be indexed, got %q / %q", doc.Title, doc.Content)
+	}
+
+	// 后处理器只保留 report.pdf，并忽略不在原结果中的文档
+	results, err := m.Search("quarterly", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "report.pdf" || results[0].Score != 0.99 {
+		t.Errorf("Expected post-processed results, got %+v", results)
+	}
+
+	if out, err := m.RunTool("calc", "6*7"); err != nil || out != "42" {
+		t.Errorf("RunTool = %q, %v", out, err)
+	}
+	if _, err := m.RunTool("broken", ""); err == nil {
+		t.Error("Expected invalid plugin not to be runnable")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	m := newTestMMQ(t)
+	m.SetActor("agent-1")
+
+	testDir := filepath.Join(t.TempDir(), "docs")
+	os.MkdirAll(testDir, 0755)
+	os.WriteFile(filepath.Join(testDir, "a.md"), []byte("# A\nalpha"), 0644)
+	os.WriteFile(filepath.Join(testDir, "b.md"), []byte("# B\nbeta"), 0644)
+
+	opts := IndexOptions{Collection: "docs", Mask: "**/*.md"}
+	if _, err := m.IndexDirectory(testDir, opts); err != nil {
+		t.Fatal(err)
+	}
+	// 未变化的重新索引不产生事件
+	if _, err := m.IndexDirectory(testDir, opts); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(testDir, "a.md"), []byte("# A\nalpha v2"), 0644)
+	os.Remove(filepath.Join(testDir, "b.md"))
+	if _, err := m.IndexDirectory(testDir, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetActor("agent-2")
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "User likes Go", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RenameCollection("docs", "notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := m.Events(EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s:%s:%s%s", e.Actor, e.Type, e.Path, e.Detail))
+	}
+	want := []string{
+		"agent-1:doc_added:a.md",
+		"agent-1:doc_added:b.md",
+		"agent-1:doc_updated:a.md",
+		"agent-1:doc_removed:b.md",
+		"agent-2:memory_stored:fact",
+		"agent-2:collection_renamed:docs",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected events:\n%s", strings.Join(got, "\n"))
+	}
+
+	// 增量同步游标和过滤
+	after, err := m.Events(EventFilter{AfterID: events[3].ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 1 || after[0].Type != EventMemoryStored {
+		t.Errorf("Expected the event after the cursor, got %+v", after)
+	}
+	byActor, _ := m.Events(EventFilter{Actor: "agent-2", Types: []string{EventCollectionRenamed}})
+	if len(byActor) != 1 || byActor[0].Collection != "notes" {
+		t.Errorf("Expected rename event by agent-2, got %+v", byActor)
+	}
+
+	// 删除记忆
+	mem := events[4].MemoryID
+	if err := m.DeleteMemory(mem); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ := m.Events(EventFilter{Limit: 1})
+	if len(latest) != 1 || latest[0].Type != EventMemoryDeleted || latest[0].MemoryID != mem {
+		t.Errorf("Expected memory_deleted event, got %+v", latest)
+	}
+}
+
+func TestDocumentEventCallbacks(t *testing.T) {
+	m := newTestMMQ(t)
+	index := func(path, content string) {
+		t.Helper()
+		if err := m.IndexDocument(Document{Collection: "docs", Path: path, Title: path, Content: content, ModifiedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 注册前的变更不会回放
+	index("old.md", "before registration")
+
+	var got []string
+	m.OnDocumentIndexed(func(e Event) {
+		got = append(got, e.Type+":"+e.Collection+"/"+e.Path)
+		// 回调中写入的文档在当前变更处理完后继续分发
+		if e.Path == "a.md" && e.Type == EventDocAdded {
+			index("derived.md", "written by a callback")
+		}
+	})
+	m.OnDocumentRemoved(func(e Event) {
+		if e.DocID == "" {
+			t.Errorf("Expected docid on removal event, got %+v", e)
+		}
+		got = append(got, e.Type+":"+e.Collection+"/"+e.Path)
+	})
+
+	index("a.md", "alpha")
+	index("a.md", "alpha v2")
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "not a document", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteDocument("a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveCollection("docs"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"doc_added:docs/a.md",
+		"doc_added:docs/derived.md",
+		"doc_updated:docs/a.md",
+		"doc_removed:docs/a.md",
+		"doc_removed:docs/derived.md",
+		"doc_removed:docs/old.md",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected callbacks:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestDeleteDocumentsWhere(t *testing.T) {
+	m := newTestMMQ(t)
+	old := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range []struct {
+		collection, path string
+		modified         time.Time
+	}{
+		{"web", "old/a.md", old},
+		{"web", "old/sub/b.md", old},
+		{"web", "old/c.md", recent},
+		{"web", "new/d.md", old},
+		{"notes", "old/e.md", old},
+	} {
+		doc := Document{Collection: d.collection, Path: d.path, Title: d.path, Content: "content of " + d.path, ModifiedAt: d.modified}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.DeleteDocumentsWhere(DeleteFilter{}); err == nil {
+		t.Error("Expected an empty filter to be rejected")
+	}
+	if _, err := m.DeleteDocumentsWhere(DeleteFilter{Collection: "missing"}); err == nil {
+		t.Error("Expected an unknown collection to be rejected")
+	}
+
+	paths := func(r *DeleteResult) string {
+		var got []string
+		for _, d := range r.Documents {
+			got = append(got, d.Collection+"/"+d.Path)
+		}
+		return strings.Join(got, ",")
+	}
+	filter := DeleteFilter{Collection: "web", Path: "old/**", Before: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), DryRun: true}
+	preview, err := m.DeleteDocumentsWhere(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(preview); got != "web/old/a.md,web/old/sub/b.md" {
+		t.Fatalf("Unexpected dry-run matches: %s", got)
+	}
+	if status, _ := m.Status(); status.TotalDocuments != 5 {
+		t.Fatalf("Dry run removed documents: %d left", status.TotalDocuments)
+	}
+
+	var removed []string
+	m.OnDocumentRemoved(func(e Event) { removed = append(removed, e.Path) })
+	filter.DryRun = false
+	result, err := m.DeleteDocumentsWhere(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths(result) != paths(preview) || len(removed) != 2 {
+		t.Fatalf("Expected the previewed documents to be removed, got %s (events %v)", paths(result), removed)
+	}
+	if status, _ := m.Status(); status.TotalDocuments != 3 {
+		t.Errorf("Expected 3 documents left, got %d", status.TotalDocuments)
+	}
+
+	// 不含通配符的路径匹配该目录下的全部文档
+	result, err = m.DeleteDocumentsWhere(DeleteFilter{Path: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(result); got != "notes/old/e.md,web/old/c.md" {
+		t.Errorf("Unexpected prefix matches: %s", got)
+	}
+}
+
+func TestContentTransform(t *testing.T) {
+	m := newTestMMQ(t)
+	b64 := base64.StdEncoding
+	if err := m.AddContentTransform(ContentTransform{
+		Name:  "b64",
+		Match: "**/*.enc",
+		Decode: func(path string, data []byte) ([]byte, error) {
+			return b64.DecodeString(string(data))
+		},
+		Encode: func(path string, data []byte) ([]byte, error) {
+			return []byte(b64.EncodeToString(data)), nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddContentTransform(ContentTransform{Name: "broken", Match: "**/*.bad", Decode: func(string, []byte) ([]byte, error) {
+		return nil, fmt.Errorf("wrong key")
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	testDir := t.TempDir()
+	secret := "# Secret\nthe launch code is swordfish"
+	os.WriteFile(filepath.Join(testDir, "a.md.enc"), []byte(b64.EncodeToString([]byte(secret))), 0644)
+	os.WriteFile(filepath.Join(testDir, "b.md"), []byte("# Plain\nnothing to hide"), 0644)
+	os.WriteFile(filepath.Join(testDir, "c.md.bad"), []byte("garbage"), 0644)
+
+	summary, err := m.IndexDirectory(testDir, IndexOptions{Collection: "vault", Mask: "**/*.{md,enc,bad}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 2 || len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0].Error, "wrong key") {
+		t.Fatalf("Expected 2 documents and a decode error, got %+v", summary)
+	}
+	doc, err := m.GetDocumentByPath("vault/a.md.enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != secret || doc.Title != "Secret" {
+		t.Errorf("Expected decoded content, got %q (title %q)", doc.Content, doc.Title)
+	}
+
+	// 写回时编码；匹配的转换没有 Encode 时拒绝写入明文
+	out := filepath.Join(testDir, "export.md.enc")
+	if err := m.WriteFile(out, []byte("transcript"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != b64.EncodeToString([]byte("transcript")) {
+		t.Errorf("Expected encoded file, got %q", data)
+	}
+	if err := m.WriteFile(filepath.Join(testDir, "export.md.bad"), []byte("transcript"), 0644); err == nil {
+		t.Error("Expected writing without an encode step to fail")
+	}
+
+	// 没有 **/ 的规则按集合内的相对路径匹配，与索引一致
+	if err := m.AddContentTransform(ContentTransform{
+		Name:   "journal",
+		Match:  "journal/*.md",
+		Decode: func(path string, data []byte) ([]byte, error) { return b64.DecodeString(string(data)) },
+		Encode: func(path string, data []byte) ([]byte, error) { return []byte(b64.EncodeToString(data)), nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(testDir, "journal"), 0755)
+	entry := filepath.Join(testDir, "journal", "today.md")
+	if err := m.WriteFile(entry, []byte("# Today\nprivate entry"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(entry); string(data) != b64.EncodeToString([]byte("# Today\nprivate entry")) {
+		t.Errorf("Expected journal entry to be encoded, got %q", data)
+	}
+	if _, err := m.IndexDirectory(testDir, IndexOptions{Collection: "vault", Mask: "**/*.{md,enc,bad}"}); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := m.GetDocumentByPath("vault/journal/today.md"); err != nil || doc.Content != "# Today\nprivate entry" {
+		t.Errorf("Expected written entry to round-trip through indexing, got %+v (%v)", doc, err)
+	}
+
+	// 配置规则通过外部命令转换
+	if _, err := exec.LookPath("base64"); err == nil {
+		rule := TransformRule{Name: "cmd", Match: "**/*.b64", Decode: []string{"base64", "-d"}}
+		tr, err := rule.transform()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := tr.Decode("x.b64", []byte(b64.EncodeToString([]byte("hello"))))
+		if err != nil || string(plain) != "hello" {
+			t.Errorf("Expected command transform to decode, got %q (%v)", plain, err)
+		}
+	}
+	if _, err := (TransformRule{Name: "x", Match: "**/*.age"}).transform(); err == nil {
+		t.Error("Expected a rule without decode command to be rejected")
+	}
+}
+
+func TestSync(t *testing.T) {
+	laptop := newTestMMQ(t)
+	server := newTestMMQ(t)
+	laptop.SetActor("laptop")
+	server.SetActor("server")
+
+	index := func(m *MMQ, path, content string) {
+		t.Helper()
+		if err := m.IndexDocument(Document{Collection: "docs", Path: path, Title: path, Content: content, ModifiedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := func(m *MMQ, path string) string {
+		doc, err := m.GetDocumentByPath("docs/" + path)
+		if err != nil {
+			return ""
+		}
+		return doc.Content
+	}
+	sync := func(conflict string) *SyncResult {
+		t.Helper()
+		result, err := laptop.Sync(server.cfg.DBPath, SyncOptions{Conflict: conflict})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// 首次同步比较全部内容
+	index(laptop, "x.md", "laptop x")
+	index(server, "y.md", "server y")
+	if err := laptop.StoreMemory(Memory{Type: MemoryTypeFact, Content: "User prefers vim", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	result := sync("")
+	if !result.Initial || result.Pushed != 2 || result.Pulled != 1 {
+		t.Fatalf("Unexpected first sync: %+v", result)
+	}
+	if content(server, "x.md") != "laptop x" || content(laptop, "y.md") != "server y" {
+		t.Fatal("Expected documents on both sides after first sync")
+	}
+	if n, _ := server.CountMemories(); n != 1 {
+		t.Errorf("Expected memory pushed to server, got %d", n)
+	}
+
+	// 同步写入的变更不会被同步回来
+	if result := sync(""); result.Pushed != 0 || result.Pulled != 0 {
+		t.Fatalf("Expected nothing to sync, got %+v", result)
+	}
+
+	// 增量：两端各自修改不同的文档
+	index(laptop, "x.md", "laptop x v2")
+	if err := server.DeleteDocument("y.md"); err != nil {
+		t.Fatal(err)
+	}
+	if result := sync(""); result.Pushed != 1 || result.Pulled != 1 || len(result.Conflicts) != 0 {
+		t.Fatalf("Unexpected incremental sync: %+v", result)
+	}
+	if content(server, "x.md") != "laptop x v2" || content(laptop, "y.md") != "" {
+		t.Error("Expected update pushed and removal pulled")
+	}
+	events, _ := server.Events(EventFilter{Types: []string{EventDocUpdated}})
+	if len(events) != 1 || events[0].Actor != "laptop" || events[0].Origin == "" {
+		t.Errorf("Expected synced event attributed to the laptop, got %+v", events)
+	}
+
+	// 冲突：manual 只报告，之后选择远端版本
+	index(laptop, "x.md", "laptop x v3")
+	index(server, "x.md", "server x v3")
+	result = sync("manual")
+	if result.Unresolved != 1 || result.Conflicts[0].Key != "docs/x.md" {
+		t.Fatalf("Expected one unresolved conflict, got %+v", result)
+	}
+	if content(laptop, "x.md") != "laptop x v3" || content(server, "x.md") != "server x v3" {
+		t.Error("Expected manual conflict to be left untouched")
+	}
+	result = sync("remote")
+	if result.Unresolved != 0 || result.Pulled != 1 || content(laptop, "x.md") != "server x v3" {
+		t.Fatalf("Expected remote version to win, got %+v", result)
+	}
+	if result := sync(""); result.Pushed != 0 || result.Pulled != 0 {
+		t.Errorf("Expected nothing to sync after resolving, got %+v", result)
+	}
+
+	if _, err := laptop.Sync("https://example.com/mmq", SyncOptions{}); err == nil {
+		t.Error("Expected HTTP remote to be rejected")
+	}
+	if _, err := laptop.Sync(laptop.cfg.DBPath, SyncOptions{}); err == nil {
+		t.Error("Expected syncing with itself to fail")
+	}
+
+	// 旧版本的远端不被迁移
+	oldPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := laptop.Sync(oldPath, SyncOptions{}); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("Expected out-of-date remote to be rejected, got %v", err)
+	}
+	if rows, err := db.Query("SELECT * FROM documents"); err == nil {
+		rows.Close()
+		t.Error("Expected the remote schema to be left unmigrated")
+	}
+}
+
+func TestRemoteCollections(t *testing.T) {
+	local := newTestMMQ(t)
+	team := newTestMMQ(t)
+
+	if err := local.IndexDocument(Document{Collection: "notes", Path: "deploy.md", Title: "Deploy", Content: "how we deploy kubernetes at home", ModifiedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := team.IndexDocument(Document{Collection: "wiki", Path: "k8s.md", Title: "K8s", Content: "team kubernetes runbook", ModifiedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := local.AddRemoteCollection("team", team.cfg.DBPath, "missing"); err == nil {
+		t.Error("Expected error for missing remote collection")
+	}
+	if err := local.AddRemoteCollection("team", "https://example.com", "wiki"); err == nil {
+		t.Error("Expected error for HTTP remote")
+	}
+	if err := local.AddRemoteCollection("team", team.cfg.DBPath, "wiki"); err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := local.GetCollection("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.Remote == "" || coll.RemoteCollection != "wiki" {
+		t.Errorf("Unexpected remote collection: %+v", coll)
+	}
+
+	// 搜索全部集合时融合本地和远端结果
+	results, err := local.Search("kubernetes", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]bool{}
+	for _, r := range results {
+		sources[r.Collection+"/"+r.Path] = true
+	}
+	if !sources["notes/deploy.md"] || !sources["team/k8s.md"] {
+		t.Fatalf("Expected fused local and remote results, got %v", sources)
+	}
+
+	// 只搜索远端集合
+	results, err = local.Search("kubernetes", SearchOptions{Limit: 10, Collection: "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Collection != "team" {
+		t.Fatalf("Expected only the remote result, got %+v", results)
+	}
+
+	// 旧版本的远端数据库不被迁移，直接拒绝
+	oldPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE documents (id INTEGER PRIMARY KEY, collection TEXT, path TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.AddRemoteCollection("old", oldPath, "wiki"); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("Expected out-of-date remote to be rejected, got %v", err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 1 {
+		t.Errorf("Expected the remote database to be left untouched, found %d tables", tables)
+	}
+}
+
+func TestIndexS3(t *testing.T) {
+	// 模拟 S3 兼容存储（path-style：/<bucket>/<key>）
+	objects := map[string]string{
+		"kb/guide.md":     "# Guide\nobject storage guide",
+		"kb/sub/faq.md":   "# FAQ\nfrequently asked",
+		"kb/image.png":    "binary",
+		"other/readme.md": "outside prefix",
+	}
+	etags := map[string]string{"kb/guide.md": "e1", "kb/sub/faq.md": "e2", "kb/image.png": "e3", "other/readme.md": "e4"}
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		if r.URL.Query().Get("list-type") == "2" {
+			prefix := r.URL.Query().Get("prefix")
+			fmt.Fprint(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					fmt.Fprintf(w, "<Contents><Key>%s</Key><ETag>&quot;%s&quot;</ETag><LastModified>2026-01-02T03:04:05Z</LastModified></Contents>", k, etags[k])
+				}
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+			return
+		}
+		content, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>")
+			return
+		}
+		gets++
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+
+	m := newTestMMQ(t)
+	m.cfg.S3Endpoint = srv.URL
+
+	summary, err := m.IndexDirectory("s3://bucket/kb", IndexOptions{Collection: "kb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 2 || summary.Skipped != 1 || gets != 2 {
+		t.Fatalf("Unexpected first index: %+v (gets=%d)", summary, gets)
+	}
+	doc, err := m.GetDocumentByPath("kb/sub/faq.md")
+	if err != nil || doc.Title != "FAQ" {
+		t.Fatalf("Expected faq document, got %+v (%v)", doc, err)
+	}
+
+	// ETag 未变化的对象不再下载
+	gets = 0
+	objects["kb/guide.md"] = "# Guide\nupdated guide"
+	etags["kb/guide.md"] = "e5"
+	delete(objects, "kb/sub/faq.md")
+	summary, err = m.UpdateCollection("kb", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated != 1 || summary.Unchanged != 0 || summary.Removed != 1 || gets != 1 {
+		t.Fatalf("Unexpected update: %+v (gets=%d)", summary, gets)
+	}
+
+	gets = 0
+	summary, err = m.UpdateCollection("kb", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unchanged != 1 || gets != 0 {
+		t.Fatalf("Expected unchanged objects to be skipped: %+v (gets=%d)", summary, gets)
+	}
+	if doc, _ := m.GetDocumentByPath("kb/guide.md"); doc == nil || !strings.Contains(doc.Content, "updated") {
+		t.Error("Expected updated guide content")
+	}
+}
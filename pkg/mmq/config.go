@@ -1,9 +1,21 @@
 package mmq
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/dyike/mmq/pkg/i18n"
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/memory"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
 )
 
 // Config MMQ配置
@@ -22,18 +34,158 @@ type Config struct {
 	ChunkSize int
 	// ChunkOverlap 分块重叠（字符数）
 	ChunkOverlap int
+	// ChunkRules 按文件扩展名（如 ".go"）覆盖分块大小和重叠
+	ChunkRules map[string]ChunkRule
 	// Threads LLM推理线程数
 	Threads int
 	// InactivityTimeout 模型空闲自动卸载时间
 	InactivityTimeout time.Duration
+	// DecayHalflives 各记忆类型的衰减半衰期（0 表示不衰减）
+	DecayHalflives map[MemoryType]time.Duration
+	// SessionBoost 回忆时当前会话记忆的相关度乘数
+	SessionBoost float64
+	// SanitizeLevel 记忆和文档注入 prompt 前的过滤级别（off/flag/strip）
+	SanitizeLevel string
+	// SystemPrompt 对话的默认 system prompt 说明（空表示内置说明），可用 {{date}}、{{pinned}}、{{collections}}
+	SystemPrompt string
+	// PIIPolicy 索引时的默认 PII 策略（off/flag/redact），可按集合覆盖
+	PIIPolicy string
+	// MemoryPIIPolicy 自动提取记忆时的 PII 策略
+	MemoryPIIPolicy string
+	// PIIPatterns 额外的 PII 检测正则（类别名 → 正则）
+	PIIPatterns map[string]string
+	// MaxDBSize 数据库大小上限（字节，0 表示不限制）
+	MaxDBSize int64
+	// MaxDocsPerCollection 单个集合的文档数上限（0 表示不限制）
+	MaxDocsPerCollection int
+	// MinFreeDisk 写入前要求的最小磁盘剩余空间（字节，0 表示不检查）
+	MinFreeDisk int64
+	// StrictCollections 索引到不存在的集合时报错，而不是自动创建
+	StrictCollections bool
+	// CaseInsensitivePaths 按路径查找文档（get、文件系统绝对路径）时忽略大小写，Windows 上默认开启
+	CaseInsensitivePaths bool
+	// LLMCacheBackend 查询扩展等 LLM 结果的缓存位置（db/file/memory）
+	LLMCacheBackend string
+	// LLMCachePath file 后端的缓存库路径（默认与主库同目录的 llm_cache.db）
+	LLMCachePath string
+	// LLMCacheMaxEntries 缓存条目上限，超出后淘汰最早的条目（0 表示不限制）
+	LLMCacheMaxEntries int
+	// AnswerCache 对话复用相似问题的缓存回答（语料版本不变时）
+	AnswerCache bool
+	// AnswerCacheThreshold 问题嵌入的余弦相似度达到该值才命中
+	AnswerCacheThreshold float64
+	// AnswerCacheTTL 缓存回答的有效期
+	AnswerCacheTTL time.Duration
+	// AnswerCacheMaxEntries 缓存回答的条目上限（0 表示不限制）
+	AnswerCacheMaxEntries int
+	// RerankCache 缓存重排分数，同一查询、分块和重排模型不再重复计算
+	RerankCache bool
+	// RerankCacheTTL 缓存分数的有效期
+	RerankCacheTTL time.Duration
+	// RerankCacheMaxEntries 缓存分数的条目上限（0 表示不限制）
+	RerankCacheMaxEntries int
+	// CandidateMultiplier 每路检索召回 Limit×倍数 个候选，越大召回越高、越慢
+	CandidateMultiplier float64
+	// RerankLimit 送入重排模型的候选上限
+	RerankLimit int
+	// RetrievalParallelism 同时执行的检索路数上限（混合检索的各路、查询扩展的各变体）
+	RetrievalParallelism int
+	// ScoreNormalization 过滤 MinScore 前的分数归一化方式（raw/minmax/calibrated）
+	ScoreNormalization string
+	// RerankBlend 重排混合权重；为空时使用 TuneRerankBlend 为本库学习的权重，再没有则用默认值
+	RerankBlend *RerankBlend
+	// AccessBoost 最近查看过的文档的排序加成（0 表示关闭）：分数乘以 1 + AccessBoost×0.5^(距上次查看/AccessHalflife)
+	AccessBoost float64
+	// AccessHalflife 查看加成的半衰期
+	AccessHalflife time.Duration
+	// VectorPrefilter 向量空间的分块数达到该值时，先按过滤条件或全文检索预筛选候选再计算距离（0 表示总是扫描全部向量）
+	VectorPrefilter int
+	// VectorPrefilterCandidates 预筛选的候选分块上限，超出时仍扫描全部向量
+	VectorPrefilterCandidates int
+	// VectorPrefilterLexical 没有过滤条件时用全文检索预筛选（默认关闭：与查询没有共同词的文档不会出现在向量结果中）
+	VectorPrefilterLexical bool
+	// MaxContextChars 返回的每条上下文文本的最大字符数，超出时截取以最匹配分块为中心的片段（0 表示不截断）
+	MaxContextChars int
+	// Personas 命名的助手人设（chat --persona 选择）
+	Personas map[string]Persona
+	// Guardrails 检索和生成前后的护栏规则（正则或模型检查）
+	Guardrails []GuardrailRule
+	// Transforms 源文件内容转换（如解密 age/gpg 加密的笔记后再索引）
+	Transforms []TransformRule
+	// PluginDir 插件目录（每个子目录一个插件，由 plugin.json 描述）
+	PluginDir string
+	// PipelineDir 检索流水线目录（每个 YAML 文件一条流水线，search --pipeline 按文件名选择）
+	PipelineDir string
+	// Actor 记录到变更事件日志的执行者（如 cli、agent 名称）
+	Actor string
+	// S3Endpoint S3 兼容存储的地址（MinIO、R2 等，默认 AWS；凭证取自 AWS_* 环境变量）
+	S3Endpoint string
+	// S3Region S3 区域（默认取 AWS_REGION，再默认 us-east-1）
+	S3Region string
+	// ModelPins 固定的模型文件校验和（文件名 → SHA256），不一致的模型拒绝下载和加载
+	ModelPins map[string]string
+	// LibPath llama.cpp 动态库目录（mmq setup 自动写入；YZMA_LIB 环境变量优先）
+	LibPath string
+	// EmbeddingAPIURL OpenAI 兼容嵌入接口地址（如 http://localhost:11434/v1），设置后用远程嵌入代替本地模型
+	// 纯 Go 构建（-tags purego）没有本地模型，只能使用远程嵌入；默认取 MMQ_EMBED_BASE_URL
+	EmbeddingAPIURL string
+	// EmbeddingAPIModel 远程嵌入模型名，向量按此名称记录；默认取 MMQ_EMBED_MODEL
+	EmbeddingAPIModel string
+	// EmbeddingAPIKey 远程嵌入接口的 API Key，只从 MMQ_EMBED_API_KEY 读取
+	EmbeddingAPIKey string
+	// DisplayTimezone CLI 输出的显示时区（IANA 名称或 local，空表示不转换）
+	DisplayTimezone string
+	// DateFormat CLI 输出的日期格式（rfc3339/iso/us/eu/de 或 Go 时间格式）
+	DateFormat string
+	// DisplayLocale CLI 输出的数字区域（如 en、de、fr），决定千分位和小数点
+	DisplayLocale string
+	// RelativeTimes 列表视图显示相对时间（如 2h ago）
+	RelativeTimes bool
+	// Language CLI 消息的语言（en/zh；空或 auto 按 LC_ALL、LC_MESSAGES、LANG 选择）
+	Language string
+}
+
+// ChunkRule 一类文档的分块参数，为 0 的字段使用全局的 ChunkSize/ChunkOverlap
+type ChunkRule struct {
+	Size    int `json:"size"`
+	Overlap int `json:"overlap"`
+}
+
+// ChunkSettings 返回路径对应的分块大小和重叠：扩展名有规则时使用规则，否则使用全局设置
+func (c *Config) ChunkSettings(path string) (size, overlap int) {
+	size, overlap = c.ChunkSize, c.ChunkOverlap
+	if size == 0 {
+		size = store.ChunkSizeChars
+	}
+	if overlap == 0 {
+		overlap = store.ChunkOverlapChars
+	}
+	if rule, ok := c.ChunkRules[strings.ToLower(filepath.Ext(path))]; ok {
+		if rule.Size > 0 {
+			size = rule.Size
+		}
+		if rule.Overlap > 0 {
+			overlap = rule.Overlap
+		}
+	}
+	return size, overlap
 }
 
+// LLM 缓存后端
+const (
+	LLMCacheDB     = "db"     // 主数据库中的 llm_cache 表
+	LLMCacheFile   = "file"   // 独立的 SQLite 文件
+	LLMCacheMemory = "memory" // 进程内 LRU，不落盘
+)
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() Config {
 	homeDir, _ := os.UserHomeDir()
 
 	return Config{
 		DBPath:            filepath.Join(homeDir, ".mmq", "memory.db"),
+		PluginDir:         filepath.Join(homeDir, ".mmq", "plugins"),
+		PipelineDir:       filepath.Join(homeDir, ".mmq", "pipelines"),
 		CacheDir:          filepath.Join(homeDir, ".cache", "mmq", "models"),
 		EmbeddingModel:    "embeddinggemma-300M-Q8_0",
 		RerankModel:       "qwen3-reranker-0.6b-q8_0",
@@ -42,9 +194,637 @@ func DefaultConfig() Config {
 		ChunkOverlap:      480,             // 15% overlap
 		Threads:           4,               // 4线程
 		InactivityTimeout: 5 * time.Minute, // 5分钟自动卸载
+		DecayHalflives:    defaultDecayHalflives(),
+		SessionBoost:      memory.DefaultSessionBoost,
+		SanitizeLevel:     string(rag.SanitizeStrip),
+		PIIPolicy:         string(pii.PolicyOff),
+		MemoryPIIPolicy:   string(pii.PolicyOff),
+		MinFreeDisk:       DefaultMinFreeDisk,
+		LLMCacheBackend:   LLMCacheDB,
+
+		AnswerCacheThreshold:  DefaultAnswerCacheThreshold,
+		AnswerCacheTTL:        DefaultAnswerCacheTTL,
+		AnswerCacheMaxEntries: DefaultAnswerCacheMaxEntries,
+
+		RerankCache:           true,
+		RerankCacheTTL:        DefaultRerankCacheTTL,
+		RerankCacheMaxEntries: DefaultRerankCacheMaxEntries,
+
+		CaseInsensitivePaths: runtime.GOOS == "windows",
+
+		EmbeddingAPIURL:   os.Getenv("MMQ_EMBED_BASE_URL"),
+		EmbeddingAPIModel: os.Getenv("MMQ_EMBED_MODEL"),
+		EmbeddingAPIKey:   os.Getenv("MMQ_EMBED_API_KEY"),
+
+		CandidateMultiplier:  rag.DefaultCandidateMultiplier,
+		RerankLimit:          rag.DefaultRerankLimit,
+		RetrievalParallelism: rag.DefaultLegParallelism,
+		ScoreNormalization:   string(rag.NormalizeRaw),
+		AccessHalflife:       DefaultAccessHalflife,
+
+		VectorPrefilter:           store.DefaultPrefilterMinVectors,
+		VectorPrefilterCandidates: store.DefaultPrefilterCandidates,
+		MaxContextChars:           DefaultMaxContextChars,
 	}
 }
 
+// DefaultAccessHalflife 查看加成的默认半衰期
+const DefaultAccessHalflife = 7 * 24 * time.Hour
+
+// 回答缓存默认值
+const (
+	DefaultAnswerCacheThreshold  = 0.95
+	DefaultAnswerCacheTTL        = 24 * time.Hour
+	DefaultAnswerCacheMaxEntries = 1000
+)
+
+// 重排分数缓存默认值
+const (
+	DefaultRerankCacheTTL        = 7 * 24 * time.Hour
+	DefaultRerankCacheMaxEntries = 100000
+)
+
+// DefaultMinFreeDisk 默认的最小磁盘剩余空间
+const DefaultMinFreeDisk = 64 << 20
+
+// defaultDecayHalflives 默认的记忆衰减半衰期（事实不衰减，对话最快）
+func defaultDecayHalflives() map[MemoryType]time.Duration {
+	halflives := make(map[MemoryType]time.Duration)
+	for t, h := range memory.DefaultDecayHalflives() {
+		halflives[MemoryType(t)] = h
+	}
+	return halflives
+}
+
+// DefaultConfigPath 返回默认配置文件路径（可通过 MMQ_CONFIG 覆盖）
+func DefaultConfigPath() string {
+	if path := os.Getenv("MMQ_CONFIG"); path != "" {
+		return path
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mmq", "config.json")
+}
+
+// fileConfig 配置文件结构
+//
+//	{
+//	  "database": {
+//	    "path": "~/.mmq/index.db"
+//	  },
+//	  "memory": {
+//	    "decay_halflife": {"conversation": "7d", "episodic": "30d", "fact": "0"},
+//	    "session_boost": 1.5
+//	  },
+//	  "prompt": {
+//	    "sanitize": "strip",
+//	    "system": "你是知识库助手。今天是 {{date}}，可检索的集合：{{collections}}。"
+//	  },
+//	  "pii": {
+//	    "policy": "flag",
+//	    "memory_policy": "redact",
+//	    "patterns": {"employee_id": "EMP-\\d{6}"}
+//	  },
+//	  "quota": {
+//	    "max_db_size": "2GB",
+//	    "max_docs_per_collection": 50000,
+//	    "min_free_disk": "500MB"
+//	  },
+//	  "index": {
+//	    "strict_collections": false,
+//	    "chunking": {".md": {"size": 1200, "overlap": 200}, ".go": {"size": 400, "overlap": 50}}
+//	  },
+//	  "cache": {
+//	    "backend": "file",
+//	    "path": "~/.mmq/llm_cache.db",
+//	    "max_entries": 10000
+//	  },
+//	  "retrieval": {
+//	    "candidate_multiplier": 3,
+//	    "rerank_limit": 60,
+//	    "parallelism": 8,
+//	    "score_normalization": "calibrated",
+//	    "rerank_blend": {"top": 0.8, "mid": 0.6, "tail": 0.3},
+//	    "access_boost": 0.2,
+//	    "access_halflife": "7d",
+//	    "vector_prefilter": 50000,
+//	    "vector_prefilter_candidates": 5000,
+//	    "vector_prefilter_lexical": false,
+//	    "max_context_chars": 4000
+//	  },
+//	  "personas": {
+//	    "coder": {
+//	      "system_prompt": "你是一名资深 Go 工程师……",
+//	      "memory_namespace": "coder",
+//	      "collections": ["code", "design-docs"],
+//	      "retrieval": {"strategy": "hybrid", "limit": 5, "min_score": 0.3}
+//	    }
+//	  },
+//	  "guardrails": [
+//	    {"name": "no-secrets", "stage": "post_generation", "type": "regex", "pattern": "sk-[A-Za-z0-9]{20,}", "action": "redact"},
+//	    {"name": "safety", "stage": "pre_generation", "type": "llm"}
+//	  ],
+//	  "transforms": [
+//	    {"name": "age", "match": "**/*.age", "decode": ["age", "-d", "-i", "~/.age/key.txt"], "encode": ["age", "-r", "age1..."]}
+//	  ],
+//	  "plugins": {
+//	    "dir": "~/.mmq/plugins"
+//	  },
+//	  "s3": {
+//	    "endpoint": "http://localhost:9000",
+//	    "region": "us-east-1"
+//	  },
+//	  "models": {
+//	    "pins": {"embeddinggemma-300M-Q8_0.gguf": "<sha256>"},
+//	    "lib_path": "~/.cache/mmq/lib",
+//	    "embedding_api": {"base_url": "http://localhost:11434/v1", "model": "nomic-embed-text"}
+//	  },
+//	  "display": {
+//	    "timezone": "Asia/Shanghai",
+//	    "date_format": "iso",
+//	    "locale": "de",
+//	    "relative_times": true,
+//	    "language": "zh"
+//	  }
+//	}
+type fileConfig struct {
+	Database struct {
+		Path string `json:"path"`
+	} `json:"database"`
+	Memory struct {
+		DecayHalflife map[string]string `json:"decay_halflife"`
+		SessionBoost  float64           `json:"session_boost"`
+	} `json:"memory"`
+	Prompt struct {
+		Sanitize string `json:"sanitize"`
+		System   string `json:"system"`
+	} `json:"prompt"`
+	PII struct {
+		Policy       string            `json:"policy"`
+		MemoryPolicy string            `json:"memory_policy"`
+		Patterns     map[string]string `json:"patterns"`
+	} `json:"pii"`
+	Quota struct {
+		MaxDBSize            string `json:"max_db_size"`
+		MaxDocsPerCollection int    `json:"max_docs_per_collection"`
+		MinFreeDisk          string `json:"min_free_disk"`
+	} `json:"quota"`
+	Index struct {
+		StrictCollections    *bool                `json:"strict_collections"`
+		CaseInsensitivePaths *bool                `json:"case_insensitive_paths"`
+		Chunking             map[string]ChunkRule `json:"chunking"`
+	} `json:"index"`
+	Cache struct {
+		Backend    string `json:"backend"`
+		Path       string `json:"path"`
+		MaxEntries int    `json:"max_entries"`
+	} `json:"cache"`
+	AnswerCache struct {
+		Enabled    *bool   `json:"enabled"`
+		Threshold  float64 `json:"threshold"`
+		TTL        string  `json:"ttl"`
+		MaxEntries *int    `json:"max_entries"`
+	} `json:"answer_cache"`
+	RerankCache struct {
+		Enabled    *bool  `json:"enabled"`
+		TTL        string `json:"ttl"`
+		MaxEntries *int   `json:"max_entries"`
+	} `json:"rerank_cache"`
+	Retrieval struct {
+		CandidateMultiplier float64      `json:"candidate_multiplier"`
+		RerankLimit         int          `json:"rerank_limit"`
+		Parallelism         int          `json:"parallelism"`
+		ScoreNormalization  string       `json:"score_normalization"`
+		RerankBlend         *RerankBlend `json:"rerank_blend"`
+		AccessBoost         float64      `json:"access_boost"`
+		AccessHalflife      string       `json:"access_halflife"`
+		VectorPrefilter     *int         `json:"vector_prefilter"`
+		PrefilterCandidates int          `json:"vector_prefilter_candidates"`
+		PrefilterLexical    bool         `json:"vector_prefilter_lexical"`
+		MaxContextChars     *int         `json:"max_context_chars"`
+	} `json:"retrieval"`
+	Personas   map[string]filePersona `json:"personas"`
+	Guardrails []GuardrailRule        `json:"guardrails"`
+	Transforms []TransformRule        `json:"transforms"`
+	Plugins    struct {
+		Dir string `json:"dir"`
+	} `json:"plugins"`
+	Pipelines struct {
+		Dir string `json:"dir"`
+	} `json:"pipelines"`
+	S3 struct {
+		Endpoint string `json:"endpoint"`
+		Region   string `json:"region"`
+	} `json:"s3"`
+	Models struct {
+		Pins         map[string]string `json:"pins"`
+		LibPath      string            `json:"lib_path"`
+		EmbeddingAPI struct {
+			BaseURL string `json:"base_url"`
+			Model   string `json:"model"`
+		} `json:"embedding_api"`
+	} `json:"models"`
+	Display struct {
+		Timezone      string `json:"timezone"`
+		DateFormat    string `json:"date_format"`
+		Locale        string `json:"locale"`
+		RelativeTimes *bool  `json:"relative_times"`
+		Language      string `json:"language"`
+	} `json:"display"`
+}
+
+// LoadFile 从配置文件加载配置，覆盖已有字段
+// 文件不存在时不报错
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(expandPath(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if fc.Database.Path != "" {
+		c.DBPath = expandPath(fc.Database.Path)
+	}
+
+	if len(fc.Memory.DecayHalflife) > 0 {
+		if c.DecayHalflives == nil {
+			c.DecayHalflives = defaultDecayHalflives()
+		}
+		for memType, value := range fc.Memory.DecayHalflife {
+			d, err := ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid decay_halflife for %s: %w", memType, err)
+			}
+			c.DecayHalflives[MemoryType(memType)] = d
+		}
+	}
+
+	if fc.Memory.SessionBoost > 0 {
+		c.SessionBoost = fc.Memory.SessionBoost
+	}
+
+	if fc.Prompt.Sanitize != "" {
+		level, err := rag.ParseSanitizeLevel(fc.Prompt.Sanitize)
+		if err != nil {
+			return err
+		}
+		c.SanitizeLevel = string(level)
+	}
+	if fc.Prompt.System != "" {
+		c.SystemPrompt = fc.Prompt.System
+	}
+
+	if fc.PII.Policy != "" {
+		c.PIIPolicy = fc.PII.Policy
+	}
+	if fc.PII.MemoryPolicy != "" {
+		c.MemoryPIIPolicy = fc.PII.MemoryPolicy
+	}
+	if len(fc.PII.Patterns) > 0 {
+		if c.PIIPatterns == nil {
+			c.PIIPatterns = make(map[string]string)
+		}
+		for name, re := range fc.PII.Patterns {
+			c.PIIPatterns[name] = re
+		}
+	}
+
+	if fc.Quota.MaxDBSize != "" {
+		size, err := ParseSize(fc.Quota.MaxDBSize)
+		if err != nil {
+			return fmt.Errorf("invalid quota.max_db_size: %w", err)
+		}
+		c.MaxDBSize = size
+	}
+	if fc.Quota.MaxDocsPerCollection > 0 {
+		c.MaxDocsPerCollection = fc.Quota.MaxDocsPerCollection
+	}
+	if fc.Quota.MinFreeDisk != "" {
+		size, err := ParseSize(fc.Quota.MinFreeDisk)
+		if err != nil {
+			return fmt.Errorf("invalid quota.min_free_disk: %w", err)
+		}
+		c.MinFreeDisk = size
+	}
+
+	if fc.Index.StrictCollections != nil {
+		c.StrictCollections = *fc.Index.StrictCollections
+	}
+	if fc.Index.CaseInsensitivePaths != nil {
+		c.CaseInsensitivePaths = *fc.Index.CaseInsensitivePaths
+	}
+	for ext, rule := range fc.Index.Chunking {
+		if c.ChunkRules == nil {
+			c.ChunkRules = make(map[string]ChunkRule)
+		}
+		c.ChunkRules[ext] = rule
+	}
+
+	if fc.Cache.Backend != "" {
+		c.LLMCacheBackend = fc.Cache.Backend
+	}
+	if fc.Cache.Path != "" {
+		c.LLMCachePath = expandPath(fc.Cache.Path)
+	}
+	if fc.Cache.MaxEntries > 0 {
+		c.LLMCacheMaxEntries = fc.Cache.MaxEntries
+	}
+
+	if fc.AnswerCache.Enabled != nil {
+		c.AnswerCache = *fc.AnswerCache.Enabled
+	}
+	if fc.AnswerCache.Threshold != 0 {
+		c.AnswerCacheThreshold = fc.AnswerCache.Threshold
+	}
+	if fc.AnswerCache.TTL != "" {
+		d, err := ParseDuration(fc.AnswerCache.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid answer_cache.ttl: %w", err)
+		}
+		c.AnswerCacheTTL = d
+	}
+	if fc.AnswerCache.MaxEntries != nil {
+		c.AnswerCacheMaxEntries = *fc.AnswerCache.MaxEntries
+	}
+
+	if fc.RerankCache.Enabled != nil {
+		c.RerankCache = *fc.RerankCache.Enabled
+	}
+	if fc.RerankCache.TTL != "" {
+		d, err := ParseDuration(fc.RerankCache.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid rerank_cache.ttl: %w", err)
+		}
+		c.RerankCacheTTL = d
+	}
+	if fc.RerankCache.MaxEntries != nil {
+		c.RerankCacheMaxEntries = *fc.RerankCache.MaxEntries
+	}
+
+	if fc.Retrieval.CandidateMultiplier > 0 {
+		c.CandidateMultiplier = fc.Retrieval.CandidateMultiplier
+	}
+	if fc.Retrieval.RerankLimit > 0 {
+		c.RerankLimit = fc.Retrieval.RerankLimit
+	}
+	if fc.Retrieval.Parallelism != 0 {
+		c.RetrievalParallelism = fc.Retrieval.Parallelism
+	}
+	if fc.Retrieval.ScoreNormalization != "" {
+		c.ScoreNormalization = fc.Retrieval.ScoreNormalization
+	}
+	if fc.Retrieval.RerankBlend != nil {
+		c.RerankBlend = fc.Retrieval.RerankBlend
+	}
+	if fc.Retrieval.AccessBoost != 0 {
+		c.AccessBoost = fc.Retrieval.AccessBoost
+	}
+	if fc.Retrieval.AccessHalflife != "" {
+		d, err := ParseDuration(fc.Retrieval.AccessHalflife)
+		if err != nil {
+			return fmt.Errorf("invalid retrieval.access_halflife: %w", err)
+		}
+		c.AccessHalflife = d
+	}
+	if fc.Retrieval.VectorPrefilter != nil {
+		c.VectorPrefilter = *fc.Retrieval.VectorPrefilter
+	}
+	if fc.Retrieval.PrefilterCandidates > 0 {
+		c.VectorPrefilterCandidates = fc.Retrieval.PrefilterCandidates
+	}
+	if fc.Retrieval.PrefilterLexical {
+		c.VectorPrefilterLexical = true
+	}
+	if fc.Retrieval.MaxContextChars != nil {
+		c.MaxContextChars = *fc.Retrieval.MaxContextChars
+	}
+
+	c.Guardrails = append(c.Guardrails, fc.Guardrails...)
+	c.Transforms = append(c.Transforms, fc.Transforms...)
+	if fc.Plugins.Dir != "" {
+		c.PluginDir = expandPath(fc.Plugins.Dir)
+	}
+	if fc.Pipelines.Dir != "" {
+		c.PipelineDir = expandPath(fc.Pipelines.Dir)
+	}
+	if fc.S3.Endpoint != "" {
+		c.S3Endpoint = fc.S3.Endpoint
+	}
+	if fc.S3.Region != "" {
+		c.S3Region = fc.S3.Region
+	}
+	if fc.Display.Timezone != "" {
+		c.DisplayTimezone = fc.Display.Timezone
+	}
+	if fc.Display.DateFormat != "" {
+		c.DateFormat = fc.Display.DateFormat
+	}
+	if fc.Display.Locale != "" {
+		c.DisplayLocale = fc.Display.Locale
+	}
+	if fc.Display.RelativeTimes != nil {
+		c.RelativeTimes = *fc.Display.RelativeTimes
+	}
+	if fc.Display.Language != "" {
+		if _, err := i18n.Parse(fc.Display.Language); err != nil {
+			return fmt.Errorf("invalid display.language: %w", err)
+		}
+		c.Language = fc.Display.Language
+	}
+	if len(fc.Models.Pins) > 0 {
+		if c.ModelPins == nil {
+			c.ModelPins = make(map[string]string)
+		}
+		for name, sum := range fc.Models.Pins {
+			c.ModelPins[name] = sum
+		}
+	}
+	if fc.Models.LibPath != "" {
+		c.LibPath = expandPath(fc.Models.LibPath)
+	}
+	if fc.Models.EmbeddingAPI.BaseURL != "" && os.Getenv("MMQ_EMBED_BASE_URL") == "" {
+		c.EmbeddingAPIURL = fc.Models.EmbeddingAPI.BaseURL
+	}
+	if fc.Models.EmbeddingAPI.Model != "" && os.Getenv("MMQ_EMBED_MODEL") == "" {
+		c.EmbeddingAPIModel = fc.Models.EmbeddingAPI.Model
+	}
+
+	for name, fp := range fc.Personas {
+		persona, err := fp.persona(name)
+		if err != nil {
+			return err
+		}
+		if c.Personas == nil {
+			c.Personas = make(map[string]Persona)
+		}
+		c.Personas[name] = persona
+	}
+
+	return nil
+}
+
+// SetFileValue 在配置文件中设置一个配置项（如 "models.lib_path"），保留文件中的其他内容
+// 文件或上级目录不存在时自动创建
+func SetFileValue(path, key string, value interface{}) error {
+	path = expandPath(path)
+	root := map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	parts := strings.Split(key, ".")
+	node := root
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			node[part] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = value
+
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// ParseDuration 解析时长，在 time.ParseDuration 基础上支持天（d）和周（w）
+// 例如 "7d"、"2w"、"36h"、"1d12h"；"0" 表示 0
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] == '.' || (rest[i] >= '0' && rest[i] <= '9')) {
+			i++
+		}
+		if i == 0 || i >= len(rest) {
+			break
+		}
+
+		var unit time.Duration
+		switch rest[i] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		default:
+			// 剩余部分交给标准库解析
+			d, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration: %s", s)
+			}
+			return total + d, nil
+		}
+
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		total += time.Duration(n * float64(unit))
+		rest = rest[i+1:]
+	}
+
+	if rest != "" {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return total, nil
+}
+
+// ParseTTL 解析记忆存活时长，支持策略名 session、sprint 以及 ParseDuration 格式
+func ParseTTL(s string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "session":
+		return TTLSession, nil
+	case "sprint":
+		return TTLSprint, nil
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("ttl must not be negative: %s", s)
+	}
+	return d, nil
+}
+
+// ParseSize 解析字节大小，支持 B/KB/MB/GB/TB（1024 进制，大小写不敏感）
+// 例如 "500MB"、"2GB"、"1.5G"、"4096"；"0" 表示 0
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	i := 0
+	for i < len(s) && (s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	var unit float64
+	switch strings.TrimSuffix(strings.TrimSpace(s[i:]), "B") {
+	case "":
+		unit = 1
+	case "K":
+		unit = 1 << 10
+	case "M":
+		unit = 1 << 20
+	case "G":
+		unit = 1 << 30
+	case "T":
+		unit = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(n * unit), nil
+}
+
+// FormatSize 将字节数格式化为易读形式，如 "1.5 GB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
 // Validate 验证配置
 func (c *Config) Validate() error {
 	if c.DBPath == "" {
@@ -73,6 +853,30 @@ func (c *Config) Validate() error {
 		c.ChunkOverlap = 480
 	}
 
+	// 扩展名统一为小写、带点的形式
+	if len(c.ChunkRules) > 0 {
+		rules := make(map[string]ChunkRule, len(c.ChunkRules))
+		for ext, rule := range c.ChunkRules {
+			key := strings.ToLower(strings.TrimSpace(ext))
+			if key == "" {
+				return fmt.Errorf("chunking rule needs a file extension")
+			}
+			if !strings.HasPrefix(key, ".") {
+				key = "." + key
+			}
+			if rule.Size < 0 || rule.Overlap < 0 {
+				return fmt.Errorf("chunking rule for %s: size and overlap must not be negative", key)
+			}
+			rules[key] = rule
+		}
+		c.ChunkRules = rules
+		for ext := range rules {
+			if size, overlap := c.ChunkSettings("x" + ext); overlap >= size {
+				return fmt.Errorf("chunking rule for %s: overlap %d must be smaller than size %d", ext, overlap, size)
+			}
+		}
+	}
+
 	if c.EmbeddingModel == "" {
 		c.EmbeddingModel = "embeddinggemma-300M-Q8_0"
 	}
@@ -93,5 +897,127 @@ func (c *Config) Validate() error {
 		c.InactivityTimeout = 5 * time.Minute
 	}
 
+	if c.DecayHalflives == nil {
+		c.DecayHalflives = defaultDecayHalflives()
+	}
+
+	if c.SessionBoost == 0 {
+		c.SessionBoost = memory.DefaultSessionBoost
+	}
+
+	level, err := rag.ParseSanitizeLevel(c.SanitizeLevel)
+	if err != nil {
+		return err
+	}
+	c.SanitizeLevel = string(level)
+
+	for _, policy := range []*string{&c.PIIPolicy, &c.MemoryPIIPolicy} {
+		p, err := pii.ParsePolicy(*policy)
+		if err != nil {
+			return err
+		}
+		*policy = string(p)
+	}
+
+	if _, err := pii.NewScanner(c.PIIPatterns); err != nil {
+		return err
+	}
+
+	if c.MaxDBSize < 0 || c.MaxDocsPerCollection < 0 || c.MinFreeDisk < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+
+	switch c.LLMCacheBackend {
+	case "":
+		c.LLMCacheBackend = LLMCacheDB
+	case LLMCacheDB, LLMCacheMemory:
+	case LLMCacheFile:
+		if c.LLMCachePath == "" {
+			c.LLMCachePath = filepath.Join(filepath.Dir(c.DBPath), "llm_cache.db")
+		}
+	default:
+		return fmt.Errorf("invalid cache backend %q (use db, file or memory)", c.LLMCacheBackend)
+	}
+	if c.LLMCacheMaxEntries < 0 {
+		return fmt.Errorf("cache max_entries must not be negative")
+	}
+
+	if c.AnswerCacheThreshold == 0 {
+		c.AnswerCacheThreshold = DefaultAnswerCacheThreshold
+	}
+	if c.AnswerCacheTTL == 0 {
+		c.AnswerCacheTTL = DefaultAnswerCacheTTL
+	}
+	if c.AnswerCacheThreshold < 0 || c.AnswerCacheThreshold > 1 {
+		return fmt.Errorf("answer_cache.threshold must be between 0 and 1")
+	}
+	if c.AnswerCacheTTL < 0 || c.AnswerCacheMaxEntries < 0 {
+		return fmt.Errorf("answer_cache ttl and max_entries must not be negative")
+	}
+
+	if c.RerankCacheTTL == 0 {
+		c.RerankCacheTTL = DefaultRerankCacheTTL
+	}
+	if c.RerankCacheTTL < 0 || c.RerankCacheMaxEntries < 0 {
+		return fmt.Errorf("rerank_cache ttl and max_entries must not be negative")
+	}
+
+	if c.CandidateMultiplier == 0 {
+		c.CandidateMultiplier = rag.DefaultCandidateMultiplier
+	}
+	if c.RerankLimit == 0 {
+		c.RerankLimit = rag.DefaultRerankLimit
+	}
+	if c.CandidateMultiplier < 1 || c.RerankLimit < 0 {
+		return fmt.Errorf("candidate_multiplier must be at least 1 and rerank_limit must not be negative")
+	}
+	if c.RetrievalParallelism == 0 {
+		c.RetrievalParallelism = rag.DefaultLegParallelism
+	}
+	if c.RetrievalParallelism < 0 {
+		return fmt.Errorf("retrieval.parallelism must not be negative")
+	}
+
+	normalization, err := rag.ParseScoreNormalization(c.ScoreNormalization)
+	if err != nil {
+		return err
+	}
+	c.ScoreNormalization = string(normalization)
+	if c.RerankBlend != nil {
+		if err := c.RerankBlend.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.AccessHalflife == 0 {
+		c.AccessHalflife = DefaultAccessHalflife
+	}
+	if c.AccessBoost < 0 || c.AccessHalflife < 0 {
+		return fmt.Errorf("access_boost and access_halflife must not be negative")
+	}
+	if c.VectorPrefilterCandidates == 0 {
+		c.VectorPrefilterCandidates = store.DefaultPrefilterCandidates
+	}
+	if c.VectorPrefilter < 0 || c.VectorPrefilterCandidates < 0 {
+		return fmt.Errorf("vector_prefilter and vector_prefilter_candidates must not be negative")
+	}
+	if c.MaxContextChars < 0 {
+		return fmt.Errorf("max_context_chars must not be negative")
+	}
+
+	for _, rule := range c.Guardrails {
+		if _, err := rule.hook(nil); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.Transforms {
+		if _, err := rule.transform(); err != nil {
+			return err
+		}
+	}
+
+	if err := llm.ValidatePins(c.ModelPins); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,100 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// SuggestContextOptions 上下文建议选项
+type SuggestContextOptions struct {
+	Samples   int                                 // 采样文档数（默认 8）
+	MaxChars  int                                 // 每个样本截取的字符数（默认 600）
+	Generator func(prompt string) (string, error) // 生成模型，为空时使用本地 generate 模型
+}
+
+// ContextSuggestion 生成的集合级上下文草稿
+type ContextSuggestion struct {
+	Path     string   `json:"path"`               // mmq://collection
+	Content  string   `json:"content"`            // 草稿内容
+	Existing string   `json:"existing,omitempty"` // 当前已有的上下文
+	Samples  []string `json:"samples"`            // 参考的文档路径
+}
+
+// contextSuggestPrompt 生成集合描述的 prompt
+const contextSuggestPrompt = `以下是文档集合 "%s" 中随机抽取的 %d 篇文档片段。
+请写一段 1-3 句的集合描述，说明这些文档的主题、类型和用途，供检索时作为背景上下文。
+
+要求：
+- 使用与文档相同的语言
+- 只描述整个集合的共性，不要逐篇列举
+- 不要编造文档中没有的信息
+- 只输出描述本身，不要加前缀或引号
+
+%s
+描述：`
+
+// SuggestContext 采样集合中的文档，用生成模型起草集合级上下文（不保存）
+func (m *MMQ) SuggestContext(collection string, opts SuggestContextOptions) (*ContextSuggestion, error) {
+	if opts.Samples <= 0 {
+		opts.Samples = 8
+	}
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = 600
+	}
+	generate := opts.Generator
+	if generate == nil {
+		generate = func(prompt string) (string, error) {
+			genOpts := llm.DefaultGenerateOptions()
+			genOpts.Temperature = 0.3
+			genOpts.MaxTokens = 256
+			return m.llm.Generate(prompt, genOpts)
+		}
+	}
+
+	if exists, err := m.store.CollectionExists(collection); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("collection '%s' not found", collection)
+	}
+
+	docs, err := m.store.SampleDocuments(collection, opts.Samples)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("collection '%s' has no documents to sample", collection)
+	}
+
+	suggestion := &ContextSuggestion{Path: "mmq://" + collection}
+	if existing, err := m.store.GetContext(suggestion.Path); err == nil {
+		suggestion.Existing = existing.Content
+	}
+
+	var b strings.Builder
+	for i, doc := range docs {
+		suggestion.Samples = append(suggestion.Samples, doc.Path)
+		fmt.Fprintf(&b, "[%d] %s (%s)\n%s\n\n", i+1, doc.Title, doc.Path, truncateRunes(doc.Content, opts.MaxChars))
+	}
+
+	draft, err := generate(fmt.Sprintf(contextSuggestPrompt, collection, len(docs), b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate context: %w", err)
+	}
+	suggestion.Content = strings.Trim(strings.TrimSpace(draft), `"“”`)
+	if suggestion.Content == "" {
+		return nil, fmt.Errorf("model returned an empty description")
+	}
+
+	return suggestion, nil
+}
+
+// truncateRunes 按字符截断
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
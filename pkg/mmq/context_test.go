@@ -370,3 +370,140 @@ func TestContextWithCollections(t *testing.T) {
 		t.Logf("  %s: %s", ctx.Path, ctx.Content)
 	}
 }
+
+func TestContextTree(t *testing.T) {
+	m := newTestMMQ(t)
+
+	docs := []Document{
+		{Collection: "docs", Path: "api/auth.md", Title: "Auth", Content: "auth"},
+		{Collection: "docs", Path: "api/users.md", Title: "Users", Content: "users"},
+		{Collection: "docs", Path: "guide.md", Title: "Guide", Content: "guide"},
+		{Collection: "notes", Path: "todo.md", Title: "Todo", Content: "todo"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.AddContext("/", "global")
+	m.AddContext("mmq://docs", "documentation")
+	m.AddContext("mmq://docs/api", "API reference")
+	m.AddContext("mmq://docs/api/auth.md", "auth flow")
+
+	root, err := m.ContextTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.HasContext || root.Documents != 4 || root.Uncovered != 1 {
+		t.Errorf("Unexpected root: %+v", root)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 collections, got %d", len(root.Children))
+	}
+
+	docsNode, notesNode := root.Children[0], root.Children[1]
+	if docsNode.Path != "mmq://docs" || docsNode.Documents != 3 || docsNode.Uncovered != 0 {
+		t.Errorf("Unexpected docs node: %+v", docsNode)
+	}
+	if notesNode.HasContext || notesNode.Uncovered != 1 {
+		t.Errorf("Expected notes to be missing context: %+v", notesNode)
+	}
+
+	// 路径上下文挂在集合下，文档上下文挂在路径下
+	if len(docsNode.Children) != 1 {
+		t.Fatalf("Expected one path node under docs, got %d", len(docsNode.Children))
+	}
+	api := docsNode.Children[0]
+	if api.Kind != "path" || api.Documents != 2 || len(api.Children) != 1 {
+		t.Errorf("Unexpected api node: %+v", api)
+	}
+	if leaf := api.Children[0]; leaf.Kind != "document" || leaf.Documents != 1 {
+		t.Errorf("Unexpected document node: %+v", leaf)
+	}
+}
+
+func TestSuggestContext(t *testing.T) {
+	m := newTestMMQ(t)
+
+	for i, topic := range []string{"raft consensus", "paxos", "vector clocks"} {
+		doc := Document{Collection: "papers", Path: topic + ".md", Title: topic, Content: "Notes on " + topic}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatalf("doc %d: %v", i, err)
+		}
+	}
+	m.AddContext("mmq://papers", "old description")
+
+	var prompt string
+	suggestion, err := m.SuggestContext("papers", SuggestContextOptions{
+		Samples: 2,
+		Generator: func(p string) (string, error) {
+			prompt = p
+			return "  \"Distributed systems paper notes.\"\n", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suggestion.Path != "mmq://papers" || suggestion.Content != "Distributed systems paper notes." {
+		t.Errorf("Unexpected suggestion: %+v", suggestion)
+	}
+	if suggestion.Existing != "old description" || len(suggestion.Samples) != 2 {
+		t.Errorf("Expected existing context and 2 samples: %+v", suggestion)
+	}
+	if !strings.Contains(prompt, "Notes on") || !strings.Contains(prompt, `"papers"`) {
+		t.Errorf("Prompt should include sampled content and collection name: %s", prompt)
+	}
+
+	// 建议不会自动保存
+	if ctx, _ := m.GetContext("mmq://papers"); ctx == nil || ctx.Content != "old description" {
+		t.Errorf("SuggestContext should not modify stored context: %+v", ctx)
+	}
+
+	if _, err := m.SuggestContext("missing", SuggestContextOptions{Generator: func(string) (string, error) { return "x", nil }}); err == nil {
+		t.Error("Expected error for unknown collection")
+	}
+}
+
+func TestContextGlobPatterns(t *testing.T) {
+	m := newTestMMQ(t)
+
+	m.AddContext("/", "Global")
+	m.AddContext("mmq://docs", "Docs collection")
+	m.AddContext("mmq://docs/**/api/*.md", "API page")
+	m.AddContext("mmq://docs/v2", "Version 2")
+	m.AddContext("mmq://*/README.md", "Readme")
+
+	if err := m.AddContext("mmq://docs/[unclosed", "bad"); err == nil {
+		t.Error("Expected invalid glob pattern to be rejected")
+	}
+
+	paths := func(ctxs []ContextEntry) []string {
+		var out []string
+		for _, c := range ctxs {
+			out = append(out, c.Path)
+		}
+		return out
+	}
+
+	// 最具体的在前：glob（docs/**/api/*.md 得 5 分）> docs/v2 > docs > 全局
+	ctxs, err := m.GetDocumentContexts("docs", "v2/api/users.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mmq://docs/**/api/*.md", "mmq://docs/v2", "mmq://docs", "/"}
+	if got := paths(ctxs); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Unexpected priority order: %v, want %v", got, want)
+	}
+
+	// 不匹配的文档不受 glob 影响
+	ctxs, _ = m.GetDocumentContexts("docs", "guide/intro.md")
+	if got := paths(ctxs); strings.Join(got, ",") != "mmq://docs,/" {
+		t.Errorf("Unexpected contexts for guide: %v", got)
+	}
+
+	// 集合名通配
+	ctxs, _ = m.GetContextsForPath("mmq://code/README.md")
+	if got := paths(ctxs); strings.Join(got, ",") != "/,mmq://*/README.md" {
+		t.Errorf("Unexpected contexts for code readme: %v", got)
+	}
+}
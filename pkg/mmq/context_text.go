@@ -0,0 +1,62 @@
+package mmq
+
+import "unicode/utf8"
+
+// DefaultMaxContextChars 每条上下文文本的默认最大字符数
+const DefaultMaxContextChars = 4000
+
+// trimContexts 把超过 MaxContextChars 的上下文文本截取为以最匹配分块为中心的片段
+// 截断的上下文 Metadata 中 truncated 为 true，pos 为片段在原文中的位置（字节）
+func (m *MMQ) trimContexts(query string, contexts []Context) []Context {
+	maxChars := m.cfg.MaxContextChars
+	if maxChars <= 0 {
+		return contexts
+	}
+	terms := queryTerms(query)
+	for i, c := range contexts {
+		if utf8.RuneCountInString(c.Text) <= maxChars {
+			continue
+		}
+		path, _ := c.Metadata["path"].(string)
+		text, pos := m.centeredExcerpt(terms, path, c.Text, maxChars)
+
+		metadata := make(map[string]interface{}, len(c.Metadata)+2)
+		for k, v := range c.Metadata {
+			metadata[k] = v
+		}
+		metadata["truncated"] = true
+		metadata["pos"] = pos
+		contexts[i].Text = text
+		contexts[i].Metadata = metadata
+	}
+	return contexts
+}
+
+// centeredExcerpt 截取 maxChars 个字符，使与查询最匹配的分块位于中间；
+// 截去开头或结尾时加省略号（不计入 maxChars）。返回片段及其在原文中的字节位置
+func (m *MMQ) centeredExcerpt(terms []string, path, text string, maxChars int) (string, int) {
+	runes := []rune(text)
+	center := 0
+	if chunks := m.chunkDocument(path, text); len(chunks) > 0 {
+		c := chunks[bestChunk(terms, chunks)]
+		center = utf8.RuneCountInString(text[:c.Pos]) + utf8.RuneCountInString(c.Text)/2
+	}
+
+	start := center - maxChars/2
+	if start > len(runes)-maxChars {
+		start = len(runes) - maxChars
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxChars
+
+	excerpt := string(runes[start:end])
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "…"
+	}
+	return excerpt, len(string(runes[:start]))
+}
@@ -0,0 +1,56 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// CorpusVersion 返回语料版本，每次文档新增、修改、删除或集合重命名后递增
+// 多步任务开始时记下版本，之后的检索传入 SearchOptions.CorpusVersion，中途的索引更新不会改变证据集
+func (m *MMQ) CorpusVersion() (int64, error) {
+	return m.store.CorpusVersion()
+}
+
+// resolveCorpusVersion 未固定时返回当前版本，固定的版本不能超过当前版本
+func (m *MMQ) resolveCorpusVersion(pinned int64) (int64, error) {
+	if pinned < 0 {
+		return 0, fmt.Errorf("corpus version must not be negative")
+	}
+	current, err := m.store.CorpusVersion()
+	if err != nil {
+		return 0, err
+	}
+	if pinned == 0 {
+		return current, nil
+	}
+	if pinned > current {
+		return 0, fmt.Errorf("corpus version %d does not exist yet (current is %d)", pinned, current)
+	}
+	return pinned, nil
+}
+
+// pinCorpusVersion 在 filter 之前丢弃 version 之后变更过的文档
+// 已删除的文档无法恢复，修改过的文档不再返回（而不是返回旧内容）
+func (m *MMQ) pinCorpusVersion(version int64, filter func(rag.Context) bool) (func(rag.Context) bool, error) {
+	changes, err := m.store.CorpusChangesSince(version)
+	if err != nil {
+		return nil, err
+	}
+	if changes.Empty() {
+		return filter, nil
+	}
+	return func(c rag.Context) bool {
+		if changes.Changed(getMetadataString(c.Metadata, "collection"), getMetadataString(c.Metadata, "path")) {
+			return false
+		}
+		return filter == nil || filter(c)
+	}, nil
+}
+
+// stampCorpusVersion 记录结果检索时的语料版本
+func stampCorpusVersion(results []SearchResult, version int64) {
+	for i := range results {
+		results[i].CorpusVersion = version
+	}
+}
@@ -0,0 +1,155 @@
+package mmq
+
+import "fmt"
+
+// 建议动作
+const (
+	DBActionVacuum   = "vacuum"   // 回收空闲页
+	DBActionPurge    = "purge"    // 删除非活跃文档残留的向量和全文索引
+	DBActionCleanup  = "cleanup"  // 删除停用文档、孤儿内容和 LLM 缓存并 VACUUM
+	DBActionCache    = "cache"    // 缓存移出主库或限制条目
+	DBActionCompress = "compress" // 合并全文索引段
+	DBActionWAL      = "wal"      // WAL 无法检查点
+)
+
+// 建议阈值
+const (
+	dbFreelistMinRatio = 0.10     // 空闲页超过 10%
+	dbFreelistMinBytes = 4 << 20  // 且超过 4MB 时建议 VACUUM
+	dbWALWarnBytes     = 64 << 20 // WAL 超过 64MB
+	dbCacheShare       = 0.25     // 缓存超过数据的 25%
+	dbFTSContentRatio  = 2.5      // 全文索引（含正文副本）超过内容的 2.5 倍
+)
+
+// DBStats 数据库空间统计及整理建议
+type DBStats struct {
+	Path          string         `json:"path"`
+	PageSize      int64          `json:"page_size"`
+	PageCount     int64          `json:"page_count"`
+	FreelistCount int64          `json:"freelist_count"`
+	FreeBytes     int64          `json:"free_bytes"`    // 空闲页占用
+	Fragmentation float64        `json:"fragmentation"` // 空闲页占总页数的比例
+	FileSize      int64          `json:"file_size"`
+	WALSize       int64          `json:"wal_size"`
+	Tables        []DBTableStats `json:"tables"`
+
+	InactiveDocuments int `json:"inactive_documents"`
+	OrphanedContent   int `json:"orphaned_content"`
+	StaleVectors      int `json:"stale_vectors"`
+	StaleFTSRows      int `json:"stale_fts_rows"`
+	CacheEntries      int `json:"cache_entries"`
+
+	Recommendations []DBRecommendation `json:"recommendations"`
+}
+
+// DBTableStats 一类数据（documents、content、fts、vectors、memories、cache、other）的行数和估算大小
+type DBTableStats struct {
+	Name   string   `json:"name"`
+	Tables []string `json:"tables"`
+	Rows   int64    `json:"rows"`
+	Bytes  int64    `json:"bytes"` // 列数据长度之和，不含页内开销和索引
+}
+
+// DBRecommendation 整理建议
+type DBRecommendation struct {
+	Action  string `json:"action"`
+	Reason  string `json:"reason"`
+	Command string `json:"command,omitempty"`
+}
+
+// DBStats 返回页数、空闲页、WAL 大小、各类数据占用和可回收的数据，并给出 purge/vacuum/压缩等建议
+// 需要扫描全部表，大库上耗时较长
+func (m *MMQ) DBStats() (*DBStats, error) {
+	st, err := m.store.DBStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect database stats: %w", err)
+	}
+
+	stats := &DBStats{
+		Path:              m.store.DBPath(),
+		PageSize:          st.PageSize,
+		PageCount:         st.PageCount,
+		FreelistCount:     st.FreelistCount,
+		FreeBytes:         st.FreelistCount * st.PageSize,
+		FileSize:          st.FileSize,
+		WALSize:           st.WALSize,
+		InactiveDocuments: st.InactiveDocuments,
+		OrphanedContent:   st.OrphanedContent,
+		StaleVectors:      st.StaleVectors,
+		StaleFTSRows:      st.StaleFTSRows,
+		CacheEntries:      st.CacheEntries,
+	}
+	if st.PageCount > 0 {
+		stats.Fragmentation = float64(st.FreelistCount) / float64(st.PageCount)
+	}
+	for _, t := range st.Tables {
+		stats.Tables = append(stats.Tables, DBTableStats(t))
+	}
+	stats.Recommendations = dbRecommendations(stats)
+	return stats, nil
+}
+
+// tableBytes 某类数据的估算大小
+func (s *DBStats) tableBytes(name string) int64 {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t.Bytes
+		}
+	}
+	return 0
+}
+
+// dbRecommendations 按统计结果给出整理建议，先清理再回收空间
+func dbRecommendations(s *DBStats) []DBRecommendation {
+	recs := []DBRecommendation{}
+
+	if s.StaleVectors > 0 || s.StaleFTSRows > 0 {
+		recs = append(recs, DBRecommendation{
+			Action:  DBActionPurge,
+			Reason:  fmt.Sprintf("%d vectors and %d full-text rows belong to removed documents and slow down search", s.StaleVectors, s.StaleFTSRows),
+			Command: "mmq purge",
+		})
+	}
+	if s.InactiveDocuments > 0 || s.OrphanedContent > 0 {
+		recs = append(recs, DBRecommendation{
+			Action:  DBActionCleanup,
+			Reason:  fmt.Sprintf("%d inactive documents and %d unreferenced contents can be deleted (also clears the LLM cache)", s.InactiveDocuments, s.OrphanedContent),
+			Command: "mmq cleanup",
+		})
+	}
+
+	var total int64
+	for _, t := range s.Tables {
+		total += t.Bytes
+	}
+	if cache := s.tableBytes("cache"); total > 0 && float64(cache) > float64(total)*dbCacheShare {
+		recs = append(recs, DBRecommendation{
+			Action: DBActionCache,
+			Reason: fmt.Sprintf("the LLM cache holds %s (%d entries), %.0f%% of the data; set cache.backend to file or limit cache.max_entries in the config", FormatSize(cache), s.CacheEntries, float64(cache)*100/float64(total)),
+		})
+	}
+
+	if content, fts := s.tableBytes("content"), s.tableBytes("fts"); content > 0 && float64(fts) > float64(content)*dbFTSContentRatio {
+		recs = append(recs, DBRecommendation{
+			Action:  DBActionCompress,
+			Reason:  fmt.Sprintf("the full-text index (%s) is much larger than the content it indexes (%s); rebuilding merges its segments", FormatSize(fts), FormatSize(content)),
+			Command: "mmq reindex --all",
+		})
+	}
+
+	if s.Fragmentation >= dbFreelistMinRatio && s.FreeBytes >= dbFreelistMinBytes {
+		recs = append(recs, DBRecommendation{
+			Action:  DBActionVacuum,
+			Reason:  fmt.Sprintf("%s (%.0f%%) of the file is free pages", FormatSize(s.FreeBytes), s.Fragmentation*100),
+			Command: "mmq cleanup",
+		})
+	}
+
+	if s.WALSize >= dbWALWarnBytes {
+		recs = append(recs, DBRecommendation{
+			Action: DBActionWAL,
+			Reason: fmt.Sprintf("the write-ahead log is %s; a long-running reader (serve, watch, chat) may be blocking checkpoints, restart it", FormatSize(s.WALSize)),
+		})
+	}
+	return recs
+}
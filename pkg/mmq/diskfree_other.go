@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package mmq
+
+// diskFree 当前平台不支持查询可用空间，返回 -1（未知）
+func diskFree(path string) int64 {
+	return -1
+}
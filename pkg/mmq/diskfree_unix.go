@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package mmq
+
+import "syscall"
+
+// diskFree 返回路径所在文件系统的可用字节数，-1 表示未知
+func diskFree(path string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return -1
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
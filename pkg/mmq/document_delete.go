@@ -0,0 +1,55 @@
+package mmq
+
+import (
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// DeleteFilter 批量删除文档的条件，至少指定 Collection、Path、After 或 Before 之一
+type DeleteFilter struct {
+	Collection string
+	Path       string    // 集合内路径的 glob，如 old/**；不含通配符时匹配该路径及其下的文档
+	After      time.Time // 文档日期在此之后（含当天）；没有文档日期时用修改时间
+	Before     time.Time // 文档日期在此之前（不含当天）
+	DryRun     bool      // 只返回将被删除的文档
+}
+
+// DeletedDocument 批量删除命中的文档
+type DeletedDocument struct {
+	DocID      string    `json:"docid"`
+	Collection string    `json:"collection"`
+	Path       string    `json:"path"`
+	Title      string    `json:"title"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// DeleteResult 批量删除结果
+type DeleteResult struct {
+	Documents []DeletedDocument `json:"documents"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+}
+
+// DeleteDocumentsWhere 在一个事务中删除（停用）满足条件的全部文档，DryRun 时只预览
+func (m *MMQ) DeleteDocumentsWhere(filter DeleteFilter) (*DeleteResult, error) {
+	docs, err := m.store.DeleteDocumentsWhere(store.DeleteFilter{
+		Collection: filter.Collection,
+		Path:       filter.Path,
+		Dates:      store.DateRange{After: filter.After, Before: filter.Before},
+	}, filter.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeleteResult{Documents: make([]DeletedDocument, len(docs)), DryRun: filter.DryRun}
+	for i, d := range docs {
+		result.Documents[i] = DeletedDocument{
+			DocID:      shortDocID(d.Hash),
+			Collection: d.Collection,
+			Path:       d.Path,
+			Title:      d.Title,
+			ModifiedAt: d.ModifiedAt,
+		}
+	}
+	return result, nil
+}
@@ -1,10 +1,13 @@
 package mmq
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/store"
 )
 
 func TestListDocuments(t *testing.T) {
@@ -505,3 +508,500 @@ func TestDocumentQueryIntegration(t *testing.T) {
 
 	t.Log("\n=== All tests completed ===")
 }
+
+func TestLookupDocumentCatalog(t *testing.T) {
+	m := newTestMMQ(t)
+
+	for _, doc := range []Document{
+		{Collection: "docs", Path: "readme.md", Title: "README", Content: "readme content"},
+		{Collection: "docs", Path: "api/Endpoints.md", Title: "API", Content: "api content"},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := m.ListDocuments("docs", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "api/Endpoints.md" {
+		t.Fatalf("Expected 2 sorted documents, got %+v", entries)
+	}
+
+	byID, err := m.LookupDocument(entries[1].DocID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Path != "readme.md" || byID.Title != "README" {
+		t.Errorf("Unexpected docid lookup result: %+v", byID)
+	}
+	byPath, err := m.LookupDocument("mmq://docs/api/Endpoints.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byPath.Title != "API" {
+		t.Errorf("Unexpected path lookup result: %+v", byPath)
+	}
+	if _, err := m.LookupDocument("docs/missing.md"); err == nil {
+		t.Error("Expected error for unknown path")
+	}
+
+	// 前缀匹配与 SQL LIKE 一致（不区分大小写）
+	if sub, err := m.ListDocuments("docs", "API/"); err != nil || len(sub) != 1 {
+		t.Errorf("Expected case-insensitive prefix match, got %+v, %v", sub, err)
+	}
+
+	// 写入后目录失效
+	if err := m.IndexDocument(Document{Collection: "docs", Path: "readme.md", Title: "Read Me", Content: "new readme"}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := m.LookupDocument("docs/readme.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "Read Me" || updated.DocID == byID.DocID {
+		t.Errorf("Expected catalog to reflect re-indexed document, got %+v", updated)
+	}
+
+	if err := m.RenameCollection("docs", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.LookupDocument("notes/readme.md"); err != nil {
+		t.Errorf("Expected renamed collection in catalog: %v", err)
+	}
+	if _, err := m.LookupDocument("docs/readme.md"); err == nil {
+		t.Error("Expected old collection path to be gone after rename")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	m := newTestMMQ(t)
+
+	for _, doc := range []Document{
+		{Collection: "docs", Path: "api/endpoints.md", Title: "REST Endpoints", Content: "api endpoints"},
+		{Collection: "docs", Path: "guide.md", Title: "Getting Started", Content: "start here"},
+		{Collection: "notes", Path: "rest-notes.md", Title: "Notes", Content: "resting notes"},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Search("restful design", SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Search("guide", SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	suggestions, err := m.Suggest("REST", SuggestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range suggestions {
+		got = append(got, string(s.Kind)+":"+s.Text)
+	}
+	want := []string{
+		"query:restful design",
+		"document:docs/api/endpoints.md", // 标题前缀
+		"document:notes/rest-notes.md",   // 文件名前缀
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Suggest(REST) = %v, want %v", got, want)
+	}
+
+	collections, err := m.Suggest("no", SuggestOptions{Kinds: []SuggestionKind{SuggestCollection}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || collections[0].Text != "notes" {
+		t.Errorf("Expected collection suggestion notes, got %+v", collections)
+	}
+
+	// 单词前缀匹配标题
+	started, err := m.Suggest("start", SuggestOptions{Kinds: []SuggestionKind{SuggestDocument}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 1 || started[0].Title != "Getting Started" || started[0].DocID == "" {
+		t.Errorf("Expected word-prefix title match, got %+v", started)
+	}
+
+	recent, err := m.Suggest("", SuggestOptions{Limit: 1, Kinds: []SuggestionKind{SuggestQuery}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].Text != "guide" {
+		t.Errorf("Expected most recent query first, got %+v", recent)
+	}
+}
+
+func TestSampleDocuments(t *testing.T) {
+	m := newTestMMQ(t)
+
+	var docs []Document
+	for i := 0; i < 8; i++ {
+		docs = append(docs, Document{Collection: "docs", Path: fmt.Sprintf("big/%d.md", i), Title: "Big", Content: fmt.Sprintf("big doc %d", i)})
+	}
+	docs = append(docs,
+		Document{Collection: "docs", Path: "small/a.md", Title: "Small", Content: "small doc"},
+		Document{Collection: "docs", Path: "root.md", Title: "Root", Content: "root doc"},
+		Document{Collection: "other", Path: "x.md", Title: "Other", Content: "other doc"},
+	)
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sample, err := m.SampleDocuments("docs", 5, SampleOptions{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample) != 5 {
+		t.Fatalf("Expected 5 documents, got %d", len(sample))
+	}
+	seen := make(map[string]bool)
+	for _, d := range sample {
+		if d.Collection != "docs" || d.Content == "" {
+			t.Errorf("Unexpected sampled document: %+v", d)
+		}
+		if seen[d.Path] {
+			t.Errorf("Duplicate sample %s", d.Path)
+		}
+		seen[d.Path] = true
+	}
+
+	again, err := m.SampleDocuments("docs", 5, SampleOptions{Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range sample {
+		if again[i].Path != sample[i].Path {
+			t.Fatal("Expected the same seed to reproduce the sample")
+		}
+	}
+
+	// 分层抽样覆盖每个前缀：big/、small/ 和根目录
+	stratified, err := m.SampleDocuments("docs", 3, SampleOptions{Stratify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixes := make(map[string]bool)
+	for _, d := range stratified {
+		prefixes[pathPrefix(d.Path, 1)] = true
+	}
+	if len(prefixes) != 3 {
+		t.Errorf("Expected one document per prefix, got %+v", stratified)
+	}
+
+	all, err := m.SampleDocuments("", 100, SampleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(docs) {
+		t.Errorf("Expected sample capped at corpus size %d, got %d", len(docs), len(all))
+	}
+}
+
+func TestDocumentTextStats(t *testing.T) {
+	m := newTestMMQ(t)
+
+	long := strings.Repeat("lorem ipsum dolor sit amet ", 200) // 1000 词
+	for _, doc := range []Document{
+		{Collection: "docs", Path: "long.md", Title: "Long", Content: long},
+		{Collection: "docs", Path: "short.md", Title: "Short", Content: "Hello, world! 42"},
+		{Collection: "docs", Path: "zh.md", Title: "中文", Content: "检索增强生成 RAG"},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := m.ListDocuments("docs", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := make(map[string]DocumentListEntry)
+	for _, e := range entries {
+		stats[e.Path] = e
+	}
+	if e := stats["short.md"]; e.WordCount != 3 || e.CharCount != 16 || e.ReadingSeconds != 1 {
+		t.Errorf("Unexpected stats for short.md: %+v", e)
+	}
+	if e := stats["zh.md"]; e.WordCount != 7 || e.CharCount != 10 {
+		t.Errorf("Expected CJK characters counted as words, got %+v", e)
+	}
+	if e := stats["long.md"]; e.WordCount != 1000 || e.ReadingSeconds < 240 || e.ReadingSeconds > 280 {
+		t.Errorf("Unexpected stats for long.md: %+v", e)
+	}
+
+	doc, err := m.GetDocumentByPath("docs/long.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.WordCount != 1000 || doc.CharCount != len(long) {
+		t.Errorf("Expected stats on document detail, got words=%d chars=%d", doc.WordCount, doc.CharCount)
+	}
+
+	SortDocuments(entries, SortByWords, true)
+	if entries[0].Path != "long.md" || entries[2].Path != "short.md" {
+		t.Errorf("Unexpected order by words desc: %s, %s, %s", entries[0].Path, entries[1].Path, entries[2].Path)
+	}
+	if _, err := ParseDocumentSortField("size"); err == nil {
+		t.Error("Expected unknown sort field to be rejected")
+	}
+
+	// 旧数据库缺少统计列时，打开时补算
+	dbPath := m.cfg.DBPath
+	m.Close()
+	st, err := store.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"word_count", "char_count", "reading_seconds"} {
+		if _, err := st.DB().Exec("ALTER TABLE content DROP COLUMN " + col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.Close()
+
+	st, err = store.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	migrated, err := st.LookupDocument("docs/short.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.WordCount != 3 || migrated.CharCount != 16 {
+		t.Errorf("Expected stats to be backfilled on migration, got %+v", migrated)
+	}
+}
+
+func TestDocumentDates(t *testing.T) {
+	cases := []struct{ path, content, want string }{
+		{"journal/2025-01-15.md", "", "2025-01-15"},
+		{"2024/03/05/standup.md", "", "2024-03-05"},
+		{"2024/q2.md", "", "2024-04-01"},
+		{"notes/2023-11.md", "", "2023-11-01"},
+		{"archive/2022/ideas.md", "", "2022-01-01"},
+		{"20240229.md", "", "2024-02-29"},
+		{"2023-02-30.md", "", ""},
+		{"readme.md", "", ""},
+		{"v12345/readme.md", "", ""},
+		{"2024/q1.md", "---\ntitle: Plan\ndate: \"2024-02-10T08:00:00Z\"\n---\nbody", "2024-02-10"},
+	}
+	for _, c := range cases {
+		if got := store.ExtractDocumentDate(c.path, c.content); got != c.want {
+			t.Errorf("ExtractDocumentDate(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+
+	m := newTestMMQ(t)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, doc := range []Document{
+		{Collection: "journal", Path: "2024/q1.md", Title: "Q1", Content: "quarterly review", ModifiedAt: old},
+		{Collection: "journal", Path: "2025-01-15.md", Title: "Jan", Content: "quarterly planning", ModifiedAt: old},
+		{Collection: "journal", Path: "misc.md", Title: "Misc", Content: "quarterly notes", ModifiedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := m.Search("quarterly", SearchOptions{After: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Before: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range results {
+		paths = append(paths, r.Path)
+	}
+	// misc.md 没有文档日期，使用修改时间（2024-06-01）
+	if len(results) != 2 || strings.Contains(strings.Join(paths, ","), "2025-01-15.md") {
+		t.Errorf("Expected 2024 documents only, got %v", paths)
+	}
+	for _, r := range results {
+		if r.Path == "2024/q1.md" && r.Date != "2024-01-01" {
+			t.Errorf("Expected document date on search result, got %q", r.Date)
+		}
+	}
+
+	doc, err := m.LookupDocument("journal/2025-01-15.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Date != "2025-01-15" {
+		t.Errorf("Expected date in catalog entry, got %q", doc.Date)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	m := newTestMMQ(t)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, doc := range []Document{
+		{Collection: "journals", Path: "2024-01-10.md", Title: "Kickoff", Content: "---\ntags: x\n---\n# Kickoff\n\nProject   kickoff\nmeeting.", ModifiedAt: old},
+		{Collection: "journals", Path: "2024-02-20.md", Title: "Launch", Content: "Launch day.", ModifiedAt: old},
+		{Collection: "journals", Path: "2023-12-31.md", Title: "Old", Content: "Last year.", ModifiedAt: old},
+		{Collection: "other", Path: "2024-01-15.md", Title: "Other", Content: "Elsewhere.", ModifiedAt: old},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, mem := range []Memory{
+		{Type: MemoryTypeEpisodic, Content: "Discussed launch plan", Timestamp: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{Type: MemoryTypeFact, Content: "Not an episode", Timestamp: time.Date(2024, 2, 2, 9, 0, 0, 0, time.UTC)},
+	} {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := m.Timeline(TimelineOptions{Collection: "journals", Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		if e.Kind == TimelineDocument {
+			got = append(got, e.Path)
+		} else {
+			got = append(got, string(e.MemoryType))
+		}
+	}
+	if want := "2024-01-10.md,episodic,2024-02-20.md"; strings.Join(got, ",") != want {
+		t.Fatalf("Expected timeline %s, got %v", want, got)
+	}
+	if entries[0].Snippet != "Project kickoff meeting." {
+		t.Errorf("Expected snippet without frontmatter and heading, got %q", entries[0].Snippet)
+	}
+	if entries[1].Snippet != "Discussed launch plan" {
+		t.Errorf("Expected memory content as snippet, got %q", entries[1].Snippet)
+	}
+
+	entries, err = m.Timeline(TimelineOptions{Collection: "journals", NoMemories: true, Until: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != "2024-01-10.md" {
+		t.Errorf("Expected most recent document before February, got %+v", entries)
+	}
+}
+
+func TestWindowsStylePaths(t *testing.T) {
+	m := newTestMMQ(t)
+	dir := t.TempDir()
+	if err := m.CreateCollection("notes", dir, CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IndexDocument(Document{Collection: "notes", Path: `2025\Daily.md`, Title: "Daily", Content: "windows path"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 存储时统一为 '/'
+	docs, err := m.ListDocuments("notes", "")
+	if err != nil || len(docs) != 1 || docs[0].Path != "2025/Daily.md" {
+		t.Fatalf("Expected the path to be stored as 2025/Daily.md, got %+v (%v)", docs, err)
+	}
+
+	for _, ref := range []string{`notes\2025\Daily.md`, "mmq://notes/2025/./Daily.md", filepath.Join(dir, "2025", "Daily.md")} {
+		if doc, err := m.GetDocumentByPath(ref); err != nil || doc.Path != "2025/Daily.md" {
+			t.Errorf("GetDocumentByPath(%q) = %+v, %v", ref, doc, err)
+		}
+		if _, err := m.LookupDocument(ref); err != nil {
+			t.Errorf("LookupDocument(%q) failed: %v", ref, err)
+		}
+	}
+
+	// 大小写默认区分，开启后忽略
+	if _, err := m.GetDocumentByPath("notes/2025/daily.md"); err == nil {
+		t.Error("Expected a case-sensitive lookup to miss")
+	}
+	m.GetStore().SetCaseInsensitivePaths(true)
+	if _, err := m.GetDocumentByPath("notes/2025/daily.md"); err != nil {
+		t.Errorf("Expected a case-insensitive lookup to match: %v", err)
+	}
+	if _, err := m.LookupDocument("notes/2025/DAILY.md"); err != nil {
+		t.Errorf("Expected a case-insensitive catalog lookup to match: %v", err)
+	}
+	m.GetStore().SetCaseInsensitivePaths(false)
+
+	// 盘符路径按不区分大小写匹配集合目录
+	if _, err := m.GetStore().DB().Exec(`UPDATE collections SET path = 'C:\Users\me\notes' WHERE name = 'notes'`); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := m.GetDocumentByPath(`c:\users\me\notes\2025\Daily.md`); err != nil || doc.Collection != "notes" {
+		t.Errorf("Expected a drive-letter path to resolve to notes, got %+v (%v)", doc, err)
+	}
+}
+
+func TestRecentDocuments(t *testing.T) {
+	m := newTestMMQ(t)
+	for _, p := range []string{"a.md", "b.md", "c.md"} {
+		doc := Document{Collection: "notes", Path: p, Title: strings.ToUpper(p), Content: "shared keyword text " + p}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if docs, err := m.RecentDocuments("", 10); err != nil || len(docs) != 0 {
+		t.Fatalf("expected no recent documents before viewing, got %v (%v)", docs, err)
+	}
+	for _, p := range []string{"b.md", "a.md", "b.md"} {
+		if err := m.RecordDocumentAccess("notes", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 不存在的文档被忽略
+	if err := m.RecordDocumentAccess("notes", "missing.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := m.RecentDocuments("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs[0].Path != "b.md" || docs[0].AccessCount != 2 || docs[1].Path != "a.md" {
+		t.Fatalf("recent = %+v", docs)
+	}
+	if docs[0].DocID == "" || docs[0].LastAccessedAt.IsZero() {
+		t.Errorf("recent entry missing docid or time: %+v", docs[0])
+	}
+	if docs, _ := m.RecentDocuments("other", 10); len(docs) != 0 {
+		t.Errorf("collection filter returned %+v", docs)
+	}
+
+	// 重新索引保留查看记录
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "b.md", Title: "B", Content: "changed keyword text"}); err != nil {
+		t.Fatal(err)
+	}
+	if docs, _ := m.RecentDocuments("notes", 1); len(docs) != 1 || docs[0].Path != "b.md" || docs[0].AccessCount != 2 {
+		t.Errorf("after reindex recent = %+v", docs)
+	}
+
+	// 开启查看加成后，最近查看的文档排在前面
+	results := []SearchResult{
+		{Collection: "notes", Path: "c.md", Score: 0.5},
+		{Collection: "notes", Path: "a.md", Score: 0.45},
+	}
+	if got := m.boostAccessed(append([]SearchResult(nil), results...)); got[0].Path != "c.md" || got[1].Score != 0.45 {
+		t.Errorf("boost disabled by default, got %+v", got)
+	}
+	m.cfg.AccessBoost = 0.5
+	got := m.boostAccessed(append([]SearchResult(nil), results...))
+	if got[0].Path != "a.md" || got[0].Score <= 0.45 || got[1].Score != 0.5 {
+		t.Errorf("boosted results = %+v", got)
+	}
+
+	if n, err := m.ClearDocumentAccess(); err != nil || n != 2 {
+		t.Errorf("cleared %d (%v), want 2", n, err)
+	}
+	if docs, _ := m.RecentDocuments("", 10); len(docs) != 0 {
+		t.Errorf("recent after clear = %+v", docs)
+	}
+}
@@ -0,0 +1,60 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DocumentSortField 文档列表排序字段
+type DocumentSortField string
+
+const (
+	SortByPath     DocumentSortField = "path"
+	SortByTitle    DocumentSortField = "title"
+	SortByModified DocumentSortField = "modified"
+	SortByWords    DocumentSortField = "words"
+	SortByChars    DocumentSortField = "chars"
+	SortByReading  DocumentSortField = "reading" // 预计阅读时间
+)
+
+// ParseDocumentSortField 解析排序字段
+func ParseDocumentSortField(s string) (DocumentSortField, error) {
+	switch f := DocumentSortField(strings.ToLower(strings.TrimSpace(s))); f {
+	case SortByPath, SortByTitle, SortByModified, SortByWords, SortByChars, SortByReading:
+		return f, nil
+	case "":
+		return SortByPath, nil
+	default:
+		return "", fmt.Errorf("unknown sort field %q (want path, title, modified, words, chars or reading)", s)
+	}
+}
+
+// SortDocuments 按字段排序文档列表（稳定排序，desc 为降序）
+func SortDocuments(entries []DocumentListEntry, by DocumentSortField, desc bool) {
+	less := func(a, b DocumentListEntry) bool {
+		switch by {
+		case SortByTitle:
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		case SortByModified:
+			return a.ModifiedAt.Before(b.ModifiedAt)
+		case SortByWords:
+			return a.WordCount < b.WordCount
+		case SortByChars:
+			return a.CharCount < b.CharCount
+		case SortByReading:
+			return a.ReadingSeconds < b.ReadingSeconds
+		default:
+			if a.Collection != b.Collection {
+				return a.Collection < b.Collection
+			}
+			return a.Path < b.Path
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
@@ -0,0 +1,156 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// SetCollectionEmbedModel 设置集合的专用嵌入模型（如代码集合使用代码嵌入模型）
+// 空字符串或与默认模型相同时恢复默认；设置后需运行 embed 生成新模型的向量
+func (m *MMQ) SetCollectionEmbedModel(name, model string) error {
+	if model == m.cfg.EmbeddingModel {
+		model = ""
+	}
+	return m.store.SetCollectionEmbedModel(name, model)
+}
+
+// CollectionEmbedModel 返回集合生效的嵌入模型
+func (m *MMQ) CollectionEmbedModel(name string) (string, error) {
+	model, err := m.store.GetCollectionEmbedModel(name)
+	if err != nil {
+		return "", err
+	}
+	if model == "" {
+		model = m.cfg.EmbeddingModel
+	}
+	return model, nil
+}
+
+// embedderFor 返回专用嵌入模型的生成器，首次使用时加载模型
+func (m *MMQ) embedderFor(model string) (*llm.EmbeddingGenerator, error) {
+	m.embedMu.Lock()
+	defer m.embedMu.Unlock()
+
+	if gen, ok := m.embedders[model]; ok {
+		return gen, nil
+	}
+	if m.newModelLLM == nil {
+		return nil, fmt.Errorf("no embedder available for model %s", model)
+	}
+
+	impl, err := m.newModelLLM(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedding model %s: %w", model, err)
+	}
+
+	if m.embedders == nil {
+		m.embedders = make(map[string]*llm.EmbeddingGenerator)
+		m.modelLLMs = make(map[string]llm.LLM)
+	}
+	gen := newEmbeddingGenerator(impl, model)
+	m.modelLLMs[model] = impl
+	m.embedders[model] = gen
+	return gen, nil
+}
+
+// closeModelLLMs 关闭已加载的专用嵌入模型
+func (m *MMQ) closeModelLLMs() error {
+	m.embedMu.Lock()
+	defer m.embedMu.Unlock()
+
+	for model, impl := range m.modelLLMs {
+		if err := impl.Close(); err != nil {
+			return fmt.Errorf("failed to close embedding model %s: %w", model, err)
+		}
+	}
+	m.modelLLMs = nil
+	m.embedders = nil
+	return nil
+}
+
+// modelDocument 待用专用模型嵌入的文档
+type modelDocument struct {
+	doc   store.Document
+	model string
+}
+
+// documentsNeedingModelEmbedding 获取所有专用模型下需要嵌入的文档
+func (m *MMQ) documentsNeedingModelEmbedding() ([]modelDocument, error) {
+	models, err := m.store.ListEmbedModels()
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []modelDocument
+	for _, model := range models {
+		pending, err := m.store.GetDocumentsNeedingModelEmbedding(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get documents: %w", err)
+		}
+		for _, doc := range pending {
+			docs = append(docs, modelDocument{doc: doc, model: model})
+		}
+	}
+	return docs, nil
+}
+
+// embedModelDocument 用集合专用模型嵌入文档，失败记入报告
+// 不记录逐块进度：块倒序写入，seq 0 最后写入即表示完成，中断后整篇重新嵌入
+// 只有被取消时返回错误
+func (m *MMQ) embedModelDocument(doc store.Document, model string, report *EmbedReport, t *embedTracker) error {
+	size, overlap := m.cfg.ChunkSettings(doc.Path)
+	chunks := store.ChunkDocument(doc.Content, size, overlap)
+	path := doc.Collection + "/" + doc.Path
+	remaining := len(chunks)
+
+	fail := func(chunk int, err error) {
+		report.Failed = append(report.Failed, EmbedFailure{
+			Hash:  doc.Hash,
+			Path:  path,
+			Chunk: chunk,
+			Error: err.Error(),
+		})
+		t.failed(model, path, remaining)
+	}
+
+	gen, err := m.embedderFor(model)
+	if err != nil {
+		fail(0, err)
+		return nil
+	}
+
+	// 清除上次中断残留的块
+	if err := m.store.DeleteModelEmbeddings(model, doc.Hash); err != nil {
+		fail(0, err)
+		return nil
+	}
+
+	for j := len(chunks) - 1; j >= 0; j-- {
+		if t.cancelled() {
+			return errEmbedCancelled
+		}
+		embedding, err := t.generate(model, path, false, func() ([]float32, error) {
+			return gen.Generate(chunks[j].Text, false)
+		})
+		if err != nil {
+			fail(j, err)
+			return nil
+		}
+		if err := m.store.StoreModelEmbedding(model, doc.Hash, j, chunks[j].Pos, embedding); err != nil {
+			fail(j, err)
+			return nil
+		}
+		report.Chunks++
+		remaining--
+		t.chunkDone(model, path)
+	}
+
+	if err := m.store.RecordChunkSettings(model, doc.Hash, size, overlap); err != nil {
+		fail(0, err)
+		return nil
+	}
+	report.Embedded++
+	return nil
+}
@@ -0,0 +1,112 @@
+package mmq
+
+import (
+	"errors"
+	"time"
+)
+
+// errEmbedCancelled 文档嵌入到一半时被取消
+var errEmbedCancelled = errors.New("embedding cancelled")
+
+// embedTracker 统计 EmbedDocuments 的进度、速率和剩余时间
+type embedTracker struct {
+	opts   EmbedOptions
+	report *EmbedReport
+	start  time.Time
+
+	totalChunks int
+	chunks      int // 已处理块数（含跳过和失败）
+	documents   int // 已处理文档数
+
+	loaded   map[string]bool // 已加载（已生成过块）的模型
+	loadTime time.Duration   // 模型加载总耗时，不计入速率
+
+	logf func(format string, args ...interface{}) // 未设置 Progress 时的文档进度日志
+}
+
+func newEmbedTracker(m *MMQ, opts EmbedOptions, report *EmbedReport, totalChunks int) *embedTracker {
+	return &embedTracker{
+		logf:        m.logf,
+		opts:        opts,
+		report:      report,
+		start:       time.Now(),
+		totalChunks: totalChunks,
+		loaded:      make(map[string]bool),
+	}
+}
+
+// cancelled 是否已被取消（在块之间检查，保证已写入的块和进度一致）
+func (t *embedTracker) cancelled() bool {
+	return t.opts.Context != nil && t.opts.Context.Err() != nil
+}
+
+// generate 生成一个块的嵌入；模型的第一个块包含加载时间，单独报告
+func (t *embedTracker) generate(model, path string, alreadyLoaded bool, fn func() ([]float32, error)) ([]float32, error) {
+	if t.loaded[model] || alreadyLoaded {
+		t.loaded[model] = true
+		return fn()
+	}
+
+	t.emit(EmbedStageLoading, model, path, 0)
+	begin := time.Now()
+	embedding, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	t.loaded[model] = true
+	t.loadTime += time.Since(begin)
+	t.emit(EmbedStageLoaded, model, path, time.Since(begin))
+	return embedding, nil
+}
+
+// skip 续传时跳过的块
+func (t *embedTracker) skip(n int) {
+	t.chunks += n
+}
+
+// chunkDone 完成一个块
+func (t *embedTracker) chunkDone(model, path string) {
+	t.chunks++
+	t.emit(EmbedStageChunk, model, path, 0)
+}
+
+// failed 文档失败，剩余块计为已处理
+func (t *embedTracker) failed(model, path string, remaining int) {
+	t.chunks += remaining
+	t.report.FailedChunks += remaining
+	t.emit(EmbedStageFailed, model, path, 0)
+}
+
+// docDone 处理完一个文档（成功或失败），输出日志进度
+func (t *embedTracker) docDone() {
+	t.documents++
+	if t.opts.Progress == nil && (t.documents%10 == 0 || t.documents == t.report.Documents) {
+		t.logf("Embedded %d/%d documents", t.documents, t.report.Documents)
+	}
+}
+
+func (t *embedTracker) emit(stage, model, path string, loadTime time.Duration) {
+	if t.opts.Progress == nil {
+		return
+	}
+	elapsed := time.Since(t.start)
+	p := EmbedProgress{
+		Stage:       stage,
+		Model:       model,
+		Path:        path,
+		Documents:   t.documents,
+		TotalDocs:   t.report.Documents,
+		Chunks:      t.chunks,
+		TotalChunks: t.totalChunks,
+		Elapsed:     elapsed,
+		LoadTime:    loadTime,
+	}
+	// 速率只统计本次生成的块，不含模型加载时间
+	if busy := elapsed - t.loadTime; t.report.Chunks > 0 && busy > 0 {
+		p.Rate = float64(t.report.Chunks) / busy.Seconds()
+		if remaining := t.totalChunks - t.chunks; remaining > 0 {
+			p.ETA = time.Duration(float64(remaining) / p.Rate * float64(time.Second))
+		}
+	}
+	t.opts.Progress(p)
+}
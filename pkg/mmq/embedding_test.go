@@ -1,9 +1,17 @@
 package mmq
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/rag"
 )
 
 func TestEmbedText(t *testing.T) {
@@ -237,3 +245,1270 @@ func BenchmarkGenerateEmbeddings(b *testing.B) {
 		_ = m.GenerateEmbeddings()
 	}
 }
+
+// flakyLLM 对包含 failOn 的文本返回错误
+type flakyLLM struct {
+	*testLLM
+	failOn string
+}
+
+func (l *flakyLLM) Embed(text string, isQuery bool) ([]float32, error) {
+	if l.failOn != "" && strings.Contains(text, l.failOn) {
+		return nil, fmt.Errorf("simulated failure")
+	}
+	return l.testLLM.Embed(text, isQuery)
+}
+
+func TestEmbedDocumentsResume(t *testing.T) {
+	m := newTestMMQ(t)
+	flaky := &flakyLLM{testLLM: newTestLLM(300), failOn: "poison"}
+	m.embedding = llm.NewEmbeddingGenerator(flaky, "test-embed", 300)
+	m.cfg.ChunkSize = 100
+	m.cfg.ChunkOverlap = 10
+
+	var long strings.Builder
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&long, "Paragraph %d talks about distributed systems and consensus.\n\n", i)
+	}
+	long.WriteString("The final paragraph contains poison.")
+
+	for _, doc := range []Document{
+		{Collection: "test", Path: "good.md", Title: "Good", Content: "A short healthy document."},
+		{Collection: "test", Path: "long.md", Title: "Long", Content: long.String()},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := m.EmbedDocuments(EmbedOptions{})
+	if err != nil {
+		t.Fatalf("EmbedDocuments failed: %v", err)
+	}
+	if report.Embedded != 1 || len(report.Failed) != 1 {
+		t.Fatalf("Expected 1 embedded and 1 failed, got %+v", report)
+	}
+	failed := report.Failed[0]
+	if failed.Path != "test/long.md" || failed.Chunk == 0 {
+		t.Errorf("Unexpected failure: %+v", failed)
+	}
+
+	status, _ := m.Status()
+	if status.NeedsEmbedding != 1 {
+		t.Errorf("Expected the failed document to still need embedding, got %d", status.NeedsEmbedding)
+	}
+
+	// 修复后续传，跳过已嵌入的块
+	flaky.failOn = ""
+	report, err = m.EmbedDocuments(EmbedOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("EmbedDocuments resume failed: %v", err)
+	}
+	if report.Embedded != 1 || report.Resumed != 1 || report.SkippedChunks != failed.Chunk || len(report.Failed) != 0 {
+		t.Errorf("Unexpected resume report: %+v", report)
+	}
+
+	status, _ = m.Status()
+	if status.NeedsEmbedding != 0 {
+		t.Errorf("Expected all documents embedded, got %d remaining", status.NeedsEmbedding)
+	}
+}
+
+func TestEmbedDocumentsProgress(t *testing.T) {
+	m := newTestMMQ(t)
+	m.cfg.ChunkSize = 100
+	m.cfg.ChunkOverlap = 10
+
+	for i := 0; i < 3; i++ {
+		var content strings.Builder
+		for j := 0; j < 4; j++ {
+			fmt.Fprintf(&content, "Document %d paragraph %d covers vector search and ranking.\n\n", i, j)
+		}
+		doc := Document{Collection: "test", Path: fmt.Sprintf("doc%d.md", i), Title: "Doc", Content: content.String()}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 第一个文档嵌入到一半时取消：已生成的块保留，续传时跳过
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []EmbedProgress
+	report, err := m.EmbedDocuments(EmbedOptions{
+		Context: ctx,
+		Progress: func(p EmbedProgress) {
+			events = append(events, p)
+			if p.Stage == EmbedStageChunk && p.Path == "test/doc0.md" && p.Chunks > 1 {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("EmbedDocuments failed: %v", err)
+	}
+	if !report.Interrupted || report.Pending != 3 || report.Chunks != 2 {
+		t.Fatalf("Expected an interrupted run after 2 chunks, got %+v", report)
+	}
+	if len(events) < 3 || events[0].Stage != EmbedStageLoading || events[1].Stage != EmbedStageLoaded {
+		t.Fatalf("Expected model loading events first, got %+v", events)
+	}
+	last := events[len(events)-1]
+	if last.TotalChunks <= last.Chunks || last.TotalDocs != 3 || last.Rate <= 0 || last.ETA <= 0 {
+		t.Errorf("Expected chunk totals, rate and ETA, got %+v", last)
+	}
+
+	// 续传完成剩余文档，不重新生成已保存的块
+	report, err = m.EmbedDocuments(EmbedOptions{Resume: true})
+	if err != nil {
+		t.Fatalf("EmbedDocuments resume failed: %v", err)
+	}
+	if report.Interrupted || report.Embedded != 3 || report.Resumed != 1 || report.SkippedChunks != 2 || report.Chunks != last.TotalChunks-2 {
+		t.Errorf("Unexpected resume report: %+v", report)
+	}
+	status, _ := m.Status()
+	if status.NeedsEmbedding != 0 {
+		t.Errorf("Expected all documents embedded, got %d remaining", status.NeedsEmbedding)
+	}
+}
+
+func TestCollectionEmbedModel(t *testing.T) {
+	m := newTestMMQ(t)
+
+	// 专用模型维度与默认模型不同，验证向量分开存储
+	loads := 0
+	m.newModelLLM = func(model string) (llm.LLM, error) {
+		loads++
+		return newTestLLM(64), nil
+	}
+
+	docs := []Document{
+		{Collection: "notes", Path: "meeting.md", Title: "Meeting", Content: "weekly sync about the roadmap"},
+		{Collection: "code", Path: "main.go", Title: "main", Content: "func main() { fmt.Println(\"hello\") }"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SetCollectionEmbedModel("code", "code-embed"); err != nil {
+		t.Fatal(err)
+	}
+	if model, _ := m.CollectionEmbedModel("notes"); model != m.cfg.EmbeddingModel {
+		t.Errorf("Expected default model for notes, got %s", model)
+	}
+
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 1 {
+		t.Errorf("Expected code model to be loaded once, got %d", loads)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NeedsEmbedding != 0 {
+		t.Errorf("Expected nothing left to embed, got %d", status.NeedsEmbedding)
+	}
+
+	// 限定集合时使用该集合的模型
+	results, err := m.Search(docs[1].Content, SearchOptions{Limit: 5, Collection: "code", Strategy: StrategyVector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "main.go" {
+		t.Errorf("Expected code document from code model, got %+v", results)
+	}
+
+	// 不限定集合时每个模型一路，融合后两个集合都能命中
+	results, err = m.Search(docs[1].Content, SearchOptions{Limit: 5, Strategy: StrategyVector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || (results[0].Path != "main.go" && results[1].Path != "main.go") {
+		t.Errorf("Expected results fused from both models, got %+v", results)
+	}
+	if _, err := m.Search("roadmap", SearchOptions{Limit: 5, Strategy: StrategyHybrid}); err != nil {
+		t.Errorf("Hybrid search across models failed: %v", err)
+	}
+
+	// 恢复默认模型后需要用默认模型重新嵌入
+	if err := m.SetCollectionEmbedModel("code", m.cfg.EmbeddingModel); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := m.Status(); status.NeedsEmbedding != 1 {
+		t.Errorf("Expected code document to need default embedding, got %d", status.NeedsEmbedding)
+	}
+}
+
+func TestVectorStats(t *testing.T) {
+	m := newTestMMQ(t)
+	m.newModelLLM = func(model string) (llm.LLM, error) {
+		return newTestLLM(64), nil
+	}
+
+	docs := []Document{
+		{Collection: "notes", Path: "a.md", Title: "A", Content: "alpha notes"},
+		{Collection: "notes", Path: "b.md", Title: "B", Content: "beta notes"},
+		{Collection: "code", Path: "main.go", Title: "main", Content: "package main"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SetCollectionEmbedModel("code", "code-embed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.VectorStats()
+	if err != nil {
+		t.Fatalf("VectorStats failed: %v", err)
+	}
+	if stats.Vectors != 3 {
+		t.Errorf("Expected 3 vectors, got %d", stats.Vectors)
+	}
+	if len(stats.Dimensions) != 2 || stats.Dimensions[0] != 64 || stats.Dimensions[1] != 300 {
+		t.Errorf("Expected dimensions [64 300], got %v", stats.Dimensions)
+	}
+	if want := int64(2*300*4 + 64*4); stats.EstimatedRAM != want {
+		t.Errorf("Expected estimated RAM %d, got %d", want, stats.EstimatedRAM)
+	}
+	if stats.BytesOnDisk < stats.EstimatedRAM {
+		t.Errorf("Expected on-disk size to include vec0 chunks, got %d", stats.BytesOnDisk)
+	}
+	if stats.ANNRecommended {
+		t.Error("Expected ANN not to be recommended for a tiny index")
+	}
+
+	byName := make(map[string]CollectionVectorStats)
+	for _, c := range stats.Collections {
+		byName[c.Collection] = c
+	}
+	if c := byName["notes"]; c.Documents != 2 || c.Vectors != 2 || c.Model != m.cfg.EmbeddingModel {
+		t.Errorf("Unexpected notes stats: %+v", c)
+	}
+	if c := byName["code"]; c.Documents != 1 || c.Model != "code-embed" || c.Bytes != 64*4 {
+		t.Errorf("Unexpected code stats: %+v", c)
+	}
+}
+
+func TestDocumentChunks(t *testing.T) {
+	m := newTestMMQ(t)
+	m.cfg.ChunkSize = 100
+	m.cfg.ChunkOverlap = 20
+
+	var long strings.Builder
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&long, "Paragraph %d talks about distributed systems and consensus.\n\n", i)
+	}
+	if err := m.IndexDocument(Document{Collection: "test", Path: "long.md", Title: "Long", Content: long.String()}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.DocumentChunks("test/long.md")
+	if err != nil {
+		t.Fatalf("DocumentChunks failed: %v", err)
+	}
+	if len(report.Chunks) < 3 || report.Embedded != 0 {
+		t.Fatalf("Expected several unembedded chunks, got %d chunks, %d embedded", len(report.Chunks), report.Embedded)
+	}
+	if report.ChunkSize != 100 || report.ChunkOverlap != 20 || report.Length != long.Len() {
+		t.Errorf("Unexpected chunking parameters: %+v", report)
+	}
+	overlapped := false
+	for i, c := range report.Chunks {
+		if c.Seq != i || c.End-c.Pos != c.Size || long.String()[c.Pos:c.End] != c.Text {
+			t.Errorf("Chunk %d has inconsistent boundaries: %+v", i, c)
+		}
+		if c.Overlap > 0 {
+			overlapped = true
+		}
+	}
+	if !overlapped {
+		t.Error("Expected overlapping chunks")
+	}
+
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	report, err = m.DocumentChunks(report.DocID)
+	if err != nil {
+		t.Fatalf("DocumentChunks by docid failed: %v", err)
+	}
+	if report.Embedded != len(report.Chunks) || report.Stale != 0 {
+		t.Errorf("Expected every chunk embedded, got %d/%d (stale %d)", report.Embedded, len(report.Chunks), report.Stale)
+	}
+	if v := report.Chunks[0].Vectors; len(v) != 1 || v[0].Dimensions != 300 || v[0].EmbeddedAt.IsZero() {
+		t.Errorf("Unexpected chunk vectors: %+v", v)
+	}
+
+	// 分块参数改变后旧向量不再对应
+	m.cfg.ChunkSize = 200
+	report, err = m.DocumentChunks("test/long.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stale == 0 {
+		t.Error("Expected vectors from the old chunking to be reported as stale")
+	}
+
+	if _, err := m.DocumentChunks("test/missing.md"); err == nil {
+		t.Error("Expected error for missing document")
+	}
+}
+
+func TestEmbeddingMap(t *testing.T) {
+	m := newTestMMQ(t)
+
+	docs := []Document{
+		{Collection: "notes", Path: "a/one.md", Title: "One", Content: "alpha notes about gardens"},
+		{Collection: "notes", Path: "b/two.md", Title: "Two", Content: "beta notes about oceans"},
+		{Collection: "code", Path: "main.go", Title: "main", Content: "package main"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	embMap, err := m.EmbeddingMap(EmbeddingMapOptions{})
+	if err != nil {
+		t.Fatalf("EmbeddingMap failed: %v", err)
+	}
+	if len(embMap.Points) != 3 || embMap.Method != "pca" {
+		t.Fatalf("Expected 3 projected chunks, got %+v", embMap)
+	}
+	if sum := embMap.Explained[0] + embMap.Explained[1]; sum <= 0 || sum > 1.0001 {
+		t.Errorf("Expected explained variance in (0, 1], got %v", embMap.Explained)
+	}
+	for _, p := range embMap.Points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || p.Group != p.Collection || p.Text == "" {
+			t.Errorf("Unexpected point: %+v", p)
+		}
+	}
+
+	embMap, err = m.EmbeddingMap(EmbeddingMapOptions{Collection: "notes", ColorBy: ColorByDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embMap.Points) != 2 || embMap.Points[0].Group != "notes/a" || embMap.Points[1].Group != "notes/b" {
+		t.Errorf("Expected notes points grouped by directory, got %+v", embMap.Points)
+	}
+
+	if _, err := m.EmbeddingMap(EmbeddingMapOptions{ColorBy: "size"}); err == nil {
+		t.Error("Expected invalid color-by to be rejected")
+	}
+}
+
+func TestPCAAndOutliers(t *testing.T) {
+	// 点分布在一条直线上：第一主成分解释全部方差
+	var line [][]float64
+	for i := 0; i < 10; i++ {
+		line = append(line, []float64{float64(i), 2 * float64(i), 0})
+	}
+	coords, explained := pca2(line)
+	if explained[0] < 0.999 || explained[1] > 0.001 {
+		t.Errorf("Expected one dominant component, got %v", explained)
+	}
+	if d := math.Abs(coords[9][0]-coords[0][0]) - math.Sqrt(5)*9; math.Abs(d) > 1e-6 {
+		t.Errorf("Expected distances preserved along the line, got %v", coords)
+	}
+
+	var vectors [][]float64
+	var points []EmbeddingPoint
+	for i := 0; i < 9; i++ {
+		vectors = append(vectors, unitVector([]float32{1, float32(i) * 0.01, 0}))
+		points = append(points, EmbeddingPoint{Group: "g"})
+	}
+	vectors = append(vectors, unitVector([]float32{0, 0, 1}))
+	points = append(points, EmbeddingPoint{Group: "g"})
+	markOutliers(points, vectors)
+	for i, p := range points {
+		if p.Outlier != (i == 9) {
+			t.Errorf("Point %d outlier=%v", i, p.Outlier)
+		}
+	}
+}
+
+func TestClusterCollection(t *testing.T) {
+	m := newTestMMQ(t)
+
+	docs := []Document{
+		{Collection: "notes", Path: "garden/roses.md", Title: "Roses", Content: "garden roses pruning garden"},
+		{Collection: "notes", Path: "garden/tulips.md", Title: "Tulips", Content: "garden tulips bulbs garden"},
+		{Collection: "notes", Path: "sea/whales.md", Title: "Whales", Content: "ocean whales migration ocean"},
+		{Collection: "notes", Path: "sea/coral.md", Title: "Coral", Content: "ocean coral reefs ocean"},
+		{Collection: "code", Path: "main.go", Title: "main", Content: "package main garden"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ClusterCollection(ClusterOptions{}); err == nil {
+		t.Error("Expected clustering without a collection to fail")
+	}
+
+	calls := 0
+	clusters, err := m.ClusterCollection(ClusterOptions{
+		Collection: "notes",
+		K:          2,
+		Generator: func(prompt string) (string, error) {
+			calls++
+			return fmt.Sprintf("标签：\"Topic %d\"", calls), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ClusterCollection failed: %v", err)
+	}
+	if len(clusters) == 0 || len(clusters) > 2 || calls != len(clusters) {
+		t.Fatalf("Expected up to 2 labeled clusters, got %d (%d LLM calls)", len(clusters), calls)
+	}
+	total := 0
+	for i, c := range clusters {
+		if c.ID != i+1 || c.Size != len(c.Documents) || !strings.HasPrefix(c.Label, "Topic ") {
+			t.Errorf("Unexpected cluster: %+v", c)
+		}
+		total += c.Size
+	}
+	if total != 4 {
+		t.Errorf("Expected all 4 notes documents to be assigned, got %d", total)
+	}
+
+	listed, err := m.ListClusters("notes")
+	if err != nil || len(listed) != len(clusters) {
+		t.Fatalf("ListClusters = %v, %v", listed, err)
+	}
+	cluster, err := m.GetCluster("notes", 1)
+	if err != nil || len(cluster.Documents) != clusters[0].Size {
+		t.Fatalf("GetCluster = %+v, %v", cluster, err)
+	}
+	if _, err := m.GetCluster("notes", 99); err == nil {
+		t.Error("Expected unknown cluster to fail")
+	}
+
+	if err := m.SetClusterLabel("notes", 1, " Gardening "); err != nil {
+		t.Fatal(err)
+	}
+	if cluster, _ = m.GetCluster("notes", 1); cluster.Label != "Gardening" {
+		t.Errorf("Expected renamed label, got %q", cluster.Label)
+	}
+
+	// 按聚类过滤搜索结果：各聚类的结果合起来等于不过滤的结果
+	all, err := m.Search("garden", SearchOptions{Collection: "notes"})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Expected 2 garden results, got %d (%v)", len(all), err)
+	}
+	found := 0
+	for _, c := range clusters {
+		cluster, err := m.GetCluster("notes", c.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members := make(map[string]bool)
+		for _, d := range cluster.Documents {
+			members[d.Path] = true
+		}
+		results, err := m.Search("garden", SearchOptions{Collection: "notes", Cluster: c.ID})
+		if err != nil {
+			t.Fatalf("Search with cluster failed: %v", err)
+		}
+		for _, r := range results {
+			if !members[r.Path] || r.Metadata["cluster"] != c.ID {
+				t.Errorf("Result outside cluster %d: %s %v", c.ID, r.Path, r.Metadata)
+			}
+		}
+		found += len(results)
+	}
+	if found != len(all) {
+		t.Errorf("Expected cluster-filtered searches to cover %d results, got %d", len(all), found)
+	}
+	if _, err := m.Search("garden", SearchOptions{Cluster: 1}); err == nil {
+		t.Error("Expected cluster filter without collection to fail")
+	}
+
+	// 不调用 LLM 时使用关键词标签，重新聚类替换旧结果
+	clusters, err = m.ClusterCollection(ClusterOptions{Collection: "notes", K: 1, NoLLM: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].Size != 4 || clusters[0].Label == "" {
+		t.Errorf("Expected one keyword-labeled cluster, got %+v", clusters)
+	}
+	if listed, _ = m.ListClusters("notes"); len(listed) != 1 {
+		t.Errorf("Expected re-clustering to replace old clusters, got %d", len(listed))
+	}
+}
+
+func TestPurgeVectorsOfRemovedDocuments(t *testing.T) {
+	m := newTestMMQ(t)
+	st := m.GetStore()
+
+	for _, name := range []string{"notes", "code"} {
+		if err := m.CreateCollection(name, t.TempDir(), CollectionOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs := []Document{
+		{Collection: "notes", Path: "shared.md", Title: "Shared", Content: "content shared by two collections"},
+		{Collection: "notes", Path: "only.md", Title: "Only", Content: "content only in notes"},
+		{Collection: "code", Path: "copy.md", Title: "Copy", Content: "content shared by two collections"},
+		{Collection: "code", Path: "gone.md", Title: "Gone", Content: "content that gets deleted"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	countVectors := func(table string) int {
+		var n int
+		if err := st.DB().QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := countVectors("content_vectors"); n != 3 {
+		t.Fatalf("Expected 3 embedded contents, got %d", n)
+	}
+
+	// 删除文档时删除其向量
+	if err := m.DeleteDocument("gone.md"); err != nil {
+		t.Fatal(err)
+	}
+	if cv, vv := countVectors("content_vectors"), countVectors("vectors_vec"); cv != 2 || vv != 2 {
+		t.Errorf("Expected vectors of the deleted document to be removed, got %d/%d", cv, vv)
+	}
+
+	// 删除集合时保留仍被其他集合引用的内容的向量
+	if err := m.RemoveCollection("notes"); err != nil {
+		t.Fatal(err)
+	}
+	if cv, vv := countVectors("content_vectors"), countVectors("vectors_vec"); cv != 1 || vv != 1 {
+		t.Errorf("Expected only the shared content's vector to remain, got %d/%d", cv, vv)
+	}
+	results, err := m.Search("shared", SearchOptions{})
+	if err != nil || len(results) != 1 || results[0].Collection != "code" {
+		t.Errorf("Expected the shared document to stay searchable in code, got %+v (%v)", results, err)
+	}
+
+	// 修复旧版本遗留的数据：停用文档但不删除向量和全文索引
+	if _, err := st.DB().Exec("DROP TRIGGER documents_au"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.DB().Exec("UPDATE documents SET active = 0 WHERE collection = 'code'"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := st.PurgeInactive()
+	if err != nil {
+		t.Fatalf("PurgeInactive failed: %v", err)
+	}
+	if result.Vectors != 1 || result.FTSRows != 1 {
+		t.Errorf("Expected 1 vector and 1 full-text row purged, got %+v", result)
+	}
+	if cv, vv := countVectors("content_vectors"), countVectors("vectors_vec"); cv != 0 || vv != 0 {
+		t.Errorf("Expected no vectors after purge, got %d/%d", cv, vv)
+	}
+	if result, _ := st.PurgeInactive(); result.Vectors != 0 || result.FTSRows != 0 {
+		t.Errorf("Expected a second purge to find nothing, got %+v", result)
+	}
+}
+
+func TestHardRemoveCollection(t *testing.T) {
+	m := newTestMMQ(t)
+	st := m.GetStore()
+
+	for _, name := range []string{"notes", "notes2"} {
+		if err := m.CreateCollection(name, t.TempDir(), CollectionOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docs := []Document{
+		{Collection: "notes", Path: "shared.md", Title: "Shared", Content: "content shared by two collections"},
+		{Collection: "notes", Path: "only.md", Title: "Only", Content: "content only in notes"},
+		{Collection: "notes2", Path: "copy.md", Title: "Copy", Content: "content shared by two collections"},
+	}
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"mmq://notes", "mmq://notes/sub", "mmq://notes2"} {
+		if err := m.AddContext(path, "context of "+path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := m.HardRemoveCollection("notes")
+	if err != nil {
+		t.Fatalf("HardRemoveCollection failed: %v", err)
+	}
+	if result.Documents != 2 || result.Content != 1 || result.Vectors != 1 || result.Contexts != 2 {
+		t.Errorf("Unexpected remove result: %+v", result)
+	}
+	if result.FreedBytes <= 0 {
+		t.Errorf("Expected freed bytes to be reported, got %d", result.FreedBytes)
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := st.DB().QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count("SELECT COUNT(*) FROM documents WHERE collection = 'notes'"); n != 0 {
+		t.Errorf("Expected document rows to be deleted, got %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM content"); n != 1 {
+		t.Errorf("Expected only the shared content to remain, got %d", n)
+	}
+	if cv, vv := count("SELECT COUNT(*) FROM content_vectors"), count("SELECT COUNT(*) FROM vectors_vec"); cv != 1 || vv != 1 {
+		t.Errorf("Expected the shared content's vector to remain, got %d/%d", cv, vv)
+	}
+	contexts, err := m.ListContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 1 || contexts[0].Path != "mmq://notes2" {
+		t.Errorf("Expected only the notes2 context to remain, got %+v", contexts)
+	}
+	if _, err := m.HardRemoveCollection("notes"); err == nil {
+		t.Error("Expected an error removing a missing collection")
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	m := newTestMMQ(t)
+	for i := 0; i < 4; i++ {
+		var content strings.Builder
+		for j := 0; j < 4; j++ {
+			fmt.Fprintf(&content, "Document %d paragraph %d covers vector search and ranking.\n\n", i, j)
+		}
+		doc := Document{Collection: "test", Path: fmt.Sprintf("doc%d.md", i), Title: fmt.Sprintf("Topic %d", i), Content: content.String()}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缩小分块后重建，块数应增加
+	m.cfg.ChunkSize = 100
+	m.cfg.ChunkOverlap = 10
+	var stages []string
+	report, err := m.Rebuild(RebuildOptions{
+		Progress: func(p RebuildProgress) { stages = append(stages, p.Stage) },
+	})
+	if err != nil {
+		t.Fatalf("Rebuild failed: %v (%+v)", err, report)
+	}
+	if !report.Swapped || len(report.Problems) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	want := []string{RebuildStageSnapshot, RebuildStageFTS, RebuildStageEmbed, RebuildStageVerify, RebuildStageSwap}
+	if strings.Join(stages, ",") != strings.Join(want, ",") {
+		t.Errorf("stages = %v", stages)
+	}
+	if report.After.Documents != 4 || report.After.FTSRows != 4 || report.After.Chunks <= report.Before.Chunks {
+		t.Errorf("counts before %+v after %+v", report.Before, report.After)
+	}
+	if len(report.SpotChecks) != 4 {
+		t.Errorf("expected 4 spot checks, got %+v", report.SpotChecks)
+	}
+	if _, err := os.Stat(report.Backup); err != nil {
+		t.Errorf("backup missing: %v", err)
+	}
+	if _, err := os.Stat(m.cfg.DBPath + ".reindex"); !os.IsNotExist(err) {
+		t.Errorf("staging file left behind: %v", err)
+	}
+
+	// 替换后原实例仍可用
+	status, err := m.Status()
+	if err != nil || status.NeedsEmbedding != 0 || status.TotalDocuments != 4 {
+		t.Fatalf("status after rebuild = %+v (%v)", status, err)
+	}
+	results, err := m.Search("Topic 2", SearchOptions{Limit: 5, Strategy: StrategyFTS})
+	if err != nil || len(results) == 0 || results[0].Path != "doc2.md" {
+		t.Errorf("search after rebuild = %+v (%v)", results, err)
+	}
+
+	// 演练模式不替换，保留暂存库
+	report, err = m.Rebuild(RebuildOptions{DryRun: true, SkipEmbed: true, SpotChecks: -1})
+	if err != nil || report.Swapped || report.Staging == "" {
+		t.Fatalf("dry run = %+v (%v)", report, err)
+	}
+	if _, err := os.Stat(report.Staging); err != nil {
+		t.Errorf("dry run staging missing: %v", err)
+	}
+}
+
+func TestChunkRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.CacheDir = t.TempDir()
+	cfg.ChunkRules = map[string]ChunkRule{"GO": {Size: 400, Overlap: 50}, ".txt": {Size: 800}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if size, overlap := cfg.ChunkSettings("pkg/main.Go"); size != 400 || overlap != 50 {
+		t.Errorf(".go settings = %d/%d", size, overlap)
+	}
+	if size, overlap := cfg.ChunkSettings("notes.txt"); size != 800 || overlap != cfg.ChunkOverlap {
+		t.Errorf(".txt settings = %d/%d, want overlap to fall back to %d", size, overlap, cfg.ChunkOverlap)
+	}
+	if size, _ := cfg.ChunkSettings("README.md"); size != cfg.ChunkSize {
+		t.Errorf(".md should use the global chunk size, got %d", size)
+	}
+	cfg.ChunkRules = map[string]ChunkRule{".go": {Size: 100, Overlap: 100}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected overlap >= size to be rejected")
+	}
+
+	m := newTestMMQ(t)
+	m.cfg.ChunkRules = map[string]ChunkRule{".go": {Size: 100, Overlap: 10}}
+	var content strings.Builder
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&content, "func handler%d() { return serveRequest(%d) }\n\n", i, i)
+	}
+	for _, path := range []string{"main.go", "main.md"} {
+		if err := m.IndexDocument(Document{Collection: "test", Path: path, Content: content.String()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	goReport, err := m.DocumentChunks("test/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdReport, err := m.DocumentChunks("test/main.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goReport.ChunkSize != 100 || goReport.ChunkOverlap != 10 || len(goReport.Chunks) < 2 {
+		t.Errorf(".go chunking = %d/%d with %d chunks", goReport.ChunkSize, goReport.ChunkOverlap, len(goReport.Chunks))
+	}
+	if mdReport.ChunkSize != m.cfg.ChunkSize || len(mdReport.Chunks) != 1 {
+		t.Errorf(".md chunking = %d with %d chunks", mdReport.ChunkSize, len(mdReport.Chunks))
+	}
+
+	// 向量记录生成时的分块参数
+	for _, c := range goReport.Chunks {
+		if len(c.Vectors) != 1 || c.Vectors[0].ChunkSize != 100 || c.Vectors[0].ChunkOverlap != 10 {
+			t.Fatalf("chunk %d vectors = %+v", c.Seq, c.Vectors)
+		}
+	}
+}
+
+// recordingEmbedder 记录查询嵌入的输入文本
+type recordingEmbedder struct {
+	*testLLM
+	queries []string
+}
+
+func (r *recordingEmbedder) Embed(text string, isQuery bool) ([]float32, error) {
+	if isQuery {
+		r.queries = append(r.queries, text)
+	}
+	return r.testLLM.Embed(text, isQuery)
+}
+
+func TestQueryInstruction(t *testing.T) {
+	m := newTestMMQ(t)
+	rec := &recordingEmbedder{testLLM: newTestLLM(300)}
+	m.embedding = llm.NewEmbeddingGenerator(rec, "test-embed", 300)
+	m.retriever = rag.NewRetriever(m.store, m.llm, m.embedding)
+
+	doc := Document{Collection: "test", Path: "a.md", Title: "A", Content: "Vector search ranks documents by similarity."}
+	if err := m.IndexDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未指定时 test-embed 模型不加指令
+	if _, err := m.Search("vector search", SearchOptions{Strategy: StrategyVector, Limit: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.queries) != 1 || rec.queries[0] != "vector search" {
+		t.Fatalf("default query text = %q", rec.queries)
+	}
+
+	opts := RetrieveOptions{Strategy: StrategyHybrid, Limit: 5, Instruction: "Identify the topic of the text"}
+	if _, err := m.RetrieveContext("vector search", opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "Instruct: Identify the topic of the text\nQuery: vector search"
+	if got := rec.queries[len(rec.queries)-1]; got != want {
+		t.Errorf("instructed query text = %q, want %q", got, want)
+	}
+}
+
+func TestDBStats(t *testing.T) {
+	m := newTestMMQ(t)
+	st := m.GetStore()
+
+	for i := 0; i < 3; i++ {
+		doc := Document{Collection: "notes", Path: fmt.Sprintf("doc%d.md", i), Title: fmt.Sprintf("Doc %d", i), Content: strings.Repeat(fmt.Sprintf("paragraph %d about storage. ", i), 20)}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.DBStats()
+	if err != nil {
+		t.Fatalf("DBStats failed: %v", err)
+	}
+	if stats.PageCount == 0 || stats.PageSize == 0 || stats.FileSize == 0 {
+		t.Errorf("missing page stats: %+v", stats)
+	}
+	groups := make(map[string]DBTableStats)
+	for _, tbl := range stats.Tables {
+		groups[tbl.Name] = tbl
+	}
+	if groups["content"].Rows != 3 || groups["content"].Bytes == 0 || groups["vectors"].Bytes == 0 || groups["fts"].Bytes == 0 {
+		t.Errorf("unexpected table stats: %+v", stats.Tables)
+	}
+	if len(stats.Recommendations) != 0 {
+		t.Errorf("expected no recommendations for a clean database, got %+v", stats.Recommendations)
+	}
+
+	// 旧版本遗留的停用文档：建议 purge 和 cleanup
+	if _, err := st.DB().Exec("DROP TRIGGER documents_au"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.DB().Exec("UPDATE documents SET active = 0 WHERE path = 'doc0.md'"); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = m.DBStats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats.InactiveDocuments != 1 || stats.StaleVectors == 0 || stats.StaleFTSRows != 1 {
+		t.Errorf("unexpected reclaimable counts: %+v", stats)
+	}
+	var actions []string
+	for _, r := range stats.Recommendations {
+		actions = append(actions, r.Action)
+	}
+	if strings.Join(actions, ",") != DBActionPurge+","+DBActionCleanup {
+		t.Errorf("recommendations = %v", actions)
+	}
+
+	// 空闲页、缓存和 WAL 的建议
+	recs := dbRecommendations(&DBStats{
+		FreeBytes:     64 << 20,
+		Fragmentation: 0.3,
+		WALSize:       128 << 20,
+		Tables:        []DBTableStats{{Name: "content", Bytes: 10 << 20}, {Name: "cache", Bytes: 20 << 20}},
+	})
+	actions = actions[:0]
+	for _, r := range recs {
+		actions = append(actions, r.Action)
+	}
+	if strings.Join(actions, ",") != DBActionCache+","+DBActionVacuum+","+DBActionWAL {
+		t.Errorf("recommendations = %v", actions)
+	}
+}
+
+func TestAnswerCache(t *testing.T) {
+	m := newTestMMQ(t)
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := m.CorpusVersion()
+	if err != nil || v1 == 0 {
+		t.Fatalf("expected a corpus version after indexing, got %d (%v)", v1, err)
+	}
+
+	const question, scope = "what does alpha mean?", "chat|test|model"
+	if hit, err := m.LookupAnswer(question, scope); err != nil || hit != nil {
+		t.Fatalf("expected a miss on an empty cache, got %+v (%v)", hit, err)
+	}
+	if err := m.CacheAnswer(question, scope, "alpha is the first letter"); err != nil {
+		t.Fatal(err)
+	}
+
+	hit, err := m.LookupAnswer(question, scope)
+	if err != nil || hit == nil {
+		t.Fatalf("expected a cache hit, got %+v (%v)", hit, err)
+	}
+	if hit.Answer != "alpha is the first letter" || hit.Similarity < m.cfg.AnswerCacheThreshold || hit.CorpusVersion != v1 {
+		t.Errorf("unexpected hit: %+v", hit)
+	}
+	if hit, _ := m.LookupAnswer("something unrelated entirely", scope); hit != nil {
+		t.Errorf("expected a dissimilar question to miss, got %+v", hit)
+	}
+	if hit, _ := m.LookupAnswer(question, "chat|other|model"); hit != nil {
+		t.Errorf("expected another scope to miss, got %+v", hit)
+	}
+
+	// 语料变化后旧回答失效
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "b.md", Title: "B", Content: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	if v2, _ := m.CorpusVersion(); v2 <= v1 {
+		t.Fatalf("expected the corpus version to increase, got %d after %d", v2, v1)
+	}
+	if hit, _ := m.LookupAnswer(question, scope); hit != nil {
+		t.Errorf("expected a miss after the corpus changed, got %+v", hit)
+	}
+
+	// 写入新版本的回答时清掉旧版本条目；过期的回答不再命中
+	if err := m.CacheAnswer(question, scope, "alpha, revised"); err != nil {
+		t.Fatal(err)
+	}
+	var rows int
+	if err := m.store.DB().QueryRow("SELECT COUNT(*) FROM answer_cache").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("expected outdated answers to be dropped, got %d rows", rows)
+	}
+	m.cfg.AnswerCacheTTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if hit, _ := m.LookupAnswer(question, scope); hit != nil {
+		t.Errorf("expected an expired answer to miss, got %+v", hit)
+	}
+}
+
+func TestCorpusVersionPinning(t *testing.T) {
+	m := newTestMMQ(t)
+	for _, doc := range []Document{
+		{Collection: "notes", Path: "a.md", Title: "A", Content: "kestrel migration notes"},
+		{Collection: "notes", Path: "b.md", Title: "B", Content: "kestrel nesting habits"},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v1, err := m.CorpusVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := m.Search("kestrel", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].CorpusVersion != v1 {
+		t.Fatalf("expected 2 results at version %d, got %+v", v1, results)
+	}
+
+	// 任务进行中索引发生变化：修改 a，新增 c
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "kestrel migration notes, revised"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "c.md", Title: "C", Content: "kestrel diet"}); err != nil {
+		t.Fatal(err)
+	}
+	v2, _ := m.CorpusVersion()
+	if v2 <= v1 {
+		t.Fatalf("expected the corpus version to increase after indexing, got %d then %d", v1, v2)
+	}
+
+	pinned, err := m.Search("kestrel", SearchOptions{Limit: 10, CorpusVersion: v1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pinned) != 1 || pinned[0].Path != "b.md" || pinned[0].CorpusVersion != v1 {
+		t.Errorf("expected only the unchanged b.md at version %d, got %+v", v1, pinned)
+	}
+	current, err := m.Search("kestrel", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current) != 3 || current[0].CorpusVersion != v2 {
+		t.Errorf("expected 3 results at version %d, got %+v", v2, current)
+	}
+
+	if _, err := m.Search("kestrel", SearchOptions{CorpusVersion: v2 + 1}); err == nil {
+		t.Error("expected an error when pinning to a future corpus version")
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.CorpusVersion != v2 {
+		t.Errorf("expected status corpus version %d, got %d", v2, status.CorpusVersion)
+	}
+}
+
+func TestStatusChanges(t *testing.T) {
+	m := newTestMMQ(t)
+	start := time.Now().Add(-time.Second)
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := m.ChangesSinceVersion(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Documents.Added != 1 || cursor.Cursor == 0 {
+		t.Fatalf("expected 1 added document and a cursor, got %+v", cursor)
+	}
+
+	// 上次运行之后：新增、修改、删除文档并存储记忆
+	for _, doc := range []Document{
+		{Collection: "notes", Path: "a.md", Title: "A", Content: "alpha revised"},
+		{Collection: "notes", Path: "b.md", Title: "B", Content: "beta"},
+		{Collection: "logs", Path: "c.md", Title: "C", Content: "gamma"},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.DeleteDocument("c.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "User deploys on Fridays", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := m.ChangesSinceVersion(cursor.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Documents != (DocumentChanges{Added: 2, Updated: 1, Removed: 1}) {
+		t.Errorf("unexpected document changes: %+v", changes.Documents)
+	}
+	if len(changes.Collections) != 2 || changes.Collections[0].Name != "logs" ||
+		changes.Collections[1].DocumentChanges != (DocumentChanges{Added: 1, Updated: 1}) {
+		t.Errorf("unexpected per-collection changes: %+v", changes.Collections)
+	}
+	if changes.Memories.Stored != 1 {
+		t.Errorf("expected 1 stored memory, got %+v", changes.Memories)
+	}
+	if changes.Cursor <= cursor.Cursor || changes.Empty() {
+		t.Errorf("expected the cursor to advance past %d, got %+v", cursor.Cursor, changes)
+	}
+
+	again, err := m.ChangesSinceVersion(changes.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Documents.Total() != 0 || again.Memories != (MemoryChanges{}) {
+		t.Errorf("expected no events after the latest cursor, got %+v", again)
+	}
+	if _, err := m.ChangesSinceVersion(changes.Cursor + 1); err == nil {
+		t.Error("expected an error for a future version")
+	}
+
+	byTime, err := m.ChangesSince(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byTime.Documents.Added != 3 || byTime.Embeddings.Documents != 1 || byTime.Embeddings.Chunks == 0 {
+		t.Errorf("unexpected changes since %v: %+v", start, byTime)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in      string
+		since   time.Time
+		version int64
+		wantErr bool
+	}{
+		{in: "42", version: 42},
+		{in: "2026-03-09T08:00:00Z", since: time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)},
+		{in: "24h", since: now.Add(-24 * time.Hour)},
+		{in: "7d", since: now.Add(-7 * 24 * time.Hour)},
+		{in: "-1", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	} {
+		since, version, err := ParseSince(tc.in, now)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseSince(%q) error = %v", tc.in, err)
+			continue
+		}
+		if !tc.wantErr && (!since.Equal(tc.since) || version != tc.version) {
+			t.Errorf("ParseSince(%q) = %v, %d", tc.in, since, version)
+		}
+	}
+}
+
+func TestBenchCollectionStrategy(t *testing.T) {
+	m := newTestMMQ(t)
+	for _, doc := range []Document{
+		{Collection: "notes", Path: "falcon.md", Title: "Falcon diving speed", Content: "# Falcon diving speed\n\nPeregrines reach 300 km/h.\n\n## Hunting from above\n\nThey stoop on prey."},
+		{Collection: "notes", Path: "owl.md", Title: "Owl night vision", Content: "# Owl night vision\n\nLarge eyes gather light."},
+		{Collection: "notes", Path: "heron.md", Title: "Heron fishing", Content: "Herons wait motionless in shallow water."},
+	} {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strategy, err := m.resolveStrategy(StrategyAuto, "notes"); err != nil || strategy != StrategyHybrid {
+		t.Errorf("expected hybrid before benchmarking, got %q, %v", strategy, err)
+	}
+
+	bench, err := m.BenchCollection("notes", BenchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 个标题 + falcon 的小标题
+	if bench.Queries != 4 || bench.FTS.Hits != 4 || bench.FTS.MRR < 0.9 {
+		t.Errorf("expected full-text search to find all 4 source documents, got %+v", bench)
+	}
+	// 测试嵌入只对相同文本相似，标题查询找不到原文档
+	if bench.Recommended != StrategyFTS || bench.Reason == "" {
+		t.Errorf("expected fts to be recommended, got %+v", bench)
+	}
+	if strategy, _ := m.CollectionStrategy("notes"); strategy != StrategyFTS {
+		t.Errorf("expected the recommendation to be recorded, got %q", strategy)
+	}
+
+	results, err := m.Search("heron", SearchOptions{Collection: "notes", Strategy: StrategyAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Source != "fts" {
+		t.Errorf("expected StrategyAuto to use the recorded fts strategy, got %+v", results)
+	}
+	if strategy, _ := m.resolveStrategy(StrategyAuto, ""); strategy != StrategyHybrid {
+		t.Errorf("expected hybrid without a collection, got %q", strategy)
+	}
+	if _, err := m.BenchCollection("missing", BenchOptions{}); err == nil {
+		t.Error("expected an error for an unknown collection")
+	}
+
+	for _, tc := range []struct {
+		fts, vector StrategyScore
+		want        RetrievalStrategy
+	}{
+		{StrategyScore{MRR: 0.9, Hits: 9}, StrategyScore{MRR: 0.5, Hits: 6}, StrategyFTS},
+		{StrategyScore{MRR: 0.4, Hits: 5}, StrategyScore{MRR: 0.8, Hits: 9}, StrategyVector},
+		{StrategyScore{MRR: 0.7, Hits: 8}, StrategyScore{MRR: 0.65, Hits: 8}, StrategyHybrid},
+		{StrategyScore{}, StrategyScore{}, StrategyHybrid},
+	} {
+		if got, _ := recommendStrategy(tc.fts, tc.vector); got != tc.want {
+			t.Errorf("recommendStrategy(%+v, %+v) = %s, want %s", tc.fts, tc.vector, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyVectors(t *testing.T) {
+	m := newTestMMQ(t)
+	db := m.GetStore().DB()
+
+	for _, name := range []string{"corrupt", "nan", "unindexed", "model", "intact"} {
+		doc := Document{Collection: "notes", Path: name + ".md", Title: name, Content: "document about " + name + " vectors"}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.VerifyVectors(VerifyVectorsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Contents != 5 || report.Vectors != 5 || len(report.Issues) != 0 || report.Remaining != 0 {
+		t.Fatalf("Expected 5 intact vectors, got %+v", report)
+	}
+
+	hashOf := func(path string) string {
+		var hash string
+		if err := db.QueryRow("SELECT hash FROM documents WHERE path = ?", path).Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	for _, stmt := range []struct {
+		query string
+		path  string
+	}{
+		{"UPDATE content_vectors SET embedding = x'0102030405' WHERE hash = ?", "corrupt.md"},
+		{"UPDATE content_vectors SET embedding = x'0000c07f' || substr(embedding, 5) WHERE hash = ?", "nan.md"},
+		{"DELETE FROM vectors_vec WHERE hash_seq = ? || '_0'", "unindexed.md"},
+		{"UPDATE content_vectors SET model = 'old-model' WHERE hash = ?", "model.md"},
+	} {
+		if _, err := db.Exec(stmt.query, hashOf(stmt.path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err = m.VerifyVectors(VerifyVectorsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"corrupt.md":   VectorIssueCorrupt,
+		"nan.md":       VectorIssueNaN,
+		"unindexed.md": VectorIssueUnindexed,
+		"model.md":     VectorIssueModel,
+	}
+	if len(report.Issues) != len(want) || report.Remaining != len(want) {
+		t.Fatalf("Expected %d issues, got %+v", len(want), report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if want[issue.Path] != issue.Kind || issue.Chunk != 0 || issue.Model != m.cfg.EmbeddingModel {
+			t.Errorf("Unexpected issue %+v", issue)
+		}
+	}
+
+	// 隔离损坏的向量，受影响的文档等待重新嵌入
+	report, err = m.VerifyVectors(VerifyVectorsOptions{Quarantine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Quarantined != 3 || report.Remaining != 4 {
+		t.Errorf("Expected 3 quarantined vectors and 4 remaining problems, got %+v", report)
+	}
+	var quarantined int
+	if err := db.QueryRow("SELECT COUNT(*) FROM vector_quarantine WHERE length(embedding) = 5").Scan(&quarantined); err != nil || quarantined != 1 {
+		t.Errorf("Expected the corrupt blob to be kept in quarantine, got %d (%v)", quarantined, err)
+	}
+	report, err = m.VerifyVectors(VerifyVectorsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts[VectorIssueMissing] != 3 || report.Counts[VectorIssueUnindexed] != 1 {
+		t.Errorf("Expected 3 missing and 1 unindexed document, got %v", report.Counts)
+	}
+
+	// 重新嵌入后全部修复
+	report, err = m.VerifyVectors(VerifyVectorsOptions{Reembed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Embed == nil || report.Embed.Embedded != 4 || report.Remaining != 0 {
+		t.Errorf("Expected 4 documents re-embedded and nothing remaining, got %+v", report)
+	}
+	results, err := m.Search("document about unindexed vectors", SearchOptions{Limit: 1, Strategy: StrategyVector})
+	if err != nil || len(results) != 1 || results[0].Path != "unindexed.md" {
+		t.Errorf("Expected the re-embedded document to be searchable, got %+v (%v)", results, err)
+	}
+}
@@ -0,0 +1,163 @@
+package mmq
+
+import (
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// 事件类型
+const (
+	EventDocAdded          = store.EventDocAdded
+	EventDocUpdated        = store.EventDocUpdated
+	EventDocRemoved        = store.EventDocRemoved
+	EventCollectionRenamed = store.EventCollectionRenamed
+	EventMemoryStored      = store.EventMemoryStored
+	EventMemoryUpdated     = store.EventMemoryUpdated
+	EventMemoryDeleted     = store.EventMemoryDeleted
+)
+
+// Event 索引变更事件（文档增删改、记忆存储和删除）
+type Event struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Actor      string    `json:"actor,omitempty"`
+	Collection string    `json:"collection,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	DocID      string    `json:"docid,omitempty"` // 文档事件的内容 docid
+	MemoryID   string    `json:"memory_id,omitempty"`
+	Detail     string    `json:"detail,omitempty"` // 记忆类型或重命名前的集合名
+	Origin     string    `json:"origin,omitempty"` // 同步写入时为变更最初发生的实例 ID
+}
+
+// EventFilter 事件查询条件
+type EventFilter struct {
+	AfterID    int64     // 增量同步游标：只返回 ID 更大的事件
+	Since      time.Time // 只返回该时间之后的事件
+	Types      []string
+	Collection string
+	Actor      string
+	Limit      int // 0 表示不限制；有 AfterID 时取其后最早的 N 条，否则取最新的 N 条
+}
+
+// SetActor 设置之后变更记录的执行者（如 cli、agent 名称）
+func (m *MMQ) SetActor(actor string) {
+	m.store.SetActor(actor)
+}
+
+// Events 按发生顺序返回变更事件，同步工具可记录最后的 ID 作为下次的 AfterID
+func (m *MMQ) Events(filter EventFilter) ([]Event, error) {
+	events, err := m.store.ListEvents(store.EventFilter{
+		AfterID:    filter.AfterID,
+		Since:      filter.Since,
+		Types:      filter.Types,
+		Collection: filter.Collection,
+		Actor:      filter.Actor,
+		Limit:      filter.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, len(events))
+	for i, e := range events {
+		result[i] = toEvent(e)
+	}
+	return result, nil
+}
+
+// toEvent 转换事件日志记录
+func toEvent(e store.Event) Event {
+	event := Event{
+		ID:         e.ID,
+		Time:       e.Time,
+		Type:       e.Type,
+		Actor:      e.Actor,
+		Collection: e.Collection,
+		Path:       e.Path,
+		MemoryID:   e.MemoryID,
+		Detail:     e.Detail,
+		Origin:     e.Origin,
+	}
+	if len(e.Hash) >= 6 {
+		event.DocID = e.Hash[:6]
+	}
+	return event
+}
+
+// OnDocumentIndexed 注册文档新增或更新（doc_added、doc_updated）后的回调
+// 回调在变更提交后按事件顺序同步执行，只收到注册之后的事件
+func (m *MMQ) OnDocumentIndexed(fn func(Event)) {
+	m.addDocumentListener(&m.docIndexed, fn)
+}
+
+// OnDocumentRemoved 注册文档删除或停用（doc_removed）后的回调
+func (m *MMQ) OnDocumentRemoved(fn func(Event)) {
+	m.addDocumentListener(&m.docRemoved, fn)
+}
+
+func (m *MMQ) addDocumentListener(list *[]func(Event), fn func(Event)) {
+	m.docEventsMu.Lock()
+	defer m.docEventsMu.Unlock()
+	if !m.docCursorSet {
+		m.initDocumentCursor()
+	}
+	*list = append(*list, fn)
+	if !m.hasDocListeners.Swap(true) {
+		m.store.SetChangeHook(m.dispatchDocumentEvents)
+	}
+}
+
+// initDocumentCursor 从当前最大事件 ID 开始订阅，调用方持有 docEventsMu
+func (m *MMQ) initDocumentCursor() {
+	id, err := m.store.LastEventID()
+	if err != nil {
+		m.logf("Warning: document events unavailable: %v", err)
+		return
+	}
+	m.docCursor = id
+	m.docCursorSet = true
+}
+
+// dispatchDocumentEvents 文档变更提交后读取事件日志中的新事件并分发给回调
+// 回调中再次写入（或并发写入）产生的事件由当前分发循环继续处理，不会重入
+func (m *MMQ) dispatchDocumentEvents() {
+	m.docEventsPending.Store(true)
+	for m.docEventsPending.Load() {
+		if !m.docEventsMu.TryLock() {
+			return
+		}
+		for m.docEventsPending.Swap(false) {
+			m.drainDocumentEvents()
+		}
+		m.docEventsMu.Unlock()
+	}
+}
+
+// drainDocumentEvents 分发游标之后的文档事件，调用方持有 docEventsMu
+func (m *MMQ) drainDocumentEvents() {
+	if !m.docCursorSet {
+		m.initDocumentCursor()
+		return
+	}
+	events, err := m.store.ListEvents(store.EventFilter{
+		AfterID: m.docCursor,
+		Types:   []string{EventDocAdded, EventDocUpdated, EventDocRemoved},
+	})
+	if err != nil {
+		m.logf("Warning: failed to read document events: %v", err)
+		return
+	}
+	for _, e := range events {
+		m.docCursor = e.ID
+		listeners := m.docIndexed
+		if e.Type == EventDocRemoved {
+			listeners = m.docRemoved
+		}
+		event := toEvent(e)
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package mmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// RerankBlend 重排时 RRF 位置分数所占的权重（其余为重排器分数），按 RRF 排名分三段，取值 0-1
+type RerankBlend struct {
+	Top  float64 `json:"top"`  // 排名 1-3（默认 0.75）
+	Mid  float64 `json:"mid"`  // 排名 4-10（默认 0.6）
+	Tail float64 `json:"tail"` // 排名 11+（默认 0.4）
+}
+
+// DefaultRerankBlend 默认的重排混合权重
+var DefaultRerankBlend = RerankBlend(rag.DefaultBlendWeights)
+
+// rerankBlendMetaKey 本库学习到的混合权重在 meta 表中的键
+const rerankBlendMetaKey = "rerank_blend"
+
+// RelevanceJudgment 一条相关性标注（反馈或评测数据）
+type RelevanceJudgment struct {
+	Query    string `json:"query"`
+	Doc      string `json:"doc"` // collection/path、mmq://collection/path 或 #docid
+	Relevant bool   `json:"relevant"`
+}
+
+// Feedback 已记录的相关性反馈
+type Feedback struct {
+	ID         int64     `json:"id"`
+	Query      string    `json:"query"`
+	Collection string    `json:"collection"`
+	Path       string    `json:"path"`
+	Relevant   bool      `json:"relevant"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BlendTuneOptions TuneRerankBlend 的选项
+type BlendTuneOptions struct {
+	Judgments  []RelevanceJudgment // 评测数据；为空时使用已记录的反馈
+	Collection string              // 只在该集合中检索候选
+	DryRun     bool                // 只计算，不保存
+}
+
+// BlendTuning 学习混合权重的结果，分数为标注文档的 MAP
+type BlendTuning struct {
+	Weights       RerankBlend `json:"weights"`
+	Score         float64     `json:"score"`
+	Previous      RerankBlend `json:"previous"`
+	PreviousScore float64     `json:"previous_score"`
+	Queries       int         `json:"queries"` // 参与评估的查询数（候选中至少有一个相关文档）
+	Judgments     int         `json:"judgments"`
+	Saved         bool        `json:"saved"`
+}
+
+// Validate 检查权重都在 0-1 之间
+func (b RerankBlend) Validate() error {
+	return rag.BlendWeights(b).Validate()
+}
+
+// RecordFeedback 记录某次查询中一个文档是否相关，供 TuneRerankBlend 学习
+func (m *MMQ) RecordFeedback(query, doc string, relevant bool) error {
+	collection, path, err := m.resolveJudgedDoc(doc)
+	if err != nil {
+		return err
+	}
+	return m.store.InsertSearchFeedback(query, collection, path, relevant)
+}
+
+// ListFeedback 返回所有已记录的相关性反馈
+func (m *MMQ) ListFeedback() ([]Feedback, error) {
+	list, err := m.store.ListSearchFeedback()
+	if err != nil {
+		return nil, err
+	}
+	feedback := make([]Feedback, len(list))
+	for i, f := range list {
+		feedback[i] = Feedback{
+			ID:         f.ID,
+			Query:      f.Query,
+			Collection: f.Collection,
+			Path:       f.Path,
+			Relevant:   f.Relevant,
+			CreatedAt:  f.CreatedAt,
+		}
+	}
+	return feedback, nil
+}
+
+// ClearFeedback 删除所有相关性反馈，返回删除的条数
+func (m *MMQ) ClearFeedback() (int64, error) {
+	return m.store.ClearSearchFeedback()
+}
+
+// RerankBlend 返回当前使用的重排混合权重
+func (m *MMQ) RerankBlend() RerankBlend {
+	return RerankBlend(m.retriever.BlendWeights())
+}
+
+// TuneRerankBlend 用相关性标注学习本库的重排混合权重
+// 对每个查询做一次混合检索和重排，再网格搜索使标注文档 MAP 最高的权重；
+// 不是 DryRun 时保存到数据库，之后打开本库时自动使用（配置中的 rerank_blend 优先）
+func (m *MMQ) TuneRerankBlend(opts BlendTuneOptions) (*BlendTuning, error) {
+	judgments := opts.Judgments
+	if len(judgments) == 0 {
+		stored, err := m.store.ListSearchFeedback()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range stored {
+			judgments = append(judgments, RelevanceJudgment{Query: f.Query, Doc: f.Collection + "/" + f.Path, Relevant: f.Relevant})
+		}
+	}
+	if len(judgments) == 0 {
+		return nil, fmt.Errorf("no relevance judgments: record feedback first or pass evaluation data")
+	}
+
+	// 按查询分组，后面的标注覆盖前面的
+	var queries []string
+	byQuery := make(map[string]map[string]bool)
+	for _, j := range judgments {
+		collection, path, err := m.resolveJudgedDoc(j.Doc)
+		if err != nil {
+			return nil, fmt.Errorf("judgment for %q: %w", j.Query, err)
+		}
+		if byQuery[j.Query] == nil {
+			byQuery[j.Query] = make(map[string]bool)
+			queries = append(queries, j.Query)
+		}
+		byQuery[j.Query][collection+"/"+path] = j.Relevant
+	}
+
+	var samples [][]rag.BlendCandidate
+	for _, q := range queries {
+		sample, err := m.blendSample(q, opts.Collection, byQuery[q])
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	previous := m.retriever.BlendWeights()
+	fit := rag.FitBlendWeights(samples, previous)
+	if fit.Queries == 0 {
+		return nil, fmt.Errorf("none of the %d queries retrieved a document judged relevant", len(queries))
+	}
+	tuning := &BlendTuning{
+		Weights:       RerankBlend(fit.Weights),
+		Score:         fit.Score,
+		Previous:      RerankBlend(previous),
+		PreviousScore: fit.BaselineScore,
+		Queries:       fit.Queries,
+		Judgments:     len(judgments),
+	}
+	if opts.DryRun {
+		return tuning, nil
+	}
+
+	data, err := json.Marshal(fit.Weights)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.SetMeta(rerankBlendMetaKey, string(data)); err != nil {
+		return nil, err
+	}
+	tuning.Saved = true
+	if m.cfg.RerankBlend == nil {
+		m.retriever.SetBlendWeights(fit.Weights)
+	}
+	return tuning, nil
+}
+
+// ResetRerankBlend 删除本库学习到的混合权重，恢复配置或默认值
+func (m *MMQ) ResetRerankBlend() error {
+	if err := m.store.SetMeta(rerankBlendMetaKey, ""); err != nil {
+		return err
+	}
+	return m.applyRerankBlend()
+}
+
+// applyRerankBlend 设置重排混合权重：配置中的 rerank_blend 优先，其次是本库学习到的权重，最后是默认值
+func (m *MMQ) applyRerankBlend() error {
+	if m.cfg.RerankBlend != nil {
+		m.retriever.SetBlendWeights(rag.BlendWeights(*m.cfg.RerankBlend))
+		return nil
+	}
+
+	w := rag.DefaultBlendWeights
+	data, err := m.store.GetMeta(rerankBlendMetaKey)
+	if err != nil {
+		return err
+	}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &w); err != nil {
+			return fmt.Errorf("invalid learned rerank blend: %w", err)
+		}
+	}
+	m.retriever.SetBlendWeights(w)
+	return nil
+}
+
+// blendSample 检索一个查询的重排候选，并按标注标记相关性
+func (m *MMQ) blendSample(query, collection string, judged map[string]bool) ([]rag.BlendCandidate, error) {
+	limit := m.rerankLimit(0)
+	ragOpts, err := m.ragOptions(SearchOptions{Limit: limit, Collection: collection, Strategy: StrategyHybrid})
+	if err != nil {
+		return nil, err
+	}
+	contexts, _, err := m.retrieve(query, ragOpts, false)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve %q: %w", query, err)
+	}
+	if len(contexts) == 0 {
+		return nil, nil
+	}
+
+	docs := make([]llm.Document, len(contexts))
+	for i, c := range contexts {
+		docs[i] = llm.Document{ID: strconv.Itoa(i), Content: c.Text, Title: getMetadataString(c.Metadata, "title")}
+	}
+	scores, err := m.llm.Rerank(query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("rerank %q: %w", query, err)
+	}
+
+	sample := make([]rag.BlendCandidate, 0, len(scores))
+	for _, s := range scores {
+		i, err := strconv.Atoi(s.ID)
+		if err != nil || i < 0 || i >= len(contexts) {
+			continue
+		}
+		key := getMetadataString(contexts[i].Metadata, "collection") + "/" + getMetadataString(contexts[i].Metadata, "path")
+		sample = append(sample, rag.BlendCandidate{Rank: i + 1, RerankScore: s.Score, Relevant: judged[key]})
+	}
+	return sample, nil
+}
+
+// resolveJudgedDoc 把标注中的文档引用解析为集合和路径
+func (m *MMQ) resolveJudgedDoc(doc string) (collection, path string, err error) {
+	doc = strings.TrimSpace(doc)
+	var d *DocumentDetail
+	if strings.HasPrefix(doc, "#") || !strings.Contains(doc, "/") {
+		d, err = m.GetDocumentByID(doc)
+	} else {
+		d, err = m.GetDocumentByPath(doc)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("document %s not found: %w", doc, err)
+	}
+	return d.Collection, d.Path, nil
+}
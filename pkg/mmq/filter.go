@@ -0,0 +1,239 @@
+package mmq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterFields 过滤表达式可用的字段：score 为数值，其余为字符串
+var filterFields = map[string]func(c Context) interface{}{
+	"score":      func(c Context) interface{} { return c.Relevance },
+	"collection": func(c Context) interface{} { return getMetadataString(c.Metadata, "collection") },
+	"path":       func(c Context) interface{} { return getMetadataString(c.Metadata, "path") },
+	"title":      func(c Context) interface{} { return getMetadataString(c.Metadata, "title") },
+	"language":   func(c Context) interface{} { return getMetadataString(c.Metadata, "language") },
+	"date":       func(c Context) interface{} { return getMetadataString(c.Metadata, "date") },
+	"source":     func(c Context) interface{} { return getMetadataString(c.Metadata, "source") },
+	"docid":      func(c Context) interface{} { return shortDocID(getMetadataString(c.Metadata, "hash")) },
+	"text":       func(c Context) interface{} { return c.Text },
+}
+
+// ParseFilter 解析结果过滤表达式，返回可用作 RetrieveOptions.PostFilter 的函数
+//
+//	score>0.4 && collection!="web"
+//	(path~"design/" || title~"rfc") && date>="2024-01-01"
+//
+// 字段：score、collection、path、title、language、date、source、docid、text；
+// 比较：== != > >= < <=，~ 和 !~ 为不区分大小写的包含；用 && || ! 和括号组合。
+// 字符串用双引号或单引号，字符串之间的大小比较按字典序（适用于 YYYY-MM-DD 日期）
+func ParseFilter(expr string) (func(Context) bool, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	p := &filterParser{tokens: tokens}
+	fn, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return fn, nil
+}
+
+// filterToken 词法单元
+type filterToken struct {
+	kind byte // i 标识符，n 数字，s 字符串，o 运算符
+	text string
+}
+
+// lexFilter 切分过滤表达式
+func lexFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(expr[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, filterToken{'s', expr[i+1 : i+1+j]})
+			i += j + 2
+		case c >= '0' && c <= '9' || c == '.' || c == '-':
+			j := i + 1
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{'n', expr[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, filterToken{'i', strings.ToLower(expr[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", ">=", "<=", "!~", ">", "<", "~", "!", "(", ")"} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, filterToken{'o', op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser 递归下降解析器：or → and → unary → comparison
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == 'o' && p.tokens[p.pos].text == op
+}
+
+func (p *filterParser) or() (func(Context) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c Context) bool { return l(c) || right(c) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (func(Context) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c Context) bool { return l(c) && right(c) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (func(Context) bool, error) {
+	switch {
+	case p.peek("!"):
+		p.pos++
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(c Context) bool { return !inner(c) }, nil
+	case p.peek("("):
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (func(Context) bool, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("expected <field> <op> <value>")
+	}
+	field, op, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	p.pos += 3
+
+	if field.kind != 'i' {
+		return nil, fmt.Errorf("expected a field name, got %q", field.text)
+	}
+	get, ok := filterFields[field.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q (use score, collection, path, title, language, date, source, docid or text)", field.text)
+	}
+	if op.kind != 'o' {
+		return nil, fmt.Errorf("expected an operator after %s, got %q", field.text, op.text)
+	}
+
+	if field.text == "score" {
+		if value.kind != 'n' {
+			return nil, fmt.Errorf("score needs a number, got %q", value.text)
+		}
+		want, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value.text)
+		}
+		var cmp func(float64) bool
+		switch op.text {
+		case "==":
+			cmp = func(v float64) bool { return v == want }
+		case "!=":
+			cmp = func(v float64) bool { return v != want }
+		case ">":
+			cmp = func(v float64) bool { return v > want }
+		case ">=":
+			cmp = func(v float64) bool { return v >= want }
+		case "<":
+			cmp = func(v float64) bool { return v < want }
+		case "<=":
+			cmp = func(v float64) bool { return v <= want }
+		default:
+			return nil, fmt.Errorf("operator %s cannot be used with score", op.text)
+		}
+		return func(c Context) bool { return cmp(get(c).(float64)) }, nil
+	}
+
+	if value.kind != 's' {
+		return nil, fmt.Errorf("%s needs a quoted string, got %q", field.text, value.text)
+	}
+	want := value.text
+	var cmp func(string) bool
+	switch op.text {
+	case "==":
+		cmp = func(v string) bool { return v == want }
+	case "!=":
+		cmp = func(v string) bool { return v != want }
+	case ">":
+		cmp = func(v string) bool { return v > want }
+	case ">=":
+		cmp = func(v string) bool { return v >= want }
+	case "<":
+		cmp = func(v string) bool { return v < want }
+	case "<=":
+		cmp = func(v string) bool { return v <= want }
+	case "~", "!~":
+		lower, negate := strings.ToLower(want), op.text == "!~"
+		cmp = func(v string) bool { return strings.Contains(strings.ToLower(v), lower) != negate }
+	default:
+		return nil, fmt.Errorf("operator %s cannot be used with %s", op.text, field.text)
+	}
+	return func(c Context) bool { return cmp(get(c).(string)) }, nil
+}
@@ -0,0 +1,129 @@
+package mmq
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dyike/mmq/pkg/s3"
+)
+
+// CollectionFreshness 集合的索引时间和磁盘上的变化
+type CollectionFreshness struct {
+	Collection string     `json:"collection"`
+	IndexedAt  *time.Time `json:"indexed_at,omitempty"`  // 最近一次成功索引，nil 表示从未完整索引
+	EmbeddedAt *time.Time `json:"embedded_at,omitempty"` // 最近一次生成嵌入
+	Scanned    bool       `json:"scanned"`               // 是否扫描了磁盘（远端和对象存储集合不扫描）
+	Modified   int        `json:"modified"`              // 索引后修改过的文件
+	Added      int        `json:"added"`                 // 索引后新增、尚未索引的文件
+	Removed    int        `json:"removed"`               // 已索引但磁盘上不存在的文件
+	Error      string     `json:"error,omitempty"`       // 扫描失败的原因
+}
+
+// Changed 磁盘上与索引不一致的文件数
+func (f CollectionFreshness) Changed() int {
+	return f.Modified + f.Added + f.Removed
+}
+
+// CollectionFreshness 返回各集合的最近索引和嵌入时间，并按修改时间扫描集合目录，
+// 统计索引后修改、新增和删除的文件（只读取文件元数据，不读取内容）
+func (m *MMQ) CollectionFreshness() ([]CollectionFreshness, error) {
+	colls, err := m.store.CollectionFreshness()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CollectionFreshness, 0, len(colls))
+	for _, c := range colls {
+		f := CollectionFreshness{Collection: c.Name}
+		if !c.IndexedAt.IsZero() {
+			t := c.IndexedAt
+			f.IndexedAt = &t
+		}
+		if !c.EmbeddedAt.IsZero() {
+			t := c.EmbeddedAt
+			f.EmbeddedAt = &t
+		}
+		if c.Remote == "" && c.Path != "" && !s3.IsURL(c.Path) {
+			if err := m.scanFreshness(&f, c.Path, c.Mask, c.IndexedAt); err != nil {
+				f.Error = err.Error()
+			} else {
+				f.Scanned = true
+			}
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// scanFreshness 按索引时的规则遍历目录，与已索引文档的修改时间比较
+// 未索引的文件只在索引后修改过时计为新增，避免反复提示索引时就失败的文件
+func (m *MMQ) scanFreshness(f *CollectionFreshness, root, mask string, indexedAt time.Time) error {
+	if mask == "" {
+		mask = "**/*.md"
+	}
+	indexed, err := m.store.GetCollectionModTimes(f.Collection)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(indexed))
+	err = filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && filePath != root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || name == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if matched, err := doublestar.Match(mask, relPath); err != nil || !matched {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		// 存储的修改时间精确到秒
+		modTime := info.ModTime().Truncate(time.Second)
+
+		stored, ok := indexed[relPath]
+		switch {
+		case !ok:
+			if indexedAt.IsZero() || modTime.After(indexedAt) {
+				f.Added++
+			}
+		case modTime.After(stored):
+			f.Modified++
+		}
+		seen[relPath] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for path := range indexed {
+		if !seen[path] {
+			f.Removed++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package mmq
+
+import (
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// VerifyOptions 答案核查选项
+type VerifyOptions struct {
+	Threshold float64                             // 嵌入相似度阈值（默认 0.6）
+	Judge     func(prompt string) (string, error) // 设置后由生成模型逐句判断
+}
+
+// SentenceCheck 单个句子的核查结果
+type SentenceCheck struct {
+	Sentence  string  `json:"sentence"`
+	Supported bool    `json:"supported"`
+	Score     float64 `json:"score"`
+	Source    string  `json:"source,omitempty"`
+}
+
+// GroundingReport 答案核查报告
+type GroundingReport struct {
+	Method      string          `json:"method"` // embedding 或 judge
+	Sentences   []SentenceCheck `json:"sentences"`
+	Supported   int             `json:"supported"`
+	Unsupported int             `json:"unsupported"`
+}
+
+// Annotate 在没有依据的句子后加上标记
+func (r *GroundingReport) Annotate(marker string) string {
+	checks := make([]rag.SentenceCheck, len(r.Sentences))
+	for i, s := range r.Sentences {
+		checks[i] = rag.SentenceCheck{Sentence: s.Sentence, Supported: s.Supported}
+	}
+	return (&rag.GroundingReport{Sentences: checks}).Annotate(marker)
+}
+
+// VerifyAnswer 核查答案中的每句话是否能在检索到的上下文中找到依据
+func (m *MMQ) VerifyAnswer(answer string, contexts []Context, opts VerifyOptions) (*GroundingReport, error) {
+	verifier := rag.NewGroundingVerifier(m.embedding)
+	if opts.Threshold > 0 {
+		verifier.SetThreshold(opts.Threshold)
+	}
+	if opts.Judge != nil {
+		verifier.SetJudge(opts.Judge)
+	}
+
+	ragContexts := make([]rag.Context, len(contexts))
+	for i, c := range contexts {
+		ragContexts[i] = rag.Context{Text: c.Text, Source: c.Source, Relevance: c.Relevance}
+	}
+
+	report, err := verifier.Verify(answer, ragContexts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GroundingReport{
+		Method:      string(report.Method),
+		Supported:   report.Supported,
+		Unsupported: report.Unsupported,
+	}
+	for _, s := range report.Sentences {
+		result.Sentences = append(result.Sentences, SentenceCheck{
+			Sentence:  s.Sentence,
+			Supported: s.Supported,
+			Score:     s.Score,
+			Source:    s.Source,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 结果分组方式
+const (
+	GroupByDocument   = "doc"        // 同一文档的多个分块命中归到一个文档下
+	GroupByCollection = "collection" // 按集合分组
+)
+
+// maxChunkHits 按文档分组时每个文档最多返回的分块命中数
+const maxChunkHits = 3
+
+// ResultGroup 一组搜索结果，Score 为组内最高分
+type ResultGroup struct {
+	Key        string         `json:"key"` // 文档为 collection/path，集合为集合名
+	Collection string         `json:"collection"`
+	Path       string         `json:"path,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	DocID      string         `json:"docid,omitempty"`
+	Score      float64        `json:"score"`
+	Hits       []SearchResult `json:"hits"`
+}
+
+// ValidateGroupBy 检查分组方式，空字符串表示不分组
+func ValidateGroupBy(by string) error {
+	switch by {
+	case "", GroupByDocument, GroupByCollection:
+		return nil
+	default:
+		return fmt.Errorf("invalid group-by %q (use %s or %s)", by, GroupByDocument, GroupByCollection)
+	}
+}
+
+// GroupResults 按文档或集合分组，组按最高分降序，组内保持原有顺序
+func GroupResults(results []SearchResult, by string) ([]ResultGroup, error) {
+	if by == "" {
+		return nil, nil
+	}
+	if err := ValidateGroupBy(by); err != nil {
+		return nil, err
+	}
+
+	var groups []ResultGroup
+	index := make(map[string]int)
+	for _, r := range results {
+		key := r.Collection
+		if by == GroupByDocument {
+			key = r.Collection + "/" + r.Path
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			g := ResultGroup{Key: key, Collection: r.Collection, Score: r.Score}
+			if by == GroupByDocument {
+				g.Path, g.Title, g.DocID = r.Path, r.Title, r.DocID
+			}
+			groups = append(groups, g)
+		}
+		if r.Score > groups[i].Score {
+			groups[i].Score = r.Score
+		}
+		groups[i].Hits = append(groups[i].Hits, r)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Score > groups[j].Score })
+	return groups, nil
+}
+
+// chunkHits 把每个文档结果展开为与查询词重合的分块（最多 maxChunkHits 个）
+// 分块得分按命中数相对最佳分块缩放文档得分；没有命中的文档保持原样
+func (m *MMQ) chunkHits(query string, results []SearchResult) []SearchResult {
+	terms := queryTerms(query)
+	var out []SearchResult
+	for _, r := range results {
+		chunks := m.chunkDocument(r.Path, r.Content)
+		if len(chunks) <= 1 {
+			out = append(out, r)
+			continue
+		}
+
+		type hit struct{ idx, hits int }
+		var hits []hit
+		for j, c := range chunks {
+			lower := strings.ToLower(c.Text)
+			n := 0
+			for _, t := range terms {
+				n += strings.Count(lower, t)
+			}
+			if n > 0 {
+				hits = append(hits, hit{j, n})
+			}
+		}
+		if len(hits) == 0 {
+			out = append(out, r)
+			continue
+		}
+		sort.SliceStable(hits, func(a, b int) bool { return hits[a].hits > hits[b].hits })
+		if len(hits) > maxChunkHits {
+			hits = hits[:maxChunkHits]
+		}
+
+		for _, h := range hits {
+			c := r
+			c.Content = chunks[h.idx].Text
+			c.Snippet = truncateRunes(collapseSpaces(c.Content), 200)
+			c.Score = r.Score * float64(h.hits) / float64(hits[0].hits)
+			c.Metadata = make(map[string]interface{}, len(r.Metadata)+2)
+			for k, v := range r.Metadata {
+				c.Metadata[k] = v
+			}
+			c.Metadata["chunk"] = h.idx
+			c.Metadata["pos"] = chunks[h.idx].Pos
+			out = append(out, c)
+		}
+	}
+	return out
+}
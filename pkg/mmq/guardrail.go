@@ -0,0 +1,236 @@
+package mmq
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// HookStage 钩子触发点
+type HookStage string
+
+const (
+	HookPreRetrieval   HookStage = "pre_retrieval"   // 检索前：可改写或拒绝查询
+	HookPreGeneration  HookStage = "pre_generation"  // 生成前：可修改或拒绝 prompt
+	HookPostGeneration HookStage = "post_generation" // 生成后：可修改或拒绝输出
+)
+
+// HookPayload 钩子处理的内容，钩子可以就地修改
+type HookPayload struct {
+	Stage  HookStage
+	Query  string // 用户查询
+	Prompt string // 发送给生成模型的 prompt（pre_generation、post_generation）
+	Output string // 生成结果（post_generation）
+}
+
+// text 该阶段检查的内容
+func (p *HookPayload) text() *string {
+	switch p.Stage {
+	case HookPreGeneration:
+		return &p.Prompt
+	case HookPostGeneration:
+		return &p.Output
+	default:
+		return &p.Query
+	}
+}
+
+// Hook 钩子函数，返回错误表示拒绝（用 Veto 说明原因）
+type Hook func(p *HookPayload) error
+
+// VetoError 钩子拒绝了查询、prompt 或输出
+type VetoError struct {
+	Stage  HookStage
+	Hook   string
+	Reason string
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("%s vetoed by %s: %s", e.Stage, e.Hook, e.Reason)
+}
+
+// Veto 构造拒绝错误，阶段和钩子名由 RunHooks 填充
+func Veto(reason string) error {
+	return &VetoError{Reason: reason}
+}
+
+// IsVetoed 错误是否来自钩子拒绝
+func IsVetoed(err error) bool {
+	var veto *VetoError
+	return errors.As(err, &veto)
+}
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// AddHook 注册钩子，同一阶段按注册顺序执行
+func (m *MMQ) AddHook(stage HookStage, name string, hook Hook) {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+	if m.hooks == nil {
+		m.hooks = make(map[HookStage][]namedHook)
+	}
+	m.hooks[stage] = append(m.hooks[stage], namedHook{name: name, fn: hook})
+}
+
+// HasHooks 该阶段是否注册了钩子
+func (m *MMQ) HasHooks(stage HookStage) bool {
+	m.hooksMu.RLock()
+	defer m.hooksMu.RUnlock()
+	return len(m.hooks[stage]) > 0
+}
+
+// RunHooks 依次执行该阶段的钩子，任一钩子拒绝即停止
+// Search/Query 会自动执行 pre_retrieval；自行调用生成模型时在生成前后调用 RunHooks
+func (m *MMQ) RunHooks(stage HookStage, payload *HookPayload) error {
+	m.hooksMu.RLock()
+	hooks := m.hooks[stage]
+	m.hooksMu.RUnlock()
+
+	payload.Stage = stage
+	for _, h := range hooks {
+		if err := h.fn(payload); err != nil {
+			var veto *VetoError
+			if errors.As(err, &veto) {
+				veto.Stage = stage
+				if veto.Hook == "" {
+					veto.Hook = h.name
+				}
+				return veto
+			}
+			return fmt.Errorf("%s hook %s failed: %w", stage, h.name, err)
+		}
+	}
+	return nil
+}
+
+// SetGuardrailJudge 设置 llm 类型护栏使用的生成函数（默认使用本地生成模型）
+func (m *MMQ) SetGuardrailJudge(judge func(prompt string) (string, error)) {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+	m.guardJudge = judge
+}
+
+// judge 调用护栏判断模型
+func (m *MMQ) judge(prompt string) (string, error) {
+	m.hooksMu.RLock()
+	judge := m.guardJudge
+	m.hooksMu.RUnlock()
+	if judge != nil {
+		return judge(prompt)
+	}
+
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0
+	opts.MaxTokens = 32
+	return m.llm.Generate(prompt, opts)
+}
+
+// 护栏规则类型和动作
+const (
+	GuardrailRegex = "regex" // 正则匹配
+	GuardrailLLM   = "llm"   // 由生成模型判断
+
+	GuardrailVeto   = "veto"   // 拒绝（默认）
+	GuardrailRedact = "redact" // 替换匹配内容（仅 regex）
+)
+
+// GuardrailRule 配置文件中的护栏规则
+type GuardrailRule struct {
+	Name    string    `json:"name"`
+	Stage   HookStage `json:"stage"`
+	Type    string    `json:"type"`              // regex 或 llm
+	Pattern string    `json:"pattern,omitempty"` // regex 规则的正则
+	Action  string    `json:"action,omitempty"`  // veto（默认）或 redact
+	Replace string    `json:"replace,omitempty"` // redact 的替换文本（默认 [BLOCKED]）
+	Prompt  string    `json:"prompt,omitempty"`  // llm 规则的提示词，{{text}} 替换为待检查内容
+}
+
+// defaultGuardrailPrompt llm 规则的默认提示词
+const defaultGuardrailPrompt = `判断以下内容是否安全、合规，可以继续处理。
+只回答 ALLOW 或 BLOCK，不要输出其他内容。
+
+内容：
+{{text}}`
+
+// hook 将规则转换为钩子
+func (r GuardrailRule) hook(m *MMQ) (Hook, error) {
+	switch r.Stage {
+	case HookPreRetrieval, HookPreGeneration, HookPostGeneration:
+	default:
+		return nil, fmt.Errorf("guardrail %s: invalid stage %q (use pre_retrieval, pre_generation or post_generation)", r.Name, r.Stage)
+	}
+
+	action := r.Action
+	if action == "" {
+		action = GuardrailVeto
+	}
+	if action != GuardrailVeto && action != GuardrailRedact {
+		return nil, fmt.Errorf("guardrail %s: invalid action %q (use veto or redact)", r.Name, r.Action)
+	}
+
+	switch r.Type {
+	case GuardrailRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail %s: invalid pattern: %w", r.Name, err)
+		}
+		replace := r.Replace
+		if replace == "" {
+			replace = "[BLOCKED]"
+		}
+		return func(p *HookPayload) error {
+			text := p.text()
+			if !re.MatchString(*text) {
+				return nil
+			}
+			if action == GuardrailRedact {
+				*text = re.ReplaceAllString(*text, replace)
+				return nil
+			}
+			return Veto(fmt.Sprintf("matched pattern %s", r.Pattern))
+		}, nil
+
+	case GuardrailLLM:
+		if action != GuardrailVeto {
+			return nil, fmt.Errorf("guardrail %s: llm rules only support veto", r.Name)
+		}
+		prompt := r.Prompt
+		if prompt == "" {
+			prompt = defaultGuardrailPrompt
+		}
+		return func(p *HookPayload) error {
+			answer, err := m.judge(strings.ReplaceAll(prompt, "{{text}}", *p.text()))
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(answer)), "BLOCK") {
+				return Veto("rejected by model check")
+			}
+			return nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("guardrail %s: invalid type %q (use regex or llm)", r.Name, r.Type)
+	}
+}
+
+// setupGuardrails 注册配置文件中的护栏规则
+func (m *MMQ) setupGuardrails() error {
+	for i, rule := range m.cfg.Guardrails {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("guardrail-%d", i+1)
+		}
+		hook, err := rule.hook(m)
+		if err != nil {
+			return err
+		}
+		m.AddHook(rule.Stage, rule.Name, hook)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package harness
+
+// Doc 语料中的一个文档，Path 为集合内的相对路径（以 / 分隔）
+type Doc struct {
+	Path    string
+	Content string
+}
+
+// DefaultCorpus 默认语料：几个主题互不重叠的 Markdown 笔记，每个主题有可区分的关键词，
+// 便于断言某个查询应引用哪篇文档
+func DefaultCorpus() []Doc {
+	return []Doc{
+		{Path: "go/concurrency.md", Content: `# Go Concurrency
+
+Goroutines are lightweight threads managed by the Go runtime.
+Channels connect goroutines: an unbuffered channel blocks the sender until a receiver is ready.
+
+## Select
+
+The select statement waits on several channel operations and runs the first one that can proceed.
+Use a context with a deadline to stop goroutines that would otherwise leak.
+`},
+		{Path: "go/errors.md", Content: `# Go Error Handling
+
+Wrap errors with fmt.Errorf and the %w verb so callers can inspect them with errors.Is and errors.As.
+Sentinel errors such as io.EOF are compared with errors.Is, never with string matching.
+
+## Panics
+
+Reserve panic for programmer mistakes; recover only at goroutine boundaries such as HTTP handlers.
+`},
+		{Path: "ops/backup.md", Content: `# SQLite Backups
+
+Take online backups with the VACUUM INTO statement or the sqlite3 .backup command.
+Copying the database file while a writer holds the WAL can produce a corrupt snapshot.
+
+## Restore drill
+
+Restore the latest backup to a scratch host every month and run PRAGMA integrity_check.
+`},
+		{Path: "ops/deploy.md", Content: `# Kubernetes Deployments
+
+Roll out new versions with kubectl rollout and watch the status until every replica is ready.
+Readiness probes keep traffic away from pods that are still warming caches.
+
+## Rollback
+
+kubectl rollout undo returns the deployment to the previous ReplicaSet.
+`},
+		{Path: "recipes/sourdough.md", Content: `# Sourdough Bread
+
+Feed the starter twelve hours before mixing; it should double and smell slightly sour.
+Mix flour, water and salt, then stretch and fold the dough every thirty minutes.
+
+## Baking
+
+Bake in a preheated Dutch oven at 250°C with the lid on for twenty minutes, then uncovered until dark brown.
+`},
+		{Path: "meetings/2024-03-retro.md", Content: `# Retro March 2024
+
+The release slipped a week because the migration script was not tested against production data.
+Action item: add a staging database refreshed nightly from anonymized production dumps.
+Action item: on-call handbook gets a section about database restore.
+`},
+	}
+}
@@ -0,0 +1,193 @@
+// Package harness 提供端到端集成测试工具：真实的 SQLite 数据库和索引流程，配合确定性的 MockLLM，
+// 以及索引语料、执行查询和断言引用的场景函数，便于在不加载模型的情况下测试基于 mmq 的 agent 集成
+//
+//	func TestAgent(t *testing.T) {
+//		h := harness.New(t)
+//		h.IndexCorpus("notes", harness.DefaultCorpus())
+//		res := h.Query("how do I roll back a kubernetes deployment", mmq.QueryOptions{})
+//		h.AssertTopCited(harness.ContextSources(res.Contexts), "notes/ops/deploy.md")
+//	}
+package harness
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+// MockEmbeddingModel 嵌入向量记录的模型名
+const MockEmbeddingModel = "mock-embed"
+
+// Harness 一个隔离的 mmq 实例：数据库和语料文件都在测试的临时目录中，测试结束时关闭
+// 嵌入的 *mmq.MMQ 可直接调用全部 API；场景函数出错时直接让测试失败，
+// 需要检查错误时调用 h.MMQ 上的同名方法（如 h.MMQ.Query）
+type Harness struct {
+	*mmq.MMQ
+	LLM *MockLLM
+	Dir string // 临时目录，语料写入 Dir/corpus/<集合>
+
+	t testing.TB
+}
+
+// New 创建 Harness；插件和流水线目录为空，日志丢弃
+// opts 在默认选项之后应用（如 WithConfig 调整检索参数、WithLogger 查看日志）
+func New(t testing.TB, opts ...mmq.Option) *Harness {
+	t.Helper()
+
+	dir := t.TempDir()
+	mock := NewMockLLM(DefaultDimensions)
+	base := []mmq.Option{
+		mmq.WithLLM(mock),
+		mmq.WithEmbeddingModel(MockEmbeddingModel),
+		mmq.WithCacheDir(filepath.Join(dir, "cache")),
+		mmq.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		mmq.WithConfig(func(cfg *mmq.Config) {
+			cfg.PluginDir = ""
+			cfg.PipelineDir = ""
+		}),
+	}
+	m, err := mmq.New(filepath.Join(dir, "mmq.db"), append(base, opts...)...)
+	if err != nil {
+		t.Fatalf("harness: failed to create mmq: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	return &Harness{MMQ: m, LLM: mock, Dir: dir, t: t}
+}
+
+// IndexCorpus 把文档写入 Dir/corpus/<collection> 并按真实流程索引目录、生成嵌入
+func (h *Harness) IndexCorpus(collection string, docs []Doc) *mmq.IndexSummary {
+	h.t.Helper()
+
+	root := filepath.Join(h.Dir, "corpus", collection)
+	for _, doc := range docs {
+		path := filepath.Join(root, filepath.FromSlash(doc.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			h.t.Fatalf("harness: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(doc.Content), 0644); err != nil {
+			h.t.Fatalf("harness: %v", err)
+		}
+	}
+
+	summary, err := h.IndexDirectory(root, mmq.IndexOptions{Collection: collection})
+	if err != nil {
+		h.t.Fatalf("harness: failed to index %s: %v", collection, err)
+	}
+	h.Embed()
+	return summary
+}
+
+// Embed 为尚未嵌入的文档生成嵌入（直接修改语料后调用）
+func (h *Harness) Embed() *mmq.EmbedReport {
+	h.t.Helper()
+	report, err := h.EmbedDocuments(mmq.EmbedOptions{Progress: func(mmq.EmbedProgress) {}})
+	if err != nil {
+		h.t.Fatalf("harness: failed to embed documents: %v", err)
+	}
+	if len(report.Failed) > 0 {
+		h.t.Fatalf("harness: %d documents failed to embed: %+v", len(report.Failed), report.Failed)
+	}
+	return report
+}
+
+// Query 执行统一查询（默认混合检索）
+func (h *Harness) Query(query string, opts mmq.QueryOptions) *mmq.QueryResult {
+	h.t.Helper()
+	res, err := h.MMQ.Query(query, opts)
+	if err != nil {
+		h.t.Fatalf("harness: query %q failed: %v", query, err)
+	}
+	return res
+}
+
+// Search 执行搜索
+func (h *Harness) Search(query string, opts mmq.SearchOptions) []mmq.SearchResult {
+	h.t.Helper()
+	results, err := h.MMQ.Search(query, opts)
+	if err != nil {
+		h.t.Fatalf("harness: search %q failed: %v", query, err)
+	}
+	return results
+}
+
+// Retrieve 检索可注入 prompt 的上下文
+func (h *Harness) Retrieve(query string, opts mmq.RetrieveOptions) []mmq.Context {
+	h.t.Helper()
+	contexts, err := h.RetrieveContext(query, opts)
+	if err != nil {
+		h.t.Fatalf("harness: retrieve %q failed: %v", query, err)
+	}
+	return contexts
+}
+
+// Remember 存储一条当前时间的记忆
+func (h *Harness) Remember(memType mmq.MemoryType, content string) {
+	h.t.Helper()
+	mem := mmq.Memory{Type: memType, Content: content, Timestamp: time.Now(), Importance: 0.5}
+	if err := h.StoreMemory(mem); err != nil {
+		h.t.Fatalf("harness: failed to store memory: %v", err)
+	}
+}
+
+// ResultSources 搜索结果的引用（集合/路径），按结果顺序
+func ResultSources(results []mmq.SearchResult) []string {
+	sources := make([]string, len(results))
+	for i, r := range results {
+		sources[i] = r.Collection + "/" + r.Path
+	}
+	return sources
+}
+
+// ContextSources 上下文的引用，按上下文顺序；合并的上下文展开为各个来源
+func ContextSources(contexts []mmq.Context) []string {
+	var sources []string
+	for _, c := range contexts {
+		sources = append(sources, strings.Split(c.Source, ", ")...)
+	}
+	return sources
+}
+
+// AssertCited 断言 want 中的每个引用都出现在 sources 中
+func (h *Harness) AssertCited(sources []string, want ...string) {
+	h.t.Helper()
+	for _, w := range want {
+		if indexOf(sources, w) < 0 {
+			h.t.Errorf("expected %s to be cited, got %v", w, sources)
+		}
+	}
+}
+
+// AssertNotCited 断言 unwanted 中的引用都没有出现在 sources 中
+func (h *Harness) AssertNotCited(sources []string, unwanted ...string) {
+	h.t.Helper()
+	for _, u := range unwanted {
+		if indexOf(sources, u) >= 0 {
+			h.t.Errorf("expected %s not to be cited, got %v", u, sources)
+		}
+	}
+}
+
+// AssertTopCited 断言第一个引用是 want
+func (h *Harness) AssertTopCited(sources []string, want string) {
+	h.t.Helper()
+	if len(sources) == 0 || sources[0] != want {
+		h.t.Errorf("expected %s to be cited first, got %v", want, sources)
+	}
+}
+
+// indexOf 引用在列表中的位置，不存在时为 -1
+func indexOf(sources []string, source string) int {
+	for i, s := range sources {
+		if s == source {
+			return i
+		}
+	}
+	return -1
+}
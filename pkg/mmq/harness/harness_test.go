@@ -0,0 +1,79 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/mmq"
+)
+
+func TestHarnessScenario(t *testing.T) {
+	h := New(t)
+	summary := h.IndexCorpus("notes", DefaultCorpus())
+	if summary.Added != len(DefaultCorpus()) {
+		t.Fatalf("Expected %d documents indexed, got %+v", len(DefaultCorpus()), summary)
+	}
+	if calls := h.LLM.Calls(); calls.Embed == 0 {
+		t.Error("Expected documents to be embedded with the mock LLM")
+	}
+
+	// 全文、向量和混合检索都应把主题对应的文档排在第一
+	for _, strategy := range []mmq.RetrievalStrategy{mmq.StrategyFTS, mmq.StrategyVector, mmq.StrategyHybrid} {
+		results := h.Search("kubernetes rollout undo", mmq.SearchOptions{Limit: 3, Strategy: strategy})
+		h.AssertTopCited(ResultSources(results), "notes/ops/deploy.md")
+	}
+
+	res := h.Query("sqlite backup restore integrity check", mmq.QueryOptions{SearchOptions: mmq.SearchOptions{Limit: 3, Rerank: true}})
+	sources := ContextSources(res.Contexts)
+	h.AssertTopCited(sources, "notes/ops/backup.md")
+	h.AssertNotCited(sources, "notes/recipes/sourdough.md")
+	if h.LLM.Calls().Rerank == 0 {
+		t.Error("Expected the mock reranker to be used")
+	}
+
+	h.Remember(mmq.MemoryTypePreference, "The user bakes sourdough bread on weekends")
+	retrieval, err := h.RetrieveWithMemories("does the user bake sourdough bread", mmq.RetrieveOptions{Limit: 2, Strategy: mmq.StrategyHybrid}, mmq.MemoryJoin{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AssertCited(ContextSources(retrieval.Documents), "notes/recipes/sourdough.md")
+	if len(retrieval.Memories) != 1 {
+		t.Errorf("Expected the sourdough memory to be recalled, got %+v", retrieval.Memories)
+	}
+}
+
+func TestMockLLM(t *testing.T) {
+	m := NewMockLLM(0)
+
+	a, _ := m.Embed("goroutines and channels", false)
+	b, _ := m.Embed("channels connect goroutines", true)
+	c, _ := m.Embed("sourdough bread starter", false)
+	if len(a) != DefaultDimensions {
+		t.Fatalf("Expected %d dimensions, got %d", DefaultDimensions, len(a))
+	}
+	if dot(a, b) <= dot(a, c) {
+		t.Errorf("Expected texts sharing words to be closer: %f <= %f", dot(a, b), dot(a, c))
+	}
+	if _, err := m.Embed("  ", false); err == nil {
+		t.Error("Expected empty text to fail")
+	}
+
+	m.Respond("summarize", "a summary")
+	if got, _ := m.Generate("please summarize this", llm.DefaultGenerateOptions()); got != "a summary" {
+		t.Errorf("Expected registered response, got %q", got)
+	}
+	if got, _ := m.Generate("anything else", llm.DefaultGenerateOptions()); got != DefaultMockResponse {
+		t.Errorf("Expected default response, got %q", got)
+	}
+	if calls := m.Calls(); calls.Embed != 3 || calls.Generate != 2 {
+		t.Errorf("Unexpected call counts: %+v", calls)
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
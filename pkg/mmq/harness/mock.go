@@ -0,0 +1,232 @@
+package harness
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dyike/mmq/pkg/lang"
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// DefaultDimensions MockLLM 默认的嵌入维度
+const DefaultDimensions = 256
+
+// MockLLM 确定性的 LLM 实现，不加载模型
+//   - Embed：词袋特征哈希后归一化，共享词越多的文本向量越接近（去掉停用词）
+//   - Rerank：查询词在标题和内容中出现的比例
+//   - ExpandQuery：只返回原始查询的 lex 和 vec 两路
+//   - Generate：返回 Respond 登记的回复，未登记时返回固定文本
+type MockLLM struct {
+	dimensions int
+
+	mu        sync.Mutex
+	calls     MockCalls
+	responses []mockResponse
+	loaded    map[llm.ModelType]bool
+}
+
+// MockCalls 各能力的调用次数
+type MockCalls struct {
+	Embed    int // 每个文本计一次（EmbedBatch 按文本数计）
+	Rerank   int
+	Generate int
+	Expand   int
+}
+
+// mockResponse prompt 包含 substr 时返回 text
+type mockResponse struct {
+	substr string
+	text   string
+}
+
+// DefaultMockResponse 没有匹配的登记回复时 Generate 返回的文本
+const DefaultMockResponse = "mock response"
+
+// NewMockLLM 创建 MockLLM，dimensions 为 0 时使用 DefaultDimensions
+func NewMockLLM(dimensions int) *MockLLM {
+	if dimensions <= 0 {
+		dimensions = DefaultDimensions
+	}
+	return &MockLLM{
+		dimensions: dimensions,
+		loaded:     make(map[llm.ModelType]bool),
+	}
+}
+
+// Respond 登记生成回复：prompt 包含 substr 时 Generate 返回 text（按登记顺序匹配第一个）
+func (m *MockLLM) Respond(substr, text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, mockResponse{substr: substr, text: text})
+}
+
+// Calls 返回到目前为止的调用次数
+func (m *MockLLM) Calls() MockCalls {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// ResetCalls 清零调用次数
+func (m *MockLLM) ResetCalls() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = MockCalls{}
+}
+
+// Embed 生成词袋哈希向量
+func (m *MockLLM) Embed(text string, isQuery bool) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty text")
+	}
+	m.mu.Lock()
+	m.calls.Embed++
+	m.loaded[llm.ModelTypeEmbedding] = true
+	m.mu.Unlock()
+
+	embedding := make([]float64, m.dimensions)
+	terms := mockTerms(text)
+	if len(terms) == 0 {
+		// 只有停用词或标点时按整段文本取一维，避免零向量
+		terms = []string{text}
+	}
+	for _, term := range terms {
+		h := fnv.New32a()
+		h.Write([]byte(term))
+		sum := h.Sum32()
+		sign := 1.0
+		if sum&0x80000000 != 0 {
+			sign = -1
+		}
+		embedding[int(sum%uint32(m.dimensions))] += sign
+	}
+
+	var norm float64
+	for _, v := range embedding {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	result := make([]float32, m.dimensions)
+	for i, v := range embedding {
+		result[i] = float32(v / norm)
+	}
+	return result, nil
+}
+
+// EmbedBatch 逐个生成嵌入
+func (m *MockLLM) EmbedBatch(texts []string, isQuery bool) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := m.Embed(text, isQuery)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = emb
+	}
+	return embeddings, nil
+}
+
+// Rerank 按查询词的覆盖比例打分，分数从高到低返回
+func (m *MockLLM) Rerank(query string, docs []llm.Document) ([]llm.RerankResult, error) {
+	m.mu.Lock()
+	m.calls.Rerank++
+	m.loaded[llm.ModelTypeRerank] = true
+	m.mu.Unlock()
+
+	queryTerms := mockTerms(query)
+	results := make([]llm.RerankResult, len(docs))
+	for i, doc := range docs {
+		docTerms := make(map[string]bool)
+		for _, t := range mockTerms(doc.Title + "\n" + doc.Content) {
+			docTerms[t] = true
+		}
+		score := 0.0
+		if len(queryTerms) > 0 {
+			common := 0
+			for _, t := range queryTerms {
+				if docTerms[t] {
+					common++
+				}
+			}
+			score = float64(common) / float64(len(queryTerms))
+		}
+		results[i] = llm.RerankResult{ID: doc.ID, Score: score, Index: i}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// Generate 返回登记的回复
+func (m *MockLLM) Generate(prompt string, opts llm.GenerateOptions) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls.Generate++
+	m.loaded[llm.ModelTypeGenerate] = true
+	for _, r := range m.responses {
+		if strings.Contains(prompt, r.substr) {
+			return r.text, nil
+		}
+	}
+	return DefaultMockResponse, nil
+}
+
+// ExpandQuery 不做扩展，原始查询同时用于全文和向量检索
+func (m *MockLLM) ExpandQuery(query string) ([]llm.QueryExpansion, error) {
+	m.mu.Lock()
+	m.calls.Expand++
+	m.mu.Unlock()
+	return []llm.QueryExpansion{
+		{Type: "lex", Text: query, Weight: 2.0},
+		{Type: "vec", Text: query, Weight: 2.0},
+	}, nil
+}
+
+// Close 无需释放资源
+func (m *MockLLM) Close() error {
+	return nil
+}
+
+// IsLoaded 该类模型是否被调用过
+func (m *MockLLM) IsLoaded(modelType llm.ModelType) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loaded[modelType]
+}
+
+// SetModelPath 忽略模型路径
+func (m *MockLLM) SetModelPath(modelType llm.ModelType, path string) {}
+
+// mockTerms 小写分词并去掉停用词；汉字逐字作为词
+func mockTerms(text string) []string {
+	code := lang.Detect(text)
+	var terms []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			if w := string(word); !lang.IsStopword(code, w) {
+				terms = append(terms, w)
+			}
+			word = nil
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
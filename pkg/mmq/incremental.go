@@ -0,0 +1,102 @@
+package mmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SearchStage 增量搜索的阶段
+type SearchStage string
+
+const (
+	SearchStageFTS    SearchStage = "fts"    // 仅 BM25，立即返回
+	SearchStageHybrid SearchStage = "hybrid" // BM25 + 向量融合，不做扩展和重排
+	SearchStageFinal  SearchStage = "final"  // 按请求的选项执行完整流程
+)
+
+// SearchUpdate 增量搜索的一批结果，每批完整替换上一批
+type SearchUpdate struct {
+	Stage   SearchStage    `json:"stage"`
+	Results []SearchResult `json:"results"`
+	Final   bool           `json:"final"`   // 最后一批，之后通道关闭
+	Elapsed time.Duration  `json:"elapsed"` // 从开始搜索到本批结果的耗时
+	Err     error          `json:"-"`       // 本阶段失败（此时 Final 为 true）
+	Error   string         `json:"error,omitempty"`
+}
+
+// SearchIncremental 边输入边搜索：先推送 FTS 结果，再推送混合检索结果，最后推送扩展/重排后的完整结果
+// 返回的通道在最后一批（Final）后关闭；ctx 取消（如用户继续输入）后不再开始新的阶段
+// Strategy 为空时按混合检索；只有最后一批的查询写入查询历史
+func (m *MMQ) SearchIncremental(ctx context.Context, query string, opts SearchOptions) <-chan SearchUpdate {
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyHybrid
+	}
+
+	type stage struct {
+		name SearchStage
+		opts SearchOptions
+	}
+	fts := opts
+	fts.Strategy, fts.Rerank, fts.ExpandQuery, fts.Pipeline = StrategyFTS, false, false, ""
+	stages := []stage{{SearchStageFTS, fts}}
+	if opts.Pipeline != "" || opts.Strategy != StrategyFTS || opts.Rerank || opts.ExpandQuery {
+		if opts.Pipeline == "" && opts.Strategy == StrategyHybrid && (opts.Rerank || opts.ExpandQuery) {
+			hybrid := opts
+			hybrid.Rerank, hybrid.ExpandQuery = false, false
+			stages = append(stages, stage{SearchStageHybrid, hybrid})
+		}
+		stages = append(stages, stage{SearchStageFinal, opts})
+	}
+
+	updates := make(chan SearchUpdate, 1)
+	go func() {
+		defer close(updates)
+		start := time.Now()
+		for i, s := range stages {
+			if ctx.Err() != nil {
+				return
+			}
+			last := i == len(stages)-1
+			results, _, err := m.search(query, s.opts, last, false)
+			u := SearchUpdate{Stage: s.name, Results: results, Final: last || err != nil, Elapsed: time.Since(start)}
+			if err != nil {
+				u.Err = fmt.Errorf("%s search failed: %w", s.name, err)
+				u.Error = u.Err.Error()
+			}
+			select {
+			case updates <- u:
+			case <-ctx.Done():
+				return
+			}
+			if u.Final {
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// WriteSearchEvents 把增量搜索结果写成 server-sent events（event 为阶段名，失败时为 error）
+// w 实现 Flush（如 http.Flusher）时每批写完立即刷新
+func WriteSearchEvents(w io.Writer, updates <-chan SearchUpdate) error {
+	for u := range updates {
+		data, err := json.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("marshal search update: %w", err)
+		}
+		event := string(u.Stage)
+		if u.Err != nil {
+			event = "error"
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+	return nil
+}
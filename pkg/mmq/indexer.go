@@ -2,31 +2,43 @@ package mmq
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/dyike/mmq/pkg/plugin"
+	"github.com/dyike/mmq/pkg/s3"
+	"github.com/dyike/mmq/pkg/store"
 )
 
-// IndexDirectory 索引目录（批量索引）
-func (m *MMQ) IndexDirectory(path string, opts IndexOptions) error {
+// IndexDirectory 索引目录（批量索引），path 为 s3://bucket/prefix 时索引对象存储
+// 遍历、读取和哈希由多个 worker 并发完成，写入按遍历顺序串行进行
+func (m *MMQ) IndexDirectory(path string, opts IndexOptions) (*IndexSummary, error) {
+	if s3.IsURL(path) {
+		return m.indexS3(path, opts)
+	}
+
 	// 展开路径
 	absPath, err := filepath.Abs(expandPath(path))
 	if err != nil {
-		return fmt.Errorf("invalid path: %w", err)
+		return nil, fmt.Errorf("invalid path: %w", err)
 	}
 
 	// 检查路径是否存在
 	if _, err := os.Stat(absPath); err != nil {
-		return fmt.Errorf("path not found: %w", err)
+		return nil, fmt.Errorf("path not found: %w", err)
 	}
 
 	// 设置默认值
-	mask := opts.Mask
+	mask := filepath.ToSlash(opts.Mask)
 	if mask == "" {
 		mask = "**/*.md"
 	}
@@ -37,108 +49,332 @@ func (m *MMQ) IndexDirectory(path string, opts IndexOptions) error {
 		collection = filepath.Base(absPath)
 	}
 
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
 	// 确保集合存在
 	exists, _ := m.store.CollectionExists(collection)
 	if !exists {
 		if err := m.store.CreateCollection(collection, absPath, mask); err != nil {
-			return fmt.Errorf("failed to create collection: %w", err)
+			return nil, fmt.Errorf("failed to create collection: %w", err)
 		}
 	}
 
-	// 遍历目录，找到匹配的文件
-	var indexed int
+	// 集合的 PII 策略
+	piiPolicy, err := m.CollectionPIIPolicy(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PII policy: %w", err)
+	}
+
+	// 扫描器在启动 worker 前获取，worker 之间共享（只读）
+	scanner, err := m.scanner()
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有文档的哈希，用于区分新增、更新和未变化
+	existing, err := m.store.GetCollectionHashes(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing documents: %w", err)
+	}
+
+	// 新版本先写入暂存区，全部完成后一次性替换，避免搜索看到半新半旧的结果
+	generation, err := m.store.BeginReindex(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin reindex: %w", err)
+	}
+
+	summary := &IndexSummary{Collection: collection, PIIPolicy: string(piiPolicy)}
+	if summary.IndexMode, err = m.summaryIndexMode(collection); err != nil {
+		m.store.AbortReindex(collection, generation)
+		return nil, err
+	}
+
+	// 流水线：walker → workers（读取/PII/哈希）→ 按序写入
+	done := make(chan struct{})
+	jobs := make(chan indexJob)
+	results := make(chan indexResult)
+
+	var walkErr error
 	var skipped int
+	go func() {
+		defer close(jobs)
+		seq := 0
+		walkErr = filepath.WalkDir(absPath, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
 
-	err = filepath.WalkDir(absPath, func(filePath string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+			// 跳过目录
+			if d.IsDir() {
+				// 跳过隐藏目录和node_modules等
+				name := d.Name()
+				if strings.HasPrefix(name, ".") || name == "node_modules" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
-		// 跳过目录
-		if d.IsDir() {
-			// 跳过隐藏目录和node_modules等
-			name := d.Name()
-			if strings.HasPrefix(name, ".") || name == "node_modules" {
-				return filepath.SkipDir
+			// 计算相对路径，统一用 '/' 分隔（mask 匹配和存储都按 '/'）
+			relPath, err := filepath.Rel(absPath, filePath)
+			if err != nil {
+				return err
 			}
-			return nil
-		}
+			relPath = filepath.ToSlash(relPath)
 
-		// 计算相对路径
-		relPath, err := filepath.Rel(absPath, filePath)
-		if err != nil {
-			return err
+			// 检查是否匹配mask
+			matched, err := doublestar.Match(mask, relPath)
+			if err != nil || !matched {
+				skipped++
+				return nil
+			}
+
+			select {
+			case jobs <- indexJob{seq: seq, path: filePath, relPath: relPath, entry: d}:
+				seq++
+				return nil
+			case <-done:
+				return filepath.SkipAll
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res := prepareDocument(job, collection, scanner, piiPolicy, m.transformFor(job.relPath), m.extractorFor(job.path))
+				select {
+				case results <- res:
+				case <-done:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 按遍历顺序写入暂存区，保证结果稳定
+	var stageErr error
+	pending := make(map[int]indexResult)
+	next := 0
+	for res := range results {
+		if stageErr != nil {
+			continue // 已中止，排空剩余结果
+		}
+		pending[res.seq] = res
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := m.commitPrepared(generation, r, existing, summary); err != nil {
+				stageErr = err
+				close(done)
+				break
+			}
 		}
+	}
 
-		// 检查是否匹配mask
-		matched, err := doublestar.Match(mask, relPath)
-		if err != nil || !matched {
-			skipped++
-			return nil
+	summary.Skipped += skipped
+	if stageErr == nil {
+		stageErr = walkErr
+	}
+	if stageErr != nil {
+		// 中止时丢弃暂存区，集合保持原有版本
+		m.store.AbortReindex(collection, generation)
+		if errors.Is(stageErr, ErrQuotaExceeded) {
+			return summary, stageErr
 		}
+		return summary, fmt.Errorf("failed to walk directory: %w", stageErr)
+	}
+
+	stats, err := m.store.CommitReindex(collection, generation)
+	if err != nil {
+		m.store.AbortReindex(collection, generation)
+		return summary, err
+	}
+	summary.Removed = stats.Deactivated
+
+	return summary, nil
+}
+
+// indexJob 待处理的文件
+type indexJob struct {
+	seq     int
+	path    string
+	relPath string
+	entry   fs.DirEntry
+}
+
+// indexResult worker 处理后的文件
+type indexResult struct {
+	seq     int
+	doc     store.Document
+	matches []pii.Match
+	err     error
+}
 
-		// 读取文件内容
-		content, err := os.ReadFile(filePath)
+// prepareDocument 读取文件、应用 PII 策略并计算哈希（可并发调用）
+// transform 非空时读取后在内存中解码；否则 extractor 非空时由插件提取文本，再否则直接读取文件
+func prepareDocument(job indexJob, collection string, scanner *pii.Scanner, piiPolicy pii.Policy, transform *ContentTransform, extractor *plugin.Plugin) indexResult {
+	res := indexResult{seq: job.seq}
+
+	// 读取文件内容
+	var content, title string
+	if transform != nil {
+		data, err := os.ReadFile(job.path)
+		if err == nil {
+			data, err = decodeContent(transform, job.relPath, data)
+		}
 		if err != nil {
-			fmt.Printf("Warning: failed to read %s: %v\n", relPath, err)
-			skipped++
-			return nil
+			res.err = err
+			res.doc.Path = job.relPath
+			return res
 		}
-
-		// 获取文件信息
-		info, _ := d.Info()
-		modTime := time.Now()
-		if info != nil {
-			modTime = info.ModTime()
+		content = string(data)
+	} else if extractor != nil {
+		extracted, err := extractor.Extract(job.path)
+		if err != nil {
+			res.err = err
+			res.doc.Path = job.relPath
+			return res
 		}
-
-		// 提取标题（从文件名或内容）
-		title := extractTitle(string(content), relPath)
-
-		// 索引文档
-		doc := Document{
-			Collection: collection,
-			Path:       relPath,
-			Title:      title,
-			Content:    string(content),
-			CreatedAt:  modTime,
-			ModifiedAt: modTime,
+		content, title = extracted.Text, extracted.Title
+	} else {
+		data, err := os.ReadFile(job.path)
+		if err != nil {
+			res.err = err
+			res.doc.Path = job.relPath
+			return res
 		}
+		content = string(data)
+	}
 
-		if err := m.IndexDocument(doc); err != nil {
-			fmt.Printf("Warning: failed to index %s: %v\n", relPath, err)
-			skipped++
-			return nil
-		}
+	// 获取文件信息
+	info, _ := job.entry.Info()
+	modTime := time.Now()
+	if info != nil {
+		modTime = info.ModTime()
+	}
 
-		indexed++
+	prepareText(&res, collection, job.relPath, content, title, modTime, scanner, piiPolicy)
+	return res
+}
 
-		// 显示进度
-		if indexed%10 == 0 {
-			fmt.Printf("Indexed %d files...\n", indexed)
-		}
+// prepareText 对读取到的文本应用 PII 策略、提取标题并计算哈希
+func prepareText(res *indexResult, collection, relPath, content, title string, modTime time.Time, scanner *pii.Scanner, piiPolicy pii.Policy) {
+	// PII 检测（flag 仅提示，redact 脱敏后再索引）
+	text, matches := scanner.Apply(content, piiPolicy)
+	if title == "" {
+		title = extractTitle(text, relPath) // 提取标题（从文件名或内容）
+	}
 
-		return nil
-	})
+	res.matches = matches
+	res.doc = store.Document{
+		Collection: collection,
+		Path:       relPath,
+		Title:      title,
+		Hash:       hashContent(text),
+		Content:    text,
+		CreatedAt:  modTime,
+		ModifiedAt: modTime,
+	}
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to walk directory: %w", err)
+// commitPrepared 写入一个处理完的文件并更新汇总，只有需要中止时才返回错误
+// 处理失败的文件仍然存在，已索引的版本原样保留，不会在提交时被停用
+func (m *MMQ) commitPrepared(generation int64, res indexResult, existing map[string]string, summary *IndexSummary) error {
+	if res.err != nil {
+		summary.Errors = append(summary.Errors, IndexFileError{Path: res.doc.Path, Error: res.err.Error()})
+		return m.keepIndexed(generation, summary.Collection, res.doc.Path, existing)
+	}
+
+	if len(res.matches) > 0 {
+		summary.PIIFiles = append(summary.PIIFiles, IndexPIIFile{Path: res.doc.Path, Summary: pii.Summarize(res.matches)})
+	}
+
+	// 摘要模式：原文只在内存中用于生成摘要
+	if summary.IndexMode == IndexModeSummary {
+		if err := m.summarizeDocument(&res.doc, existing); err != nil {
+			summary.Errors = append(summary.Errors, IndexFileError{Path: res.doc.Path, Error: err.Error()})
+			return m.keepIndexed(generation, summary.Collection, res.doc.Path, existing)
+		}
 	}
 
-	// 更新集合时间戳
-	m.store.UpdateCollectionTimestamp(collection)
+	if err := m.stageDocument(generation, res.doc); err != nil {
+		// 超出配额时中止，避免继续写满磁盘
+		if errors.Is(err, ErrQuotaExceeded) {
+			return err
+		}
+		summary.Errors = append(summary.Errors, IndexFileError{Path: res.doc.Path, Error: err.Error()})
+		return m.keepIndexed(generation, summary.Collection, res.doc.Path, existing)
+	}
 
-	fmt.Printf("\nIndexing complete: %d files indexed, %d skipped\n", indexed, skipped)
+	switch oldHash, ok := existing[res.doc.Path]; {
+	case !ok:
+		summary.Added++
+	case oldHash != res.doc.Hash:
+		summary.Updated++
+	default:
+		summary.Unchanged++
+	}
+	return nil
+}
 
+// keepIndexed 把已索引的文档原样写入暂存区（文件处理失败时使用），未索引过的文件不处理
+func (m *MMQ) keepIndexed(generation int64, collection, path string, existing map[string]string) error {
+	if _, ok := existing[path]; !ok {
+		return nil
+	}
+	if _, err := m.store.StageUnchanged(generation, collection, path); err != nil {
+		return fmt.Errorf("failed to keep %s: %w", path, err)
+	}
 	return nil
 }
 
+// stageDocument 检查配额后将文档写入暂存区
+func (m *MMQ) stageDocument(generation int64, doc store.Document) error {
+	if err := m.checkWriteQuota(); err != nil {
+		return err
+	}
+
+	// 暂存区即集合的新版本，按暂存数量检查文档数上限
+	if m.cfg.MaxDocsPerCollection > 0 {
+		count, err := m.store.CountStagedDocuments(doc.Collection, generation)
+		if err != nil {
+			return fmt.Errorf("failed to count documents: %w", err)
+		}
+		if count >= m.cfg.MaxDocsPerCollection {
+			return &QuotaError{
+				Kind:       QuotaCollection,
+				Collection: doc.Collection,
+				Current:    int64(count),
+				Limit:      int64(m.cfg.MaxDocsPerCollection),
+			}
+		}
+	}
+
+	return m.store.StageDocument(generation, doc)
+}
+
 // IndexCollection 索引整个集合（重新索引）
-func (m *MMQ) IndexCollection(name string) error {
+func (m *MMQ) IndexCollection(name string) (*IndexSummary, error) {
 	// 获取集合信息
 	coll, err := m.store.GetCollection(name)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if coll.Path == "" {
+		return nil, fmt.Errorf("collection %s has no source path", name)
 	}
 
 	// 使用集合的路径和mask重新索引
@@ -150,17 +386,17 @@ func (m *MMQ) IndexCollection(name string) error {
 }
 
 // UpdateCollection 更新集合（可选git pull）
-func (m *MMQ) UpdateCollection(name string, pull bool) error {
+func (m *MMQ) UpdateCollection(name string, pull bool) (*IndexSummary, error) {
 	// 获取集合信息
 	coll, err := m.store.GetCollection(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 如果需要，执行git pull
-	if pull {
-		if err := gitPull(coll.Path); err != nil {
-			fmt.Printf("Warning: git pull failed: %v\n", err)
+	// 如果需要，执行git pull（对象存储集合没有仓库）
+	if pull && !s3.IsURL(coll.Path) {
+		if err := m.gitPull(coll.Path); err != nil {
+			m.logf("Warning: git pull failed: %v", err)
 			// 继续索引，不中断
 		}
 	}
@@ -202,7 +438,7 @@ func expandPath(path string) string {
 }
 
 // gitPull 执行git pull
-func gitPull(path string) error {
+func (m *MMQ) gitPull(path string) error {
 	// 检查是否是git仓库
 	gitDir := filepath.Join(path, ".git")
 	if _, err := os.Stat(gitDir); err != nil {
@@ -211,7 +447,7 @@ func gitPull(path string) error {
 
 	// TODO: 实际执行git pull命令
 	// 这里暂时跳过，因为需要exec包
-	fmt.Printf("Git pull in %s (skipped in current implementation)\n", path)
+	m.logf("Git pull in %s (skipped in current implementation)", path)
 	return nil
 }
 
@@ -0,0 +1,61 @@
+package mmq
+
+import "github.com/dyike/mmq/pkg/memory"
+
+// DefaultDuplicateThreshold 记忆去重默认的余弦相似度阈值
+const DefaultDuplicateThreshold = memory.DefaultDuplicateThreshold
+
+// FindDuplicateMemories 按嵌入相似度查找整个记忆库中的近似重复记忆，并给出合并方案
+// 只比较类型和命名空间都相同的记忆；缺少嵌入的记忆不参与比较
+func (m *MMQ) FindDuplicateMemories(opts MemoryDedupeOptions) ([]MemoryDuplicateGroup, error) {
+	groups, err := m.memoryManager.FindDuplicates(opts.Threshold, memory.MemoryType(opts.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MemoryDuplicateGroup, len(groups))
+	for i, g := range groups {
+		result[i] = MemoryDuplicateGroup{
+			Keep:       convertToMMQMemoriesFromInternal([]memory.Memory{g.Keep})[0],
+			Merge:      convertToMMQMemoriesFromInternal(g.Merge),
+			Similarity: g.Similarity,
+			Tags:       g.Tags,
+			Importance: g.Importance,
+		}
+	}
+	return result, nil
+}
+
+// MergeDuplicateMemories 应用合并方案：保留 Keep 并更新其标签和重要性，删除 Merge 中的记忆
+func (m *MMQ) MergeDuplicateMemories(group MemoryDuplicateGroup) error {
+	return m.memoryManager.MergeDuplicates(memory.DuplicateGroup{
+		Keep:       toInternalMemory(group.Keep),
+		Merge:      toInternalMemories(group.Merge),
+		Similarity: group.Similarity,
+		Tags:       group.Tags,
+		Importance: group.Importance,
+	})
+}
+
+// toInternalMemories 从 mmq.Memory 转换为 memory.Memory
+func toInternalMemories(memories []Memory) []memory.Memory {
+	result := make([]memory.Memory, len(memories))
+	for i, mem := range memories {
+		result[i] = toInternalMemory(mem)
+	}
+	return result
+}
+
+func toInternalMemory(mem Memory) memory.Memory {
+	return memory.Memory{
+		ID:          mem.ID,
+		Type:        memory.MemoryType(mem.Type),
+		Content:     mem.Content,
+		Metadata:    mem.Metadata,
+		Tags:        mem.Tags,
+		Timestamp:   mem.Timestamp,
+		ExpiresAt:   mem.ExpiresAt,
+		Importance:  mem.Importance,
+		AccessCount: mem.AccessCount,
+	}
+}
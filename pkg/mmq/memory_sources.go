@@ -0,0 +1,86 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/memory"
+)
+
+// MemorySource 记忆的来源文档
+type MemorySource struct {
+	DocID     string              `json:"docid"`
+	Documents []DocumentListEntry `json:"documents,omitempty"` // 当前内容匹配该 docid 的文档
+	Missing   bool                `json:"missing,omitempty"`   // 文档已删除或内容已变化
+}
+
+// MemorySources 返回记忆提取时对话引用的文档
+// 来源记录在元数据 source_docs 中（docid 列表），文档内容变化后 docid 不再匹配，标记为 Missing
+func (m *MMQ) MemorySources(id string) ([]MemorySource, error) {
+	mem, err := m.GetMemoryByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs := memorySourceDocIDs(mem.Metadata)
+	if len(docIDs) == 0 {
+		return nil, nil
+	}
+
+	entries, err := m.store.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	sources := make([]MemorySource, 0, len(docIDs))
+	for _, docID := range docIDs {
+		source := MemorySource{DocID: docID}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Hash, docID) {
+				source.Documents = append(source.Documents, DocumentListEntry{
+					ID:         e.ID,
+					DocID:      e.DocID,
+					Collection: e.Collection,
+					Path:       e.Path,
+					Title:      e.Title,
+					Hash:       e.Hash,
+					CreatedAt:  e.CreatedAt,
+					ModifiedAt: e.ModifiedAt,
+					Date:       e.Date,
+
+					WordCount:      e.WordCount,
+					CharCount:      e.CharCount,
+					ReadingSeconds: e.ReadingSeconds,
+				})
+			}
+		}
+		source.Missing = len(source.Documents) == 0
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// memorySourceDocIDs 读取元数据中的来源 docid（存储后 JSON 解码为 []interface{}）
+func memorySourceDocIDs(metadata map[string]interface{}) []string {
+	var ids []string
+	switch v := metadata[memory.MetadataSourceDocs].(type) {
+	case []string:
+		ids = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	case string:
+		ids = strings.Split(v, ",")
+	}
+
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimPrefix(strings.TrimSpace(id), "#"); id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
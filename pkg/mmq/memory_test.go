@@ -1,9 +1,19 @@
 package mmq
 
 import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/memory"
+	"github.com/dyike/mmq/pkg/rag"
 )
 
 func TestStoreAndRecallMemory(t *testing.T) {
@@ -418,6 +428,724 @@ func TestCountMemories(t *testing.T) {
 	t.Logf("Total memories: %d", count)
 }
 
+func TestReembedMemories(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	if err := m.StoreMemory(Memory{
+		Type:      MemoryTypeFact,
+		Content:   "Go 是静态类型语言",
+		Timestamp: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟降级模式下写入的记忆（无嵌入向量）
+	err := m.GetStore().InsertMemory(string(MemoryTypeFact), "SQLite 支持 FTS5 全文索引",
+		nil, nil, now, nil, 0.5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing, err := m.CountMemoriesMissingEmbedding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != 1 {
+		t.Fatalf("Expected 1 memory missing embedding, got %d", missing)
+	}
+
+	count, err := m.ReembedMemories(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 memory re-embedded, got %d", count)
+	}
+
+	missing, _ = m.CountMemoriesMissingEmbedding()
+	if missing != 0 {
+		t.Errorf("Expected no memories missing embedding, got %d", missing)
+	}
+
+	// 补齐后应能被召回
+	memories, err := m.RecallMemories("SQLite 支持 FTS5 全文索引", RecallOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) == 0 || memories[0].Content != "SQLite 支持 FTS5 全文索引" {
+		t.Errorf("Expected backfilled memory to be recalled first, got %+v", memories)
+	}
+
+	// --all 重新生成所有记忆
+	count, err = m.ReembedMemories(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 memories re-embedded, got %d", count)
+	}
+}
+
+func TestPerTypeDecay(t *testing.T) {
+	m := newTestMMQ(t)
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	for _, mem := range []Memory{
+		{Type: MemoryTypeFact, Content: "项目使用 SQLite 存储", Timestamp: old},
+		{Type: MemoryTypeConversation, Content: "项目使用 SQLite 存储", Timestamp: old},
+	} {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	memories, err := m.RecallMemories("项目使用 SQLite 存储", RecallOptions{
+		Limit:         10,
+		ApplyDecay:    true,
+		DecayHalflife: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) != 2 {
+		t.Fatalf("Expected 2 memories, got %d", len(memories))
+	}
+
+	// 事实默认不衰减，应排在衰减后的对话之前
+	if memories[0].Type != MemoryTypeFact {
+		t.Errorf("Expected fact first, got %s", memories[0].Type)
+	}
+	if memories[1].Relevance >= memories[0].Relevance {
+		t.Errorf("Expected decayed conversation to rank lower: %.4f >= %.4f",
+			memories[1].Relevance, memories[0].Relevance)
+	}
+
+	// 调用方按类型覆盖
+	memories, err = m.RecallMemories("项目使用 SQLite 存储", RecallOptions{
+		Limit:      10,
+		ApplyDecay: true,
+		TypeHalflives: map[MemoryType]time.Duration{
+			MemoryTypeFact:         24 * time.Hour,
+			MemoryTypeConversation: 0,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memories[0].Type != MemoryTypeConversation {
+		t.Errorf("Expected conversation first with overridden halflives, got %s", memories[0].Type)
+	}
+
+	if f := m.MemoryDecayFactor(MemoryTypeFact, 365*24*time.Hour); f != 1.0 {
+		t.Errorf("Expected facts to never decay, got factor %.4f", f)
+	}
+	if f := m.MemoryDecayFactor(MemoryTypeEpisodic, 60*24*time.Hour); math.Abs(f-0.25) > 1e-9 {
+		t.Errorf("Expected episodic memories to halve every 30 days, got factor %.4f after 60 days", f)
+	}
+}
+
+func TestSessionBoost(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	for _, sid := range []string{"session-a", "session-b"} {
+		err := m.StoreMemory(Memory{
+			Type:      MemoryTypeConversation,
+			Content:   "讨论了向量索引的构建",
+			Metadata:  map[string]interface{}{"session_id": sid},
+			Timestamp: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sid := range []string{"session-a", "session-b"} {
+		memories, err := m.RecallMemories("讨论了向量索引的构建", RecallOptions{
+			Limit:     2,
+			SessionID: sid,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(memories) != 2 {
+			t.Fatalf("Expected 2 memories, got %d", len(memories))
+		}
+		if got := memories[0].Metadata["session_id"]; got != sid {
+			t.Errorf("Expected memory from %s first, got %v", sid, got)
+		}
+		if memories[0].Relevance <= memories[1].Relevance {
+			t.Errorf("Expected boosted relevance %.4f > %.4f", memories[0].Relevance, memories[1].Relevance)
+		}
+	}
+}
+
+func TestSessionBoostFromConfig(t *testing.T) {
+	// 小于 1 的乘数让当前会话的记忆排在后面，便于和默认值区分
+	m, err := New(filepath.Join(t.TempDir(), "test.db"),
+		WithLLM(llm.Compose(generateOnly{})),
+		WithEmbedder(embedOnly{newTestLLM(300)}),
+		WithConfig(func(cfg *Config) { cfg.SessionBoost = 0.5 }),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	now := time.Now()
+	for _, sid := range []string{"session-a", "session-b"} {
+		if err := m.StoreMemory(Memory{
+			Type:      MemoryTypeConversation,
+			Content:   "讨论了向量索引的构建",
+			Metadata:  map[string]interface{}{"session_id": sid},
+			Timestamp: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 对话侧召回只传会话 ID，乘数来自配置
+	memories, err := m.GetMemoryManager().Recall("讨论了向量索引的构建", memory.RecallOptions{
+		Limit:     2,
+		SessionID: "session-a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) != 2 {
+		t.Fatalf("Expected 2 memories, got %d", len(memories))
+	}
+	if got := memories[0].Metadata["session_id"]; got != "session-b" {
+		t.Errorf("Expected the configured boost to rank session-a last, got %v first", got)
+	}
+	if ratio := memories[1].Relevance / memories[0].Relevance; ratio < 0.49 || ratio > 0.51 {
+		t.Errorf("Expected relevance ratio 0.5, got %.4f", ratio)
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	if err := m.StoreMemory(Memory{
+		Type:      MemoryTypeConversation,
+		Content:   "短期上下文",
+		Timestamp: now,
+		TTL:       TTLSession,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	memories, err := m.ListMemoriesByType(MemoryTypeConversation)
+	if err != nil || len(memories) != 1 {
+		t.Fatalf("ListMemoriesByType: %v (%d)", err, len(memories))
+	}
+	if memories[0].ExpiresAt == nil {
+		t.Fatal("Expected ExpiresAt to be set from TTL")
+	}
+	if diff := memories[0].ExpiresAt.Sub(now.Add(TTLSession)); diff > time.Second || diff < -time.Second {
+		t.Errorf("Expected expiry ~%v, got %v", now.Add(TTLSession), memories[0].ExpiresAt)
+	}
+
+	for input, want := range map[string]time.Duration{
+		"session": TTLSession,
+		"sprint":  TTLSprint,
+		"3d":      72 * time.Hour,
+		"":        0,
+	} {
+		got, err := ParseTTL(input)
+		if err != nil || got != want {
+			t.Errorf("ParseTTL(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseTTL("forever"); err == nil {
+		t.Error("Expected error for invalid TTL")
+	}
+}
+
+func TestListMemoriesSortAndPaginate(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	for i, imp := range []float64{0.2, 0.9, 0.5} {
+		if err := m.StoreMemory(Memory{
+			Type:       MemoryTypeFact,
+			Content:    fmt.Sprintf("fact %d", i),
+			Timestamp:  now.Add(time.Duration(i) * time.Minute),
+			Importance: imp,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.StoreMemory(Memory{
+		Type:      MemoryTypePreference,
+		Content:   "prefers tabs",
+		Timestamp: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	byImportance, err := m.ListMemories(MemoryListOptions{Type: MemoryTypeFact, SortBy: MemorySortImportance})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byImportance) != 3 || byImportance[0].Importance != 0.9 || byImportance[2].Importance != 0.2 {
+		t.Errorf("Unexpected importance order: %+v", byImportance)
+	}
+
+	page, err := m.ListMemories(MemoryListOptions{Type: MemoryTypeFact, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].Content != "fact 1" || page[1].Content != "fact 0" {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+
+	// 回忆会累加访问次数
+	if _, err := m.RecallMemories("prefers tabs", RecallOptions{Limit: 1, MemoryTypes: []MemoryType{MemoryTypePreference}}); err != nil {
+		t.Fatal(err)
+	}
+	byAccess, err := m.ListMemories(MemoryListOptions{SortBy: MemorySortAccess, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byAccess) != 1 || byAccess[0].Content != "prefers tabs" || byAccess[0].AccessCount != 1 {
+		t.Errorf("Expected recalled memory first with 1 access, got %+v", byAccess)
+	}
+
+	counts, err := m.CountMemoriesGroupedByType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[MemoryTypeFact] != 3 || counts[MemoryTypePreference] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+func TestListMemoriesPagingStableOnTies(t *testing.T) {
+	m := newTestMMQ(t)
+
+	// 时间戳、重要度和访问次数都相同，分页只能靠 ID 排序保持稳定
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := m.StoreMemory(Memory{
+			Type:       MemoryTypeFact,
+			Content:    fmt.Sprintf("tied fact %d", i),
+			Timestamp:  now,
+			Importance: 0.5,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sortBy := range []MemorySort{MemorySortRecency, MemorySortImportance, MemorySortAccess} {
+		seen := make(map[string]bool)
+		for offset := 0; offset < 5; offset += 2 {
+			page, err := m.ListMemories(MemoryListOptions{SortBy: sortBy, Limit: 2, Offset: offset})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mem := range page {
+				if seen[mem.ID] {
+					t.Errorf("%s: memory %s returned on more than one page", sortBy, mem.ID)
+				}
+				seen[mem.ID] = true
+			}
+		}
+		if len(seen) != 5 {
+			t.Errorf("%s: expected 5 distinct memories across pages, got %d", sortBy, len(seen))
+		}
+	}
+}
+
+func TestMemoryReview(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	for i, content := range []string{"用户喜欢Go", "用户住在北京", "用户有点累"} {
+		if err := m.StoreMemory(Memory{
+			Type:      MemoryTypeFact,
+			Content:   content,
+			Metadata:  map[string]interface{}{"source": "auto_extract"},
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 手动添加的记忆不需要审阅
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "manual", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.AutoMemoryImportance(MemoryTypeFact); got != 0.7 {
+		t.Errorf("Expected default auto importance 0.7, got %.3f", got)
+	}
+
+	pending, err := m.PendingMemoryReviews(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 3 {
+		t.Fatalf("Expected 3 pending memories, got %d", len(pending))
+	}
+
+	if err := m.ReviewMemory(pending[0], MemoryReviewDelete); err != nil {
+		t.Fatal(err)
+	}
+	pending[1].Content = "用户常住北京"
+	if err := m.ReviewMemory(pending[1], MemoryReviewEdit); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReviewMemory(pending[2], MemoryReviewKeep); err != nil {
+		t.Fatal(err)
+	}
+	// 保留也会写入审阅标记，需要记录事件供同步
+	latest, err := m.Events(EventFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].Type != EventMemoryUpdated || latest[0].MemoryID != pending[2].ID {
+		t.Errorf("Expected memory_updated event for the kept memory, got %+v", latest)
+	}
+
+	pending, err = m.PendingMemoryReviews(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending memories, got %d", len(pending))
+	}
+
+	count, _ := m.CountMemoriesByType(MemoryTypeFact)
+	if count != 3 {
+		t.Errorf("Expected 3 facts after delete, got %d", count)
+	}
+
+	// 2/3 保留：(2 + 0.7*4) / (3 + 4)
+	want := (2 + 0.7*4) / 7.0
+	if got := m.AutoMemoryImportance(MemoryTypeFact); got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("Expected auto importance %.4f, got %.4f", want, got)
+	}
+}
+
+func TestResolveMemoryID(t *testing.T) {
+	m := newTestMMQ(t)
+
+	if err := m.StoreMemory(Memory{
+		Type:      MemoryTypeFact,
+		Content:   "Go uses goroutines",
+		Timestamp: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	memories, err := m.ListMemoriesByType(MemoryTypeFact)
+	if err != nil || len(memories) != 1 {
+		t.Fatalf("ListMemoriesByType: %v (%d)", err, len(memories))
+	}
+	fullID := memories[0].ID
+
+	id, err := m.ResolveMemoryID(fullID[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != fullID {
+		t.Errorf("Expected %s, got %s", fullID, id)
+	}
+
+	if _, err := m.ResolveMemoryID("zzzzzzzz"); err == nil {
+		t.Error("Expected error for unknown prefix")
+	}
+
+	// LIKE 通配符按原样比较
+	for _, prefix := range []string{"%", "_"} {
+		if _, err := m.ResolveMemoryID(prefix); err == nil {
+			t.Errorf("Expected %q not to match as a wildcard", prefix)
+		}
+		if err := m.DeleteMemory(prefix); err == nil {
+			t.Errorf("Expected deleting %q to match nothing", prefix)
+		}
+	}
+	if n, _ := m.CountMemories(); n != 1 {
+		t.Errorf("Expected the memory to survive wildcard prefixes, got %d", n)
+	}
+}
+
+func TestUpdateMemoryReembedsOnlyChangedContent(t *testing.T) {
+	embedder := &countingEmbedder{embedOnly: embedOnly{newTestLLM(300)}}
+	m, err := New(filepath.Join(t.TempDir(), "test.db"), WithLLM(llm.Compose(generateOnly{})), WithEmbedder(embedder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "Go uses goroutines", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	memories, err := m.ListMemoriesByType(MemoryTypeFact)
+	if err != nil || len(memories) != 1 {
+		t.Fatalf("ListMemoriesByType: %v (%d)", err, len(memories))
+	}
+	id := memories[0].ID
+
+	calls := embedder.calls
+	if err := m.UpdateMemory(id, Memory{Content: "Go uses goroutines", Importance: 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	if embedder.calls != calls {
+		t.Errorf("Expected no embedding for an unchanged content update, got %d calls", embedder.calls-calls)
+	}
+	if n, _ := m.CountMemoriesMissingEmbedding(); n != 0 {
+		t.Errorf("Expected the embedding to be kept, %d memories missing one", n)
+	}
+
+	if err := m.UpdateMemory(id, Memory{Content: "Go uses goroutines and channels", Importance: 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	if embedder.calls != calls+1 {
+		t.Errorf("Expected one embedding for changed content, got %d calls", embedder.calls-calls)
+	}
+}
+
+func TestMemorySources(t *testing.T) {
+	m := newTestMMQ(t)
+
+	if err := m.IndexDocument(Document{
+		Collection: "notes",
+		Path:       "go.md",
+		Title:      "Go",
+		Content:    "Go uses goroutines for concurrency",
+		ModifiedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := m.LookupDocument("notes/go.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docID := doc.Hash[:6]
+
+	if err := m.StoreMemory(Memory{
+		Type:      MemoryTypeFact,
+		Content:   "Go uses goroutines",
+		Metadata:  map[string]interface{}{"source_docs": []string{docID, "ffffff"}},
+		Timestamp: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	memories, err := m.ListMemoriesByType(MemoryTypeFact)
+	if err != nil || len(memories) != 1 {
+		t.Fatalf("ListMemoriesByType: %v (%d)", err, len(memories))
+	}
+
+	sources, err := m.MemorySources(memories[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("Expected 2 sources, got %d", len(sources))
+	}
+	if sources[0].Missing || len(sources[0].Documents) != 1 || sources[0].Documents[0].Path != "go.md" {
+		t.Errorf("Expected source to resolve to notes/go.md, got %+v", sources[0])
+	}
+	if !sources[1].Missing {
+		t.Errorf("Expected unknown docid to be missing, got %+v", sources[1])
+	}
+}
+
+func TestRecallMemoryNamespace(t *testing.T) {
+	m := newTestMMQ(t)
+
+	for _, mem := range []Memory{
+		{Type: MemoryTypeFact, Content: "project uses Go modules", Metadata: map[string]interface{}{"namespace": "coder"}},
+		{Type: MemoryTypeFact, Content: "project uses Go modules too", Metadata: map[string]interface{}{"namespace": "writer"}},
+		{Type: MemoryTypeFact, Content: "project uses Go modules as well"},
+	} {
+		mem.Timestamp = time.Now()
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recalled, err := m.GetMemoryManager().Recall("project uses Go modules", memory.RecallOptions{Limit: 5, Namespace: "coder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 1 || recalled[0].Content != "project uses Go modules" {
+		t.Errorf("Expected only the coder memory, got %+v", recalled)
+	}
+
+	all, err := m.GetMemoryManager().Recall("project uses Go modules", memory.RecallOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected all 3 memories without a namespace filter, got %d", len(all))
+	}
+}
+
+func TestFindAndMergeDuplicateMemories(t *testing.T) {
+	m := newTestMMQ(t)
+
+	now := time.Now()
+	for _, mem := range []Memory{
+		{Type: MemoryTypeFact, Content: "user prefers dark mode", Tags: []string{"ui"}, Importance: 0.4,
+			Metadata: map[string]interface{}{"source_docs": []string{"aaa111"}}},
+		{Type: MemoryTypeFact, Content: "user prefers dark mode", Tags: []string{"auto", "ui"}, Importance: 0.9},
+		{Type: MemoryTypeFact, Content: "user prefers dark mode", Importance: 0.6},
+		{Type: MemoryTypePreference, Content: "user prefers dark mode", Importance: 0.5},
+		{Type: MemoryTypeFact, Content: "user prefers dark mode", Importance: 0.5,
+			Metadata: map[string]interface{}{"namespace": "writer"}},
+		{Type: MemoryTypeFact, Content: "deploys happen on fridays", Importance: 0.5},
+	} {
+		mem.Timestamp = now
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := m.FindDuplicateMemories(MemoryDedupeOptions{})
+	if err != nil {
+		t.Fatalf("FindDuplicateMemories failed: %v", err)
+	}
+	// 类型或命名空间不同的记忆不合并
+	if len(groups) != 1 || len(groups[0].Merge) != 2 {
+		t.Fatalf("Expected one group merging 2 facts, got %+v", groups)
+	}
+	g := groups[0]
+	if g.Keep.Importance != 0.9 || g.Importance != 0.9 {
+		t.Errorf("Expected the most important memory to be kept, got %+v", g.Keep)
+	}
+	if fmt.Sprint(g.Tags) != "[auto ui]" {
+		t.Errorf("Expected union of tags, got %v", g.Tags)
+	}
+	for _, sim := range g.Similarity {
+		if sim < DefaultDuplicateThreshold {
+			t.Errorf("Expected similarity above threshold, got %v", sim)
+		}
+	}
+
+	if groups, _ := m.FindDuplicateMemories(MemoryDedupeOptions{Type: MemoryTypePreference}); len(groups) != 0 {
+		t.Errorf("Expected no duplicates among preferences, got %d", len(groups))
+	}
+	if _, err := m.FindDuplicateMemories(MemoryDedupeOptions{Threshold: 1.5}); err == nil {
+		t.Error("Expected invalid threshold to be rejected")
+	}
+
+	if err := m.MergeDuplicateMemories(g); err != nil {
+		t.Fatalf("MergeDuplicateMemories failed: %v", err)
+	}
+	if n, _ := m.CountMemories(); n != 4 {
+		t.Errorf("Expected 4 memories after merge, got %d", n)
+	}
+	kept, err := m.GetMemoryByID(g.Keep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(kept.Tags) != "[auto ui]" || kept.Importance != 0.9 {
+		t.Errorf("Expected merged tags and importance, got %+v", kept)
+	}
+	if merged, _ := kept.Metadata["merged_from"].([]interface{}); len(merged) != 2 {
+		t.Errorf("Expected merged_from to list 2 ids, got %v", kept.Metadata["merged_from"])
+	}
+	if sources, _ := kept.Metadata["source_docs"].([]interface{}); len(sources) != 1 {
+		t.Errorf("Expected source docs to be carried over, got %v", kept.Metadata["source_docs"])
+	}
+	for _, mem := range g.Merge {
+		if _, err := m.GetMemoryByID(mem.ID); err == nil {
+			t.Errorf("Expected merged memory %s to be deleted", mem.ID)
+		}
+	}
+
+	if groups, _ := m.FindDuplicateMemories(MemoryDedupeOptions{}); len(groups) != 0 {
+		t.Errorf("Expected no duplicates after merge, got %d", len(groups))
+	}
+}
+
+func TestChatSessionRegistry(t *testing.T) {
+	m := newTestMMQ(t)
+
+	// 每次提取都返回同一条事实，并发会话只应写入一次
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"[{\"type\":\"fact\",\"content\":\"用户的名字是张三\"}]"}}]}`)
+	}))
+	defer srv.Close()
+	apiClient := &llm.APIClient{BaseURL: srv.URL, Model: "test", Client: srv.Client()}
+
+	registry := memory.NewSessionRegistry(m.GetMemoryManager(), apiClient, 50*time.Millisecond)
+	registry.SetSetup(func(s *memory.Session) { s.SetMaxMessages(4) })
+
+	const sessions = 8
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := registry.Open(fmt.Sprintf("s%d", i))
+			for turn := 0; turn < 3; turn++ {
+				s.AppendMessages(
+					llm.ChatMessage{Role: "user", Content: fmt.Sprintf("s%d question %d", i, turn)},
+					llm.ChatMessage{Role: "assistant", Content: fmt.Sprintf("s%d answer %d", i, turn)},
+				)
+			}
+			s.BuildSystemPrompt("我叫张三，你好", nil)
+			if _, err := s.Extract(memory.ConversationTurn{User: "我叫张三，你好", Assistant: "你好张三"}); err != nil {
+				t.Errorf("session s%d: extract failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if ids := registry.IDs(); len(ids) != sessions {
+		t.Fatalf("Expected %d open sessions, got %v", sessions, ids)
+	}
+
+	// 各会话只看到自己的消息，且只保留最近 4 条
+	for i := 0; i < sessions; i++ {
+		s, ok := registry.Get(fmt.Sprintf("s%d", i))
+		if !ok {
+			t.Fatalf("session s%d missing", i)
+		}
+		msgs := s.Messages()
+		if len(msgs) != 4 {
+			t.Fatalf("session s%d: expected 4 messages, got %d", i, len(msgs))
+		}
+		if want := fmt.Sprintf("s%d question 1", i); msgs[0].Content != want {
+			t.Errorf("session s%d: expected oldest message %q, got %q", i, want, msgs[0].Content)
+		}
+	}
+	if registry.Open("s0") != registry.Open("s0") {
+		t.Error("Expected Open to reuse an existing session")
+	}
+
+	facts, err := m.GetMemoryManager().GetByType(memory.MemoryTypeFact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facts) != 1 {
+		t.Errorf("Expected concurrent extraction to store the fact once, got %d", len(facts))
+	}
+	if id, _ := facts[0].Metadata["session_id"].(string); id == "" {
+		t.Error("Expected extracted memory to record its session")
+	}
+
+	// 空闲会话被回收，最近使用的保留
+	time.Sleep(80 * time.Millisecond)
+	registry.Open("s1")
+	expired := registry.Expire()
+	if len(expired) != sessions-1 {
+		t.Errorf("Expected %d idle sessions to expire, got %v", sessions-1, expired)
+	}
+	if ids := registry.IDs(); len(ids) != 1 || ids[0] != "s1" {
+		t.Errorf("Expected only s1 to remain, got %v", ids)
+	}
+	if !registry.Close("s1") || registry.Close("s1") {
+		t.Error("Expected Close to report whether the session existed")
+	}
+}
+
 func BenchmarkStoreMemory(b *testing.B) {
 	tmpDir := b.TempDir()
 	m, _ := NewWithDB(filepath.Join(tmpDir, "bench.db"))
@@ -464,3 +1192,213 @@ func BenchmarkRecallMemories(b *testing.B) {
 		m.RecallMemories("memory", opts)
 	}
 }
+
+func TestSessionPinnedDocs(t *testing.T) {
+	m := newTestMMQ(t)
+	s := memory.NewSession("pins", m.GetMemoryManager(), nil)
+
+	if !s.PinDoc("notes/a.md") || !s.PinDoc("notes/b.md") {
+		t.Fatal("expected new pins to be added")
+	}
+	if s.PinDoc("notes/a.md") {
+		t.Error("expected pinning the same document twice to be a no-op")
+	}
+	if got := s.PinnedDocs(); len(got) != 2 || got[0] != "notes/a.md" || got[1] != "notes/b.md" {
+		t.Errorf("unexpected pins: %v", got)
+	}
+	if !s.UnpinDoc("notes/a.md") || s.UnpinDoc("notes/a.md") {
+		t.Error("expected unpin to remove the document exactly once")
+	}
+	if n := s.ClearPins(); n != 1 || len(s.PinnedDocs()) != 0 {
+		t.Errorf("expected ClearPins to remove 1 pin, removed %d, left %v", n, s.PinnedDocs())
+	}
+
+	// 固定的文档不按相关度过滤，注入的内容也比检索片段长
+	long := strings.Repeat("kestrel ", 200)
+	prompt, _ := s.BuildSystemPrompt("question", []rag.Context{
+		{Text: long, Source: "notes/pinned.md", Relevance: 1, Metadata: map[string]interface{}{"pinned": true}},
+		{Text: long, Source: "notes/found.md", Relevance: 0.8},
+	})
+	pinnedAt := strings.Index(prompt, "notes/pinned.md")
+	foundAt := strings.Index(prompt, "notes/found.md")
+	if pinnedAt < 0 || foundAt < 0 {
+		t.Fatalf("expected both documents in the prompt:\n%s", prompt)
+	}
+	if pinnedLen := foundAt - pinnedAt; pinnedLen < 1000 {
+		t.Errorf("expected the pinned document to keep more than the 500-character snippet, got %d characters", pinnedLen)
+	}
+}
+
+func TestSessionInjectedDocs(t *testing.T) {
+	m := newTestMMQ(t)
+	s := memory.NewSession("inject", m.GetMemoryManager(), nil)
+
+	s.InjectDoc("notes/a.md")
+	s.InjectDoc("notes/b.md")
+	s.InjectDoc("notes/a.md")
+	if got := s.TakeInjectedDocs(); len(got) != 2 || got[0] != "notes/a.md" || got[1] != "notes/b.md" {
+		t.Errorf("unexpected injected docs: %v", got)
+	}
+	// 只注入一轮
+	if got := s.TakeInjectedDocs(); len(got) != 0 {
+		t.Errorf("expected injected docs to be consumed, got %v", got)
+	}
+}
+
+func TestExpandSystemPrompt(t *testing.T) {
+	vars := memory.PromptVars{
+		Date:        time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC),
+		Pinned:      []string{"notes/go.md"},
+		Collections: []string{"notes", "code"},
+	}
+	got := memory.ExpandPrompt("Today is {{date}}. Pinned: {{pinned}}. Search {{collections}}. Keep {{other}}.", vars)
+	want := "Today is 2026-03-05. Pinned: notes/go.md. Search notes, code. Keep {{other}}."
+	if got != want {
+		t.Errorf("ExpandPrompt = %q, want %q", got, want)
+	}
+	if got := memory.ExpandPrompt("Pinned: {{pinned}}", memory.PromptVars{}); got != "Pinned: (none)" {
+		t.Errorf("empty list = %q", got)
+	}
+
+	// 自定义说明替换默认说明，清空后恢复默认
+	m := newTestMMQ(t)
+	session := memory.NewSession("s1", m.GetMemoryManager(), nil)
+	session.SetInstructions(memory.ExpandPrompt("You answer about {{collections}}.", vars))
+	prompt, _ := session.BuildSystemPrompt("", nil)
+	if !strings.HasPrefix(prompt, "You answer about notes, code.") {
+		t.Errorf("expected custom instructions first, got %q", prompt)
+	}
+	session.SetInstructions("")
+	if prompt, _ := session.BuildSystemPrompt("", nil); strings.Contains(prompt, "You answer about") {
+		t.Errorf("expected default instructions after reset, got %q", prompt)
+	}
+}
+
+func TestSessionTopicShift(t *testing.T) {
+	m := newTestMMQ(t)
+	session := memory.NewSession("s1", m.GetMemoryManager(), nil)
+
+	// 第一条消息只记录话题
+	if shifted, err := session.DetectTopicShift("How do goroutines work?"); err != nil || shifted {
+		t.Fatalf("first message: shifted=%v err=%v", shifted, err)
+	}
+	if shifted, _ := session.DetectTopicShift("How do goroutines work?"); shifted {
+		t.Error("expected the same message to stay on topic")
+	}
+	if shifted, _ := session.DetectTopicShift("Best sourdough bread recipe"); !shifted {
+		t.Error("expected an unrelated message to shift the topic")
+	}
+
+	if fresh := session.RecordSources([]string{"aaa111", "bbb222"}); fresh != 2 {
+		t.Errorf("first sources: fresh = %d, want 2", fresh)
+	}
+	if fresh := session.RecordSources([]string{"bbb222", "ccc333"}); fresh != 1 {
+		t.Errorf("overlapping sources: fresh = %d, want 1", fresh)
+	}
+
+	// 清空历史后重新开始，关闭检测后不再判断
+	session.ClearMessages()
+	if shifted, _ := session.DetectTopicShift("Best sourdough bread recipe"); shifted {
+		t.Error("expected no shift right after clearing the session")
+	}
+	session.SetTopicShiftThreshold(0)
+	if shifted, _ := session.DetectTopicShift("How do goroutines work?"); shifted {
+		t.Error("expected detection to be disabled")
+	}
+}
+
+func TestSessionSharedEmbeddingAndPrepare(t *testing.T) {
+	m := newTestMMQ(t)
+	embedder := &countingEmbedder{embedOnly: embedOnly{newTestLLM(300)}}
+	mgr := memory.NewManager(m.store, llm.NewEmbeddingGenerator(embedder, "test-embed", 300))
+	session := memory.NewSession("s1", mgr, nil)
+
+	// 话题检测生成的嵌入供记忆召回复用
+	if _, err := session.DetectTopicShift("How do channels work?"); err != nil {
+		t.Fatal(err)
+	}
+	session.BuildSystemPrompt("How do channels work?", nil)
+	if embedder.calls != 1 {
+		t.Errorf("expected one embedding for topic detection and recall, got %d", embedder.calls)
+	}
+	if session.QueryEmbedding("How do channels work?") == nil || session.QueryEmbedding("other") != nil {
+		t.Error("expected the embedding to be cached for the same message only")
+	}
+	session.BuildSystemPrompt("What about select?", nil)
+	if embedder.calls != 2 {
+		t.Errorf("expected facts and memories to share one embedding, got %d calls", embedder.calls)
+	}
+
+	// 提前组装的对话记忆只使用一次
+	convMem := memory.NewConversationMemory(mgr)
+	store := func(user string) {
+		if err := convMem.StoreTurn(memory.ConversationTurn{User: user, Assistant: "ok", SessionID: "s1", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store("first question")
+	session.Prepare()
+	if prompt, _ := session.BuildSystemPrompt("", nil); !strings.Contains(prompt, "first question") {
+		t.Errorf("expected the prepared history, got %q", prompt)
+	}
+	store("second question")
+	if prompt, _ := session.BuildSystemPrompt("", nil); !strings.Contains(prompt, "second question") {
+		t.Errorf("expected fresh history after the prepared one was used, got %q", prompt)
+	}
+}
+
+func TestSessionTranscript(t *testing.T) {
+	m := newTestMMQ(t)
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "go.md", Title: "Go", Content: "goroutines and channels"}); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := m.GetDocumentByPath("notes/go.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convMem := memory.NewConversationMemory(m.GetMemoryManager())
+	start := time.Now().Add(-time.Hour)
+	for i, turn := range []memory.ConversationTurn{
+		{User: "How do goroutines talk?", Assistant: "Channels.", Sources: []string{doc.DocID, "ffffff"}},
+		{User: "And select?", Assistant: "It waits on several channels.", Sources: []string{doc.DocID}},
+	} {
+		turn.SessionID = "s1"
+		turn.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := convMem.StoreTurn(turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := convMem.StoreTurn(memory.ConversationTurn{User: "other", Assistant: "session", SessionID: "s2", Timestamp: start}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "User writes Go", Timestamp: start.Add(2 * time.Minute),
+		Metadata: map[string]interface{}{"session_id": "s1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := m.SessionTranscript("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Turns) != 2 || tr.Turns[0].User != "How do goroutines talk?" || tr.Turns[1].Assistant != "It waits on several channels." {
+		t.Fatalf("unexpected turns: %+v", tr.Turns)
+	}
+	// 同一文档在两轮中引用同一个编号，找不到的 docid 标记为 Missing
+	if len(tr.Citations) != 2 || tr.Citations[0].Path != "go.md" || !tr.Citations[1].Missing {
+		t.Errorf("unexpected citations: %+v", tr.Citations)
+	}
+	if got := tr.Turns[1].Citations; len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected the second turn to cite [1], got %v", got)
+	}
+	if len(tr.Memories) != 1 || tr.Memories[0].Content != "User writes Go" {
+		t.Errorf("expected the extracted memory, got %+v", tr.Memories)
+	}
+	if !tr.Start.Before(tr.End) {
+		t.Errorf("expected start before end, got %v and %v", tr.Start, tr.End)
+	}
+
+	if _, err := m.SessionTranscript("missing"); err == nil {
+		t.Error("expected an error for an unknown session")
+	}
+}
@@ -3,12 +3,19 @@ package mmq
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dyike/mmq/pkg/lang"
 	"github.com/dyike/mmq/pkg/llm"
 	"github.com/dyike/mmq/pkg/memory"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/dyike/mmq/pkg/plugin"
 	"github.com/dyike/mmq/pkg/rag"
 	"github.com/dyike/mmq/pkg/store"
 )
@@ -16,15 +23,62 @@ import (
 // MMQ 核心实例
 type MMQ struct {
 	store         *store.Store
+	contexts      store.ContextStore // 上下文管理只经过此接口，默认即 store
 	llm           llm.LLM
 	embedding     *llm.EmbeddingGenerator
 	retriever     *rag.Retriever
 	memoryManager *memory.Manager
+	piiScanner    *pii.Scanner
 	cfg           Config
+
+	// 集合专用嵌入模型，按需加载
+	newModelLLM func(model string) (llm.LLM, error)
+	embedMu     sync.Mutex
+	modelLLMs   map[string]llm.LLM
+	embedders   map[string]*llm.EmbeddingGenerator
+
+	// 护栏钩子
+	hooksMu    sync.RWMutex
+	hooks      map[HookStage][]namedHook
+	guardJudge func(prompt string) (string, error)
+	transforms []ContentTransform
+
+	plugins []plugin.Plugin
+
+	// logger 为空时进度和警告直接打印到标准输出
+	logger *slog.Logger
+
+	// 远端集合所在的数据库，按路径缓存
+	remotesMu sync.Mutex
+	remotes   map[string]*remoteIndex
+
+	// 文档事件回调，docCursor 为已分发的最后一个事件 ID
+	docEventsMu      sync.Mutex
+	docEventsPending atomic.Bool
+	hasDocListeners  atomic.Bool
+	docIndexed       []func(Event)
+	docRemoved       []func(Event)
+	docCursor        int64
+	docCursorSet     bool
+}
+
+// New 使用默认配置和数据库路径创建MMQ实例，用 Option 定制
+//
+//	m, err := mmq.New("mmq.db", mmq.WithEmbedder(remote), mmq.WithChunking(2000, 300))
+func New(dbPath string, opts ...Option) (*MMQ, error) {
+	cfg := DefaultConfig()
+	cfg.DBPath = dbPath
+	return NewFromConfig(cfg, opts...)
 }
 
-// New 创建新的MMQ实例
-func New(cfg Config) (*MMQ, error) {
+// NewFromConfig 按完整配置创建MMQ实例（CLI 使用），Option 在配置之后应用
+func NewFromConfig(cfg Config, opts ...Option) (*MMQ, error) {
+	o := &options{cfg: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg = o.cfg
+
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
@@ -35,6 +89,10 @@ func New(cfg Config) (*MMQ, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %w", err)
 	}
+	if err := setupLLMCache(st, cfg); err != nil {
+		st.Close()
+		return nil, err
+	}
 
 	// 初始化LLM
 	// 使用工厂方法创建LLM实例
@@ -42,53 +100,189 @@ func New(cfg Config) (*MMQ, error) {
 	modelCfg.Threads = cfg.Threads
 	modelCfg.Timeout = cfg.InactivityTimeout
 	modelCfg.CacheDir = cfg.CacheDir
-	modelCfg.LibPath = os.Getenv("YZMA_LIB")
-
-	llmImpl, err := llm.NewLLM(modelCfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create LLM: %w", err)
+	modelCfg.LibPath = cfg.LibPath
+	if env := os.Getenv("YZMA_LIB"); env != "" {
+		modelCfg.LibPath = env
 	}
+	modelCfg.Pins = cfg.ModelPins
+
+	llmImpl := o.llm
+	if llmImpl == nil {
+		llmImpl, err = llm.NewLLM(modelCfg)
+		if err != nil {
+			st.Close()
+			return nil, fmt.Errorf("failed to create LLM: %w", err)
+		}
 
-	// 设置模型路径
-	// 注意：这里假设模型文件名在Config中或者是相对于CacheDir的路径
-	// 如果是完整路径，则直接使用；否则拼接CacheDir
-	setPath := func(t llm.ModelType, info string) {
-		path := info
-		if !filepath.IsAbs(path) && cfg.CacheDir != "" {
-			path = filepath.Join(cfg.CacheDir, path)
+		// 设置模型路径
+		// 注意：这里假设模型文件名在Config中或者是相对于CacheDir的路径
+		// 如果是完整路径，则直接使用；否则拼接CacheDir
+		setPath := func(t llm.ModelType, info string) {
+			llmImpl.SetModelPath(t, modelPath(cfg.CacheDir, info))
 		}
-		llmImpl.SetModelPath(t, path)
-	}
 
-	setPath(llm.ModelTypeEmbedding, cfg.EmbeddingModel)
-	setPath(llm.ModelTypeRerank, cfg.RerankModel)
-	setPath(llm.ModelTypeGenerate, cfg.GenerateModel)
+		setPath(llm.ModelTypeEmbedding, cfg.EmbeddingModel)
+		setPath(llm.ModelTypeRerank, cfg.RerankModel)
+		setPath(llm.ModelTypeGenerate, cfg.GenerateModel)
+	}
+	if o.embedder == nil && cfg.EmbeddingAPIURL != "" {
+		// 配置了远程嵌入接口，向量按远程模型名记录
+		o.embedder = llm.NewRemoteEmbedder(cfg.EmbeddingAPIURL, cfg.EmbeddingAPIKey, cfg.EmbeddingAPIModel)
+		if cfg.EmbeddingAPIModel != "" {
+			cfg.EmbeddingModel = cfg.EmbeddingAPIModel
+		}
+	}
+	if o.embedder != nil {
+		// 嵌入交给指定的嵌入器，其余能力仍由 LLM 后端提供
+		llmImpl = llm.Compose(llmImpl, o.embedder)
+	}
 
 	// 创建嵌入生成器
-	// 维度设为 0，由 EmbeddingGenerator 自动适配实际模型维度
-	embeddingGen := llm.NewEmbeddingGenerator(llmImpl, cfg.EmbeddingModel, 0)
+	embeddingGen := newEmbeddingGenerator(llmImpl, cfg.EmbeddingModel)
 
 	// 创建RAG检索器
 	retriever := rag.NewRetriever(st, llmImpl, embeddingGen)
 
 	// 创建记忆管理器
 	memoryMgr := memory.NewManager(st, embeddingGen)
+	memoryMgr.SetDecayHalflives(convertHalflives(cfg.DecayHalflives))
+	memoryMgr.SetSessionBoost(cfg.SessionBoost)
+
+	// PII 扫描器（正则已在 Validate 中校验）
+	piiScanner, err := pii.NewScanner(cfg.PIIPatterns)
+	if err != nil {
+		return nil, err
+	}
+	memoryMgr.SetPIIPolicy(piiScanner, pii.Policy(cfg.MemoryPIIPolicy))
 
-	return &MMQ{
+	m := &MMQ{
 		store:         st,
+		contexts:      st,
 		llm:           llmImpl,
 		embedding:     embeddingGen,
 		retriever:     retriever,
 		memoryManager: memoryMgr,
+		piiScanner:    piiScanner,
 		cfg:           cfg,
-	}, nil
+		logger:        o.logger,
+	}
+
+	st.SetActor(cfg.Actor)
+	st.SetCaseInsensitivePaths(cfg.CaseInsensitivePaths)
+	st.SetVectorPrefilter(m.vectorPrefilter())
+
+	// 集合专用嵌入模型各自使用独立的 LLM 实例
+	m.newModelLLM = func(model string) (llm.LLM, error) {
+		impl, err := llm.NewLLM(modelCfg)
+		if err != nil {
+			return nil, err
+		}
+		impl.SetModelPath(llm.ModelTypeEmbedding, modelPath(cfg.CacheDir, model))
+		return impl, nil
+	}
+	retriever.SetEmbedderResolver(m.embedderFor)
+	retriever.SetLogger(o.logger)
+	retriever.SetLegParallelism(cfg.RetrievalParallelism)
+	m.setupRerankCache(retriever)
+	if err := m.applyRerankBlend(); err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	if err := m.setupGuardrails(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	if err := m.setupTransforms(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	if err := m.loadPlugins(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	if err := m.loadPipelines(); err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// vectorPrefilter 配置的向量检索候选预筛选
+func (m *MMQ) vectorPrefilter() store.VectorPrefilter {
+	return store.VectorPrefilter{
+		MinVectors:    m.cfg.VectorPrefilter,
+		MaxCandidates: m.cfg.VectorPrefilterCandidates,
+		Lexical:       m.cfg.VectorPrefilterLexical,
+	}
+}
+
+// setupLLMCache 按配置切换 LLM 缓存后端
+func setupLLMCache(st *store.Store, cfg Config) error {
+	var cache store.LLMCache
+	switch cfg.LLMCacheBackend {
+	case LLMCacheFile:
+		c, err := store.NewSQLiteCache(cfg.LLMCachePath, cfg.LLMCacheMaxEntries)
+		if err != nil {
+			return err
+		}
+		cache = c
+	case LLMCacheMemory:
+		cache = store.NewMemoryCache(cfg.LLMCacheMaxEntries)
+	default:
+		if cfg.LLMCacheMaxEntries > 0 {
+			cache = store.NewDBCache(st, cfg.LLMCacheMaxEntries)
+		}
+	}
+	if cache == nil {
+		return nil
+	}
+
+	// 缓存移出主库时清掉遗留条目，避免继续占用主库空间
+	if cfg.LLMCacheBackend != LLMCacheDB {
+		if err := st.ClearCache(); err != nil {
+			return err
+		}
+	}
+	return st.SetLLMCache(cache)
 }
 
-// NewWithDB 使用指定数据库路径快速初始化
+// modelPath 模型文件路径，相对路径以 CacheDir 为基准
+func modelPath(cacheDir, name string) string {
+	if !filepath.IsAbs(name) && cacheDir != "" {
+		return filepath.Join(cacheDir, name)
+	}
+	return name
+}
+
+// newEmbeddingGenerator 创建嵌入生成器，按模型决定是否使用查询指令
+// 维度设为 0，由 EmbeddingGenerator 自动适配实际模型维度
+func newEmbeddingGenerator(impl llm.LLM, model string) *llm.EmbeddingGenerator {
+	gen := llm.NewEmbeddingGenerator(impl, model, 0)
+	if modelUsesInstruction(model) {
+		// 按查询语言选择检索指令
+		gen.SetQueryInstruction(func(query string) string {
+			return lang.QueryInstruction(lang.Detect(query))
+		})
+	}
+	return gen
+}
+
+// modelUsesInstruction 嵌入模型是否要求查询带指令前缀（Qwen3-Embedding、GTE-Qwen、E5-Mistral 等）
+func modelUsesInstruction(model string) bool {
+	model = strings.ToLower(model)
+	for _, name := range []string{"qwen3-embedding", "gte-qwen", "e5-mistral"} {
+		if strings.Contains(model, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWithDB 使用指定数据库路径快速初始化（等同于不带 Option 的 New）
 func NewWithDB(dbPath string) (*MMQ, error) {
-	cfg := DefaultConfig()
-	cfg.DBPath = dbPath
-	return New(cfg)
+	return New(dbPath)
 }
 
 // Close 关闭MMQ实例
@@ -99,6 +293,10 @@ func (m *MMQ) Close() error {
 			return fmt.Errorf("failed to close LLM: %w", err)
 		}
 	}
+	if err := m.closeModelLLMs(); err != nil {
+		return err
+	}
+	m.closeRemotes()
 
 	// 关闭store
 	if m.store != nil {
@@ -114,13 +312,26 @@ func (m *MMQ) Status() (Status, error) {
 		return Status{}, err
 	}
 
+	version, err := m.store.CorpusVersion()
+	if err != nil {
+		return Status{}, err
+	}
+
 	status := Status{
+		CorpusVersion:  version,
 		TotalDocuments: storeStatus.TotalDocuments,
 		NeedsEmbedding: storeStatus.NeedsEmbedding,
 		Collections:    storeStatus.Collections,
 		DBPath:         storeStatus.DBPath,
 		CacheDir:       m.cfg.CacheDir,
 	}
+
+	quota, err := m.Quota()
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	status.Quota = quota
+
 	return status, nil
 }
 
@@ -128,24 +339,44 @@ func (m *MMQ) Status() (Status, error) {
 
 // RetrieveContext 检索相关上下文
 func (m *MMQ) RetrieveContext(query string, opts RetrieveOptions) ([]Context, error) {
-	// 转换为rag.RetrieveOptions
-	ragOpts := rag.RetrieveOptions{
-		Limit:       opts.Limit,
-		MinScore:    opts.MinScore,
-		Collection:  opts.Collection,
-		Strategy:    rag.RetrievalStrategy(opts.Strategy),
-		Rerank:      opts.Rerank,
-		ExpandQuery: opts.ExpandQuery,
+	var err error
+	limit := opts.Limit
+	if opts.Cluster > 0 {
+		if opts.Collection == "" {
+			return nil, fmt.Errorf("cluster filter requires a collection")
+		}
+		opts.Limit = clusterCandidates(normalizeSearchLimit(limit))
+	}
+	if opts.CorpusVersion, err = m.resolveCorpusVersion(opts.CorpusVersion); err != nil {
+		return nil, err
+	}
+
+	// 转换为rag.RetrieveOptions（两种选项字段相同）
+	ragOpts, err := m.ragOptions(SearchOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	query, err = m.preRetrieval(query)
+	if err != nil {
+		return nil, err
 	}
 
 	// 调用retriever
-	ragContexts, err := m.retriever.Retrieve(query, ragOpts)
+	ragContexts, _, err := m.retrieve(query, ragOpts, false)
 	if err != nil {
 		return nil, err
 	}
 
 	// 转换类型
-	return convertRagContexts(ragContexts), nil
+	contexts := convertRagContexts(ragContexts)
+	if opts.Cluster > 0 {
+		contexts, err = m.filterContextsByCluster(contexts, opts.Collection, opts.Cluster, normalizeSearchLimit(limit))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m.trimContexts(query, contexts), nil
 }
 
 // convertRagContexts 转换rag.Context到mmq.Context
@@ -162,28 +393,172 @@ func convertRagContexts(ragContexts []rag.Context) []Context {
 	return contexts
 }
 
-// Search BM25全文搜索（对标QMD的search）
+// Search BM25全文搜索（对标QMD的search）；需要上下文、记忆或分块粒度时用 Query
 func (m *MMQ) Search(query string, opts SearchOptions) ([]SearchResult, error) {
-	strategy := opts.Strategy
-	if strategy == "" {
-		strategy = StrategyFTS
+	results, _, err := m.search(query, opts, true, false)
+	return results, err
+}
+
+// search 执行搜索；record 为 false 时不写入查询历史（如边输入边搜索的中间查询），
+// withTimings 为 true 时同时返回各阶段耗时
+func (m *MMQ) search(query string, opts SearchOptions, record, withTimings bool) ([]SearchResult, *rag.Timings, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyFTS
+	}
+	opts.Limit = normalizeSearchLimit(opts.Limit)
+	limit := opts.Limit
+	if opts.Cluster > 0 {
+		if opts.Collection == "" {
+			return nil, nil, fmt.Errorf("cluster filter requires a collection")
+		}
+		opts.Limit = clusterCandidates(limit)
+	}
+	version, err := m.resolveCorpusVersion(opts.CorpusVersion)
+	if err != nil {
+		return nil, nil, err
 	}
+	opts.CorpusVersion = version
 
-	ragOpts := rag.RetrieveOptions{
-		Limit:       normalizeSearchLimit(opts.Limit),
+	ragOpts, err := m.ragOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query, err = m.preRetrieval(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contexts, timings, err := m.retrieve(query, ragOpts, withTimings)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record {
+		m.store.RecordQuery(query) // 供 Suggest 补全，失败不影响搜索
+	}
+
+	results, err := m.postProcess(query, m.boostAccessed(convertContextsToSearchResults(contexts)))
+	if err != nil {
+		return nil, nil, err
+	}
+	stampCorpusVersion(results, version)
+	if opts.Cluster > 0 {
+		if results, err = m.filterByCluster(results, opts.Collection, opts.Cluster, limit); err != nil {
+			return nil, nil, err
+		}
+	}
+	return results, timings, nil
+}
+
+// preRetrieval 执行 pre_retrieval 钩子，返回（可能被改写的）查询
+func (m *MMQ) preRetrieval(query string) (string, error) {
+	if !m.HasHooks(HookPreRetrieval) {
+		return query, nil
+	}
+	payload := &HookPayload{Query: query}
+	if err := m.RunHooks(HookPreRetrieval, payload); err != nil {
+		return "", err
+	}
+	return payload.Query, nil
+}
+
+// HybridSearch 混合搜索（BM25 + 向量，RRF 融合），按 opts 决定是否重排和查询扩展；统一入口见 Query
+func (m *MMQ) HybridSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	opts.Strategy = StrategyHybrid
+	return m.Search(query, opts)
+}
+
+// ragOptions 转换为 rag.RetrieveOptions，未指定的调优参数使用配置
+func (m *MMQ) ragOptions(opts SearchOptions) (rag.RetrieveOptions, error) {
+	normalize, err := m.scoreNormalization(opts.Normalize)
+	if err != nil {
+		return rag.RetrieveOptions{}, err
+	}
+
+	if n := len(opts.RRFWeights); n != 0 && n != 2 {
+		return rag.RetrieveOptions{}, fmt.Errorf("RRFWeights needs 2 values (fts, vector), got %d", n)
+	}
+	for _, w := range opts.RRFWeights {
+		if w < 0 {
+			return rag.RetrieveOptions{}, fmt.Errorf("RRFWeights must not be negative")
+		}
+	}
+	if opts.RRFK < 0 {
+		return rag.RetrieveOptions{}, fmt.Errorf("RRFK must not be negative")
+	}
+	if opts.Timeout < 0 {
+		return rag.RetrieveOptions{}, fmt.Errorf("Timeout must not be negative")
+	}
+
+	var postFilter func(rag.Context) bool
+	if opts.PostFilter != nil {
+		postFilter = func(c rag.Context) bool {
+			return opts.PostFilter(Context{Text: c.Text, Source: c.Source, Relevance: c.Relevance, Metadata: c.Metadata})
+		}
+	}
+	if opts.CorpusVersion > 0 {
+		if postFilter, err = m.pinCorpusVersion(opts.CorpusVersion, postFilter); err != nil {
+			return rag.RetrieveOptions{}, err
+		}
+	}
+	multiplier := m.candidateMultiplier(opts.CandidateMultiplier)
+	if opts.Within != "" {
+		if postFilter, err = m.withinResultSet(opts.Within, postFilter); err != nil {
+			return rag.RetrieveOptions{}, err
+		}
+		multiplier = max(multiplier, withinCandidateMultiplier)
+	}
+	strategy, err := m.resolveStrategy(opts.Strategy, opts.Collection)
+	if err != nil {
+		return rag.RetrieveOptions{}, err
+	}
+
+	return rag.RetrieveOptions{
+		Limit:       opts.Limit,
 		MinScore:    opts.MinScore,
 		Collection:  opts.Collection,
+		Language:    opts.Language,
 		Strategy:    rag.RetrievalStrategy(strategy),
 		Rerank:      opts.Rerank,
 		ExpandQuery: opts.ExpandQuery,
+		RRFWeights:  opts.RRFWeights,
+		RRFK:        opts.RRFK,
+
+		CandidateMultiplier: multiplier,
+		RerankLimit:         m.rerankLimit(opts.RerankLimit),
+		Normalize:           normalize,
+		Timeout:             opts.Timeout,
+		Context:             opts.Context,
+		After:               opts.After,
+		Before:              opts.Before,
+		Pipeline:            opts.Pipeline,
+		Instruction:         opts.Instruction,
+		PostFilter:          postFilter,
+	}, nil
+}
+
+// candidateMultiplier 未指定时使用配置的候选倍数
+func (m *MMQ) candidateMultiplier(v float64) float64 {
+	if v > 0 {
+		return v
 	}
+	return m.cfg.CandidateMultiplier
+}
 
-	contexts, err := m.retriever.Retrieve(query, ragOpts)
-	if err != nil {
-		return nil, err
+// rerankLimit 未指定时使用配置的重排上限
+func (m *MMQ) rerankLimit(v int) int {
+	if v > 0 {
+		return v
 	}
+	return m.cfg.RerankLimit
+}
 
-	return convertContextsToSearchResults(contexts), nil
+// scoreNormalization 未指定时使用配置的归一化方式
+func (m *MMQ) scoreNormalization(v string) (rag.ScoreNormalization, error) {
+	if v == "" {
+		v = m.cfg.ScoreNormalization
+	}
+	return rag.ParseScoreNormalization(v)
 }
 
 func normalizeSearchLimit(limit int) int {
@@ -197,6 +572,7 @@ func convertContextsToSearchResults(contexts []rag.Context) []SearchResult {
 	results := make([]SearchResult, len(contexts))
 	for i, ctx := range contexts {
 		results[i] = SearchResult{
+			DocID:      shortDocID(getMetadataString(ctx.Metadata, "hash")),
 			Score:      ctx.Relevance,
 			Title:      getMetadataString(ctx.Metadata, "title"),
 			Content:    ctx.Text,
@@ -204,6 +580,8 @@ func convertContextsToSearchResults(contexts []rag.Context) []SearchResult {
 			Source:     getMetadataString(ctx.Metadata, "source"),
 			Collection: getMetadataString(ctx.Metadata, "collection"),
 			Path:       getMetadataString(ctx.Metadata, "path"),
+			Language:   getMetadataString(ctx.Metadata, "language"),
+			Date:       getMetadataString(ctx.Metadata, "date"),
 			Timestamp:  getMetadataTime(ctx.Metadata, "timestamp"),
 		}
 	}
@@ -211,6 +589,14 @@ func convertContextsToSearchResults(contexts []rag.Context) []SearchResult {
 	return results
 }
 
+// shortDocID 由内容哈希生成短docid（#前6位）
+func shortDocID(hash string) string {
+	if len(hash) < 6 {
+		return ""
+	}
+	return "#" + hash[:6]
+}
+
 // getMetadataString 从元数据中获取字符串值
 func getMetadataString(metadata map[string]interface{}, key string) string {
 	if val, ok := metadata[key]; ok {
@@ -234,6 +620,10 @@ func getMetadataTime(metadata map[string]interface{}, key string) time.Time {
 
 // StoreMemory 存储记忆
 func (m *MMQ) StoreMemory(mem Memory) error {
+	if err := m.checkWriteQuota(); err != nil {
+		return err
+	}
+
 	memoryMem := memory.Memory{
 		ID:         mem.ID,
 		Type:       memory.MemoryType(mem.Type),
@@ -241,7 +631,7 @@ func (m *MMQ) StoreMemory(mem Memory) error {
 		Metadata:   mem.Metadata,
 		Tags:       mem.Tags,
 		Timestamp:  mem.Timestamp,
-		ExpiresAt:  mem.ExpiresAt,
+		ExpiresAt:  mem.expiresAt(),
 		Importance: mem.Importance,
 	}
 
@@ -255,8 +645,11 @@ func (m *MMQ) RecallMemories(query string, opts RecallOptions) ([]Memory, error)
 		MemoryTypes:        convertMemoryTypes(opts.MemoryTypes),
 		ApplyDecay:         opts.ApplyDecay,
 		DecayHalflife:      opts.DecayHalflife,
+		TypeHalflives:      convertHalflives(opts.TypeHalflives),
 		WeightByImportance: opts.WeightByImportance,
 		MinRelevance:       opts.MinRelevance,
+		SessionID:          opts.SessionID,
+		SessionBoost:       opts.SessionBoost,
 	}
 
 	memories, err := m.memoryManager.Recall(query, memOpts)
@@ -279,10 +672,19 @@ func (m *MMQ) UpdateMemory(id string, mem Memory) error {
 		ExpiresAt:  mem.ExpiresAt,
 		Importance: mem.Importance,
 	}
+	if mem.ExpiresAt == nil && mem.TTL > 0 {
+		// 更新时 TTL 从当前时间起算
+		memoryMem.ExpiresAt = ExpiresIn(mem.TTL)
+	}
 
 	return m.memoryManager.Update(id, memoryMem)
 }
 
+// ResolveMemoryID 将ID前缀（如列表中显示的前8位）解析为完整记忆ID
+func (m *MMQ) ResolveMemoryID(id string) (string, error) {
+	return m.store.ResolveMemoryID(id)
+}
+
 // DeleteMemory 删除记忆
 func (m *MMQ) DeleteMemory(id string) error {
 	return m.memoryManager.Delete(id)
@@ -322,6 +724,17 @@ func (m *MMQ) CountMemoriesByType(memType MemoryType) (int, error) {
 	return m.memoryManager.CountByType(memory.MemoryType(memType))
 }
 
+// CountMemoriesMissingEmbedding 统计缺少嵌入向量的记忆数量
+func (m *MMQ) CountMemoriesMissingEmbedding() (int, error) {
+	return m.memoryManager.CountMissingEmbeddings()
+}
+
+// ReembedMemories 为缺少嵌入向量的记忆补齐嵌入，返回处理的记忆数量
+// all 为 true 时重新生成所有记忆的嵌入
+func (m *MMQ) ReembedMemories(all bool) (int, error) {
+	return m.memoryManager.Reembed(all)
+}
+
 // ListMemoriesByType 按类型列出所有记忆
 func (m *MMQ) ListMemoriesByType(memType MemoryType) ([]Memory, error) {
 	memories, err := m.memoryManager.GetByType(memory.MemoryType(memType))
@@ -331,24 +744,109 @@ func (m *MMQ) ListMemoriesByType(memType MemoryType) ([]Memory, error) {
 	return convertToMMQMemoriesFromInternal(memories), nil
 }
 
+// ListMemories 按排序方式分页列出记忆
+func (m *MMQ) ListMemories(opts MemoryListOptions) ([]Memory, error) {
+	memories, err := m.memoryManager.List(memory.ListOptions{
+		Type:   memory.MemoryType(opts.Type),
+		SortBy: string(opts.SortBy),
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertToMMQMemoriesFromInternal(memories), nil
+}
+
+// CountMemoriesGroupedByType 统计每种类型的记忆数量
+func (m *MMQ) CountMemoriesGroupedByType() (map[MemoryType]int, error) {
+	counts, err := m.memoryManager.CountGroupedByType()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[MemoryType]int, len(counts))
+	for t, c := range counts {
+		result[MemoryType(t)] = c
+	}
+	return result, nil
+}
+
+// PendingMemoryReviews 获取尚未审阅的自动提取记忆（最新的在前）
+func (m *MMQ) PendingMemoryReviews(limit int) ([]Memory, error) {
+	memories, err := m.memoryManager.PendingReview(limit)
+	if err != nil {
+		return nil, err
+	}
+	return convertToMMQMemoriesFromInternal(memories), nil
+}
+
+// ReviewMemory 应用对自动提取记忆的审阅结果
+// 反馈会影响之后自动提取记忆的重要性；edit 时使用 mem.Content 作为新内容
+func (m *MMQ) ReviewMemory(mem Memory, action MemoryReviewAction) error {
+	return m.memoryManager.Review(memory.Memory{
+		ID:         mem.ID,
+		Type:       memory.MemoryType(mem.Type),
+		Content:    mem.Content,
+		Metadata:   mem.Metadata,
+		Tags:       mem.Tags,
+		Timestamp:  mem.Timestamp,
+		ExpiresAt:  mem.ExpiresAt,
+		Importance: mem.Importance,
+	}, memory.ReviewAction(action))
+}
+
+// AutoMemoryImportance 返回当前自动提取的指定类型记忆将使用的重要性
+func (m *MMQ) AutoMemoryImportance(memType MemoryType) float64 {
+	return m.memoryManager.AutoImportance(memory.MemoryType(memType))
+}
+
 // convertToMMQMemoriesFromInternal 从 memory.Memory 转换为 mmq.Memory
 func convertToMMQMemoriesFromInternal(memories []memory.Memory) []Memory {
 	result := make([]Memory, len(memories))
 	for i, mem := range memories {
 		result[i] = Memory{
-			ID:         mem.ID,
-			Type:       MemoryType(mem.Type),
-			Content:    mem.Content,
-			Metadata:   mem.Metadata,
-			Tags:       mem.Tags,
-			Timestamp:  mem.Timestamp,
-			ExpiresAt:  mem.ExpiresAt,
-			Importance: mem.Importance,
+			ID:          mem.ID,
+			Type:        MemoryType(mem.Type),
+			Content:     mem.Content,
+			Metadata:    mem.Metadata,
+			Tags:        mem.Tags,
+			Timestamp:   mem.Timestamp,
+			ExpiresAt:   mem.ExpiresAt,
+			Importance:  mem.Importance,
+			AccessCount: mem.AccessCount,
 		}
 	}
 	return result
 }
 
+// MemoryDecayHalflives 返回当前生效的各记忆类型衰减半衰期
+func (m *MMQ) MemoryDecayHalflives() map[MemoryType]time.Duration {
+	result := make(map[MemoryType]time.Duration)
+	for t, h := range m.memoryManager.DecayHalflives() {
+		result[MemoryType(t)] = h
+	}
+	return result
+}
+
+// MemoryDecayFactor 计算指定类型的记忆在给定年龄下的衰减系数
+func (m *MMQ) MemoryDecayFactor(memType MemoryType, age time.Duration) float64 {
+	return memory.DecayFactor(age, m.memoryManager.DecayHalflives()[memory.MemoryType(memType)])
+}
+
+// convertHalflives 转换按类型的半衰期配置
+func convertHalflives(halflives map[MemoryType]time.Duration) map[memory.MemoryType]time.Duration {
+	if halflives == nil {
+		return nil
+	}
+
+	result := make(map[memory.MemoryType]time.Duration, len(halflives))
+	for t, h := range halflives {
+		result[memory.MemoryType(t)] = h
+	}
+	return result
+}
+
 // convertMemoryTypes 转换记忆类型
 func convertMemoryTypes(types []MemoryType) []memory.MemoryType {
 	if types == nil {
@@ -375,6 +873,7 @@ func convertToMMQMemories(memories []memory.Memory) []Memory {
 			Timestamp:  mem.Timestamp,
 			ExpiresAt:  mem.ExpiresAt,
 			Importance: mem.Importance,
+			Relevance:  mem.Relevance,
 		}
 	}
 	return mmqMemories
@@ -390,15 +889,53 @@ func (m *MMQ) CreateCollection(name, path string, opts CollectionOptions) error
 		mask = "**/*.md" // 默认索引markdown文件
 	}
 
+	if _, err := pii.ParsePolicy(opts.PIIPolicy); err != nil {
+		return err
+	}
+	if _, err := ParseIndexMode(opts.IndexMode); err != nil {
+		return err
+	}
+
 	// 创建集合记录
 	err := m.store.CreateCollection(name, path, mask)
 	if err != nil {
 		return err
 	}
 
+	if opts.PIIPolicy != "" {
+		if err := m.SetCollectionPIIPolicy(name, opts.PIIPolicy); err != nil {
+			return err
+		}
+	}
+	if opts.IndexMode != "" {
+		return m.SetCollectionIndexMode(name, opts.IndexMode)
+	}
+
 	return nil
 }
 
+// ensureCollection 确保集合记录存在，按配置自动创建或报错
+func (m *MMQ) ensureCollection(name string) error {
+	if name == "" {
+		return fmt.Errorf("collection name is required")
+	}
+
+	exists, err := m.store.CollectionExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if m.cfg.StrictCollections {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+
+	// 自动创建的集合没有文件系统路径，只能通过 API 写入文档
+	return m.store.CreateCollection(name, "", "**/*.md")
+}
+
 // ListCollections 列出所有集合
 func (m *MMQ) ListCollections() ([]Collection, error) {
 	storeCollections, err := m.store.ListCollections()
@@ -415,6 +952,10 @@ func (m *MMQ) ListCollections() ([]Collection, error) {
 			CreatedAt: sc.CreatedAt,
 			UpdatedAt: sc.UpdatedAt,
 			DocCount:  sc.DocCount,
+			Metadata:  sc.Metadata,
+
+			Remote:           sc.Remote,
+			RemoteCollection: sc.RemoteCollection,
 		}
 	}
 
@@ -435,14 +976,45 @@ func (m *MMQ) GetCollection(name string) (*Collection, error) {
 		CreatedAt: sc.CreatedAt,
 		UpdatedAt: sc.UpdatedAt,
 		DocCount:  sc.DocCount,
+		Metadata:  sc.Metadata,
+
+		Remote:           sc.Remote,
+		RemoteCollection: sc.RemoteCollection,
 	}, nil
 }
 
+// GetCollectionMetadata 获取集合的自定义元数据
+func (m *MMQ) GetCollectionMetadata(name string) (map[string]interface{}, error) {
+	return m.store.GetCollectionMetadata(name)
+}
+
+// SetCollectionMetadata 合并写入集合的自定义元数据（描述、负责人、图标、同步游标等）
+// 值为 nil 的键被删除，未提及的键保持不变
+func (m *MMQ) SetCollectionMetadata(name string, values map[string]interface{}) error {
+	return m.store.SetCollectionMetadata(name, values)
+}
+
 // RemoveCollection 删除集合
 func (m *MMQ) RemoveCollection(name string) error {
 	return m.store.RemoveCollection(name)
 }
 
+// HardRemoveCollection 在一个事务内彻底删除集合：文档记录、不再被其他集合引用的内容和嵌入、
+// 集合的上下文，返回释放的数据统计
+func (m *MMQ) HardRemoveCollection(name string) (*CollectionRemoveResult, error) {
+	stats, err := m.store.HardRemoveCollection(name)
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionRemoveResult{
+		Documents:  stats.Documents,
+		Content:    stats.Content,
+		Vectors:    stats.Vectors,
+		Contexts:   stats.Contexts,
+		FreedBytes: stats.FreedBytes,
+	}, nil
+}
+
 // RenameCollection 重命名集合
 func (m *MMQ) RenameCollection(oldName, newName string) error {
 	return m.store.RenameCollection(oldName, newName)
@@ -452,12 +1024,12 @@ func (m *MMQ) RenameCollection(oldName, newName string) error {
 
 // AddContext 添加或更新上下文
 func (m *MMQ) AddContext(path, content string) error {
-	return m.store.AddContext(path, content)
+	return m.contexts.AddContext(path, content)
 }
 
 // ListContexts 列出所有上下文
 func (m *MMQ) ListContexts() ([]ContextEntry, error) {
-	storeContexts, err := m.store.ListContexts()
+	storeContexts, err := m.contexts.ListContexts()
 	if err != nil {
 		return nil, err
 	}
@@ -477,7 +1049,7 @@ func (m *MMQ) ListContexts() ([]ContextEntry, error) {
 
 // GetContext 获取指定路径的上下文
 func (m *MMQ) GetContext(path string) (*ContextEntry, error) {
-	sc, err := m.store.GetContext(path)
+	sc, err := m.contexts.GetContext(path)
 	if err != nil {
 		return nil, err
 	}
@@ -492,7 +1064,31 @@ func (m *MMQ) GetContext(path string) (*ContextEntry, error) {
 
 // RemoveContext 删除上下文
 func (m *MMQ) RemoveContext(path string) error {
-	return m.store.RemoveContext(path)
+	return m.contexts.RemoveContext(path)
+}
+
+// ContextTree 返回上下文层级树，包含每个节点匹配的文档数和未覆盖的文档数
+func (m *MMQ) ContextTree() (*ContextNode, error) {
+	root, err := m.store.ContextTree()
+	if err != nil {
+		return nil, err
+	}
+	return convertContextNode(root), nil
+}
+
+func convertContextNode(n *store.ContextNode) *ContextNode {
+	node := &ContextNode{
+		Path:       n.Path,
+		Kind:       n.Kind,
+		Content:    n.Content,
+		HasContext: n.HasContext,
+		Documents:  n.Documents,
+		Uncovered:  n.Uncovered,
+	}
+	for _, child := range n.Children {
+		node.Children = append(node.Children, convertContextNode(child))
+	}
+	return node
 }
 
 // CheckMissingContexts 检查缺失上下文的集合和路径
@@ -502,7 +1098,7 @@ func (m *MMQ) CheckMissingContexts() ([]string, error) {
 
 // GetContextsForPath 获取路径的所有相关上下文
 func (m *MMQ) GetContextsForPath(path string) ([]ContextEntry, error) {
-	storeContexts, err := m.store.GetContextsForPath(path)
+	storeContexts, err := m.contexts.GetContextsForPath(path)
 	if err != nil {
 		return nil, err
 	}
@@ -522,7 +1118,7 @@ func (m *MMQ) GetContextsForPath(path string) ([]ContextEntry, error) {
 
 // GetDocumentContexts 获取文档的所有相关上下文（按优先级）
 func (m *MMQ) GetDocumentContexts(collection, path string) ([]ContextEntry, error) {
-	storeContexts, err := m.store.GetAllContextsForDocument(collection, path)
+	storeContexts, err := m.contexts.GetAllContextsForDocument(collection, path)
 	if err != nil {
 		return nil, err
 	}
@@ -563,12 +1159,42 @@ func (m *MMQ) ListDocuments(collection, path string) ([]DocumentListEntry, error
 			Hash:       se.Hash,
 			CreatedAt:  se.CreatedAt,
 			ModifiedAt: se.ModifiedAt,
+
+			WordCount:      se.WordCount,
+			CharCount:      se.CharCount,
+			ReadingSeconds: se.ReadingSeconds,
+			Date:           se.Date,
 		}
 	}
 
 	return entries, nil
 }
 
+// LookupDocument 通过 docid 或路径查找文档的目录信息（标题、路径、哈希）
+// 走内存目录缓存，适合自动补全、选择器和引用渲染等高频场景；需要内容时用 GetDocumentByPath/GetDocumentByID
+func (m *MMQ) LookupDocument(ref string) (*DocumentListEntry, error) {
+	se, err := m.store.LookupDocument(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocumentListEntry{
+		ID:         se.ID,
+		DocID:      se.DocID,
+		Collection: se.Collection,
+		Path:       se.Path,
+		Title:      se.Title,
+		Hash:       se.Hash,
+		CreatedAt:  se.CreatedAt,
+		ModifiedAt: se.ModifiedAt,
+
+		WordCount:      se.WordCount,
+		CharCount:      se.CharCount,
+		ReadingSeconds: se.ReadingSeconds,
+		Date:           se.Date,
+	}, nil
+}
+
 // GetDocumentByPath 通过路径获取文档
 // 路径格式：collection/path 或 mmq://collection/path
 func (m *MMQ) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
@@ -587,6 +1213,11 @@ func (m *MMQ) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
 		Hash:       storeDoc.Hash,
 		CreatedAt:  storeDoc.CreatedAt,
 		ModifiedAt: storeDoc.ModifiedAt,
+
+		WordCount:      storeDoc.WordCount,
+		CharCount:      storeDoc.CharCount,
+		ReadingSeconds: storeDoc.ReadingSeconds,
+		Date:           storeDoc.Date,
 	}, nil
 }
 
@@ -608,6 +1239,11 @@ func (m *MMQ) GetDocumentByID(docID string) (*DocumentDetail, error) {
 		Hash:       storeDoc.Hash,
 		CreatedAt:  storeDoc.CreatedAt,
 		ModifiedAt: storeDoc.ModifiedAt,
+
+		WordCount:      storeDoc.WordCount,
+		CharCount:      storeDoc.CharCount,
+		ReadingSeconds: storeDoc.ReadingSeconds,
+		Date:           storeDoc.Date,
 	}, nil
 }
 
@@ -639,6 +1275,11 @@ func (m *MMQ) GetMultipleDocuments(pattern string, maxBytes int) ([]DocumentDeta
 			Hash:       sd.Hash,
 			CreatedAt:  sd.CreatedAt,
 			ModifiedAt: sd.ModifiedAt,
+
+			WordCount:      sd.WordCount,
+			CharCount:      sd.CharCount,
+			ReadingSeconds: sd.ReadingSeconds,
+			Date:           sd.Date,
 		})
 	}
 
@@ -648,16 +1289,40 @@ func (m *MMQ) GetMultipleDocuments(pattern string, maxBytes int) ([]DocumentDeta
 // --- 文档管理API ---
 
 // IndexDocument 索引单个文档
+// 集合不存在时自动创建（StrictCollections 时返回 ErrCollectionNotFound）
 func (m *MMQ) IndexDocument(doc Document) error {
+	doc.Path = store.NormalizeDocPath(doc.Path)
+	if err := m.ensureCollection(doc.Collection); err != nil {
+		return err
+	}
+	if err := m.checkWriteQuota(); err != nil {
+		return err
+	}
+	if err := m.checkCollectionQuota(doc.Collection, doc.Path); err != nil {
+		return err
+	}
+
 	storeDoc := store.Document{
 		ID:         doc.ID,
 		Collection: doc.Collection,
 		Path:       doc.Path,
 		Title:      doc.Title,
 		Content:    doc.Content,
+		Language:   doc.Language,
+		Date:       doc.Date,
 		CreatedAt:  doc.CreatedAt,
 		ModifiedAt: doc.ModifiedAt,
 	}
+	if mode, err := m.CollectionIndexMode(doc.Collection); err != nil {
+		return err
+	} else if mode == IndexModeSummary {
+		if storeDoc.Title == "" {
+			storeDoc.Title = extractTitle(storeDoc.Content, storeDoc.Path)
+		}
+		if err := m.summarizeDocument(&storeDoc, nil); err != nil {
+			return err
+		}
+	}
 	return m.store.IndexDocument(storeDoc)
 }
 
@@ -674,6 +1339,7 @@ func (m *MMQ) GetDocument(id string) (*Document, error) {
 		Path:       storeDoc.Path,
 		Title:      storeDoc.Title,
 		Content:    storeDoc.Content,
+		Language:   storeDoc.Language,
 		CreatedAt:  storeDoc.CreatedAt,
 		ModifiedAt: storeDoc.ModifiedAt,
 	}
@@ -688,44 +1354,165 @@ func (m *MMQ) DeleteDocument(id string) error {
 // --- 嵌入管理（Phase 2实现）---
 
 // GenerateEmbeddings 生成所有文档的嵌入
+// 单个文档失败不会中断其他文档，结束后汇总返回错误
 func (m *MMQ) GenerateEmbeddings() error {
+	report, err := m.EmbedDocuments(EmbedOptions{})
+	if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		f := report.Failed[0]
+		return fmt.Errorf("%d documents failed to embed (first: %s chunk %d: %s)",
+			len(report.Failed), f.Path, f.Chunk, f.Error)
+	}
+	return nil
+}
+
+// EmbedDocuments 生成需要嵌入的文档的嵌入，逐块记录进度
+// 失败的文档记入报告，不影响其他文档；Resume 时从上次中断的块继续
+// opts.Context 取消时在块之间停止并返回部分报告（Interrupted），已生成的块都已保存
+func (m *MMQ) EmbedDocuments(opts EmbedOptions) (*EmbedReport, error) {
 	// 获取需要嵌入的文档
 	docs, err := m.store.GetDocumentsNeedingEmbedding()
 	if err != nil {
-		return fmt.Errorf("failed to get documents: %w", err)
+		return nil, fmt.Errorf("failed to get documents: %w", err)
 	}
 
-	if len(docs) == 0 {
-		return nil // 没有需要嵌入的文档
+	// 使用专用嵌入模型的集合
+	modelDocs, err := m.documentsNeedingModelEmbedding()
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(docs) + len(modelDocs)
+	report := &EmbedReport{Documents: total}
+
+	// 预先统计总块数，用于进度和剩余时间估计
+	totalChunks := 0
+	for _, doc := range docs {
+		totalChunks += len(m.chunkDocument(doc.Path, doc.Content))
+	}
+	for _, md := range modelDocs {
+		totalChunks += len(m.chunkDocument(md.doc.Path, md.doc.Content))
+	}
+	t := newEmbedTracker(m, opts, report, totalChunks)
+	defer func() { report.Elapsed = time.Since(t.start) }()
+
+	interrupted := func(done int) (*EmbedReport, error) {
+		report.Interrupted = true
+		report.Pending = total - done
+		return report, nil
 	}
 
-	// 逐个文档生成嵌入
 	for i, doc := range docs {
-		// 分块
-		chunks := store.ChunkDocument(doc.Content, m.cfg.ChunkSize, m.cfg.ChunkOverlap)
-
-		// 为每个块生成嵌入
-		for j, chunk := range chunks {
-			embedding, err := m.embedding.Generate(chunk.Text, false)
-			if err != nil {
-				return fmt.Errorf("failed to generate embedding for doc %s chunk %d: %w",
-					doc.Hash, j, err)
+		if t.cancelled() {
+			return interrupted(i)
+		}
+		if err := m.embedDocument(doc, opts, report, t); err != nil {
+			if err == errEmbedCancelled {
+				return interrupted(i)
 			}
+			return report, err
+		}
+		t.docDone()
+	}
+
+	for i, md := range modelDocs {
+		if t.cancelled() {
+			return interrupted(len(docs) + i)
+		}
+		if err := m.embedModelDocument(md.doc, md.model, report, t); err != nil {
+			return interrupted(len(docs) + i)
+		}
+		t.docDone()
+	}
+
+	return report, nil
+}
+
+// embedDocument 嵌入单个文档，只有进度无法保存或被取消时才返回错误
+func (m *MMQ) embedDocument(doc store.Document, opts EmbedOptions, report *EmbedReport, t *embedTracker) error {
+	// 分块（参数按文件类型，见 Config.ChunkSettings）
+	size, overlap := m.cfg.ChunkSettings(doc.Path)
+	chunks := store.ChunkDocument(doc.Content, size, overlap)
+	path := doc.Collection + "/" + doc.Path
+	model := m.cfg.EmbeddingModel
+
+	progress, err := m.store.GetEmbeddingProgress(doc.Hash)
+	if err != nil {
+		return err
+	}
 
-			// 存储嵌入
-			err = m.store.StoreEmbedding(doc.Hash, j, chunk.Pos, embedding, m.cfg.EmbeddingModel)
-			if err != nil {
-				return fmt.Errorf("failed to store embedding: %w", err)
+	// 模型和分块一致时才能续传，否则从头开始并清除残留的嵌入
+	start := 0
+	if progress != nil && progress.Status != store.EmbedStatusDone {
+		if opts.Resume && progress.Model == model && progress.TotalChunks == len(chunks) {
+			start = progress.DoneChunks
+			if start > 0 {
+				report.Resumed++
+				report.SkippedChunks += start
+				t.skip(start)
+			}
+		} else if progress.DoneChunks > 0 {
+			if err := m.store.DeleteEmbeddings(doc.Hash); err != nil {
+				return err
 			}
 		}
+	}
+
+	state := store.EmbeddingProgress{
+		Hash:        doc.Hash,
+		Model:       model,
+		TotalChunks: len(chunks),
+		DoneChunks:  start,
+		Status:      store.EmbedStatusRunning,
+	}
+
+	fail := func(chunk int, err error) error {
+		report.Failed = append(report.Failed, EmbedFailure{
+			Hash:  doc.Hash,
+			Path:  path,
+			Chunk: chunk,
+			Error: err.Error(),
+		})
+		t.failed(model, path, len(chunks)-chunk)
+		state.Status = store.EmbedStatusFailed
+		state.Error = err.Error()
+		return m.store.SaveEmbeddingProgress(state)
+	}
+
+	// 为每个块生成嵌入，每块之后保存进度，中断后可续传
+	for j := start; j < len(chunks); j++ {
+		if t.cancelled() {
+			return errEmbedCancelled
+		}
 
-		// 打印进度
-		if (i+1)%10 == 0 || i == len(docs)-1 {
-			fmt.Printf("Embedded %d/%d documents\n", i+1, len(docs))
+		embedding, err := t.generate(model, path, m.llm.IsLoaded(llm.ModelTypeEmbedding), func() ([]float32, error) {
+			return m.embedding.Generate(chunks[j].Text, false)
+		})
+		if err != nil {
+			return fail(j, err)
+		}
+
+		// 存储嵌入
+		if err := m.store.StoreEmbedding(doc.Hash, j, chunks[j].Pos, embedding, model); err != nil {
+			return fail(j, err)
+		}
+
+		report.Chunks++
+		state.DoneChunks = j + 1
+		if err := m.store.SaveEmbeddingProgress(state); err != nil {
+			return err
 		}
+		t.chunkDone(model, path)
 	}
 
-	return nil
+	if err := m.store.RecordChunkSettings("", doc.Hash, size, overlap); err != nil {
+		return err
+	}
+	report.Embedded++
+	state.Status = store.EmbedStatusDone
+	return m.store.SaveEmbeddingProgress(state)
 }
 
 // EmbedText 对文本生成嵌入向量
@@ -733,6 +1520,11 @@ func (m *MMQ) EmbedText(text string) ([]float32, error) {
 	return m.embedding.Generate(text, true)
 }
 
+// GetConfig 返回生效的配置
+func (m *MMQ) GetConfig() Config {
+	return m.cfg
+}
+
 // GetStore 获取Store实例（用于高级用法）
 func (m *MMQ) GetStore() *store.Store {
 	return m.store
@@ -1,9 +1,23 @@
 package mmq
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/i18n"
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/dyike/mmq/pkg/store"
 )
 
 func TestMMQBasic(t *testing.T) {
@@ -15,7 +29,7 @@ func TestMMQBasic(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.DBPath = dbPath
 
-	m, err := New(cfg)
+	m, err := NewFromConfig(cfg)
 	if err != nil {
 		t.Fatalf("Failed to create MMQ: %v", err)
 	}
@@ -104,7 +118,7 @@ func TestMMQMultipleDocuments(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.DBPath = dbPath
 
-	m, err := New(cfg)
+	m, err := NewFromConfig(cfg)
 	if err != nil {
 		t.Fatalf("Failed to create MMQ: %v", err)
 	}
@@ -268,3 +282,591 @@ func BenchmarkSearch(b *testing.B) {
 		m.Search("programming", SearchOptions{Limit: 10})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"0", 0},
+		{"7d", 7 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"abc", "7x", "d"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q) expected error", bad)
+		}
+	}
+}
+
+func TestConfigLoadFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.json")
+
+	cfg := DefaultConfig()
+
+	// 文件不存在时不报错
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile on missing file failed: %v", err)
+	}
+
+	data := `{"memory": {"decay_halflife": {"conversation": "3d", "fact": "0"}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if got := cfg.DecayHalflives[MemoryTypeConversation]; got != 3*24*time.Hour {
+		t.Errorf("Expected conversation halflife 3d, got %v", got)
+	}
+	if got := cfg.DecayHalflives[MemoryTypeEpisodic]; got != 30*24*time.Hour {
+		t.Errorf("Expected episodic halflife to keep default 30d, got %v", got)
+	}
+
+	data = `{"display": {"timezone": "Europe/Berlin", "date_format": "de", "locale": "de", "relative_times": true}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.DisplayTimezone != "Europe/Berlin" || cfg.DateFormat != "de" || cfg.DisplayLocale != "de" || !cfg.RelativeTimes {
+		t.Errorf("Unexpected display config: %q %q %q %v", cfg.DisplayTimezone, cfg.DateFormat, cfg.DisplayLocale, cfg.RelativeTimes)
+	}
+
+	// database.path 覆盖默认数据库位置，未设置时保持不变
+	before := cfg.DBPath
+	if err := SetFileValue(path, "database.path", "~/data/mmq.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	home, _ := os.UserHomeDir()
+	if want := filepath.Join(home, "data", "mmq.db"); cfg.DBPath != want || cfg.DBPath == before {
+		t.Errorf("Expected DBPath %s, got %s", want, cfg.DBPath)
+	}
+
+	data = `{"answer_cache": {"enabled": true, "threshold": 0.9, "ttl": "2h", "max_entries": 0}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if !cfg.AnswerCache || cfg.AnswerCacheThreshold != 0.9 || cfg.AnswerCacheTTL != 2*time.Hour || cfg.AnswerCacheMaxEntries != 0 {
+		t.Errorf("Unexpected answer cache config: %v %v %v %d", cfg.AnswerCache, cfg.AnswerCacheThreshold, cfg.AnswerCacheTTL, cfg.AnswerCacheMaxEntries)
+	}
+
+	data = `{"prompt": {"system": "Today is {{date}}."}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.SystemPrompt != "Today is {{date}}." {
+		t.Errorf("Unexpected system prompt: %q", cfg.SystemPrompt)
+	}
+}
+
+func TestConfigLanguage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := DefaultConfig()
+	if err := os.WriteFile(path, []byte(`{"display": {"language": "zh_CN.UTF-8"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.Language != "zh_CN.UTF-8" {
+		t.Errorf("Language = %q", cfg.Language)
+	}
+	if err := os.WriteFile(path, []byte(`{"display": {"language": "fr"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFile(path); err == nil {
+		t.Error("expected unsupported language to be rejected")
+	}
+
+	defer i18n.Set(i18n.Language())
+	if err := i18n.Set(cfg.Language); err != nil {
+		t.Fatal(err)
+	}
+	if got := i18n.T("memory.deleted", "abc"); got != "✓ 记忆 abc 已删除" {
+		t.Errorf("zh message = %q", got)
+	}
+	if got := i18n.T("no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key = %q", got)
+	}
+	i18n.Set("en")
+	if got := i18n.T("memory.deleted", "abc"); got != "✓ Memory abc deleted" {
+		t.Errorf("en message = %q", got)
+	}
+
+	// 每条英文消息都有中文翻译，且哨兵错误的英文与库一致
+	if missing := i18n.Missing(i18n.Chinese); len(missing) > 0 {
+		t.Errorf("zh catalog is missing %v", missing)
+	}
+	for key, err := range map[string]error{
+		"err.collection_not_found": ErrCollectionNotFound,
+		"err.quota_exceeded":       ErrQuotaExceeded,
+		"err.rebuild_verification": ErrRebuildVerification,
+	} {
+		if got := i18n.T(key); got != err.Error() {
+			t.Errorf("%s = %q, want %q", key, got, err.Error())
+		}
+	}
+}
+
+func TestSetFileValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "config.json")
+
+	// 文件不存在时创建
+	if err := SetFileValue(path, "models.lib_path", "/opt/llama/lib"); err != nil {
+		t.Fatalf("SetFileValue failed: %v", err)
+	}
+
+	// 保留已有配置项
+	data := `{"display": {"locale": "de"}, "models": {"pins": {"a.gguf": "abc"}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFileValue(path, "models.lib_path", "/opt/llama/lib"); err != nil {
+		t.Fatalf("SetFileValue failed: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.LibPath != "/opt/llama/lib" {
+		t.Errorf("Expected lib path to be saved, got %q", cfg.LibPath)
+	}
+	if cfg.DisplayLocale != "de" || cfg.ModelPins["a.gguf"] != "abc" {
+		t.Errorf("Existing settings lost: locale=%q pins=%v", cfg.DisplayLocale, cfg.ModelPins)
+	}
+}
+
+func TestConfigPersonas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"personas": {
+		"coder": {
+			"system_prompt": "You are a Go expert.",
+			"memory_namespace": "coder",
+			"collections": ["code"],
+			"retrieval": {"strategy": "fts", "limit": 5, "min_score": 0.2}
+		},
+		"writer": {}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	p, err := cfg.GetPersona("coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "coder" || p.SystemPrompt != "You are a Go expert." || p.MemoryNamespace != "coder" ||
+		len(p.Collections) != 1 || p.Strategy != "fts" || p.Limit != 5 || p.MinScore != 0.2 {
+		t.Errorf("Unexpected persona: %+v", p)
+	}
+
+	if _, err := cfg.GetPersona("nobody"); err == nil || !strings.Contains(err.Error(), "coder, writer") {
+		t.Errorf("Expected unknown persona error listing available personas, got %v", err)
+	}
+
+	bad := `{"personas": {"x": {"retrieval": {"strategy": "magic"}}}}`
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg = DefaultConfig()
+	if err := cfg.LoadFile(path); err == nil {
+		t.Error("Expected error for invalid persona strategy")
+	}
+}
+
+func TestModelPins(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.CacheDir = t.TempDir()
+
+	good := []byte("good model")
+	if err := os.WriteFile(filepath.Join(cfg.CacheDir, "embed.gguf"), good, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.CacheDir, "rerank.gguf"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.CacheDir, "other.gguf"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(good)
+	goodSum := hex.EncodeToString(sum[:])
+	cfg.ModelPins = map[string]string{
+		"embed":         goodSum, // 可省略 .gguf
+		"rerank.gguf":   goodSum,
+		"generate.gguf": goodSum,
+	}
+
+	checks, err := VerifyModels(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, c := range checks {
+		status[c.File] = c.Status
+	}
+	want := map[string]string{
+		"embed.gguf":    ModelOK,
+		"rerank.gguf":   ModelMismatch,
+		"other.gguf":    ModelUnpinned,
+		"generate.gguf": ModelMissing,
+	}
+	for file, s := range want {
+		if status[file] != s {
+			t.Errorf("%s: expected %s, got %s", file, s, status[file])
+		}
+	}
+
+	// 下载器拒绝使用校验和不一致的缓存文件
+	opts := llm.DefaultDownloadOptions()
+	opts.CacheDir = cfg.CacheDir
+	opts.Pins = cfg.ModelPins
+	_, err = llm.NewDownloader(opts).Download(llm.HFRef{Repo: "x/y", Filename: "rerank.gguf"})
+	var checksumErr *llm.ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Errorf("Expected checksum error, got %v", err)
+	}
+	if path, err := llm.NewDownloader(opts).Download(llm.HFRef{Repo: "x/y", Filename: "embed.gguf"}); err != nil || filepath.Base(path) != "embed.gguf" {
+		t.Errorf("Expected verified cached model, got %s, %v", path, err)
+	}
+
+	cfg.ModelPins = map[string]string{"embed.gguf": "not-a-sha"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid pin to fail validation")
+	}
+}
+
+func TestLLMCacheBackends(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, backend := range []string{LLMCacheDB, LLMCacheFile, LLMCacheMemory} {
+		t.Run(backend, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.DBPath = filepath.Join(dir, backend, "memory.db")
+			cfg.CacheDir = dir
+			cfg.LLMCacheBackend = backend
+			cfg.LLMCacheMaxEntries = 2
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			st, err := store.New(cfg.DBPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+			if err := st.SetCachedResult("stale", "x"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := setupLLMCache(st, cfg); err != nil {
+				t.Fatalf("setupLLMCache failed: %v", err)
+			}
+			for _, key := range []string{"a", "b", "c"} {
+				if err := st.SetCachedResult(key, "result-"+key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if n, _ := st.GetCacheStats(); n != 2 {
+				t.Errorf("Expected cache to be capped at 2 entries, got %d", n)
+			}
+			if got, _ := st.GetCachedResult("c"); got != "result-c" {
+				t.Errorf("Expected newest entry to be cached, got %q", got)
+			}
+
+			// 独立后端不再写入主库
+			var mainRows int
+			st.DB().QueryRow("SELECT COUNT(*) FROM llm_cache").Scan(&mainRows)
+			if backend != LLMCacheDB && mainRows != 0 {
+				t.Errorf("Expected main DB cache table to be empty, got %d rows", mainRows)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, LLMCacheFile, "llm_cache.db")); err != nil {
+		t.Errorf("Expected separate cache file next to the main DB: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(dir, "bad", "memory.db")
+	cfg.CacheDir = dir
+	cfg.LLMCacheBackend = "redis"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected unknown cache backend to be rejected")
+	}
+}
+
+func TestPIIScanner(t *testing.T) {
+	s, err := pii.NewScanner(map[string]string{"employee_id": `EMP-\d{6}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := "身份证 11010519491231002X，卡号 4111 1111 1111 1111，订单 1234 5678 9012 3456，工号 EMP-004211"
+	matches := s.Scan(text)
+
+	got := map[string]string{}
+	for _, m := range matches {
+		got[m.Kind] = m.Value
+	}
+	if got[pii.KindCNID] != "11010519491231002X" {
+		t.Errorf("Expected CN ID match, got %+v", matches)
+	}
+	if got[pii.KindCreditCard] != "4111 1111 1111 1111" {
+		t.Errorf("Expected Luhn-valid card match, got %+v", matches)
+	}
+	if got["employee_id"] != "EMP-004211" {
+		t.Errorf("Expected custom pattern match, got %+v", matches)
+	}
+	if len(matches) != 3 {
+		t.Errorf("Expected 3 matches (Luhn-invalid number ignored), got %+v", matches)
+	}
+
+	if _, err := pii.NewScanner(map[string]string{"bad": "("}); err == nil {
+		t.Error("Expected error for invalid custom pattern")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{"500MB", 500 << 20},
+		{"2GB", 2 << 30},
+		{"1.5g", 3 << 29},
+		{"64 KB", 64 << 10},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"abc", "10XB", "-1GB"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q) expected error", bad)
+		}
+	}
+}
+
+func TestQuota(t *testing.T) {
+	m := newTestMMQ(t)
+	m.cfg.MaxDocsPerCollection = 2
+
+	for _, path := range []string{"a.md", "b.md"} {
+		doc := Document{Collection: "notes", Path: path, Title: path, Content: "content " + path}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatalf("IndexDocument(%s) failed: %v", path, err)
+		}
+	}
+
+	// 更新已有文档不受文档数限制
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "a", Content: "updated"}); err != nil {
+		t.Fatalf("updating existing document failed: %v", err)
+	}
+
+	err := m.IndexDocument(Document{Collection: "notes", Path: "c.md", Title: "c", Content: "third"})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Kind != QuotaCollection || quotaErr.Current != 2 {
+		t.Errorf("Unexpected quota error: %+v", quotaErr)
+	}
+
+	// 数据库大小上限
+	m.cfg.MaxDBSize = 1
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "x"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected StoreMemory to fail with ErrQuotaExceeded, got %v", err)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Quota.DBSize <= 0 || status.Quota.LargestCollection != "notes" || status.Quota.LargestCollectionDocs != 2 {
+		t.Errorf("Unexpected quota usage: %+v", status.Quota)
+	}
+}
+
+// countingEmbedder 统计嵌入调用次数
+type countingEmbedder struct {
+	embedOnly
+	calls int
+}
+
+func (c *countingEmbedder) EmbedBatch(texts []string, isQuery bool) ([][]float32, error) {
+	c.calls++
+	return c.embedOnly.EmbedBatch(texts, isQuery)
+}
+
+func (c *countingEmbedder) Embed(text string, isQuery bool) ([]float32, error) {
+	c.calls++
+	return c.embedOnly.Embed(text, isQuery)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	embedder := &countingEmbedder{embedOnly: embedOnly{newTestLLM(300)}}
+
+	m, err := New(filepath.Join(t.TempDir(), "test.db"),
+		WithLLM(llm.Compose(generateOnly{})),
+		WithEmbedder(embedder),
+		WithEmbeddingModel("remote-embed"),
+		WithChunking(1000, 100),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("New with options failed: %v", err)
+	}
+	defer m.Close()
+
+	if m.cfg.ChunkSize != 1000 || m.cfg.ChunkOverlap != 100 || m.cfg.EmbeddingModel != "remote-embed" {
+		t.Errorf("Expected options to override the config, got %+v", m.cfg)
+	}
+	if !llm.Supports(m.llm, llm.CapabilityEmbed) || !llm.Supports(m.llm, llm.CapabilityGenerate) || llm.Supports(m.llm, llm.CapabilityRerank) {
+		t.Errorf("Expected embed and generate capabilities, got %v", llm.Capabilities(m.llm))
+	}
+
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "options are applied"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedDocuments failed: %v", err)
+	}
+	if embedder.calls == 0 {
+		t.Error("Expected embeddings to come from the configured embedder")
+	}
+	if !strings.Contains(logs.String(), "Embedded 1/1 documents") {
+		t.Errorf("Expected progress in the logger, got %q", logs.String())
+	}
+
+	if _, err := New(filepath.Join(t.TempDir(), "bad.db"), WithLLM(newTestLLM(300)), WithConfig(func(cfg *Config) { cfg.SanitizeLevel = "bogus" })); err == nil {
+		t.Error("Expected options to be validated")
+	}
+}
+
+func TestRemoteEmbeddingAPI(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/embeddings" || req.Model != "remote-model" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []item
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, item{Index: i, Embedding: []float32{1, float32(i), 0.5}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer srv.Close()
+
+	// 没有本地模型（如纯 Go 构建）时，嵌入全部来自远程接口
+	m, err := New(filepath.Join(t.TempDir(), "test.db"),
+		WithLLM(llm.Compose()),
+		WithConfig(func(cfg *Config) {
+			cfg.EmbeddingAPIURL = srv.URL
+			cfg.EmbeddingAPIModel = "remote-model"
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer m.Close()
+
+	if m.cfg.EmbeddingModel != "remote-model" {
+		t.Errorf("Expected vectors to be recorded under the remote model, got %q", m.cfg.EmbeddingModel)
+	}
+
+	vecs, err := llm.NewRemoteEmbedder(srv.URL, "", "remote-model").EmbedBatch([]string{"a", "b", "c"}, false)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(vecs) != 3 || vecs[2][1] != 2 {
+		t.Errorf("Expected vectors in input order, got %v", vecs)
+	}
+
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "remote embeddings"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.EmbedDocuments(EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedDocuments failed: %v", err)
+	}
+	if requests < 2 {
+		t.Errorf("Expected the document to be embedded remotely, got %d requests", requests)
+	}
+}
+
+func TestIndexHealthWarningsWriter(t *testing.T) {
+	m := newTestMMQ(t)
+	if err := m.IndexDocument(Document{Collection: "notes", Path: "a.md", Title: "A", Content: "not embedded yet"}); err != nil {
+		t.Fatal(err)
+	}
+	health, err := m.GetStore().CheckIndexHealth()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	store.WriteIndexHealthWarnings(&buf, health)
+	if !strings.Contains(buf.String(), "Run 'mmq embed'") {
+		t.Errorf("Expected an embed warning in the writer, got %q", buf.String())
+	}
+}
@@ -0,0 +1,72 @@
+package mmq
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// 模型校验状态
+const (
+	ModelOK       = "ok"       // 与固定的校验和一致
+	ModelMismatch = "mismatch" // 与固定的校验和不一致，拒绝加载
+	ModelUnpinned = "unpinned" // 没有固定校验和
+	ModelMissing  = "missing"  // 固定了校验和但文件不存在
+)
+
+// ModelCheck 本地模型文件的校验结果
+type ModelCheck struct {
+	File     string `json:"file"`
+	Path     string `json:"path,omitempty"`
+	Status   string `json:"status"`
+	SHA256   string `json:"sha256,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// VerifyModels 按 cfg.ModelPins 重新校验模型缓存目录中的全部模型文件
+// 不需要加载推理库，可在打开数据库前运行
+func VerifyModels(cfg Config) ([]ModelCheck, error) {
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = DefaultConfig().CacheDir
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.CacheDir, "*.gguf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []ModelCheck
+	found := make(map[string]bool)
+	for _, path := range files {
+		name := filepath.Base(path)
+		found[strings.TrimSuffix(name, ".gguf")] = true
+
+		check := ModelCheck{File: name, Path: path, Status: ModelUnpinned}
+		check.SHA256, err = llm.FileSHA256(path)
+		if err != nil {
+			check.Error = err.Error()
+		}
+		if expected, ok := llm.PinFor(cfg.ModelPins, path); ok {
+			check.Expected = expected
+			check.Status = ModelOK
+			if check.SHA256 != expected {
+				check.Status = ModelMismatch
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	// 固定了但不在缓存目录中的模型
+	for name, sum := range cfg.ModelPins {
+		if found[strings.TrimSuffix(name, ".gguf")] {
+			continue
+		}
+		checks = append(checks, ModelCheck{File: name, Status: ModelMissing, Expected: strings.ToLower(sum)})
+	}
+
+	sort.Slice(checks, func(i, j int) bool { return checks[i].File < checks[j].File })
+	return checks, nil
+}
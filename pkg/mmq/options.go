@@ -0,0 +1,80 @@
+package mmq
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// Option 构造 MMQ 时的可选项，新增功能只需增加 Option，不影响已有调用
+type Option func(*options)
+
+// options New 和 NewFromConfig 收集的构造参数
+type options struct {
+	cfg      Config
+	llm      llm.LLM
+	embedder llm.Embedder
+	logger   *slog.Logger
+}
+
+// WithConfig 在默认配置上修改任意字段
+func WithConfig(fn func(cfg *Config)) Option {
+	return func(o *options) {
+		fn(&o.cfg)
+	}
+}
+
+// WithLLM 使用自定义的 LLM 后端代替本地 yzma 模型
+// 只提供部分能力的后端可以先用 llm.Compose 组合
+func WithLLM(impl llm.LLM) Option {
+	return func(o *options) {
+		o.llm = impl
+	}
+}
+
+// WithEmbedder 使用指定的嵌入器（如远程嵌入服务）生成嵌入，其余能力仍由 LLM 后端提供
+// 向量按 Config.EmbeddingModel 记录，换用不同的嵌入器时用 WithEmbeddingModel 区分
+func WithEmbedder(e llm.Embedder) Option {
+	return func(o *options) {
+		o.embedder = e
+	}
+}
+
+// WithEmbeddingModel 设置嵌入模型名称（本地模型文件名，或自定义嵌入器的标识）
+func WithEmbeddingModel(model string) Option {
+	return func(o *options) {
+		o.cfg.EmbeddingModel = model
+	}
+}
+
+// WithLogger 把进度和警告写入 logger，而不是打印到标准输出
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithChunking 设置分块大小和重叠（字符数，0 表示使用默认值）
+func WithChunking(size, overlap int) Option {
+	return func(o *options) {
+		o.cfg.ChunkSize = size
+		o.cfg.ChunkOverlap = overlap
+	}
+}
+
+// WithCacheDir 设置模型缓存目录
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cfg.CacheDir = dir
+	}
+}
+
+// logf 输出进度和警告：设置了 logger 时写入日志，否则打印到标准输出
+func (m *MMQ) logf(format string, args ...interface{}) {
+	if m.logger != nil {
+		m.logger.Info(fmt.Sprintf(format, args...))
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
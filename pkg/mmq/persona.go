@@ -0,0 +1,83 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// Persona 命名的助手人设，一个安装可以服务多个配置不同的助手
+type Persona struct {
+	Name            string   `json:"name"`
+	SystemPrompt    string   `json:"system_prompt,omitempty"`    // 替换默认 system prompt 的开头说明
+	MemoryNamespace string   `json:"memory_namespace,omitempty"` // 记忆命名空间：只回忆该空间的记忆，新记忆写入该空间
+	Collections     []string `json:"collections,omitempty"`      // 允许检索的集合（为空表示全部）
+
+	// 检索默认值
+	Strategy    string  `json:"strategy,omitempty"` // fts/vector/hybrid（默认 hybrid）
+	Limit       int     `json:"limit,omitempty"`    // 注入的文档数（默认 3）
+	MinScore    float64 `json:"min_score,omitempty"`
+	ExpandQuery bool    `json:"expand_query,omitempty"`
+	Rerank      bool    `json:"rerank,omitempty"`
+}
+
+// filePersona 配置文件中的人设
+type filePersona struct {
+	SystemPrompt    string   `json:"system_prompt"`
+	MemoryNamespace string   `json:"memory_namespace"`
+	Collections     []string `json:"collections"`
+	Retrieval       struct {
+		Strategy string  `json:"strategy"`
+		Limit    int     `json:"limit"`
+		MinScore float64 `json:"min_score"`
+		Expand   bool    `json:"expand"`
+		Rerank   bool    `json:"rerank"`
+	} `json:"retrieval"`
+}
+
+// persona 校验并转换配置文件中的人设
+func (fp filePersona) persona(name string) (Persona, error) {
+	switch rag.RetrievalStrategy(fp.Retrieval.Strategy) {
+	case "", rag.StrategyFTS, rag.StrategyVector, rag.StrategyHybrid:
+	default:
+		return Persona{}, fmt.Errorf("invalid retrieval strategy %q for persona %s", fp.Retrieval.Strategy, name)
+	}
+	if fp.Retrieval.Limit < 0 {
+		return Persona{}, fmt.Errorf("invalid retrieval limit %d for persona %s", fp.Retrieval.Limit, name)
+	}
+
+	return Persona{
+		Name:            name,
+		SystemPrompt:    strings.TrimSpace(fp.SystemPrompt),
+		MemoryNamespace: fp.MemoryNamespace,
+		Collections:     fp.Collections,
+		Strategy:        fp.Retrieval.Strategy,
+		Limit:           fp.Retrieval.Limit,
+		MinScore:        fp.Retrieval.MinScore,
+		ExpandQuery:     fp.Retrieval.Expand,
+		Rerank:          fp.Retrieval.Rerank,
+	}, nil
+}
+
+// GetPersona 按名称获取人设
+func (c Config) GetPersona(name string) (Persona, error) {
+	if p, ok := c.Personas[name]; ok {
+		return p, nil
+	}
+	if len(c.Personas) == 0 {
+		return Persona{}, fmt.Errorf("unknown persona %q: no personas configured", name)
+	}
+	return Persona{}, fmt.Errorf("unknown persona %q (available: %s)", name, strings.Join(c.PersonaNames(), ", "))
+}
+
+// PersonaNames 返回已配置的人设名称（按字母排序）
+func (c Config) PersonaNames() []string {
+	names := make([]string, 0, len(c.Personas))
+	for name := range c.Personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,93 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/pii"
+)
+
+// scanner 返回 PII 扫描器（未通过 New 创建时按配置惰性初始化）
+func (m *MMQ) scanner() (*pii.Scanner, error) {
+	if m.piiScanner == nil {
+		s, err := pii.NewScanner(m.cfg.PIIPatterns)
+		if err != nil {
+			return nil, err
+		}
+		m.piiScanner = s
+	}
+	return m.piiScanner, nil
+}
+
+// SetCollectionPIIPolicy 设置集合索引时的 PII 策略（off/flag/redact，空字符串表示使用默认策略）
+func (m *MMQ) SetCollectionPIIPolicy(name, policy string) error {
+	if policy != "" {
+		p, err := pii.ParsePolicy(policy)
+		if err != nil {
+			return err
+		}
+		policy = string(p)
+	}
+	return m.store.SetCollectionPIIPolicy(name, policy)
+}
+
+// CollectionPIIPolicy 返回集合生效的 PII 策略
+func (m *MMQ) CollectionPIIPolicy(name string) (pii.Policy, error) {
+	policy, err := m.store.GetCollectionPIIPolicy(name)
+	if err != nil {
+		return "", err
+	}
+	if policy == "" {
+		policy = m.cfg.PIIPolicy
+	}
+	return pii.ParsePolicy(policy)
+}
+
+// ScanPII 审计已索引文档和记忆中的 PII（不修改数据）
+func (m *MMQ) ScanPII(opts PIIScanOptions) ([]PIIFinding, error) {
+	s, err := m.scanner()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []PIIFinding
+
+	if opts.Documents {
+		docs, err := m.store.GetDocumentContents(opts.Collection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load documents: %w", err)
+		}
+		for _, doc := range docs {
+			matches := s.Scan(doc.Title + "\n" + doc.Content)
+			if len(matches) == 0 {
+				continue
+			}
+			findings = append(findings, PIIFinding{
+				Source:     "document",
+				ID:         doc.DocID,
+				Collection: doc.Collection,
+				Path:       doc.Path,
+				Matches:    matches,
+			})
+		}
+	}
+
+	if opts.Memories {
+		memories, err := m.store.GetAllMemories()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load memories: %w", err)
+		}
+		for _, mem := range memories {
+			matches := s.Scan(mem.Content)
+			if len(matches) == 0 {
+				continue
+			}
+			findings = append(findings, PIIFinding{
+				Source:  "memory",
+				ID:      mem.ID,
+				Matches: matches,
+			})
+		}
+	}
+
+	return findings, nil
+}
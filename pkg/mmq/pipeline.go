@@ -0,0 +1,52 @@
+package mmq
+
+import (
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// PipelineInfo 已加载的检索流水线
+type PipelineInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Steps       []string `json:"steps"`
+}
+
+// loadPipelines 从流水线目录加载 YAML 流水线并注册到检索器
+func (m *MMQ) loadPipelines() error {
+	if m.cfg.PipelineDir == "" {
+		return nil
+	}
+	pipelines, err := rag.LoadPipelines(m.cfg.PipelineDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range pipelines {
+		if err := m.retriever.RegisterPipeline(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPipeline 解析 YAML 并注册流水线（同名覆盖），之后可用 SearchOptions.Pipeline 选择
+func (m *MMQ) AddPipeline(name string, yamlData []byte) error {
+	p, err := rag.ParsePipeline(name, yamlData)
+	if err != nil {
+		return err
+	}
+	return m.retriever.RegisterPipeline(p)
+}
+
+// Pipelines 列出已加载的检索流水线
+func (m *MMQ) Pipelines() []PipelineInfo {
+	pipelines := m.retriever.Pipelines()
+	infos := make([]PipelineInfo, len(pipelines))
+	for i, p := range pipelines {
+		steps := make([]string, len(p.Steps))
+		for j, s := range p.Steps {
+			steps[j] = s.Type
+		}
+		infos[i] = PipelineInfo{Name: p.Name, Description: p.Description, Steps: steps}
+	}
+	return infos
+}
@@ -0,0 +1,130 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/plugin"
+)
+
+// PluginInfo 已发现插件的信息
+type PluginInfo struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Description string   `json:"description,omitempty"`
+	Extensions  []string `json:"extensions,omitempty"`
+	Dir         string   `json:"dir"`
+	Error       string   `json:"error,omitempty"` // 描述文件无效时的原因
+}
+
+// loadPlugins 扫描插件目录
+func (m *MMQ) loadPlugins() error {
+	if m.cfg.PluginDir == "" {
+		return nil
+	}
+	plugins, err := plugin.Discover(m.cfg.PluginDir)
+	if err != nil {
+		return err
+	}
+	m.plugins = plugins
+	return nil
+}
+
+// Plugins 列出插件目录中发现的插件（包括无效的插件）
+func (m *MMQ) Plugins() []PluginInfo {
+	infos := make([]PluginInfo, len(m.plugins))
+	for i, p := range m.plugins {
+		infos[i] = PluginInfo{
+			Name:        p.Name,
+			Kind:        string(p.Kind),
+			Description: p.Description,
+			Extensions:  p.Extensions,
+			Dir:         p.Dir,
+		}
+		if p.Err != nil {
+			infos[i].Error = p.Err.Error()
+		}
+	}
+	return infos
+}
+
+// pluginsOf 返回指定类型的可用插件
+func (m *MMQ) pluginsOf(kind plugin.Kind) []plugin.Plugin {
+	var result []plugin.Plugin
+	for _, p := range m.plugins {
+		if p.Err == nil && p.Kind == kind {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// extractorFor 返回处理该文件的 extractor 插件（按名称顺序取第一个）
+func (m *MMQ) extractorFor(path string) *plugin.Plugin {
+	for _, p := range m.pluginsOf(plugin.KindExtractor) {
+		if p.Handles(path) {
+			return &p
+		}
+	}
+	return nil
+}
+
+// postProcess 依次执行检索后处理插件
+func (m *MMQ) postProcess(query string, results []SearchResult) ([]SearchResult, error) {
+	for _, p := range m.pluginsOf(plugin.KindPostProcessor) {
+		input := make([]plugin.Result, len(results))
+		byPath := make(map[string]SearchResult, len(results))
+		for i, r := range results {
+			input[i] = plugin.Result{
+				DocID:      r.DocID,
+				Collection: r.Collection,
+				Path:       r.Path,
+				Title:      r.Title,
+				Snippet:    r.Snippet,
+				Score:      r.Score,
+			}
+			byPath[r.Collection+"/"+r.Path] = r
+		}
+
+		output, err := p.PostProcess(query, input)
+		if err != nil {
+			return nil, err
+		}
+
+		// 只接受原结果中的文档，插件可以删除、重排或修改分数和摘要
+		processed := make([]SearchResult, 0, len(output))
+		for _, o := range output {
+			r, ok := byPath[o.Collection+"/"+o.Path]
+			if !ok {
+				continue
+			}
+			r.Score = o.Score
+			if o.Snippet != "" {
+				r.Snippet = o.Snippet
+			}
+			processed = append(processed, r)
+		}
+		results = processed
+	}
+	return results, nil
+}
+
+// Tools 列出可在对话中调用的工具插件
+func (m *MMQ) Tools() []PluginInfo {
+	var tools []PluginInfo
+	for _, info := range m.Plugins() {
+		if info.Kind == string(plugin.KindTool) && info.Error == "" {
+			tools = append(tools, info)
+		}
+	}
+	return tools
+}
+
+// RunTool 调用工具插件，返回工具输出
+func (m *MMQ) RunTool(name, input string) (string, error) {
+	for _, p := range m.pluginsOf(plugin.KindTool) {
+		if p.Name == name {
+			return p.Run(input)
+		}
+	}
+	return "", fmt.Errorf("tool not found: %s", name)
+}
@@ -0,0 +1,233 @@
+package mmq
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// Granularity 查询结果粒度
+type Granularity string
+
+const (
+	// GranularityDocument 每个文档一条结果（默认）
+	GranularityDocument Granularity = "document"
+	// GranularityChunk 每个文档返回与查询最匹配的分块
+	GranularityChunk Granularity = "chunk"
+)
+
+// QueryOptions 统一查询选项
+type QueryOptions struct {
+	SearchOptions // 检索策略、重排、查询扩展、RRF 等参数（Strategy 默认 hybrid）
+
+	Granularity     Granularity // 结果粒度（默认 document）
+	IncludeMemories bool        // 同时回忆相关记忆
+	MemoryLimit     int         // 回忆的记忆数（默认 5）
+	Explain         bool        // 返回实际生效的检索参数
+	GroupBy         string      // 结果分组（doc、collection），doc 时每个文档返回多个分块命中
+}
+
+// QueryResult 统一查询结果
+type QueryResult struct {
+	Query    string         `json:"query"`
+	Results  []SearchResult `json:"results"`
+	Contexts []Context      `json:"contexts"` // 可直接注入 prompt 的上下文
+	Memories []Memory       `json:"memories,omitempty"`
+	Timings  QueryTimings   `json:"timings"`
+	Explain  *QueryExplain  `json:"explain,omitempty"`
+	Groups   []ResultGroup  `json:"groups,omitempty"` // 设置 GroupBy 时的分组结果
+
+	CorpusVersion int64 `json:"corpus_version"` // 检索时的语料版本（固定时为固定的版本）
+
+	// Degraded 超出 Timeout 时为 true，Skipped 为被跳过的阶段（expand、rerank）
+	Degraded bool     `json:"degraded,omitempty"`
+	Skipped  []string `json:"skipped,omitempty"`
+}
+
+// QueryTimings 各阶段耗时
+// 混合检索的 FTS 与向量两路并行执行，各阶段之和可能大于 Retrieve
+type QueryTimings struct {
+	Expand   time.Duration `json:"expand,omitempty"` // 查询扩展
+	Embed    time.Duration `json:"embed,omitempty"`  // 生成查询嵌入
+	FTS      time.Duration `json:"fts,omitempty"`
+	Vector   time.Duration `json:"vector,omitempty"`
+	Fusion   time.Duration `json:"fusion,omitempty"` // RRF 融合
+	Rerank   time.Duration `json:"rerank,omitempty"`
+	Retrieve time.Duration `json:"retrieve"`
+	Memory   time.Duration `json:"memory,omitempty"`
+	Total    time.Duration `json:"total"`
+}
+
+// QueryExplain 实际生效的检索参数
+type QueryExplain struct {
+	Strategy            RetrievalStrategy `json:"strategy"`
+	Granularity         Granularity       `json:"granularity"`
+	Rerank              bool              `json:"rerank"`
+	ExpandQuery         bool              `json:"expand_query"`
+	Normalize           string            `json:"normalize"`
+	CandidateMultiplier float64           `json:"candidate_multiplier"`
+	RerankLimit         int               `json:"rerank_limit"`
+	RRFWeights          []float64         `json:"rrf_weights,omitempty"`
+	RRFK                int               `json:"rrf_k,omitempty"`
+	Sources             map[string]int    `json:"sources"` // 各来源（fts/vector/hybrid/rerank）的结果数
+}
+
+// Query 统一查询入口：按选项检索文档（可选分块粒度），并可附带相关记忆
+func (m *MMQ) Query(q string, opts QueryOptions) (*QueryResult, error) {
+	start := time.Now()
+
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyHybrid
+	}
+	if opts.Granularity == "" {
+		opts.Granularity = GranularityDocument
+	}
+	if opts.MemoryLimit <= 0 {
+		opts.MemoryLimit = 5
+	}
+	if err := ValidateGroupBy(opts.GroupBy); err != nil {
+		return nil, err
+	}
+	version, err := m.resolveCorpusVersion(opts.CorpusVersion)
+	if err != nil {
+		return nil, err
+	}
+	opts.CorpusVersion = version
+
+	results, timings, err := m.search(q, opts.SearchOptions, true, true)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case opts.GroupBy == GroupByDocument:
+		results = m.chunkHits(q, results)
+	case opts.Granularity == GranularityChunk:
+		results = m.bestChunks(q, results)
+	}
+
+	res := &QueryResult{Query: q, Results: results, Degraded: timings.Degraded(), CorpusVersion: version}
+	res.Groups, _ = GroupResults(results, opts.GroupBy)
+	for _, stage := range timings.Skipped {
+		res.Skipped = append(res.Skipped, string(stage))
+	}
+	res.Timings = QueryTimings{
+		Expand:   timings.Stages[rag.StageExpand],
+		Embed:    timings.Stages[rag.StageEmbed],
+		FTS:      timings.Stages[rag.StageFTS],
+		Vector:   timings.Stages[rag.StageVector],
+		Fusion:   timings.Stages[rag.StageFusion],
+		Rerank:   timings.Stages[rag.StageRerank],
+		Retrieve: time.Since(start),
+	}
+
+	for _, r := range results {
+		metadata := r.Metadata
+		if res.Degraded {
+			metadata = map[string]interface{}{"degraded": true}
+			for k, v := range r.Metadata {
+				metadata[k] = v
+			}
+		}
+		res.Contexts = append(res.Contexts, Context{
+			Text:      r.Content,
+			Source:    r.Collection + "/" + r.Path,
+			Relevance: r.Score,
+			Metadata:  metadata,
+		})
+	}
+	res.Contexts = m.trimContexts(q, res.Contexts)
+
+	if opts.IncludeMemories {
+		memStart := time.Now()
+		memories, err := m.RecallMemories(q, RecallOptions{
+			Limit:              opts.MemoryLimit,
+			ApplyDecay:         true,
+			WeightByImportance: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Memories = memories
+		res.Timings.Memory = time.Since(memStart)
+	}
+
+	if opts.Explain {
+		ragOpts, _ := m.ragOptions(opts.SearchOptions)
+		res.Explain = &QueryExplain{
+			Strategy:            RetrievalStrategy(ragOpts.Strategy),
+			Granularity:         opts.Granularity,
+			Rerank:              opts.Rerank,
+			ExpandQuery:         opts.ExpandQuery,
+			Normalize:           string(ragOpts.Normalize),
+			CandidateMultiplier: ragOpts.CandidateMultiplier,
+			RerankLimit:         ragOpts.RerankLimit,
+			RRFWeights:          opts.RRFWeights,
+			RRFK:                opts.RRFK,
+			Sources:             make(map[string]int),
+		}
+		for _, r := range results {
+			res.Explain.Sources[r.Source]++
+		}
+	}
+
+	res.Timings.Total = time.Since(start)
+	return res, nil
+}
+
+// bestChunks 把文档结果替换为各文档中与查询词重合最多的分块
+func (m *MMQ) bestChunks(query string, results []SearchResult) []SearchResult {
+	terms := queryTerms(query)
+	for i, r := range results {
+		chunks := m.chunkDocument(r.Path, r.Content)
+		if len(chunks) <= 1 {
+			continue
+		}
+
+		best := bestChunk(terms, chunks)
+		metadata := make(map[string]interface{}, len(r.Metadata)+2)
+		for k, v := range r.Metadata {
+			metadata[k] = v
+		}
+		metadata["chunk"] = best
+		metadata["pos"] = chunks[best].Pos
+
+		results[i].Content = chunks[best].Text
+		results[i].Metadata = metadata
+	}
+	return results
+}
+
+// bestChunk 与查询词重合最多的分块下标（相同时取靠前的）
+func bestChunk(terms []string, chunks []store.Chunk) int {
+	best, bestHits := 0, -1
+	for i, c := range chunks {
+		lower := strings.ToLower(c.Text)
+		hits := 0
+		for _, t := range terms {
+			hits += strings.Count(lower, t)
+		}
+		if hits > bestHits {
+			best, bestHits = i, hits
+		}
+	}
+	return best
+}
+
+// queryTerms 小写查询词；非 ASCII 的词（如中文）拆成二元组
+func queryTerms(query string) []string {
+	var terms []string
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		if utf8.RuneCountInString(w) == len(w) || utf8.RuneCountInString(w) < 3 {
+			terms = append(terms, w)
+			continue
+		}
+		runes := []rune(w)
+		for i := 0; i+1 < len(runes); i++ {
+			terms = append(terms, string(runes[i:i+2]))
+		}
+	}
+	return terms
+}
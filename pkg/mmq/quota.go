@@ -0,0 +1,138 @@
+package mmq
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrQuotaExceeded 超出配额，可用 errors.Is 判断
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// 配额类型
+const (
+	QuotaDBSize     = "db_size"    // 数据库大小
+	QuotaCollection = "collection" // 单集合文档数
+	QuotaDiskFree   = "disk_free"  // 磁盘剩余空间
+)
+
+// QuotaError 配额错误，Current/Limit 单位为字节或文档数
+type QuotaError struct {
+	Kind       string
+	Collection string
+	Current    int64
+	Limit      int64
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Kind {
+	case QuotaDBSize:
+		return fmt.Sprintf("quota exceeded: database size %s reaches limit %s",
+			FormatSize(e.Current), FormatSize(e.Limit))
+	case QuotaCollection:
+		return fmt.Sprintf("quota exceeded: collection %s has %d documents (limit %d)",
+			e.Collection, e.Current, e.Limit)
+	case QuotaDiskFree:
+		return fmt.Sprintf("quota exceeded: only %s free on disk (minimum %s)",
+			FormatSize(e.Current), FormatSize(e.Limit))
+	default:
+		return fmt.Sprintf("quota exceeded: %s", e.Kind)
+	}
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaUsage 当前用量与配额（限制为 0 表示不限制，DiskFree 为 -1 表示未知）
+type QuotaUsage struct {
+	DBSize                int64  `json:"db_size"`
+	MaxDBSize             int64  `json:"max_db_size"`
+	DiskFree              int64  `json:"disk_free"`
+	MinFreeDisk           int64  `json:"min_free_disk"`
+	MaxDocsPerCollection  int    `json:"max_docs_per_collection"`
+	LargestCollection     string `json:"largest_collection,omitempty"`
+	LargestCollectionDocs int    `json:"largest_collection_docs"`
+}
+
+// Quota 返回当前用量与配额
+func (m *MMQ) Quota() (QuotaUsage, error) {
+	usage := QuotaUsage{
+		MaxDBSize:            m.cfg.MaxDBSize,
+		MinFreeDisk:          m.cfg.MinFreeDisk,
+		MaxDocsPerCollection: m.cfg.MaxDocsPerCollection,
+		DiskFree:             diskFree(m.dbDir()),
+	}
+
+	size, err := m.store.DatabaseSize()
+	if err != nil {
+		return usage, err
+	}
+	usage.DBSize = size
+
+	name, count, err := m.store.LargestCollection()
+	if err != nil {
+		return usage, fmt.Errorf("failed to count documents: %w", err)
+	}
+	usage.LargestCollection = name
+	usage.LargestCollectionDocs = count
+
+	return usage, nil
+}
+
+// checkWriteQuota 写入前检查数据库大小和磁盘剩余空间
+func (m *MMQ) checkWriteQuota() error {
+	if m.cfg.MaxDBSize > 0 {
+		size, err := m.store.DatabaseSize()
+		if err != nil {
+			return err
+		}
+		if size >= m.cfg.MaxDBSize {
+			return &QuotaError{Kind: QuotaDBSize, Current: size, Limit: m.cfg.MaxDBSize}
+		}
+	}
+
+	if m.cfg.MinFreeDisk > 0 {
+		// 无法获取剩余空间时不拦截
+		if free := diskFree(m.dbDir()); free >= 0 && free < m.cfg.MinFreeDisk {
+			return &QuotaError{Kind: QuotaDiskFree, Current: free, Limit: m.cfg.MinFreeDisk}
+		}
+	}
+
+	return nil
+}
+
+// checkCollectionQuota 检查集合文档数，更新已有文档不计入
+func (m *MMQ) checkCollectionQuota(collection, path string) error {
+	if m.cfg.MaxDocsPerCollection <= 0 {
+		return nil
+	}
+
+	exists, err := m.store.DocumentExists(collection, path)
+	if err != nil {
+		return fmt.Errorf("failed to check document: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	count, err := m.store.CountCollectionDocuments(collection)
+	if err != nil {
+		return fmt.Errorf("failed to count documents: %w", err)
+	}
+	if count >= m.cfg.MaxDocsPerCollection {
+		return &QuotaError{
+			Kind:       QuotaCollection,
+			Collection: collection,
+			Current:    int64(count),
+			Limit:      int64(m.cfg.MaxDocsPerCollection),
+		}
+	}
+	return nil
+}
+
+// dbDir 数据库所在目录
+func (m *MMQ) dbDir() string {
+	return filepath.Dir(m.store.DBPath())
+}
@@ -0,0 +1,367 @@
+package mmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// 全量重建的阶段
+const (
+	RebuildStageSnapshot = "snapshot" // 复制数据库到暂存文件
+	RebuildStageFTS      = "fts"      // 重建全文索引、清空向量
+	RebuildStageEmbed    = "embed"    // 按当前配置重新分块和嵌入
+	RebuildStageVerify   = "verify"   // 核对行数、抽查检索一致性
+	RebuildStageSwap     = "swap"     // 原子替换数据库文件
+)
+
+// RebuildOptions 全量重建选项
+type RebuildOptions struct {
+	// Context 取消时放弃重建并删除暂存库，数据库保持不变
+	Context context.Context
+	// Progress 进入每个阶段时回调
+	Progress func(RebuildProgress)
+	// EmbedProgress 重新嵌入时每个块的进度（同 EmbedOptions.Progress）
+	EmbedProgress func(EmbedProgress)
+	// SkipEmbed 只重建全文索引；向量被清空，之后用 mmq embed 生成
+	SkipEmbed bool
+	// SpotChecks 抽查检索一致性的文档数（0 为 20，负数不抽查）
+	SpotChecks int
+	// Force 核对不通过时仍然替换
+	Force bool
+	// DryRun 只构建和核对，不替换；暂存库保留供检查
+	DryRun bool
+}
+
+// RebuildProgress 全量重建进度
+type RebuildProgress struct {
+	Stage   string        `json:"stage"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// IndexCounts 全量重建前后核对的行数
+type IndexCounts struct {
+	Collections int `json:"collections"`
+	Documents   int `json:"documents"` // 活跃文档
+	Contents    int `json:"contents"`  // 活跃文档引用的内容
+	FTSRows     int `json:"fts_rows"`
+	Chunks      int `json:"chunks"` // 已嵌入的块（含集合专用模型）
+	Memories    int `json:"memories"`
+	Contexts    int `json:"contexts"`
+}
+
+// RebuildSpotCheck 一个抽查文档在新旧索引中的检索结果
+type RebuildSpotCheck struct {
+	Doc          string `json:"doc"` // collection/path
+	Query        string `json:"query"`
+	FTSMatch     bool   `json:"fts_match"`               // 新旧全文检索的前 10 个结果一致
+	VectorBefore bool   `json:"vector_before,omitempty"` // 原库向量检索前 10 中有该文档
+	VectorAfter  bool   `json:"vector_after,omitempty"`  // 新库向量检索前 10 中有该文档
+}
+
+// RebuildReport 全量重建报告
+type RebuildReport struct {
+	Before     IndexCounts        `json:"before"`
+	After      IndexCounts        `json:"after"`
+	Embed      *EmbedReport       `json:"embed,omitempty"`
+	SpotChecks []RebuildSpotCheck `json:"spot_checks,omitempty"`
+	Problems   []string           `json:"problems,omitempty"` // 阻止替换的核对失败
+	Warnings   []string           `json:"warnings,omitempty"` // 不阻止替换（如换了嵌入模型后向量结果不同）
+	Swapped    bool               `json:"swapped"`
+	Backup     string             `json:"backup,omitempty"`  // 替换前的数据库
+	Staging    string             `json:"staging,omitempty"` // DryRun 时保留的暂存库
+	Elapsed    time.Duration      `json:"elapsed"`
+}
+
+// ErrRebuildVerification 核对未通过，数据库未替换
+var ErrRebuildVerification = errors.New("rebuild verification failed; database unchanged")
+
+// spotCheckLimit 抽查时比较的结果数
+const spotCheckLimit = 10
+
+// Rebuild 停机全量重建：把数据库复制到暂存文件，从文档内容重建全文索引，按当前配置重新分块和嵌入，
+// 核对行数并抽查检索一致性，然后原子替换数据库文件（原文件保留为 <db>.bak）
+// 适用于数据库损坏或分块、嵌入模型等配置大改之后；重建期间不应有其他进程写入
+// 核对未通过时返回报告和 ErrRebuildVerification，数据库不变
+func (m *MMQ) Rebuild(opts RebuildOptions) (*RebuildReport, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	stage := func(name string) {
+		if opts.Progress != nil {
+			opts.Progress(RebuildProgress{Stage: name, Elapsed: time.Since(start)})
+		}
+	}
+
+	report := &RebuildReport{}
+	before, err := m.store.RebuildCounts()
+	if err != nil {
+		return nil, err
+	}
+	report.Before = IndexCounts(before)
+
+	// 1. 复制到暂存库（清掉上次中断遗留的文件）
+	stage(RebuildStageSnapshot)
+	staging := m.cfg.DBPath + ".reindex"
+	removeDBFiles(staging)
+	if err := m.store.SnapshotTo(staging); err != nil {
+		return nil, err
+	}
+	st, err := store.New(staging)
+	if err != nil {
+		removeDBFiles(staging)
+		return nil, fmt.Errorf("failed to open staging database: %w", err)
+	}
+	staged := m.stagedIndex(st)
+	keep, closed := false, false
+	defer func() {
+		if !closed {
+			staged.closeModelLLMs()
+			st.Close()
+		}
+		if !keep {
+			removeDBFiles(staging)
+		}
+	}()
+
+	// 2. 重建全文索引，清空向量
+	stage(RebuildStageFTS)
+	if err := st.ResetDerivedIndexes(); err != nil {
+		return nil, err
+	}
+
+	// 3. 按当前配置重新分块和嵌入
+	if !opts.SkipEmbed {
+		stage(RebuildStageEmbed)
+		embed, err := staged.EmbedDocuments(EmbedOptions{Context: ctx, Progress: opts.EmbedProgress})
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-embed: %w", err)
+		}
+		report.Embed = embed
+		if embed.Interrupted {
+			return nil, fmt.Errorf("rebuild interrupted; database unchanged")
+		}
+	}
+
+	// 4. 核对
+	stage(RebuildStageVerify)
+	after, err := st.RebuildCounts()
+	if err != nil {
+		return nil, err
+	}
+	report.After = IndexCounts(after)
+	if err := m.verifyRebuild(staged, report, opts); err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("rebuild interrupted; database unchanged")
+	}
+
+	report.Elapsed = time.Since(start)
+	if opts.DryRun {
+		keep = true
+		report.Staging = staging
+		return report, nil
+	}
+	if len(report.Problems) > 0 && !opts.Force {
+		return report, ErrRebuildVerification
+	}
+
+	// 5. 原子替换
+	stage(RebuildStageSwap)
+	closed = true
+	staged.closeModelLLMs()
+	if err := st.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close staging database: %w", err)
+	}
+	backup := m.cfg.DBPath + ".bak"
+	if err := m.store.SwapFile(staging, backup); err != nil {
+		return nil, err
+	}
+	keep = true // 已被移走，不再清理
+	report.Swapped = true
+	report.Backup = backup
+	report.Elapsed = time.Since(start)
+	return report, nil
+}
+
+// stagedIndex 使用本实例模型、指向暂存库的 MMQ，只用于嵌入和检索
+func (m *MMQ) stagedIndex(st *store.Store) *MMQ {
+	staged := &MMQ{
+		store:       st,
+		contexts:    st,
+		llm:         m.llm,
+		embedding:   m.embedding,
+		retriever:   rag.NewRetriever(st, m.llm, m.embedding),
+		cfg:         m.cfg,
+		newModelLLM: m.newModelLLM,
+		logger:      m.logger,
+	}
+	staged.retriever.SetEmbedderResolver(staged.embedderFor)
+	return staged
+}
+
+// verifyRebuild 核对新旧行数，并抽查文档的全文和向量检索结果
+func (m *MMQ) verifyRebuild(staged *MMQ, report *RebuildReport, opts RebuildOptions) error {
+	b, a := report.Before, report.After
+	counts := []struct {
+		name          string
+		before, after int
+	}{
+		{"collections", b.Collections, a.Collections},
+		{"documents", b.Documents, a.Documents},
+		{"contents", b.Contents, a.Contents},
+		{"memories", b.Memories, a.Memories},
+		{"contexts", b.Contexts, a.Contexts},
+	}
+	for _, c := range counts {
+		if c.before != c.after {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: %d before, %d after", c.name, c.before, c.after))
+		}
+	}
+	if a.FTSRows != a.Documents {
+		report.Problems = append(report.Problems, fmt.Sprintf("full-text index has %d rows for %d documents", a.FTSRows, a.Documents))
+	}
+
+	if opts.SkipEmbed {
+		report.Warnings = append(report.Warnings, "vectors cleared; run 'mmq embed' after the rebuild")
+	} else {
+		if n := len(report.Embed.Failed); n > 0 {
+			f := report.Embed.Failed[0]
+			report.Problems = append(report.Problems, fmt.Sprintf("%d documents failed to embed (first: %s: %s)", n, f.Path, f.Error))
+		}
+		pending, err := staged.store.GetDocumentsNeedingEmbedding()
+		if err != nil {
+			return err
+		}
+		modelPending, err := staged.documentsNeedingModelEmbedding()
+		if err != nil {
+			return err
+		}
+		if n := len(pending) + len(modelPending); n > 0 && len(report.Embed.Failed) == 0 {
+			report.Problems = append(report.Problems, fmt.Sprintf("%d documents still need embedding", n))
+		}
+	}
+
+	return m.spotCheckRebuild(staged, report, opts)
+}
+
+// spotCheckRebuild 随机抽取文档，用标题（或开头几个词）检索新旧索引
+// 全文检索应完全一致；向量结果在更换模型或分块后可能变化，只记为警告
+func (m *MMQ) spotCheckRebuild(staged *MMQ, report *RebuildReport, opts RebuildOptions) error {
+	n := opts.SpotChecks
+	if n == 0 {
+		n = 20
+	}
+	if n < 0 {
+		return nil
+	}
+	collections, err := m.store.ListCollections()
+	if err != nil {
+		return err
+	}
+	if len(collections) == 0 {
+		return nil
+	}
+	perCollection := (n + len(collections) - 1) / len(collections)
+
+	var lostFTS, lostVector int
+	for _, c := range collections {
+		docs, err := m.store.SampleDocuments(c.Name, perCollection)
+		if err != nil {
+			return err
+		}
+		for _, d := range docs {
+			if len(report.SpotChecks) >= n {
+				break
+			}
+			query := spotCheckQuery(d.Title, d.Content)
+			if query == "" {
+				continue
+			}
+			check := RebuildSpotCheck{Doc: d.Collection + "/" + d.Path, Query: query}
+
+			ftsOpts := rag.RetrieveOptions{Limit: spotCheckLimit, Strategy: rag.StrategyFTS}
+			oldFTS, err := m.retriever.Retrieve(query, ftsOpts)
+			if err != nil {
+				return fmt.Errorf("spot check %q: %w", query, err)
+			}
+			newFTS, err := staged.retriever.Retrieve(query, ftsOpts)
+			if err != nil {
+				return fmt.Errorf("spot check %q: %w", query, err)
+			}
+			check.FTSMatch = contextKeys(oldFTS) == contextKeys(newFTS)
+			if !check.FTSMatch {
+				lostFTS++
+			}
+
+			if !opts.SkipEmbed && report.Before.Chunks > 0 {
+				vecOpts := rag.RetrieveOptions{Limit: spotCheckLimit, Strategy: rag.StrategyVector, Collection: d.Collection}
+				if oldVec, err := m.retriever.Retrieve(query, vecOpts); err == nil {
+					check.VectorBefore = containsDoc(oldVec, check.Doc)
+				}
+				if newVec, err := staged.retriever.Retrieve(query, vecOpts); err == nil {
+					check.VectorAfter = containsDoc(newVec, check.Doc)
+				}
+				if check.VectorBefore && !check.VectorAfter {
+					lostVector++
+				}
+			}
+			report.SpotChecks = append(report.SpotChecks, check)
+		}
+	}
+
+	if lostFTS > 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d of %d spot checks returned different full-text results", lostFTS, len(report.SpotChecks)))
+	}
+	if lostVector > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d of %d spot-checked documents no longer appear in their own vector top %d (expected after changing the embedding model or chunking)", lostVector, len(report.SpotChecks), spotCheckLimit))
+	}
+	return nil
+}
+
+// spotCheckQuery 抽查用的查询：标题，没有标题时取正文开头 8 个词
+func spotCheckQuery(title, content string) string {
+	if q := strings.TrimSpace(title); q != "" {
+		return q
+	}
+	words := strings.Fields(content)
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	return strings.Join(words, " ")
+}
+
+// contextKeys 结果的 collection/path 序列
+func contextKeys(contexts []rag.Context) string {
+	keys := make([]string, len(contexts))
+	for i, c := range contexts {
+		keys[i] = getMetadataString(c.Metadata, "collection") + "/" + getMetadataString(c.Metadata, "path")
+	}
+	return strings.Join(keys, "\n")
+}
+
+// containsDoc 结果中是否有该文档
+func containsDoc(contexts []rag.Context, doc string) bool {
+	for _, c := range contexts {
+		if getMetadataString(c.Metadata, "collection")+"/"+getMetadataString(c.Metadata, "path") == doc {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDBFiles 删除数据库文件及其 WAL 文件
+func removeDBFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,196 @@
+package mmq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// remoteIndex 远端数据库及其检索器
+type remoteIndex struct {
+	store     *store.Store
+	retriever *rag.Retriever
+}
+
+// remoteDBPath 解析远端地址（路径或 file:// URL）为数据库文件的绝对路径
+func remoteDBPath(remote string) (string, error) {
+	switch {
+	case remote == "":
+		return "", fmt.Errorf("remote is required")
+	case strings.HasPrefix(remote, "http://"), strings.HasPrefix(remote, "https://"):
+		return "", fmt.Errorf("HTTP remotes are not supported; use the path of the other database (e.g. on a mounted or synced drive)")
+	}
+	path := expandPath(strings.TrimPrefix(remote, "file://"))
+	return filepath.Abs(path)
+}
+
+// AddRemoteCollection 添加远端集合：文档留在另一个 mmq 数据库（如共享盘上的团队语料），
+// 搜索时直接查询远端并与本地结果融合；remoteCollection 为空时与 name 相同
+func (m *MMQ) AddRemoteCollection(name, remote, remoteCollection string) error {
+	if remoteCollection == "" {
+		remoteCollection = name
+	}
+	path, err := remoteDBPath(remote)
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(m.cfg.DBPath); err == nil && abs == path {
+		return fmt.Errorf("remote database is this database")
+	}
+
+	r, err := m.openRemote(path)
+	if err != nil {
+		return err
+	}
+	if _, err := r.store.GetCollection(remoteCollection); err != nil {
+		return fmt.Errorf("remote %s: %w", path, err)
+	}
+	return m.store.CreateRemoteCollection(name, path, remoteCollection)
+}
+
+// openRemote 打开（并缓存）远端数据库
+func (m *MMQ) openRemote(path string) (*remoteIndex, error) {
+	m.remotesMu.Lock()
+	defer m.remotesMu.Unlock()
+	if r, ok := m.remotes[path]; ok {
+		return r, nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("remote database not found: %s", path)
+	}
+	// 远端是别人的数据库，只读打开，不迁移
+	st, err := store.OpenReadOnly(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote database: %w", err)
+	}
+
+	st.SetVectorPrefilter(m.vectorPrefilter())
+
+	// 使用本地的模型，远端向量需由相同的嵌入模型生成
+	retriever := rag.NewRetriever(st, m.llm, m.embedding)
+	retriever.SetEmbedderResolver(m.embedderFor)
+	retriever.SetLegParallelism(m.cfg.RetrievalParallelism)
+	m.setupRerankCache(retriever)
+	for _, p := range m.retriever.Pipelines() {
+		retriever.RegisterPipeline(p)
+	}
+
+	r := &remoteIndex{store: st, retriever: retriever}
+	if m.remotes == nil {
+		m.remotes = make(map[string]*remoteIndex)
+	}
+	m.remotes[path] = r
+	return r, nil
+}
+
+// closeRemotes 关闭打开的远端数据库
+func (m *MMQ) closeRemotes() {
+	m.remotesMu.Lock()
+	defer m.remotesMu.Unlock()
+	for _, r := range m.remotes {
+		r.store.Close()
+	}
+	m.remotes = nil
+}
+
+// retrieve 检索本地索引和涉及的远端集合，有远端结果时按排名 RRF 融合
+// 搜索全部集合时不可用的远端被跳过；指定远端集合时返回错误
+func (m *MMQ) retrieve(query string, opts rag.RetrieveOptions, withTimings bool) ([]rag.Context, *rag.Timings, error) {
+	remotes, err := m.store.RemoteCollections()
+	if err != nil {
+		return nil, nil, err
+	}
+	var targets []store.Collection
+	for _, c := range remotes {
+		if opts.Collection == "" || opts.Collection == c.Name {
+			targets = append(targets, c)
+		}
+	}
+
+	var lists [][]rag.Context
+	var timings *rag.Timings
+	if opts.Collection == "" || len(targets) == 0 {
+		var local []rag.Context
+		if withTimings {
+			local, timings, err = m.retriever.RetrieveWithTimings(query, opts)
+		} else {
+			local, err = m.retriever.Retrieve(query, opts)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(targets) == 0 {
+			return local, timings, nil
+		}
+		lists = append(lists, local)
+	}
+
+	for _, c := range targets {
+		results, err := m.retrieveRemote(query, c, opts)
+		if err != nil {
+			if opts.Collection != "" {
+				return nil, nil, fmt.Errorf("remote collection %s: %w", c.Name, err)
+			}
+			continue
+		}
+		lists = append(lists, results)
+	}
+
+	return fuseContexts(lists, opts.RRFK, opts.Limit), timings, nil
+}
+
+// retrieveRemote 在远端数据库中检索，结果的集合名换成本地的远端集合名
+func (m *MMQ) retrieveRemote(query string, c store.Collection, opts rag.RetrieveOptions) ([]rag.Context, error) {
+	r, err := m.openRemote(c.Remote)
+	if err != nil {
+		return nil, err
+	}
+	opts.Collection = c.RemoteCollection
+	contexts, err := r.retriever.Retrieve(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range contexts {
+		path := getMetadataString(contexts[i].Metadata, "path")
+		contexts[i].Source = c.Name + "/" + path
+		contexts[i].Metadata["collection"] = c.Name
+		contexts[i].Metadata["remote"] = c.Remote
+	}
+	return contexts, nil
+}
+
+// fuseContexts 按排名做 RRF 融合（各来源的分数不可比），分数为融合分数
+func fuseContexts(lists [][]rag.Context, k, limit int) []rag.Context {
+	if k <= 0 {
+		k = 60
+	}
+	scores := make(map[string]float64)
+	first := make(map[string]rag.Context)
+	var order []string
+	for _, list := range lists {
+		for rank, ctx := range list {
+			if _, ok := first[ctx.Source]; !ok {
+				first[ctx.Source] = ctx
+				order = append(order, ctx.Source)
+			}
+			scores[ctx.Source] += 1.0 / float64(k+rank+1)
+		}
+	}
+
+	fused := make([]rag.Context, len(order))
+	for i, source := range order {
+		fused[i] = first[source]
+		fused[i].Relevance = scores[source]
+	}
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Relevance > fused[j].Relevance })
+	if limit > 0 && len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
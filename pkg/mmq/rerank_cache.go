@@ -0,0 +1,40 @@
+package mmq
+
+import (
+	"time"
+
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// storeRerankCache 保存在主库 rerank_cache 表中的重排分数缓存
+type storeRerankCache struct {
+	store      *store.Store
+	ttl        time.Duration
+	maxEntries int
+}
+
+func (c storeRerankCache) Get(model, queryHash string, chunkHashes []string) (map[string]float64, error) {
+	return c.store.GetRerankScores(model, queryHash, chunkHashes, time.Now().Add(-c.ttl))
+}
+
+func (c storeRerankCache) Put(model, queryHash string, scores map[string]float64) error {
+	return c.store.PutRerankScores(model, queryHash, scores, c.maxEntries)
+}
+
+// setupRerankCache 按配置为检索器开启重排分数缓存（远端集合的检索器也使用本地缓存）
+func (m *MMQ) setupRerankCache(r *rag.Retriever) {
+	if !m.cfg.RerankCache {
+		return
+	}
+	r.SetRerankCache(m.cfg.RerankModel, storeRerankCache{
+		store:      m.store,
+		ttl:        m.cfg.RerankCacheTTL,
+		maxEntries: m.cfg.RerankCacheMaxEntries,
+	})
+}
+
+// ClearRerankCache 清空重排分数缓存，返回删除的条目数
+func (m *MMQ) ClearRerankCache() (int, error) {
+	return m.store.ClearRerankCache()
+}
@@ -0,0 +1,105 @@
+package mmq
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/dyike/mmq/pkg/rag"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// withinCandidateMultiplier 在结果集中检索时每路至少召回 Limit×该倍数个候选，过滤后仍能凑够结果
+const withinCandidateMultiplier = 10
+
+// resultSetNamePattern 结果集名称：字母、数字、点、下划线和连字符
+var resultSetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ResultSet 命名结果集（search --save），后续查询可以用 SearchOptions.Within 只在其中继续筛选
+type ResultSet struct {
+	Name      string         `json:"name"`
+	Query     string         `json:"query"`
+	CreatedAt time.Time      `json:"created_at"`
+	Size      int            `json:"size"`
+	Docs      []ResultSetDoc `json:"docs,omitempty"`
+}
+
+// ResultSetDoc 结果集中的一个文档
+type ResultSetDoc struct {
+	Collection string  `json:"collection"`
+	Path       string  `json:"path"`
+	Title      string  `json:"title,omitempty"`
+	Score      float64 `json:"score"`
+}
+
+// SaveResultSet 把一次检索的结果保存为命名结果集（同名的被替换），同一文档的多个命中只保留第一个
+func (m *MMQ) SaveResultSet(name, query string, results []SearchResult) error {
+	if !resultSetNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid result set name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	seen := make(map[string]bool, len(results))
+	docs := make([]store.ResultSetDoc, 0, len(results))
+	for _, r := range results {
+		key := r.Collection + "/" + r.Path
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		docs = append(docs, store.ResultSetDoc{Collection: r.Collection, Path: r.Path, Title: r.Title, Score: r.Score})
+	}
+	return m.store.SaveResultSet(name, query, docs)
+}
+
+// GetResultSet 返回结果集及其文档
+func (m *MMQ) GetResultSet(name string) (*ResultSet, error) {
+	rs, err := m.store.GetResultSet(name)
+	if err != nil {
+		return nil, err
+	}
+	set := convertResultSet(*rs)
+	for _, d := range rs.Docs {
+		set.Docs = append(set.Docs, ResultSetDoc(d))
+	}
+	return &set, nil
+}
+
+// ListResultSets 列出未过期的结果集，最近保存的在前
+func (m *MMQ) ListResultSets() ([]ResultSet, error) {
+	sets, err := m.store.ListResultSets()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ResultSet, len(sets))
+	for i, rs := range sets {
+		result[i] = convertResultSet(rs)
+	}
+	return result, nil
+}
+
+// DeleteResultSet 删除结果集
+func (m *MMQ) DeleteResultSet(name string) error {
+	return m.store.DeleteResultSet(name)
+}
+
+// convertResultSet 转换结果集（不含文档）
+func convertResultSet(rs store.ResultSet) ResultSet {
+	return ResultSet{Name: rs.Name, Query: rs.Query, CreatedAt: rs.CreatedAt, Size: rs.Size}
+}
+
+// withinResultSet 在 filter 之外只保留结果集中的文档
+func (m *MMQ) withinResultSet(name string, filter func(rag.Context) bool) (func(rag.Context) bool, error) {
+	rs, err := m.store.GetResultSet(name)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(rs.Docs))
+	for _, d := range rs.Docs {
+		members[d.Collection+"/"+d.Path] = true
+	}
+	return func(c rag.Context) bool {
+		if !members[getMetadataString(c.Metadata, "collection")+"/"+getMetadataString(c.Metadata, "path")] {
+			return false
+		}
+		return filter == nil || filter(c)
+	}, nil
+}
@@ -0,0 +1,83 @@
+package mmq
+
+import "time"
+
+// MemoryJoin 检索文档时一并召回的记忆，零值使用默认值
+type MemoryJoin struct {
+	Limit        int          // 最多返回的记忆数（默认 5）
+	Types        []MemoryType // 记忆类型（默认事实和偏好）
+	MinRelevance float64      // 最小相关度（默认 0.3，含衰减和重要性加权；负数表示不过滤）
+	MaxChars     int          // 记忆组的字符预算（默认 2000），与文档分开计算
+	SessionID    string       // 当前会话，同会话记忆获得加权
+}
+
+// Retrieval 文档和记忆两组上下文
+type Retrieval struct {
+	Documents []Context `json:"documents"`
+	Memories  []Context `json:"memories,omitempty"` // Source 为 memory:<类型>/<ID>，Metadata 含 memory_id、type、timestamp
+}
+
+// 记忆组的默认值（与对话 prompt 中的记忆召回一致）
+const (
+	defaultJoinMemoryLimit     = 5
+	defaultJoinMemoryRelevance = 0.3
+	defaultJoinMemoryChars     = 2000
+)
+
+// RetrieveWithMemories 检索文档上下文，同时按同一查询召回相关记忆作为单独的一组返回，
+// 相当于对话组装 prompt 时的文档和记忆部分；记忆组有自己的条数和字符预算，不占用文档的 Limit
+func (m *MMQ) RetrieveWithMemories(query string, opts RetrieveOptions, join MemoryJoin) (*Retrieval, error) {
+	docs, err := m.RetrieveContext(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &Retrieval{Documents: docs}
+
+	if join.Limit <= 0 {
+		join.Limit = defaultJoinMemoryLimit
+	}
+	if len(join.Types) == 0 {
+		join.Types = []MemoryType{MemoryTypeFact, MemoryTypePreference}
+	}
+	if join.MinRelevance == 0 {
+		join.MinRelevance = defaultJoinMemoryRelevance
+	}
+	if join.MaxChars <= 0 {
+		join.MaxChars = defaultJoinMemoryChars
+	}
+
+	memories, err := m.RecallMemories(query, RecallOptions{
+		Limit:              join.Limit,
+		MemoryTypes:        join.Types,
+		ApplyDecay:         true,
+		DecayHalflife:      30 * 24 * time.Hour,
+		TypeHalflives:      m.cfg.DecayHalflives,
+		WeightByImportance: true,
+		MinRelevance:       join.MinRelevance,
+		SessionID:          join.SessionID,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// 按相关度依次放入，超出预算的记忆跳过（较短的后续记忆仍可放入）
+	budget := join.MaxChars
+	for _, mem := range memories {
+		n := len([]rune(mem.Content))
+		if n > budget {
+			continue
+		}
+		budget -= n
+		result.Memories = append(result.Memories, Context{
+			Text:      mem.Content,
+			Source:    "memory:" + string(mem.Type) + "/" + mem.ID,
+			Relevance: mem.Relevance,
+			Metadata: map[string]interface{}{
+				"memory_id": mem.ID,
+				"type":      string(mem.Type),
+				"timestamp": mem.Timestamp,
+			},
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,31 @@
+package mmq
+
+import (
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// ChatTurn 一轮对话
+type ChatTurn struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// RewriteQuery 结合最近的对话把追问改写为可独立检索的查询
+// generate 为空时使用本地 generate 模型
+func (m *MMQ) RewriteQuery(query string, history []ChatTurn, generate func(prompt string) (string, error)) (string, error) {
+	if generate == nil {
+		generate = func(prompt string) (string, error) {
+			opts := llm.DefaultGenerateOptions()
+			opts.Temperature = 0
+			opts.MaxTokens = 128
+			return m.llm.Generate(prompt, opts)
+		}
+	}
+
+	turns := make([]rag.Turn, len(history))
+	for i, t := range history {
+		turns[i] = rag.Turn{User: t.User, Assistant: t.Assistant}
+	}
+	return rag.RewriteQuery(query, turns, generate)
+}
@@ -0,0 +1,204 @@
+package mmq
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dyike/mmq/pkg/pii"
+	"github.com/dyike/mmq/pkg/plugin"
+	"github.com/dyike/mmq/pkg/s3"
+)
+
+// s3Client 按环境变量和配置创建对象存储客户端（配置优先）
+func (m *MMQ) s3Client() *s3.Client {
+	c := s3.FromEnv()
+	if m.cfg.S3Endpoint != "" {
+		c.Endpoint = m.cfg.S3Endpoint
+	}
+	if m.cfg.S3Region != "" {
+		c.Region = m.cfg.S3Region
+	}
+	return c
+}
+
+// indexS3 索引 S3 兼容对象存储中前缀下匹配 mask 的对象
+// ETag 未变化的对象直接沿用已索引的版本，不再下载
+func (m *MMQ) indexS3(source string, opts IndexOptions) (*IndexSummary, error) {
+	bucket, prefix, err := s3.ParseURL(source)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/" // 前缀视为目录
+	}
+
+	mask := opts.Mask
+	if mask == "" {
+		mask = "**/*.md"
+	}
+
+	collection := opts.Collection
+	if collection == "" {
+		collection = bucket
+		if prefix != "" {
+			collection = path.Base(strings.TrimSuffix(prefix, "/"))
+		}
+	}
+
+	exists, _ := m.store.CollectionExists(collection)
+	if !exists {
+		if err := m.store.CreateCollection(collection, source, mask); err != nil {
+			return nil, fmt.Errorf("failed to create collection: %w", err)
+		}
+	}
+
+	piiPolicy, err := m.CollectionPIIPolicy(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PII policy: %w", err)
+	}
+	scanner, err := m.scanner()
+	if err != nil {
+		return nil, err
+	}
+
+	client := m.s3Client()
+	objects, err := client.List(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := m.store.GetCollectionHashes(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing documents: %w", err)
+	}
+	oldETags, err := m.store.ObjectETags(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, err := m.store.BeginReindex(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin reindex: %w", err)
+	}
+
+	summary := &IndexSummary{Collection: collection, PIIPolicy: string(piiPolicy)}
+	if summary.IndexMode, err = m.summaryIndexMode(collection); err != nil {
+		m.store.AbortReindex(collection, generation)
+		return nil, err
+	}
+	etags := make(map[string]string)
+	var stageErr error
+	for i, obj := range objects {
+		relPath := strings.TrimPrefix(obj.Key, prefix)
+		if relPath == "" || strings.HasSuffix(relPath, "/") {
+			continue // 目录占位对象
+		}
+		if matched, err := doublestar.Match(mask, relPath); err != nil || !matched {
+			summary.Skipped++
+			continue
+		}
+
+		// ETag 未变化：沿用已索引的文档
+		if obj.ETag != "" && oldETags[relPath] == obj.ETag {
+			ok, err := m.store.StageUnchanged(generation, collection, relPath)
+			if err != nil {
+				stageErr = err
+				break
+			}
+			if ok {
+				etags[relPath] = obj.ETag
+				summary.Unchanged++
+				continue
+			}
+		}
+
+		res := m.prepareObject(client, bucket, obj, i, collection, relPath, scanner, piiPolicy)
+		if err := m.commitPrepared(generation, res, existing, summary); err != nil {
+			stageErr = err
+			break
+		}
+		if res.err == nil {
+			etags[relPath] = obj.ETag
+		}
+	}
+
+	if stageErr != nil {
+		m.store.AbortReindex(collection, generation)
+		if errors.Is(stageErr, ErrQuotaExceeded) {
+			return summary, stageErr
+		}
+		return summary, fmt.Errorf("failed to index %s: %w", source, stageErr)
+	}
+
+	stats, err := m.store.CommitReindex(collection, generation)
+	if err != nil {
+		m.store.AbortReindex(collection, generation)
+		return summary, err
+	}
+	summary.Removed = stats.Deactivated
+
+	if err := m.store.ReplaceObjectETags(collection, etags); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// prepareObject 下载对象并准备文档；有匹配的内容转换时在内存中解码，
+// 否则有匹配的提取插件时先写入临时文件再提取
+func (m *MMQ) prepareObject(client *s3.Client, bucket string, obj s3.Object, seq int, collection, relPath string, scanner *pii.Scanner, piiPolicy pii.Policy) indexResult {
+	res := indexResult{seq: seq}
+	res.doc.Path = relPath
+
+	data, err := client.Get(bucket, obj.Key)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	content, title := string(data), ""
+	if transform := m.transformFor(relPath); transform != nil {
+		decoded, err := decodeContent(transform, relPath, data)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		content = string(decoded)
+	} else if extractor := m.extractorFor(relPath); extractor != nil {
+		extracted, err := extractObject(extractor, relPath, data)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		content, title = extracted.Text, extracted.Title
+	}
+
+	modTime := obj.LastModified
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	prepareText(&res, collection, relPath, content, title, modTime, scanner, piiPolicy)
+	return res
+}
+
+// extractObject 把对象内容写入临时文件（保留扩展名）后交给提取插件
+func extractObject(extractor *plugin.Plugin, relPath string, data []byte) (*plugin.Extracted, error) {
+	f, err := os.CreateTemp("", "mmq-s3-*"+path.Ext(relPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return extractor.Extract(f.Name())
+}
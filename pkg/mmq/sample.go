@@ -0,0 +1,99 @@
+package mmq
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// SampleOptions 随机抽样选项
+type SampleOptions struct {
+	Stratify bool  // 按路径前缀分层，各层轮流抽取（覆盖更均匀）
+	Depth    int   // 分层使用的目录层数（默认 1）
+	Seed     int64 // 随机种子（0 为随机；固定种子可复现抽样，便于构建评测集）
+}
+
+// SampleDocuments 从集合中随机抽取 n 个文档（collection 为空表示所有集合）
+func (m *MMQ) SampleDocuments(collection string, n int, opts SampleOptions) ([]DocumentDetail, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("sample size must be positive, got %d", n)
+	}
+
+	entries, err := m.store.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	// 分组：不分层时只有一组
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	groups := make(map[string][]int)
+	var keys []string
+	for i, e := range entries {
+		if collection != "" && e.Collection != collection {
+			continue
+		}
+		key := ""
+		if opts.Stratify {
+			key = e.Collection + "/" + pathPrefix(e.Path, depth)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	// 各组内打乱，组顺序也打乱，然后轮流取
+	rng.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	for _, k := range keys {
+		g := groups[k]
+		rng.Shuffle(len(g), func(i, j int) { g[i], g[j] = g[j], g[i] })
+	}
+
+	var picked []int
+	for round := 0; len(picked) < n; round++ {
+		added := false
+		for _, k := range keys {
+			if round < len(groups[k]) && len(picked) < n {
+				picked = append(picked, groups[k][round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	docs := make([]DocumentDetail, 0, len(picked))
+	for _, idx := range picked {
+		e := entries[idx]
+		doc, err := m.GetDocumentByPath(e.Collection + "/" + e.Path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+	return docs, nil
+}
+
+// pathPrefix 路径的前 depth 层目录（根目录下的文件返回空）
+func pathPrefix(path string, depth int) string {
+	parts := strings.Split(path, "/")
+	if len(parts) <= 1 {
+		return ""
+	}
+	dirs := parts[:len(parts)-1]
+	if len(dirs) > depth {
+		dirs = dirs[:depth]
+	}
+	return strings.Join(dirs, "/")
+}
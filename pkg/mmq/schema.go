@@ -0,0 +1,25 @@
+package mmq
+
+// SchemaVersion 对外 JSON 结构的版本号
+//
+// 兼容性约定：同一版本内只会新增可选字段，不会删除、重命名字段或改变字段类型；
+// 任何不兼容的改动都会递增版本号。消费方应忽略不认识的字段，并检查 schema_version。
+const SchemaVersion = 1
+
+// FormatVersion 文本、Markdown、CSV 和 XML 输出布局的版本号
+//
+// 列顺序、表头、字段标签或行结构的变化都会递增版本号（新增 JSON 字段不算，见 SchemaVersion）；
+// 解析这些输出的工具应检查 mmq --version 报告的 format 版本。
+const FormatVersion = 1
+
+// Envelope 带版本号的 JSON 输出外层
+type Envelope struct {
+	SchemaVersion int         `json:"schema_version"`
+	Kind          string      `json:"kind"` // 数据类型，如 search_results、collections
+	Data          interface{} `json:"data"`
+}
+
+// NewEnvelope 用当前版本号包装数据
+func NewEnvelope(kind string, data interface{}) Envelope {
+	return Envelope{SchemaVersion: SchemaVersion, Kind: kind, Data: data}
+}
@@ -0,0 +1,51 @@
+package mmq
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// schemaV1 冻结的 v1 JSON 字段；只允许新增字段，删除或重命名需要递增 SchemaVersion
+var schemaV1 = map[string][]string{
+	"SearchResult":      {"id", "docid", "score", "title", "content", "snippet", "source", "collection", "path", "language", "metadata", "timestamp"},
+	"Collection":        {"name", "path", "mask", "created_at", "updated_at", "doc_count", "metadata"},
+	"Context":           {"text", "source", "relevance", "metadata"},
+	"ContextEntry":      {"path", "content", "created_at", "updated_at"},
+	"ContextNode":       {"path", "kind", "content", "has_context", "documents", "uncovered", "children"},
+	"DocumentListEntry": {"id", "docid", "collection", "path", "title", "hash", "created_at", "modified_at"},
+	"DocumentDetail":    {"id", "docid", "collection", "path", "title", "content", "hash", "created_at", "modified_at"},
+	"Status":            {"total_documents", "needs_embedding", "collections", "db_path", "cache_dir", "quota"},
+	"Memory":            {"id", "type", "content", "metadata", "tags", "timestamp", "expires_at", "importance", "relevance", "ttl", "access_count"},
+}
+
+func TestJSONSchemaV1Frozen(t *testing.T) {
+	if SchemaVersion != 1 {
+		t.Skip("schemaV1 only guards version 1")
+	}
+
+	for _, v := range []interface{}{
+		SearchResult{}, Collection{}, Context{}, ContextEntry{}, ContextNode{},
+		DocumentListEntry{}, DocumentDetail{}, Status{}, Memory{},
+	} {
+		rt := reflect.TypeOf(v)
+		keys := make(map[string]bool)
+		for i := 0; i < rt.NumField(); i++ {
+			keys[strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]] = true
+		}
+		for _, want := range schemaV1[rt.Name()] {
+			if !keys[want] {
+				t.Errorf("%s: JSON field %q removed or renamed; bump SchemaVersion", rt.Name(), want)
+			}
+		}
+	}
+
+	data, err := json.Marshal(NewEnvelope("search_results", []SearchResult{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"schema_version":1,"kind":"search_results","data":[]}` {
+		t.Errorf("Unexpected envelope: %s", data)
+	}
+}
@@ -0,0 +1,180 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// 集合自测参数
+const (
+	benchDefaultQueries = 20  // 默认合成查询数
+	benchLimit          = 10  // 每个查询检查前 10 个结果
+	benchMargin         = 0.1 // MRR 差距不超过该值时两路相当，推荐混合检索
+)
+
+// BenchOptions 集合自测选项
+type BenchOptions struct {
+	Queries int   // 合成查询数（默认 20）
+	Seed    int64 // 抽样种子（默认 1，结果可复现）
+}
+
+// StrategyBench 集合自测结果：用文档标题和小标题合成查询，比较全文和向量检索能否找回原文档
+type StrategyBench struct {
+	Collection  string            `json:"collection"`
+	Queries     int               `json:"queries"`
+	FTS         StrategyScore     `json:"fts"`
+	Vector      StrategyScore     `json:"vector"`
+	Recommended RetrievalStrategy `json:"recommended"`
+	Reason      string            `json:"reason"`
+}
+
+// StrategyScore 单一策略的自测得分
+type StrategyScore struct {
+	MRR  float64 `json:"mrr"`  // 原文档排名倒数的平均值（前 10 之外记 0）
+	Hits int     `json:"hits"` // 原文档出现在前 10 的查询数
+}
+
+// benchQuery 合成查询及其来源文档
+type benchQuery struct {
+	query string
+	doc   string // collection/path
+}
+
+// BenchCollection 对集合做小规模自测，记录推荐的默认策略，之后 StrategyAuto 检索该集合时使用
+// 两路得分相当时推荐混合检索；集合没有嵌入时向量检索得分为 0，推荐全文检索
+func (m *MMQ) BenchCollection(name string, opts BenchOptions) (*StrategyBench, error) {
+	if opts.Queries <= 0 {
+		opts.Queries = benchDefaultQueries
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	if _, err := m.store.GetCollectionStrategy(name); err != nil {
+		return nil, err
+	}
+
+	docs, err := m.SampleDocuments(name, opts.Queries, SampleOptions{Seed: opts.Seed})
+	if err != nil {
+		return nil, err
+	}
+	queries := benchQueries(docs, opts.Queries)
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("collection '%s' has no documents to benchmark", name)
+	}
+
+	bench := &StrategyBench{Collection: name, Queries: len(queries)}
+	for _, leg := range []struct {
+		strategy rag.RetrievalStrategy
+		score    *StrategyScore
+	}{
+		{rag.StrategyFTS, &bench.FTS},
+		{rag.StrategyVector, &bench.Vector},
+	} {
+		for _, q := range queries {
+			contexts, err := m.retriever.Retrieve(q.query, rag.RetrieveOptions{Limit: benchLimit, Strategy: leg.strategy, Collection: name})
+			if err != nil {
+				// 没有嵌入时向量检索可能报错，按未命中计
+				continue
+			}
+			if rank := docRank(contexts, q.doc); rank > 0 {
+				leg.score.Hits++
+				leg.score.MRR += 1 / float64(rank)
+			}
+		}
+		leg.score.MRR /= float64(len(queries))
+	}
+
+	bench.Recommended, bench.Reason = recommendStrategy(bench.FTS, bench.Vector)
+	if err := m.store.SetCollectionStrategy(name, string(bench.Recommended)); err != nil {
+		return nil, err
+	}
+	return bench, nil
+}
+
+// CollectionStrategy 返回集合记录的推荐策略，未自测时为空
+func (m *MMQ) CollectionStrategy(name string) (RetrievalStrategy, error) {
+	strategy, err := m.store.GetCollectionStrategy(name)
+	return RetrievalStrategy(strategy), err
+}
+
+// resolveStrategy 把 StrategyAuto 换成集合推荐的策略
+func (m *MMQ) resolveStrategy(strategy RetrievalStrategy, collection string) (RetrievalStrategy, error) {
+	if strategy != StrategyAuto {
+		return strategy, nil
+	}
+	if collection == "" {
+		return StrategyHybrid, nil
+	}
+	recommended, err := m.CollectionStrategy(collection)
+	if err != nil {
+		return "", err
+	}
+	if recommended == "" {
+		return StrategyHybrid, nil
+	}
+	return recommended, nil
+}
+
+// recommendStrategy 按两路 MRR 推荐策略
+func recommendStrategy(fts, vector StrategyScore) (RetrievalStrategy, string) {
+	switch {
+	case vector.Hits == 0 && fts.Hits == 0:
+		return StrategyHybrid, "neither full-text nor vector search found the source documents"
+	case vector.Hits == 0:
+		return StrategyFTS, "vector search found none of the source documents (run 'mmq embed' and benchmark again)"
+	case fts.MRR-vector.MRR > benchMargin:
+		return StrategyFTS, fmt.Sprintf("full-text search ranks source documents clearly higher (MRR %.2f vs %.2f)", fts.MRR, vector.MRR)
+	case vector.MRR-fts.MRR > benchMargin:
+		return StrategyVector, fmt.Sprintf("vector search ranks source documents clearly higher (MRR %.2f vs %.2f)", vector.MRR, fts.MRR)
+	default:
+		return StrategyHybrid, fmt.Sprintf("full-text and vector search perform alike (MRR %.2f vs %.2f)", fts.MRR, vector.MRR)
+	}
+}
+
+// benchQueries 从文档标题和 Markdown 小标题合成查询，最多 n 个
+// 先取各文档的标题，不足时再取各文档第一个与标题不同的小标题
+func benchQueries(docs []DocumentDetail, n int) []benchQuery {
+	var titles, headings []benchQuery
+	for _, d := range docs {
+		doc := d.Collection + "/" + d.Path
+		title := spotCheckQuery(d.Title, d.Content)
+		if title != "" {
+			titles = append(titles, benchQuery{query: title, doc: doc})
+		}
+		if h := firstHeading(d.Content, title); h != "" {
+			headings = append(headings, benchQuery{query: h, doc: doc})
+		}
+	}
+	queries := append(titles, headings...)
+	if len(queries) > n {
+		queries = queries[:n]
+	}
+	return queries
+}
+
+// firstHeading 正文中第一个与 exclude 不同的 Markdown 标题
+func firstHeading(content, exclude string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		h := strings.TrimSpace(strings.TrimLeft(line, "#"))
+		if h != "" && !strings.EqualFold(h, exclude) {
+			return h
+		}
+	}
+	return ""
+}
+
+// docRank 文档在结果中的名次（从 1 开始），不在结果中返回 0
+func docRank(contexts []rag.Context, doc string) int {
+	for i, c := range contexts {
+		if getMetadataString(c.Metadata, "collection")+"/"+getMetadataString(c.Metadata, "path") == doc {
+			return i + 1
+		}
+	}
+	return 0
+}
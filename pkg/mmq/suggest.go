@@ -0,0 +1,128 @@
+package mmq
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SuggestionKind 补全建议类型
+type SuggestionKind string
+
+const (
+	SuggestCollection SuggestionKind = "collection" // 集合名
+	SuggestQuery      SuggestionKind = "query"      // 最近查询
+	SuggestDocument   SuggestionKind = "document"   // 文档（标题或路径匹配）
+)
+
+// SuggestOptions 补全选项
+type SuggestOptions struct {
+	Limit int              // 最多返回条数（默认 10）
+	Kinds []SuggestionKind // 只返回这些类型（空为全部）
+}
+
+// Suggestion 补全建议
+type Suggestion struct {
+	Kind  SuggestionKind `json:"kind"`
+	Text  string         `json:"text"`            // 补全文本：集合名、查询或 collection/path
+	Title string         `json:"title,omitempty"` // 文档标题
+	DocID string         `json:"docid,omitempty"`
+}
+
+// Suggest 按前缀（不区分大小写）返回补全建议，依次为集合、最近查询、文档
+// 文档按标题前缀、路径（含文件名）前缀、标题中单词前缀的顺序排列
+func (m *MMQ) Suggest(prefix string, opts SuggestOptions) ([]Suggestion, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	want := func(k SuggestionKind) bool {
+		if len(opts.Kinds) == 0 {
+			return true
+		}
+		for _, kind := range opts.Kinds {
+			if kind == k {
+				return true
+			}
+		}
+		return false
+	}
+
+	lower := strings.ToLower(strings.TrimSpace(prefix))
+	var out []Suggestion
+
+	if want(SuggestCollection) {
+		collections, err := m.store.ListCollections()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range collections {
+			if strings.HasPrefix(strings.ToLower(c.Name), lower) {
+				out = append(out, Suggestion{Kind: SuggestCollection, Text: c.Name})
+			}
+		}
+	}
+
+	if want(SuggestQuery) && len(out) < limit {
+		queries, err := m.store.RecentQueries(lower, limit-len(out))
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queries {
+			out = append(out, Suggestion{Kind: SuggestQuery, Text: q.Query})
+		}
+	}
+
+	if want(SuggestDocument) && lower != "" && len(out) < limit {
+		entries, err := m.store.Catalog()
+		if err != nil {
+			return nil, err
+		}
+
+		// 按匹配方式分档，档内保持目录顺序
+		var tiers [3][]Suggestion
+		for _, e := range entries {
+			title := strings.ToLower(e.Title)
+			path := strings.ToLower(e.Path)
+			full := strings.ToLower(e.Collection + "/" + e.Path)
+
+			tier := -1
+			switch {
+			case strings.HasPrefix(title, lower):
+				tier = 0
+			case strings.HasPrefix(path, lower) || strings.HasPrefix(full, lower) ||
+				strings.HasPrefix(path[strings.LastIndex(path, "/")+1:], lower):
+				tier = 1
+			case hasWordPrefix(title, lower):
+				tier = 2
+			}
+			if tier >= 0 {
+				tiers[tier] = append(tiers[tier], Suggestion{
+					Kind:  SuggestDocument,
+					Text:  e.Collection + "/" + e.Path,
+					Title: e.Title,
+					DocID: e.DocID,
+				})
+			}
+		}
+		for _, tier := range tiers {
+			out = append(out, tier...)
+		}
+	}
+
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// hasWordPrefix s 中是否有单词以 prefix 开头
+func hasWordPrefix(s, prefix string) bool {
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/lang"
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// 集合的索引模式
+const (
+	IndexModeFull    = "full"    // 保存全文（默认）
+	IndexModeSummary = "summary" // 只保存生成模型写的摘要及其嵌入，检索结果指向原文件
+)
+
+// summaryInputChars 生成摘要时输入的最大字符数
+const summaryInputChars = 8000
+
+// summaryHashPrefix 摘要文档的内容哈希前缀：按原文计算以便识别未变化的文件，
+// 又不与保存全文的同一内容共用 content 行
+const summaryHashPrefix = "summary-only\x00"
+
+// summaryIndexPrompt 生成索引摘要的 prompt
+const summaryIndexPrompt = `为以下文档写一段检索用的摘要（不超过 150 字），说明它的主题、涉及的对象和关键词，
+让读者能据此判断是否需要打开原文件。
+
+要求：
+- 使用与文档相同的语言
+- 不要照抄原文中的敏感细节（账号、金额、联系方式、具体数字等），只概括其类型
+- 只输出摘要本身，不要加前缀或引号
+
+文件：%s
+标题：%s
+
+%s
+摘要：`
+
+// ParseIndexMode 解析索引模式，空字符串视为 full
+func ParseIndexMode(mode string) (string, error) {
+	switch mode {
+	case "", IndexModeFull:
+		return IndexModeFull, nil
+	case IndexModeSummary:
+		return IndexModeSummary, nil
+	}
+	return "", fmt.Errorf("invalid index mode %q (use full or summary)", mode)
+}
+
+// SetCollectionIndexMode 设置集合的索引模式，下次索引时生效
+func (m *MMQ) SetCollectionIndexMode(name, mode string) error {
+	mode, err := ParseIndexMode(mode)
+	if err != nil {
+		return err
+	}
+	if mode == IndexModeFull {
+		mode = ""
+	}
+	return m.store.SetCollectionIndexMode(name, mode)
+}
+
+// CollectionIndexMode 返回集合的索引模式
+func (m *MMQ) CollectionIndexMode(name string) (string, error) {
+	mode, err := m.store.GetCollectionIndexMode(name)
+	if err != nil {
+		return "", err
+	}
+	return ParseIndexMode(mode)
+}
+
+// summaryHash 摘要文档按原文计算的内容哈希
+func summaryHash(text string) string {
+	return hashContent(summaryHashPrefix + text)
+}
+
+// summarizeDocument 把文档内容替换为生成的摘要；原文未变化（哈希与 existing 中一致）时沿用已保存的摘要
+// 日期和语言在替换前从原文提取
+func (m *MMQ) summarizeDocument(doc *store.Document, existing map[string]string) error {
+	hash := summaryHash(doc.Content)
+	if doc.Date == "" {
+		doc.Date = store.ExtractDocumentDate(doc.Path, doc.Content)
+	}
+	if doc.Language == "" {
+		doc.Language = lang.Detect(doc.Content)
+	}
+	doc.Hash = hash
+	if existing[doc.Path] == hash {
+		doc.Content = ""
+		return nil
+	}
+
+	summary, err := m.generateSummary(doc.Path, doc.Title, doc.Content)
+	if err != nil {
+		return err
+	}
+	doc.Content = summary
+	return nil
+}
+
+// generateSummary 用生成模型为文档写摘要
+func (m *MMQ) generateSummary(path, title, content string) (string, error) {
+	if !llm.Supports(m.llm, llm.CapabilityGenerate) {
+		return "", fmt.Errorf("summary indexing needs a generation model")
+	}
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0.2
+	opts.MaxTokens = 256
+	out, err := m.llm.Generate(fmt.Sprintf(summaryIndexPrompt, path, title, truncateRunes(content, summaryInputChars)), opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", path, err)
+	}
+	summary := strings.Trim(strings.TrimSpace(out), `"“”`)
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary for %s", path)
+	}
+	return summary, nil
+}
+
+// summaryIndexMode 集合为摘要模式时返回 summary，否则返回空（IndexSummary 中省略）
+func (m *MMQ) summaryIndexMode(collection string) (string, error) {
+	mode, err := m.CollectionIndexMode(collection)
+	if err != nil || mode != IndexModeSummary {
+		return "", err
+	}
+	return mode, nil
+}
@@ -0,0 +1,89 @@
+package mmq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	Conflict string // 冲突处理：lww（默认，较晚的修改生效）、manual、local、remote
+	DryRun   bool   // 只计算要同步的变更，不写入
+}
+
+// SyncConflict 两端都修改过的文档或记忆
+type SyncConflict struct {
+	Key        string    `json:"key"` // collection/path 或 memory:<id>
+	LocalTime  time.Time `json:"local_time"`
+	RemoteTime time.Time `json:"remote_time"`
+	Winner     string    `json:"winner,omitempty"` // local 或 remote，manual 模式下为空
+}
+
+// SyncResult 同步结果
+type SyncResult struct {
+	Remote     string         `json:"remote"`
+	RemoteID   string         `json:"remote_id"`
+	Initial    bool           `json:"initial,omitempty"`
+	Pulled     int            `json:"pulled"`
+	Pushed     int            `json:"pushed"`
+	Renamed    int            `json:"renamed,omitempty"`
+	Conflicts  []SyncConflict `json:"conflicts,omitempty"`
+	Unresolved int            `json:"unresolved,omitempty"`
+	DryRun     bool           `json:"dry_run,omitempty"`
+}
+
+// Sync 与另一个 mmq 数据库双向同步文档和记忆
+// remote 为数据库路径或 file:// URL（如挂载盘或同步盘上的数据库）
+// 首次同步比较两端全部内容，之后只交换事件日志中自上次同步以来的变更
+func (m *MMQ) Sync(remote string, opts SyncOptions) (*SyncResult, error) {
+	policy, err := store.ParseSyncPolicy(opts.Conflict)
+	if err != nil {
+		return nil, err
+	}
+	path, err := remoteDBPath(remote)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(m.cfg.DBPath); err == nil && abs == path {
+		return nil, fmt.Errorf("cannot sync a database with itself")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("remote database not found: %s", path)
+	}
+
+	// 远端可能是另一台机器上的数据库：不切换 WAL、不迁移，schema 不是当前版本时拒绝
+	rs, err := store.OpenExisting(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote database: %w", err)
+	}
+	defer rs.Close()
+
+	stats, err := m.store.Sync(rs, remote, policy, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{
+		Remote:     remote,
+		RemoteID:   stats.RemoteID,
+		Initial:    stats.Initial,
+		Pulled:     stats.Pulled,
+		Pushed:     stats.Pushed,
+		Renamed:    stats.Renamed,
+		Unresolved: stats.Unresolved(),
+		DryRun:     stats.DryRun,
+	}
+	for _, c := range stats.Conflicts {
+		result.Conflicts = append(result.Conflicts, SyncConflict{
+			Key:        c.Key,
+			LocalTime:  c.LocalTime,
+			RemoteTime: c.RemoteTime,
+			Winner:     c.Winner,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package mmq
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// TimelineKind 时间线条目类型
+type TimelineKind string
+
+const (
+	TimelineDocument TimelineKind = "document"
+	TimelineMemory   TimelineKind = "memory"
+)
+
+// TimelineOptions 时间线选项
+type TimelineOptions struct {
+	Collection  string       // 只包含该集合的文档（为空表示所有集合，不影响记忆）
+	Since       time.Time    // 起始时间（含），零值表示不限
+	Until       time.Time    // 截止时间（不含），零值表示不限
+	MemoryTypes []MemoryType // 包含的记忆类型（默认只有情景记忆）
+	NoMemories  bool         // 只列出文档
+	Limit       int          // 只保留最近的 N 条（0 表示不限制）
+	SnippetLen  int          // 摘要长度（字符，默认 160）
+}
+
+// TimelineEntry 时间线条目，文档和记忆共用
+type TimelineEntry struct {
+	Kind    TimelineKind `json:"kind"`
+	Time    time.Time    `json:"time"`
+	Title   string       `json:"title,omitempty"`
+	Snippet string       `json:"snippet"`
+
+	// 文档条目
+	DocID      string `json:"docid,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Path       string `json:"path,omitempty"`
+
+	// 记忆条目
+	MemoryID   string     `json:"memory_id,omitempty"`
+	MemoryType MemoryType `json:"memory_type,omitempty"`
+}
+
+// Timeline 按时间顺序合并文档和记忆（从早到晚）
+// 文档使用文档日期（路径/frontmatter 解析），没有时使用修改时间；记忆使用记录时间
+func (m *MMQ) Timeline(opts TimelineOptions) ([]TimelineEntry, error) {
+	inRange := func(t time.Time) bool {
+		if !opts.Since.IsZero() && t.Before(opts.Since) {
+			return false
+		}
+		if !opts.Until.IsZero() && !t.Before(opts.Until) {
+			return false
+		}
+		return true
+	}
+
+	entries, err := m.store.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	var timeline []TimelineEntry
+	for _, e := range entries {
+		if opts.Collection != "" && e.Collection != opts.Collection {
+			continue
+		}
+		t := e.ModifiedAt
+		if e.Date != "" {
+			if d, err := time.Parse(store.DateLayout, e.Date); err == nil {
+				t = d
+			}
+		}
+		if !inRange(t) {
+			continue
+		}
+		timeline = append(timeline, TimelineEntry{
+			Kind:       TimelineDocument,
+			Time:       t,
+			Title:      e.Title,
+			DocID:      e.DocID,
+			Collection: e.Collection,
+			Path:       e.Path,
+		})
+	}
+
+	if !opts.NoMemories {
+		types := opts.MemoryTypes
+		if len(types) == 0 {
+			types = []MemoryType{MemoryTypeEpisodic}
+		}
+		for _, memType := range types {
+			memories, err := m.ListMemories(MemoryListOptions{Type: memType})
+			if err != nil {
+				return nil, err
+			}
+			for _, mem := range memories {
+				if !inRange(mem.Timestamp) {
+					continue
+				}
+				timeline = append(timeline, TimelineEntry{
+					Kind:       TimelineMemory,
+					Time:       mem.Timestamp,
+					Snippet:    mem.Content,
+					MemoryID:   mem.ID,
+					MemoryType: mem.Type,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+	if opts.Limit > 0 && len(timeline) > opts.Limit {
+		timeline = timeline[len(timeline)-opts.Limit:]
+	}
+
+	// 截断后再读取文档内容，避免加载整个集合
+	snippetLen := opts.SnippetLen
+	if snippetLen <= 0 {
+		snippetLen = 160
+	}
+	for i := range timeline {
+		entry := &timeline[i]
+		if entry.Kind == TimelineDocument {
+			doc, err := m.GetDocumentByPath(entry.Collection + "/" + entry.Path)
+			if err != nil {
+				return nil, err
+			}
+			entry.Snippet = timelineSnippet(doc.Content)
+		}
+		entry.Snippet = truncateRunes(collapseSpaces(entry.Snippet), snippetLen)
+	}
+
+	return timeline, nil
+}
+
+// timelineSnippet 去掉 frontmatter 和标题行，取正文开头
+func timelineSnippet(content string) string {
+	if strings.HasPrefix(content, "---") {
+		if end := strings.Index(content[3:], "\n---"); end >= 0 {
+			content = content[3+end+len("\n---"):]
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) >= 5 {
+			break
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// collapseSpaces 合并连续空白
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
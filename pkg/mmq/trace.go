@@ -0,0 +1,147 @@
+package mmq
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dyike/mmq/pkg/rag"
+)
+
+// SearchTrace 一次搜索的完整检索过程，可整体写入文件附在问题报告中
+// 只包含模型文件名，不包含数据库和模型的本地路径
+type SearchTrace struct {
+	SchemaVersion int            `json:"schema_version"`
+	Version       string         `json:"mmq_version,omitempty"` // 由调用方填写
+	CreatedAt     time.Time      `json:"created_at"`
+	Query         string         `json:"query"` // 原始查询（pre_retrieval 钩子改写前）
+	Settings      TraceSettings  `json:"settings"`
+	Retrieval     *rag.Trace     `json:"retrieval"`
+	Results       []SearchResult `json:"results"` // 后处理之后返回给调用方的结果
+}
+
+// TraceSettings 本次检索实际生效的参数和配置
+type TraceSettings struct {
+	Strategy            RetrievalStrategy    `json:"strategy"`
+	Pipeline            string               `json:"pipeline,omitempty"`
+	Instruction         string               `json:"instruction,omitempty"`
+	Limit               int                  `json:"limit"`
+	MinScore            float64              `json:"min_score,omitempty"`
+	Collection          string               `json:"collection,omitempty"`
+	Language            string               `json:"language,omitempty"`
+	Rerank              bool                 `json:"rerank"`
+	ExpandQuery         bool                 `json:"expand_query"`
+	RRFWeights          []float64            `json:"rrf_weights,omitempty"`
+	RRFK                int                  `json:"rrf_k"`
+	CandidateMultiplier float64              `json:"candidate_multiplier"`
+	RerankLimit         int                  `json:"rerank_limit"`
+	Normalize           string               `json:"normalize"`
+	RerankBlend         RerankBlend          `json:"rerank_blend"`
+	AccessBoost         float64              `json:"access_boost,omitempty"`
+	Timeout             time.Duration        `json:"timeout,omitempty"`
+	EmbeddingModel      string               `json:"embedding_model,omitempty"`
+	RerankModel         string               `json:"rerank_model,omitempty"`
+	GenerateModel       string               `json:"generate_model,omitempty"`
+	ChunkSize           int                  `json:"chunk_size"`
+	ChunkOverlap        int                  `json:"chunk_overlap"`
+	ChunkRules          map[string]ChunkRule `json:"chunk_rules,omitempty"` // 按扩展名覆盖的分块参数
+}
+
+// TraceSearch 执行一次搜索（同 Search）并记录完整检索过程：
+// 查询扩展、各路候选及原始分数、RRF 融合表、重排分数、最终结果和生效配置
+// 远端集合的检索过程不在追踪范围内，只检索本地数据库
+func (m *MMQ) TraceSearch(query string, opts SearchOptions) (*SearchTrace, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = StrategyFTS
+	}
+	opts.Limit = normalizeSearchLimit(opts.Limit)
+	if opts.Cluster > 0 {
+		return nil, fmt.Errorf("tracing does not support the cluster filter")
+	}
+
+	ragOpts, err := m.ragOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	rewritten, err := m.preRetrieval(query)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts, trace, err := m.retriever.RetrieveWithTrace(rewritten, ragOpts)
+	if err != nil {
+		return nil, err
+	}
+	if rewritten != query {
+		trace.Notes = append(trace.Notes, fmt.Sprintf("pre_retrieval hook rewrote the query to %q", rewritten))
+	}
+	if remotes, err := m.store.RemoteCollections(); err == nil && len(remotes) > 0 {
+		trace.Notes = append(trace.Notes, fmt.Sprintf("%d remote collection(s) not traced", len(remotes)))
+	}
+	m.store.RecordQuery(rewritten)
+
+	results, err := m.postProcess(rewritten, m.boostAccessed(convertContextsToSearchResults(contexts)))
+	if err != nil {
+		return nil, err
+	}
+
+	rrfK := ragOpts.RRFK
+	if rrfK == 0 {
+		rrfK = 60
+	}
+	return &SearchTrace{
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Query:         query,
+		Settings: TraceSettings{
+			Strategy:            RetrievalStrategy(ragOpts.Strategy),
+			Pipeline:            opts.Pipeline,
+			Instruction:         opts.Instruction,
+			Limit:               opts.Limit,
+			MinScore:            opts.MinScore,
+			Collection:          opts.Collection,
+			Language:            opts.Language,
+			Rerank:              opts.Rerank,
+			ExpandQuery:         opts.ExpandQuery,
+			RRFWeights:          opts.RRFWeights,
+			RRFK:                rrfK,
+			CandidateMultiplier: ragOpts.CandidateMultiplier,
+			RerankLimit:         ragOpts.RerankLimit,
+			Normalize:           string(ragOpts.Normalize),
+			RerankBlend:         m.RerankBlend(),
+			AccessBoost:         m.cfg.AccessBoost,
+			Timeout:             opts.Timeout,
+			EmbeddingModel:      modelName(m.cfg.EmbeddingModel),
+			RerankModel:         modelName(m.cfg.RerankModel),
+			GenerateModel:       modelName(m.cfg.GenerateModel),
+			ChunkSize:           m.cfg.ChunkSize,
+			ChunkOverlap:        m.cfg.ChunkOverlap,
+			ChunkRules:          m.cfg.ChunkRules,
+		},
+		Retrieval: trace,
+		Results:   results,
+	}, nil
+}
+
+// Timings 各阶段耗时
+func (t *SearchTrace) Timings() QueryTimings {
+	stages := t.Retrieval.Timings
+	return QueryTimings{
+		Expand:   stages[rag.StageExpand],
+		Embed:    stages[rag.StageEmbed],
+		FTS:      stages[rag.StageFTS],
+		Vector:   stages[rag.StageVector],
+		Fusion:   stages[rag.StageFusion],
+		Rerank:   stages[rag.StageRerank],
+		Retrieve: t.Retrieval.Total,
+		Total:    t.Retrieval.Total,
+	}
+}
+
+// modelName 只保留模型文件名，避免泄露本地目录
+func modelName(model string) string {
+	if model == "" {
+		return ""
+	}
+	return filepath.Base(model)
+}
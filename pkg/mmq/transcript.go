@@ -0,0 +1,100 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// Transcript 对话会话的完整记录：各轮问答、引用的文档和从会话提取的记忆
+type Transcript struct {
+	SessionID string           `json:"session_id"`
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Turns     []TranscriptTurn `json:"turns"`
+	Citations []Citation       `json:"citations,omitempty"` // 按首次引用的顺序编号（从 1 开始）
+	Memories  []Memory         `json:"memories,omitempty"`  // 从该会话自动提取的记忆
+}
+
+// TranscriptTurn 一轮问答
+type TranscriptTurn struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Assistant string    `json:"assistant"`
+	Citations []int     `json:"citations,omitempty"` // 本轮注入上下文的文档在 Transcript.Citations 中的编号
+}
+
+// Citation 对话引用的文档
+type Citation struct {
+	DocID      string `json:"docid"`
+	Collection string `json:"collection,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Missing    bool   `json:"missing,omitempty"` // 文档已删除或内容已变化
+}
+
+// SessionTranscript 返回会话的完整记录，会话不存在时返回错误
+func (m *MMQ) SessionTranscript(sessionID string) (*Transcript, error) {
+	memories, err := m.store.GetSessionMemories(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	t := &Transcript{SessionID: sessionID}
+	var entries []store.DocumentListEntry // 第一次遇到引用时加载
+	loaded := false
+	citationIndex := make(map[string]int)
+	for _, mem := range memories {
+		if MemoryType(mem.Type) != MemoryTypeConversation {
+			t.Memories = append(t.Memories, Memory{
+				ID:         mem.ID,
+				Type:       MemoryType(mem.Type),
+				Content:    mem.Content,
+				Metadata:   mem.Metadata,
+				Tags:       mem.Tags,
+				Timestamp:  mem.Timestamp,
+				ExpiresAt:  mem.ExpiresAt,
+				Importance: mem.Importance,
+			})
+			continue
+		}
+
+		turn := TranscriptTurn{Timestamp: mem.Timestamp}
+		turn.User, _ = mem.Metadata["user_msg"].(string)
+		turn.Assistant, _ = mem.Metadata["assistant_msg"].(string)
+		for _, docID := range memorySourceDocIDs(mem.Metadata) {
+			n, ok := citationIndex[docID]
+			if !ok {
+				if !loaded {
+					if entries, err = m.store.Catalog(); err != nil {
+						return nil, fmt.Errorf("failed to load documents: %w", err)
+					}
+					loaded = true
+				}
+				t.Citations = append(t.Citations, citationFor(docID, entries))
+				n = len(t.Citations)
+				citationIndex[docID] = n
+			}
+			turn.Citations = append(turn.Citations, n)
+		}
+		t.Turns = append(t.Turns, turn)
+	}
+	if len(t.Turns) == 0 {
+		return nil, fmt.Errorf("session %q has no conversation turns", sessionID)
+	}
+	t.Start = t.Turns[0].Timestamp
+	t.End = t.Turns[len(t.Turns)-1].Timestamp
+	return t, nil
+}
+
+// citationFor 按 docid 查找当前内容匹配的文档（多个匹配时取第一个）
+func citationFor(docID string, entries []store.DocumentListEntry) Citation {
+	for _, e := range entries {
+		if strings.HasPrefix(e.Hash, docID) {
+			return Citation{DocID: docID, Collection: e.Collection, Path: e.Path, Title: e.Title}
+		}
+	}
+	return Citation{DocID: docID, Missing: true}
+}
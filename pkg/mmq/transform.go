@@ -0,0 +1,220 @@
+package mmq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dyike/mmq/pkg/s3"
+)
+
+// defaultTransformTimeout 外部转换命令的默认超时
+const defaultTransformTimeout = 30 * time.Second
+
+// ContentTransform 源文件内容转换：索引读取时 Decode（如解密）后再处理，
+// 写回文件（导出对话记录）时 Encode（如加密）。明文只在内存和数据库中，不写入磁盘
+type ContentTransform struct {
+	Name  string
+	Match string // 路径 glob（如 **/*.age），匹配集合内的相对路径；写回集合外的文件时匹配目标路径
+	// Decode 把源文件内容转换为可索引的文本
+	Decode func(path string, data []byte) ([]byte, error)
+	// Encode 写回前转换内容，为空时匹配的路径不能写回
+	Encode func(path string, data []byte) ([]byte, error)
+}
+
+// TransformRule 配置文件中的内容转换规则，内容经 stdin 传给外部命令（如 age、gpg），从 stdout 读回
+type TransformRule struct {
+	Name    string   `json:"name"`
+	Match   string   `json:"match"`
+	Decode  []string `json:"decode"`
+	Encode  []string `json:"encode,omitempty"`
+	Timeout string   `json:"timeout,omitempty"` // 单次调用超时（默认 30s）
+}
+
+// transform 将规则转换为内容转换
+func (r TransformRule) transform() (ContentTransform, error) {
+	if r.Match == "" || !doublestar.ValidatePattern(r.Match) {
+		return ContentTransform{}, fmt.Errorf("transform %s: invalid match pattern %q", r.Name, r.Match)
+	}
+	if len(r.Decode) == 0 {
+		return ContentTransform{}, fmt.Errorf("transform %s: decode command is required", r.Name)
+	}
+	timeout := defaultTransformTimeout
+	if r.Timeout != "" {
+		d, err := ParseDuration(r.Timeout)
+		if err != nil {
+			return ContentTransform{}, fmt.Errorf("transform %s: invalid timeout: %w", r.Name, err)
+		}
+		timeout = d
+	}
+
+	t := ContentTransform{Name: r.Name, Match: r.Match, Decode: commandTransform(r.Name, r.Decode, timeout)}
+	if len(r.Encode) > 0 {
+		t.Encode = commandTransform(r.Name, r.Encode, timeout)
+	}
+	return t, nil
+}
+
+// commandTransform 运行外部命令转换内容，命令参数中的 ~ 展开为主目录
+func commandTransform(name string, command []string, timeout time.Duration) func(string, []byte) ([]byte, error) {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		if strings.HasPrefix(arg, "~") {
+			arg = expandPath(arg)
+		}
+		args[i] = arg
+	}
+
+	return func(path string, data []byte) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("transform %s timed out after %s", name, timeout)
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("transform %s failed: %w: %s", name, err, msg)
+			}
+			return nil, fmt.Errorf("transform %s failed: %w", name, err)
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// AddContentTransform 注册内容转换，按注册顺序取第一个匹配路径的转换（配置文件中的规则先注册）
+func (m *MMQ) AddContentTransform(t ContentTransform) error {
+	if t.Match == "" || !doublestar.ValidatePattern(t.Match) {
+		return fmt.Errorf("transform %s: invalid match pattern %q", t.Name, t.Match)
+	}
+	if t.Decode == nil {
+		return fmt.Errorf("transform %s: decode function is required", t.Name)
+	}
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+	m.transforms = append(m.transforms, t)
+	return nil
+}
+
+// setupTransforms 注册配置文件中的内容转换规则
+func (m *MMQ) setupTransforms() error {
+	for i, rule := range m.cfg.Transforms {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("transform-%d", i+1)
+		}
+		t, err := rule.transform()
+		if err != nil {
+			return err
+		}
+		if err := m.AddContentTransform(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformFor 返回匹配路径的内容转换
+func (m *MMQ) transformFor(path string) *ContentTransform {
+	m.hooksMu.RLock()
+	defer m.hooksMu.RUnlock()
+	path = filepath.ToSlash(path)
+	for i := range m.transforms {
+		if ok, _ := doublestar.Match(m.transforms[i].Match, path); ok {
+			t := m.transforms[i]
+			return &t
+		}
+	}
+	return nil
+}
+
+// decodeContent 按匹配的转换解码源文件内容，没有匹配时原样返回
+func decodeContent(t *ContentTransform, path string, data []byte) ([]byte, error) {
+	if t == nil {
+		return data, nil
+	}
+	decoded, err := t.Decode(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return decoded, nil
+}
+
+// writeTransform 返回写回路径匹配的内容转换
+// 目标在集合目录下时与索引一样按集合内的相对路径匹配，再按目标路径本身匹配，任一匹配即使用
+func (m *MMQ) writeTransform(path string) (*ContentTransform, error) {
+	rel, err := m.collectionRelPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if rel != "" {
+		if t := m.transformFor(rel); t != nil {
+			return t, nil
+		}
+	}
+	return m.transformFor(path), nil
+}
+
+// collectionRelPath 返回文件在包含它的集合目录中的相对路径（嵌套时取最深的集合），不在任何集合中时为空
+func (m *MMQ) collectionRelPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	colls, err := m.store.ListCollections()
+	if err != nil {
+		return "", err
+	}
+	var rel string
+	depth := -1
+	for _, c := range colls {
+		if c.Remote != "" || c.Path == "" || s3.IsURL(c.Path) {
+			continue
+		}
+		root, err := filepath.Abs(c.Path)
+		if err != nil {
+			continue
+		}
+		r, err := filepath.Rel(root, abs)
+		if err != nil || r == "." || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if d := len(root); d > depth {
+			rel, depth = filepath.ToSlash(r), d
+		}
+	}
+	return rel, nil
+}
+
+// WriteFile 写回文件：路径匹配内容转换时先 Encode（如加密），明文不落盘
+// 匹配的转换没有 Encode 时拒绝写入
+func (m *MMQ) WriteFile(path string, data []byte, perm os.FileMode) error {
+	t, err := m.writeTransform(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve transform for %s: %w", path, err)
+	}
+	if t != nil {
+		if t.Encode == nil {
+			return fmt.Errorf("transform %s has no encode step: refusing to write plaintext to %s", t.Name, path)
+		}
+		encoded, err := t.Encode(path, data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		data = encoded
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,6 +1,12 @@
 package mmq
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dyike/mmq/pkg/pii"
+)
 
 // RetrievalStrategy 检索策略类型
 type RetrievalStrategy string
@@ -12,6 +18,8 @@ const (
 	StrategyVector RetrievalStrategy = "vector"
 	// StrategyHybrid 混合搜索+重排（最佳质量）
 	StrategyHybrid RetrievalStrategy = "hybrid"
+	// StrategyAuto 使用集合自测（BenchCollection）推荐的策略，未自测或未指定集合时混合检索
+	StrategyAuto RetrievalStrategy = "auto"
 )
 
 // MemoryType 记忆类型
@@ -31,6 +39,7 @@ const (
 // SearchResult 搜索结果
 type SearchResult struct {
 	ID         string                 `json:"id"`
+	DocID      string                 `json:"docid,omitempty"` // 短docid（前6位哈希）
 	Score      float64                `json:"score"`
 	Title      string                 `json:"title"`
 	Content    string                 `json:"content"`
@@ -38,8 +47,12 @@ type SearchResult struct {
 	Source     string                 `json:"source"` // "fts", "vector", "hybrid"
 	Collection string                 `json:"collection"`
 	Path       string                 `json:"path"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Language   string                 `json:"language,omitempty"`
+	Date       string                 `json:"date,omitempty"` // 文档日期（frontmatter 或路径中解析，YYYY-MM-DD）
+	Metadata   map[string]interface{} `json:"metadata,omitempty" xml:"-"`
 	Timestamp  time.Time              `json:"timestamp"`
+
+	CorpusVersion int64 `json:"corpus_version,omitempty" xml:",omitempty"` // 检索时的语料版本，传给 SearchOptions.CorpusVersion 可固定证据集
 }
 
 // Context RAG上下文
@@ -47,19 +60,99 @@ type Context struct {
 	Text      string                 `json:"text"`
 	Source    string                 `json:"source"`
 	Relevance float64                `json:"relevance"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" xml:"-"`
 }
 
 // Memory 记忆
 type Memory struct {
-	ID         string                 `json:"id"`
-	Type       MemoryType             `json:"type"`
-	Content    string                 `json:"content"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty"`
-	Tags       []string               `json:"tags,omitempty"`
-	Timestamp  time.Time              `json:"timestamp"`
-	ExpiresAt  *time.Time             `json:"expires_at,omitempty"` // 可选过期时间
-	Importance float64                `json:"importance"`           // 重要性权重 0.0-1.0
+	ID          string                 `json:"id"`
+	Type        MemoryType             `json:"type"`
+	Content     string                 `json:"content"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty" xml:"-"`
+	Tags        []string               `json:"tags,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"` // 可选过期时间
+	Importance  float64                `json:"importance"`           // 重要性权重 0.0-1.0
+	Relevance   float64                `json:"relevance,omitempty"`  // 回忆时的相关度（含衰减和加权）
+	TTL         time.Duration          `json:"ttl,omitempty"`        // 存活时长，ExpiresAt 为空时换算为过期时间
+	AccessCount int                    `json:"access_count"`         // 被回忆的次数
+}
+
+// MemorySort 记忆列表排序方式
+type MemorySort string
+
+const (
+	// MemorySortRecency 按时间倒序
+	MemorySortRecency MemorySort = "recency"
+	// MemorySortImportance 按重要性倒序
+	MemorySortImportance MemorySort = "importance"
+	// MemorySortAccess 按访问次数倒序
+	MemorySortAccess MemorySort = "access"
+)
+
+// MemoryReviewAction 记忆审阅动作
+type MemoryReviewAction string
+
+const (
+	// MemoryReviewKeep 保留
+	MemoryReviewKeep MemoryReviewAction = "keep"
+	// MemoryReviewEdit 修改后保留
+	MemoryReviewEdit MemoryReviewAction = "edit"
+	// MemoryReviewDelete 删除
+	MemoryReviewDelete MemoryReviewAction = "delete"
+)
+
+// MemoryListOptions 记忆列表选项
+type MemoryListOptions struct {
+	Type   MemoryType // 过滤记忆类型（为空表示全部）
+	SortBy MemorySort // 排序方式（默认按时间）
+	Limit  int        // 返回数量（0 表示不限制）
+	Offset int        // 分页偏移
+}
+
+// MemoryDedupeOptions 记忆去重选项
+type MemoryDedupeOptions struct {
+	Threshold float64    // 判定为重复的余弦相似度（默认 0.92）
+	Type      MemoryType // 只检查该类型（为空表示全部）
+}
+
+// MemoryDuplicateGroup 一组近似重复的记忆及合并方案
+type MemoryDuplicateGroup struct {
+	Keep       Memory    `json:"keep"`       // 保留的记忆（重要性最高）
+	Merge      []Memory  `json:"merge"`      // 合并后删除的记忆
+	Similarity []float64 `json:"similarity"` // Merge 中各记忆与 Keep 的相似度
+	Tags       []string  `json:"tags"`       // 合并后的标签（并集）
+	Importance float64   `json:"importance"` // 合并后的重要性
+}
+
+// 常用的记忆存活时长策略
+const (
+	// TTLSession 会话级记忆，一天后过期
+	TTLSession = 24 * time.Hour
+	// TTLSprint 迭代级记忆，两周后过期
+	TTLSprint = 14 * 24 * time.Hour
+)
+
+// ExpiresIn 返回从现在起 ttl 后的过期时间，ttl<=0 返回 nil（不过期）
+func ExpiresIn(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	t := time.Now().Add(ttl)
+	return &t
+}
+
+// expiresAt 计算记忆的过期时间：显式 ExpiresAt 优先，否则由 TTL 从记忆时间起换算
+func (mem Memory) expiresAt() *time.Time {
+	if mem.ExpiresAt != nil || mem.TTL <= 0 {
+		return mem.ExpiresAt
+	}
+	base := mem.Timestamp
+	if base.IsZero() {
+		base = time.Now()
+	}
+	t := base.Add(mem.TTL)
+	return &t
 }
 
 // Document 文档
@@ -69,19 +162,63 @@ type Document struct {
 	Path       string                 `json:"path"`
 	Title      string                 `json:"title"`
 	Content    string                 `json:"content"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Language   string                 `json:"language,omitempty"` // 为空时索引时自动检测
+	Date       string                 `json:"date,omitempty"`     // 文档日期 YYYY-MM-DD，为空时从 frontmatter 或路径解析
+	Metadata   map[string]interface{} `json:"metadata,omitempty" xml:"-"`
 	CreatedAt  time.Time              `json:"created_at"`
 	ModifiedAt time.Time              `json:"modified_at"`
 }
 
+// PIIFinding 一个文档或记忆中发现的 PII
+type PIIFinding struct {
+	Source     string      `json:"source"` // "document" 或 "memory"
+	ID         string      `json:"id"`     // 文档 docid 或记忆 ID
+	Collection string      `json:"collection,omitempty"`
+	Path       string      `json:"path,omitempty"`
+	Matches    []pii.Match `json:"matches"`
+}
+
+// PIIScanOptions PII 审计选项
+type PIIScanOptions struct {
+	Collection string // 仅扫描指定集合（为空表示全部）
+	Documents  bool   // 扫描已索引文档
+	Memories   bool   // 扫描记忆
+}
+
 // RetrieveOptions 检索选项
 type RetrieveOptions struct {
 	Limit       int               // 返回结果数量
 	MinScore    float64           // 最小相关度分数
 	Collection  string            // 集合过滤
+	Language    string            // 语言过滤（如 zh、en）
 	Strategy    RetrievalStrategy // 检索策略
 	Rerank      bool              // 是否使用LLM重排
 	ExpandQuery bool              // 是否使用查询扩展（lex/vec/hyde）
+	RRFWeights  []float64         // 混合检索的 RRF 权重（fts, vector），空为 [1, 1]
+	RRFK        int               // RRF 参数 k（0 为 60）
+
+	CandidateMultiplier float64 // 每路召回 Limit×倍数 个候选（0 使用配置）
+	RerankLimit         int     // 重排的候选上限（0 使用配置）
+	Normalize           string  // 分数归一化：raw、minmax、calibrated（空使用配置）
+
+	Timeout time.Duration // 检索时长预算（0 不限制），超时跳过扩展/重排并标记 degraded
+
+	Context context.Context // 取消时停止检索并返回 ctx.Err()（为空不可取消）
+
+	After  time.Time // 只返回文档日期在此之后（含当天）的文档；没有文档日期时用修改时间
+	Before time.Time // 只返回文档日期在此之前（不含当天）的文档
+
+	Pipeline string // 使用命名的检索流水线（流水线目录中的 YAML），代替 Strategy/ExpandQuery/Rerank/MinScore
+
+	Cluster int // 只返回该聚类中的文档（需要 Collection，见 ClusterCollection）
+
+	Instruction string // 本次查询嵌入使用的指令，代替模型默认的检索指令（如 "Identify the topic of the text" 用于聚类）
+
+	PostFilter func(Context) bool // 融合和重排之后、截断前调用，返回 false 的结果被丢弃（表达式见 ParseFilter）
+
+	CorpusVersion int64 // 固定到该语料版本（见 MMQ.CorpusVersion）：之后新增、修改或删除过的文档不出现在结果中；0 使用当前版本
+
+	Within string // 只在该命名结果集（见 SaveResultSet）的文档中检索
 }
 
 // SearchOptions 搜索选项
@@ -89,9 +226,35 @@ type SearchOptions struct {
 	Limit       int               // 返回结果数量
 	MinScore    float64           // 最小分数
 	Collection  string            // 集合过滤
+	Language    string            // 语言过滤（如 zh、en）
 	Strategy    RetrievalStrategy // 检索策略
 	Rerank      bool              // 是否使用LLM重排
 	ExpandQuery bool              // 是否使用查询扩展（lex/vec/hyde）
+	RRFWeights  []float64         // 混合检索的 RRF 权重（fts, vector），空为 [1, 1]
+	RRFK        int               // RRF 参数 k（0 为 60）
+
+	CandidateMultiplier float64 // 每路召回 Limit×倍数 个候选（0 使用配置）
+	RerankLimit         int     // 重排的候选上限（0 使用配置）
+	Normalize           string  // 分数归一化：raw、minmax、calibrated（空使用配置）
+
+	Timeout time.Duration // 检索时长预算（0 不限制），超时跳过扩展/重排并标记 degraded
+
+	Context context.Context // 取消时停止检索并返回 ctx.Err()（为空不可取消）
+
+	After  time.Time // 只返回文档日期在此之后（含当天）的文档；没有文档日期时用修改时间
+	Before time.Time // 只返回文档日期在此之前（不含当天）的文档
+
+	Pipeline string // 使用命名的检索流水线（流水线目录中的 YAML），代替 Strategy/ExpandQuery/Rerank/MinScore
+
+	Cluster int // 只返回该聚类中的文档（需要 Collection，见 ClusterCollection）
+
+	Instruction string // 本次查询嵌入使用的指令，代替模型默认的检索指令（如 "Identify the topic of the text" 用于聚类）
+
+	PostFilter func(Context) bool // 融合和重排之后、截断前调用，返回 false 的结果被丢弃（表达式见 ParseFilter）
+
+	CorpusVersion int64 // 固定到该语料版本（见 MMQ.CorpusVersion）：之后新增、修改或删除过的文档不出现在结果中；0 使用当前版本
+
+	Within string // 只在该命名结果集（见 SaveResultSet）的文档中检索
 }
 
 // IndexOptions 索引选项
@@ -99,25 +262,126 @@ type IndexOptions struct {
 	Mask       string // Glob模式，如 "**/*.md"
 	Recursive  bool   // 是否递归
 	Collection string // 集合名称
+	Workers    int    // 并发读取/哈希的 worker 数（默认 CPU 核数）
+}
+
+// IndexFileError 索引失败的文件
+type IndexFileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// IndexPIIFile 含有 PII 的文件
+type IndexPIIFile struct {
+	Path    string `json:"path"`
+	Summary string `json:"summary"` // 如 "email×2, phone×1"
+}
+
+// IndexSummary 目录索引结果
+type IndexSummary struct {
+	Collection string           `json:"collection"`
+	Added      int              `json:"added"`     // 新增的文档
+	Updated    int              `json:"updated"`   // 内容变化的文档
+	Unchanged  int              `json:"unchanged"` // 未变化的文档
+	Skipped    int              `json:"skipped"`   // 不匹配 mask 的文件
+	Removed    int              `json:"removed"`   // 已从目录删除而被停用的文档
+	Errors     []IndexFileError `json:"errors,omitempty"`
+	PIIPolicy  string           `json:"pii_policy"`
+	PIIFiles   []IndexPIIFile   `json:"pii_files,omitempty"`
+	IndexMode  string           `json:"index_mode,omitempty"` // summary 时只保存了摘要
+}
+
+// Indexed 成功写入的文档数
+func (s *IndexSummary) Indexed() int {
+	return s.Added + s.Updated + s.Unchanged
+}
+
+// EmbedOptions 嵌入生成选项
+type EmbedOptions struct {
+	Resume bool // 从上次中断处继续，跳过已嵌入的块
+
+	// Context 取消时（如 Ctrl-C）在块之间停止，已完成的块都已保存，之后用 Resume 继续
+	Context context.Context
+	// Progress 每完成一个块、以及模型开始和完成加载时回调；设置后不再输出 "Embedded n/m" 日志
+	Progress func(EmbedProgress)
+}
+
+// 嵌入进度阶段
+const (
+	EmbedStageLoading = "loading" // 开始加载嵌入模型（首个块之前）
+	EmbedStageLoaded  = "loaded"  // 模型加载完成并生成了首个块
+	EmbedStageChunk   = "chunk"   // 完成一个块
+	EmbedStageFailed  = "failed"  // 文档失败，剩余块计为已处理
+)
+
+// EmbedProgress 嵌入进度
+type EmbedProgress struct {
+	Stage       string        `json:"stage"`
+	Model       string        `json:"model"`
+	Path        string        `json:"path"`         // 当前文档
+	Documents   int           `json:"documents"`    // 已处理文档数
+	TotalDocs   int           `json:"total_docs"`   // 待处理文档数
+	Chunks      int           `json:"chunks"`       // 已处理块数（含续传跳过和失败文档的剩余块）
+	TotalChunks int           `json:"total_chunks"` // 待处理块数
+	Elapsed     time.Duration `json:"elapsed"`
+	Rate        float64       `json:"rate"`                // 块/秒，只统计本次生成的块，不含模型加载时间
+	ETA         time.Duration `json:"eta"`                 // 0 表示尚无法估计
+	LoadTime    time.Duration `json:"load_time,omitempty"` // Stage 为 loaded 时的模型加载耗时
+}
+
+// EmbedFailure 嵌入失败的文档
+type EmbedFailure struct {
+	Hash  string `json:"hash"`
+	Path  string `json:"path"`
+	Chunk int    `json:"chunk"`
+	Error string `json:"error"`
 }
 
+// EmbedReport 嵌入生成报告
+type EmbedReport struct {
+	Documents     int            `json:"documents"`      // 待处理文档数
+	Embedded      int            `json:"embedded"`       // 完成的文档数
+	Resumed       int            `json:"resumed"`        // 从断点继续的文档数
+	Chunks        int            `json:"chunks"`         // 新生成的块数
+	SkippedChunks int            `json:"skipped_chunks"` // 续传时跳过的块数
+	FailedChunks  int            `json:"failed_chunks"`  // 失败文档中未嵌入的块数
+	Failed        []EmbedFailure `json:"failed,omitempty"`
+	Interrupted   bool           `json:"interrupted,omitempty"` // 被取消，未处理完所有文档
+	Pending       int            `json:"pending,omitempty"`     // 中断时尚未处理的文档数
+	Elapsed       time.Duration  `json:"elapsed"`
+}
+
+// ErrCollectionNotFound 集合不存在（StrictCollections 模式下索引到未知集合）
+var ErrCollectionNotFound = errors.New("collection not found")
+
 // Status 索引状态
 type Status struct {
-	TotalDocuments int      `json:"total_documents"`
-	NeedsEmbedding int      `json:"needs_embedding"`
-	Collections    []string `json:"collections"`
-	DBPath         string   `json:"db_path"`
-	CacheDir       string   `json:"cache_dir"`
+	TotalDocuments int        `json:"total_documents"`
+	NeedsEmbedding int        `json:"needs_embedding"`
+	Collections    []string   `json:"collections"`
+	DBPath         string     `json:"db_path"`
+	CacheDir       string     `json:"cache_dir"`
+	Quota          QuotaUsage `json:"quota"`
+	CorpusVersion  int64      `json:"corpus_version,omitempty"` // 语料版本，每次文档变更递增
+
+	// Vectors 向量索引统计（仅 verbose 时填充）
+	Vectors *VectorStats `json:"vectors,omitempty"`
+
+	// Freshness 各集合的索引时间和磁盘上的变化（需要扫描目录，由 CollectionFreshness 填充）
+	Freshness []CollectionFreshness `json:"freshness,omitempty"`
 }
 
 // RecallOptions 记忆回忆选项
 type RecallOptions struct {
-	Limit              int           // 返回记忆数量
-	MemoryTypes        []MemoryType  // 过滤记忆类型
-	ApplyDecay         bool          // 是否应用时间衰减
-	DecayHalflife      time.Duration // 衰减半衰期
-	WeightByImportance bool          // 是否按重要性加权
-	MinRelevance       float64       // 最小相关度
+	Limit              int                          // 返回记忆数量
+	MemoryTypes        []MemoryType                 // 过滤记忆类型
+	ApplyDecay         bool                         // 是否应用时间衰减
+	DecayHalflife      time.Duration                // 未配置类型时使用的衰减半衰期
+	TypeHalflives      map[MemoryType]time.Duration // 按类型覆盖衰减半衰期（0 表示不衰减）
+	WeightByImportance bool                         // 是否按重要性加权
+	MinRelevance       float64                      // 最小相关度
+	SessionID          string                       // 当前会话ID（同会话记忆获得加权）
+	SessionBoost       float64                      // 同会话记忆的相关度乘数（0 使用配置值）
 }
 
 // Collection 集合
@@ -128,6 +392,20 @@ type Collection struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	DocCount  int       `json:"doc_count"`
+
+	Metadata map[string]interface{} `json:"metadata,omitempty" xml:"-"` // 自定义元数据
+
+	Remote           string `json:"remote,omitempty"`            // 远端 mmq 数据库，非空时搜索直接查询远端
+	RemoteCollection string `json:"remote_collection,omitempty"` // 远端数据库中的集合名
+}
+
+// CollectionRemoveResult 硬删除集合的结果
+type CollectionRemoveResult struct {
+	Documents  int   `json:"documents"`   // 删除的文档记录
+	Content    int   `json:"content"`     // 删除的不再被引用的内容
+	Vectors    int   `json:"vectors"`     // 删除的分块向量
+	Contexts   int   `json:"contexts"`    // 删除的上下文
+	FreedBytes int64 `json:"freed_bytes"` // 释放的内容和向量数据大小
 }
 
 // CollectionOptions 集合选项
@@ -135,6 +413,19 @@ type CollectionOptions struct {
 	Mask      string // Glob模式，如 "**/*.md"
 	Recursive bool   // 是否递归（默认true）
 	GitPull   bool   // 是否先执行git pull
+	PIIPolicy string // 索引时的 PII 策略（off/flag/redact，为空使用配置默认值）
+	IndexMode string // 索引模式（full/summary，为空保存全文）
+}
+
+// ContextNode 上下文层级树节点（global → collection → path → document）
+type ContextNode struct {
+	Path       string         `json:"path"`
+	Kind       string         `json:"kind"` // global/collection/path/document/pattern
+	Content    string         `json:"content,omitempty"`
+	HasContext bool           `json:"has_context"`
+	Documents  int            `json:"documents"` // 上下文会注入的文档数
+	Uncovered  int            `json:"uncovered"` // 没有任何集合级及以下上下文的文档数
+	Children   []*ContextNode `json:"children,omitempty"`
 }
 
 // ContextEntry 上下文条目
@@ -155,6 +446,12 @@ type DocumentListEntry struct {
 	Hash       string    `json:"hash"`
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
+
+	WordCount      int `json:"word_count"`      // 单词数（中日韩文字按字计）
+	CharCount      int `json:"char_count"`      // 字符数
+	ReadingSeconds int `json:"reading_seconds"` // 预计阅读时间（秒）
+
+	Date string `json:"date,omitempty"` // 文档日期（YYYY-MM-DD），未解析出时为空
 }
 
 // DocumentDetail 文档详情
@@ -168,4 +465,10 @@ type DocumentDetail struct {
 	Hash       string    `json:"hash"`
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
+
+	WordCount      int `json:"word_count"`      // 单词数（中日韩文字按字计）
+	CharCount      int `json:"char_count"`      // 字符数
+	ReadingSeconds int `json:"reading_seconds"` // 预计阅读时间（秒）
+
+	Date string `json:"date,omitempty"` // 文档日期（YYYY-MM-DD），未解析出时为空
 }
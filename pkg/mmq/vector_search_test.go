@@ -1,9 +1,13 @@
 package mmq
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dyike/mmq/pkg/store"
 )
 
 func TestVectorSearch(t *testing.T) {
@@ -218,3 +222,85 @@ func BenchmarkVectorSearch(b *testing.B) {
 		_, _ = m.Search("programming", SearchOptions{Limit: 5, Strategy: StrategyVector})
 	}
 }
+
+func TestVectorPrefilter(t *testing.T) {
+	m := newTestMMQ(t)
+
+	// 每路召回 Limit×2 个候选，向量检索取其 3 倍的分块：Limit 1 需要 6 个全文检索命中才预筛选
+	docs := make(map[string]string)
+	for i := 0; i < 6; i++ {
+		docs[fmt.Sprintf("notes/kiwi-%d.md", i)] = fmt.Sprintf("kiwi note number %d", i)
+		docs[fmt.Sprintf("misc/other-%d.md", i)] = fmt.Sprintf("grocery list number %d", i)
+	}
+	for ref, content := range docs {
+		collection, path, _ := strings.Cut(ref, "/")
+		doc := Document{Collection: collection, Path: path, Title: path, Content: content, CreatedAt: time.Now(), ModifiedAt: time.Now()}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(query string, opts SearchOptions) []string {
+		t.Helper()
+		opts.Strategy = StrategyVector
+		results, err := m.Search(query, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs := make([]string, len(results))
+		for i, r := range results {
+			refs[i] = r.Collection + "/" + r.Path
+		}
+		return refs
+	}
+
+	// 全量扫描的排序作为参照
+	m.store.SetVectorPrefilter(store.VectorPrefilter{})
+	full := search("kiwi", SearchOptions{Limit: 20})
+	fullNotes := search("kiwi", SearchOptions{Limit: 20, Collection: "notes"})
+	if len(full) != len(docs) || len(fullNotes) != 6 {
+		t.Fatalf("full scan returned %v / %v", full, fullNotes)
+	}
+	var lexical []string
+	for _, ref := range full {
+		if strings.Contains(docs[ref], "kiwi") {
+			lexical = append(lexical, ref)
+		}
+	}
+
+	m.store.SetVectorPrefilter(store.VectorPrefilter{MinVectors: 1, MaxCandidates: 100})
+
+	// 集合过滤：计算集合内全部分块，结果与全量扫描一致
+	if got := search("kiwi", SearchOptions{Limit: 20, Collection: "notes"}); strings.Join(got, ",") != strings.Join(fullNotes, ",") {
+		t.Errorf("collection prefilter = %v, want %v", got, fullNotes)
+	}
+
+	// 全文检索预筛选默认关闭，没有过滤条件时全量扫描
+	if got := search("kiwi", SearchOptions{Limit: 1}); strings.Join(got, ",") != strings.Join(full[:1], ",") {
+		t.Errorf("default prefilter = %v, want %v", got, full[:1])
+	}
+
+	m.store.SetVectorPrefilter(store.VectorPrefilter{MinVectors: 1, MaxCandidates: 100, Lexical: true})
+
+	// 全文检索命中足够多时只计算命中文档
+	if got := search("kiwi", SearchOptions{Limit: 1}); len(got) != 1 || got[0] != lexical[0] {
+		t.Errorf("lexical prefilter = %v, want %v", got, lexical[:1])
+	}
+
+	// 命中不足或只有停用词时仍扫描全部向量
+	if got := search("kiwi", SearchOptions{Limit: 2}); strings.Join(got, ",") != strings.Join(full[:2], ",") {
+		t.Errorf("fallback = %v, want %v", got, full[:2])
+	}
+	if got := search("the", SearchOptions{Limit: 20}); len(got) != len(docs) {
+		t.Errorf("stopword query = %v", got)
+	}
+
+	// 候选超过上限时不预筛选
+	m.store.SetVectorPrefilter(store.VectorPrefilter{MinVectors: 1, MaxCandidates: 2})
+	if got := search("kiwi", SearchOptions{Limit: 20, Collection: "notes"}); strings.Join(got, ",") != strings.Join(fullNotes, ",") {
+		t.Errorf("capped prefilter = %v, want %v", got, fullNotes)
+	}
+}
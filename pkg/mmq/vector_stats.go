@@ -0,0 +1,58 @@
+package mmq
+
+// ANNRecommendThreshold 向量数超过该值时暴力搜索延迟明显，建议启用 ANN
+const ANNRecommendThreshold = 100000
+
+// VectorStats 向量索引统计
+type VectorStats struct {
+	Vectors        int                     `json:"vectors"`
+	Dimensions     []int                   `json:"dimensions"`
+	BytesOnDisk    int64                   `json:"bytes_on_disk"`
+	EstimatedRAM   int64                   `json:"estimated_ram"` // 暴力搜索需要加载的向量内存
+	ANNRecommended bool                    `json:"ann_recommended"`
+	Spaces         []VectorSpaceStats      `json:"spaces,omitempty"`
+	Collections    []CollectionVectorStats `json:"collections,omitempty"`
+}
+
+// VectorSpaceStats 单个模型/维度的向量统计
+type VectorSpaceStats struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+	Vectors    int    `json:"vectors"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// CollectionVectorStats 单个集合的向量统计
+type CollectionVectorStats struct {
+	Collection string `json:"collection"`
+	Model      string `json:"model"`
+	Documents  int    `json:"documents"`
+	Vectors    int    `json:"vectors"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// VectorStats 返回向量数量、维度、磁盘占用、暴力搜索内存估算及按集合的细分
+func (m *MMQ) VectorStats() (*VectorStats, error) {
+	st, err := m.store.VectorStats()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &VectorStats{
+		Vectors:        st.Vectors,
+		Dimensions:     st.Dimensions,
+		BytesOnDisk:    st.BytesOnDisk,
+		EstimatedRAM:   st.EstimatedRAM,
+		ANNRecommended: st.Vectors >= ANNRecommendThreshold,
+	}
+	for _, sp := range st.Spaces {
+		stats.Spaces = append(stats.Spaces, VectorSpaceStats(sp))
+	}
+	for _, cs := range st.Collections {
+		if cs.Model == "" {
+			cs.Model = m.cfg.EmbeddingModel
+		}
+		stats.Collections = append(stats.Collections, CollectionVectorStats(cs))
+	}
+	return stats, nil
+}
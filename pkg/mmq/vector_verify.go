@@ -0,0 +1,135 @@
+package mmq
+
+import (
+	"sort"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// 向量检查发现的问题
+const (
+	VectorIssueMissing    = store.VectorIssueMissing    // 活跃文档没有预期模型的向量
+	VectorIssueIncomplete = store.VectorIssueIncomplete // 缺少部分分块的向量
+	VectorIssueModel      = store.VectorIssueModel      // 向量由其他模型生成
+	VectorIssueDimension  = store.VectorIssueDimension  // 维度与向量表不一致
+	VectorIssueCorrupt    = store.VectorIssueCorrupt    // 向量数据为空或长度不是 4 的倍数
+	VectorIssueNaN        = store.VectorIssueNaN        // 含 NaN 或无穷大
+	VectorIssueUnindexed  = store.VectorIssueUnindexed  // 向量表中没有对应行，检索不到
+)
+
+// VerifyVectorsOptions 向量完整性检查选项
+type VerifyVectorsOptions struct {
+	// Quarantine 把损坏的向量移到隔离表，并删除受影响文档的其余向量，等待重新嵌入
+	Quarantine bool
+	// Reembed 删除有问题的文档的向量并重新生成（同 mmq embed，也会嵌入其他待嵌入的文档）
+	Reembed bool
+	// Embed 重新嵌入的选项（进度回调、取消）
+	Embed EmbedOptions
+}
+
+// VectorIssue 一个有问题的向量行或文档
+type VectorIssue struct {
+	Kind       string `json:"kind"`
+	DocID      string `json:"docid"`
+	Collection string `json:"collection"`
+	Path       string `json:"path"`
+	Model      string `json:"model"`
+	Chunk      int    `json:"chunk"` // 分块序号，-1 表示整个文档
+	Dimensions int    `json:"dimensions,omitempty"`
+	Detail     string `json:"detail"`
+}
+
+// VectorVerification 向量完整性检查报告
+type VectorVerification struct {
+	Contents    int            `json:"contents"` // 检查的内容数（同一内容在不同模型下分别计）
+	Vectors     int            `json:"vectors"`  // 检查的向量行数
+	Counts      map[string]int `json:"counts,omitempty"`
+	Issues      []VectorIssue  `json:"issues,omitempty"`
+	Quarantined int            `json:"quarantined,omitempty"` // 移入隔离表的向量行数
+	Embed       *EmbedReport   `json:"embed,omitempty"`       // Reembed 时的嵌入报告
+	Remaining   int            `json:"remaining"`             // 修复后仍然存在的问题数（未修复时等于问题数）
+}
+
+// VerifyVectors 检查每个活跃文档的分块都有预期模型和维度的向量，找出损坏的向量数据
+// （长度不是 4 的倍数、含 NaN）和检索不到的向量，按选项隔离或重新嵌入
+func (m *MMQ) VerifyVectors(opts VerifyVectorsOptions) (*VectorVerification, error) {
+	check, err := m.store.CheckVectors(m.cfg.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VectorVerification{Contents: check.Contents, Vectors: check.Vectors, Remaining: len(check.Issues)}
+	for _, issue := range check.Issues {
+		if report.Counts == nil {
+			report.Counts = make(map[string]int)
+		}
+		report.Counts[issue.Kind]++
+		report.Issues = append(report.Issues, m.vectorIssue(issue))
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Collection != b.Collection {
+			return a.Collection < b.Collection
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Chunk < b.Chunk
+	})
+	if len(check.Issues) == 0 || (!opts.Quarantine && !opts.Reembed) {
+		return report, nil
+	}
+
+	if opts.Quarantine {
+		if report.Quarantined, err = m.store.QuarantineVectors(check.Issues); err != nil {
+			return report, err
+		}
+	}
+	if opts.Reembed {
+		// 删除有问题的文档在该模型下的全部向量，重新嵌入时从头生成
+		done := make(map[[2]string]bool)
+		for _, issue := range check.Issues {
+			key := [2]string{issue.Model, issue.Hash}
+			if issue.Kind == VectorIssueMissing || done[key] {
+				continue
+			}
+			done[key] = true
+			if issue.Model == "" {
+				err = m.store.DeleteEmbeddings(issue.Hash)
+			} else {
+				err = m.store.DeleteModelEmbeddings(issue.Model, issue.Hash)
+			}
+			if err != nil {
+				return report, err
+			}
+		}
+		if report.Embed, err = m.EmbedDocuments(opts.Embed); err != nil {
+			return report, err
+		}
+	}
+
+	after, err := m.store.CheckVectors(m.cfg.EmbeddingModel)
+	if err != nil {
+		return report, err
+	}
+	report.Remaining = len(after.Issues)
+	return report, nil
+}
+
+// vectorIssue 转换为对外的问题记录，默认模型填入模型名
+func (m *MMQ) vectorIssue(issue store.VectorIssue) VectorIssue {
+	model := issue.Model
+	if model == "" {
+		model = m.cfg.EmbeddingModel
+	}
+	return VectorIssue{
+		Kind:       issue.Kind,
+		DocID:      shortDocID(issue.Hash),
+		Collection: issue.Collection,
+		Path:       issue.Path,
+		Model:      model,
+		Chunk:      issue.Seq,
+		Dimensions: issue.Dimensions,
+		Detail:     issue.Detail,
+	}
+}
@@ -0,0 +1,276 @@
+package mmq
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+)
+
+// 嵌入地图的着色方式
+const (
+	ColorByCollection = "collection" // 按集合
+	ColorByDir        = "dir"        // 按集合内的顶层目录
+	ColorByDoc        = "doc"        // 每个文档一种颜色
+)
+
+// EmbeddingMapOptions 嵌入地图选项
+type EmbeddingMapOptions struct {
+	Collection string // 只包含该集合（为空表示默认模型的全部集合）
+	Limit      int    // 最多抽取的分块数（默认 5000，<0 表示不限制）
+	ColorBy    string // collection（默认）、dir 或 doc
+}
+
+// EmbeddingMap 分块嵌入的二维投影
+type EmbeddingMap struct {
+	Model     string           `json:"model"`
+	Method    string           `json:"method"`    // 投影方法（pca）
+	Explained [2]float64       `json:"explained"` // 两个主成分解释的方差比例
+	ColorBy   string           `json:"color_by"`
+	Points    []EmbeddingPoint `json:"points"`
+}
+
+// EmbeddingPoint 地图上的一个分块
+type EmbeddingPoint struct {
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Group      string  `json:"group"` // 着色分组
+	Collection string  `json:"collection"`
+	Path       string  `json:"path"`
+	Title      string  `json:"title"`
+	Seq        int     `json:"seq"`
+	Text       string  `json:"text"`
+	Outlier    bool    `json:"outlier,omitempty"` // 与所在分组中心的距离异常大
+}
+
+// EmbeddingMap 用 PCA 把分块向量投影到二维，用于观察语料中的聚类和离群点
+// 集合使用专用嵌入模型时投影该模型的向量；不同模型的向量不能放在同一张图中
+func (m *MMQ) EmbeddingMap(opts EmbeddingMapOptions) (*EmbeddingMap, error) {
+	if opts.ColorBy == "" {
+		opts.ColorBy = ColorByCollection
+	}
+	switch opts.ColorBy {
+	case ColorByCollection, ColorByDir, ColorByDoc:
+	default:
+		return nil, fmt.Errorf("invalid color-by %q (use %s, %s or %s)", opts.ColorBy, ColorByCollection, ColorByDir, ColorByDoc)
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 5000
+	}
+
+	model := ""
+	if opts.Collection != "" {
+		var err error
+		if model, err = m.store.GetCollectionEmbedModel(opts.Collection); err != nil {
+			return nil, err
+		}
+	}
+	chunks, err := m.store.GetChunkEmbeddings(opts.Collection, model, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		model = m.cfg.EmbeddingModel
+	}
+
+	result := &EmbeddingMap{Model: model, Method: "pca", ColorBy: opts.ColorBy, Points: []EmbeddingPoint{}}
+	if len(chunks) == 0 {
+		return result, nil
+	}
+
+	// 只保留与第一个向量维度相同的分块，并归一化（余弦距离）
+	dims := len(chunks[0].Embedding)
+	var vectors [][]float64
+	for _, c := range chunks {
+		if len(c.Embedding) != dims || dims == 0 {
+			continue
+		}
+		vectors = append(vectors, unitVector(c.Embedding))
+		result.Points = append(result.Points, EmbeddingPoint{
+			Group:      pointGroup(opts.ColorBy, c.Collection, c.Path),
+			Collection: c.Collection,
+			Path:       c.Path,
+			Title:      c.Title,
+			Seq:        c.Seq,
+			Text:       collapseSpaces(c.Text),
+		})
+	}
+
+	coords, explained := pca2(vectors)
+	result.Explained = explained
+	for i := range result.Points {
+		result.Points[i].X, result.Points[i].Y = coords[i][0], coords[i][1]
+	}
+	markOutliers(result.Points, vectors)
+	return result, nil
+}
+
+// pointGroup 按着色方式计算分组名
+func pointGroup(colorBy, collection, docPath string) string {
+	switch colorBy {
+	case ColorByDoc:
+		return collection + "/" + docPath
+	case ColorByDir:
+		dir := strings.SplitN(docPath, "/", 2)[0]
+		if dir == docPath {
+			dir = "."
+		}
+		return path.Join(collection, dir)
+	default:
+		return collection
+	}
+}
+
+// unitVector 转为 float64 并归一化到单位长度
+func unitVector(v []float32) []float64 {
+	out := make([]float64, len(v))
+	var norm float64
+	for i, x := range v {
+		out[i] = float64(x)
+		norm += out[i] * out[i]
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for i := range out {
+			out[i] /= norm
+		}
+	}
+	return out
+}
+
+// pca2 用幂迭代求前两个主成分，返回各点坐标和两个成分解释的方差比例
+// 不构造协方差矩阵，内存只与点数×维度成正比
+func pca2(vectors [][]float64) ([][2]float64, [2]float64) {
+	n := len(vectors)
+	coords := make([][2]float64, n)
+	var explained [2]float64
+	if n == 0 {
+		return coords, explained
+	}
+	dims := len(vectors[0])
+
+	mean := make([]float64, dims)
+	for _, v := range vectors {
+		for j, x := range v {
+			mean[j] += x
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(n)
+	}
+	centered := make([][]float64, n)
+	var total float64
+	for i, v := range vectors {
+		c := make([]float64, dims)
+		for j, x := range v {
+			c[j] = x - mean[j]
+			total += c[j] * c[j]
+		}
+		centered[i] = c
+	}
+
+	var components [][]float64
+	for k := 0; k < 2; k++ {
+		// 确定性的初始向量，结果可复现
+		v := make([]float64, dims)
+		for j := range v {
+			v[j] = 1 / float64(j+k+1)
+		}
+		for _, c := range components {
+			orthogonalize(v, c)
+		}
+		normalize(v)
+
+		var eigen float64
+		for iter := 0; iter < 100; iter++ {
+			// v ← Xᵀ(Xv)，并去掉已求出成分方向上的数值误差
+			next := make([]float64, dims)
+			for _, row := range centered {
+				p := dot(row, v)
+				for j, x := range row {
+					next[j] += p * x
+				}
+			}
+			for _, c := range components {
+				orthogonalize(next, c)
+			}
+			if eigen = normalize(next); eigen == 0 {
+				break
+			}
+			v = next
+		}
+		components = append(components, v)
+		if total > 0 {
+			explained[k] = eigen / total
+		}
+	}
+
+	for i, row := range centered {
+		coords[i] = [2]float64{dot(row, components[0]), dot(row, components[1])}
+	}
+	return coords, explained
+}
+
+// markOutliers 标记与所在分组中心的余弦距离超过均值加两倍标准差的点（分组至少 5 个点）
+func markOutliers(points []EmbeddingPoint, vectors [][]float64) {
+	groups := make(map[string][]int)
+	for i, p := range points {
+		groups[p.Group] = append(groups[p.Group], i)
+	}
+
+	for _, idx := range groups {
+		if len(idx) < 5 {
+			continue
+		}
+		centroid := make([]float64, len(vectors[idx[0]]))
+		for _, i := range idx {
+			for j, x := range vectors[i] {
+				centroid[j] += x
+			}
+		}
+		normalize(centroid)
+
+		dist := make([]float64, len(idx))
+		var mean, sq float64
+		for k, i := range idx {
+			dist[k] = 1 - dot(vectors[i], centroid)
+			mean += dist[k]
+		}
+		mean /= float64(len(idx))
+		for _, d := range dist {
+			sq += (d - mean) * (d - mean)
+		}
+		limit := mean + 2*math.Sqrt(sq/float64(len(idx)))
+		for k, i := range idx {
+			if dist[k] > limit {
+				points[i].Outlier = true
+			}
+		}
+	}
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// normalize 归一化并返回原长度
+func normalize(v []float64) float64 {
+	norm := math.Sqrt(dot(v, v))
+	if norm > 0 {
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+	return norm
+}
+
+// orthogonalize 去掉 v 在单位向量 u 上的分量
+func orthogonalize(v, u []float64) {
+	p := dot(v, u)
+	for i := range v {
+		v[i] -= p * u[i]
+	}
+}
@@ -0,0 +1,205 @@
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Policy PII 处理策略
+type Policy string
+
+const (
+	// PolicyOff 不扫描
+	PolicyOff Policy = "off"
+	// PolicyFlag 保留原文，仅报告发现的 PII
+	PolicyFlag Policy = "flag"
+	// PolicyRedact 将 PII 替换为 [REDACTED:类别]
+	PolicyRedact Policy = "redact"
+)
+
+// ParsePolicy 解析策略，空字符串返回 PolicyOff
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyOff, nil
+	case PolicyOff, PolicyFlag, PolicyRedact:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid PII policy: %s (use off, flag or redact)", s)
+	}
+}
+
+// 内置的 PII 类别
+const (
+	KindEmail      = "email"
+	KindPhone      = "phone"
+	KindCNID       = "cn_id"
+	KindSSN        = "ssn"
+	KindCreditCard = "credit_card"
+)
+
+// Match 一处 PII
+type Match struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// pattern 检测模式，validate 用于进一步校验（如 Luhn）
+type pattern struct {
+	kind     string
+	re       *regexp.Regexp
+	validate func(string) bool
+}
+
+// Scanner PII 扫描器
+type Scanner struct {
+	patterns []pattern
+}
+
+// builtinPatterns 内置检测模式（按优先级排列，重叠时先匹配者优先）
+func builtinPatterns() []pattern {
+	return []pattern{
+		{kind: KindEmail, re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+		{kind: KindCNID, re: regexp.MustCompile(`\b[1-9]\d{5}(?:19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`)},
+		{kind: KindCreditCard, re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), validate: luhnValid},
+		{kind: KindSSN, re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+		{kind: KindPhone, re: regexp.MustCompile(`(?:\+\d{1,3}[ -]?)?(?:\b1[3-9]\d{9}\b|\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b)`)},
+	}
+}
+
+// NewScanner 创建扫描器，custom 为额外的 类别名 → 正则
+func NewScanner(custom map[string]string) (*Scanner, error) {
+	s := &Scanner{patterns: builtinPatterns()}
+
+	// 按名称排序保证结果稳定
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		re, err := regexp.Compile(custom[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid PII pattern %s: %w", name, err)
+		}
+		s.patterns = append(s.patterns, pattern{kind: name, re: re})
+	}
+
+	return s, nil
+}
+
+// Scan 扫描文本中的 PII，结果按位置排序且互不重叠
+func (s *Scanner) Scan(text string) []Match {
+	var matches []Match
+	for _, p := range s.patterns {
+		for _, loc := range p.re.FindAllStringIndex(text, -1) {
+			value := text[loc[0]:loc[1]]
+			if p.validate != nil && !p.validate(value) {
+				continue
+			}
+			if overlaps(matches, loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, Match{Kind: p.kind, Value: value, Start: loc[0], End: loc[1]})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+	return matches
+}
+
+// Apply 按策略处理文本，返回处理后的文本和发现的 PII
+func (s *Scanner) Apply(text string, policy Policy) (string, []Match) {
+	if policy == PolicyOff || policy == "" {
+		return text, nil
+	}
+
+	matches := s.Scan(text)
+	if policy != PolicyRedact || len(matches) == 0 {
+		return text, matches
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString("[REDACTED:" + m.Kind + "]")
+		last = m.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String(), matches
+}
+
+// Summarize 按类别统计匹配数量，如 "email×2, phone×1"
+func Summarize(matches []Match) string {
+	counts := make(map[string]int)
+	for _, m := range matches {
+		counts[m.Kind]++
+	}
+
+	kinds := Kinds(matches)
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s×%d", kind, counts[kind])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Kinds 返回匹配中出现的类别（去重，保持首次出现顺序）
+func Kinds(matches []Match) []string {
+	seen := make(map[string]bool)
+	var kinds []string
+	for _, m := range matches {
+		if !seen[m.Kind] {
+			seen[m.Kind] = true
+			kinds = append(kinds, m.Kind)
+		}
+	}
+	return kinds
+}
+
+// overlaps 检查区间是否与已有匹配重叠
+func overlaps(matches []Match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.End && end > m.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid Luhn 校验（信用卡号）
+func luhnValid(s string) bool {
+	var digits []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
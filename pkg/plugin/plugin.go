@@ -0,0 +1,253 @@
+// Package plugin 提供基于子进程的插件：内容提取器、检索后处理器和对话工具
+//
+// 插件放在插件目录的子目录中，由 plugin.json 描述：
+//
+//	{
+//	  "name": "pdf",
+//	  "kind": "extractor",
+//	  "description": "Extract text from PDF files",
+//	  "command": ["./pdf-extract"],
+//	  "extensions": [".pdf"]
+//	}
+//
+// 每次调用启动一次 command（工作目录为插件目录），从 stdin 写入一行 JSON 请求
+// {"method": "...", "params": {...}}，从 stdout 读取 JSON 响应 {"result": ..., "error": "..."}。
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind 插件类型
+type Kind string
+
+const (
+	KindExtractor     Kind = "extractor"     // 把文件转换为可索引的文本
+	KindPostProcessor Kind = "postprocessor" // 检索结果的后处理（过滤、重排、补充）
+	KindTool          Kind = "tool"          // 对话中可调用的工具
+)
+
+// ManifestFile 插件描述文件名
+const ManifestFile = "plugin.json"
+
+// DefaultTimeout 单次调用的默认超时
+const DefaultTimeout = 30 * time.Second
+
+// Manifest 插件描述
+type Manifest struct {
+	Name        string   `json:"name"`
+	Kind        Kind     `json:"kind"`
+	Description string   `json:"description,omitempty"`
+	Command     []string `json:"command"`
+	Extensions  []string `json:"extensions,omitempty"` // extractor 处理的文件扩展名（如 .pdf）
+	Timeout     string   `json:"timeout,omitempty"`    // 单次调用超时（如 10s，默认 30s）
+}
+
+// Plugin 已发现的插件
+type Plugin struct {
+	Manifest
+	Dir string `json:"dir"`
+	Err error  `json:"-"` // 描述文件无效时的错误，此时插件不可用
+
+	timeout time.Duration
+}
+
+// Discover 扫描插件目录，目录不存在时返回空
+// 描述文件无效的插件也会返回（Err 非空），便于列出并提示
+func Discover(dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []Plugin
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pluginDir := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(filepath.Join(pluginDir, ManifestFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		p := Plugin{Dir: pluginDir}
+		if err == nil {
+			err = json.Unmarshal(data, &p.Manifest)
+		}
+		if err == nil {
+			err = p.validate()
+		}
+		if p.Name == "" {
+			p.Name = e.Name()
+		}
+		p.Err = err
+		plugins = append(plugins, p)
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// validate 校验描述文件
+func (p *Plugin) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	switch p.Kind {
+	case KindExtractor:
+		if len(p.Extensions) == 0 {
+			return fmt.Errorf("extractor must declare extensions")
+		}
+	case KindPostProcessor, KindTool:
+	default:
+		return fmt.Errorf("invalid kind %q (use extractor, postprocessor or tool)", p.Kind)
+	}
+	if len(p.Command) == 0 {
+		return fmt.Errorf("missing command")
+	}
+
+	p.timeout = DefaultTimeout
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", p.Timeout)
+		}
+		p.timeout = d
+	}
+	return nil
+}
+
+// Handles extractor 是否处理该文件（按扩展名，不区分大小写）
+func (p Plugin) Handles(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range p.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// request 插件请求
+type request struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+// response 插件响应
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Call 调用插件方法，result 为响应 result 字段的解码目标
+func (p Plugin) Call(method string, params, result interface{}) error {
+	if p.Err != nil {
+		return fmt.Errorf("plugin %s is invalid: %w", p.Name, p.Err)
+	}
+
+	input, err := json.Marshal(request{Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	name := p.Command[0]
+	if !filepath.IsAbs(name) && strings.ContainsRune(name, filepath.Separator) {
+		name = filepath.Join(p.Dir, name)
+	}
+	cmd := exec.CommandContext(ctx, name, p.Command[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("plugin %s timed out after %s", p.Name, p.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("plugin %s failed: %w: %s", p.Name, err, msg)
+		}
+		return fmt.Errorf("plugin %s failed: %w", p.Name, err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return fmt.Errorf("plugin %s returned invalid JSON: %w", p.Name, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("plugin %s returned an invalid result: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Extracted extractor 的结果
+type Extracted struct {
+	Text  string `json:"text"`
+	Title string `json:"title,omitempty"` // 为空时由 mmq 从文本或文件名提取
+}
+
+// Extract 调用 extractor 提取文件文本（method: extract，params: {path}）
+func (p Plugin) Extract(path string) (*Extracted, error) {
+	var out Extracted
+	if err := p.Call("extract", map[string]string{"path": path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Result 交给后处理器的检索结果
+type Result struct {
+	DocID      string  `json:"docid"`
+	Collection string  `json:"collection"`
+	Path       string  `json:"path"`
+	Title      string  `json:"title"`
+	Snippet    string  `json:"snippet,omitempty"`
+	Score      float64 `json:"score"`
+}
+
+// PostProcess 调用后处理器（method: postprocess，params: {query, results}）
+// 返回的结果按 collection/path 对应原结果，可以删除、重新排序或修改分数和摘要
+func (p Plugin) PostProcess(query string, results []Result) ([]Result, error) {
+	var out struct {
+		Results []Result `json:"results"`
+	}
+	params := map[string]interface{}{"query": query, "results": results}
+	if err := p.Call("postprocess", params, &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
+}
+
+// Run 调用对话工具（method: run，params: {input}），返回工具输出文本
+func (p Plugin) Run(input string) (string, error) {
+	var out struct {
+		Output string `json:"output"`
+	}
+	if err := p.Call("run", map[string]string{"input": input}, &out); err != nil {
+		return "", err
+	}
+	return out.Output, nil
+}
@@ -0,0 +1,135 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// BlendWeights 重排时 RRF 位置分数所占的权重（其余为重排器分数），按 RRF 排名分三段
+type BlendWeights struct {
+	Top  float64 `json:"top"`  // 排名 1-3
+	Mid  float64 `json:"mid"`  // 排名 4-10
+	Tail float64 `json:"tail"` // 排名 11+
+}
+
+// DefaultBlendWeights 默认混合权重：靠前的结果更信任检索，靠后的更信任重排器
+var DefaultBlendWeights = BlendWeights{Top: 0.75, Mid: 0.60, Tail: 0.40}
+
+// Validate 检查权重都在 [0, 1] 内
+func (w BlendWeights) Validate() error {
+	for _, v := range []float64{w.Top, w.Mid, w.Tail} {
+		if v < 0 || v > 1 || math.IsNaN(v) {
+			return fmt.Errorf("rerank blend weights must be between 0 and 1, got %v", w)
+		}
+	}
+	return nil
+}
+
+// weight 返回 RRF 排名对应的 RRF 权重
+func (w BlendWeights) weight(rrfRank int) float64 {
+	switch {
+	case rrfRank <= 3:
+		return w.Top
+	case rrfRank <= 10:
+		return w.Mid
+	default:
+		return w.Tail
+	}
+}
+
+// Blend 混合 RRF 位置分数（1/rank）和重排器分数
+func (w BlendWeights) Blend(rrfRank int, rerankScore float64) float64 {
+	rrfWeight := w.weight(rrfRank)
+	return rrfWeight*(1.0/float64(rrfRank)) + (1-rrfWeight)*rerankScore
+}
+
+// SetBlendWeights 设置重排混合权重
+func (r *Retriever) SetBlendWeights(w BlendWeights) {
+	r.blend = w
+}
+
+// BlendWeights 返回当前的重排混合权重
+func (r *Retriever) BlendWeights() BlendWeights {
+	return r.blend
+}
+
+// BlendCandidate 一条带相关性标注的重排候选
+type BlendCandidate struct {
+	Rank        int     // RRF 排名（1 开始）
+	RerankScore float64 // 重排器分数
+	Relevant    bool
+}
+
+// BlendFit FitBlendWeights 的结果，Score 为各查询平均精度的均值（MAP）
+type BlendFit struct {
+	Weights       BlendWeights `json:"weights"`
+	Score         float64      `json:"score"`
+	Baseline      BlendWeights `json:"baseline"`
+	BaselineScore float64      `json:"baseline_score"`
+	Queries       int          `json:"queries"`
+}
+
+// blendGridStep 搜索权重时的步长
+const blendGridStep = 0.05
+
+// FitBlendWeights 在标注数据上网格搜索使 MAP 最高的混合权重
+// 每个查询是一组候选；没有相关候选的查询不参与评估。得分相同时取离 baseline 最近的权重
+func FitBlendWeights(queries [][]BlendCandidate, baseline BlendWeights) BlendFit {
+	var usable [][]BlendCandidate
+	for _, q := range queries {
+		for _, c := range q {
+			if c.Relevant {
+				usable = append(usable, q)
+				break
+			}
+		}
+	}
+
+	fit := BlendFit{Weights: baseline, Baseline: baseline, Queries: len(usable)}
+	if len(usable) == 0 {
+		return fit
+	}
+	fit.BaselineScore = meanAveragePrecision(usable, baseline)
+	fit.Score = fit.BaselineScore
+
+	steps := int(math.Round(1 / blendGridStep))
+	bestDist := 0.0
+	for i := 0; i <= steps; i++ {
+		for j := 0; j <= steps; j++ {
+			for k := 0; k <= steps; k++ {
+				w := BlendWeights{Top: float64(i) / float64(steps), Mid: float64(j) / float64(steps), Tail: float64(k) / float64(steps)}
+				score := meanAveragePrecision(usable, w)
+				dist := math.Abs(w.Top-baseline.Top) + math.Abs(w.Mid-baseline.Mid) + math.Abs(w.Tail-baseline.Tail)
+				if score > fit.Score+1e-9 || (math.Abs(score-fit.Score) <= 1e-9 && dist < bestDist) {
+					fit.Weights, fit.Score, bestDist = w, score, dist
+				}
+			}
+		}
+	}
+	return fit
+}
+
+// meanAveragePrecision 按权重 w 混合排序后各查询平均精度的均值
+func meanAveragePrecision(queries [][]BlendCandidate, w BlendWeights) float64 {
+	total := 0.0
+	for _, q := range queries {
+		scores := make([]float64, len(q))
+		order := make([]int, len(q))
+		for i, c := range q {
+			scores[i] = w.Blend(c.Rank, c.RerankScore)
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+		hits, sum := 0, 0.0
+		for pos, idx := range order {
+			if q[idx].Relevant {
+				hits++
+				sum += float64(hits) / float64(pos+1)
+			}
+		}
+		total += sum / float64(hits)
+	}
+	return total / float64(len(queries))
+}
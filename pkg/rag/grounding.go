@@ -0,0 +1,246 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// GroundingMethod 答案核查方式
+type GroundingMethod string
+
+const (
+	// GroundingEmbedding 按句子与检索片段的嵌入相似度判断
+	GroundingEmbedding GroundingMethod = "embedding"
+	// GroundingJudge 由生成模型逐句判断是否有依据
+	GroundingJudge GroundingMethod = "judge"
+)
+
+// DefaultGroundingThreshold 嵌入核查的默认相似度阈值
+const DefaultGroundingThreshold = 0.6
+
+// SentenceCheck 单个句子的核查结果
+type SentenceCheck struct {
+	Sentence  string  `json:"sentence"`
+	Supported bool    `json:"supported"`
+	Score     float64 `json:"score"`            // 最高相似度（judge 模式为 0 或 1）
+	Source    string  `json:"source,omitempty"` // 最相近的来源
+}
+
+// GroundingReport 答案核查报告
+type GroundingReport struct {
+	Method      GroundingMethod `json:"method"`
+	Sentences   []SentenceCheck `json:"sentences"`
+	Supported   int             `json:"supported"`
+	Unsupported int             `json:"unsupported"`
+}
+
+// Annotate 在没有依据的句子后加上标记
+func (r *GroundingReport) Annotate(marker string) string {
+	var b strings.Builder
+	for _, s := range r.Sentences {
+		b.WriteString(s.Sentence)
+		if !s.Supported {
+			b.WriteString(marker)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// GroundingVerifier 核查答案中的每句话是否能在检索上下文中找到依据
+type GroundingVerifier struct {
+	embedding *llm.EmbeddingGenerator
+	judge     func(prompt string) (string, error)
+	threshold float64
+}
+
+// NewGroundingVerifier 创建核查器，默认使用嵌入相似度
+func NewGroundingVerifier(embedding *llm.EmbeddingGenerator) *GroundingVerifier {
+	return &GroundingVerifier{embedding: embedding, threshold: DefaultGroundingThreshold}
+}
+
+// SetThreshold 设置嵌入核查的相似度阈值
+func (v *GroundingVerifier) SetThreshold(t float64) { v.threshold = t }
+
+// SetJudge 设置生成模型，之后使用 judge 模式核查
+func (v *GroundingVerifier) SetJudge(fn func(prompt string) (string, error)) { v.judge = fn }
+
+// Verify 核查答案，没有上下文时所有句子都视为无依据
+func (v *GroundingVerifier) Verify(answer string, contexts []Context) (*GroundingReport, error) {
+	sentences := SplitSentences(answer)
+
+	var (
+		report *GroundingReport
+		err    error
+	)
+	switch {
+	case len(contexts) == 0:
+		report = &GroundingReport{Method: GroundingEmbedding}
+		for _, s := range sentences {
+			report.Sentences = append(report.Sentences, SentenceCheck{Sentence: s})
+		}
+	case v.judge != nil:
+		report, err = v.verifyJudge(sentences, contexts)
+	default:
+		report, err = v.verifyEmbedding(sentences, contexts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range report.Sentences {
+		if s.Supported {
+			report.Supported++
+		} else {
+			report.Unsupported++
+		}
+	}
+	return report, nil
+}
+
+// verifyEmbedding 每句答案与上下文的各个句子比较，取最高相似度
+func (v *GroundingVerifier) verifyEmbedding(sentences []string, contexts []Context) (*GroundingReport, error) {
+	type evidence struct {
+		vec    []float32
+		source string
+	}
+
+	var evidences []evidence
+	for _, ctx := range contexts {
+		for _, s := range SplitSentences(ctx.Text) {
+			vec, err := v.embedding.Generate(s, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed context: %w", err)
+			}
+			evidences = append(evidences, evidence{vec: vec, source: ctx.Source})
+		}
+	}
+
+	report := &GroundingReport{Method: GroundingEmbedding}
+	for _, s := range sentences {
+		// 句子间是对称比较，两侧都按文档编码
+		vec, err := v.embedding.Generate(s, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed answer: %w", err)
+		}
+
+		check := SentenceCheck{Sentence: s}
+		for _, e := range evidences {
+			// 向量已归一化，点积即余弦相似度
+			if score := dot(vec, e.vec); score > check.Score {
+				check.Score = score
+				check.Source = e.source
+			}
+		}
+		check.Supported = check.Score >= v.threshold
+		report.Sentences = append(report.Sentences, check)
+	}
+	return report, nil
+}
+
+// groundingJudgePrompt 逐句判断是否有依据的 prompt
+const groundingJudgePrompt = `根据下面的参考文档，判断回答中的每一句话是否有文档依据。
+只有文档明确支持的内容才算有依据；常识性的过渡语句也算有依据。
+
+[参考文档]
+%s
+[回答（已编号）]
+%s
+返回 JSON 数组，每句一项，如 [{"i":1,"supported":true}]，不要输出其他文字：`
+
+// verifyJudge 使用生成模型判断
+func (v *GroundingVerifier) verifyJudge(sentences []string, contexts []Context) (*GroundingReport, error) {
+	var docs, numbered strings.Builder
+	for i, ctx := range contexts {
+		fmt.Fprintf(&docs, "[%d] (%s)\n%s\n\n", i+1, ctx.Source, ctx.Text)
+	}
+	for i, s := range sentences {
+		fmt.Fprintf(&numbered, "%d. %s\n", i+1, s)
+	}
+
+	output, err := v.judge(fmt.Sprintf(groundingJudgePrompt, docs.String(), numbered.String()))
+	if err != nil {
+		return nil, fmt.Errorf("grounding judge failed: %w", err)
+	}
+
+	var verdicts []struct {
+		I         int  `json:"i"`
+		Supported bool `json:"supported"`
+	}
+	start, end := strings.Index(output, "["), strings.LastIndex(output, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("grounding judge returned no JSON array")
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &verdicts); err != nil {
+		return nil, fmt.Errorf("failed to parse grounding judge output: %w", err)
+	}
+
+	supported := make(map[int]bool, len(verdicts))
+	for _, vd := range verdicts {
+		supported[vd.I] = vd.Supported
+	}
+
+	report := &GroundingReport{Method: GroundingJudge}
+	for i, s := range sentences {
+		check := SentenceCheck{Sentence: s, Supported: supported[i+1]}
+		if check.Supported {
+			check.Score = 1
+		}
+		report.Sentences = append(report.Sentences, check)
+	}
+	return report, nil
+}
+
+// SplitSentences 按中英文句末标点和换行拆分句子，忽略空白和过短的片段
+func SplitSentences(text string) []string {
+	var sentences []string
+	var cur strings.Builder
+
+	flush := func() {
+		s := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if utf8.RuneCountInString(s) >= 4 {
+			sentences = append(sentences, s)
+		}
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		switch r {
+		case '\n':
+			flush()
+			continue
+		case '。', '！', '？', '；':
+			cur.WriteRune(r)
+			flush()
+			continue
+		case '.', '!', '?':
+			cur.WriteRune(r)
+			// 英文句号后需跟空白，避免拆开小数和缩写
+			if i+1 == len(runes) || runes[i+1] == ' ' || runes[i+1] == '\n' {
+				flush()
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+
+	return sentences
+}
+
+// dot 向量点积
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
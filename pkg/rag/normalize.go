@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// ScoreNormalization 分数归一化方式，使同一个 MinScore 在不同策略下含义接近
+type ScoreNormalization string
+
+const (
+	// NormalizeRaw 保留各检索器的原始分数（BM25 为 1/(1+|s|)，向量为余弦，混合为 RRF）
+	NormalizeRaw ScoreNormalization = "raw"
+	// NormalizeMinMax 按本次查询的结果做 min-max 缩放，最佳结果为 1
+	NormalizeMinMax ScoreNormalization = "minmax"
+	// NormalizeCalibrated 按策略映射到统一的 [0,1] 刻度，BM25 用语料规模校准
+	NormalizeCalibrated ScoreNormalization = "calibrated"
+)
+
+// ParseScoreNormalization 解析归一化方式，空字符串为 raw
+func ParseScoreNormalization(s string) (ScoreNormalization, error) {
+	switch ScoreNormalization(s) {
+	case "", NormalizeRaw:
+		return NormalizeRaw, nil
+	case NormalizeMinMax, NormalizeCalibrated:
+		return ScoreNormalization(s), nil
+	default:
+		return "", fmt.Errorf("invalid score normalization %q (use raw, minmax or calibrated)", s)
+	}
+}
+
+// normalizeScores 按选项归一化分数（原地修改）
+func (r *Retriever) normalizeScores(results []store.SearchResult, opts RetrieveOptions) {
+	switch opts.Normalize {
+	case NormalizeMinMax:
+		minMaxNormalize(results)
+	case NormalizeCalibrated:
+		docs, _ := r.store.CountActiveDocuments()
+		scale := bm25Scale(docs)
+		maxRRF := maxRRFScore(opts)
+		for i := range results {
+			results[i].Score = calibratedScore(results[i], scale, maxRRF)
+		}
+	}
+}
+
+// minMaxNormalize 缩放到 [0,1]；只有一个结果或分数全相同时都记为 1
+func minMaxNormalize(results []store.SearchResult) {
+	if len(results) == 0 {
+		return
+	}
+	lo, hi := strength(results[0]), strength(results[0])
+	for _, r := range results {
+		lo = math.Min(lo, strength(r))
+		hi = math.Max(hi, strength(r))
+	}
+	for i := range results {
+		if hi == lo {
+			results[i].Score = 1
+		} else {
+			results[i].Score = (strength(results[i]) - lo) / (hi - lo)
+		}
+	}
+}
+
+// strength 越大越相关的分数；1/(1+|s|) 的 BM25 分数方向相反，改用 |bm25|
+func strength(r store.SearchResult) float64 {
+	if r.Source == "fts" {
+		return r.RawScore
+	}
+	return r.Score
+}
+
+// bm25Scale BM25 的校准尺度：语料中只出现一次的词的 IDF 约为 ln(N)，
+// 单个稀有词完整命中时得分约 0.5
+func bm25Scale(docs int) float64 {
+	return math.Max(math.Log(1+float64(docs)), 1)
+}
+
+// maxRRFScore 一个文档在所有路都排第一时的 RRF 分数（含 top-rank 奖励）
+func maxRRFScore(opts RetrieveOptions) float64 {
+	k := opts.RRFK
+	if k == 0 {
+		k = 60
+	}
+	weights := opts.RRFWeights
+	if len(weights) == 0 {
+		weights = []float64{1, 1}
+	}
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+	return sum/float64(k+1) + 0.05
+}
+
+// calibratedScore 把不同来源的分数映射到统一刻度
+func calibratedScore(r store.SearchResult, bm25Scale, maxRRF float64) float64 {
+	var score float64
+	switch r.Source {
+	case "fts":
+		score = strength(r) / (strength(r) + bm25Scale)
+	case "hybrid":
+		score = r.Score / maxRRF
+	default:
+		// 向量为余弦相似度
+		score = r.Score
+	}
+	return math.Max(0, math.Min(1, score))
+}
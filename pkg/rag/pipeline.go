@@ -0,0 +1,326 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// 流水线步骤类型
+const (
+	StepExpand   = "expand"   // 查询扩展（lex 扩展给 fts，vec/hyde 扩展给 vector）
+	StepFTS      = "fts"      // BM25 检索，每个查询一路
+	StepVector   = "vector"   // 向量检索，每个查询一路
+	StepFuse     = "fuse"     // RRF 融合已有的各路结果
+	StepFilter   = "filter"   // 按分数过滤、截断
+	StepRerank   = "rerank"   // 重排序
+	StepCompress = "compress" // 截断文档内容，减少注入的文本
+)
+
+// PipelineStep 流水线中的一步，字段按步骤类型使用
+type PipelineStep struct {
+	Type       string  `yaml:"type"`
+	Weight     float64 `yaml:"weight,omitempty"`     // fts/vector：融合权重（默认 1）
+	Candidates int     `yaml:"candidates,omitempty"` // fts/vector：每路召回数（默认 Limit×候选倍数）
+	K          int     `yaml:"k,omitempty"`          // fuse：RRF 参数 k（默认 RRFK）
+	MinScore   float64 `yaml:"min_score,omitempty"`  // filter：最小分数
+	Limit      int     `yaml:"limit,omitempty"`      // filter：保留前 N 个；rerank：候选上限
+	MaxChars   int     `yaml:"max_chars,omitempty"`  // compress：每个文档保留的字符数
+}
+
+// Pipeline 声明式的检索流水线
+//
+//	description: 扩展 + 混合检索 + 重排
+//	steps:
+//	  - type: expand
+//	  - type: fts
+//	  - type: vector
+//	    weight: 1.5
+//	  - type: fuse
+//	  - type: filter
+//	    min_score: 0.01
+//	  - type: rerank
+//	    limit: 30
+//	  - type: compress
+//	    max_chars: 1200
+type Pipeline struct {
+	Name        string         `yaml:"name,omitempty"` // 默认为文件名
+	Description string         `yaml:"description,omitempty"`
+	Steps       []PipelineStep `yaml:"steps"`
+}
+
+// ParsePipeline 解析并校验 YAML 流水线
+func ParsePipeline(name string, data []byte) (*Pipeline, error) {
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("pipeline %s: invalid YAML: %w", name, err)
+	}
+	if p.Name == "" {
+		p.Name = name
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate 校验步骤顺序和参数
+// expand 必须在检索步骤之前，检索步骤必须在 fuse 之前，filter/rerank/compress 必须在检索步骤之后
+func (p *Pipeline) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline %s: no steps", p.Name)
+	}
+
+	retrieved, fused := false, false
+	for i, s := range p.Steps {
+		if s.Weight < 0 || s.Candidates < 0 || s.K < 0 || s.MinScore < 0 || s.Limit < 0 || s.MaxChars < 0 {
+			return fmt.Errorf("pipeline %s: step %d (%s): values must not be negative", p.Name, i+1, s.Type)
+		}
+		switch s.Type {
+		case StepExpand:
+			if retrieved {
+				return fmt.Errorf("pipeline %s: step %d: expand must come before fts/vector", p.Name, i+1)
+			}
+		case StepFTS, StepVector:
+			if fused {
+				return fmt.Errorf("pipeline %s: step %d: %s must come before fuse", p.Name, i+1, s.Type)
+			}
+			retrieved = true
+		case StepFuse, StepFilter, StepRerank, StepCompress:
+			if !retrieved {
+				return fmt.Errorf("pipeline %s: step %d: %s needs a preceding fts or vector step", p.Name, i+1, s.Type)
+			}
+			fused = true
+		default:
+			return fmt.Errorf("pipeline %s: step %d: unknown type %q (use expand, fts, vector, fuse, filter, rerank or compress)", p.Name, i+1, s.Type)
+		}
+	}
+	if !retrieved {
+		return fmt.Errorf("pipeline %s: needs at least one fts or vector step", p.Name)
+	}
+	return nil
+}
+
+// LoadPipelines 读取目录中的 *.yaml / *.yml 流水线，名称默认为文件名，目录不存在时返回空
+func LoadPipelines(dir string) ([]*Pipeline, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline directory: %w", err)
+	}
+
+	var pipelines []*Pipeline
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pipeline: %w", err)
+		}
+		p, err := ParsePipeline(strings.TrimSuffix(e.Name(), ext), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines, nil
+}
+
+// RegisterPipeline 注册流水线，同名覆盖；检索时用 RetrieveOptions.Pipeline 按名称选择
+func (r *Retriever) RegisterPipeline(p *Pipeline) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.pipelinesMu.Lock()
+	defer r.pipelinesMu.Unlock()
+	if r.pipelines == nil {
+		r.pipelines = make(map[string]*Pipeline)
+	}
+	r.pipelines[p.Name] = p
+	return nil
+}
+
+// Pipelines 返回已注册的流水线（按名称排序）
+func (r *Retriever) Pipelines() []*Pipeline {
+	r.pipelinesMu.RLock()
+	defer r.pipelinesMu.RUnlock()
+	pipelines := make([]*Pipeline, 0, len(r.pipelines))
+	for _, p := range r.pipelines {
+		pipelines = append(pipelines, p)
+	}
+	sort.Slice(pipelines, func(i, j int) bool { return pipelines[i].Name < pipelines[j].Name })
+	return pipelines
+}
+
+// pipeline 按名称查找流水线
+func (r *Retriever) pipeline(name string) (*Pipeline, error) {
+	r.pipelinesMu.RLock()
+	p, ok := r.pipelines[name]
+	r.pipelinesMu.RUnlock()
+	if ok {
+		return p, nil
+	}
+
+	var names []string
+	for _, p := range r.Pipelines() {
+		names = append(names, p.Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("unknown pipeline %q: no pipelines loaded", name)
+	}
+	return nil, fmt.Errorf("unknown pipeline %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// pipelineQuery 流水线中的一个查询（原查询或扩展查询）
+type pipelineQuery struct {
+	text   string
+	kind   string // 空为原查询，否则为扩展类型 lex/vec/hyde
+	weight float64
+}
+
+// runPipeline 按步骤执行流水线
+// Limit、Collection、Language 和日期过滤仍取自 opts；策略、扩展、重排和 MinScore 由流水线决定
+func (r *Retriever) runPipeline(query string, p *Pipeline, opts RetrieveOptions) ([]store.SearchResult, error) {
+	queries := []pipelineQuery{{text: query, weight: 1}}
+	var (
+		legs    [][]store.SearchResult
+		labels  []string
+		weights []float64
+		results []store.SearchResult
+		fused   bool
+	)
+
+	// 后续步骤需要结果时，融合尚未融合的各路结果
+	fuse := func(k int) {
+		if fused {
+			return
+		}
+		if k <= 0 {
+			k = opts.RRFK
+		}
+		start := time.Now()
+		if len(legs) == 1 {
+			results = legs[0]
+		} else {
+			results = store.ReciprocalRankFusion(legs, weights, k)
+			opts.trace.fusion(labels, legs, weights, k, results)
+		}
+		opts.timings.add(StageFusion, start)
+		fused = true
+	}
+
+	for _, step := range p.Steps {
+		switch step.Type {
+		case StepExpand:
+			start := time.Now()
+			expansions, err := r.expandQueryWithCache(opts.ctx, query)
+			opts.timings.add(StageExpand, start)
+			if cerr := opts.ctx.Err(); cerr != nil {
+				return nil, cerr
+			}
+			if err != nil {
+				opts.trace.note("query expansion failed, using the original query: %v", err)
+				continue // 扩展失败时只用原查询
+			}
+			opts.trace.expanded(expansions)
+			queries = append(queries, expandedQueries(expansions)...)
+
+		case StepFTS, StepVector:
+			legOpts := opts
+			if step.Candidates > 0 {
+				legOpts.Limit = step.Candidates
+				legOpts.CandidateMultiplier = 1
+			}
+			weight := step.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			for _, q := range queries {
+				var leg []store.SearchResult
+				var err error
+				if step.Type == StepFTS {
+					if q.kind != "" && q.kind != "lex" {
+						continue
+					}
+					leg, err = r.retrieveFTS(q.text, legOpts)
+				} else {
+					if q.kind == "lex" {
+						continue
+					}
+					leg, err = r.retrieveVector(q.text, legOpts)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("pipeline %s: %s failed: %w", p.Name, step.Type, err)
+				}
+				legs = append(legs, leg)
+				labels = append(labels, string(step.Type)+": "+q.text)
+				weights = append(weights, weight*q.weight)
+			}
+
+		case StepFuse:
+			fuse(step.K)
+
+		case StepFilter:
+			fuse(0)
+			if step.MinScore > 0 {
+				filtered := make([]store.SearchResult, 0, len(results))
+				for _, res := range results {
+					if res.Score >= step.MinScore {
+						filtered = append(filtered, res)
+					}
+				}
+				results = filtered
+			}
+			if step.Limit > 0 && len(results) > step.Limit {
+				results = results[:step.Limit]
+			}
+
+		case StepRerank:
+			fuse(0)
+			start := time.Now()
+			reranked, err := r.rerank(opts.ctx, query, results, step.Limit, opts.trace)
+			opts.timings.add(StageRerank, start)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %s: rerank failed: %w", p.Name, err)
+			}
+			results = reranked
+
+		case StepCompress:
+			fuse(0)
+			if step.MaxChars > 0 {
+				for i := range results {
+					results[i].Content = truncateRunes(results[i].Content, step.MaxChars)
+				}
+			}
+		}
+	}
+
+	fuse(0)
+	return results, nil
+}
+
+// expandedQueries 转换查询扩展结果，权重为 0 时记为 1
+func expandedQueries(expansions []llm.QueryExpansion) []pipelineQuery {
+	queries := make([]pipelineQuery, 0, len(expansions))
+	for _, exp := range expansions {
+		weight := exp.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		queries = append(queries, pipelineQuery{text: exp.Text, kind: exp.Type, weight: weight})
+	}
+	return queries
+}
@@ -0,0 +1,107 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/dyike/mmq/pkg/llm"
+)
+
+// RerankCache 重排分数缓存，按（查询哈希、分块哈希、重排模型）保存 cross-encoder 分数
+// 过期和条目上限由实现负责
+type RerankCache interface {
+	// Get 返回已缓存的分数（按分块哈希），未命中的分块不在结果中
+	Get(model, queryHash string, chunkHashes []string) (map[string]float64, error)
+	// Put 写入分数
+	Put(model, queryHash string, scores map[string]float64) error
+}
+
+// SetRerankCache 设置重排分数缓存，model 为当前重排模型（更换模型后不会命中旧分数），c 为 nil 时关闭
+func (r *Retriever) SetRerankCache(model string, c RerankCache) {
+	r.rerankModel = model
+	r.rerankCache = c
+}
+
+// cacheWarning 记录缓存读写失败（不输出到标准输出，避免混入 JSON 结果）
+func (r *Retriever) cacheWarning(trace *Trace, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	trace.note("%s", msg)
+	if r.logger != nil {
+		r.logger.Warn(msg)
+	}
+}
+
+// rerankQueryHash 查询的缓存键
+func rerankQueryHash(query string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(query)))
+}
+
+// rerankChunkHash 分块的缓存键，按送入重排模型的标题和内容计算
+func rerankChunkHash(doc llm.Document) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(doc.Title+"\x00"+doc.Content)))
+}
+
+// rerankWithCache 调用重排模型，已缓存分数的分块不再计算，新算出的分数写回缓存
+// 缓存读写失败只记录提示，不影响重排；ctx 结束后（重排已被放弃）不再写回缓存
+func (r *Retriever) rerankWithCache(ctx context.Context, query string, docs []llm.Document, trace *Trace) ([]llm.RerankResult, error) {
+	if r.rerankCache == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return r.llm.Rerank(query, docs)
+	}
+
+	queryHash := rerankQueryHash(query)
+	hashes := make([]string, len(docs))
+	for i, doc := range docs {
+		hashes[i] = rerankChunkHash(doc)
+	}
+	cached, err := r.rerankCache.Get(r.rerankModel, queryHash, hashes)
+	if err != nil {
+		r.cacheWarning(trace, "rerank cache unavailable: %v", err)
+		cached = nil
+	}
+
+	results := make([]llm.RerankResult, 0, len(docs))
+	var missing []llm.Document
+	var missingHashes []string
+	for i, doc := range docs {
+		if score, ok := cached[hashes[i]]; ok {
+			results = append(results, llm.RerankResult{ID: doc.ID, Score: score, Index: i})
+			continue
+		}
+		missing = append(missing, doc)
+		missingHashes = append(missingHashes, hashes[i])
+	}
+	trace.note("rerank cache: %d of %d candidates cached", len(results), len(docs))
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	scored, err := r.llm.Rerank(query, missing)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	hashByID := make(map[string]string, len(missing))
+	for i, doc := range missing {
+		hashByID[doc.ID] = missingHashes[i]
+	}
+	fresh := make(map[string]float64, len(scored))
+	for _, rr := range scored {
+		if h, ok := hashByID[rr.ID]; ok {
+			fresh[h] = rr.Score
+		}
+		results = append(results, rr)
+	}
+	if err := r.rerankCache.Put(r.rerankModel, queryHash, fresh); err != nil {
+		r.cacheWarning(trace, "failed to cache rerank scores: %v", err)
+	}
+	return results, nil
+}
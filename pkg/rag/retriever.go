@@ -1,29 +1,107 @@
 package rag
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/dyike/mmq/pkg/llm"
 	"github.com/dyike/mmq/pkg/store"
 )
 
+// Store 检索器依赖的存储：全文检索和向量检索
+type Store interface {
+	store.DocumentStore
+	store.VectorStore
+}
+
 // Retriever RAG检索器
 type Retriever struct {
-	store     *store.Store
+	store     Store
 	llm       llm.LLM
 	embedding *llm.EmbeddingGenerator
+
+	// embedderFor 返回集合专用嵌入模型的生成器
+	embedderFor func(model string) (*llm.EmbeddingGenerator, error)
+
+	pipelinesMu sync.RWMutex
+	pipelines   map[string]*Pipeline // 按名称注册的检索流水线
+
+	blend BlendWeights // 重排时 RRF 与重排器分数的混合权重
+
+	rerankCache RerankCache // 重排分数缓存，为空时每次重新计算
+	rerankModel string      // 重排分数缓存键中的模型名
+
+	legSlots chan struct{} // 限制同时执行的全文/向量检索路数
+
+	logger *slog.Logger // 为空时直接打印到标准输出
 }
 
+// DefaultLegParallelism 同时执行的检索路数上限（混合检索的全文和各模型向量路、查询扩展的各变体）
+const DefaultLegParallelism = 4
+
 // NewRetriever 创建检索器
-func NewRetriever(st *store.Store, llmImpl llm.LLM, embGen *llm.EmbeddingGenerator) *Retriever {
+func NewRetriever(st Store, llmImpl llm.LLM, embGen *llm.EmbeddingGenerator) *Retriever {
 	return &Retriever{
 		store:     st,
 		llm:       llmImpl,
 		embedding: embGen,
+		blend:     DefaultBlendWeights,
+		legSlots:  make(chan struct{}, DefaultLegParallelism),
+	}
+}
+
+// SetLegParallelism 设置同时执行的检索路数上限（<= 0 使用默认值），应在检索开始前调用
+func (r *Retriever) SetLegParallelism(n int) {
+	if n <= 0 {
+		n = DefaultLegParallelism
+	}
+	r.legSlots = make(chan struct{}, n)
+}
+
+// acquireLeg 占用一个检索路数名额，返回释放函数；ctx 结束时不再等待名额
+// 只在不再派生检索的叶子（单次全文或向量搜索）中调用，嵌套的检索不会互相等待
+func (r *Retriever) acquireLeg(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case r.legSlots <- struct{}{}:
+		return func() { <-r.legSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runLegs 并发执行 n 路检索，结果和错误按下标返回，融合顺序与完成顺序无关
+func runLegs(n int, fn func(i int) ([]store.SearchResult, error)) ([][]store.SearchResult, []error) {
+	results := make([][]store.SearchResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = fn(i)
+		}(i)
 	}
+	wg.Wait()
+	return results, errs
+}
+
+// SetEmbedderResolver 设置专用嵌入模型的生成器查找函数
+func (r *Retriever) SetEmbedderResolver(fn func(model string) (*llm.EmbeddingGenerator, error)) {
+	r.embedderFor = fn
+}
+
+// SetLogger 设置检索过程提示的输出位置
+func (r *Retriever) SetLogger(l *slog.Logger) {
+	r.logger = l
 }
 
 // RetrievalStrategy 检索策略
@@ -40,11 +118,129 @@ type RetrieveOptions struct {
 	Limit       int               // 返回结果数量
 	MinScore    float64           // 最小分数阈值
 	Collection  string            // 集合过滤
+	Language    string            // 语言过滤（如 zh、en）
 	Strategy    RetrievalStrategy // 检索策略
 	Rerank      bool              // 是否重排序
 	ExpandQuery bool              // 是否使用查询扩展
 	RRFWeights  []float64         // RRF权重
 	RRFK        int               // RRF参数K
+
+	CandidateMultiplier float64 // 每路召回 Limit×倍数 个候选（默认 2）
+	RerankLimit         int     // 重排的候选上限（默认 40）
+
+	Normalize ScoreNormalization // 过滤 MinScore 前的分数归一化方式（默认 raw）
+
+	// After/Before 按文档日期过滤（After 含当天，Before 不含；没有文档日期时用修改时间）
+	After  time.Time
+	Before time.Time
+
+	// Timeout 检索总时长预算（0 不限制）
+	// 查询扩展、混合检索的向量路或重排超出预算时跳过该阶段，返回已有结果并标记 degraded
+	// 混合检索总是等待全文检索完成；全文检索没有结果时继续等待向量检索
+	Timeout time.Duration
+
+	// Context 取消时停止检索，返回 ctx.Err()（为空不可取消）
+	// 超出 Timeout 的阶段同样被取消：尚未开始的检索路、LLM 调用和缓存写入不再执行
+	Context context.Context
+
+	// QueryEmbedding 调用方已用默认嵌入模型为查询生成的嵌入（如对话中检测话题时生成的），
+	// 默认模型的向量检索直接使用，避免重复嵌入；查询扩展的变体或设置了 Instruction 时仍重新生成
+	QueryEmbedding []float32
+
+	// Pipeline 使用已注册的命名流水线代替 Strategy/ExpandQuery/Rerank/MinScore
+	Pipeline string
+
+	// Instruction 代替默认的查询嵌入指令（如聚类或分类任务的指令），空为默认
+	Instruction string
+
+	// PostFilter 融合和重排之后、截断到 Limit 之前调用，返回 false 的结果被丢弃
+	PostFilter func(Context) bool
+
+	timings *Timings // 由 RetrieveWithTimings 设置
+	trace   *Trace   // 由 RetrieveWithTrace 设置
+
+	query    string          // Retrieve 的原始查询，QueryEmbedding 只用于该查询
+	deadline time.Time       // 由 Timeout 计算
+	skips    *stageSkips     // 因超出 Timeout 跳过的阶段
+	ctx      context.Context // 当前阶段的 ctx：可跳过的阶段在超出 Timeout 时被取消
+}
+
+// LongQueryFTSWeight 长查询混合检索时全文检索路的权重系数（全文检索只用提取的关键词，以向量检索为主）
+const LongQueryFTSWeight = 0.5
+
+// longQueryOptions 超长查询（如整段文字）以向量检索为主：跳过查询扩展，
+// 混合检索时降低全文检索路的 RRF 权重；显式选择的全文检索不变
+func longQueryOptions(opts RetrieveOptions) RetrieveOptions {
+	if opts.ExpandQuery {
+		opts.ExpandQuery = false
+		opts.trace.note("long query: skipping query expansion")
+	}
+	if opts.Strategy == StrategyHybrid {
+		weights := []float64{1, 1}
+		if len(opts.RRFWeights) == 2 {
+			weights = []float64{opts.RRFWeights[0], opts.RRFWeights[1]}
+		}
+		weights[0] *= LongQueryFTSWeight
+		opts.RRFWeights = weights
+		opts.trace.note("long query: vector-first fusion, full-text weight %g uses %d keywords", weights[0], store.LongQueryKeywords)
+	}
+	return opts
+}
+
+// stageSkips 因超出 Timeout 跳过的阶段，并发的检索路共用
+type stageSkips struct {
+	mu     sync.Mutex
+	stages []Stage
+}
+
+// add 记录跳过的阶段（nil 安全）
+func (s *stageSkips) add(stage Stage) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.stages = append(s.stages, stage)
+	s.mu.Unlock()
+}
+
+// list 返回跳过的阶段
+func (s *stageSkips) list() []Stage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Stage(nil), s.stages...)
+}
+
+// 候选数量默认值
+const (
+	DefaultCandidateMultiplier = 2.0
+	DefaultRerankLimit         = 40
+)
+
+// candidateLimit 每路检索召回的候选数
+func (o RetrieveOptions) candidateLimit() int {
+	mult := o.CandidateMultiplier
+	if mult <= 0 {
+		mult = DefaultCandidateMultiplier
+	}
+	n := int(math.Ceil(float64(o.Limit) * mult))
+	if n < o.Limit {
+		n = o.Limit
+	}
+	return n
+}
+
+// record 阶段完成后记录耗时；阶段已被取消（结果会被丢弃）时不记录，返回 ctx 的错误
+func (o RetrieveOptions) record(stage Stage, start time.Time) error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+	o.timings.add(stage, start)
+	return nil
+}
+
+// dateRange 文档日期过滤条件
+func (o RetrieveOptions) dateRange() store.DateRange {
+	return store.DateRange{After: o.After, Before: o.Before}
 }
 
 // DefaultRetrieveOptions 默认检索选项
@@ -57,6 +253,9 @@ func DefaultRetrieveOptions() RetrieveOptions {
 		ExpandQuery: false,
 		RRFWeights:  []float64{1.0, 1.0},
 		RRFK:        60,
+
+		CandidateMultiplier: DefaultCandidateMultiplier,
+		RerankLimit:         DefaultRerankLimit,
 	}
 }
 
@@ -73,8 +272,69 @@ func (r *Retriever) Retrieve(query string, opts RetrieveOptions) ([]Context, err
 	var results []store.SearchResult
 	var err error
 
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 返回后被放弃的阶段不再写入耗时和追踪
+	defer opts.timings.seal()
+	defer opts.trace.seal()
+
+	// stageCtx 用于可跳过的阶段（查询扩展、重排），超出 Timeout 时取消
+	var deadline time.Time
+	stageCtx := ctx
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	skips := &stageSkips{}
+	opts.query, opts.deadline, opts.skips, opts.ctx = query, deadline, skips, ctx
+
+	if opts.Pipeline != "" {
+		p, err := r.pipeline(opts.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		opts.trace.note("pipeline %s", p.Name)
+		results, err := r.runPipeline(query, p, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = r.postFilter(results, opts)
+		if len(results) > opts.Limit {
+			results = results[:opts.Limit]
+		}
+		return r.toContexts(results), nil
+	}
+
+	if store.IsLongQuery(query) {
+		opts = longQueryOptions(opts)
+	}
+
 	// 如果启用查询扩展，执行多查询并合并结果
-	if opts.ExpandQuery {
+	if opts.ExpandQuery && !deadline.IsZero() {
+		// 有时间预算时同时执行普通检索，扩展超时则用普通检索结果
+		expandOpts := opts
+		expandOpts.ctx = stageCtx
+		expanded := runAsync(func() ([]store.SearchResult, error) {
+			return r.retrieveWithExpansion(query, expandOpts)
+		})
+		results, err = r.retrieveSingleQuery(query, opts)
+		if err == nil {
+			if res, ok := expanded.wait(stageCtx); ok && res.err == nil {
+				results = res.results
+			} else if !ok {
+				if err = ctx.Err(); err == nil {
+					skips.add(StageExpand)
+				}
+			}
+		}
+	} else if opts.ExpandQuery {
 		results, err = r.retrieveWithExpansion(query, opts)
 	} else {
 		// 标准检索
@@ -94,6 +354,8 @@ func (r *Retriever) Retrieve(query string, opts RetrieveOptions) ([]Context, err
 		return nil, err
 	}
 
+	r.normalizeScores(results, opts)
+
 	// 过滤低分结果
 	if opts.MinScore > 0 {
 		filtered := make([]store.SearchResult, 0, len(results))
@@ -102,88 +364,318 @@ func (r *Retriever) Retrieve(query string, opts RetrieveOptions) ([]Context, err
 				filtered = append(filtered, r)
 			}
 		}
+		opts.trace.note("min score %g kept %d of %d candidates", opts.MinScore, len(filtered), len(results))
 		results = filtered
 	}
 
 	// 重排序
 	if opts.Rerank && len(results) > 0 {
-		results, err = r.rerank(query, results)
+		start := time.Now()
+		if deadline.IsZero() {
+			results, err = r.rerank(ctx, query, results, opts.RerankLimit, opts.trace)
+		} else {
+			// 超时则保留未重排的结果（后台的重排被取消，结果不写入缓存）
+			candidates := results
+			reranked := runAsync(func() ([]store.SearchResult, error) {
+				return r.rerank(stageCtx, query, candidates, opts.RerankLimit, opts.trace)
+			})
+			if res, ok := reranked.wait(stageCtx); ok {
+				results, err = res.results, res.err
+			} else if err = ctx.Err(); err == nil {
+				skips.add(StageRerank)
+			}
+		}
+		opts.timings.add(StageRerank, start)
 		if err != nil {
 			return nil, fmt.Errorf("rerank failed: %w", err)
 		}
 	}
 
+	results = r.postFilter(results, opts)
+
 	// 限制结果数量
 	if len(results) > opts.Limit {
 		results = results[:opts.Limit]
 	}
 
 	// 转换为Context
-	return r.toContexts(results), nil
+	contexts := r.toContexts(results)
+	if skipped := skips.list(); len(skipped) > 0 {
+		opts.timings.skip(skipped)
+		for i := range contexts {
+			contexts[i].Metadata["degraded"] = true
+		}
+	}
+	return contexts, nil
+}
+
+// postFilter 按 opts.PostFilter 过滤结果
+func (r *Retriever) postFilter(results []store.SearchResult, opts RetrieveOptions) []store.SearchResult {
+	if opts.PostFilter == nil || len(results) == 0 {
+		return results
+	}
+	contexts := r.toContexts(results)
+	kept := make([]store.SearchResult, 0, len(results))
+	for i, c := range contexts {
+		if opts.PostFilter(c) {
+			kept = append(kept, results[i])
+		}
+	}
+	opts.trace.note("post filter kept %d of %d candidates", len(kept), len(results))
+	return kept
+}
+
+// asyncResult 后台检索阶段的结果
+type asyncResult struct {
+	results []store.SearchResult
+	err     error
+}
+
+type asyncStage chan asyncResult
+
+// runAsync 在后台执行检索阶段
+func runAsync(fn func() ([]store.SearchResult, error)) asyncStage {
+	ch := make(asyncStage, 1)
+	go func() {
+		results, err := fn()
+		ch <- asyncResult{results: results, err: err}
+	}()
+	return ch
+}
+
+// wait 等待阶段完成，ctx 先结束（超出 Timeout 或被取消）时返回 false
+func (s asyncStage) wait(ctx context.Context) (asyncResult, bool) {
+	select {
+	case res := <-s:
+		return res, true
+	case <-ctx.Done():
+		return asyncResult{}, false
+	}
 }
 
 // retrieveFTS BM25全文搜索
 func (r *Retriever) retrieveFTS(query string, opts RetrieveOptions) ([]store.SearchResult, error) {
-	return r.store.SearchFTS(query, opts.Limit*2, opts.Collection)
+	release, err := r.acquireLeg(opts.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start := time.Now()
+	results, err := r.store.SearchFTS(query, opts.candidateLimit(), opts.Collection, opts.Language, opts.dateRange())
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.record(StageFTS, start); err != nil {
+		return nil, err
+	}
+	opts.trace.leg("fts", "", query, results)
+	return results, nil
 }
 
 // retrieveVector 向量语义搜索
+// 集合使用不同嵌入模型时，每个模型分别搜索，再用 RRF 融合（不同模型的分数不可比）
 func (r *Retriever) retrieveVector(query string, opts RetrieveOptions) ([]store.SearchResult, error) {
-	// 生成查询嵌入
-	embedding, err := r.embedding.Generate(query, true)
+	legs, models, err := r.vectorLegs(query, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		return nil, err
+	}
+	if len(legs) == 1 {
+		return legs[0], nil
+	}
+	start := time.Now()
+	fused := store.ReciprocalRankFusion(legs, nil, opts.RRFK)
+	if err := opts.record(StageFusion, start); err != nil {
+		return nil, err
+	}
+	opts.trace.fusion(vectorLegLabels(models), legs, nil, opts.RRFK, fused)
+	return fused, nil
+}
+
+// vectorLegLabels 融合表中各路向量检索的说明
+func vectorLegLabels(models []string) []string {
+	labels := make([]string, len(models))
+	for i, model := range models {
+		labels[i] = "vector"
+		if model != "" {
+			labels[i] = "vector:" + model
+		}
+	}
+	return labels
+}
+
+// vectorLegs 按嵌入模型分路做向量搜索，第一路为默认模型；同时返回各路的模型
+func (r *Retriever) vectorLegs(query string, opts RetrieveOptions) ([][]store.SearchResult, []string, error) {
+	models := []string{""}
+	if opts.Collection != "" {
+		// 集合不存在时按默认模型搜索（结果为空）
+		model, _ := r.store.GetCollectionEmbedModel(opts.Collection)
+		models = []string{model}
+	} else {
+		extra, err := r.store.ListEmbedModels()
+		if err != nil {
+			return nil, nil, err
+		}
+		models = append(models, extra...)
 	}
 
-	return r.store.SearchVectorDocuments(query, embedding, opts.Limit*2, opts.Collection)
+	legs, errs := runLegs(len(models), func(i int) ([]store.SearchResult, error) {
+		results, err := r.searchVectorModel(query, models[i], opts)
+		if err == nil {
+			opts.trace.leg("vector", models[i], query, results)
+		}
+		return results, err
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return legs, models, nil
+}
+
+// searchVectorModel 用指定模型生成查询嵌入并搜索该模型的向量（model 为空表示默认模型）
+func (r *Retriever) searchVectorModel(query, model string, opts RetrieveOptions) ([]store.SearchResult, error) {
+	release, err := r.acquireLeg(opts.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var results []store.SearchResult
+	if model == "" {
+		embedding := opts.QueryEmbedding
+		if len(embedding) == 0 || opts.Instruction != "" || query != opts.query {
+			// 生成查询嵌入
+			start := time.Now()
+			embedding, err = r.embedding.GenerateQuery(query, opts.Instruction)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+			}
+			if err := opts.record(StageEmbed, start); err != nil {
+				return nil, err
+			}
+		}
+		start := time.Now()
+		results, err = r.store.SearchVectorDocuments(query, embedding, opts.candidateLimit(), opts.Collection, opts.Language, opts.dateRange())
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.record(StageVector, start); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	if r.embedderFor == nil {
+		return nil, fmt.Errorf("no embedder available for model %s", model)
+	}
+	gen, err := r.embedderFor(model)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	embedding, err := gen.GenerateQuery(query, opts.Instruction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding with %s: %w", model, err)
+	}
+	if err := opts.record(StageEmbed, start); err != nil {
+		return nil, err
+	}
+	start = time.Now()
+	results, err = r.store.SearchModelVectorDocuments(model, query, embedding, opts.candidateLimit(), opts.Collection, opts.Language, opts.dateRange())
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.record(StageVector, start); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // retrieveHybrid 混合搜索
 func (r *Retriever) retrieveHybrid(query string, opts RetrieveOptions) ([]store.SearchResult, error) {
 	var (
 		ftsResults []store.SearchResult
-		vecResults []store.SearchResult
+		vecLegs    [][]store.SearchResult
+		vecModels  []string
 		ftsErr     error
 		vecErr     error
 	)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		ftsResults, ftsErr = r.retrieveFTS(query, opts)
-	}()
+	// 不再等待向量检索时取消它
+	vecCtx, cancel := context.WithCancel(opts.ctx)
+	defer cancel()
+	vecOpts := opts
+	vecOpts.ctx = vecCtx
+
+	vecDone := make(chan struct{})
 	go func() {
-		defer wg.Done()
-		vecResults, vecErr = r.retrieveVector(query, opts)
+		defer close(vecDone)
+		vecLegs, vecModels, vecErr = r.vectorLegs(query, vecOpts)
 	}()
-	wg.Wait()
-
+	ftsResults, ftsErr = r.retrieveFTS(query, opts)
 	if ftsErr != nil {
 		return nil, fmt.Errorf("FTS search failed: %w", ftsErr)
 	}
+
+	// 有时间预算时，全文检索有结果就不再等待超时的向量检索（后台的结果被丢弃）
+	if !opts.deadline.IsZero() && len(ftsResults) > 0 {
+		timer := time.NewTimer(time.Until(opts.deadline))
+		defer timer.Stop()
+		select {
+		case <-vecDone:
+		case <-timer.C:
+			opts.skips.add(StageVector)
+			opts.trace.note("vector search exceeded the time budget, using full-text results only")
+			return ftsResults, nil
+		case <-opts.ctx.Done():
+			return nil, opts.ctx.Err()
+		}
+	} else {
+		<-vecDone
+	}
 	if vecErr != nil {
 		return nil, fmt.Errorf("vector search failed: %w", vecErr)
 	}
 
-	resultLists := [][]store.SearchResult{ftsResults, vecResults}
-	fused := store.ReciprocalRankFusion(resultLists, opts.RRFWeights, opts.RRFK)
+	// 每个嵌入模型一路，共用向量权重
+	resultLists := append([][]store.SearchResult{ftsResults}, vecLegs...)
+	weights := opts.RRFWeights
+	if len(vecLegs) > 1 && len(weights) == 2 {
+		weights = []float64{weights[0]}
+		for range vecLegs {
+			weights = append(weights, opts.RRFWeights[1])
+		}
+	}
+	start := time.Now()
+	fused := store.ReciprocalRankFusion(resultLists, weights, opts.RRFK)
+	if err := opts.record(StageFusion, start); err != nil {
+		return nil, err
+	}
+	opts.trace.fusion(append([]string{"fts"}, vectorLegLabels(vecModels)...), resultLists, weights, opts.RRFK, fused)
 
 	return fused, nil
 }
 
 // rerank 使用LLM重排序，并与RRF位置分数混合
 // 使用 position-aware blending：排名靠前的结果更信任检索，排名靠后的结果更信任重排器
-func (r *Retriever) rerank(query string, results []store.SearchResult) ([]store.SearchResult, error) {
-	if len(results) == 0 {
+// limit 为重排候选上限（<=0 时使用 DefaultRerankLimit），控制延迟和成本
+// 后端没有重排能力时（如只组合了嵌入和生成的 LLM）保持原顺序
+// ctx 结束时不再调用重排模型，返回 ctx 的错误
+func (r *Retriever) rerank(ctx context.Context, query string, results []store.SearchResult, limit int, trace *Trace) ([]store.SearchResult, error) {
+	if len(results) == 0 || !llm.Supports(r.llm, llm.CapabilityRerank) {
 		return results, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// 硬上限：最多重排40个文档（控制延迟和成本）
-	const rerankDocLimit = 40
+	if limit <= 0 {
+		limit = DefaultRerankLimit
+	}
 	candidates := results
-	if len(candidates) > rerankDocLimit {
-		candidates = candidates[:rerankDocLimit]
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
 	}
 
 	// 记录每个候选的RRF排名（1-indexed）
@@ -206,11 +698,14 @@ func (r *Retriever) rerank(query string, results []store.SearchResult) ([]store.
 		}
 	}
 
-	// 调用LLM重排
-	rerankResults, err := r.llm.Rerank(query, docs)
+	// 调用LLM重排（已缓存的分数直接复用）
+	rerankResults, err := r.rerankWithCache(ctx, query, docs, trace)
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// 创建索引映射
 	indexMap := make(map[string]int)
@@ -218,10 +713,8 @@ func (r *Retriever) rerank(query string, results []store.SearchResult) ([]store.
 		indexMap[res.ID] = i
 	}
 
-	// Position-aware blending: 混合 RRF 位置分数和重排器分数
-	// 排名 1-3:  75% RRF, 25% reranker（信任检索对精确匹配的判断）
-	// 排名 4-10: 60% RRF, 40% reranker
-	// 排名 11+:  40% RRF, 60% reranker（信任重排器对低排名的判断）
+	// Position-aware blending: 混合 RRF 位置分数和重排器分数，权重按 RRF 排名分段（见 BlendWeights）
+	// 默认排名 1-3 75% RRF，4-10 60%，11+ 40%：靠前的信任检索对精确匹配的判断，靠后的信任重排器
 	reranked := make([]store.SearchResult, 0, len(rerankResults))
 	for _, rr := range rerankResults {
 		idx, ok := indexMap[rr.ID]
@@ -240,22 +733,8 @@ func (r *Retriever) rerank(query string, results []store.SearchResult) ([]store.
 		if rrfRank == 0 {
 			rrfRank = 30 // 默认排名
 		}
-
-		// 根据RRF排名选择混合权重
-		var rrfWeight float64
-		switch {
-		case rrfRank <= 3:
-			rrfWeight = 0.75
-		case rrfRank <= 10:
-			rrfWeight = 0.60
-		default:
-			rrfWeight = 0.40
-		}
-
-		// RRF位置分数：1/rank
-		rrfScore := 1.0 / float64(rrfRank)
-		// 混合最终分数
-		blendedScore := rrfWeight*rrfScore + (1-rrfWeight)*rr.Score
+		blendedScore := r.blend.Blend(rrfRank, rr.Score)
+		trace.reranked(result, rrfRank, rr.Score, r.blend.weight(rrfRank), blendedScore)
 
 		result.Score = blendedScore
 		result.Source = "rerank"
@@ -281,10 +760,13 @@ func (r *Retriever) toContexts(results []store.SearchResult) []Context {
 			Relevance: res.Score,
 			Metadata: map[string]interface{}{
 				"title":      res.Title,
+				"hash":       res.Hash,
 				"collection": res.Collection,
 				"path":       res.Path,
 				"snippet":    res.Snippet,
 				"source":     res.Source,
+				"language":   res.Language,
+				"date":       res.Date,
 				"timestamp":  res.Timestamp,
 			},
 		}
@@ -293,6 +775,21 @@ func (r *Retriever) toContexts(results []store.SearchResult) []Context {
 	return contexts
 }
 
+// SourceDocIDs 返回上下文来源文档的 docid（哈希前 6 位，去重保序）
+func SourceDocIDs(contexts []Context) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, ctx := range contexts {
+		hash, _ := ctx.Metadata["hash"].(string)
+		if len(hash) < 6 || seen[hash[:6]] {
+			continue
+		}
+		seen[hash[:6]] = true
+		ids = append(ids, hash[:6])
+	}
+	return ids
+}
+
 // AdaptiveRetrieve 自适应检索（根据查询类型选择策略）
 func (r *Retriever) AdaptiveRetrieve(query string, opts RetrieveOptions) ([]Context, error) {
 	// 检测查询类型
@@ -365,6 +862,9 @@ func (r *Retriever) retrieveWithExpansion(query string, opts RetrieveOptions) ([
 	//    如果 top score >= 0.85 且与第二名差距 >= 0.15，说明 BM25 已有精确匹配，
 	//    跳过昂贵的 LLM query expansion
 	initialFTS, _ := r.retrieveFTS(query, opts)
+	if err := opts.ctx.Err(); err != nil {
+		return nil, err
+	}
 	if len(initialFTS) > 0 {
 		topScore := initialFTS[0].Score
 		secondScore := 0.0
@@ -372,60 +872,60 @@ func (r *Retriever) retrieveWithExpansion(query string, opts RetrieveOptions) ([
 			secondScore = initialFTS[1].Score
 		}
 		if topScore >= 0.85 && (topScore-secondScore) >= 0.15 {
-			fmt.Printf("Strong BM25 signal (%.2f) — skipping query expansion\n", topScore)
+			msg := fmt.Sprintf("Strong BM25 signal (%.2f) — skipping query expansion", topScore)
+			opts.trace.note("%s", msg)
+			if r.logger != nil {
+				r.logger.Info(msg)
+			} else {
+				fmt.Println(msg)
+			}
 			return r.retrieveSingleQuery(query, opts)
 		}
 	}
 
 	// 1. 扩展查询（带缓存）
-	expansions, err := r.expandQueryWithCache(query)
+	start := time.Now()
+	expansions, err := r.expandQueryWithCache(opts.ctx, query)
+	if err == nil {
+		err = opts.record(StageExpand, start)
+	}
+	if cerr := opts.ctx.Err(); cerr != nil {
+		return nil, cerr
+	}
 	if err != nil {
 		// 如果扩展失败，回退到原始查询
+		opts.trace.note("query expansion failed, using the original query: %v", err)
 		return r.retrieveSingleQuery(query, opts)
 	}
+	opts.trace.expanded(expansions)
 
 	if len(expansions) == 0 {
 		return r.retrieveSingleQuery(query, opts)
 	}
 
-	type expansionResult struct {
-		results []store.SearchResult
-		weight  float64
-	}
-
-	ch := make(chan expansionResult, len(expansions))
-	var wg sync.WaitGroup
-	for _, exp := range expansions {
-		exp := exp
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			var results []store.SearchResult
-			var err error
-
-			switch exp.Type {
-			case "lex":
-				results, err = r.retrieveFTS(exp.Text, opts)
-			case "vec", "hyde":
-				results, err = r.retrieveVector(exp.Text, opts)
-			default:
-				results, err = r.retrieveHybrid(exp.Text, opts)
-			}
-
-			if err == nil && len(results) > 0 {
-				ch <- expansionResult{results: results, weight: exp.Weight}
-			}
-		}()
-	}
-
-	wg.Wait()
-	close(ch)
+	// 2. 并发检索各变体，按扩展顺序融合，失败或无结果的变体跳过
+	lists, errs := runLegs(len(expansions), func(i int) ([]store.SearchResult, error) {
+		exp := expansions[i]
+		switch exp.Type {
+		case "lex":
+			return r.retrieveFTS(exp.Text, opts)
+		case "vec", "hyde":
+			return r.retrieveVector(exp.Text, opts)
+		default:
+			return r.retrieveHybrid(exp.Text, opts)
+		}
+	})
 
 	var allResultLists [][]store.SearchResult
 	var weights []float64
-	for res := range ch {
-		allResultLists = append(allResultLists, res.results)
-		weights = append(weights, res.weight)
+	var labels []string
+	for i, exp := range expansions {
+		if errs[i] != nil || len(lists[i]) == 0 {
+			continue
+		}
+		allResultLists = append(allResultLists, lists[i])
+		weights = append(weights, exp.Weight)
+		labels = append(labels, exp.Type+": "+exp.Text)
 	}
 
 	// 3. 如果所有查询都失败，使用原始查询
@@ -434,7 +934,12 @@ func (r *Retriever) retrieveWithExpansion(query string, opts RetrieveOptions) ([
 	}
 
 	// 4. 使用 RRF 融合所有结果
+	start = time.Now()
 	fused := store.ReciprocalRankFusion(allResultLists, weights, opts.RRFK)
+	if err := opts.record(StageFusion, start); err != nil {
+		return nil, err
+	}
+	opts.trace.fusion(labels, allResultLists, weights, opts.RRFK, fused)
 
 	return fused, nil
 }
@@ -453,8 +958,8 @@ func (r *Retriever) retrieveSingleQuery(query string, opts RetrieveOptions) ([]s
 	}
 }
 
-// expandQueryWithCache 扩展查询并使用缓存
-func (r *Retriever) expandQueryWithCache(query string) ([]llm.QueryExpansion, error) {
+// expandQueryWithCache 扩展查询并使用缓存；ctx 结束时不再调用 LLM
+func (r *Retriever) expandQueryWithCache(ctx context.Context, query string) ([]llm.QueryExpansion, error) {
 	// 生成缓存键
 	cacheKey := store.CacheKey("expandQuery", map[string]string{"query": query})
 
@@ -469,6 +974,9 @@ func (r *Retriever) expandQueryWithCache(query string) ([]llm.QueryExpansion, er
 	}
 
 	// 调用 LLM 扩展查询
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	expansions, err := r.llm.ExpandQuery(query)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,84 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Turn 一轮对话，用于改写追问
+type Turn struct {
+	User      string
+	Assistant string
+}
+
+// rewriteQueryPrompt 把追问改写为独立查询的 prompt
+const rewriteQueryPrompt = `根据对话历史，把用户的最新问题改写成一个可以独立理解的检索查询。
+- 把代词和省略的内容（如"它"、"这个"、"那性能呢"）替换为历史中指代的具体对象
+- 如果问题本身已经完整，原样输出
+- 保持与问题相同的语言，不要回答问题
+- 只输出改写后的查询，不要加引号或解释
+
+[对话历史]
+%s
+[最新问题]
+%s
+
+改写后的查询：`
+
+// maxRewriteTurns 改写时参考的最近轮数
+const maxRewriteTurns = 3
+
+// RewriteQuery 结合最近几轮对话把追问改写为独立查询
+// 没有历史或改写结果不可用时返回原查询
+func RewriteQuery(query string, history []Turn, generate func(prompt string) (string, error)) (string, error) {
+	query = strings.TrimSpace(query)
+	if len(history) == 0 || generate == nil {
+		return query, nil
+	}
+	if len(history) > maxRewriteTurns {
+		history = history[len(history)-maxRewriteTurns:]
+	}
+
+	var b strings.Builder
+	for _, turn := range history {
+		fmt.Fprintf(&b, "用户: %s\n", truncateRunes(turn.User, 300))
+		fmt.Fprintf(&b, "助手: %s\n", truncateRunes(turn.Assistant, 300))
+	}
+
+	output, err := generate(fmt.Sprintf(rewriteQueryPrompt, b.String(), query))
+	if err != nil {
+		return query, fmt.Errorf("failed to rewrite query: %w", err)
+	}
+
+	rewritten := cleanRewrite(output)
+	// 空结果或明显跑题的长回答视为改写失败
+	if rewritten == "" || utf8.RuneCountInString(rewritten) > 4*utf8.RuneCountInString(query)+100 {
+		return query, nil
+	}
+	return rewritten, nil
+}
+
+// cleanRewrite 取第一行非空输出并去掉常见前缀和引号
+func cleanRewrite(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		for _, prefix := range []string{"改写后的查询：", "改写后的查询:", "查询：", "查询:", "Query:"} {
+			line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+		}
+		return strings.Trim(line, "\"'“”「」`")
+	}
+	return ""
+}
+
+// truncateRunes 按字符截断
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
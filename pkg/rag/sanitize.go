@@ -0,0 +1,167 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SanitizeLevel 注入过滤的严格程度
+type SanitizeLevel string
+
+const (
+	// SanitizeOff 不做任何处理
+	SanitizeOff SanitizeLevel = "off"
+	// SanitizeFlag 移除不可见字符，保留可疑内容但加上警示标记
+	SanitizeFlag SanitizeLevel = "flag"
+	// SanitizeStrip 移除不可见字符和可疑内容（默认）；普通文本中的 "system:" 等行首角色名只标记不移除
+	SanitizeStrip SanitizeLevel = "strip"
+)
+
+// ParseSanitizeLevel 解析过滤级别，空字符串返回默认级别
+func ParseSanitizeLevel(s string) (SanitizeLevel, error) {
+	switch level := SanitizeLevel(strings.ToLower(strings.TrimSpace(s))); level {
+	case "":
+		return SanitizeStrip, nil
+	case SanitizeOff, SanitizeFlag, SanitizeStrip:
+		return level, nil
+	default:
+		return "", fmt.Errorf("invalid sanitize level: %s (use off, flag or strip)", s)
+	}
+}
+
+// 可疑内容类别
+const (
+	FindingInstruction = "instruction" // 面向模型的指令（如"忽略之前的指令"）
+	FindingRoleMarker  = "role_marker" // 伪造的角色/对话分隔标记
+	FindingToolCall    = "tool_call"   // 类似工具调用的结构
+)
+
+// SanitizeFinding 一处可疑内容
+type SanitizeFinding struct {
+	Kind  string `json:"kind"`
+	Match string `json:"match"`
+}
+
+// SanitizeReport 过滤报告
+type SanitizeReport struct {
+	InvisibleChars int               `json:"invisible_chars"` // 移除的零宽/方向控制字符数
+	Findings       []SanitizeFinding `json:"findings,omitempty"`
+}
+
+// Empty 是否没有发现任何问题
+func (r SanitizeReport) Empty() bool {
+	return r.InvisibleChars == 0 && len(r.Findings) == 0
+}
+
+// Merge 合并另一份报告
+func (r *SanitizeReport) Merge(other SanitizeReport) {
+	r.InvisibleChars += other.InvisibleChars
+	r.Findings = append(r.Findings, other.Findings...)
+}
+
+// String 报告摘要
+func (r SanitizeReport) String() string {
+	if r.Empty() {
+		return "clean"
+	}
+
+	counts := make(map[string]int)
+	for _, f := range r.Findings {
+		counts[f.Kind]++
+	}
+
+	var parts []string
+	for _, kind := range []string{FindingInstruction, FindingRoleMarker, FindingToolCall} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", kind, counts[kind]))
+		}
+	}
+	if r.InvisibleChars > 0 {
+		parts = append(parts, fmt.Sprintf("invisible=%d", r.InvisibleChars))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sanitizePattern 可疑内容模式
+type sanitizePattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var sanitizePatterns = []sanitizePattern{
+	// 面向模型的指令
+	{FindingInstruction, regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,40}\b(previous|prior|above|earlier|all|any|system)\b[^.\n]{0,20}\b(instructions?|prompts?|rules?|messages?)\b`)},
+	{FindingInstruction, regexp.MustCompile(`(?i)\byou are now\b|\bfrom now on,? you\b|\bnew instructions?:`)},
+	{FindingInstruction, regexp.MustCompile(`(?i)\b(reveal|print|show|repeat)\b[^.\n]{0,30}\bsystem prompt\b`)},
+	{FindingInstruction, regexp.MustCompile(`(忽略|无视|忘记|忘掉)[^。\n]{0,20}(之前|以上|上面|前面|所有|系统)[^。\n]{0,10}(指令|指示|提示|规则|设定)`)},
+	{FindingInstruction, regexp.MustCompile(`(从现在起|从现在开始)[，,]?你|你现在是一个|(输出|打印|泄露|重复)[^。\n]{0,10}系统提示`)},
+
+	// 角色/对话分隔标记
+	{FindingRoleMarker, regexp.MustCompile(`<\|(im_start|im_end|system|user|assistant|endoftext)\|>|\[/?INST\]|<</?SYS>>|</s>`)},
+
+	// 工具调用结构
+	{FindingToolCall, regexp.MustCompile(`(?i)</?(tool_call|function_call|function_calls|invoke|tool_use)\b[^>]*>`)},
+	{FindingToolCall, regexp.MustCompile(`(?i)"(tool_calls?|function_call)"\s*:`)},
+}
+
+// roleLinePattern 行首的角色名（如 "system:"），正常文档中也常见（对话记录、配置说明），
+// strip 级别下也只标记不移除；只有对话模板的特殊标记会被移除
+var roleLinePattern = sanitizePattern{FindingRoleMarker, regexp.MustCompile(`(?im)^\s*(system|assistant|developer)\s*[:：]`)}
+
+// isInvisible 是否为零宽或双向文本控制字符
+// 零宽非连接符/连接符（U+200C/U+200D）和软连字符（U+00AD）是正常文字的一部分
+// （波斯语、印地语、emoji 组合序列、断词），不会移除
+func isInvisible(r rune) bool {
+	switch {
+	case r == 0x200B: // 零宽空格
+		return true
+	case r == 0x200E, r == 0x200F: // LRM/RLM
+		return true
+	case r >= 0x202A && r <= 0x202E: // 双向嵌入/覆盖
+		return true
+	case r >= 0x2060 && r <= 0x2064: // 词连接符、不可见运算符
+		return true
+	case r >= 0x2066 && r <= 0x2069: // 双向隔离
+		return true
+	case r == 0xFEFF: // BOM
+		return true
+	}
+	return false
+}
+
+// Sanitize 按级别过滤检索内容，返回处理后的文本和报告
+func Sanitize(text string, level SanitizeLevel) (string, SanitizeReport) {
+	var report SanitizeReport
+	if level == SanitizeOff || text == "" {
+		return text, report
+	}
+
+	// 1. 移除不可见字符
+	text = strings.Map(func(r rune) rune {
+		if isInvisible(r) {
+			report.InvisibleChars++
+			return -1
+		}
+		return r
+	}, text)
+
+	// 2. 检测可疑模式
+	for _, p := range sanitizePatterns {
+		text = replaceSuspicious(text, p, level, &report)
+	}
+	text = replaceSuspicious(text, roleLinePattern, SanitizeFlag, &report)
+
+	return text, report
+}
+
+// replaceSuspicious 移除或标记匹配的可疑内容，并记录到报告
+func replaceSuspicious(text string, p sanitizePattern, level SanitizeLevel, report *SanitizeReport) string {
+	return p.re.ReplaceAllStringFunc(text, func(match string) string {
+		report.Findings = append(report.Findings, SanitizeFinding{Kind: p.kind, Match: match})
+		if level == SanitizeFlag {
+			return "[⚠可疑内容: " + match + "]"
+		}
+		return "[已移除]"
+	})
+}
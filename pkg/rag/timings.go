@@ -0,0 +1,82 @@
+package rag
+
+import (
+	"sync"
+	"time"
+)
+
+// Stage 检索阶段
+type Stage string
+
+const (
+	StageExpand Stage = "expand" // 查询扩展
+	StageEmbed  Stage = "embed"  // 生成查询嵌入
+	StageFTS    Stage = "fts"    // BM25 检索
+	StageVector Stage = "vector" // 向量检索
+	StageFusion Stage = "fusion" // RRF 融合
+	StageRerank Stage = "rerank" // 重排序
+)
+
+// Timings 各阶段耗时
+// 并行执行的阶段（混合检索的两路、查询扩展的多路）累计各路耗时，因此各阶段之和可能大于 Total
+type Timings struct {
+	mu      sync.Mutex
+	sealed  bool // Retrieve 返回后被放弃的阶段不再记录
+	Stages  map[Stage]time.Duration
+	Skipped []Stage // 因超出 Timeout 被跳过的阶段
+	Total   time.Duration
+}
+
+// Degraded 是否因超时跳过了部分阶段
+func (t *Timings) Degraded() bool {
+	return len(t.Skipped) > 0
+}
+
+// skip 记录被跳过的阶段（nil 安全）
+func (t *Timings) skip(stages []Stage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Skipped = append(t.Skipped, stages...)
+	}
+	t.mu.Unlock()
+}
+
+// add 累加阶段耗时（nil 安全）
+func (t *Timings) add(stage Stage, start time.Time) {
+	if t == nil {
+		return
+	}
+	d := time.Since(start)
+	t.mu.Lock()
+	if !t.sealed {
+		t.Stages[stage] += d
+	}
+	t.mu.Unlock()
+}
+
+// seal 停止记录（nil 安全），之后可以不加锁读取
+func (t *Timings) seal() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.sealed = true
+	t.mu.Unlock()
+}
+
+// RetrieveWithTimings 执行检索并返回各阶段耗时
+func (r *Retriever) RetrieveWithTimings(query string, opts RetrieveOptions) ([]Context, *Timings, error) {
+	start := time.Now()
+	timings := &Timings{Stages: make(map[Stage]time.Duration)}
+	opts.timings = timings
+
+	contexts, err := r.Retrieve(query, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	timings.Total = time.Since(start)
+	return contexts, timings, nil
+}
@@ -0,0 +1,206 @@
+package rag
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dyike/mmq/pkg/llm"
+	"github.com/dyike/mmq/pkg/store"
+)
+
+// Trace 一次检索的完整过程：查询扩展、各路候选及原始分数、融合表、重排分数和最终结果
+// 由 RetrieveWithTrace 填充，用于问题报告；各方法对 nil 安全，未开启追踪时不产生开销
+type Trace struct {
+	mu     sync.Mutex
+	sealed bool // Retrieve 返回后被放弃的阶段不再记录
+
+	Query      string                  `json:"query"`
+	Expansions []llm.QueryExpansion    `json:"expansions,omitempty"`
+	Legs       []TraceLeg              `json:"legs"`
+	Fusions    []TraceFusion           `json:"fusions,omitempty"`
+	Rerank     []TraceRerank           `json:"rerank,omitempty"`
+	Final      []TraceCandidate        `json:"final"`
+	Notes      []string                `json:"notes,omitempty"` // 跳过扩展、超时降级、MinScore 过滤等
+	Timings    map[Stage]time.Duration `json:"timings,omitempty"`
+	Total      time.Duration           `json:"total"`
+}
+
+// TraceLeg 一路检索（fts 或某个嵌入模型的向量检索）的候选
+type TraceLeg struct {
+	Kind       string           `json:"kind"`            // fts 或 vector
+	Model      string           `json:"model,omitempty"` // 集合专用嵌入模型，空为默认模型
+	Query      string           `json:"query"`           // 该路实际使用的查询（扩展后可能不同）
+	Candidates []TraceCandidate `json:"candidates"`
+}
+
+// TraceCandidate 候选文档及其在该列表中的排名和原始分数
+type TraceCandidate struct {
+	Rank       int     `json:"rank"`
+	Collection string  `json:"collection"`
+	Path       string  `json:"path"`
+	Title      string  `json:"title,omitempty"`
+	Score      float64 `json:"score"`
+}
+
+// TraceFusion 一次 RRF 融合：输入各路、权重和融合后每个文档在各路中的排名
+type TraceFusion struct {
+	Inputs  []string         `json:"inputs"`
+	Weights []float64        `json:"weights,omitempty"`
+	K       int              `json:"k"`
+	Rows    []TraceFusionRow `json:"rows"`
+}
+
+// TraceFusionRow 融合表的一行，Ranks 与 Inputs 一一对应（0 表示该路没有此文档）
+type TraceFusionRow struct {
+	Rank       int     `json:"rank"`
+	Collection string  `json:"collection"`
+	Path       string  `json:"path"`
+	Score      float64 `json:"score"`
+	Ranks      []int   `json:"ranks"`
+}
+
+// TraceRerank 一个重排候选：RRF 排名、重排器分数、RRF 权重和混合后的分数
+type TraceRerank struct {
+	Collection  string  `json:"collection"`
+	Path        string  `json:"path"`
+	RRFRank     int     `json:"rrf_rank"`
+	RerankScore float64 `json:"rerank_score"`
+	RRFWeight   float64 `json:"rrf_weight"`
+	Blended     float64 `json:"blended"`
+}
+
+// RetrieveWithTrace 执行检索并记录完整过程（同时记录各阶段耗时）
+func (r *Retriever) RetrieveWithTrace(query string, opts RetrieveOptions) ([]Context, *Trace, error) {
+	trace := &Trace{Query: query}
+	opts.trace = trace
+
+	contexts, timings, err := r.RetrieveWithTimings(query, opts)
+	if err != nil {
+		return nil, trace, err
+	}
+
+	trace.Timings, trace.Total = timings.Stages, timings.Total
+	for _, stage := range timings.Skipped {
+		trace.Notes = append(trace.Notes, fmt.Sprintf("%s skipped: time budget exceeded", stage))
+	}
+	for i, c := range contexts {
+		trace.Final = append(trace.Final, TraceCandidate{
+			Rank:       i + 1,
+			Collection: fmt.Sprint(c.Metadata["collection"]),
+			Path:       fmt.Sprint(c.Metadata["path"]),
+			Title:      fmt.Sprint(c.Metadata["title"]),
+			Score:      c.Relevance,
+		})
+	}
+	return contexts, trace, nil
+}
+
+// traceCandidates 转换为带排名的候选列表
+func traceCandidates(results []store.SearchResult) []TraceCandidate {
+	candidates := make([]TraceCandidate, len(results))
+	for i, res := range results {
+		candidates[i] = TraceCandidate{Rank: i + 1, Collection: res.Collection, Path: res.Path, Title: res.Title, Score: res.Score}
+	}
+	return candidates
+}
+
+// leg 记录一路检索的候选
+func (t *Trace) leg(kind, model, query string, results []store.SearchResult) {
+	if t == nil {
+		return
+	}
+	leg := TraceLeg{Kind: kind, Model: model, Query: query, Candidates: traceCandidates(results)}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Legs = append(t.Legs, leg)
+	}
+	t.mu.Unlock()
+}
+
+// expanded 记录查询扩展的结果
+func (t *Trace) expanded(expansions []llm.QueryExpansion) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Expansions = append(t.Expansions, expansions...)
+	}
+	t.mu.Unlock()
+}
+
+// fusion 记录一次 RRF 融合，inputs 为各路的说明
+func (t *Trace) fusion(inputs []string, lists [][]store.SearchResult, weights []float64, k int, fused []store.SearchResult) {
+	if t == nil {
+		return
+	}
+	if k == 0 {
+		k = 60
+	}
+	ranks := make([]map[string]int, len(lists))
+	for i, list := range lists {
+		ranks[i] = make(map[string]int, len(list))
+		for j, res := range list {
+			key := res.Collection + "/" + res.Path
+			if _, ok := ranks[i][key]; !ok {
+				ranks[i][key] = j + 1
+			}
+		}
+	}
+
+	f := TraceFusion{Inputs: inputs, Weights: weights, K: k}
+	for i, res := range fused {
+		row := TraceFusionRow{Rank: i + 1, Collection: res.Collection, Path: res.Path, Score: res.Score, Ranks: make([]int, len(lists))}
+		for j := range lists {
+			row.Ranks[j] = ranks[j][res.Collection+"/"+res.Path]
+		}
+		f.Rows = append(f.Rows, row)
+	}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Fusions = append(t.Fusions, f)
+	}
+	t.mu.Unlock()
+}
+
+// reranked 记录一个重排候选的分数
+func (t *Trace) reranked(res store.SearchResult, rrfRank int, rerankScore, rrfWeight, blended float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Rerank = append(t.Rerank, TraceRerank{
+			Collection:  res.Collection,
+			Path:        res.Path,
+			RRFRank:     rrfRank,
+			RerankScore: rerankScore,
+			RRFWeight:   rrfWeight,
+			Blended:     blended,
+		})
+	}
+	t.mu.Unlock()
+}
+
+// seal 停止记录（nil 安全），之后由 RetrieveWithTrace 直接填写结果
+func (t *Trace) seal() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.sealed = true
+	t.mu.Unlock()
+}
+
+// note 记录检索过程中的决策
+func (t *Trace) note(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.sealed {
+		t.Notes = append(t.Notes, fmt.Sprintf(format, args...))
+	}
+	t.mu.Unlock()
+}
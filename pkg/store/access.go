@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// accessTimeLayout 定长的 UTC 时间格式，保证按字符串排序与按时间排序一致
+const accessTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// RecentDocument 最近查看过的文档
+type RecentDocument struct {
+	ID             int
+	Collection     string
+	Path           string
+	Title          string
+	Hash           string
+	AccessCount    int
+	LastAccessedAt time.Time
+}
+
+// RecordDocumentAccess 记录文档被查看一次（get、对话引用等），文档不存在时忽略
+func (s *Store) RecordDocumentAccess(collection, path string) error {
+	_, err := s.db.Exec(`
+		UPDATE documents SET access_count = access_count + 1, last_accessed_at = ?
+		WHERE collection = ? AND path = ? AND active = 1
+	`, time.Now().UTC().Format(accessTimeLayout), collection, NormalizeDocPath(path))
+	if err != nil {
+		return fmt.Errorf("failed to record document access: %w", err)
+	}
+	return nil
+}
+
+// RecentDocuments 按最近查看时间倒序返回文档，collection 为空时不限集合
+func (s *Store) RecentDocuments(collection string, limit int) ([]RecentDocument, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `
+		SELECT id, collection, path, title, hash, access_count, last_accessed_at
+		FROM documents
+		WHERE active = 1 AND last_accessed_at IS NOT NULL`
+	args := []interface{}{}
+	if collection != "" {
+		query += " AND collection = ?"
+		args = append(args, collection)
+	}
+	query += " ORDER BY last_accessed_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []RecentDocument
+	for rows.Next() {
+		var d RecentDocument
+		var accessed string
+		if err := rows.Scan(&d.ID, &d.Collection, &d.Path, &d.Title, &d.Hash, &d.AccessCount, &accessed); err != nil {
+			return nil, fmt.Errorf("failed to scan recent document: %w", err)
+		}
+		d.LastAccessedAt, _ = time.Parse(time.RFC3339Nano, accessed)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// DocumentAccessTimes 返回 since 之后查看过的文档的最近查看时间，键为 collection/path
+func (s *Store) DocumentAccessTimes(since time.Time) (map[string]time.Time, error) {
+	rows, err := s.db.Query(`
+		SELECT collection, path, last_accessed_at FROM documents
+		WHERE active = 1 AND last_accessed_at >= ?
+	`, since.UTC().Format(accessTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document access times: %w", err)
+	}
+	defer rows.Close()
+
+	times := make(map[string]time.Time)
+	for rows.Next() {
+		var collection, path, accessed string
+		if err := rows.Scan(&collection, &path, &accessed); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339Nano, accessed); err == nil {
+			times[collection+"/"+path] = t
+		}
+	}
+	return times, rows.Err()
+}
+
+// ClearDocumentAccess 清除所有文档的查看记录，返回清除的文档数
+func (s *Store) ClearDocumentAccess() (int64, error) {
+	res, err := s.db.Exec(`
+		UPDATE documents SET access_count = 0, last_accessed_at = NULL
+		WHERE last_accessed_at IS NOT NULL
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear document access: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// AnswerCacheEntry 语义回答缓存条目
+type AnswerCacheEntry struct {
+	ID            int64
+	Scope         string // 回答所依赖的设置（模型、人设等），不同范围互不命中
+	CorpusVersion int64
+	Question      string
+	Embedding     []float32
+	Answer        string
+	CreatedAt     time.Time
+	Hits          int
+}
+
+// PutAnswer 缓存回答，同时删除该范围内旧语料版本的条目（不会再命中）并按 maxEntries 淘汰最早的条目
+func (s *Store) PutAnswer(e AnswerCacheEntry, maxEntries int) error {
+	blob, err := serializeFloat32(e.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to serialize question embedding: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO answer_cache (scope, corpus_version, question, embedding, answer, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, e.Scope, e.CorpusVersion, e.Question, blob, e.Answer, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("failed to cache answer: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM answer_cache WHERE scope = ? AND corpus_version < ?`, e.Scope, e.CorpusVersion); err != nil {
+		return fmt.Errorf("failed to drop outdated answers: %w", err)
+	}
+	if maxEntries > 0 {
+		if _, err := tx.Exec(`
+			DELETE FROM answer_cache WHERE id NOT IN (
+				SELECT id FROM answer_cache ORDER BY id DESC LIMIT ?
+			)
+		`, maxEntries); err != nil {
+			return fmt.Errorf("failed to prune answer cache: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// AnswerCandidates 返回范围和语料版本都相同、且在 since 之后缓存的回答（最新的在前）
+func (s *Store) AnswerCandidates(scope string, corpusVersion int64, since time.Time) ([]AnswerCacheEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, question, embedding, answer, created_at, hits FROM answer_cache
+		WHERE scope = ? AND corpus_version = ? AND created_at >= ?
+		ORDER BY id DESC
+	`, scope, corpusVersion, since.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query answer cache: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AnswerCacheEntry
+	for rows.Next() {
+		e := AnswerCacheEntry{Scope: scope, CorpusVersion: corpusVersion}
+		var blob []byte
+		var createdAt string
+		if err := rows.Scan(&e.ID, &e.Question, &blob, &e.Answer, &createdAt, &e.Hits); err != nil {
+			return nil, fmt.Errorf("failed to scan cached answer: %w", err)
+		}
+		e.Embedding = blobToFloat32(blob)
+		e.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// RecordAnswerHit 记录缓存回答被复用
+func (s *Store) RecordAnswerHit(id int64) error {
+	if _, err := s.db.Exec(`UPDATE answer_cache SET hits = hits + 1 WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to record answer cache hit: %w", err)
+	}
+	return nil
+}
+
+// ClearAnswerCache 清空回答缓存，返回删除的条目数
+func (s *Store) ClearAnswerCache() (int, error) {
+	res, err := s.db.Exec(`DELETE FROM answer_cache`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear answer cache: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -2,10 +2,8 @@ package store
 
 import (
 	"crypto/sha256"
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	"time"
 )
 
 // CacheKey 生成缓存键
@@ -22,54 +20,31 @@ func CacheKey(operation string, params interface{}) string {
 
 // GetCachedResult 获取缓存结果
 func (s *Store) GetCachedResult(key string) (string, error) {
-	var result string
-	err := s.db.QueryRow(`
-		SELECT result FROM llm_cache
-		WHERE hash = ?
-	`, key).Scan(&result)
-
-	if err == sql.ErrNoRows {
-		return "", nil // 缓存未命中
-	}
-
-	if err != nil {
-		return "", fmt.Errorf("failed to get cached result: %w", err)
-	}
-
-	return result, nil
+	return s.cache.Get(key)
 }
 
 // SetCachedResult 设置缓存结果
 func (s *Store) SetCachedResult(key string, result string) error {
-	now := time.Now().UTC().Format(time.RFC3339)
-
-	_, err := s.db.Exec(`
-		INSERT OR REPLACE INTO llm_cache (hash, result, created_at)
-		VALUES (?, ?, ?)
-	`, key, result, now)
-
-	if err != nil {
-		return fmt.Errorf("failed to set cached result: %w", err)
-	}
-
-	return nil
+	return s.cache.Set(key, result)
 }
 
 // ClearCache 清空所有缓存
 func (s *Store) ClearCache() error {
-	_, err := s.db.Exec("DELETE FROM llm_cache")
-	if err != nil {
-		return fmt.Errorf("failed to clear cache: %w", err)
-	}
-	return nil
+	_, err := s.cache.Clear()
+	return err
 }
 
 // GetCacheStats 获取缓存统计
 func (s *Store) GetCacheStats() (int, error) {
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM llm_cache`).Scan(&count)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get cache stats: %w", err)
+	return s.cache.Count()
+}
+
+// SetLLMCache 替换 LLM 缓存后端，原后端会被关闭
+func (s *Store) SetLLMCache(cache LLMCache) error {
+	old := s.cache
+	s.cache = cache
+	if old != nil {
+		return old.Close()
 	}
-	return count, nil
+	return nil
 }
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// catalog 活跃文档的内存目录（docid↔路径↔标题），首次使用时加载，文档写入时失效
+// 只感知本进程内的写入；其他进程修改数据库后需调用 InvalidateCatalog
+type catalog struct {
+	mu      sync.RWMutex
+	loaded  bool
+	entries []DocumentListEntry // 按 collection、path 排序
+	byPath  map[string]int      // collection/path -> entries 下标
+}
+
+// InvalidateCatalog 使文档目录缓存失效，下次访问时重新加载
+func (s *Store) InvalidateCatalog() {
+	s.catalog.mu.Lock()
+	s.catalog.loaded = false
+	s.catalog.entries = nil
+	s.catalog.byPath = nil
+	s.catalog.mu.Unlock()
+}
+
+// Catalog 返回所有活跃文档的目录条目（按集合、路径排序），返回的切片不可修改
+func (s *Store) Catalog() ([]DocumentListEntry, error) {
+	entries, _, err := s.catalogSnapshot()
+	return entries, err
+}
+
+// catalogSnapshot 返回目录及其路径索引，未加载时从数据库加载
+func (s *Store) catalogSnapshot() ([]DocumentListEntry, map[string]int, error) {
+	s.catalog.mu.RLock()
+	if s.catalog.loaded {
+		entries, byPath := s.catalog.entries, s.catalog.byPath
+		s.catalog.mu.RUnlock()
+		return entries, byPath, nil
+	}
+	s.catalog.mu.RUnlock()
+
+	s.catalog.mu.Lock()
+	defer s.catalog.mu.Unlock()
+	if s.catalog.loaded {
+		return s.catalog.entries, s.catalog.byPath, nil
+	}
+
+	entries, err := s.queryCatalog()
+	if err != nil {
+		return nil, nil, err
+	}
+	byPath := make(map[string]int, len(entries))
+	for i, e := range entries {
+		byPath[e.Collection+"/"+e.Path] = i
+	}
+	s.catalog.entries = entries
+	s.catalog.byPath = byPath
+	s.catalog.loaded = true
+	return entries, byPath, nil
+}
+
+// LookupDocument 通过 docid（#abc123 或 abc123）或路径（collection/path、mmq://collection/path）查找目录条目
+func (s *Store) LookupDocument(ref string) (*DocumentListEntry, error) {
+	entries, byPath, err := s.catalogSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(ref, "#") || isHexPrefix(ref) {
+		hash := strings.TrimPrefix(ref, "#")
+		var found *DocumentListEntry
+		for i := range entries {
+			if strings.HasPrefix(entries[i].Hash, hash) {
+				if found != nil {
+					return nil, fmt.Errorf("ambiguous docid: %s", ref)
+				}
+				found = &entries[i]
+			}
+		}
+		if found != nil {
+			e := *found
+			return &e, nil
+		}
+		if strings.HasPrefix(ref, "#") {
+			return nil, fmt.Errorf("document not found: %s", ref)
+		}
+	}
+
+	collection, path := s.resolveDocRef(ref)
+	idx, ok := byPath[collection+"/"+path]
+	if !ok && s.pathNoCase {
+		for i, e := range entries {
+			if e.Collection == collection && strings.EqualFold(e.Path, path) {
+				idx, ok = i, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("document not found: %s", ref)
+	}
+	e := entries[idx]
+	return &e, nil
+}
+
+// isHexPrefix 是否像不带 # 的短 docid（6 位以上十六进制）
+func isHexPrefix(s string) bool {
+	if len(s) < 6 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ChangeCounts 某个起点之后的变更计数
+type ChangeCounts struct {
+	Events       map[string]int            // 事件类型 → 次数
+	Collections  map[string]map[string]int // 集合 → 文档事件类型 → 次数
+	EmbeddedDocs int                       // 生成了嵌入的内容数
+	EmbeddedRows int                       // 生成的分块向量数
+	LastEventID  int64                     // 当前最大的事件 ID
+}
+
+// EventTime 返回事件发生的时间，事件不存在时返回零值
+func (s *Store) EventTime(id int64) (time.Time, error) {
+	var t string
+	err := s.db.QueryRow("SELECT time FROM events WHERE id = ?", id).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read event %d: %w", id, err)
+	}
+	parsed, _ := time.Parse(time.RFC3339, t)
+	return parsed, nil
+}
+
+// CountChangesSince 统计事件 ID 大于 afterID（afterID 为 0 时按 since 时间）的事件，以及 since 之后生成的向量
+// 嵌入不记录事件，总是按时间统计
+func (s *Store) CountChangesSince(afterID int64, since time.Time) (*ChangeCounts, error) {
+	counts := &ChangeCounts{
+		Events:      make(map[string]int),
+		Collections: make(map[string]map[string]int),
+	}
+	sinceStr := since.UTC().Format(time.RFC3339)
+
+	cond, arg := "time >= ?", interface{}(sinceStr)
+	if afterID > 0 {
+		cond, arg = "id > ?", afterID
+	}
+	rows, err := s.db.Query(`
+		SELECT type, collection, COUNT(*) FROM events
+		WHERE `+cond+`
+		GROUP BY type, collection
+	`, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var eventType, collection string
+		var n int
+		if err := rows.Scan(&eventType, &collection, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan event counts: %w", err)
+		}
+		counts.Events[eventType] += n
+		switch eventType {
+		case EventDocAdded, EventDocUpdated, EventDocRemoved:
+			if counts.Collections[collection] == nil {
+				counts.Collections[collection] = make(map[string]int)
+			}
+			counts.Collections[collection][eventType] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.QueryRow(`
+		SELECT COUNT(DISTINCT hash), COUNT(*) FROM (
+			SELECT hash FROM content_vectors WHERE embedded_at >= ?
+			UNION ALL
+			SELECT hash FROM model_vectors WHERE embedded_at >= ?
+		)
+	`, sinceStr, sinceStr).Scan(&counts.EmbeddedDocs, &counts.EmbeddedRows); err != nil {
+		return nil, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+
+	if counts.LastEventID, err = s.maxEventID(); err != nil {
+		return nil, fmt.Errorf("failed to read last event: %w", err)
+	}
+	return counts, nil
+}
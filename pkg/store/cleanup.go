@@ -15,12 +15,22 @@ type CleanupResult struct {
 func (s *Store) Cleanup() (*CleanupResult, error) {
 	result := &CleanupResult{}
 
-	// 1. 清除 LLM 缓存
+	// 1. 清除 LLM 缓存、回答缓存和重排分数缓存
 	count, err := s.deleteLLMCache()
 	if err != nil {
 		return nil, fmt.Errorf("delete LLM cache: %w", err)
 	}
 	result.CacheDeleted = count
+	count, err = s.ClearAnswerCache()
+	if err != nil {
+		return nil, err
+	}
+	result.CacheDeleted += count
+	count, err = s.ClearRerankCache()
+	if err != nil {
+		return nil, err
+	}
+	result.CacheDeleted += count
 
 	// 2. 删除非活跃文档
 	count, err = s.deleteInactiveDocuments()
@@ -54,12 +64,7 @@ func (s *Store) Cleanup() (*CleanupResult, error) {
 
 // deleteLLMCache 清除所有 LLM 缓存
 func (s *Store) deleteLLMCache() (int, error) {
-	res, err := s.db.Exec("DELETE FROM llm_cache")
-	if err != nil {
-		return 0, err
-	}
-	n, _ := res.RowsAffected()
-	return int(n), nil
+	return s.cache.Clear()
 }
 
 // deleteInactiveDocuments 删除 active=0 的文档
@@ -77,6 +82,7 @@ func (s *Store) cleanupOrphanedContent() (int, error) {
 	res, err := s.db.Exec(`
 		DELETE FROM content
 		WHERE hash NOT IN (SELECT DISTINCT hash FROM documents)
+		  AND hash NOT IN (SELECT DISTINCT hash FROM index_staging)
 	`)
 	if err != nil {
 		return 0, err
@@ -95,7 +101,18 @@ func (s *Store) cleanupOrphanedVectors() (int, error) {
 		return 0, err
 	}
 	n, _ := res.RowsAffected()
-	return int(n), nil
+
+	// 专用嵌入模型的向量
+	res, err = s.db.Exec(`
+		DELETE FROM model_vectors
+		WHERE hash NOT IN (SELECT DISTINCT hash FROM content)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	m, _ := res.RowsAffected()
+
+	return int(n + m), nil
 }
 
 // vacuum 压缩数据库文件
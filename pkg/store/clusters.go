@@ -0,0 +1,118 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cluster 集合中的一个文档聚类
+type Cluster struct {
+	Collection string
+	ID         int
+	Label      string
+	Size       int
+	CreatedAt  time.Time
+}
+
+// ClusterAssignment 文档所属的聚类及与聚类中心的余弦距离
+type ClusterAssignment struct {
+	Path     string
+	Title    string // 读取时填充
+	Cluster  int
+	Distance float64
+}
+
+// ReplaceClusters 用新的聚类结果替换集合原有的聚类
+func (s *Store) ReplaceClusters(collection string, clusters []Cluster, assignments []ClusterAssignment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"clusters", "document_clusters"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE collection = ?", collection); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range clusters {
+		if _, err := tx.Exec(
+			"INSERT INTO clusters (collection, id, label, size, created_at) VALUES (?, ?, ?, ?, ?)",
+			collection, c.ID, c.Label, c.Size, now,
+		); err != nil {
+			return fmt.Errorf("failed to save cluster: %w", err)
+		}
+	}
+	for _, a := range assignments {
+		if _, err := tx.Exec(
+			"INSERT INTO document_clusters (collection, path, cluster, distance) VALUES (?, ?, ?, ?)",
+			collection, a.Path, a.Cluster, a.Distance,
+		); err != nil {
+			return fmt.Errorf("failed to save cluster assignment: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListClusters 返回聚类（collection 为空时返回全部集合），按集合和 id 排序
+func (s *Store) ListClusters(collection string) ([]Cluster, error) {
+	rows, err := s.db.Query(`
+		SELECT collection, id, label, size, created_at FROM clusters
+		WHERE ? = '' OR collection = ?
+		ORDER BY collection, id
+	`, collection, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clusters: %w", err)
+	}
+	defer rows.Close()
+
+	var clusters []Cluster
+	for rows.Next() {
+		var c Cluster
+		var createdAt string
+		if err := rows.Scan(&c.Collection, &c.ID, &c.Label, &c.Size, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster: %w", err)
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		clusters = append(clusters, c)
+	}
+	return clusters, rows.Err()
+}
+
+// SetClusterLabel 修改聚类的标签
+func (s *Store) SetClusterLabel(collection string, id int, label string) error {
+	res, err := s.db.Exec("UPDATE clusters SET label = ? WHERE collection = ? AND id = ?", label, collection, id)
+	if err != nil {
+		return fmt.Errorf("failed to update cluster label: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("cluster %d not found in collection '%s'", id, collection)
+	}
+	return nil
+}
+
+// ClusterAssignments 返回集合中各文档（路径）的聚类，id 为 0 时返回全部聚类，按与中心的距离排序
+func (s *Store) ClusterAssignments(collection string, id int) ([]ClusterAssignment, error) {
+	rows, err := s.db.Query(`
+		SELECT dc.path, d.title, dc.cluster, dc.distance FROM document_clusters dc
+		JOIN documents d ON d.collection = dc.collection AND d.path = dc.path AND d.active = 1
+		WHERE dc.collection = ? AND (? = 0 OR dc.cluster = ?)
+		ORDER BY dc.cluster, dc.distance
+	`, collection, id, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []ClusterAssignment
+	for rows.Next() {
+		var a ClusterAssignment
+		if err := rows.Scan(&a.Path, &a.Title, &a.Cluster, &a.Distance); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster assignment: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
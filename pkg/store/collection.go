@@ -14,6 +14,11 @@ type Collection struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	DocCount   int // 文档数量（统计信息）
+
+	Metadata map[string]interface{} // 自定义元数据（描述、负责人、同步游标等）
+
+	Remote           string // 远端 mmq 数据库路径，非空时为远端集合（不在本地索引，搜索时直接查询远端）
+	RemoteCollection string // 远端数据库中对应的集合名
 }
 
 // CreateCollection 创建集合
@@ -53,6 +58,9 @@ func (s *Store) ListCollections() ([]Collection, error) {
 			c.mask,
 			c.created_at,
 			c.updated_at,
+			c.metadata,
+			c.remote,
+			c.remote_collection,
 			COUNT(DISTINCT d.id) as doc_count
 		FROM collections c
 		LEFT JOIN documents d ON d.collection = c.name AND d.active = 1
@@ -67,7 +75,7 @@ func (s *Store) ListCollections() ([]Collection, error) {
 	var collections []Collection
 	for rows.Next() {
 		var c Collection
-		var createdAtStr, updatedAtStr string
+		var createdAtStr, updatedAtStr, metadata string
 
 		err := rows.Scan(
 			&c.Name,
@@ -75,6 +83,9 @@ func (s *Store) ListCollections() ([]Collection, error) {
 			&c.Mask,
 			&createdAtStr,
 			&updatedAtStr,
+			&metadata,
+			&c.Remote,
+			&c.RemoteCollection,
 			&c.DocCount,
 		)
 		if err != nil {
@@ -83,6 +94,7 @@ func (s *Store) ListCollections() ([]Collection, error) {
 
 		c.CreatedAt, _ = time.Parse(time.RFC3339, createdAtStr)
 		c.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAtStr)
+		c.Metadata = decodeCollectionMetadata(metadata)
 
 		collections = append(collections, c)
 	}
@@ -93,7 +105,7 @@ func (s *Store) ListCollections() ([]Collection, error) {
 // GetCollection 获取集合信息
 func (s *Store) GetCollection(name string) (*Collection, error) {
 	var c Collection
-	var createdAtStr, updatedAtStr string
+	var createdAtStr, updatedAtStr, metadata string
 	var docCount sql.NullInt64
 
 	err := s.db.QueryRow(`
@@ -103,6 +115,9 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 			c.mask,
 			c.created_at,
 			c.updated_at,
+			c.metadata,
+			c.remote,
+			c.remote_collection,
 			COUNT(DISTINCT d.id) as doc_count
 		FROM collections c
 		LEFT JOIN documents d ON d.collection = c.name AND d.active = 1
@@ -114,6 +129,9 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 		&c.Mask,
 		&createdAtStr,
 		&updatedAtStr,
+		&metadata,
+		&c.Remote,
+		&c.RemoteCollection,
 		&docCount,
 	)
 
@@ -126,6 +144,7 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 
 	c.CreatedAt, _ = time.Parse(time.RFC3339, createdAtStr)
 	c.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAtStr)
+	c.Metadata = decodeCollectionMetadata(metadata)
 	if docCount.Valid {
 		c.DocCount = int(docCount.Int64)
 	}
@@ -133,43 +152,160 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 	return &c, nil
 }
 
-// RemoveCollection 删除集合
+// RemoveStats 硬删除集合释放的数据
+type RemoveStats struct {
+	Documents  int   // 删除的文档记录（含之前已停用的）
+	Content    int   // 删除的不再被引用的内容
+	Vectors    int   // 删除的分块向量
+	Contexts   int   // 删除的集合及其路径上的上下文
+	FreedBytes int64 // 释放的内容和向量数据大小（VACUUM 后才会缩小数据库文件）
+}
+
+// RemoveCollection 删除集合（文档只停用，内容保留；仅被该集合引用的向量会删除）
 func (s *Store) RemoveCollection(name string) error {
+	_, err := s.removeCollection(name, false)
+	return err
+}
+
+// HardRemoveCollection 在一个事务内彻底删除集合：文档记录、不再被引用的内容、
+// 向量、集合的上下文和其他集合级数据
+func (s *Store) HardRemoveCollection(name string) (*RemoveStats, error) {
+	return s.removeCollection(name, true)
+}
+
+func (s *Store) removeCollection(name string, hard bool) (*RemoveStats, error) {
+	defer s.InvalidateCatalog()
+
 	// 先检查是否存在
 	_, err := s.GetCollection(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 开始事务
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// 删除集合的所有文档（设置为inactive）
-	_, err = tx.Exec("UPDATE documents SET active = 0 WHERE collection = ?", name)
+	stats := &RemoveStats{}
+	var before int64
+	if hard {
+		if before, err = storedBytes(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	// 删除集合的所有文档（软删除时设置为inactive）
+	if err := logDocumentEvents(tx, EventDocRemoved, s.actor, "collection = ? AND active = 1", name); err != nil {
+		return nil, err
+	}
+	activeOnly := " AND active = 1"
+	if hard {
+		activeOnly = ""
+	}
+	hashes, err := queryStrings(tx, "SELECT DISTINCT hash FROM documents WHERE collection = ?"+activeOnly, name)
 	if err != nil {
-		return fmt.Errorf("failed to deactivate documents: %w", err)
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	if hard {
+		res, err := tx.Exec("DELETE FROM documents WHERE collection = ?", name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete documents: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		stats.Documents = int(n)
+	} else if _, err := tx.Exec("UPDATE documents SET active = 0 WHERE collection = ?", name); err != nil {
+		return nil, fmt.Errorf("failed to deactivate documents: %w", err)
+	}
+	// 删除只被该集合引用的内容的向量
+	if stats.Vectors, err = purgeVectors(tx, hashes); err != nil {
+		return nil, err
+	}
+
+	if hard {
+		for _, hash := range hashes {
+			res, err := tx.Exec(`
+				DELETE FROM content WHERE hash = ?
+				  AND NOT EXISTS (SELECT 1 FROM documents WHERE hash = ?)
+				  AND NOT EXISTS (SELECT 1 FROM index_staging WHERE hash = ?)
+			`, hash, hash, hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete content: %w", err)
+			}
+			n, _ := res.RowsAffected()
+			stats.Content += int(n)
+		}
+
+		prefix := "mmq://" + name
+		res, err := tx.Exec("DELETE FROM contexts WHERE path = ? OR substr(path, 1, ?) = ?",
+			prefix, len(prefix)+1, prefix+"/")
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete contexts: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		stats.Contexts = int(n)
+
+		if _, err := tx.Exec("DELETE FROM index_staging WHERE collection = ?", name); err != nil {
+			return nil, fmt.Errorf("failed to delete staged documents: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM object_etags WHERE collection = ?", name); err != nil {
+		return nil, fmt.Errorf("failed to delete object etags: %w", err)
+	}
+	for _, table := range []string{"clusters", "document_clusters"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE collection = ?", name); err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %w", table, err)
+		}
 	}
 
 	// 删除集合记录
 	_, err = tx.Exec("DELETE FROM collections WHERE name = ?", name)
 	if err != nil {
-		return fmt.Errorf("failed to delete collection: %w", err)
+		return nil, fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	if hard {
+		after, err := storedBytes(tx)
+		if err != nil {
+			return nil, err
+		}
+		stats.FreedBytes = before - after
 	}
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.notifyChanged()
 
-	return nil
+	return stats, nil
+}
+
+// storedBytes 内容和向量数据的总大小（向量在元数据表和 vec 表中各存一份）
+func storedBytes(tx *sql.Tx) (int64, error) {
+	var content, vectors int64
+	if err := tx.QueryRow("SELECT COALESCE(SUM(LENGTH(doc)), 0) FROM content").Scan(&content); err != nil {
+		return 0, fmt.Errorf("failed to measure content: %w", err)
+	}
+	if err := tx.QueryRow(`
+		SELECT COALESCE(SUM(LENGTH(embedding)), 0) FROM (
+			SELECT embedding FROM content_vectors
+			UNION ALL
+			SELECT embedding FROM model_vectors
+		)
+	`).Scan(&vectors); err != nil {
+		return 0, fmt.Errorf("failed to measure vectors: %w", err)
+	}
+	return content + 2*vectors, nil
 }
 
 // RenameCollection 重命名集合
 func (s *Store) RenameCollection(oldName, newName string) error {
+	defer s.InvalidateCatalog()
+
 	// 检查旧集合是否存在
 	_, err := s.GetCollection(oldName)
 	if err != nil {
@@ -210,6 +346,17 @@ func (s *Store) RenameCollection(oldName, newName string) error {
 	if err != nil {
 		return fmt.Errorf("failed to update documents: %w", err)
 	}
+	if _, err := tx.Exec("UPDATE object_etags SET collection = ? WHERE collection = ?", newName, oldName); err != nil {
+		return fmt.Errorf("failed to update object etags: %w", err)
+	}
+	for _, table := range []string{"clusters", "document_clusters"} {
+		if _, err := tx.Exec("UPDATE "+table+" SET collection = ? WHERE collection = ?", newName, oldName); err != nil {
+			return fmt.Errorf("failed to update %s: %w", table, err)
+		}
+	}
+	if err := logEvent(tx, Event{Type: EventCollectionRenamed, Actor: s.actor, Collection: newName, Detail: oldName}); err != nil {
+		return err
+	}
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
@@ -266,3 +413,51 @@ func (s *Store) CollectionExists(name string) (bool, error) {
 
 	return exists > 0, nil
 }
+
+// GetCollectionPIIPolicy 获取集合的 PII 策略（空字符串表示使用默认策略）
+func (s *Store) GetCollectionPIIPolicy(name string) (string, error) {
+	var policy string
+	err := s.db.QueryRow("SELECT pii_policy FROM collections WHERE name = ?", name).Scan(&policy)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("collection '%s' not found", name)
+	}
+	return policy, err
+}
+
+// SetCollectionPIIPolicy 设置集合的 PII 策略
+func (s *Store) SetCollectionPIIPolicy(name, policy string) error {
+	result, err := s.db.Exec("UPDATE collections SET pii_policy = ? WHERE name = ?", policy, name)
+	if err != nil {
+		return fmt.Errorf("failed to set PII policy: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+	return nil
+}
+
+// GetCollectionIndexMode 获取集合的索引模式（空字符串表示保存全文）
+func (s *Store) GetCollectionIndexMode(name string) (string, error) {
+	var mode string
+	err := s.db.QueryRow("SELECT index_mode FROM collections WHERE name = ?", name).Scan(&mode)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("collection '%s' not found", name)
+	}
+	return mode, err
+}
+
+// SetCollectionIndexMode 设置集合的索引模式
+func (s *Store) SetCollectionIndexMode(name, mode string) error {
+	result, err := s.db.Exec("UPDATE collections SET index_mode = ? WHERE name = ?", mode, name)
+	if err != nil {
+		return fmt.Errorf("failed to set index mode: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+	return nil
+}
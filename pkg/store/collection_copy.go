@@ -0,0 +1,297 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy 合并时目标集合已有相同路径的处理方式
+type ConflictPolicy string
+
+const (
+	// ConflictSkip 保留目标集合中已有的文档
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite 用来源文档覆盖
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictNewer 保留修改时间较新的文档
+	ConflictNewer ConflictPolicy = "newer"
+	// ConflictRename 保留两者，来源文档改名为 name.<来源集合>.ext
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// ParseConflictPolicy 解析冲突策略，空字符串返回 ConflictSkip
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictOverwrite, ConflictNewer, ConflictRename:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy: %s (use skip, overwrite, newer or rename)", s)
+	}
+}
+
+// CopyStats 复制/合并结果
+type CopyStats struct {
+	Documents int // 写入的文档数
+	Conflicts int // 路径冲突数
+	Skipped   int // 因冲突跳过的文档数
+	Renamed   int // 因冲突改名的文档数
+	Contexts  int // 复制的上下文数
+}
+
+// copyDoc 待复制的文档行
+type copyDoc struct {
+	path, title, hash, createdAt, modifiedAt, language, date string
+}
+
+// CloneCollection 克隆集合（文档、上下文、集合设置）
+// 嵌入按内容哈希存储，克隆后无需重新生成
+func (s *Store) CloneCollection(src, dst string) (*CopyStats, error) {
+	defer s.InvalidateCatalog()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := requireCollection(tx, src); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := tx.Exec(`
+		INSERT OR IGNORE INTO collections (name, path, mask, created_at, updated_at, pii_policy, embed_model, metadata, index_mode, indexed_at)
+		SELECT ?, path, mask, ?, ?, pii_policy, embed_model, metadata, index_mode, indexed_at FROM collections WHERE name = ?
+	`, dst, now, now, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("collection '%s' already exists", dst)
+	}
+
+	stats := &CopyStats{}
+	if err := copyDocuments(tx, src, dst, ConflictOverwrite, stats, s.actor); err != nil {
+		return nil, err
+	}
+	if err := copyContexts(tx, src, dst, false, stats); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit clone: %w", err)
+	}
+	s.notifyChanged()
+	return stats, nil
+}
+
+// MergeCollections 将多个集合合并到 dst（不存在时创建），来源集合保持不变
+func (s *Store) MergeCollections(sources []string, dst string, policy ConflictPolicy) (*CopyStats, error) {
+	defer s.InvalidateCatalog()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, src := range sources {
+		if err := requireCollection(tx, src); err != nil {
+			return nil, err
+		}
+	}
+
+	// 合并结果跨多个目录，新建的目标集合没有源目录
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO collections (name, path, mask, created_at, updated_at)
+		VALUES (?, '', '**/*.md', ?, ?)
+	`, dst, now, now); err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	stats := &CopyStats{}
+	for _, src := range sources {
+		if src == dst {
+			continue
+		}
+		if err := copyDocuments(tx, src, dst, policy, stats, s.actor); err != nil {
+			return nil, err
+		}
+		if err := copyContexts(tx, src, dst, policy == ConflictOverwrite, stats); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE collections SET updated_at = ? WHERE name = ?", now, dst); err != nil {
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+	s.notifyChanged()
+	return stats, nil
+}
+
+// requireCollection 检查集合是否存在
+func requireCollection(tx *sql.Tx, name string) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE name = ?)", name).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check collection: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("collection not found: %s", name)
+	}
+	return nil
+}
+
+// copyDocuments 复制 src 的活跃文档到 dst，按策略处理路径冲突
+func copyDocuments(tx *sql.Tx, src, dst string, policy ConflictPolicy, stats *CopyStats, actor string) error {
+	docs, err := queryCopyDocs(tx, src)
+	if err != nil {
+		return err
+	}
+	existing, err := queryCopyDocs(tx, dst)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]copyDoc, len(existing))
+	for _, d := range existing {
+		taken[d.path] = d
+	}
+
+	for _, d := range docs {
+		eventType := EventDocAdded
+		if old, ok := taken[d.path]; ok {
+			stats.Conflicts++
+			switch policy {
+			case ConflictSkip:
+				stats.Skipped++
+				continue
+			case ConflictNewer:
+				if old.modifiedAt >= d.modifiedAt {
+					stats.Skipped++
+					continue
+				}
+			case ConflictRename:
+				d.path = renameConflict(d.path, src, taken)
+				stats.Renamed++
+			}
+			if d.path == old.path {
+				eventType = EventDocUpdated
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active, language, doc_date)
+			VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)
+			ON CONFLICT(collection, path) DO UPDATE SET
+				title = excluded.title,
+				hash = excluded.hash,
+				modified_at = excluded.modified_at,
+				active = 1,
+				language = excluded.language,
+				doc_date = excluded.doc_date
+		`, dst, d.path, d.title, d.hash, d.createdAt, d.modifiedAt, d.language, d.date)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s/%s: %w", src, d.path, err)
+		}
+		if old, ok := taken[d.path]; !ok || old.hash != d.hash || old.title != d.title {
+			if err := logEvent(tx, Event{Type: eventType, Actor: actor, Collection: dst, Path: d.path, Hash: d.hash}); err != nil {
+				return err
+			}
+		}
+		taken[d.path] = d
+		stats.Documents++
+	}
+	return nil
+}
+
+// queryCopyDocs 读取集合的活跃文档
+func queryCopyDocs(tx *sql.Tx, collection string) ([]copyDoc, error) {
+	rows, err := tx.Query(`
+		SELECT path, title, hash, created_at, modified_at, language, doc_date
+		FROM documents WHERE collection = ? AND active = 1
+		ORDER BY path
+	`, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []copyDoc
+	for rows.Next() {
+		var d copyDoc
+		if err := rows.Scan(&d.path, &d.title, &d.hash, &d.createdAt, &d.modifiedAt, &d.language, &d.date); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// renameConflict 生成不冲突的路径，如 notes/todo.md → notes/todo.<src>.md
+func renameConflict(p, src string, taken map[string]copyDoc) string {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	candidate := fmt.Sprintf("%s.%s%s", base, src, ext)
+	for i := 2; ; i++ {
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s.%s-%d%s", base, src, i, ext)
+	}
+}
+
+// copyContexts 复制 mmq://src 及其子路径的上下文到 mmq://dst
+func copyContexts(tx *sql.Tx, src, dst string, overwrite bool, stats *CopyStats) error {
+	srcPrefix := "mmq://" + src
+	rows, err := tx.Query(`
+		SELECT path, content FROM contexts
+		WHERE path = ? OR path LIKE ? ESCAPE '\'
+	`, srcPrefix, escapeLike(srcPrefix)+"/%")
+	if err != nil {
+		return fmt.Errorf("failed to query contexts: %w", err)
+	}
+
+	type entry struct{ path, content string }
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.path, &e.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan context: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		target := "mmq://" + dst + strings.TrimPrefix(e.path, srcPrefix)
+		stmt := `INSERT OR IGNORE INTO contexts (path, content, created_at, updated_at) VALUES (?, ?, ?, ?)`
+		if overwrite {
+			stmt = `INSERT OR REPLACE INTO contexts (path, content, created_at, updated_at) VALUES (?, ?, ?, ?)`
+		}
+		res, err := tx.Exec(stmt, target, e.content, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to copy context %s: %w", e.path, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			stats.Contexts++
+		}
+	}
+	return nil
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
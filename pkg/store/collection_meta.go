@@ -0,0 +1,70 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetCollectionMetadata 获取集合的自定义元数据
+func (s *Store) GetCollectionMetadata(name string) (map[string]interface{}, error) {
+	var metadata string
+	err := s.db.QueryRow("SELECT metadata FROM collections WHERE name = ?", name).Scan(&metadata)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("collection '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection metadata: %w", err)
+	}
+	return decodeCollectionMetadata(metadata), nil
+}
+
+// SetCollectionMetadata 合并写入集合元数据，值为 nil 的键被删除
+// 读取和写入在同一事务中，避免并发更新互相覆盖
+func (s *Store) SetCollectionMetadata(name string, values map[string]interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var metadata string
+	err = tx.QueryRow("SELECT metadata FROM collections WHERE name = ?", name).Scan(&metadata)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get collection metadata: %w", err)
+	}
+
+	merged := decodeCollectionMetadata(metadata)
+	for k, v := range values {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to encode collection metadata: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec("UPDATE collections SET metadata = ?, updated_at = ? WHERE name = ?", string(data), now, name); err != nil {
+		return fmt.Errorf("failed to set collection metadata: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// decodeCollectionMetadata 解析元数据 JSON，无效内容视为空
+func decodeCollectionMetadata(s string) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if s != "" {
+		json.Unmarshal([]byte(s), &metadata)
+	}
+	return metadata
+}
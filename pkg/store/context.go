@@ -3,8 +3,11 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 // ContextEntry 上下文条目
@@ -17,6 +20,10 @@ type ContextEntry struct {
 
 // AddContext 添加上下文
 func (s *Store) AddContext(path, content string) error {
+	if isGlobPattern(path) && !doublestar.ValidatePattern(path) {
+		return fmt.Errorf("invalid context pattern: %s", path)
+	}
+
 	now := time.Now().UTC().Format(time.RFC3339)
 
 	// 检查是否已存在
@@ -153,6 +160,11 @@ func (s *Store) GetContextsForPath(targetPath string) ([]ContextEntry, error) {
 		}
 	}
 
+	// 从最宽泛到最具体
+	sort.SliceStable(contexts, func(i, j int) bool {
+		return moreSpecific(contexts[j].Path, contexts[i].Path)
+	})
+
 	return contexts, nil
 }
 
@@ -200,12 +212,18 @@ func (s *Store) ContextExists(path string) (bool, error) {
 // - "/" 匹配所有路径（全局）
 // - "mmq://collection" 匹配该集合下所有文档
 // - "mmq://collection/path" 匹配特定路径
+// - "mmq://docs/**/api/*.md" glob 模式，匹配目标路径或其任一上级目录
 func isPathMatch(contextPath, targetPath string) bool {
 	// 全局上下文
 	if contextPath == "/" {
 		return true
 	}
 
+	// glob 模式
+	if isGlobPattern(contextPath) {
+		return globPathMatch(contextPath, targetPath)
+	}
+
 	// 精确匹配
 	if contextPath == targetPath {
 		return true
@@ -226,24 +244,19 @@ func isPathMatch(contextPath, targetPath string) bool {
 	return false
 }
 
-// GetAllContextsForDocument 获取文档的所有相关上下文（按优先级排序）
+// GetAllContextsForDocument 获取文档的所有相关上下文（按优先级排序，最具体的在前）
+// 包括精确路径、上级目录、集合、全局以及匹配的 glob 模式
 func (s *Store) GetAllContextsForDocument(collection, path string) ([]ContextEntry, error) {
 	targetPath := fmt.Sprintf("mmq://%s/%s", collection, path)
 
-	// 可能的上下文路径（按优先级从高到低）
-	paths := []string{
-		targetPath,                      // 精确路径
-		fmt.Sprintf("mmq://%s", collection), // 集合级别
-		"/",                             // 全局
+	contexts, err := s.GetContextsForPath(targetPath)
+	if err != nil {
+		return nil, err
 	}
 
-	var contexts []ContextEntry
-
-	for _, p := range paths {
-		ctx, err := s.GetContext(p)
-		if err == nil {
-			contexts = append(contexts, *ctx)
-		}
+	// 反转为从最具体到最宽泛
+	for i, j := 0, len(contexts)-1; i < j; i, j = i+1, j-1 {
+		contexts[i], contexts[j] = contexts[j], contexts[i]
 	}
 
 	return contexts, nil
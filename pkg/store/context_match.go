@@ -0,0 +1,66 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// isGlobPattern 上下文路径是否包含 glob 通配符
+func isGlobPattern(p string) bool {
+	return strings.ContainsAny(p, "*?[{")
+}
+
+// globPathMatch glob 模式匹配目标路径或其任一上级目录（与前缀匹配的语义一致）
+func globPathMatch(pattern, target string) bool {
+	stop := 0
+	if strings.HasPrefix(target, "mmq://") {
+		stop = len("mmq://")
+	}
+
+	for p := target; ; {
+		if ok, _ := doublestar.Match(pattern, p); ok {
+			return true
+		}
+		i := strings.LastIndex(p, "/")
+		if i <= stop {
+			return false
+		}
+		p = p[:i]
+	}
+}
+
+// contextSpecificity 上下文的具体程度，越大越优先
+// 字面量路径段计 2 分，部分通配的段（如 *.md）计 1 分，* 和 ** 不计分；全局上下文最低
+func contextSpecificity(p string) int {
+	if p == "/" {
+		return -1
+	}
+
+	score := 0
+	for _, seg := range strings.Split(strings.TrimPrefix(p, "mmq://"), "/") {
+		switch {
+		case seg == "" || seg == "*" || seg == "**":
+		case isGlobPattern(seg):
+			score++
+		default:
+			score += 2
+		}
+	}
+	return score
+}
+
+// moreSpecific a 是否比 b 更具体
+// 依次比较：具体程度、精确路径优先于 glob、路径长度、字典序
+func moreSpecific(a, b string) bool {
+	if sa, sb := contextSpecificity(a), contextSpecificity(b); sa != sb {
+		return sa > sb
+	}
+	if ga, gb := isGlobPattern(a), isGlobPattern(b); ga != gb {
+		return !ga
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a < b
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 上下文树节点类型
+const (
+	ContextNodeGlobal     = "global"
+	ContextNodeCollection = "collection"
+	ContextNodePath       = "path"
+	ContextNodeDocument   = "document"
+	ContextNodePattern    = "pattern" // glob 模式
+)
+
+// ContextNode 上下文层级树的节点
+type ContextNode struct {
+	Path       string         // 上下文路径（/、mmq://collection、mmq://collection/path）
+	Kind       string         // global/collection/path/document/pattern
+	Content    string         // 上下文内容（HasContext 为 false 时为空）
+	HasContext bool           // 该节点是否存储了上下文
+	Documents  int            // 该节点范围内的文档数（即上下文会注入的文档数）
+	Uncovered  int            // 范围内没有匹配到任何上下文的文档数（global 节点不计入覆盖）
+	Children   []*ContextNode // 子节点
+}
+
+// ContextTree 构建上下文层级树：global → collection → path → document
+// 每个集合都会出现，没有上下文的集合 HasContext 为 false，便于发现缺失
+func (s *Store) ContextTree() (*ContextNode, error) {
+	contexts, err := s.ListContexts()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := s.GetCollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	docPaths, err := s.activeDocumentPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]string, len(contexts))
+	for _, ctx := range contexts {
+		byPath[ctx.Path] = ctx.Content
+	}
+
+	root := &ContextNode{Path: "/", Kind: ContextNodeGlobal}
+	root.Content, root.HasContext = byPath["/"]
+
+	// 集合节点（包括只在上下文中出现、尚未创建的集合）
+	collNodes := make(map[string]*ContextNode)
+	addCollection := func(name string) *ContextNode {
+		if node, ok := collNodes[name]; ok {
+			return node
+		}
+		node := &ContextNode{Path: "mmq://" + name, Kind: ContextNodeCollection, Documents: len(docPaths[name])}
+		node.Content, node.HasContext = byPath[node.Path]
+		collNodes[name] = node
+		return node
+	}
+	for _, name := range collections {
+		addCollection(name)
+	}
+
+	// 路径/文档节点，按路径长度排序保证父节点先建立
+	var subPaths []string
+	var rootPatterns []*ContextNode
+	for _, ctx := range contexts {
+		coll, rel := splitContextPath(ctx.Path)
+		switch {
+		case isGlobPattern(coll):
+			// 集合名也是通配符，可能跨多个集合
+			node := &ContextNode{Path: ctx.Path, Kind: ContextNodePattern, Content: ctx.Content, HasContext: true}
+			for name, docs := range docPaths {
+				node.Documents += countMatching(ctx.Path, name, docs)
+			}
+			rootPatterns = append(rootPatterns, node)
+		case coll != "" && rel != "":
+			subPaths = append(subPaths, ctx.Path)
+		case coll != "":
+			addCollection(coll)
+		}
+	}
+	sort.Slice(subPaths, func(i, j int) bool {
+		if len(subPaths[i]) != len(subPaths[j]) {
+			return len(subPaths[i]) < len(subPaths[j])
+		}
+		return subPaths[i] < subPaths[j]
+	})
+
+	pathNodes := make(map[string]*ContextNode)
+	for _, p := range subPaths {
+		coll, rel := splitContextPath(p)
+		collNode := addCollection(coll)
+
+		node := &ContextNode{Path: p, Kind: ContextNodePath, Content: byPath[p], HasContext: true}
+		if isGlobPattern(rel) {
+			node.Kind = ContextNodePattern
+		}
+		for _, doc := range docPaths[coll] {
+			if doc == rel {
+				node.Kind = ContextNodeDocument
+			}
+		}
+		node.Documents = countMatching(p, coll, docPaths[coll])
+
+		// 挂到最近的祖先上下文下
+		parent := collNode
+		for dir := rel; ; {
+			i := strings.LastIndex(dir, "/")
+			if i < 0 {
+				break
+			}
+			dir = dir[:i]
+			if n, ok := pathNodes["mmq://"+coll+"/"+dir]; ok {
+				parent = n
+				break
+			}
+		}
+		parent.Children = append(parent.Children, node)
+		pathNodes[p] = node
+	}
+
+	// 集合按名称排序，并统计未覆盖的文档
+	names := make([]string, 0, len(collNodes))
+	for name := range collNodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node := collNodes[name]
+		if !node.HasContext {
+			for _, doc := range docPaths[name] {
+				if !isPathCovered(contexts, "mmq://"+name+"/"+doc) {
+					node.Uncovered++
+				}
+			}
+		}
+		root.Documents += node.Documents
+		root.Uncovered += node.Uncovered
+		root.Children = append(root.Children, node)
+	}
+
+	root.Children = append(root.Children, rootPatterns...)
+
+	// 其他格式的上下文路径不会匹配任何文档，单独列出
+	for _, ctx := range contexts {
+		if ctx.Path == "/" || strings.HasPrefix(ctx.Path, "mmq://") {
+			continue
+		}
+		root.Children = append(root.Children, &ContextNode{
+			Path: ctx.Path, Kind: ContextNodePath, Content: ctx.Content, HasContext: true,
+		})
+	}
+
+	return root, nil
+}
+
+// splitContextPath 拆分 mmq://collection/path，非 mmq:// 路径返回空集合名
+func splitContextPath(p string) (collection, rel string) {
+	rest, ok := strings.CutPrefix(p, "mmq://")
+	if !ok {
+		return "", ""
+	}
+	collection, rel, _ = strings.Cut(strings.TrimSuffix(rest, "/"), "/")
+	return collection, rel
+}
+
+// isPathCovered 检查目标路径是否被全局以外的任一上下文匹配
+func isPathCovered(contexts []ContextEntry, target string) bool {
+	for _, ctx := range contexts {
+		if ctx.Path != "/" && isPathMatch(ctx.Path, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// countMatching 统计集合中被上下文路径匹配的文档数
+func countMatching(contextPath, collection string, docs []string) int {
+	n := 0
+	for _, doc := range docs {
+		if isPathMatch(contextPath, "mmq://"+collection+"/"+doc) {
+			n++
+		}
+	}
+	return n
+}
+
+// activeDocumentPaths 返回每个集合的活跃文档路径
+func (s *Store) activeDocumentPaths() (map[string][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT collection, path FROM documents
+		WHERE active = 1
+		ORDER BY collection, path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string][]string)
+	for rows.Next() {
+		var coll, path string
+		if err := rows.Scan(&coll, &path); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		paths[coll] = append(paths[coll], path)
+	}
+	return paths, rows.Err()
+}
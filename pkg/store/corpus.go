@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// corpusEventTypes 改变可检索文档集合的事件
+var corpusEventTypes = []interface{}{EventDocAdded, EventDocUpdated, EventDocRemoved, EventCollectionRenamed}
+
+// CorpusVersion 返回语料版本：最近一次文档变更事件的 ID，每次索引变更单调递增
+func (s *Store) CorpusVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`
+		SELECT id FROM events WHERE type IN (?, ?, ?, ?)
+		ORDER BY id DESC LIMIT 1
+	`, corpusEventTypes...).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read corpus version: %w", err)
+	}
+	return version, nil
+}
+
+// CorpusChanges 某个语料版本之后变更过的文档
+type CorpusChanges struct {
+	Documents   map[string]bool // collection/path
+	Collections map[string]bool // 重命名过的集合（新名称），其中的文档都视为变更
+}
+
+// Empty 该版本之后没有变更
+func (c *CorpusChanges) Empty() bool {
+	return len(c.Documents) == 0 && len(c.Collections) == 0
+}
+
+// Changed 文档在该版本之后是否新增、修改或删除过
+func (c *CorpusChanges) Changed(collection, path string) bool {
+	return c.Collections[collection] || c.Documents[collection+"/"+path]
+}
+
+// CorpusChangesSince 返回语料版本 version 之后变更过的文档
+func (s *Store) CorpusChangesSince(version int64) (*CorpusChanges, error) {
+	args := append([]interface{}{version}, corpusEventTypes...)
+	rows, err := s.db.Query(`
+		SELECT type, collection, path FROM events
+		WHERE id > ? AND type IN (?, ?, ?, ?)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := &CorpusChanges{Documents: make(map[string]bool), Collections: make(map[string]bool)}
+	for rows.Next() {
+		var eventType, collection, path string
+		if err := rows.Scan(&eventType, &collection, &path); err != nil {
+			return nil, fmt.Errorf("failed to scan corpus change: %w", err)
+		}
+		if eventType == EventCollectionRenamed {
+			changes.Collections[collection] = true
+		} else {
+			changes.Documents[collection+"/"+path] = true
+		}
+	}
+	return changes, rows.Err()
+}
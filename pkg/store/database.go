@@ -3,9 +3,10 @@ package store
 import (
 	"database/sql"
 	"fmt"
-
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 )
 
 // schema SQLite数据库schema
@@ -14,7 +15,10 @@ const schema = `
 CREATE TABLE IF NOT EXISTS content (
     hash TEXT PRIMARY KEY,
     doc TEXT NOT NULL,
-    created_at TEXT NOT NULL
+    created_at TEXT NOT NULL,
+    word_count INTEGER NOT NULL DEFAULT 0,
+    char_count INTEGER NOT NULL DEFAULT 0,
+    reading_seconds INTEGER NOT NULL DEFAULT 0
 );
 
 -- 文档元数据
@@ -27,6 +31,11 @@ CREATE TABLE IF NOT EXISTS documents (
     created_at TEXT NOT NULL,
     modified_at TEXT NOT NULL,
     active INTEGER NOT NULL DEFAULT 1,
+    language TEXT NOT NULL DEFAULT '',
+    doc_date TEXT NOT NULL DEFAULT '',
+    access_count INTEGER NOT NULL DEFAULT 0,
+    last_accessed_at TEXT,
+    managed INTEGER NOT NULL DEFAULT 1,
     FOREIGN KEY (hash) REFERENCES content(hash) ON DELETE CASCADE,
     UNIQUE(collection, path)
 );
@@ -44,9 +53,71 @@ CREATE TABLE IF NOT EXISTS content_vectors (
     model TEXT NOT NULL,
     embedding BLOB,
     embedded_at TEXT NOT NULL,
+    chunk_size INTEGER NOT NULL DEFAULT 0,
+    chunk_overlap INTEGER NOT NULL DEFAULT 0,
     PRIMARY KEY (hash, seq)
 );
 
+-- 集合专用嵌入模型的向量（默认模型的向量在 content_vectors）
+CREATE TABLE IF NOT EXISTS model_vectors (
+    model TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    seq INTEGER NOT NULL DEFAULT 0,
+    pos INTEGER NOT NULL DEFAULT 0,
+    embedding BLOB,
+    embedded_at TEXT NOT NULL,
+    chunk_size INTEGER NOT NULL DEFAULT 0,
+    chunk_overlap INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (model, hash, seq)
+);
+
+-- 专用嵌入模型登记，id 用于命名各模型的向量表 vectors_vec_<id>
+CREATE TABLE IF NOT EXISTS embedding_models (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model TEXT NOT NULL UNIQUE
+);
+
+-- 嵌入进度（断点续传）
+CREATE TABLE IF NOT EXISTS embedding_progress (
+    hash TEXT PRIMARY KEY,
+    model TEXT NOT NULL,
+    total_chunks INTEGER NOT NULL,
+    done_chunks INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL
+);
+
+-- 命名结果集（search --save），后续查询可以只在其中继续筛选（--within）；超过 ResultSetTTL 未更新的在保存新结果集时删除
+CREATE TABLE IF NOT EXISTS result_sets (
+    name TEXT PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS result_set_docs (
+    name TEXT NOT NULL,
+    rank INTEGER NOT NULL,
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    score REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (name, rank)
+);
+
+-- verify-vectors 隔离的损坏向量，保留原始数据供排查
+CREATE TABLE IF NOT EXISTS vector_quarantine (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    model TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    pos INTEGER NOT NULL DEFAULT 0,
+    embedding BLOB,
+    reason TEXT NOT NULL,
+    quarantined_at TEXT NOT NULL
+);
+
 -- FTS5全文搜索索引
 CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
     filepath, title, body,
@@ -60,6 +131,32 @@ CREATE TABLE IF NOT EXISTS llm_cache (
     created_at TEXT NOT NULL
 );
 
+-- 语义回答缓存：相似问题在同一语料版本下复用回答
+CREATE TABLE IF NOT EXISTS answer_cache (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL,
+    corpus_version INTEGER NOT NULL,
+    question TEXT NOT NULL,
+    embedding BLOB NOT NULL,
+    answer TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_answer_cache_scope ON answer_cache(scope, corpus_version);
+
+-- 重排分数缓存：同一查询、分块和重排模型复用 cross-encoder 分数
+CREATE TABLE IF NOT EXISTS rerank_cache (
+    query_hash TEXT NOT NULL,
+    chunk_hash TEXT NOT NULL,
+    model TEXT NOT NULL,
+    score REAL NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (query_hash, chunk_hash, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rerank_cache_created ON rerank_cache(created_at);
+
 -- 记忆存储
 CREATE TABLE IF NOT EXISTS memories (
     id TEXT PRIMARY KEY,
@@ -70,7 +167,9 @@ CREATE TABLE IF NOT EXISTS memories (
     timestamp TEXT NOT NULL,
     expires_at TEXT,
     importance REAL NOT NULL DEFAULT 0.5,
-    embedding BLOB
+    embedding BLOB,
+    access_count INTEGER NOT NULL DEFAULT 0,
+    last_accessed_at TEXT
 );
 
 -- 记忆索引
@@ -78,18 +177,127 @@ CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
 CREATE INDEX IF NOT EXISTS idx_memories_timestamp ON memories(timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_memories_expires ON memories(expires_at);
 
+-- 记忆审阅反馈（用于自动提取的重要性评分）
+CREATE TABLE IF NOT EXISTS memory_feedback (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    memory_type TEXT NOT NULL,
+    action TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_feedback_type ON memory_feedback(memory_type);
+
 -- 集合管理
 CREATE TABLE IF NOT EXISTS collections (
     name TEXT PRIMARY KEY,
     path TEXT NOT NULL,
     mask TEXT NOT NULL DEFAULT '**/*',
     created_at TEXT NOT NULL,
-    updated_at TEXT NOT NULL
+    updated_at TEXT NOT NULL,
+    pii_policy TEXT NOT NULL DEFAULT '',
+    generation INTEGER NOT NULL DEFAULT 0,
+    embed_model TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    remote TEXT NOT NULL DEFAULT '',
+    remote_collection TEXT NOT NULL DEFAULT '',
+    strategy TEXT NOT NULL DEFAULT '',
+    index_mode TEXT NOT NULL DEFAULT '',
+    indexed_at TEXT NOT NULL DEFAULT ''
 );
 
 -- 集合索引
 CREATE INDEX IF NOT EXISTS idx_collections_path ON collections(path);
 
+-- 重新索引暂存区（提交时在一个事务内替换集合的文档）
+CREATE TABLE IF NOT EXISTS index_staging (
+    collection TEXT NOT NULL,
+    generation INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    title TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    modified_at TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    doc_date TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, generation, path)
+);
+
+-- 最近查询（key 为小写查询，主键即前缀索引）
+CREATE TABLE IF NOT EXISTS query_history (
+    key TEXT PRIMARY KEY,
+    query TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 1,
+    last_used_at TEXT NOT NULL
+);
+
+-- 搜索结果的相关性反馈（用于学习重排混合权重）
+CREATE TABLE IF NOT EXISTS search_feedback (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    query TEXT NOT NULL,
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    relevant INTEGER NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+-- 索引变更事件日志（只追加，供同步工具和审计使用）
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    time TEXT NOT NULL,
+    type TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    collection TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL DEFAULT '',
+    hash TEXT NOT NULL DEFAULT '',
+    memory_id TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    origin TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_time ON events(time);
+
+-- 实例信息（如同步用的实例 ID）
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- 与其他实例的同步游标
+CREATE TABLE IF NOT EXISTS sync_peers (
+    peer_id TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    pulled_id INTEGER NOT NULL DEFAULT 0,
+    pushed_id INTEGER NOT NULL DEFAULT 0,
+    synced_at TEXT NOT NULL
+);
+
+-- 对象存储集合中各对象上次索引时的 ETag（变化检测）
+CREATE TABLE IF NOT EXISTS object_etags (
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    PRIMARY KEY (collection, path)
+);
+
+-- 集合的文档聚类（mmq cluster），id 从 1 开始
+CREATE TABLE IF NOT EXISTS clusters (
+    collection TEXT NOT NULL,
+    id INTEGER NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (collection, id)
+);
+
+-- 文档所属的聚类
+CREATE TABLE IF NOT EXISTS document_clusters (
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    cluster INTEGER NOT NULL,
+    distance REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (collection, path)
+);
+
 -- 上下文管理
 CREATE TABLE IF NOT EXISTS contexts (
     path TEXT PRIMARY KEY,
@@ -129,42 +337,244 @@ END;
 type Store struct {
 	db     *sql.DB
 	dbPath string
+	cache  LLMCache
+
+	catalog catalog                // 文档目录缓存
+	actor   string                 // 记录到事件日志的执行者
+	changed atomic.Pointer[func()] // 文档事件提交后调用（可能在其他 goroutine 中设置）
+
+	pathNoCase bool // 按路径查找文档时忽略大小写
+
+	prefilter VectorPrefilter // 向量检索前的候选预筛选
 }
 
 // New 创建新的Store实例
 func New(dbPath string) (*Store, error) {
 	// 初始化 sqlite-vec 扩展
-	sqlite_vec.Auto()
+	registerVec()
 
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		db:     db,
+		dbPath: dbPath,
+		cache:  &sqliteCache{db: db},
+	}, nil
+}
+
+// OpenReadOnly 以只读方式打开另一个 mmq 数据库（如远端集合的数据库）
+// 不修改文件：不切换 WAL、不初始化或迁移 schema；schema 不是当前版本时返回错误
+func OpenReadOnly(dbPath string) (*Store, error) {
+	return openExisting(dbPath, "ro")
+}
+
+// OpenExisting 打开另一个 mmq 数据库用于写入（如同步），同样不切换 WAL、不初始化或迁移 schema
+func OpenExisting(dbPath string) (*Store, error) {
+	return openExisting(dbPath, "rw")
+}
+
+// openExisting 按 SQLite URI 的 mode 打开已存在的数据库并检查 schema
+func openExisting(dbPath, mode string) (*Store, error) {
+	registerVec()
+
+	path := filepath.ToSlash(dbPath)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows 盘符路径
+	}
+	dsn := (&url.URL{Scheme: "file", Path: path, RawQuery: "mode=" + mode}).String()
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := checkSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db:     db,
+		dbPath: dbPath,
+		cache:  &sqliteCache{db: db},
+	}, nil
+}
+
+// checkSchema 确认数据库包含当前版本 schema 的全部表和列（与内存中新建的数据库比较）
+func checkSchema(db *sql.DB) error {
+	ref, err := sql.Open(sqliteDriver, ":memory:")
+	if err != nil {
+		return err
+	}
+	defer ref.Close()
+	ref.SetMaxOpenConns(1) // 内存数据库按连接隔离
+	if _, err := ref.Exec(schema); err != nil {
+		return fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	if err := migrate(ref); err != nil {
+		return err
+	}
+
+	tables, err := queryStrings(ref, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		columns, err := queryStrings(ref, fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			exists, err := columnExists(db, table, column)
+			if err != nil {
+				return fmt.Errorf("failed to read schema: %w", err)
+			}
+			if !exists {
+				return fmt.Errorf("database schema is out of date (missing %s.%s); open it once with this version of mmq to upgrade it", table, column)
+			}
+		}
+	}
+	return nil
+}
+
+// openDB 打开数据库，设置连接参数并初始化、迁移 schema
+func openDB(dbPath string) (*sql.DB, error) {
 	// 打开数据库
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(sqliteDriver, dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// 启用WAL模式（Write-Ahead Logging）
 	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
 
 	// 启用外键约束
 	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
 	// 初始化schema
 	if _, err := db.Exec(schema); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	return &Store{
-		db:     db,
-		dbPath: dbPath,
-	}, nil
+	// 为旧数据库补齐新增列
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return db, nil
+}
+
+// migrate 为旧版本数据库补齐后续新增的列
+func migrate(db *sql.DB) error {
+	hasTextStats, err := columnExists(db, "content", "char_count")
+	if err != nil {
+		return err
+	}
+	hasDocDate, err := columnExists(db, "documents", "doc_date")
+	if err != nil {
+		return err
+	}
+
+	columns := []struct {
+		table, name, def string
+	}{
+		{"memories", "access_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"memories", "last_accessed_at", "TEXT"},
+		{"collections", "pii_policy", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "generation", "INTEGER NOT NULL DEFAULT 0"},
+		{"collections", "embed_model", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "metadata", "TEXT NOT NULL DEFAULT '{}'"},
+		{"documents", "language", "TEXT NOT NULL DEFAULT ''"},
+		{"index_staging", "language", "TEXT NOT NULL DEFAULT ''"},
+		{"content", "word_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"content", "char_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"content", "reading_seconds", "INTEGER NOT NULL DEFAULT 0"},
+		{"documents", "doc_date", "TEXT NOT NULL DEFAULT ''"},
+		{"index_staging", "doc_date", "TEXT NOT NULL DEFAULT ''"},
+		{"events", "origin", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "remote", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "remote_collection", "TEXT NOT NULL DEFAULT ''"},
+		{"documents", "access_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"documents", "last_accessed_at", "TEXT"},
+		{"content_vectors", "chunk_size", "INTEGER NOT NULL DEFAULT 0"},
+		{"content_vectors", "chunk_overlap", "INTEGER NOT NULL DEFAULT 0"},
+		{"model_vectors", "chunk_size", "INTEGER NOT NULL DEFAULT 0"},
+		{"model_vectors", "chunk_overlap", "INTEGER NOT NULL DEFAULT 0"},
+		{"collections", "strategy", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "index_mode", "TEXT NOT NULL DEFAULT ''"},
+		{"collections", "indexed_at", "TEXT NOT NULL DEFAULT ''"},
+		{"documents", "managed", "INTEGER NOT NULL DEFAULT 1"},
+	}
+
+	for _, col := range columns {
+		exists, err := columnExists(db, col.table, col.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, col.def)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", col.table, col.name, err)
+		}
+	}
+
+	// 统计列是新增的，为已有内容补算
+	if !hasTextStats {
+		if err := backfillTextStats(db); err != nil {
+			return err
+		}
+	}
+	if !hasDocDate {
+		if err := backfillDocumentDates(db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// columnExists 检查表中是否存在指定列
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
 }
 
 // Close 关闭数据库连接
 func (s *Store) Close() error {
+	if s.cache != nil {
+		s.cache.Close()
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -177,27 +587,40 @@ func (s *Store) DB() *sql.DB {
 }
 
 // ensureVectorTable 确保vectors_vec虚拟表存在
-// 如果表不存在，根据提供的向量维度创建它
 func (s *Store) ensureVectorTable(dimensions int) error {
-	// 检查表是否存在
-	var tableName string
-	err := s.db.QueryRow(`
-		SELECT name FROM sqlite_master
-		WHERE type='table' AND name='vectors_vec'
-	`).Scan(&tableName)
+	return s.ensureVecTable("vectors_vec", dimensions)
+}
 
-	if err == sql.ErrNoRows {
-		// 表不存在，创建它
-		createSQL := fmt.Sprintf(
-			"CREATE VIRTUAL TABLE vectors_vec USING vec0(hash_seq TEXT PRIMARY KEY, embedding float[%d] distance_metric=cosine)",
-			dimensions,
-		)
-		if _, err := s.db.Exec(createSQL); err != nil {
-			return fmt.Errorf("failed to create vectors_vec table: %w", err)
-		}
-	} else if err != nil {
-		return fmt.Errorf("failed to check vectors_vec table: %w", err)
+// ensureVecTable 确保指定的 sqlite-vec 虚拟表存在
+func (s *Store) ensureVecTable(table string, dimensions int) error {
+	exists, err := s.tableExists(table)
+	if err != nil {
+		return fmt.Errorf("failed to check %s table: %w", table, err)
+	}
+	if exists {
+		return nil
 	}
 
+	// 表不存在，创建它
+	createSQL := fmt.Sprintf(
+		"CREATE VIRTUAL TABLE %s USING vec0(hash_seq TEXT PRIMARY KEY, embedding float[%d] distance_metric=cosine)",
+		table, dimensions,
+	)
+	if _, err := s.db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", table, err)
+	}
 	return nil
 }
+
+// tableExists 检查表是否存在
+func (s *Store) tableExists(table string) (bool, error) {
+	var name string
+	err := s.db.QueryRow(`
+		SELECT name FROM sqlite_master
+		WHERE type='table' AND name=?
+	`, table).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DBStats 数据库文件的页、空闲页、WAL 和各类数据的占用
+type DBStats struct {
+	PageSize      int64
+	PageCount     int64
+	FreelistCount int64 // 空闲页数，VACUUM 后归还给文件系统
+	FileSize      int64
+	WALSize       int64
+	Tables        []TableStats
+
+	InactiveDocuments int // 已停用的文档记录（cleanup 删除）
+	OrphanedContent   int // 不被任何文档引用的内容（cleanup 删除）
+	StaleVectors      int // 不被活跃文档引用的内容的分块向量（purge 删除）
+	StaleFTSRows      int // 非活跃文档的全文索引行（purge 删除）
+	CacheEntries      int // 主库中的 LLM 缓存条目
+}
+
+// TableStats 一类数据的行数和估算大小
+type TableStats struct {
+	Name   string   // documents、content、fts、vectors、memories、cache、other
+	Tables []string // 归入该类的表（含 FTS 和 sqlite-vec 的影子表）
+	Rows   int64    // 各表行数之和
+	Bytes  int64    // 各列数据长度之和，不含页内开销和索引
+}
+
+// tableGroups 表名到数据类别，按前缀匹配（FTS、sqlite-vec 影子表）
+var tableGroups = []struct {
+	prefix string
+	group  string
+}{
+	{"documents_fts", "fts"},
+	{"vectors_vec", "vectors"},
+	{"content_vectors", "vectors"},
+	{"model_vectors", "vectors"},
+	{"embedding_", "vectors"},
+	{"vector_quarantine", "vectors"},
+	{"content", "content"},
+	{"documents", "documents"},
+	{"collections", "documents"},
+	{"contexts", "documents"},
+	{"index_staging", "documents"},
+	{"object_etags", "documents"},
+	{"result_set", "documents"},
+	{"clusters", "documents"},
+	{"document_clusters", "documents"},
+	{"memor", "memories"},
+	{"llm_cache", "cache"},
+	{"answer_cache", "cache"},
+	{"rerank_cache", "cache"},
+}
+
+// tableGroupOrder 输出顺序
+var tableGroupOrder = []string{"documents", "content", "fts", "vectors", "memories", "cache", "other"}
+
+// tableGroup 表所属的数据类别
+func tableGroup(table string) string {
+	for _, g := range tableGroups {
+		if strings.HasPrefix(table, g.prefix) {
+			return g.group
+		}
+	}
+	return "other"
+}
+
+// DBStats 统计页数、空闲页、WAL 大小、各类数据的行数和估算大小以及可清理的数据
+// 各表大小由列数据长度估算（驱动未编译 dbstat 虚拟表），需要扫描全部表
+func (s *Store) DBStats() (*DBStats, error) {
+	stats := &DBStats{}
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{
+		{"page_size", &stats.PageSize},
+		{"page_count", &stats.PageCount},
+		{"freelist_count", &stats.FreelistCount},
+	} {
+		if err := s.db.QueryRow("PRAGMA " + p.name).Scan(p.dst); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p.name, err)
+		}
+	}
+	if info, err := os.Stat(s.dbPath); err == nil {
+		stats.FileSize = info.Size()
+	}
+	if info, err := os.Stat(s.dbPath + "-wal"); err == nil {
+		stats.WALSize = info.Size()
+	}
+
+	// 在同一读事务中统计，结果彼此一致
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tables, err := queryStrings(tx, `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		  AND sql NOT LIKE 'CREATE VIRTUAL TABLE%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	groups := make(map[string]*TableStats)
+	for _, table := range tables {
+		rows, bytes, err := tableSize(tx, table)
+		if err != nil {
+			return nil, err
+		}
+		name := tableGroup(table)
+		g, ok := groups[name]
+		if !ok {
+			g = &TableStats{Name: name}
+			groups[name] = g
+		}
+		g.Tables = append(g.Tables, table)
+		g.Rows += rows
+		g.Bytes += bytes
+	}
+	for _, name := range tableGroupOrder {
+		if g, ok := groups[name]; ok {
+			sort.Strings(g.Tables)
+			stats.Tables = append(stats.Tables, *g)
+		}
+	}
+
+	for _, c := range []struct {
+		dst   *int
+		query string
+	}{
+		{&stats.InactiveDocuments, "SELECT COUNT(*) FROM documents WHERE active = 0"},
+		{&stats.OrphanedContent, `
+			SELECT COUNT(*) FROM content
+			WHERE hash NOT IN (SELECT hash FROM documents)
+			  AND hash NOT IN (SELECT hash FROM index_staging)`},
+		{&stats.StaleVectors, `
+			SELECT COUNT(*) FROM (
+				SELECT hash FROM content_vectors
+				UNION ALL
+				SELECT hash FROM model_vectors
+			)
+			WHERE hash NOT IN (SELECT hash FROM documents WHERE active = 1)
+			  AND hash NOT IN (SELECT hash FROM index_staging)`},
+		{&stats.StaleFTSRows, "SELECT COUNT(*) FROM documents_fts WHERE rowid NOT IN (SELECT id FROM documents WHERE active = 1)"},
+		{&stats.CacheEntries, "SELECT COUNT(*) FROM llm_cache"},
+	} {
+		if err := tx.QueryRow(c.query).Scan(c.dst); err != nil {
+			return nil, fmt.Errorf("failed to count reclaimable data: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// tableSize 返回表的行数和各列数据长度之和
+func tableSize(tx *sql.Tx, table string) (rows, bytes int64, err error) {
+	columns, err := queryStrings(tx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	sum := "0"
+	if len(columns) > 0 {
+		parts := make([]string, len(columns))
+		for i, c := range columns {
+			parts[i] = fmt.Sprintf(`COALESCE(length(%q), 0)`, c)
+		}
+		sum = strings.Join(parts, " + ")
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(%s), 0) FROM %q`, sum, table)
+	if err := tx.QueryRow(query).Scan(&rows, &bytes); err != nil {
+		return 0, 0, fmt.Errorf("failed to measure %s: %w", table, err)
+	}
+	return rows, bytes, nil
+}
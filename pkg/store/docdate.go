@@ -0,0 +1,184 @@
+package store
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DateLayout 文档日期格式（只精确到天）
+const DateLayout = "2006-01-02"
+
+var (
+	pathDateRe    = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})[-_/.]?(0[1-9]|1[0-2])[-_/.]?(0[1-9]|[12]\d|3[01])(?:[^0-9]|$)`)
+	pathQuarterRe = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})[-_/]?[qQ]([1-4])(?:[^0-9]|$)`)
+	pathMonthRe   = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})[-_/](0[1-9]|1[0-2])(?:[^0-9]|$)`)
+	pathYearRe    = regexp.MustCompile(`(?:^|/)((?:19|20)\d{2})(?:/|\.[^/]*$|$)`)
+)
+
+// frontmatterDateKeys frontmatter 中表示文档日期的键（按优先级）
+var frontmatterDateKeys = []string{"date", "created", "created_at", "published"}
+
+// ExtractDocumentDate 从 frontmatter 或路径中解析文档日期（YYYY-MM-DD），无法解析时返回空
+// frontmatter 优先；路径支持 2025-01-15.md、2024/03/05、2024/q1.md、2024-03、2024/ 等形式，
+// 只有年/季度/月时取该段的第一天
+func ExtractDocumentDate(path, content string) string {
+	if d := frontmatterDate(content); d != "" {
+		return d
+	}
+	return pathDate(path)
+}
+
+// frontmatterDate 读取开头 --- 块中的日期字段
+func frontmatterDate(content string) string {
+	if !strings.HasPrefix(content, "---") {
+		return ""
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Scan() // 跳过开头的 ---
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	for _, key := range frontmatterDateKeys {
+		v := strings.ReplaceAll(values[key], "/", "-")
+		if len(v) < len(DateLayout) {
+			continue
+		}
+		if t, err := time.Parse(DateLayout, v[:len(DateLayout)]); err == nil {
+			return t.Format(DateLayout)
+		}
+	}
+	return ""
+}
+
+// pathDate 从路径中解析日期，越精确的形式越优先
+func pathDate(path string) string {
+	if m := lastMatch(pathDateRe, path); m != nil {
+		// 形如日期但不合法（如 2023-02-30）时不再降级到月份
+		t, err := time.Parse(DateLayout, m[1]+"-"+m[2]+"-"+m[3])
+		if err != nil {
+			return ""
+		}
+		return t.Format(DateLayout)
+	}
+	if m := lastMatch(pathQuarterRe, path); m != nil {
+		month := (int(m[2][0]-'0')-1)*3 + 1
+		return fmt.Sprintf("%s-%02d-01", m[1], month)
+	}
+	if m := lastMatch(pathMonthRe, path); m != nil {
+		return m[1] + "-" + m[2] + "-01"
+	}
+	if m := lastMatch(pathYearRe, path); m != nil {
+		return m[1] + "-01-01"
+	}
+	return ""
+}
+
+// lastMatch 返回最后一个匹配（文件名比上层目录更具体）
+func lastMatch(re *regexp.Regexp, s string) []string {
+	matches := re.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[len(matches)-1]
+}
+
+// ParseDatePeriod 解析 YYYY、YYYY-MM 或 YYYY-MM-DD，返回该时间段的起点和（不含的）终点
+func ParseDatePeriod(s string) (start, end time.Time, err error) {
+	switch len(s) {
+	case len("2006"):
+		start, err = time.Parse("2006", s)
+		end = start.AddDate(1, 0, 0)
+	case len("2006-01"):
+		start, err = time.Parse("2006-01", s)
+		end = start.AddDate(0, 1, 0)
+	default:
+		start, err = time.Parse(DateLayout, s)
+		end = start.AddDate(0, 0, 1)
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: want YYYY, YYYY-MM or YYYY-MM-DD", s)
+	}
+	return start, end, nil
+}
+
+// DateRange 文档日期过滤（按天，After 含当天，Before 不含当天；零值表示不限）
+// 没有解析出日期的文档使用修改时间
+type DateRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// IsZero 是否不限日期
+func (r DateRange) IsZero() bool {
+	return r.After.IsZero() && r.Before.IsZero()
+}
+
+// sqlFilter 生成 documents 表（别名 d）的日期过滤条件
+func (r DateRange) sqlFilter() (string, []interface{}) {
+	const effective = "substr(CASE WHEN d.doc_date != '' THEN d.doc_date ELSE d.modified_at END, 1, 10)"
+	var clause string
+	var args []interface{}
+	if !r.After.IsZero() {
+		clause += " AND " + effective + " >= ?"
+		args = append(args, r.After.Format(DateLayout))
+	}
+	if !r.Before.IsZero() {
+		clause += " AND " + effective + " < ?"
+		args = append(args, r.Before.Format(DateLayout))
+	}
+	return clause, args
+}
+
+// backfillDocumentDates 为新增日期列之前索引的文档解析日期
+func backfillDocumentDates(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT d.id, d.path, COALESCE(c.doc, '')
+		FROM documents d LEFT JOIN content c ON c.hash = d.hash
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to read documents: %w", err)
+	}
+	dates := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var path, doc string
+		if err := rows.Scan(&id, &path, &doc); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan document: %w", err)
+		}
+		if d := ExtractDocumentDate(path, doc); d != "" {
+			dates[id] = d
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for id, d := range dates {
+		if _, err := tx.Exec("UPDATE documents SET doc_date = ? WHERE id = ?", d, id); err != nil {
+			return fmt.Errorf("failed to update document date: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -6,10 +6,15 @@ import (
 	"encoding/hex"
 	"fmt"
 	"time"
+
+	"github.com/dyike/mmq/pkg/lang"
 )
 
 // IndexDocument 索引单个文档
 func (s *Store) IndexDocument(doc Document) error {
+	defer s.InvalidateCatalog()
+	doc.Path = NormalizeDocPath(doc.Path)
+
 	// 1. 计算内容哈希
 	hash := computeHash(doc.Content)
 
@@ -23,9 +28,10 @@ func (s *Store) IndexDocument(doc Document) error {
 	// 3. 插入内容（如果不存在）
 	if !exists {
 		now := time.Now().UTC().Format(time.RFC3339)
+		stats := ComputeTextStats(doc.Content)
 		_, err = s.db.Exec(
-			"INSERT OR IGNORE INTO content (hash, doc, created_at) VALUES (?, ?, ?)",
-			hash, doc.Content, now,
+			"INSERT OR IGNORE INTO content (hash, doc, created_at, word_count, char_count, reading_seconds) VALUES (?, ?, ?, ?, ?, ?)",
+			hash, doc.Content, now, stats.Words, stats.Chars, stats.ReadingSeconds,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert content: %w", err)
@@ -40,23 +46,68 @@ func (s *Store) IndexDocument(doc Document) error {
 		doc.ModifiedAt = time.Now().UTC()
 	}
 
-	// 使用REPLACE确保路径唯一性
-	_, err = s.db.Exec(`
-		INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active)
-		VALUES (?, ?, ?, ?, ?, ?, 1)
+	if doc.Language == "" {
+		doc.Language = lang.Detect(doc.Content)
+	}
+	if doc.Date == "" {
+		doc.Date = ExtractDocumentDate(doc.Path, doc.Content)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 判断变更类型（内容和标题都没变时不记录事件）
+	var oldHash, oldTitle string
+	var oldActive bool
+	eventType := ""
+	err = tx.QueryRow(
+		"SELECT hash, title, active FROM documents WHERE collection = ? AND path = ?", doc.Collection, doc.Path,
+	).Scan(&oldHash, &oldTitle, &oldActive)
+	switch {
+	case err == sql.ErrNoRows || (err == nil && !oldActive):
+		eventType = EventDocAdded
+	case err != nil:
+		return fmt.Errorf("failed to check document: %w", err)
+	case oldHash != hash || oldTitle != doc.Title:
+		eventType = EventDocUpdated
+	}
+
+	// 使用REPLACE确保路径唯一性；直接写入的文档不由目录重新索引管理（managed = 0）
+	_, err = tx.Exec(`
+		INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active, language, doc_date, managed)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, 0)
 		ON CONFLICT(collection, path) DO UPDATE SET
 			title = excluded.title,
 			hash = excluded.hash,
 			modified_at = excluded.modified_at,
-			active = 1
+			active = 1,
+			language = excluded.language,
+			doc_date = excluded.doc_date,
+			managed = 0
 	`, doc.Collection, doc.Path, doc.Title, hash,
 	   doc.CreatedAt.Format(time.RFC3339),
-	   doc.ModifiedAt.Format(time.RFC3339))
+	   doc.ModifiedAt.Format(time.RFC3339), doc.Language, doc.Date)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert document: %w", err)
 	}
 
+	if eventType != "" {
+		err = logEvent(tx, Event{Type: eventType, Actor: s.actor, Collection: doc.Collection, Path: doc.Path, Hash: hash})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit document: %w", err)
+	}
+	if eventType != "" {
+		s.notifyChanged()
+	}
 	return nil
 }
 
@@ -67,7 +118,7 @@ func (s *Store) GetDocument(id string) (*Document, error) {
 
 	// 支持两种ID格式：数字ID或哈希
 	query := `
-		SELECT d.id, d.collection, d.path, d.title, c.doc, d.created_at, d.modified_at
+		SELECT d.id, d.collection, d.path, d.title, c.doc, d.created_at, d.modified_at, d.language
 		FROM documents d
 		JOIN content c ON c.hash = d.hash
 		WHERE (d.id = ? OR d.hash = ? OR d.path = ?) AND d.active = 1
@@ -76,7 +127,7 @@ func (s *Store) GetDocument(id string) (*Document, error) {
 
 	err := s.db.QueryRow(query, id, id, id).Scan(
 		&doc.ID, &doc.Collection, &doc.Path, &doc.Title, &doc.Content,
-		&createdAt, &modifiedAt,
+		&createdAt, &modifiedAt, &doc.Language,
 	)
 
 	if err == sql.ErrNoRows {
@@ -95,12 +146,25 @@ func (s *Store) GetDocument(id string) (*Document, error) {
 
 // DeleteDocument 删除文档（软删除）
 func (s *Store) DeleteDocument(id string) error {
-	result, err := s.db.Exec(`
-		UPDATE documents
-		SET active = 0
-		WHERE (id = ? OR hash = ? OR path = ?) AND active = 1
-	`, id, id, id)
+	defer s.InvalidateCatalog()
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where := "(id = ? OR hash = ? OR path = ?) AND active = 1"
+	path := NormalizeDocPath(id)
+	if err := logDocumentEvents(tx, EventDocRemoved, s.actor, where, id, id, path); err != nil {
+		return err
+	}
+	hashes, err := queryStrings(tx, "SELECT DISTINCT hash FROM documents WHERE "+where, id, id, path)
+	if err != nil {
+		return fmt.Errorf("failed to look up document: %w", err)
+	}
+
+	result, err := tx.Exec("UPDATE documents SET active = 0 WHERE "+where, id, id, path)
 	if err != nil {
 		return fmt.Errorf("failed to delete document: %w", err)
 	}
@@ -110,6 +174,15 @@ func (s *Store) DeleteDocument(id string) error {
 		return fmt.Errorf("document not found: %s", id)
 	}
 
+	// 内容不再被其他活跃文档引用时一并删除向量
+	if _, err := purgeVectors(tx, hashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete: %w", err)
+	}
+	s.notifyChanged()
 	return nil
 }
 
@@ -171,12 +244,21 @@ func (s *Store) GetStatus() (Status, error) {
 		SELECT COUNT(DISTINCT d.hash)
 		FROM documents d
 		LEFT JOIN content_vectors v ON d.hash = v.hash AND v.seq = 0
-		WHERE d.active = 1 AND v.hash IS NULL
+		LEFT JOIN embedding_progress p ON p.hash = d.hash
+		WHERE d.active = 1 AND (v.hash IS NULL OR p.status IN ('running', 'failed'))
+		  AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')
 	`).Scan(&status.NeedsEmbedding)
 	if err != nil {
 		return status, fmt.Errorf("failed to count documents needing embedding: %w", err)
 	}
 
+	// 加上使用专用嵌入模型的集合
+	modelPending, err := s.countNeedingModelEmbedding()
+	if err != nil {
+		return status, fmt.Errorf("failed to count documents needing embedding: %w", err)
+	}
+	status.NeedsEmbedding += modelPending
+
 	// 获取集合列表
 	rows, err := s.db.Query("SELECT DISTINCT collection FROM documents WHERE active = 1 ORDER BY collection")
 	if err != nil {
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DeleteFilter 批量删除文档的条件，至少指定一项
+type DeleteFilter struct {
+	Collection string
+	Path       string    // 集合内路径的 glob（支持 **），不含通配符时匹配该路径及其下的文档
+	Dates      DateRange // 文档日期，没有解析出日期时用修改时间
+}
+
+// IsZero 是否没有任何条件
+func (f DeleteFilter) IsZero() bool {
+	return f.Collection == "" && f.Path == "" && f.Dates.IsZero()
+}
+
+// DeletedDocument 批量删除命中的文档
+type DeletedDocument struct {
+	Collection string
+	Path       string
+	Title      string
+	Hash       string
+	ModifiedAt time.Time
+}
+
+// DeleteDocumentsWhere 在一个事务中停用满足条件的活跃文档（软删除，同 DeleteDocument）
+// dryRun 时只返回命中的文档，不做修改
+func (s *Store) DeleteDocumentsWhere(f DeleteFilter, dryRun bool) ([]DeletedDocument, error) {
+	if f.IsZero() {
+		return nil, fmt.Errorf("delete filter is empty: specify a collection, path or date")
+	}
+	pattern := NormalizeDocPath(f.Path)
+	if s.pathNoCase {
+		pattern = strings.ToLower(pattern)
+	}
+	if pattern != "" && !doublestar.ValidatePattern(pattern) {
+		return nil, fmt.Errorf("invalid path pattern: %s", f.Path)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT d.id, d.collection, d.path, d.title, d.hash, d.modified_at
+		FROM documents d
+		WHERE d.active = 1`
+	var args []interface{}
+	if f.Collection != "" {
+		if err := requireCollection(tx, f.Collection); err != nil {
+			return nil, err
+		}
+		query += " AND d.collection = ?"
+		args = append(args, f.Collection)
+	}
+	dateClause, dateArgs := f.Dates.sqlFilter()
+	query += dateClause + " ORDER BY d.collection, d.path"
+	args = append(args, dateArgs...)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	var ids []int64
+	var matched []DeletedDocument
+	for rows.Next() {
+		var id int64
+		var doc DeletedDocument
+		var modifiedAt string
+		if err := rows.Scan(&id, &doc.Collection, &doc.Path, &doc.Title, &doc.Hash, &modifiedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		if pattern != "" {
+			target := doc.Path
+			if s.pathNoCase {
+				target = strings.ToLower(target)
+			}
+			if !globPathMatch(pattern, target) {
+				continue
+			}
+		}
+		doc.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
+		ids = append(ids, id)
+		matched = append(matched, doc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if dryRun || len(matched) == 0 {
+		return matched, nil
+	}
+
+	defer s.InvalidateCatalog()
+	seen := make(map[string]bool)
+	var hashes []string
+	for i, id := range ids {
+		if err := logDocumentEvents(tx, EventDocRemoved, s.actor, "id = ?", id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE documents SET active = 0 WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete document: %w", err)
+		}
+		if hash := matched[i].Hash; !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+
+	// 内容不再被其他活跃文档引用时一并删除向量
+	if _, err := purgeVectors(tx, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit delete: %w", err)
+	}
+	s.notifyChanged()
+	return matched, nil
+}
@@ -18,6 +18,12 @@ type DocumentListEntry struct {
 	Hash       string    `json:"hash"`
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
+
+	WordCount      int `json:"word_count"`
+	CharCount      int `json:"char_count"`
+	ReadingSeconds int `json:"reading_seconds"` // 预计阅读时间
+
+	Date string `json:"date,omitempty"` // 文档日期（YYYY-MM-DD）
 }
 
 // DocumentDetail 文档详情
@@ -31,66 +37,60 @@ type DocumentDetail struct {
 	Hash       string    `json:"hash"`
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
+
+	WordCount      int `json:"word_count"`
+	CharCount      int `json:"char_count"`
+	ReadingSeconds int `json:"reading_seconds"`
+
+	Date string `json:"date,omitempty"`
 }
 
-// ListDocumentsByPath 列出集合或路径下的文档
+// ListDocumentsByPath 列出集合或路径下的文档（读内存目录缓存）
 // - collection 为空：列出所有集合
 // - collection 不为空，path 为空：列出集合下所有文档
 // - collection 和 path 都不为空：列出路径下的文档（前缀匹配）
 func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntry, error) {
-	var rows *sql.Rows
-	var err error
+	entries, err := s.Catalog()
+	if err != nil {
+		return nil, err
+	}
 
-	if collection == "" {
-		// 列出所有文档（按集合分组）
-		rows, err = s.db.Query(`
-			SELECT
-				id,
-				collection,
-				path,
-				title,
-				hash,
-				created_at,
-				modified_at
-			FROM documents
-			WHERE active = 1
-			ORDER BY collection, path
-		`)
-	} else if path == "" {
-		// 列出集合下所有文档
-		rows, err = s.db.Query(`
-			SELECT
-				id,
-				collection,
-				path,
-				title,
-				hash,
-				created_at,
-				modified_at
-			FROM documents
-			WHERE active = 1 AND collection = ?
-			ORDER BY path
-		`, collection)
-	} else {
-		// 列出路径下的文档（前缀匹配）
-		pathPrefix := strings.TrimSuffix(path, "/")
-		rows, err = s.db.Query(`
-			SELECT
-				id,
-				collection,
-				path,
-				title,
-				hash,
-				created_at,
-				modified_at
-			FROM documents
-			WHERE active = 1
-				AND collection = ?
-				AND (path = ? OR path LIKE ?)
-			ORDER BY path
-		`, collection, pathPrefix, pathPrefix+"/%")
+	pathPrefix := strings.TrimSuffix(path, "/")
+	var docs []DocumentListEntry
+	for _, e := range entries {
+		if collection != "" && e.Collection != collection {
+			continue
+		}
+		// 与 SQL LIKE 一致，前缀匹配不区分大小写
+		if path != "" && e.Path != pathPrefix &&
+			!(len(e.Path) > len(pathPrefix) && strings.EqualFold(e.Path[:len(pathPrefix)+1], pathPrefix+"/")) {
+			continue
+		}
+		docs = append(docs, e)
 	}
+	return docs, nil
+}
 
+// queryCatalog 从数据库读取所有活跃文档的目录条目（按集合、路径排序）
+func (s *Store) queryCatalog() ([]DocumentListEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			d.id,
+			d.collection,
+			d.path,
+			d.title,
+			d.hash,
+			d.created_at,
+			d.modified_at,
+			COALESCE(c.word_count, 0),
+			COALESCE(c.char_count, 0),
+			COALESCE(c.reading_seconds, 0),
+			d.doc_date
+		FROM documents d
+		LEFT JOIN content c ON c.hash = d.hash
+		WHERE d.active = 1
+		ORDER BY d.collection, d.path
+	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list documents: %w", err)
 	}
@@ -109,6 +109,10 @@ func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntr
 			&doc.Hash,
 			&createdStr,
 			&modifiedStr,
+			&doc.WordCount,
+			&doc.CharCount,
+			&doc.ReadingSeconds,
+			&doc.Date,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan document: %w", err)
@@ -124,17 +128,21 @@ func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntr
 		docs = append(docs, doc)
 	}
 
-	return docs, nil
+	return docs, rows.Err()
 }
 
 // GetDocumentByPath 通过路径获取文档
 // 路径格式：collection/path 或 mmq://collection/path
 func (s *Store) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
 	// 解析路径
-	collection, path := parseFilePath(filePath)
+	collection, path := s.resolveDocRef(filePath)
 	if collection == "" {
 		return nil, fmt.Errorf("invalid file path: %s", filePath)
 	}
+	match := "d.collection = ? AND d.path = ?"
+	if s.pathNoCase {
+		match = "d.collection = ? AND d.path = ? COLLATE NOCASE"
+	}
 
 	var doc DocumentDetail
 	var createdStr, modifiedStr string
@@ -149,12 +157,15 @@ func (s *Store) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
 			d.hash,
 			c.doc,
 			d.created_at,
-			d.modified_at
+			d.modified_at,
+			c.word_count,
+			c.char_count,
+			c.reading_seconds,
+			d.doc_date
 		FROM documents d
 		JOIN content c ON c.hash = d.hash
-		WHERE d.active = 1
-			AND d.collection = ?
-			AND d.path = ?
+		WHERE d.active = 1 AND `+match+`
+		LIMIT 1
 	`, collection, path).Scan(
 		&doc.ID,
 		&doc.Collection,
@@ -164,6 +175,10 @@ func (s *Store) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
 		&content,
 		&createdStr,
 		&modifiedStr,
+		&doc.WordCount,
+		&doc.CharCount,
+		&doc.ReadingSeconds,
+		&doc.Date,
 	)
 
 	if err == sql.ErrNoRows {
@@ -205,7 +220,11 @@ func (s *Store) GetDocumentByID(docID string) (*DocumentDetail, error) {
 			d.hash,
 			c.doc,
 			d.created_at,
-			d.modified_at
+			d.modified_at,
+			c.word_count,
+			c.char_count,
+			c.reading_seconds,
+			d.doc_date
 		FROM documents d
 		JOIN content c ON c.hash = d.hash
 		WHERE d.active = 1
@@ -220,6 +239,10 @@ func (s *Store) GetDocumentByID(docID string) (*DocumentDetail, error) {
 		&content,
 		&createdStr,
 		&modifiedStr,
+		&doc.WordCount,
+		&doc.CharCount,
+		&doc.ReadingSeconds,
+		&doc.Date,
 	)
 
 	if err == sql.ErrNoRows {
@@ -280,7 +303,11 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 				c.doc,
 				d.created_at,
 				d.modified_at,
-				length(c.doc) as size
+				length(c.doc) as size,
+				c.word_count,
+				c.char_count,
+				c.reading_seconds,
+				d.doc_date
 			FROM documents d
 			JOIN content c ON c.hash = d.hash
 			WHERE d.active = 1
@@ -298,7 +325,11 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 				c.doc,
 				d.created_at,
 				d.modified_at,
-				length(c.doc) as size
+				length(c.doc) as size,
+				c.word_count,
+				c.char_count,
+				c.reading_seconds,
+				d.doc_date
 			FROM documents d
 			JOIN content c ON c.hash = d.hash
 			WHERE d.active = 1 AND d.collection = ?
@@ -327,6 +358,10 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 			&createdStr,
 			&modifiedStr,
 			&size,
+			&doc.WordCount,
+			&doc.CharCount,
+			&doc.ReadingSeconds,
+			&doc.Date,
 		)
 		if err != nil {
 			continue
@@ -419,10 +454,12 @@ func (s *Store) getDocumentSingle(identifier string, maxBytes int) (*DocumentDet
 // - qmd://collection/path -> (collection, path) (兼容旧格式)
 // - collection/path -> (collection, path)
 // - path -> ("", path)
+// Windows 风格的 '\' 分隔符按 '/' 处理
 func parseFilePath(filePath string) (collection, path string) {
 	// 移除 mmq:// 或 qmd:// 前缀
 	filePath = strings.TrimPrefix(filePath, "mmq://")
 	filePath = strings.TrimPrefix(filePath, "qmd://")
+	filePath = strings.ReplaceAll(filePath, `\`, "/")
 
 	// 分割路径
 	parts := strings.SplitN(filePath, "/", 2)
@@ -431,5 +468,70 @@ func parseFilePath(filePath string) (collection, path string) {
 		return "", parts[0]
 	}
 
-	return parts[0], parts[1]
+	return parts[0], NormalizeDocPath(parts[1])
+}
+
+// GetDocumentContents 获取活跃文档的完整内容（collection 为空表示所有集合）
+func (s *Store) GetDocumentContents(collection string) ([]DocumentDetail, error) {
+	query := `
+		SELECT d.id, d.collection, d.path, d.title, d.hash, c.doc, d.created_at, d.modified_at
+		FROM documents d
+		JOIN content c ON c.hash = d.hash
+		WHERE d.active = 1
+	`
+	args := []interface{}{}
+	if collection != "" {
+		query += " AND d.collection = ?"
+		args = append(args, collection)
+	}
+	query += " ORDER BY d.collection, d.path"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentDetail
+	for rows.Next() {
+		var doc DocumentDetail
+		var createdAt, modifiedAt string
+		if err := rows.Scan(&doc.ID, &doc.Collection, &doc.Path, &doc.Title, &doc.Hash,
+			&doc.Content, &createdAt, &modifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		doc.DocID = "#" + getDocid(doc.Hash)
+		doc.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		doc.ModifiedAt, _ = time.Parse(time.RFC3339, modifiedAt)
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// SampleDocuments 从集合中随机抽取 n 个活跃文档
+func (s *Store) SampleDocuments(collection string, n int) ([]DocumentDetail, error) {
+	rows, err := s.db.Query(`
+		SELECT d.id, d.collection, d.path, d.title, d.hash, c.doc
+		FROM documents d
+		JOIN content c ON c.hash = d.hash
+		WHERE d.active = 1 AND d.collection = ?
+		ORDER BY RANDOM()
+		LIMIT ?
+	`, collection, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []DocumentDetail
+	for rows.Next() {
+		var doc DocumentDetail
+		if err := rows.Scan(&doc.ID, &doc.Collection, &doc.Path, &doc.Title, &doc.Hash, &doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		doc.DocID = "#" + getDocid(doc.Hash)
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
 }
@@ -0,0 +1,21 @@
+//go:build !purego
+
+package store
+
+import (
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqliteDriver database/sql 驱动名（mattn/go-sqlite3，需要 cgo）
+const sqliteDriver = "sqlite3"
+
+// registerVec 为之后打开的所有连接注册 sqlite-vec 扩展
+func registerVec() {
+	sqlite_vec.Auto()
+}
+
+// serializeFloat32 把向量序列化为 sqlite-vec 接受的 BLOB
+func serializeFloat32(v []float32) ([]byte, error) {
+	return sqlite_vec.SerializeFloat32(v)
+}
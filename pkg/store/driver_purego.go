@@ -0,0 +1,19 @@
+//go:build purego
+
+package store
+
+import (
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/ncruces"
+	_ "github.com/ncruces/go-sqlite3/driver" // 纯 Go SQLite 驱动（WASM，无需 cgo）
+)
+
+// sqliteDriver database/sql 驱动名（ncruces/go-sqlite3，内嵌包含 sqlite-vec 和 FTS5 的 SQLite 构建）
+const sqliteDriver = "sqlite3"
+
+// registerVec sqlite-vec 已编译进内嵌的 SQLite，无需注册
+func registerVec() {}
+
+// serializeFloat32 把向量序列化为 sqlite-vec 接受的 BLOB
+func serializeFloat32(v []float32) ([]byte, error) {
+	return sqlite_vec.SerializeFloat32(v)
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetCollectionEmbedModel 获取集合的专用嵌入模型（空字符串表示使用默认模型）
+func (s *Store) GetCollectionEmbedModel(name string) (string, error) {
+	var model string
+	err := s.db.QueryRow("SELECT embed_model FROM collections WHERE name = ?", name).Scan(&model)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("collection '%s' not found", name)
+	}
+	return model, err
+}
+
+// SetCollectionEmbedModel 设置集合的专用嵌入模型，空字符串恢复默认模型
+func (s *Store) SetCollectionEmbedModel(name, model string) error {
+	result, err := s.db.Exec("UPDATE collections SET embed_model = ? WHERE name = ?", model, name)
+	if err != nil {
+		return fmt.Errorf("failed to set embedding model: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+	return nil
+}
+
+// ListEmbedModels 返回集合使用的专用嵌入模型（去重，不含默认模型）
+func (s *Store) ListEmbedModels() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT embed_model FROM collections
+		WHERE embed_model != ''
+		ORDER BY embed_model
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding models: %w", err)
+	}
+	defer rows.Close()
+
+	var models []string
+	for rows.Next() {
+		var model string
+		if err := rows.Scan(&model); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding model: %w", err)
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
+
+// GetDocumentsNeedingModelEmbedding 获取使用指定专用模型、且尚无该模型嵌入的文档
+func (s *Store) GetDocumentsNeedingModelEmbedding(model string) ([]Document, error) {
+	rows, err := s.db.Query(`
+		SELECT d.hash, c.doc, d.collection, d.path
+		FROM documents d
+		JOIN collections coll ON coll.name = d.collection AND coll.embed_model = ?
+		JOIN content c ON c.hash = d.hash
+		LEFT JOIN model_vectors v ON v.model = ? AND v.hash = d.hash AND v.seq = 0
+		WHERE d.active = 1 AND v.hash IS NULL
+		GROUP BY d.hash
+		ORDER BY MAX(d.modified_at) DESC
+	`, model, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		var doc Document
+		if err := rows.Scan(&doc.Hash, &doc.Content, &doc.Collection, &doc.Path); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// countNeedingModelEmbedding 统计缺少专用模型嵌入的文档数
+func (s *Store) countNeedingModelEmbedding() (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT DISTINCT coll.embed_model, d.hash
+			FROM documents d
+			JOIN collections coll ON coll.name = d.collection AND coll.embed_model != ''
+			LEFT JOIN model_vectors v ON v.model = coll.embed_model AND v.hash = d.hash AND v.seq = 0
+			WHERE d.active = 1 AND v.hash IS NULL
+		)
+	`).Scan(&count)
+	return count, err
+}
+
+// StoreModelEmbedding 存储专用模型的嵌入向量，每个模型使用独立的向量表（维度可以不同）
+func (s *Store) StoreModelEmbedding(model, hash string, seq, pos int, embedding []float32) error {
+	table, err := s.ensureModelVectorTable(model, len(embedding))
+	if err != nil {
+		return err
+	}
+
+	blob, err := serializeFloat32(embedding)
+	if err != nil {
+		return fmt.Errorf("failed to serialize vector: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	hashSeq := fmt.Sprintf("%s_%d", hash, seq)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO model_vectors (model, hash, seq, pos, embedding, embedded_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, model, hash, seq, pos, blob, now)
+	if err != nil {
+		return fmt.Errorf("failed to store embedding metadata: %w", err)
+	}
+
+	// vec0 表的 INSERT OR REPLACE 不会替换已有主键，先删除
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE hash_seq = ?", hashSeq); err != nil {
+		return fmt.Errorf("failed to replace vector: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO "+table+" (hash_seq, embedding) VALUES (?, ?)", hashSeq, blob); err != nil {
+		return fmt.Errorf("failed to store vector in %s: %w", table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteModelEmbeddings 删除文档在专用模型下的所有嵌入
+func (s *Store) DeleteModelEmbeddings(model, hash string) error {
+	table, err := s.modelVectorTable(model)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM model_vectors WHERE model = ? AND hash = ?", model, hash); err != nil {
+		return fmt.Errorf("failed to delete from model_vectors: %w", err)
+	}
+	if table != "" {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE hash_seq LIKE ?", hash+"_%"); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SearchModelVectorDocuments 在专用模型的向量表中搜索，queryEmbed 须由同一模型生成
+// 只返回使用该模型的集合中的文档
+func (s *Store) SearchModelVectorDocuments(model, query string, queryEmbed []float32, limit int, collection, language string, dates DateRange) ([]SearchResult, error) {
+	table, err := s.modelVectorTable(model)
+	if err != nil {
+		return nil, err
+	}
+	if table == "" {
+		// 该模型还没有任何向量
+		return []SearchResult{}, nil
+	}
+
+	return s.searchVectorSpace(vectorSpace{vecTable: table, metaTable: "model_vectors", model: model},
+		query, queryEmbed, limit, collection, language, dates)
+}
+
+// modelVectorTable 返回专用模型的向量表名，尚未创建时返回空字符串
+func (s *Store) modelVectorTable(model string) (string, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT id FROM embedding_models WHERE model = ?", model).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up embedding model: %w", err)
+	}
+
+	table := modelVectorTableName(id)
+	exists, err := s.tableExists(table)
+	if err != nil || !exists {
+		return "", err
+	}
+	return table, nil
+}
+
+// ensureModelVectorTable 登记专用模型并确保其向量表存在
+func (s *Store) ensureModelVectorTable(model string, dimensions int) (string, error) {
+	if _, err := s.db.Exec("INSERT OR IGNORE INTO embedding_models (model) VALUES (?)", model); err != nil {
+		return "", fmt.Errorf("failed to register embedding model: %w", err)
+	}
+
+	var id int64
+	if err := s.db.QueryRow("SELECT id FROM embedding_models WHERE model = ?", model).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to look up embedding model: %w", err)
+	}
+
+	table := modelVectorTableName(id)
+	if err := s.ensureVecTable(table, dimensions); err != nil {
+		return "", err
+	}
+	return table, nil
+}
+
+// modelVectorTableName 专用模型向量表名（用登记 id 命名，避免模型名中的特殊字符）
+func modelVectorTableName(id int64) string {
+	return fmt.Sprintf("vectors_vec_%d", id)
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// 嵌入进度状态
+const (
+	EmbedStatusRunning = "running" // 进行中（中断后可续传）
+	EmbedStatusDone    = "done"    // 已完成
+	EmbedStatusFailed  = "failed"  // 失败，Error 记录原因
+)
+
+// EmbeddingProgress 单个文档的嵌入进度，前 DoneChunks 个块已写入
+type EmbeddingProgress struct {
+	Hash        string
+	Model       string
+	TotalChunks int
+	DoneChunks  int
+	Status      string
+	Error       string
+	UpdatedAt   time.Time
+}
+
+// GetEmbeddingProgress 获取文档的嵌入进度，没有记录时返回 nil
+func (s *Store) GetEmbeddingProgress(hash string) (*EmbeddingProgress, error) {
+	var p EmbeddingProgress
+	var updatedAt string
+	err := s.db.QueryRow(`
+		SELECT hash, model, total_chunks, done_chunks, status, error, updated_at
+		FROM embedding_progress WHERE hash = ?
+	`, hash).Scan(&p.Hash, &p.Model, &p.TotalChunks, &p.DoneChunks, &p.Status, &p.Error, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding progress: %w", err)
+	}
+	p.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return &p, nil
+}
+
+// SaveEmbeddingProgress 保存文档的嵌入进度
+func (s *Store) SaveEmbeddingProgress(p EmbeddingProgress) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO embedding_progress (hash, model, total_chunks, done_chunks, status, error, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, p.Hash, p.Model, p.TotalChunks, p.DoneChunks, p.Status, p.Error,
+		time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save embedding progress: %w", err)
+	}
+	return nil
+}
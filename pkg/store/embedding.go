@@ -1,21 +1,26 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
-
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 )
 
 // GetDocumentsNeedingEmbedding 获取需要生成嵌入的文档
 func (s *Store) GetDocumentsNeedingEmbedding() ([]Document, error) {
+	// 没有任何嵌入，或上次嵌入未完成/失败的文档（使用专用模型的集合除外）
 	query := `
-		SELECT DISTINCT d.hash, c.doc
+		SELECT d.hash, c.doc, d.collection, d.path
 		FROM documents d
 		JOIN content c ON c.hash = d.hash
 		LEFT JOIN content_vectors v ON d.hash = v.hash AND v.seq = 0
-		WHERE d.active = 1 AND v.hash IS NULL
-		ORDER BY d.modified_at DESC
+		LEFT JOIN embedding_progress p ON p.hash = d.hash
+		WHERE d.active = 1 AND (v.hash IS NULL OR p.status IN ('running', 'failed'))
+		  AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')
+		GROUP BY d.hash
+		ORDER BY MAX(d.modified_at) DESC
 	`
 
 	rows, err := s.db.Query(query)
@@ -27,7 +32,7 @@ func (s *Store) GetDocumentsNeedingEmbedding() ([]Document, error) {
 	var docs []Document
 	for rows.Next() {
 		var doc Document
-		err := rows.Scan(&doc.Hash, &doc.Content)
+		err := rows.Scan(&doc.Hash, &doc.Content, &doc.Collection, &doc.Path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan document: %w", err)
 		}
@@ -45,7 +50,7 @@ func (s *Store) StoreEmbedding(hash string, seq int, pos int, embedding []float3
 	}
 
 	// 序列化向量（content_vectors 和 vectors_vec 共用）
-	blob, err := sqlite_vec.SerializeFloat32(embedding)
+	blob, err := serializeFloat32(embedding)
 	if err != nil {
 		return fmt.Errorf("failed to serialize vector: %w", err)
 	}
@@ -87,6 +92,21 @@ func (s *Store) StoreEmbedding(hash string, seq int, pos int, embedding []float3
 	return nil
 }
 
+// RecordChunkSettings 记录内容的向量使用的分块参数，供排查分块问题
+// model 为空时更新默认模型的向量，否则更新该专用模型的向量
+func (s *Store) RecordChunkSettings(model, hash string, size, overlap int) error {
+	var err error
+	if model == "" {
+		_, err = s.db.Exec("UPDATE content_vectors SET chunk_size = ?, chunk_overlap = ? WHERE hash = ?", size, overlap, hash)
+	} else {
+		_, err = s.db.Exec("UPDATE model_vectors SET chunk_size = ?, chunk_overlap = ? WHERE model = ? AND hash = ?", size, overlap, model, hash)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record chunk settings: %w", err)
+	}
+	return nil
+}
+
 // GetEmbedding 获取嵌入向量
 func (s *Store) GetEmbedding(hash string, seq int) ([]float32, error) {
 	var blob []byte
@@ -174,3 +194,116 @@ func (s *Store) CountEmbeddedDocuments() (int, error) {
 
 	return count, nil
 }
+
+// ChunkVector 一个分块已存储的向量
+type ChunkVector struct {
+	Seq        int
+	Pos        int
+	Model      string
+	Dimensions int
+	EmbeddedAt time.Time
+	// 生成该向量时的分块参数（0 表示未记录）
+	ChunkSize    int
+	ChunkOverlap int
+}
+
+// GetChunkVectors 返回内容的全部分块向量（默认模型和集合专用模型），按 seq、模型排序
+func (s *Store) GetChunkVectors(hash string) ([]ChunkVector, error) {
+	rows, err := s.db.Query(`
+		SELECT seq, pos, model, length(embedding) / 4, embedded_at, chunk_size, chunk_overlap FROM content_vectors WHERE hash = ?
+		UNION ALL
+		SELECT seq, pos, model, length(embedding) / 4, embedded_at, chunk_size, chunk_overlap FROM model_vectors WHERE hash = ?
+		ORDER BY 1, 3
+	`, hash, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk vectors: %w", err)
+	}
+	defer rows.Close()
+
+	var vectors []ChunkVector
+	for rows.Next() {
+		var v ChunkVector
+		var dims sql.NullInt64
+		var embeddedAt string
+		if err := rows.Scan(&v.Seq, &v.Pos, &v.Model, &dims, &embeddedAt, &v.ChunkSize, &v.ChunkOverlap); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk vector: %w", err)
+		}
+		v.Dimensions = int(dims.Int64)
+		v.EmbeddedAt, _ = time.Parse(time.RFC3339, embeddedAt)
+		vectors = append(vectors, v)
+	}
+	return vectors, rows.Err()
+}
+
+// ChunkEmbedding 分块向量及其所属文档
+type ChunkEmbedding struct {
+	Collection string
+	Path       string
+	Title      string
+	Hash       string
+	Seq        int
+	Pos        int
+	Text       string // 分块开头的文本
+	Embedding  []float32
+}
+
+// GetChunkEmbeddings 返回活跃文档的分块向量
+// model 为空时读取默认模型的向量（不含使用专用模型的集合），否则读取该专用模型的向量；
+// limit > 0 时随机抽取最多 limit 个
+func (s *Store) GetChunkEmbeddings(collection, model string, limit int) ([]ChunkEmbedding, error) {
+	table, modelFilter := "content_vectors", "AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')"
+	args := []interface{}{collection, collection}
+	if model != "" {
+		table, modelFilter = "model_vectors", "AND v.model = ?"
+		args = append(args, model)
+	}
+
+	order := "ORDER BY d.collection, d.path, v.seq"
+	if limit > 0 {
+		order = "ORDER BY random() LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT d.collection, d.path, d.title, v.hash, v.seq, v.pos, v.embedding,
+		       substr(CAST(c.doc AS BLOB), v.pos + 1, 200)
+		FROM `+table+` v
+		JOIN documents d ON d.hash = v.hash AND d.active = 1
+		JOIN content c ON c.hash = v.hash
+		WHERE (? = '' OR d.collection = ?) `+modelFilter+`
+		`+order, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []ChunkEmbedding
+	for rows.Next() {
+		var c ChunkEmbedding
+		var blob, text []byte
+		if err := rows.Scan(&c.Collection, &c.Path, &c.Title, &c.Hash, &c.Seq, &c.Pos, &blob, &text); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk embedding: %w", err)
+		}
+		c.Embedding = blobToFloat32(blob)
+		c.Text = strings.ToValidUTF8(string(text), "")
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 {
+		// 抽样后恢复稳定的顺序
+		sort.Slice(chunks, func(i, j int) bool {
+			a, b := chunks[i], chunks[j]
+			if a.Collection != b.Collection {
+				return a.Collection < b.Collection
+			}
+			if a.Path != b.Path {
+				return a.Path < b.Path
+			}
+			return a.Seq < b.Seq
+		})
+	}
+	return chunks, nil
+}
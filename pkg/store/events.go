@@ -0,0 +1,186 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 事件类型
+const (
+	EventDocAdded          = "doc_added"          // 新文档（或重新激活的文档）
+	EventDocUpdated        = "doc_updated"        // 文档内容或标题变化
+	EventDocRemoved        = "doc_removed"        // 文档被删除或停用
+	EventCollectionRenamed = "collection_renamed" // 集合重命名（Detail 为旧名称）
+	EventMemoryStored      = "memory_stored"      // 新记忆（Detail 为记忆类型）
+	EventMemoryUpdated     = "memory_updated"     // 记忆内容更新
+	EventMemoryDeleted     = "memory_deleted"     // 记忆被删除或过期清理
+)
+
+// Event 索引变更事件，事件日志只追加，ID 单调递增
+type Event struct {
+	ID         int64
+	Time       time.Time
+	Type       string
+	Actor      string // 执行变更的一方（CLI、agent 名称等）
+	Collection string
+	Path       string
+	Hash       string
+	MemoryID   string
+	Detail     string
+	Origin     string // 变更最初发生的实例 ID（同步写入时设置，本机变更为空）
+}
+
+// EventFilter 事件查询条件
+type EventFilter struct {
+	AfterID    int64     // 只返回 ID 大于该值的事件（增量同步）
+	Since      time.Time // 只返回该时间之后的事件
+	Types      []string
+	Collection string
+	Actor      string
+	Limit      int // 0 表示不限制；有 AfterID 时取其后最早的 N 条，否则取最新的 N 条
+}
+
+// execer 事件可以写在调用方的事务中
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SetActor 设置之后变更记录的执行者
+func (s *Store) SetActor(actor string) {
+	s.actor = actor
+}
+
+// Actor 返回当前的执行者
+func (s *Store) Actor() string {
+	return s.actor
+}
+
+// SetChangeHook 设置文档变更（增删改事件）提交后的回调，用于通知订阅方读取新事件
+func (s *Store) SetChangeHook(fn func()) {
+	if fn == nil {
+		s.changed.Store(nil)
+		return
+	}
+	s.changed.Store(&fn)
+}
+
+// notifyChanged 文档事件提交后调用变更回调
+func (s *Store) notifyChanged() {
+	if fn := s.changed.Load(); fn != nil {
+		(*fn)()
+	}
+}
+
+// LastEventID 当前最大的事件 ID，订阅方从这里开始读取之后的事件
+func (s *Store) LastEventID() (int64, error) {
+	id, err := s.maxEventID()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query last event: %w", err)
+	}
+	return id, nil
+}
+
+// logEvent 追加一条事件
+func logEvent(ex execer, e Event) error {
+	_, err := ex.Exec(`
+		INSERT INTO events (time, type, actor, collection, path, hash, memory_id, detail, origin)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, time.Now().UTC().Format(time.RFC3339), e.Type, e.Actor, e.Collection, e.Path, e.Hash, e.MemoryID, e.Detail, e.Origin)
+	if err != nil {
+		return fmt.Errorf("failed to log %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// logDocumentEvents 为满足条件的文档各追加一条事件（where 作用于 documents 表）
+func logDocumentEvents(ex execer, eventType, actor, where string, args ...interface{}) error {
+	params := append([]interface{}{time.Now().UTC().Format(time.RFC3339), eventType, actor}, args...)
+	_, err := ex.Exec(`
+		INSERT INTO events (time, type, actor, collection, path, hash)
+		SELECT ?, ?, ?, collection, path, hash FROM documents WHERE `+where+`
+		ORDER BY collection, path
+	`, params...)
+	if err != nil {
+		return fmt.Errorf("failed to log %s events: %w", eventType, err)
+	}
+	return nil
+}
+
+// logMemoryEvents 为满足条件的记忆各追加一条事件（where 作用于 memories 表）
+func logMemoryEvents(ex execer, eventType, actor, where string, args ...interface{}) error {
+	params := append([]interface{}{time.Now().UTC().Format(time.RFC3339), eventType, actor}, args...)
+	_, err := ex.Exec(`
+		INSERT INTO events (time, type, actor, memory_id, detail)
+		SELECT ?, ?, ?, id, type FROM memories WHERE `+where+`
+		ORDER BY timestamp
+	`, params...)
+	if err != nil {
+		return fmt.Errorf("failed to log %s events: %w", eventType, err)
+	}
+	return nil
+}
+
+// ListEvents 按 ID 升序返回事件
+func (s *Store) ListEvents(f EventFilter) ([]Event, error) {
+	var conds []string
+	var args []interface{}
+	if f.AfterID > 0 {
+		conds = append(conds, "id > ?")
+		args = append(args, f.AfterID)
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "time >= ?")
+		args = append(args, f.Since.UTC().Format(time.RFC3339))
+	}
+	if len(f.Types) > 0 {
+		conds = append(conds, "type IN (?"+strings.Repeat(",?", len(f.Types)-1)+")")
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+	if f.Collection != "" {
+		conds = append(conds, "collection = ?")
+		args = append(args, f.Collection)
+	}
+	if f.Actor != "" {
+		conds = append(conds, "actor = ?")
+		args = append(args, f.Actor)
+	}
+
+	query := "SELECT id, time, type, actor, collection, path, hash, memory_id, detail, origin FROM events"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	switch {
+	case f.Limit > 0 && f.AfterID > 0:
+		// 增量同步：取游标之后最早的 N 条
+		query += " ORDER BY id LIMIT ?"
+		args = append(args, f.Limit)
+	case f.Limit > 0:
+		// 取最新的 N 条，仍按 ID 升序返回
+		query = "SELECT * FROM (" + query + " ORDER BY id DESC LIMIT ?) ORDER BY id"
+		args = append(args, f.Limit)
+	default:
+		query += " ORDER BY id"
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var ts string
+		if err := rows.Scan(&e.ID, &ts, &e.Type, &e.Actor, &e.Collection, &e.Path, &e.Hash, &e.MemoryID, &e.Detail, &e.Origin); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		e.Time, _ = time.Parse(time.RFC3339, ts)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
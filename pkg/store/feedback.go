@@ -0,0 +1,97 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchFeedback 一条搜索结果的相关性反馈
+type SearchFeedback struct {
+	ID         int64
+	Query      string
+	Collection string
+	Path       string
+	Relevant   bool
+	CreatedAt  time.Time
+}
+
+// InsertSearchFeedback 记录某次查询中一个文档是否相关
+func (s *Store) InsertSearchFeedback(query, collection, path string, relevant bool) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return fmt.Errorf("feedback query is empty")
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO search_feedback (query, collection, path, relevant, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, query, collection, NormalizeDocPath(path), relevant, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record search feedback: %w", err)
+	}
+	return nil
+}
+
+// ListSearchFeedback 按记录顺序返回所有相关性反馈
+func (s *Store) ListSearchFeedback() ([]SearchFeedback, error) {
+	rows, err := s.db.Query(`
+		SELECT id, query, collection, path, relevant, created_at
+		FROM search_feedback ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list search feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var list []SearchFeedback
+	for rows.Next() {
+		var f SearchFeedback
+		var created string
+		if err := rows.Scan(&f.ID, &f.Query, &f.Collection, &f.Path, &f.Relevant, &created); err != nil {
+			return nil, err
+		}
+		f.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// ClearSearchFeedback 删除所有相关性反馈，返回删除的条数
+func (s *Store) ClearSearchFeedback() (int64, error) {
+	res, err := s.db.Exec("DELETE FROM search_feedback")
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear search feedback: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// GetMeta 读取 meta 表中的值，不存在时返回空字符串
+func (s *Store) GetMeta(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read meta %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetMeta 写入 meta 表，value 为空时删除该项
+func (s *Store) SetMeta(key, value string) error {
+	var err error
+	if value == "" {
+		_, err = s.db.Exec("DELETE FROM meta WHERE key = ?", key)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT INTO meta (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		`, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write meta %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// CollectionFreshness 集合最近一次成功索引和生成嵌入的时间
+type CollectionFreshness struct {
+	Name       string
+	Path       string
+	Mask       string
+	Remote     string
+	IndexedAt  time.Time // 零值表示从未完整索引
+	EmbeddedAt time.Time // 零值表示没有嵌入
+}
+
+// CollectionFreshness 返回各集合的最近索引和嵌入时间
+// 升级前索引的集合没有 indexed_at，按完成过重新索引时的 updated_at 计
+func (s *Store) CollectionFreshness() ([]CollectionFreshness, error) {
+	rows, err := s.db.Query(`
+		SELECT c.name, c.path, c.mask, c.remote,
+			CASE WHEN c.indexed_at = '' AND c.generation > 0 THEN c.updated_at ELSE c.indexed_at END,
+			COALESCE((
+				SELECT MAX(v.embedded_at) FROM (
+					SELECT hash, embedded_at FROM content_vectors
+					UNION ALL
+					SELECT hash, embedded_at FROM model_vectors
+				) v
+				JOIN documents d ON d.hash = v.hash AND d.active = 1
+				WHERE d.collection = c.name
+			), '')
+		FROM collections c
+		ORDER BY c.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection freshness: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CollectionFreshness
+	for rows.Next() {
+		var f CollectionFreshness
+		var indexedAt, embeddedAt string
+		if err := rows.Scan(&f.Name, &f.Path, &f.Mask, &f.Remote, &indexedAt, &embeddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan collection freshness: %w", err)
+		}
+		f.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt)
+		f.EmbeddedAt, _ = time.Parse(time.RFC3339, embeddedAt)
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// GetCollectionModTimes 返回集合中从目录索引的活跃文档的修改时间（按路径）
+func (s *Store) GetCollectionModTimes(collection string) (map[string]time.Time, error) {
+	rows, err := s.db.Query(
+		"SELECT path, modified_at FROM documents WHERE collection = ? AND active = 1 AND managed = 1", collection,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document times: %w", err)
+	}
+	defer rows.Close()
+
+	times := make(map[string]time.Time)
+	for rows.Next() {
+		var path, modifiedAt string
+		if err := rows.Scan(&path, &modifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan document time: %w", err)
+		}
+		times[path], _ = time.Parse(time.RFC3339, modifiedAt)
+	}
+	return times, rows.Err()
+}
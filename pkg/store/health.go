@@ -2,7 +2,11 @@ package store
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"time"
+
+	"github.com/dyike/mmq/pkg/i18n"
 )
 
 // IndexHealth 索引健康状态
@@ -49,25 +53,28 @@ func (s *Store) CheckIndexHealth() (*IndexHealth, error) {
 	return h, nil
 }
 
-// PrintIndexHealthWarnings 打印索引健康警告到 stderr
+// PrintIndexHealthWarnings 打印索引健康警告到标准输出
 func PrintIndexHealthWarnings(h *IndexHealth) {
+	WriteIndexHealthWarnings(os.Stdout, h)
+}
+
+// WriteIndexHealthWarnings 把索引健康警告写到 w（管道输出时可用标准错误或丢弃）
+func WriteIndexHealthWarnings(w io.Writer, h *IndexHealth) {
 	if h.TotalDocuments == 0 {
 		return
 	}
 
 	if !h.HasVectorIndex {
-		fmt.Println("⚠ Vector index not found. Run 'mmq embed' to create embeddings.")
+		fmt.Fprintln(w, i18n.T("health.no_vector_index"))
 		return
 	}
 
 	if h.MissingEmbedding > 0 {
 		pct := h.MissingEmbedding * 100 / h.TotalDocuments
-		fmt.Printf("⚠ %d/%d documents (%d%%) missing embeddings. Run 'mmq embed' to update.\n",
-			h.MissingEmbedding, h.TotalDocuments, pct)
+		fmt.Fprintln(w, i18n.T("health.missing_embedding", h.MissingEmbedding, h.TotalDocuments, pct))
 	}
 
 	if h.OldestUpdateDays > 7 {
-		fmt.Printf("⚠ Index last updated %d days ago (%s). Run 'mmq update' to refresh.\n",
-			h.OldestUpdateDays, h.OldestUpdateDate[:10])
+		fmt.Fprintln(w, i18n.T("health.stale", h.OldestUpdateDays, h.OldestUpdateDate[:10]))
 	}
 }
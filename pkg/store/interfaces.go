@@ -0,0 +1,73 @@
+package store
+
+import "time"
+
+// 上层（rag、memory、mmq）只依赖下面这些窄接口，便于替换存储实现或在测试中 mock
+// *Store 实现全部接口
+
+// DocumentStore 文档全文检索、统计和 LLM 结果缓存（查询扩展）
+type DocumentStore interface {
+	SearchFTS(query string, limit int, collectionFilter, languageFilter string, dates DateRange) ([]SearchResult, error)
+	CountActiveDocuments() (int, error)
+	GetCachedResult(key string) (string, error)
+	SetCachedResult(key string, result string) error
+}
+
+// VectorStore 向量检索和集合嵌入模型
+type VectorStore interface {
+	SearchVectorDocuments(query string, queryEmbed []float32, limit int, collection, language string, dates DateRange) ([]SearchResult, error)
+	SearchModelVectorDocuments(model, query string, queryEmbed []float32, limit int, collection, language string, dates DateRange) ([]SearchResult, error)
+	GetCollectionEmbedModel(name string) (string, error)
+	ListEmbedModels() ([]string, error)
+}
+
+// MemoryStore 记忆的增删改查、向量检索和反馈统计
+type MemoryStore interface {
+	InsertMemory(memType, content string, metadata map[string]interface{}, tags []string, timestamp time.Time, expiresAt *time.Time, importance float64, embedding []float32) error
+	UpdateMemory(id, content string, metadata map[string]interface{}, tags []string, expiresAt *time.Time, importance float64, embedding []float32) error
+	UpdateMemoryMetadata(id string, metadata map[string]interface{}) error
+	UpdateMemoryEmbedding(id string, embedding []float32) error
+	MergeMemories(keepID string, tags []string, importance float64, metadata map[string]interface{}, mergeIDs []string) error
+	TouchMemories(ids []string) error
+	DeleteMemory(id string) error
+	DeleteMemoriesBySession(sessionID string) (int, error)
+	DeleteExpiredMemories() (int, error)
+
+	SearchMemories(queryEmbedding []float32, limit int, memoryTypes []string) ([]MemoryResult, error)
+	GetMemoryByID(id string) (*MemoryResult, error)
+	GetMemoriesByType(memType string) ([]MemoryResult, error)
+	GetMemoriesBySession(sessionID string, limit int) ([]MemoryResult, error)
+	GetRecentMemoriesByType(memType string, limit int) ([]MemoryResult, error)
+	GetUnreviewedMemories(source string, limit int) ([]MemoryResult, error)
+	GetAllMemories() ([]MemoryResult, error)
+	GetMemoriesMissingEmbedding() ([]MemoryResult, error)
+	GetMemoryEmbeddings() (map[string][]float32, error)
+	ListMemories(opts MemoryListOptions) ([]MemoryResult, error)
+	GetSessionIDs() ([]string, error)
+
+	CountMemories() (int, error)
+	CountMemoriesByType(memType string) (int, error)
+	CountMemoriesBySession(sessionID string) (int, error)
+	CountMemoriesGroupedByType() (map[string]int, error)
+	CountMemoriesMissingEmbedding() (int, error)
+
+	InsertMemoryFeedback(memType, action string) error
+	CountMemoryFeedback(memType string) (map[string]int, error)
+}
+
+// ContextStore 路径上下文的管理和查找
+type ContextStore interface {
+	AddContext(path, content string) error
+	GetContext(path string) (*ContextEntry, error)
+	ListContexts() ([]ContextEntry, error)
+	RemoveContext(path string) error
+	GetContextsForPath(targetPath string) ([]ContextEntry, error)
+	GetAllContextsForDocument(collection, path string) ([]ContextEntry, error)
+}
+
+var (
+	_ DocumentStore = (*Store)(nil)
+	_ VectorStore   = (*Store)(nil)
+	_ MemoryStore   = (*Store)(nil)
+	_ ContextStore  = (*Store)(nil)
+)
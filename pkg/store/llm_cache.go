@@ -0,0 +1,195 @@
+package store
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LLMCache LLM 结果缓存后端
+type LLMCache interface {
+	// Get 获取缓存结果，未命中时返回空字符串
+	Get(key string) (string, error)
+	// Set 写入缓存结果
+	Set(key, result string) error
+	// Clear 清空缓存，返回删除的条目数
+	Clear() (int, error)
+	// Count 返回缓存条目数
+	Count() (int, error)
+	// Close 释放后端资源
+	Close() error
+}
+
+// llmCacheSchema 独立缓存库的表结构（与主库中的 llm_cache 相同）
+const llmCacheSchema = `
+CREATE TABLE IF NOT EXISTS llm_cache (
+    hash TEXT PRIMARY KEY,
+    result TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_llm_cache_created ON llm_cache(created_at);
+`
+
+// sqliteCache 基于 SQLite llm_cache 表的缓存
+type sqliteCache struct {
+	db         *sql.DB
+	owned      bool // 独立缓存库时由缓存负责关闭
+	maxEntries int  // 0 表示不限制
+}
+
+// NewSQLiteCache 打开独立的 SQLite 缓存库，避免缓存写入使主索引文件碎片化
+func NewSQLiteCache(path string, maxEntries int) (LLMCache, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+	if _, err := db.Exec(llmCacheSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize cache schema: %w", err)
+	}
+	return &sqliteCache{db: db, owned: true, maxEntries: maxEntries}, nil
+}
+
+// NewDBCache 使用主数据库中的 llm_cache 表，并限制条目数
+func NewDBCache(s *Store, maxEntries int) LLMCache {
+	return &sqliteCache{db: s.db, maxEntries: maxEntries}
+}
+
+func (c *sqliteCache) Get(key string) (string, error) {
+	var result string
+	err := c.db.QueryRow(`SELECT result FROM llm_cache WHERE hash = ?`, key).Scan(&result)
+	if err == sql.ErrNoRows {
+		return "", nil // 缓存未命中
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get cached result: %w", err)
+	}
+	return result, nil
+}
+
+func (c *sqliteCache) Set(key, result string) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := c.db.Exec(`
+		INSERT OR REPLACE INTO llm_cache (hash, result, created_at)
+		VALUES (?, ?, ?)
+	`, key, result, now)
+	if err != nil {
+		return fmt.Errorf("failed to set cached result: %w", err)
+	}
+	return c.prune()
+}
+
+// prune 超过上限时删除最早写入的条目
+func (c *sqliteCache) prune() error {
+	if c.maxEntries <= 0 {
+		return nil
+	}
+	count, err := c.Count()
+	if err != nil || count <= c.maxEntries {
+		return err
+	}
+	_, err = c.db.Exec(`
+		DELETE FROM llm_cache WHERE hash IN (
+			SELECT hash FROM llm_cache ORDER BY created_at ASC LIMIT ?
+		)
+	`, count-c.maxEntries)
+	if err != nil {
+		return fmt.Errorf("failed to prune cache: %w", err)
+	}
+	return nil
+}
+
+func (c *sqliteCache) Clear() (int, error) {
+	res, err := c.db.Exec("DELETE FROM llm_cache")
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (c *sqliteCache) Count() (int, error) {
+	var count int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM llm_cache`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get cache stats: %w", err)
+	}
+	return count, nil
+}
+
+func (c *sqliteCache) Close() error {
+	if c.owned {
+		return c.db.Close()
+	}
+	return nil
+}
+
+// memoryCache 进程内 LRU 缓存，进程退出后失效
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // 最近使用的在前
+	items      map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key, result string
+}
+
+// NewMemoryCache 创建内存 LRU 缓存，maxEntries 为 0 时不限制
+func NewMemoryCache(maxEntries int) LLMCache {
+	return &memoryCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *memoryCache) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*memoryCacheEntry).result, nil
+	}
+	return "", nil
+}
+
+func (c *memoryCache) Set(key, result string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*memoryCacheEntry).result = result
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(&memoryCacheEntry{key: key, result: result})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Clear() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.order.Len()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	return n, nil
+}
+
+func (c *memoryCache) Count() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len(), nil
+}
+
+func (c *memoryCache) Close() error { return nil }
@@ -5,23 +5,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	"github.com/google/uuid"
 )
 
 // MemoryResult 记忆查询结果
 type MemoryResult struct {
-	ID         string
-	Type       string
-	Content    string
-	Metadata   map[string]interface{}
-	Tags       []string
-	Timestamp  time.Time
-	ExpiresAt  *time.Time
-	Importance float64
-	Relevance  float64 // 向量搜索时的相关度
+	ID          string
+	Type        string
+	Content     string
+	Metadata    map[string]interface{}
+	Tags        []string
+	Timestamp   time.Time
+	ExpiresAt   *time.Time
+	Importance  float64
+	Relevance   float64 // 向量搜索时的相关度
+	AccessCount int     // 被回忆的次数
+}
+
+// 记忆列表排序方式
+const (
+	MemorySortRecency    = "recency"
+	MemorySortImportance = "importance"
+	MemorySortAccess     = "access"
+)
+
+// MemoryListOptions 记忆列表选项
+type MemoryListOptions struct {
+	Type   string // 为空时列出所有类型
+	SortBy string // recency（默认）、importance、access
+	Limit  int    // 0 表示不限制
+	Offset int
 }
 
 // InsertMemory 插入记忆
@@ -58,7 +75,7 @@ func (s *Store) InsertMemory(
 	}
 
 	// 序列化embedding为BLOB
-	embeddingBlob, err := sqlite_vec.SerializeFloat32(embedding)
+	embeddingBlob, err := serializeFloat32(embedding)
 	if err != nil {
 		return fmt.Errorf("failed to serialize embedding: %w", err)
 	}
@@ -71,12 +88,24 @@ func (s *Store) InsertMemory(
 	}
 
 	// 插入数据库
-	_, err = s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		INSERT INTO memories (id, type, content, metadata, tags, timestamp, expires_at, importance, embedding)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, id, memType, content, metadataJSON, tagsJSON, timestamp.Format(time.RFC3339), expiresAtStr, importance, embeddingBlob)
+	if err != nil {
+		return err
+	}
+	if err := logEvent(tx, Event{Type: EventMemoryStored, Actor: s.actor, MemoryID: id, Detail: memType}); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
 // SearchMemories 向量搜索记忆
@@ -241,6 +270,39 @@ func (s *Store) GetMemoryByID(id string) (*MemoryResult, error) {
 	}, nil
 }
 
+// ResolveMemoryID 将ID前缀解析为完整记忆ID
+// 前缀匹配多条记忆时返回错误
+func (s *Store) ResolveMemoryID(id string) (string, error) {
+	if len(id) >= 36 {
+		return id, nil
+	}
+
+	// 前缀按原样比较（LIKE 会把 _ 和 % 当作通配符）
+	rows, err := s.db.Query("SELECT id FROM memories WHERE substr(id, 1, ?) = ? LIMIT 2", utf8.RuneCountInString(id), id)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var matches []string
+	for rows.Next() {
+		var fullID string
+		if err := rows.Scan(&fullID); err != nil {
+			return "", err
+		}
+		matches = append(matches, fullID)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no memory found with ID prefix: %s", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous memory ID prefix: %s", id)
+	}
+}
+
 // GetMemoriesByType 获取指定类型的所有记忆
 func (s *Store) GetMemoriesByType(memType string) ([]MemoryResult, error) {
 	rows, err := s.db.Query(`
@@ -255,7 +317,7 @@ func (s *Store) GetMemoriesByType(memType string) ([]MemoryResult, error) {
 	}
 	defer rows.Close()
 
-	return s.scanMemoryResults(rows)
+	return s.scanMemoryResults(rows, false)
 }
 
 // GetMemoriesBySession 获取指定会话的记忆
@@ -273,7 +335,23 @@ func (s *Store) GetMemoriesBySession(sessionID string, limit int) ([]MemoryResul
 	}
 	defer rows.Close()
 
-	return s.scanMemoryResults(rows)
+	return s.scanMemoryResults(rows, false)
+}
+
+// GetSessionMemories 获取会话的全部记忆（对话轮次和从中提取的记忆），按时间正序
+func (s *Store) GetSessionMemories(sessionID string) ([]MemoryResult, error) {
+	rows, err := s.db.Query(`
+		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
+		FROM memories
+		WHERE json_extract(metadata, '$.session_id') = ?
+		ORDER BY timestamp ASC, rowid ASC
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanMemoryResults(rows, false)
 }
 
 // GetRecentMemoriesByType 获取最近的指定类型记忆
@@ -291,10 +369,10 @@ func (s *Store) GetRecentMemoriesByType(memType string, limit int) ([]MemoryResu
 	}
 	defer rows.Close()
 
-	return s.scanMemoryResults(rows)
+	return s.scanMemoryResults(rows, false)
 }
 
-// UpdateMemory 更新记忆
+// UpdateMemory 更新记忆；embedding 为 nil 时保留原有嵌入（内容未变化）
 func (s *Store) UpdateMemory(
 	id, content string,
 	metadata map[string]interface{},
@@ -324,9 +402,13 @@ func (s *Store) UpdateMemory(
 	}
 
 	// 序列化embedding
-	embeddingBlob, err := sqlite_vec.SerializeFloat32(embedding)
-	if err != nil {
-		return fmt.Errorf("failed to serialize embedding: %w", err)
+	var embeddingBlob []byte
+	if embedding != nil {
+		blob, err := serializeFloat32(embedding)
+		if err != nil {
+			return fmt.Errorf("failed to serialize embedding: %w", err)
+		}
+		embeddingBlob = blob
 	}
 
 	// 处理expires_at
@@ -336,68 +418,81 @@ func (s *Store) UpdateMemory(
 		expiresAtStr = &str
 	}
 
-	_, err = s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		UPDATE memories
-		SET content = ?, metadata = ?, tags = ?, expires_at = ?, importance = ?, embedding = ?
+		SET content = ?, metadata = ?, tags = ?, expires_at = ?, importance = ?, embedding = COALESCE(?, embedding)
 		WHERE id = ?
 	`, content, metadataJSON, tagsJSON, expiresAtStr, importance, embeddingBlob, id)
+	if err != nil {
+		return err
+	}
+	if err := logMemoryEvents(tx, EventMemoryUpdated, s.actor, "id = ?", id); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
 // DeleteMemory 删除记忆（支持前缀匹配）
 func (s *Store) DeleteMemory(id string) error {
-	var result sql.Result
-	var err error
-
+	if id == "" {
+		return fmt.Errorf("memory ID is required")
+	}
 	// 如果 ID 较短（< 36 字符，即非完整 UUID），使用前缀匹配
+	where, args := "id = ?", []interface{}{id}
 	if len(id) < 36 {
-		result, err = s.db.Exec("DELETE FROM memories WHERE id LIKE ?", id+"%")
-	} else {
-		result, err = s.db.Exec("DELETE FROM memories WHERE id = ?", id)
+		where, args = "substr(id, 1, ?) = ?", []interface{}{utf8.RuneCountInString(id), id}
 	}
 
+	rows, err := s.deleteMemoriesWhere(where, args...)
 	if err != nil {
 		return err
 	}
-
-	rows, _ := result.RowsAffected()
 	if rows == 0 {
 		return fmt.Errorf("no memory found with ID prefix: %s", id)
 	}
 	return nil
 }
 
-// DeleteMemoriesBySession 删除指定会话的记忆
-func (s *Store) DeleteMemoriesBySession(sessionID string) (int, error) {
-	result, err := s.db.Exec(`
-		DELETE FROM memories
-		WHERE type = 'conversation' AND json_extract(metadata, '$.session_id') = ?
-	`, sessionID)
+// deleteMemoriesWhere 删除满足条件的记忆并记录事件，返回删除数量
+func (s *Store) deleteMemoriesWhere(where string, args ...interface{}) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	if err := logMemoryEvents(tx, EventMemoryDeleted, s.actor, where, args...); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("DELETE FROM memories WHERE "+where, args...)
 	if err != nil {
 		return 0, err
 	}
-
 	count, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 	return int(count), nil
 }
 
+// DeleteMemoriesBySession 删除指定会话的记忆
+func (s *Store) DeleteMemoriesBySession(sessionID string) (int, error) {
+	return s.deleteMemoriesWhere("type = 'conversation' AND json_extract(metadata, '$.session_id') = ?", sessionID)
+}
+
 // DeleteExpiredMemories 删除过期记忆
 func (s *Store) DeleteExpiredMemories() (int, error) {
 	now := time.Now().Format(time.RFC3339)
 
-	result, err := s.db.Exec(`
-		DELETE FROM memories
-		WHERE expires_at IS NOT NULL AND expires_at < ?
-	`, now)
-
-	if err != nil {
-		return 0, err
-	}
-
-	count, _ := result.RowsAffected()
-	return int(count), nil
+	return s.deleteMemoriesWhere("expires_at IS NOT NULL AND expires_at < ?", now)
 }
 
 // CountMemories 统计记忆总数
@@ -449,17 +544,303 @@ func (s *Store) GetSessionIDs() ([]string, error) {
 	return sessionIDs, nil
 }
 
+// ListMemories 按排序方式分页列出记忆
+func (s *Store) ListMemories(opts MemoryListOptions) ([]MemoryResult, error) {
+	var orderBy string
+	switch opts.SortBy {
+	case "", MemorySortRecency:
+		orderBy = "timestamp DESC, id"
+	case MemorySortImportance:
+		orderBy = "importance DESC, timestamp DESC, id"
+	case MemorySortAccess:
+		orderBy = "access_count DESC, timestamp DESC, id"
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", opts.SortBy)
+	}
+
+	whereClause := ""
+	args := make([]interface{}, 0, 3)
+	if opts.Type != "" {
+		whereClause = "WHERE type = ?"
+		args = append(args, opts.Type)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite 中 LIMIT -1 表示不限制
+	}
+	args = append(args, limit, opts.Offset)
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance, access_count
+		FROM memories
+		%s
+		ORDER BY %s
+		LIMIT ? OFFSET ?
+	`, whereClause, orderBy), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanMemoryResults(rows, true)
+}
+
+// CountMemoriesGroupedByType 统计每种类型的记忆数量
+func (s *Store) CountMemoriesGroupedByType() (map[string]int, error) {
+	rows, err := s.db.Query("SELECT type, COUNT(*) FROM memories GROUP BY type")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var memType string
+		var count int
+		if err := rows.Scan(&memType, &count); err != nil {
+			return nil, err
+		}
+		counts[memType] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// TouchMemories 记录记忆被访问（访问次数加一并更新访问时间）
+func (s *Store) TouchMemories(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE memories
+		SET access_count = access_count + 1, last_accessed_at = ?
+		WHERE id = ?
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().Format(time.RFC3339)
+	for _, id := range ids {
+		if _, err := stmt.Exec(now, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUnreviewedMemories 获取指定来源且尚未审阅的记忆（按时间倒序）
+func (s *Store) GetUnreviewedMemories(source string, limit int) ([]MemoryResult, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
+		FROM memories
+		WHERE json_extract(metadata, '$.source') = ?
+		  AND json_extract(metadata, '$.reviewed') IS NULL
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanMemoryResults(rows, false)
+}
+
+// UpdateMemoryMetadata 仅更新记忆的metadata（不重新生成嵌入）
+func (s *Store) UpdateMemoryMetadata(id string, metadata map[string]interface{}) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE memories SET metadata = ? WHERE id = ?", string(data), id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("no memory found with ID: %s", id)
+	}
+	if err := logMemoryEvents(tx, EventMemoryUpdated, s.actor, "id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertMemoryFeedback 记录一次记忆审阅反馈
+func (s *Store) InsertMemoryFeedback(memType, action string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO memory_feedback (memory_type, action, created_at)
+		VALUES (?, ?, ?)
+	`, memType, action, time.Now().Format(time.RFC3339))
+	return err
+}
+
+// CountMemoryFeedback 按审阅动作统计指定类型的反馈数量
+func (s *Store) CountMemoryFeedback(memType string) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT action, COUNT(*) FROM memory_feedback
+		WHERE memory_type = ?
+		GROUP BY action
+	`, memType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var action string
+		var count int
+		if err := rows.Scan(&action, &count); err != nil {
+			return nil, err
+		}
+		counts[action] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetAllMemories 获取所有记忆
+func (s *Store) GetAllMemories() ([]MemoryResult, error) {
+	rows, err := s.db.Query(`
+		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
+		FROM memories
+		ORDER BY timestamp DESC
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanMemoryResults(rows, false)
+}
+
+// GetMemoriesMissingEmbedding 获取缺少嵌入向量的记忆
+// 包括 embedding 为 NULL、空 BLOB 或全零向量的记忆（降级模式下写入的记忆）
+func (s *Store) GetMemoriesMissingEmbedding() ([]MemoryResult, error) {
+	rows, err := s.db.Query(`
+		SELECT id, embedding
+		FROM memories
+		ORDER BY timestamp DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		var embeddingBlob []byte
+		if err := rows.Scan(&id, &embeddingBlob); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if isZeroVector(blobToFloat32(embeddingBlob)) {
+			ids = append(ids, id)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MemoryResult, 0, len(ids))
+	for _, id := range ids {
+		mem, err := s.GetMemoryByID(id)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *mem)
+	}
+
+	return results, nil
+}
+
+// CountMemoriesMissingEmbedding 统计缺少嵌入向量的记忆数量
+func (s *Store) CountMemoriesMissingEmbedding() (int, error) {
+	rows, err := s.db.Query("SELECT embedding FROM memories")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var embeddingBlob []byte
+		if err := rows.Scan(&embeddingBlob); err != nil {
+			return 0, err
+		}
+		if isZeroVector(blobToFloat32(embeddingBlob)) {
+			count++
+		}
+	}
+
+	return count, rows.Err()
+}
+
+// UpdateMemoryEmbedding 仅更新记忆的嵌入向量
+func (s *Store) UpdateMemoryEmbedding(id string, embedding []float32) error {
+	embeddingBlob, err := serializeFloat32(embedding)
+	if err != nil {
+		return fmt.Errorf("failed to serialize embedding: %w", err)
+	}
+
+	result, err := s.db.Exec("UPDATE memories SET embedding = ? WHERE id = ?", embeddingBlob, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("no memory found with ID: %s", id)
+	}
+	return nil
+}
+
 // scanMemoryResults 扫描记忆查询结果
-func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
+// withAccess 为 true 时查询在基础列之后额外选择了 access_count 列
+func (s *Store) scanMemoryResults(rows *sql.Rows, withAccess bool) ([]MemoryResult, error) {
 	var results []MemoryResult
 
 	for rows.Next() {
 		var id, memType, content, metadataJSON, tagsJSON, timestampStr string
 		var expiresAtStr sql.NullString
 		var importance float64
+		var accessCount int
 
-		err := rows.Scan(&id, &memType, &content, &metadataJSON, &tagsJSON,
-			&timestampStr, &expiresAtStr, &importance)
+		dest := []interface{}{&id, &memType, &content, &metadataJSON, &tagsJSON,
+			&timestampStr, &expiresAtStr, &importance}
+		if withAccess {
+			dest = append(dest, &accessCount)
+		}
+
+		err := rows.Scan(dest...)
 		if err != nil {
 			continue
 		}
@@ -483,14 +864,15 @@ func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
 		}
 
 		results = append(results, MemoryResult{
-			ID:         id,
-			Type:       memType,
-			Content:    content,
-			Metadata:   metadata,
-			Tags:       tags,
-			Timestamp:  timestamp,
-			ExpiresAt:  expiresAt,
-			Importance: importance,
+			ID:          id,
+			Type:        memType,
+			Content:     content,
+			Metadata:    metadata,
+			Tags:        tags,
+			Timestamp:   timestamp,
+			ExpiresAt:   expiresAt,
+			Importance:  importance,
+			AccessCount: accessCount,
 		})
 	}
 
@@ -517,3 +899,95 @@ func cosineDist(a, b []float32) float64 {
 	similarity := dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
 	return 1.0 - similarity
 }
+
+// isZeroVector 检查向量是否为空或全零
+func isZeroVector(vec []float32) bool {
+	for _, v := range vec {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMemoryEmbeddings 返回各记忆的嵌入向量（跳过缺少嵌入的记忆）
+func (s *Store) GetMemoryEmbeddings() (map[string][]float32, error) {
+	rows, err := s.db.Query("SELECT id, embedding FROM memories")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memory embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	embeddings := make(map[string][]float32)
+	for rows.Next() {
+		var id string
+		var embeddingBlob []byte
+		if err := rows.Scan(&id, &embeddingBlob); err != nil {
+			return nil, err
+		}
+		if vec := blobToFloat32(embeddingBlob); !isZeroVector(vec) {
+			embeddings[id] = vec
+		}
+	}
+	return embeddings, rows.Err()
+}
+
+// MergeMemories 把 mergeIDs 合并到 keepID：更新保留记忆的标签、重要性和元数据，
+// 累加访问次数，并删除被合并的记忆（同一事务）
+func (s *Store) MergeMemories(keepID string, tags []string, importance float64, metadata map[string]interface{}, mergeIDs []string) error {
+	if len(mergeIDs) == 0 {
+		return nil
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(mergeIDs)), ", ")
+	mergeArgs := make([]interface{}, len(mergeIDs))
+	for i, id := range mergeIDs {
+		mergeArgs[i] = id
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := append([]interface{}{string(metadataJSON), string(tagsJSON), importance}, mergeArgs...)
+	args = append(args, keepID)
+	result, err := tx.Exec(`
+		UPDATE memories
+		SET metadata = ?, tags = ?, importance = ?,
+		    access_count = access_count + (SELECT COALESCE(SUM(access_count), 0) FROM memories WHERE id IN (`+placeholders+`))
+		WHERE id = ?
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update memory %s: %w", keepID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no memory found with ID: %s", keepID)
+	}
+	if err := logMemoryEvents(tx, EventMemoryUpdated, s.actor, "id = ?", keepID); err != nil {
+		return err
+	}
+
+	where := "id IN (" + placeholders + ")"
+	if err := logMemoryEvents(tx, EventMemoryDeleted, s.actor, where, mergeArgs...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM memories WHERE "+where, mergeArgs...); err != nil {
+		return fmt.Errorf("failed to delete merged memories: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"fmt"
+)
+
+// ObjectETags 返回对象存储集合中各文档（相对路径）上次索引时的 ETag
+func (s *Store) ObjectETags(collection string) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT path, etag FROM object_etags WHERE collection = ?", collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query object etags: %w", err)
+	}
+	defer rows.Close()
+
+	etags := make(map[string]string)
+	for rows.Next() {
+		var path, etag string
+		if err := rows.Scan(&path, &etag); err != nil {
+			return nil, fmt.Errorf("failed to scan object etag: %w", err)
+		}
+		etags[path] = etag
+	}
+	return etags, rows.Err()
+}
+
+// ReplaceObjectETags 用本次索引的 ETag 替换集合的记录
+func (s *Store) ReplaceObjectETags(collection string, etags map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM object_etags WHERE collection = ?", collection); err != nil {
+		return fmt.Errorf("failed to clear object etags: %w", err)
+	}
+	for path, etag := range etags {
+		if _, err := tx.Exec(
+			"INSERT INTO object_etags (collection, path, etag) VALUES (?, ?, ?)",
+			collection, path, etag,
+		); err != nil {
+			return fmt.Errorf("failed to save object etag: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// StageUnchanged 把未变化的现有文档原样写入暂存区，无需重新读取内容
+// 文档不存在或已停用时返回 false
+func (s *Store) StageUnchanged(generation int64, collection, path string) (bool, error) {
+	res, err := s.db.Exec(`
+		INSERT OR REPLACE INTO index_staging (collection, generation, path, title, hash, created_at, modified_at, language, doc_date)
+		SELECT collection, ?, path, title, hash, created_at, modified_at, language, doc_date
+		FROM documents WHERE collection = ? AND path = ? AND active = 1
+	`, generation, collection, path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stage document: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NormalizeDocPath 规范化集合内的文档路径：统一用 '/' 分隔（Windows 的 '\' 也转换），
+// 去掉 "./" 和开头的 '/'，折叠多余的分隔符
+// 文档路径在索引和查找时都经过此函数，因此在不同平台上建立的索引可以互相使用
+func NormalizeDocPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	p = strings.TrimLeft(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// isFilesystemPath 是否为文件系统绝对路径（/home/...、C:\...、C:/...、\\server\share）
+func isFilesystemPath(p string) bool {
+	return filepath.IsAbs(p) || strings.HasPrefix(p, "/") || hasDriveLetter(p) || strings.HasPrefix(p, `\\`)
+}
+
+// hasDriveLetter 是否以 Windows 盘符开头（如 C:\ 或 c:/）
+func hasDriveLetter(p string) bool {
+	if len(p) < 3 || p[1] != ':' || (p[2] != '\\' && p[2] != '/') {
+		return false
+	}
+	c := p[0] | 0x20
+	return c >= 'a' && c <= 'z'
+}
+
+// SetCaseInsensitivePaths 设置按路径查找文档时是否忽略大小写（Windows、macOS 默认的文件系统不区分大小写）
+func (s *Store) SetCaseInsensitivePaths(enabled bool) {
+	s.pathNoCase = enabled
+}
+
+// resolveDocRef 把文档引用解析为集合和集合内路径
+// 支持 mmq://collection/path、collection/path（可用 '\' 分隔），以及集合目录下的文件系统绝对路径
+func (s *Store) resolveDocRef(ref string) (collection, path string) {
+	if isFilesystemPath(ref) {
+		if c, rel, ok := s.relativeToCollection(ref); ok {
+			return c, rel
+		}
+	}
+	return parseFilePath(ref)
+}
+
+// relativeToCollection 把文件系统绝对路径解析为所在集合和集合内路径
+// 集合目录有多个匹配时取最长（最具体）的；盘符和忽略大小写模式下按不区分大小写比较
+func (s *Store) relativeToCollection(fsPath string) (collection, rel string, ok bool) {
+	colls, err := s.ListCollections()
+	if err != nil {
+		return "", "", false
+	}
+
+	target := strings.TrimRight(strings.ReplaceAll(fsPath, `\`, "/"), "/")
+	noCase := s.pathNoCase || hasDriveLetter(fsPath)
+	best := -1
+	for _, c := range colls {
+		if c.Path == "" || !isFilesystemPath(c.Path) {
+			continue
+		}
+		root := strings.TrimRight(strings.ReplaceAll(c.Path, `\`, "/"), "/")
+		if len(target) <= len(root)+1 || target[len(root)] != '/' {
+			continue
+		}
+		prefix := target[:len(root)]
+		if prefix != root && !(noCase && strings.EqualFold(prefix, root)) {
+			continue
+		}
+		if len(root) > best {
+			best = len(root)
+			collection = c.Name
+			rel = NormalizeDocPath(target[len(root)+1:])
+		}
+	}
+	return collection, rel, best >= 0
+}
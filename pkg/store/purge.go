@@ -0,0 +1,189 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PurgeResult 清理非活跃文档残留数据的结果
+type PurgeResult struct {
+	Vectors int // 删除的分块向量数（默认模型和专用模型）
+	FTSRows int // 删除的全文索引行数
+}
+
+// PurgeInactive 一次性修复：删除不再被任何活跃文档引用的内容的向量、
+// 没有元数据的残留向量，以及非活跃文档的全文索引行
+// 停用文档和删除集合时会自动级联清理，这里用于修复旧版本留下的数据
+func (s *Store) PurgeInactive() (*PurgeResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hashes, err := queryStrings(tx, `
+		SELECT hash FROM content_vectors
+		UNION
+		SELECT hash FROM model_vectors
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded content: %w", err)
+	}
+
+	result := &PurgeResult{}
+	if result.Vectors, err = purgeVectors(tx, hashes); err != nil {
+		return nil, err
+	}
+	stray, err := purgeStrayVectors(tx)
+	if err != nil {
+		return nil, err
+	}
+	result.Vectors += stray
+
+	res, err := tx.Exec("DELETE FROM documents_fts WHERE rowid NOT IN (SELECT id FROM documents WHERE active = 1)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge full-text index: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	result.FTSRows = int(n)
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return result, nil
+}
+
+// purgeVectors 删除 hashes 中不再被活跃文档（或重新索引暂存区）引用的内容的全部向量和嵌入进度，
+// 返回删除的分块向量数；在停用文档的同一事务中、停用之后调用
+func purgeVectors(tx *sql.Tx, hashes []string) (int, error) {
+	tables, err := vectorTables(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, hash := range hashes {
+		var referenced bool
+		if err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM documents WHERE hash = ? AND active = 1)
+			    OR EXISTS (SELECT 1 FROM index_staging WHERE hash = ?)
+		`, hash, hash).Scan(&referenced); err != nil {
+			return purged, fmt.Errorf("failed to check references of %s: %w", hash, err)
+		}
+		if referenced {
+			continue
+		}
+
+		// 按主键逐个删除向量表中的行，避免 LIKE 扫描整张 vec0 表
+		for meta, vecTables := range tables {
+			seqs, err := queryStrings(tx, "SELECT DISTINCT hash || '_' || seq FROM "+meta+" WHERE hash = ?", hash)
+			if err != nil {
+				return purged, fmt.Errorf("failed to list vectors of %s: %w", hash, err)
+			}
+			for _, hashSeq := range seqs {
+				for _, table := range vecTables {
+					if _, err := tx.Exec("DELETE FROM "+table+" WHERE hash_seq = ?", hashSeq); err != nil {
+						return purged, fmt.Errorf("failed to delete from %s: %w", table, err)
+					}
+				}
+			}
+			res, err := tx.Exec("DELETE FROM "+meta+" WHERE hash = ?", hash)
+			if err != nil {
+				return purged, fmt.Errorf("failed to delete from %s: %w", meta, err)
+			}
+			n, _ := res.RowsAffected()
+			purged += int(n)
+		}
+
+		if _, err := tx.Exec("DELETE FROM embedding_progress WHERE hash = ?", hash); err != nil {
+			return purged, fmt.Errorf("failed to delete embedding progress: %w", err)
+		}
+	}
+	return purged, nil
+}
+
+// purgeStrayVectors 删除向量表中没有对应元数据行的向量（旧版本清理孤儿向量时遗留）
+func purgeStrayVectors(tx *sql.Tx) (int, error) {
+	tables, err := vectorTables(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for meta, vecTables := range tables {
+		for _, table := range vecTables {
+			stray, err := queryStrings(tx, `
+				SELECT hash_seq FROM `+table+`
+				WHERE hash_seq NOT IN (SELECT hash || '_' || seq FROM `+meta+`)
+			`)
+			if err != nil {
+				return purged, fmt.Errorf("failed to scan %s: %w", table, err)
+			}
+			for _, hashSeq := range stray {
+				if _, err := tx.Exec("DELETE FROM "+table+" WHERE hash_seq = ?", hashSeq); err != nil {
+					return purged, fmt.Errorf("failed to delete from %s: %w", table, err)
+				}
+			}
+			purged += len(stray)
+		}
+	}
+	return purged, nil
+}
+
+// vectorTables 元数据表 → 已创建的 sqlite-vec 向量表
+// content_vectors 对应 vectors_vec，model_vectors 对应各专用模型的 vectors_vec_<id>
+func vectorTables(tx *sql.Tx) (map[string][]string, error) {
+	existing, err := queryStrings(tx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'vectors_vec%'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vector tables: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	tables := map[string][]string{"content_vectors": nil, "model_vectors": nil}
+	if exists["vectors_vec"] {
+		tables["content_vectors"] = append(tables["content_vectors"], "vectors_vec")
+	}
+
+	rows, err := tx.Query("SELECT id FROM embedding_models ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedding models: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if table := modelVectorTableName(id); exists[table] {
+			tables["model_vectors"] = append(tables["model_vectors"], table)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// querier 可以在事务或数据库连接上查询
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryStrings 返回查询第一列的全部值
+func queryStrings(q querier, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxQueryHistory 保留的最近查询数
+const MaxQueryHistory = 200
+
+// RecentQuery 最近查询
+type RecentQuery struct {
+	Query      string
+	Count      int
+	LastUsedAt time.Time
+}
+
+// RecordQuery 记录一次查询，超出 MaxQueryHistory 时淘汰最久未用的
+func (s *Store) RecordQuery(query string) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := s.db.Exec(`
+		INSERT INTO query_history (key, query, count, last_used_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			query = excluded.query,
+			count = count + 1,
+			last_used_at = excluded.last_used_at
+	`, strings.ToLower(query), query, now)
+	if err != nil {
+		return fmt.Errorf("failed to record query: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		DELETE FROM query_history WHERE key NOT IN (
+			SELECT key FROM query_history ORDER BY last_used_at DESC LIMIT ?
+		)
+	`, MaxQueryHistory)
+	if err != nil {
+		return fmt.Errorf("failed to prune query history: %w", err)
+	}
+	return nil
+}
+
+// RecentQueries 按前缀（不区分大小写）返回最近的查询，最近使用的在前
+func (s *Store) RecentQueries(prefix string, limit int) ([]RecentQuery, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// 主键范围扫描实现前缀匹配（U+10FFFF 是最大的码点）
+	lower := strings.ToLower(prefix)
+	rows, err := s.db.Query(`
+		SELECT query, count, last_used_at FROM query_history
+		WHERE key >= ? AND key < ?
+		ORDER BY last_used_at DESC
+		LIMIT ?
+	`, lower, lower+string(utf8.MaxRune), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []RecentQuery
+	for rows.Next() {
+		var q RecentQuery
+		var lastUsed string
+		if err := rows.Scan(&q.Query, &q.Count, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan query history: %w", err)
+		}
+		q.LastUsedAt, _ = time.Parse(time.RFC3339Nano, lastUsed)
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+// ClearQueryHistory 清空查询历史
+func (s *Store) ClearQueryHistory() error {
+	if _, err := s.db.Exec("DELETE FROM query_history"); err != nil {
+		return fmt.Errorf("failed to clear query history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RebuildCounts 全量重建前后核对的行数
+type RebuildCounts struct {
+	Collections int `json:"collections"`
+	Documents   int `json:"documents"` // 活跃文档
+	Contents    int `json:"contents"`  // 活跃文档引用的内容
+	FTSRows     int `json:"fts_rows"`
+	Chunks      int `json:"chunks"` // 已嵌入的块（含集合专用模型）
+	Memories    int `json:"memories"`
+	Contexts    int `json:"contexts"`
+}
+
+// SnapshotTo 把数据库一致地复制到新文件（VACUUM INTO），path 已存在时报错
+func (s *Store) SnapshotTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot target already exists: %s", path)
+	}
+	if _, err := s.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// ResetDerivedIndexes 从文档内容重建 FTS 索引，并删除所有向量和嵌入进度，供随后重新分块和嵌入
+// 向量表一并删除，重新嵌入时按新模型的维度重建
+func (s *Store) ResetDerivedIndexes() error {
+	modelIDs, err := queryInt64s(s.db, "SELECT id FROM embedding_models")
+	if err != nil {
+		return fmt.Errorf("failed to list embedding models: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		"DELETE FROM documents_fts",
+		`INSERT INTO documents_fts (rowid, filepath, title, body)
+		 SELECT d.id, d.collection || '/' || d.path, d.title, c.doc
+		 FROM documents d JOIN content c ON c.hash = d.hash
+		 WHERE d.active = 1`,
+		"DELETE FROM content_vectors",
+		"DELETE FROM model_vectors",
+		"DELETE FROM embedding_progress",
+		"DROP TABLE IF EXISTS vectors_vec",
+	}
+	for _, id := range modelIDs {
+		stmts = append(stmts, "DROP TABLE IF EXISTS "+modelVectorTableName(id))
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to reset indexes: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// 合并 FTS 段
+	if _, err := s.db.Exec("INSERT INTO documents_fts (documents_fts) VALUES ('optimize')"); err != nil {
+		return fmt.Errorf("failed to optimize full-text index: %w", err)
+	}
+	s.InvalidateCatalog()
+	return nil
+}
+
+// RebuildCounts 统计核对用的行数
+func (s *Store) RebuildCounts() (RebuildCounts, error) {
+	var c RebuildCounts
+	queries := []struct {
+		dst   *int
+		query string
+	}{
+		{&c.Collections, "SELECT COUNT(*) FROM collections"},
+		{&c.Documents, "SELECT COUNT(*) FROM documents WHERE active = 1"},
+		{&c.Contents, "SELECT COUNT(DISTINCT hash) FROM documents WHERE active = 1"},
+		{&c.FTSRows, "SELECT COUNT(*) FROM documents_fts"},
+		{&c.Chunks, "SELECT (SELECT COUNT(*) FROM content_vectors) + (SELECT COUNT(*) FROM model_vectors)"},
+		{&c.Memories, "SELECT COUNT(*) FROM memories"},
+		{&c.Contexts, "SELECT COUNT(*) FROM contexts"},
+	}
+	for _, q := range queries {
+		if err := s.db.QueryRow(q.query).Scan(q.dst); err != nil {
+			return c, fmt.Errorf("failed to count rows: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// SwapFile 用 staging 数据库文件原子替换当前数据库并重新打开连接，原文件保留为 backup
+// staging 必须已关闭；替换期间其他进程打开的连接仍指向旧文件
+func (s *Store) SwapFile(staging, backup string) error {
+	if _, err := os.Stat(staging); err != nil {
+		return fmt.Errorf("staging database not found: %w", err)
+	}
+
+	// 关闭前合并 WAL，保证主文件完整
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	// 先备份（硬链接，不支持时复制），再用 rename 原子替换
+	os.Remove(backup)
+	if err := os.Link(s.dbPath, backup); err != nil {
+		if err := copyFile(s.dbPath, backup); err != nil {
+			return s.reopen(fmt.Errorf("failed to back up database: %w", err))
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Remove(s.dbPath + suffix)
+	}
+	if err := os.Rename(staging, s.dbPath); err != nil {
+		return s.reopen(fmt.Errorf("failed to replace database: %w", err))
+	}
+	return s.reopen(nil)
+}
+
+// reopen 重新打开数据库连接，返回 cause 或打开失败的错误
+func (s *Store) reopen(cause error) error {
+	db, err := openDB(s.dbPath)
+	if err != nil {
+		if cause != nil {
+			return fmt.Errorf("%w (reopening also failed: %v)", cause, err)
+		}
+		return err
+	}
+	s.db = db
+	if c, ok := s.cache.(*sqliteCache); ok && !c.owned {
+		c.db = db
+	}
+	s.InvalidateCatalog()
+	return cause
+}
+
+// queryInt64s 查询单列整数
+func queryInt64s(db *sql.DB, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// copyFile 复制文件内容
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
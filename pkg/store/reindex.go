@@ -0,0 +1,242 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dyike/mmq/pkg/lang"
+)
+
+// ReindexStats 提交重新索引的结果
+type ReindexStats struct {
+	Generation  int64 // 新的集合版本号
+	Staged      int   // 暂存的文档数
+	Updated     int   // 新增或内容变化的文档数
+	Deactivated int   // 不再存在而被停用的文档数
+}
+
+// BeginReindex 开始集合的重新索引，返回暂存版本号
+// 上次中断遗留的暂存数据会被丢弃
+func (s *Store) BeginReindex(collection string) (int64, error) {
+	var current int64
+	err := s.db.QueryRow("SELECT generation FROM collections WHERE name = ?", collection).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("collection not found: %s", collection)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read generation: %w", err)
+	}
+
+	if _, err := s.db.Exec("DELETE FROM index_staging WHERE collection = ?", collection); err != nil {
+		return 0, fmt.Errorf("failed to clear staging: %w", err)
+	}
+
+	return current + 1, nil
+}
+
+// StageDocument 将文档写入暂存区，提交前对搜索不可见
+// doc.Hash 为空时根据内容计算
+func (s *Store) StageDocument(generation int64, doc Document) error {
+	doc.Path = NormalizeDocPath(doc.Path)
+	hash := doc.Hash
+	if hash == "" {
+		hash = computeHash(doc.Content)
+	}
+
+	now := time.Now().UTC()
+	stats := ComputeTextStats(doc.Content)
+	if _, err := s.db.Exec(
+		"INSERT OR IGNORE INTO content (hash, doc, created_at, word_count, char_count, reading_seconds) VALUES (?, ?, ?, ?, ?, ?)",
+		hash, doc.Content, now.Format(time.RFC3339), stats.Words, stats.Chars, stats.ReadingSeconds,
+	); err != nil {
+		return fmt.Errorf("failed to insert content: %w", err)
+	}
+
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = now
+	}
+	if doc.ModifiedAt.IsZero() {
+		doc.ModifiedAt = now
+	}
+	if doc.Language == "" {
+		doc.Language = lang.Detect(doc.Content)
+	}
+	if doc.Date == "" {
+		doc.Date = ExtractDocumentDate(doc.Path, doc.Content)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO index_staging (collection, generation, path, title, hash, created_at, modified_at, language, doc_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, doc.Collection, generation, doc.Path, doc.Title, hash,
+		doc.CreatedAt.Format(time.RFC3339),
+		doc.ModifiedAt.Format(time.RFC3339), doc.Language, doc.Date)
+	if err != nil {
+		return fmt.Errorf("failed to stage document: %w", err)
+	}
+	return nil
+}
+
+// CountStagedDocuments 统计暂存区中的文档数
+func (s *Store) CountStagedDocuments(collection string, generation int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM index_staging WHERE collection = ? AND generation = ?",
+		collection, generation,
+	).Scan(&count)
+	return count, err
+}
+
+// CommitReindex 在一个事务内用暂存区替换集合的文档
+// 搜索只会看到提交前或提交后的完整语料
+func (s *Store) CommitReindex(collection string, generation int64) (*ReindexStats, error) {
+	defer s.InvalidateCatalog()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stats := &ReindexStats{Generation: generation}
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM index_staging WHERE collection = ? AND generation = ?",
+		collection, generation,
+	).Scan(&stats.Staged); err != nil {
+		return nil, fmt.Errorf("failed to count staged documents: %w", err)
+	}
+
+	// 记录变更事件（在修改前比较暂存区和当前文档）
+	if err := logReindexEvents(tx, collection, generation, s.actor); err != nil {
+		return nil, err
+	}
+	// 提交前的内容，提交后不再被引用的需要删除向量
+	oldHashes, err := queryStrings(tx, "SELECT DISTINCT hash FROM documents WHERE collection = ? AND active = 1", collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	// 1. 写入新增或变化的文档（未变化的不触发 FTS 更新）
+	res, err := tx.Exec(`
+		INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active, language, doc_date, managed)
+		SELECT collection, path, title, hash, created_at, modified_at, 1, language, doc_date, 1
+		FROM index_staging
+		WHERE collection = ? AND generation = ?
+		ON CONFLICT(collection, path) DO UPDATE SET
+			title = excluded.title,
+			hash = excluded.hash,
+			modified_at = excluded.modified_at,
+			active = 1,
+			language = excluded.language,
+			doc_date = excluded.doc_date,
+			managed = 1
+		WHERE documents.active = 0
+		   OR documents.managed = 0
+		   OR documents.hash != excluded.hash
+		   OR documents.title != excluded.title
+		   OR documents.modified_at != excluded.modified_at
+		   OR documents.language != excluded.language
+		   OR documents.doc_date != excluded.doc_date
+	`, collection, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply staged documents: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	stats.Updated = int(n)
+
+	// 2. 停用本次未出现的文档（IndexDocument 直接写入的文档不在目录中，不受影响）
+	res, err = tx.Exec(`
+		UPDATE documents SET active = 0
+		WHERE collection = ? AND active = 1 AND managed = 1
+		  AND path NOT IN (SELECT path FROM index_staging WHERE collection = ? AND generation = ?)
+	`, collection, collection, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate removed documents: %w", err)
+	}
+	n, _ = res.RowsAffected()
+	stats.Deactivated = int(n)
+
+	// 3. 清空暂存区并切换版本号
+	if _, err := tx.Exec("DELETE FROM index_staging WHERE collection = ?", collection); err != nil {
+		return nil, fmt.Errorf("failed to clear staging: %w", err)
+	}
+	if _, err := purgeVectors(tx, oldHashes); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(
+		"UPDATE collections SET generation = ?, updated_at = ?, indexed_at = ? WHERE name = ?",
+		generation, now, now, collection,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update generation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reindex: %w", err)
+	}
+	s.notifyChanged()
+	return stats, nil
+}
+
+// logReindexEvents 记录提交重新索引将产生的新增、更新和停用事件
+func logReindexEvents(tx *sql.Tx, collection string, generation int64, actor string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := tx.Exec(`
+		INSERT INTO events (time, type, actor, collection, path, hash)
+		SELECT ?, CASE WHEN d.id IS NULL OR d.active = 0 THEN ? ELSE ? END, ?, s.collection, s.path, s.hash
+		FROM index_staging s
+		LEFT JOIN documents d ON d.collection = s.collection AND d.path = s.path
+		WHERE s.collection = ? AND s.generation = ?
+		  AND (d.id IS NULL OR d.active = 0 OR d.hash != s.hash OR d.title != s.title)
+		ORDER BY s.path
+	`, now, EventDocAdded, EventDocUpdated, actor, collection, generation)
+	if err != nil {
+		return fmt.Errorf("failed to log reindex events: %w", err)
+	}
+
+	return logDocumentEvents(tx, EventDocRemoved, actor, `
+		collection = ? AND active = 1 AND managed = 1
+		AND path NOT IN (SELECT path FROM index_staging WHERE collection = ? AND generation = ?)
+	`, collection, collection, generation)
+}
+
+// AbortReindex 丢弃暂存区，集合保持原样
+func (s *Store) AbortReindex(collection string, generation int64) error {
+	_, err := s.db.Exec(
+		"DELETE FROM index_staging WHERE collection = ? AND generation = ?",
+		collection, generation,
+	)
+	return err
+}
+
+// CollectionGeneration 返回集合当前的版本号
+func (s *Store) CollectionGeneration(collection string) (int64, error) {
+	var generation int64
+	err := s.db.QueryRow("SELECT generation FROM collections WHERE name = ?", collection).Scan(&generation)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("collection not found: %s", collection)
+	}
+	return generation, err
+}
+
+// GetCollectionHashes 返回集合中活跃文档的 路径 → 内容哈希
+func (s *Store) GetCollectionHashes(collection string) (map[string]string, error) {
+	rows, err := s.db.Query(
+		"SELECT path, hash FROM documents WHERE collection = ? AND active = 1", collection,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, err
+		}
+		hashes[path] = hash
+	}
+	return hashes, rows.Err()
+}
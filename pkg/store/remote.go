@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateRemoteCollection 创建远端集合：文档留在远端 mmq 数据库中，搜索时直接查询
+func (s *Store) CreateRemoteCollection(name, remote, remoteCollection string) error {
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE name = ?)", name).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check collection: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("collection '%s' already exists", name)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(`
+		INSERT INTO collections (name, path, mask, created_at, updated_at, remote, remote_collection)
+		VALUES (?, '', '', ?, ?, ?, ?)
+	`, name, now, now, remote, remoteCollection)
+	if err != nil {
+		return fmt.Errorf("failed to create remote collection: %w", err)
+	}
+	return nil
+}
+
+// RemoteCollections 列出远端集合
+func (s *Store) RemoteCollections() ([]Collection, error) {
+	rows, err := s.db.Query(`
+		SELECT name, remote, remote_collection FROM collections WHERE remote != '' ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query remote collections: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.Name, &c.Remote, &c.RemoteCollection); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetRerankScores 返回查询与各分块在 since 之后缓存的重排分数（按分块哈希），未命中的分块不在结果中
+func (s *Store) GetRerankScores(model, queryHash string, chunkHashes []string, since time.Time) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	if len(chunkHashes) == 0 {
+		return scores, nil
+	}
+
+	args := []interface{}{model, queryHash, since.UTC().Format(time.RFC3339Nano)}
+	for _, h := range chunkHashes {
+		args = append(args, h)
+	}
+	rows, err := s.db.Query(`
+		SELECT chunk_hash, score FROM rerank_cache
+		WHERE model = ? AND query_hash = ? AND created_at >= ?
+		AND chunk_hash IN (?`+strings.Repeat(",?", len(chunkHashes)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rerank cache: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var score float64
+		if err := rows.Scan(&hash, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan rerank score: %w", err)
+		}
+		scores[hash] = score
+	}
+	return scores, rows.Err()
+}
+
+// PutRerankScores 缓存查询与各分块的重排分数，并按 maxEntries 淘汰最早的条目（0 表示不限制）
+func (s *Store) PutRerankScores(model, queryHash string, scores map[string]float64, maxEntries int) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO rerank_cache (query_hash, chunk_hash, model, score, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rerank cache insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for hash, score := range scores {
+		if _, err := stmt.Exec(queryHash, hash, model, score, now); err != nil {
+			return fmt.Errorf("failed to cache rerank score: %w", err)
+		}
+	}
+	if maxEntries > 0 {
+		if _, err := tx.Exec(`
+			DELETE FROM rerank_cache WHERE rowid NOT IN (
+				SELECT rowid FROM rerank_cache ORDER BY created_at DESC LIMIT ?
+			)
+		`, maxEntries); err != nil {
+			return fmt.Errorf("failed to prune rerank cache: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ClearRerankCache 清空重排分数缓存，返回删除的条目数
+func (s *Store) ClearRerankCache() (int, error) {
+	res, err := s.db.Exec(`DELETE FROM rerank_cache`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear rerank cache: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
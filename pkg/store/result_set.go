@@ -0,0 +1,141 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ResultSetTTL 结果集是临时的：超过该时长未重新保存的结果集在保存新结果集时删除
+const ResultSetTTL = 7 * 24 * time.Hour
+
+// ResultSet 命名结果集
+type ResultSet struct {
+	Name      string
+	Query     string
+	CreatedAt time.Time
+	Size      int // 文档数
+	Docs      []ResultSetDoc
+}
+
+// ResultSetDoc 结果集中的一个文档（按保存时的排名）
+type ResultSetDoc struct {
+	Collection string
+	Path       string
+	Title      string
+	Score      float64
+}
+
+// SaveResultSet 保存（或替换）命名结果集，同时删除过期的结果集
+func (s *Store) SaveResultSet(name, query string, docs []ResultSetDoc) error {
+	now := time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expired := now.Add(-ResultSetTTL).Format(time.RFC3339)
+	if _, err := tx.Exec("DELETE FROM result_set_docs WHERE name IN (SELECT name FROM result_sets WHERE created_at < ?) OR name = ?", expired, name); err != nil {
+		return fmt.Errorf("failed to clear result sets: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM result_sets WHERE created_at < ? OR name = ?", expired, name); err != nil {
+		return fmt.Errorf("failed to clear result sets: %w", err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO result_sets (name, query, created_at) VALUES (?, ?, ?)", name, query, now.Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save result set: %w", err)
+	}
+	for i, d := range docs {
+		if _, err := tx.Exec(`
+			INSERT INTO result_set_docs (name, rank, collection, path, title, score)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, name, i+1, d.Collection, d.Path, d.Title, d.Score); err != nil {
+			return fmt.Errorf("failed to save result set: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit result set: %w", err)
+	}
+	return nil
+}
+
+// GetResultSet 返回结果集及其文档，不存在或已过期时返回错误
+func (s *Store) GetResultSet(name string) (*ResultSet, error) {
+	rs := &ResultSet{Name: name}
+	var createdAt string
+	err := s.db.QueryRow("SELECT query, created_at FROM result_sets WHERE name = ?", name).Scan(&rs.Query, &createdAt)
+	if err == nil {
+		rs.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	}
+	if err == sql.ErrNoRows || (err == nil && time.Since(rs.CreatedAt) > ResultSetTTL) {
+		return nil, fmt.Errorf("result set '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get result set: %w", err)
+	}
+
+	rows, err := s.db.Query("SELECT collection, path, title, score FROM result_set_docs WHERE name = ? ORDER BY rank", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get result set: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d ResultSetDoc
+		if err := rows.Scan(&d.Collection, &d.Path, &d.Title, &d.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan result set: %w", err)
+		}
+		rs.Docs = append(rs.Docs, d)
+	}
+	rs.Size = len(rs.Docs)
+	return rs, rows.Err()
+}
+
+// ListResultSets 列出未过期的结果集（不含文档），最近保存的在前
+func (s *Store) ListResultSets() ([]ResultSet, error) {
+	rows, err := s.db.Query(`
+		SELECT r.name, r.query, r.created_at, (SELECT COUNT(*) FROM result_set_docs d WHERE d.name = r.name)
+		FROM result_sets r
+		WHERE r.created_at >= ?
+		ORDER BY r.created_at DESC, r.name
+	`, time.Now().UTC().Add(-ResultSetTTL).Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list result sets: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []ResultSet
+	for rows.Next() {
+		var rs ResultSet
+		var createdAt string
+		if err := rows.Scan(&rs.Name, &rs.Query, &createdAt, &rs.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan result set: %w", err)
+		}
+		rs.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		sets = append(sets, rs)
+	}
+	return sets, rows.Err()
+}
+
+// DeleteResultSet 删除结果集
+func (s *Store) DeleteResultSet(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM result_sets WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete result set: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("result set '%s' not found", name)
+	}
+	if _, err := tx.Exec("DELETE FROM result_set_docs WHERE name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete result set: %w", err)
+	}
+	return tx.Commit()
+}
@@ -8,12 +8,14 @@ import (
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/dyike/mmq/pkg/lang"
 	"github.com/dyike/mmq/pkg/vectordb"
 )
 
-// SearchFTS 使用BM25全文搜索
-func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]SearchResult, error) {
+// SearchFTS 使用BM25全文搜索，languageFilter 非空时只返回该语言的文档
+func (s *Store) SearchFTS(query string, limit int, collectionFilter, languageFilter string, dates DateRange) ([]SearchResult, error) {
 	// 构建FTS查询
 	ftsQuery := buildFTS5Query(query)
 	if ftsQuery == "" {
@@ -31,6 +33,8 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 			d.path,
 			c.doc as body,
 			d.modified_at,
+			d.language,
+			d.doc_date,
 			bm25(documents_fts, 10.0, 1.0, 1.0) as bm25_score
 		FROM documents_fts f
 		JOIN documents d ON d.id = f.rowid
@@ -45,6 +49,15 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 		args = append(args, collectionFilter)
 	}
 
+	if languageFilter != "" {
+		sql += " AND d.language = ?"
+		args = append(args, lang.Normalize(languageFilter))
+	}
+
+	dateClause, dateArgs := dates.sqlFilter()
+	sql += dateClause
+	args = append(args, dateArgs...)
+
 	sql += " ORDER BY bm25_score ASC LIMIT ?"
 	args = append(args, limit)
 
@@ -65,7 +78,7 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 		err := rows.Scan(
 			&result.ID, &result.Path, &result.Title, &result.ID,
 			&result.Collection, &result.Path, &result.Content,
-			&modifiedAt, &bm25Score,
+			&modifiedAt, &result.Language, &result.Date, &bm25Score,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan result: %w", err)
@@ -74,7 +87,9 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 		// 转换BM25分数为[0,1]范围
 		// BM25分数是负数，绝对值越大表示越相关
 		result.Score = normalizeBM25Score(bm25Score)
+		result.RawScore = -bm25Score
 		result.Source = "fts"
+		result.Hash = result.ID
 		result.Timestamp, _ = time.Parse(time.RFC3339, modifiedAt)
 
 		// 生成snippet
@@ -169,7 +184,9 @@ func (s *Store) SearchVector(query string, embedding []float32, limit int, colle
 
 		result := SearchResult{
 			ID:         c.hash,
+			Hash:       c.hash,
 			Score:      1.0 - c.distance, // 余弦相似度
+			RawScore:   1.0 - c.distance,
 			Title:      c.title,
 			Content:    c.body,
 			Source:     "vector",
@@ -217,9 +234,10 @@ func ReciprocalRankFusion(resultLists [][]SearchResult, weights []float64, k int
 
 		// 计算每个结果的RRF分数
 		for rank, result := range list {
-			key := result.ID
-			if key == "" {
-				key = result.Path
+			// 各路的 ID 含义不同（FTS 为内容哈希，向量为文档 id），按文档路径合并
+			key := result.Collection + "/" + result.Path
+			if result.Path == "" {
+				key = result.ID
 			}
 
 			rrfContribution := weight / float64(k+rank+1)
@@ -264,30 +282,111 @@ func ReciprocalRankFusion(resultLists [][]SearchResult, weights []float64, k int
 	return results
 }
 
-// buildFTS5Query 构建FTS5查询字符串
+// 超长查询的处理（agent 常把整段文字当作查询，逐词 AND 的前缀查询既慢又几乎无法命中）
+const (
+	MaxFTSQueryLength = 1000 // 全文检索只使用查询的前 1000 字节
+	LongQueryTerms    = 12   // 去掉停用词后超过该词数的查询视为长查询
+	LongQueryKeywords = 8    // 长查询提取的关键词数，用 OR 连接
+)
+
+// buildFTS5Query 构建FTS5查询：查询词加前缀匹配后用 AND 连接；
+// 长查询（见 IsLongQuery）只取 LongQueryKeywords 个关键词，用 OR 连接由 BM25 排序
 func buildFTS5Query(query string) string {
-	// 分词并清理
-	words := strings.Fields(query)
-	var terms []string
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return ""
+	}
 
-	for _, word := range words {
+	op := " AND "
+	if len(terms) > LongQueryTerms {
+		terms = extractKeywords(terms, LongQueryKeywords)
+		op = " OR "
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		// 添加前缀匹配
+		quoted[i] = fmt.Sprintf(`"%s"*`, term)
+	}
+	return strings.Join(quoted, op)
+}
+
+// IsLongQuery 查询去掉停用词后超过 LongQueryTerms 个词（全文检索只用关键词，检索以向量为主）
+func IsLongQuery(query string) bool {
+	return len(queryTerms(query)) > LongQueryTerms
+}
+
+// queryTerms 查询中的检索词：截断到 MaxFTSQueryLength，去掉标点和停用词（全是停用词时保留）
+func queryTerms(query string) []string {
+	if len(query) > MaxFTSQueryLength {
+		// 在词边界截断，避免留下半个词
+		query = strings.ToValidUTF8(query[:MaxFTSQueryLength], "")
+		if i := strings.LastIndexFunc(query, unicode.IsSpace); i > 0 {
+			query = query[:i]
+		}
+	}
+
+	// 按查询语言去掉停用词
+	queryLang := lang.Detect(query)
+
+	var terms, stopTerms []string
+	for _, word := range strings.Fields(query) {
 		// 移除非字母数字字符
 		cleaned := strings.TrimFunc(word, func(r rune) bool {
 			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
 		})
-
-		if len(cleaned) > 0 {
-			// 添加前缀匹配
-			terms = append(terms, fmt.Sprintf(`"%s"*`, cleaned))
+		if len(cleaned) == 0 {
+			continue
 		}
+		if lang.IsStopword(queryLang, cleaned) {
+			stopTerms = append(stopTerms, cleaned)
+			continue
+		}
+		terms = append(terms, cleaned)
 	}
 
 	if len(terms) == 0 {
-		return ""
+		return stopTerms
 	}
+	return terms
+}
 
-	// 使用AND连接所有词
-	return strings.Join(terms, " AND ")
+// extractKeywords 从长查询的词中选出 n 个关键词（保持在查询中的先后顺序）
+// 出现次数多、较长、含数字或大写（标识符、专有名词）的词优先
+func extractKeywords(terms []string, n int) []string {
+	type keyword struct {
+		term  string
+		first int
+		score float64
+	}
+	var keywords []*keyword
+	byTerm := make(map[string]*keyword)
+	for i, term := range terms {
+		key := strings.ToLower(term)
+		kw, ok := byTerm[key]
+		if !ok {
+			kw = &keyword{term: term, first: i, score: math.Log(1 + float64(utf8.RuneCountInString(term)))}
+			if strings.IndexFunc(term, func(r rune) bool { return unicode.IsDigit(r) || unicode.IsUpper(r) }) >= 0 {
+				kw.score += 1
+			}
+			byTerm[key] = kw
+			keywords = append(keywords, kw)
+			continue
+		}
+		// 重复出现的词更可能是主题
+		kw.score += 0.5
+	}
+
+	if len(keywords) > n {
+		sort.SliceStable(keywords, func(i, j int) bool { return keywords[i].score > keywords[j].score })
+		keywords = keywords[:n]
+		sort.Slice(keywords, func(i, j int) bool { return keywords[i].first < keywords[j].first })
+	}
+	result := make([]string, len(keywords))
+	for i, kw := range keywords {
+		result[i] = kw.term
+	}
+	return result
 }
 
 // normalizeBM25Score 将BM25分数转换为[0,1]范围
@@ -1,66 +1,93 @@
 package store
 
 import (
-	"database/sql"
 	"fmt"
 	"sort"
 	"strconv"
 	"time"
 
-	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"github.com/dyike/mmq/pkg/lang"
 )
 
 // SearchVectorDocuments 文档级向量搜索（对标QMD的vsearch）
 // 使用 sqlite-vec 的高效 MATCH 查询，采用两步查询避免 JOIN 性能问题
-func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit int, collection string) ([]SearchResult, error) {
+// language 非空时只返回该语言的文档；使用专用嵌入模型的集合不在此搜索
+func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit int, collection, language string, dates DateRange) ([]SearchResult, error) {
 	// 检查 vectors_vec 表是否存在
-	var tableName string
-	err := s.db.QueryRow(`
-		SELECT name FROM sqlite_master
-		WHERE type='table' AND name='vectors_vec'
-	`).Scan(&tableName)
-
-	if err == sql.ErrNoRows {
+	exists, err := s.tableExists("vectors_vec")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check vectors_vec table: %w", err)
+	}
+	if !exists {
 		// 如果表不存在，返回空结果（还没有索引任何向量）
 		return []SearchResult{}, nil
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to check vectors_vec table: %w", err)
 	}
 
-	// 序列化查询向量
-	vecBlob, err := sqlite_vec.SerializeFloat32(queryEmbed)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize query vector: %w", err)
-	}
+	return s.searchVectorSpace(vectorSpace{vecTable: "vectors_vec", metaTable: "content_vectors"},
+		query, queryEmbed, limit, collection, language, dates)
+}
 
-	// STEP 1: 使用 sqlite-vec MATCH 查询获取最相似的向量
-	// 获取 limit * 3 个结果用于后续过滤（参考 qmd 实现）
-	type vecResult struct {
-		hashSeq  string
-		distance float64
-	}
+// vectorSpace 一个嵌入模型的向量存储
+// model 为空表示默认模型（content_vectors），否则为专用模型（model_vectors）
+type vectorSpace struct {
+	vecTable  string
+	metaTable string
+	model     string
+}
 
-	vecQuery := `
+// vectorHit 向量检索命中的分块及其余弦距离
+type vectorHit struct {
+	hashSeq  string
+	distance float64
+}
+
+// knnVectors 使用 sqlite-vec MATCH 查询扫描全部向量，返回距离最近的 k 个分块
+func (s *Store) knnVectors(space vectorSpace, vecBlob []byte, k int) ([]vectorHit, error) {
+	rows, err := s.db.Query(`
 		SELECT hash_seq, distance
-		FROM vectors_vec
+		FROM `+space.vecTable+`
 		WHERE embedding MATCH ? AND k = ?
-	`
-
-	vecRows, err := s.db.Query(vecQuery, vecBlob, limit*3)
+	`, vecBlob, k)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query vectors: %w", err)
 	}
-	defer vecRows.Close()
-
-	var vecResults []vecResult
-	distanceMap := make(map[string]float64)
+	defer rows.Close()
 
-	for vecRows.Next() {
-		var vr vecResult
-		if err := vecRows.Scan(&vr.hashSeq, &vr.distance); err != nil {
+	var hits []vectorHit
+	for rows.Next() {
+		var h vectorHit
+		if err := rows.Scan(&h.hashSeq, &h.distance); err != nil {
 			continue
 		}
-		vecResults = append(vecResults, vr)
+		hits = append(hits, h)
+	}
+	return hits, nil
+}
+
+// searchVectorSpace 在指定向量空间中搜索
+func (s *Store) searchVectorSpace(space vectorSpace, query string, queryEmbed []float32, limit int, collection, language string, dates DateRange) ([]SearchResult, error) {
+	// 序列化查询向量
+	vecBlob, err := serializeFloat32(queryEmbed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize query vector: %w", err)
+	}
+
+	// STEP 1: 向量较多时先按过滤条件或全文检索预筛选候选，只计算候选的距离；
+	// 否则使用 sqlite-vec MATCH 查询获取最相似的向量
+	// 获取 limit * 3 个结果用于后续过滤（参考 qmd 实现）
+	vecResults, ok, err := s.prefilteredVectors(space, query, queryEmbed, limit*3, collection, language, dates)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		vecResults, err = s.knnVectors(space, vecBlob, limit*3)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	distanceMap := make(map[string]float64)
+	for _, vr := range vecResults {
 		distanceMap[vr.hashSeq] = vr.distance
 	}
 
@@ -91,17 +118,33 @@ func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit
 			d.path,
 			d.id,
 			d.modified_at,
+			d.language,
+			d.doc_date,
 			content.doc as body
-		FROM content_vectors cv
+		FROM ` + space.metaTable + ` cv
 		JOIN documents d ON d.hash = cv.hash AND d.active = 1
 		JOIN content ON content.hash = d.hash
 		WHERE cv.hash || '_' || cv.seq IN (` + placeholders + `)`
 
 	args := hashSeqs
+	if space.model == "" {
+		// 默认模型的向量可能与专用模型集合共享内容，排除这些集合
+		docQuery += ` AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')`
+	} else {
+		docQuery += ` AND cv.model = ? AND d.collection IN (SELECT name FROM collections WHERE embed_model = ?)`
+		args = append(args, space.model, space.model)
+	}
 	if collection != "" {
 		docQuery += ` AND d.collection = ?`
 		args = append(args, collection)
 	}
+	if language != "" {
+		docQuery += ` AND d.language = ?`
+		args = append(args, lang.Normalize(language))
+	}
+	dateClause, dateArgs := dates.sqlFilter()
+	docQuery += dateClause
+	args = append(args, dateArgs...)
 
 	docRows, err := s.db.Query(docQuery, args...)
 	if err != nil {
@@ -120,6 +163,8 @@ func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit
 		path        string
 		id          int
 		modifiedAt  string
+		language    string
+		date        string
 		body        string
 		distance    float64
 	}
@@ -137,6 +182,8 @@ func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit
 			&dr.path,
 			&dr.id,
 			&dr.modifiedAt,
+			&dr.language,
+			&dr.date,
 			&dr.body,
 		)
 		if err != nil {
@@ -174,13 +221,17 @@ func (s *Store) SearchVectorDocuments(query string, queryEmbed []float32, limit
 		modifiedAt, _ := time.Parse(time.RFC3339, dr.modifiedAt)
 		searchResults[i] = SearchResult{
 			ID:         strconv.Itoa(dr.id),
+			Hash:       dr.hash,
 			Title:      dr.title,
 			Content:    dr.body,
 			Snippet:    extractSnippet(dr.body, query, 200),
 			Score:      1.0 - dr.distance, // 转换为相似度分数
+			RawScore:   1.0 - dr.distance,
 			Source:     "vector",
 			Collection: dr.collection,
 			Path:       dr.path,
+			Language:   dr.language,
+			Date:       dr.date,
 			Timestamp:  modifiedAt,
 		}
 	}
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// GetCollectionStrategy 获取集合自测推荐的检索策略（空字符串表示未自测）
+func (s *Store) GetCollectionStrategy(name string) (string, error) {
+	var strategy string
+	err := s.db.QueryRow("SELECT strategy FROM collections WHERE name = ?", name).Scan(&strategy)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("collection '%s' not found", name)
+	}
+	return strategy, err
+}
+
+// SetCollectionStrategy 记录集合推荐的检索策略，空字符串清除
+func (s *Store) SetCollectionStrategy(name, strategy string) error {
+	result, err := s.db.Exec("UPDATE collections SET strategy = ? WHERE name = ?", strategy, name)
+	if err != nil {
+		return fmt.Errorf("failed to set strategy: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+	return nil
+}
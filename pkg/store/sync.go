@@ -0,0 +1,581 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SyncPolicy 两端都修改了同一文档或记忆时的处理方式
+type SyncPolicy string
+
+const (
+	SyncLastWriterWins SyncPolicy = "lww"    // 较晚的修改生效（默认）
+	SyncManual         SyncPolicy = "manual" // 只报告冲突，不修改冲突项，不推进同步游标
+	SyncPreferLocal    SyncPolicy = "local"  // 本地版本生效
+	SyncPreferRemote   SyncPolicy = "remote" // 远端版本生效
+)
+
+// ParseSyncPolicy 解析冲突处理方式，空为 lww
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case "":
+		return SyncLastWriterWins, nil
+	case SyncLastWriterWins, SyncManual, SyncPreferLocal, SyncPreferRemote:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid conflict policy %q (use lww, manual, local or remote)", s)
+}
+
+// SyncConflict 两端都修改过的文档或记忆
+type SyncConflict struct {
+	Key        string    // 文档为 collection/path，记忆为 memory:<id>
+	LocalTime  time.Time // 本地最后修改时间
+	RemoteTime time.Time // 远端最后修改时间
+	Winner     string    // local、remote，manual 模式下为空（未处理）
+}
+
+// SyncStats 同步结果
+type SyncStats struct {
+	LocalID   string
+	RemoteID  string
+	Initial   bool // 首次同步：比较两端全部文档和记忆
+	Pulled    int  // 从远端应用到本地的变更数
+	Pushed    int  // 从本地应用到远端的变更数
+	Renamed   int  // 同步的集合重命名数
+	Conflicts []SyncConflict
+	DryRun    bool
+}
+
+// Unresolved 未处理的冲突数（manual 模式）
+func (s *SyncStats) Unresolved() int {
+	n := 0
+	for _, c := range s.Conflicts {
+		if c.Winner == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// InstanceID 返回本数据库的实例 ID（首次调用时生成）
+func (s *Store) InstanceID() (string, error) {
+	var id string
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = 'instance_id'").Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to read instance id: %w", err)
+	}
+
+	if _, err := s.db.Exec(
+		"INSERT OR IGNORE INTO meta (key, value) VALUES ('instance_id', ?)", uuid.New().String(),
+	); err != nil {
+		return "", fmt.Errorf("failed to create instance id: %w", err)
+	}
+	return s.InstanceID()
+}
+
+// syncChange 同步游标之后某个文档或记忆的最后一次变更
+type syncChange struct {
+	time   time.Time
+	actor  string
+	origin string
+}
+
+// syncChanges 一端自上次同步以来的变更
+type syncChanges struct {
+	items   map[string]syncChange
+	renames []Event
+}
+
+// memoryKeyPrefix 记忆在同步键中的前缀
+const memoryKeyPrefix = "memory:"
+
+// changesSince 收集 afterID 之后的变更，跳过来自 skipOrigin 的事件（对端同步过来的变更）
+// initial 时把当前全部文档和记忆都视为变更
+func (s *Store) changesSince(afterID int64, selfID, skipOrigin string, initial bool) (*syncChanges, error) {
+	events, err := s.ListEvents(EventFilter{AfterID: afterID})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &syncChanges{items: make(map[string]syncChange)}
+	for _, e := range events {
+		origin := e.Origin
+		if origin == "" {
+			origin = selfID
+		}
+		if origin == skipOrigin {
+			continue
+		}
+		change := syncChange{time: e.Time, actor: e.Actor, origin: origin}
+		switch {
+		case e.Type == EventCollectionRenamed:
+			e.Origin = origin
+			changes.renames = append(changes.renames, e)
+		case e.MemoryID != "":
+			changes.items[memoryKeyPrefix+e.MemoryID] = change
+		default:
+			changes.items[e.Collection+"/"+e.Path] = change
+		}
+	}
+
+	if !initial {
+		return changes, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT collection || '/' || path, modified_at FROM documents WHERE active = 1
+		UNION ALL
+		SELECT 'memory:' || id, timestamp FROM memories
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, ts string
+		if err := rows.Scan(&key, &ts); err != nil {
+			return nil, err
+		}
+		if _, ok := changes.items[key]; !ok {
+			t, _ := time.Parse(time.RFC3339, ts)
+			changes.items[key] = syncChange{time: t, origin: selfID}
+		}
+	}
+	return changes, rows.Err()
+}
+
+// syncItem 文档或记忆的当前状态，Exists 为 false 表示不存在或已删除
+type syncItem struct {
+	Exists bool
+
+	// 文档
+	Collection, Path, Title, Hash string
+	CreatedAt, ModifiedAt         string
+	Language, Date                string
+	CollectionPath, Mask          string
+
+	// 记忆
+	ID, Type, Content, Metadata, Tags string
+	Timestamp                         string
+	ExpiresAt, LastAccessedAt         sql.NullString
+	Importance                        float64
+	Embedding                         []byte
+	AccessCount                       int
+}
+
+// same 两端状态是否一致（无需同步）
+func (a syncItem) same(b syncItem) bool {
+	if !a.Exists || !b.Exists {
+		return a.Exists == b.Exists
+	}
+	if a.ID != "" {
+		return a.Content == b.Content && a.Metadata == b.Metadata && a.Tags == b.Tags &&
+			a.Importance == b.Importance && a.ExpiresAt == b.ExpiresAt
+	}
+	return a.Hash == b.Hash && a.Title == b.Title
+}
+
+// loadItem 读取同步键对应的当前状态
+func (s *Store) loadItem(key string) (syncItem, error) {
+	var item syncItem
+	if id, ok := strings.CutPrefix(key, memoryKeyPrefix); ok {
+		var metadata, tags sql.NullString
+		err := s.db.QueryRow(`
+			SELECT id, type, content, metadata, tags, timestamp, expires_at, importance, embedding, access_count, last_accessed_at
+			FROM memories WHERE id = ?
+		`, id).Scan(&item.ID, &item.Type, &item.Content, &metadata, &tags, &item.Timestamp,
+			&item.ExpiresAt, &item.Importance, &item.Embedding, &item.AccessCount, &item.LastAccessedAt)
+		if err == sql.ErrNoRows {
+			return syncItem{ID: id}, nil
+		}
+		if err != nil {
+			return item, fmt.Errorf("failed to load memory %s: %w", id, err)
+		}
+		item.Metadata, item.Tags = metadata.String, tags.String
+		item.Exists = true
+		return item, nil
+	}
+
+	collection, path, _ := strings.Cut(key, "/")
+	item.Collection, item.Path = collection, path
+	err := s.db.QueryRow(`
+		SELECT d.title, d.hash, d.created_at, d.modified_at, d.language, d.doc_date,
+		       COALESCE(c.path, ''), COALESCE(c.mask, '')
+		FROM documents d
+		LEFT JOIN collections c ON c.name = d.collection
+		WHERE d.collection = ? AND d.path = ? AND d.active = 1
+	`, collection, path).Scan(&item.Title, &item.Hash, &item.CreatedAt, &item.ModifiedAt,
+		&item.Language, &item.Date, &item.CollectionPath, &item.Mask)
+	if err == sql.ErrNoRows {
+		return item, nil
+	}
+	if err != nil {
+		return item, fmt.Errorf("failed to load document %s: %w", key, err)
+	}
+	item.Exists = true
+	return item, nil
+}
+
+// applyItem 把来源端的状态写入本库，并以原执行者和来源实例记录事件
+func (s *Store) applyItem(src *Store, item syncItem, change syncChange) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	actor := change.actor
+	if actor == "" {
+		actor = "sync"
+	}
+	event := Event{Actor: actor, Origin: change.origin}
+
+	if item.ID != "" {
+		err = applyMemory(tx, item, event)
+	} else {
+		err = applyDocument(tx, src, item, event)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if item.ID == "" {
+		s.notifyChanged()
+	}
+	return nil
+}
+
+// applyMemory 写入或删除记忆
+func applyMemory(tx *sql.Tx, item syncItem, event Event) error {
+	event.MemoryID = item.ID
+	if !item.Exists {
+		var memType string
+		err := tx.QueryRow("SELECT type FROM memories WHERE id = ?", item.ID).Scan(&memType)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM memories WHERE id = ?", item.ID); err != nil {
+			return fmt.Errorf("failed to delete memory %s: %w", item.ID, err)
+		}
+		event.Type, event.Detail = EventMemoryDeleted, memType
+		return logEvent(tx, event)
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM memories WHERE id = ?)", item.ID).Scan(&exists); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO memories (id, type, content, metadata, tags, timestamp, expires_at, importance, embedding, access_count, last_accessed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			type = excluded.type,
+			content = excluded.content,
+			metadata = excluded.metadata,
+			tags = excluded.tags,
+			expires_at = excluded.expires_at,
+			importance = excluded.importance,
+			embedding = excluded.embedding
+	`, item.ID, item.Type, item.Content, item.Metadata, item.Tags, item.Timestamp,
+		item.ExpiresAt, item.Importance, item.Embedding, item.AccessCount, item.LastAccessedAt)
+	if err != nil {
+		return fmt.Errorf("failed to write memory %s: %w", item.ID, err)
+	}
+
+	event.Type, event.Detail = EventMemoryStored, item.Type
+	if exists {
+		event.Type = EventMemoryUpdated
+	}
+	return logEvent(tx, event)
+}
+
+// applyDocument 写入或停用文档，内容从来源库读取
+func applyDocument(tx *sql.Tx, src *Store, item syncItem, event Event) error {
+	event.Collection, event.Path = item.Collection, item.Path
+	if !item.Exists {
+		var hash string
+		err := tx.QueryRow(
+			"SELECT hash FROM documents WHERE collection = ? AND path = ? AND active = 1", item.Collection, item.Path,
+		).Scan(&hash)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE documents SET active = 0 WHERE collection = ? AND path = ?", item.Collection, item.Path,
+		); err != nil {
+			return fmt.Errorf("failed to remove %s/%s: %w", item.Collection, item.Path, err)
+		}
+		if _, err := purgeVectors(tx, []string{hash}); err != nil {
+			return err
+		}
+		event.Type, event.Hash = EventDocRemoved, hash
+		return logEvent(tx, event)
+	}
+
+	// 集合不存在时按来源端的设置创建（路径是来源机器上的路径，仅供参考）
+	now := time.Now().UTC().Format(time.RFC3339)
+	if item.Mask == "" {
+		item.Mask = "**/*.md"
+	}
+	if _, err := tx.Exec(
+		"INSERT OR IGNORE INTO collections (name, path, mask, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		item.Collection, item.CollectionPath, item.Mask, now, now,
+	); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", item.Collection, err)
+	}
+
+	var content string
+	if err := src.db.QueryRow("SELECT doc FROM content WHERE hash = ?", item.Hash).Scan(&content); err != nil {
+		return fmt.Errorf("failed to read content of %s/%s: %w", item.Collection, item.Path, err)
+	}
+	stats := ComputeTextStats(content)
+	if _, err := tx.Exec(
+		"INSERT OR IGNORE INTO content (hash, doc, created_at, word_count, char_count, reading_seconds) VALUES (?, ?, ?, ?, ?, ?)",
+		item.Hash, content, now, stats.Words, stats.Chars, stats.ReadingSeconds,
+	); err != nil {
+		return fmt.Errorf("failed to insert content: %w", err)
+	}
+
+	var active bool
+	err := tx.QueryRow(
+		"SELECT active FROM documents WHERE collection = ? AND path = ?", item.Collection, item.Path,
+	).Scan(&active)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	event.Type, event.Hash = EventDocAdded, item.Hash
+	if active {
+		event.Type = EventDocUpdated
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active, language, doc_date)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(collection, path) DO UPDATE SET
+			title = excluded.title,
+			hash = excluded.hash,
+			modified_at = excluded.modified_at,
+			active = 1,
+			language = excluded.language,
+			doc_date = excluded.doc_date
+	`, item.Collection, item.Path, item.Title, item.Hash, item.CreatedAt, item.ModifiedAt, item.Language, item.Date)
+	if err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", item.Collection, item.Path, err)
+	}
+	return logEvent(tx, event)
+}
+
+// applyRename 应用集合重命名（旧集合存在且新名称未被占用时）
+func (s *Store) applyRename(e Event) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldExists, newExists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE name = ?)", e.Detail).Scan(&oldExists); err != nil {
+		return false, err
+	}
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE name = ?)", e.Collection).Scan(&newExists); err != nil {
+		return false, err
+	}
+	if !oldExists || newExists {
+		return false, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec("UPDATE collections SET name = ?, updated_at = ? WHERE name = ?", e.Collection, now, e.Detail); err != nil {
+		return false, fmt.Errorf("failed to rename collection: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE documents SET collection = ? WHERE collection = ?", e.Collection, e.Detail); err != nil {
+		return false, fmt.Errorf("failed to update documents: %w", err)
+	}
+	if err := logEvent(tx, Event{Type: EventCollectionRenamed, Actor: e.Actor, Origin: e.Origin, Collection: e.Collection, Detail: e.Detail}); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// maxEventID 当前最大的事件 ID
+func (s *Store) maxEventID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM events").Scan(&id)
+	return id, err
+}
+
+// Sync 与另一个数据库双向同步自上次同步以来的变更
+// 变更来自事件日志；同步写入的事件带来源实例 ID，不会被同步回来源端
+// url 记录在同步状态中，仅用于显示
+func (s *Store) Sync(remote *Store, url string, policy SyncPolicy, dryRun bool) (*SyncStats, error) {
+	localID, err := s.InstanceID()
+	if err != nil {
+		return nil, err
+	}
+	remoteID, err := remote.InstanceID()
+	if err != nil {
+		return nil, err
+	}
+	if localID == remoteID {
+		return nil, fmt.Errorf("cannot sync a database with itself")
+	}
+
+	var pulledID, pushedID int64
+	err = s.db.QueryRow(
+		"SELECT pulled_id, pushed_id FROM sync_peers WHERE peer_id = ?", remoteID,
+	).Scan(&pulledID, &pushedID)
+	initial := err == sql.ErrNoRows
+	if err != nil && !initial {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	// 先记下两端的事件位置，本次同步写入的事件不影响下次的游标
+	localMax, err := s.maxEventID()
+	if err != nil {
+		return nil, err
+	}
+	remoteMax, err := remote.maxEventID()
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := s.changesSince(pushedID, localID, remoteID, initial)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := remote.changesSince(pulledID, remoteID, localID, initial)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &SyncStats{LocalID: localID, RemoteID: remoteID, Initial: initial, DryRun: dryRun}
+	defer s.InvalidateCatalog()
+	defer remote.InvalidateCatalog()
+
+	// 1. 集合重命名
+	for _, e := range theirs.renames {
+		if dryRun {
+			stats.Renamed++
+			continue
+		}
+		ok, err := s.applyRename(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			stats.Renamed++
+		}
+	}
+	for _, e := range local.renames {
+		if dryRun {
+			stats.Renamed++
+			continue
+		}
+		ok, err := remote.applyRename(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			stats.Renamed++
+		}
+	}
+
+	// 2. 文档和记忆（按键排序，结果稳定）
+	keys := make([]string, 0, len(local.items)+len(theirs.items))
+	for key := range local.items {
+		keys = append(keys, key)
+	}
+	for key := range theirs.items {
+		if _, ok := local.items[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		mine, inLocal := local.items[key]
+		other, inRemote := theirs.items[key]
+
+		localItem, err := s.loadItem(key)
+		if err != nil {
+			return nil, err
+		}
+		remoteItem, err := remote.loadItem(key)
+		if err != nil {
+			return nil, err
+		}
+		if localItem.same(remoteItem) {
+			continue
+		}
+
+		push := inLocal
+		if inLocal && inRemote {
+			conflict := SyncConflict{Key: key, LocalTime: mine.time, RemoteTime: other.time}
+			switch policy {
+			case SyncManual:
+				stats.Conflicts = append(stats.Conflicts, conflict)
+				continue
+			case SyncPreferLocal:
+				push = true
+			case SyncPreferRemote:
+				push = false
+			default:
+				push = !other.time.After(mine.time)
+			}
+			conflict.Winner = "remote"
+			if push {
+				conflict.Winner = "local"
+			}
+			stats.Conflicts = append(stats.Conflicts, conflict)
+		}
+
+		if push {
+			stats.Pushed++
+			if !dryRun {
+				if err := remote.applyItem(s, localItem, mine); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			stats.Pulled++
+			if !dryRun {
+				if err := s.applyItem(remote, remoteItem, other); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// 3. 推进游标（manual 模式有未处理的冲突时保留，下次重新比较）
+	if dryRun || stats.Unresolved() > 0 {
+		return stats, nil
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO sync_peers (peer_id, url, pulled_id, pushed_id, synced_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(peer_id) DO UPDATE SET
+			url = excluded.url,
+			pulled_id = excluded.pulled_id,
+			pushed_id = excluded.pushed_id,
+			synced_at = excluded.synced_at
+	`, remoteID, url, remoteMax, localMax, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 阅读速度：拉丁文字按单词、中日韩文字按字计
+const (
+	wordsPerMinute    = 230
+	cjkCharsPerMinute = 400
+)
+
+// TextStats 文本长度统计
+type TextStats struct {
+	Words          int // 单词数（中日韩文字每个字计为一个词）
+	Chars          int // 字符数（Unicode 码点）
+	ReadingSeconds int // 预计阅读时间（秒）
+}
+
+// ComputeTextStats 统计单词数、字符数和预计阅读时间
+func ComputeTextStats(text string) TextStats {
+	var words, cjk int
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+
+	seconds := float64(words)*60/wordsPerMinute + float64(cjk)*60/cjkCharsPerMinute
+	return TextStats{
+		Words:          words + cjk,
+		Chars:          utf8.RuneCountInString(text),
+		ReadingSeconds: int(seconds + 0.5),
+	}
+}
+
+// isCJK 是否为中日韩表意文字或假名、谚文
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// backfillTextStats 为新增统计列之前写入的内容补算统计
+func backfillTextStats(db *sql.DB) error {
+	rows, err := db.Query("SELECT hash, doc FROM content")
+	if err != nil {
+		return fmt.Errorf("failed to read content: %w", err)
+	}
+	type row struct {
+		hash  string
+		stats TextStats
+	}
+	var pending []row
+	for rows.Next() {
+		var hash, doc string
+		if err := rows.Scan(&hash, &doc); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan content: %w", err)
+		}
+		pending = append(pending, row{hash, ComputeTextStats(doc)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, p := range pending {
+		if _, err := tx.Exec(
+			"UPDATE content SET word_count = ?, char_count = ?, reading_seconds = ? WHERE hash = ?",
+			p.stats.Words, p.stats.Chars, p.stats.ReadingSeconds, p.hash,
+		); err != nil {
+			return fmt.Errorf("failed to update content stats: %w", err)
+		}
+	}
+	return tx.Commit()
+}
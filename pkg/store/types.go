@@ -13,18 +13,24 @@ type Document struct {
 	CreatedAt  time.Time
 	ModifiedAt time.Time
 	Active     bool
+	Language   string // 为空时索引时自动检测
+	Date       string // 文档日期 YYYY-MM-DD，为空时从 frontmatter 或路径解析
 }
 
 // SearchResult store内部使用的搜索结果类型
 type SearchResult struct {
 	ID         string
+	Hash       string
 	Score      float64
+	RawScore   float64 // 检索器原始分数（BM25 为 |bm25|，向量为余弦相似度）
 	Title      string
 	Content    string
 	Snippet    string
 	Source     string
 	Collection string
 	Path       string
+	Language   string
+	Date       string // 文档日期（YYYY-MM-DD），未解析出时为空
 	Timestamp  time.Time
 }
 
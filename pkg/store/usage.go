@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// DatabaseSize 返回数据库占用的字节数（含 WAL 文件）
+func (s *Store) DatabaseSize() (int64, error) {
+	var pageCount, pageSize int64
+	if err := s.db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+
+	size := pageCount * pageSize
+	if info, err := os.Stat(s.dbPath + "-wal"); err == nil {
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// CountCollectionDocuments 统计集合中的活跃文档数
+func (s *Store) CountCollectionDocuments(collection string) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM documents WHERE collection = ? AND active = 1", collection,
+	).Scan(&count)
+	return count, err
+}
+
+// CountActiveDocuments 统计所有集合的活跃文档数
+func (s *Store) CountActiveDocuments() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM documents WHERE active = 1").Scan(&count)
+	return count, err
+}
+
+// DocumentExists 检查集合中是否已有该路径的活跃文档
+func (s *Store) DocumentExists(collection, path string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM documents WHERE collection = ? AND path = ? AND active = 1)",
+		collection, path,
+	).Scan(&exists)
+	return exists, err
+}
+
+// LargestCollection 返回活跃文档最多的集合及其文档数
+func (s *Store) LargestCollection() (string, int, error) {
+	var name string
+	var count int
+	err := s.db.QueryRow(`
+		SELECT collection, COUNT(*) AS n FROM documents
+		WHERE active = 1
+		GROUP BY collection
+		ORDER BY n DESC
+		LIMIT 1
+	`).Scan(&name, &count)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	return name, count, err
+}
+
+// DBPath 返回数据库文件路径
+func (s *Store) DBPath() string {
+	return s.dbPath
+}
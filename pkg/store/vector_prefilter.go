@@ -0,0 +1,264 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dyike/mmq/pkg/lang"
+)
+
+// VectorPrefilter 向量检索前的候选预筛选
+// 向量空间的分块数达到 MinVectors 时先缩小候选集，只计算候选分块的距离，代替扫描全部向量：
+//   - 指定了集合、语言或日期过滤，且满足条件的分块不超过 MaxCandidates 时，候选为这些分块（结果与全量扫描一致）
+//   - 否则开启 Lexical 且查询有足够的词面内容（有非停用词，全文检索命中的文档足够多）时，候选为全文检索靠前文档的分块，
+//     与查询没有共同词的文档（如同义改写）不会出现在向量结果中，因此默认关闭
+//
+// 都不满足时仍扫描全部向量
+type VectorPrefilter struct {
+	MinVectors    int  // 0 表示关闭
+	MaxCandidates int  // 候选分块上限（默认 DefaultPrefilterCandidates）
+	Lexical       bool // 没有元数据过滤时用全文检索预筛选（牺牲召回换速度）
+}
+
+// 预筛选默认值
+const (
+	DefaultPrefilterMinVectors = 50000
+	DefaultPrefilterCandidates = 5000
+)
+
+// prefilterLexicalDocs 全文检索预筛选取的文档数
+const prefilterLexicalDocs = 500
+
+// SetVectorPrefilter 设置向量检索的候选预筛选
+func (s *Store) SetVectorPrefilter(p VectorPrefilter) {
+	if p.MaxCandidates <= 0 {
+		p.MaxCandidates = DefaultPrefilterCandidates
+	}
+	s.prefilter = p
+}
+
+// prefilteredVectors 预筛选候选后计算距离，返回最近的 k 个分块；不适用预筛选时 ok 为 false
+func (s *Store) prefilteredVectors(space vectorSpace, query string, queryEmbed []float32, k int, collection, language string, dates DateRange) (hits []vectorHit, ok bool, err error) {
+	if s.prefilter.MinVectors <= 0 {
+		return nil, false, nil
+	}
+	total, err := s.countSpaceVectors(space)
+	if err != nil || total < s.prefilter.MinVectors {
+		return nil, false, err
+	}
+
+	// 元数据过滤：满足条件的分块不多时直接全部计算
+	if collection != "" || language != "" || !dates.IsZero() {
+		hits, ok, err := s.scoreFilteredVectors(space, queryEmbed, k, collection, language, dates)
+		if err != nil || ok {
+			return hits, ok, err
+		}
+	}
+
+	// 全文检索：查询有足够的词面内容时只计算命中文档的分块
+	if !s.prefilter.Lexical || !hasContentTerms(query) {
+		return nil, false, nil
+	}
+	hashes, err := s.lexicalCandidates(space, query, collection, language, dates)
+	if err != nil || len(hashes) < k {
+		return nil, false, err
+	}
+	hits, err = s.scoreLexicalVectors(space, queryEmbed, k, hashes)
+	return hits, err == nil, err
+}
+
+// countSpaceVectors 向量空间中的分块数
+func (s *Store) countSpaceVectors(space vectorSpace) (int, error) {
+	q := `SELECT COUNT(*) FROM ` + space.metaTable
+	var args []interface{}
+	if space.model != "" {
+		q += ` WHERE model = ?`
+		args = append(args, space.model)
+	}
+	var n int
+	if err := s.db.QueryRow(q, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count vectors: %w", err)
+	}
+	return n, nil
+}
+
+// spaceFilter 文档属于该向量空间的条件（默认模型排除使用专用模型的集合）
+func spaceFilter(space vectorSpace) (string, []interface{}) {
+	if space.model == "" {
+		return ` AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')`, nil
+	}
+	return ` AND d.collection IN (SELECT name FROM collections WHERE embed_model = ?)`, []interface{}{space.model}
+}
+
+// metadataFilter 集合、语言和日期过滤条件
+func metadataFilter(collection, language string, dates DateRange) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	if collection != "" {
+		clause += ` AND d.collection = ?`
+		args = append(args, collection)
+	}
+	if language != "" {
+		clause += ` AND d.language = ?`
+		args = append(args, lang.Normalize(language))
+	}
+	dateClause, dateArgs := dates.sqlFilter()
+	return clause + dateClause, append(args, dateArgs...)
+}
+
+// scoreFilteredVectors 计算满足元数据过滤的全部分块的距离；分块超过 MaxCandidates 时 ok 为 false
+func (s *Store) scoreFilteredVectors(space vectorSpace, queryEmbed []float32, k int, collection, language string, dates DateRange) ([]vectorHit, bool, error) {
+	q := `
+		SELECT cv.hash || '_' || cv.seq, cv.embedding
+		FROM ` + space.metaTable + ` cv
+		JOIN documents d ON d.hash = cv.hash AND d.active = 1
+		WHERE cv.embedding IS NOT NULL`
+	var args []interface{}
+	if space.model != "" {
+		q += ` AND cv.model = ?`
+		args = append(args, space.model)
+	}
+	clause, clauseArgs := spaceFilter(space)
+	q += clause
+	args = append(args, clauseArgs...)
+	clause, clauseArgs = metadataFilter(collection, language, dates)
+	q += clause + ` LIMIT ?`
+	args = append(args, clauseArgs...)
+	args = append(args, s.prefilter.MaxCandidates+1)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query candidate vectors: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var hits []vectorHit
+	for rows.Next() {
+		var hashSeq string
+		var blob []byte
+		if err := rows.Scan(&hashSeq, &blob); err != nil {
+			return nil, false, fmt.Errorf("failed to scan candidate vector: %w", err)
+		}
+		if len(seen) == s.prefilter.MaxCandidates {
+			return nil, false, nil
+		}
+		if seen[hashSeq] {
+			continue
+		}
+		seen[hashSeq] = true
+		hits = append(hits, vectorHit{hashSeq: hashSeq, distance: cosineDist(queryEmbed, blobToFloat32(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	return nearestHits(hits, k), true, nil
+}
+
+// lexicalCandidates 全文检索命中的文档内容哈希（按 BM25 排序，去重）
+func (s *Store) lexicalCandidates(space vectorSpace, query, collection, language string, dates DateRange) ([]string, error) {
+	ftsQuery := buildFTS5Query(query)
+	if ftsQuery == "" {
+		return nil, nil
+	}
+	q := `
+		SELECT d.hash
+		FROM documents_fts f
+		JOIN documents d ON d.id = f.rowid
+		WHERE documents_fts MATCH ? AND d.active = 1`
+	args := []interface{}{ftsQuery}
+	clause, clauseArgs := spaceFilter(space)
+	q += clause
+	args = append(args, clauseArgs...)
+	clause, clauseArgs = metadataFilter(collection, language, dates)
+	q += clause + ` ORDER BY bm25(documents_fts, 10.0, 1.0, 1.0) LIMIT ?`
+	args = append(args, clauseArgs...)
+	args = append(args, prefilterLexicalDocs)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("FTS prefilter failed: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan FTS candidate: %w", err)
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, rows.Err()
+}
+
+// scoreLexicalVectors 计算全文检索命中文档的分块距离，按文档排名取不超过 MaxCandidates 个分块
+func (s *Store) scoreLexicalVectors(space vectorSpace, queryEmbed []float32, k int, hashes []string) ([]vectorHit, error) {
+	args := make([]interface{}, 0, len(hashes)+1)
+	for _, h := range hashes {
+		args = append(args, h)
+	}
+	q := `
+		SELECT hash, hash || '_' || seq, embedding
+		FROM ` + space.metaTable + `
+		WHERE embedding IS NOT NULL AND hash IN (?` + strings.Repeat(",?", len(hashes)-1) + `)`
+	if space.model != "" {
+		q += ` AND model = ?`
+		args = append(args, space.model)
+	}
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candidate vectors: %w", err)
+	}
+	defer rows.Close()
+
+	byHash := make(map[string][]vectorHit)
+	for rows.Next() {
+		var hash, hashSeq string
+		var blob []byte
+		if err := rows.Scan(&hash, &hashSeq, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan candidate vector: %w", err)
+		}
+		byHash[hash] = append(byHash[hash], vectorHit{hashSeq: hashSeq, distance: cosineDist(queryEmbed, blobToFloat32(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var hits []vectorHit
+	for _, h := range hashes {
+		if len(hits)+len(byHash[h]) > s.prefilter.MaxCandidates {
+			break
+		}
+		hits = append(hits, byHash[h]...)
+	}
+	return nearestHits(hits, k), nil
+}
+
+// nearestHits 按距离排序取前 k 个
+func nearestHits(hits []vectorHit, k int) []vectorHit {
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].distance < hits[j].distance
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// hasContentTerms 查询中有非停用词
+func hasContentTerms(query string) bool {
+	queryLang := lang.Detect(query)
+	for _, term := range queryTerms(query) {
+		if !lang.IsStopword(queryLang, term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VectorStats 向量索引统计
+type VectorStats struct {
+	Vectors      int                     // 向量总数（分块数）
+	Dimensions   []int                   // 出现过的向量维度
+	BytesOnDisk  int64                   // 向量占用的磁盘空间（元数据表 + vec0 分块表）
+	EstimatedRAM int64                   // 暴力搜索需要加载的向量内存
+	Spaces       []VectorSpaceStats      // 按模型和维度细分
+	Collections  []CollectionVectorStats // 按集合细分
+}
+
+// VectorSpaceStats 单个模型/维度的向量统计
+type VectorSpaceStats struct {
+	Model      string
+	Dimensions int
+	Vectors    int
+	Bytes      int64
+}
+
+// CollectionVectorStats 单个集合的向量统计
+type CollectionVectorStats struct {
+	Collection string
+	Model      string // 专用嵌入模型，空表示默认模型
+	Documents  int    // 有向量的文档数
+	Vectors    int
+	Bytes      int64
+}
+
+// VectorStats 统计向量数量、维度、磁盘占用和暴力搜索所需内存
+func (s *Store) VectorStats() (*VectorStats, error) {
+	stats := &VectorStats{}
+
+	dims := make(map[int]bool)
+	for _, table := range []string{"content_vectors", "model_vectors"} {
+		rows, err := s.db.Query(fmt.Sprintf(`
+			SELECT model, length(embedding) / 4 AS dims, COUNT(*), COALESCE(SUM(length(embedding)), 0)
+			FROM %s
+			WHERE embedding IS NOT NULL
+			GROUP BY model, dims
+			ORDER BY model, dims
+		`, table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", table, err)
+		}
+		for rows.Next() {
+			var sp VectorSpaceStats
+			if err := rows.Scan(&sp.Model, &sp.Dimensions, &sp.Vectors, &sp.Bytes); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan vector stats: %w", err)
+			}
+			stats.Spaces = append(stats.Spaces, sp)
+			stats.Vectors += sp.Vectors
+			stats.BytesOnDisk += sp.Bytes
+			// float32 向量全部载入内存
+			stats.EstimatedRAM += int64(sp.Vectors) * int64(sp.Dimensions) * 4
+			dims[sp.Dimensions] = true
+		}
+		rows.Close()
+	}
+	for d := range dims {
+		stats.Dimensions = append(stats.Dimensions, d)
+	}
+	sort.Ints(stats.Dimensions)
+
+	chunkBytes, err := s.vecChunkBytes()
+	if err != nil {
+		return nil, err
+	}
+	stats.BytesOnDisk += chunkBytes
+
+	collections, err := s.collectionVectorStats()
+	if err != nil {
+		return nil, err
+	}
+	stats.Collections = collections
+
+	return stats, nil
+}
+
+// vecChunkBytes 统计 sqlite-vec 分块影子表占用的空间
+func (s *Store) vecChunkBytes() (int64, error) {
+	rows, err := s.db.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name LIKE 'vectors\_vec%\_vector\_chunks%' ESCAPE '\'
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list vector chunk tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	var total int64
+	for _, table := range tables {
+		var n int64
+		if err := s.db.QueryRow(fmt.Sprintf(`SELECT COALESCE(SUM(length(vectors)), 0) FROM "%s"`, table)).Scan(&n); err != nil {
+			return 0, fmt.Errorf("failed to measure %s: %w", table, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// collectionVectorStats 按集合统计向量（默认模型和专用模型分别计算）
+func (s *Store) collectionVectorStats() ([]CollectionVectorStats, error) {
+	rows, err := s.db.Query(`
+		SELECT x.collection, '', COUNT(DISTINCT x.hash), COUNT(*), COALESCE(SUM(length(cv.embedding)), 0)
+		FROM (SELECT DISTINCT collection, hash FROM documents WHERE active = 1) x
+		LEFT JOIN collections c ON c.name = x.collection
+		JOIN content_vectors cv ON cv.hash = x.hash
+		WHERE COALESCE(c.embed_model, '') = ''
+		GROUP BY x.collection
+
+		UNION ALL
+
+		SELECT x.collection, c.embed_model, COUNT(DISTINCT x.hash), COUNT(*), COALESCE(SUM(length(mv.embedding)), 0)
+		FROM (SELECT DISTINCT collection, hash FROM documents WHERE active = 1) x
+		JOIN collections c ON c.name = x.collection AND c.embed_model != ''
+		JOIN model_vectors mv ON mv.hash = x.hash AND mv.model = c.embed_model
+		GROUP BY x.collection
+
+		ORDER BY 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection vector stats: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CollectionVectorStats
+	for rows.Next() {
+		var cs CollectionVectorStats
+		if err := rows.Scan(&cs.Collection, &cs.Model, &cs.Documents, &cs.Vectors, &cs.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan collection vector stats: %w", err)
+		}
+		result = append(result, cs)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 向量检查发现的问题
+const (
+	VectorIssueMissing    = "missing"    // 活跃文档没有预期模型的向量
+	VectorIssueIncomplete = "incomplete" // 缺少部分分块的向量
+	VectorIssueModel      = "model"      // 向量由其他模型生成
+	VectorIssueDimension  = "dimension"  // 维度与向量表不一致
+	VectorIssueCorrupt    = "corrupt"    // 向量数据为空或长度不是 4 的倍数
+	VectorIssueNaN        = "nan"        // 含 NaN 或无穷大
+	VectorIssueUnindexed  = "unindexed"  // 向量表中没有对应行，检索不到
+)
+
+// VectorIssue 一个有问题的向量行或文档（Seq < 0 表示整个文档）
+type VectorIssue struct {
+	Kind       string
+	Hash       string
+	Collection string // 引用该内容的一个活跃文档
+	Path       string
+	Model      string // 预期的专用模型，默认模型为空
+	Seq        int
+	Dimensions int // 实际维度（无法解析时为 0）
+	Detail     string
+}
+
+// Corrupt 向量数据本身有问题（可以隔离）
+func (i VectorIssue) Corrupt() bool {
+	switch i.Kind {
+	case VectorIssueCorrupt, VectorIssueNaN, VectorIssueDimension, VectorIssueModel:
+		return i.Seq >= 0
+	}
+	return false
+}
+
+// VectorCheck 向量完整性检查结果
+type VectorCheck struct {
+	Contents int // 检查的内容数（同一内容在不同模型下分别计）
+	Vectors  int // 检查的向量行数
+	Issues   []VectorIssue
+}
+
+// vecDimensionsPattern 从 vec0 建表语句解析维度
+var vecDimensionsPattern = regexp.MustCompile(`float\[(\d+)\]`)
+
+// CheckVectors 检查活跃文档的向量：默认模型（defaultModel）和各集合专用模型下，
+// 每个分块都有向量、由预期模型生成、维度与向量表一致、数据可以解析且不含 NaN，并且在向量表中可以检索到
+func (s *Store) CheckVectors(defaultModel string) (*VectorCheck, error) {
+	check := &VectorCheck{}
+	if err := s.checkVectorSpace(check, "", defaultModel); err != nil {
+		return nil, err
+	}
+	models, err := s.ListEmbedModels()
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range models {
+		if err := s.checkVectorSpace(check, model, model); err != nil {
+			return nil, err
+		}
+	}
+	return check, nil
+}
+
+// vectorDoc 检查时引用内容的一个活跃文档
+type vectorDoc struct {
+	collection, path string
+	seqs             int
+	maxSeq           int
+	totalChunks      int // 嵌入进度记录的总块数（0 表示未记录）
+}
+
+// checkVectorSpace 检查一个向量空间；model 为空时检查默认模型的向量，expected 为预期的模型名
+func (s *Store) checkVectorSpace(check *VectorCheck, model, expected string) error {
+	space := vectorSpace{vecTable: "vectors_vec", metaTable: "content_vectors", model: model}
+	docQuery := `
+		SELECT d.hash, MIN(d.collection), MIN(d.path), COALESCE(MAX(p.total_chunks), 0)
+		FROM documents d
+		LEFT JOIN embedding_progress p ON p.hash = d.hash AND p.model = ?
+		WHERE d.active = 1 AND d.collection NOT IN (SELECT name FROM collections WHERE embed_model != '')
+		GROUP BY d.hash`
+	docArgs := []interface{}{expected}
+	rowQuery := "SELECT hash, seq, model, embedding FROM content_vectors ORDER BY hash, seq"
+	var rowArgs []interface{}
+	if model != "" {
+		table, err := s.modelVectorTable(model)
+		if err != nil {
+			return err
+		}
+		space.vecTable = table
+		space.metaTable = "model_vectors"
+		docQuery = `
+			SELECT d.hash, MIN(d.collection), MIN(d.path), 0
+			FROM documents d
+			JOIN collections c ON c.name = d.collection AND c.embed_model = ?
+			WHERE d.active = 1
+			GROUP BY d.hash`
+		docArgs = []interface{}{model}
+		rowQuery = "SELECT hash, seq, model, embedding FROM model_vectors WHERE model = ? ORDER BY hash, seq"
+		rowArgs = []interface{}{model}
+	} else if exists, err := s.tableExists(space.vecTable); err != nil {
+		return err
+	} else if !exists {
+		space.vecTable = ""
+	}
+
+	docs := make(map[string]*vectorDoc)
+	rows, err := s.db.Query(docQuery, docArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		d := &vectorDoc{maxSeq: -1}
+		if err := rows.Scan(&hash, &d.collection, &d.path, &d.totalChunks); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs[hash] = d
+	}
+	rows.Close()
+	if len(docs) == 0 {
+		return nil
+	}
+	check.Contents += len(docs)
+
+	dims, err := s.spaceDimensions(space)
+	if err != nil {
+		return err
+	}
+	indexed, err := s.indexedVectors(space.vecTable)
+	if err != nil {
+		return err
+	}
+
+	issue := func(kind, hash string, d *vectorDoc, seq, dims int, detail string) {
+		check.Issues = append(check.Issues, VectorIssue{
+			Kind: kind, Hash: hash, Collection: d.collection, Path: d.path,
+			Model: model, Seq: seq, Dimensions: dims, Detail: detail,
+		})
+	}
+
+	rows, err = s.db.Query(rowQuery, rowArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", space.metaTable, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, rowModel string
+		var seq int
+		var blob []byte
+		if err := rows.Scan(&hash, &seq, &rowModel, &blob); err != nil {
+			return fmt.Errorf("failed to scan vector: %w", err)
+		}
+		d, ok := docs[hash]
+		if !ok {
+			// 非活跃内容的残留向量由 purge 清理
+			continue
+		}
+		check.Vectors++
+		d.seqs++
+		if seq > d.maxSeq {
+			d.maxSeq = seq
+		}
+
+		switch {
+		case len(blob) == 0:
+			issue(VectorIssueCorrupt, hash, d, seq, 0, "empty embedding")
+		case len(blob)%4 != 0:
+			issue(VectorIssueCorrupt, hash, d, seq, 0, fmt.Sprintf("%d bytes is not a multiple of 4", len(blob)))
+		default:
+			n := len(blob) / 4
+			if bad := nonFinite(blobToFloat32(blob)); bad >= 0 {
+				issue(VectorIssueNaN, hash, d, seq, n, fmt.Sprintf("value %d is not a finite number", bad))
+			} else if dims > 0 && n != dims {
+				issue(VectorIssueDimension, hash, d, seq, n, fmt.Sprintf("%d dimensions, expected %d", n, dims))
+			} else if rowModel != expected {
+				issue(VectorIssueModel, hash, d, seq, n, fmt.Sprintf("embedded with %s, expected %s", rowModel, expected))
+			} else if !indexed[fmt.Sprintf("%s_%d", hash, seq)] {
+				issue(VectorIssueUnindexed, hash, d, seq, n, "not in the vector index")
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	hashes := make([]string, 0, len(docs))
+	for hash := range docs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		d := docs[hash]
+		switch {
+		case d.seqs == 0:
+			issue(VectorIssueMissing, hash, d, -1, 0, "no embeddings")
+		case d.seqs != d.maxSeq+1:
+			issue(VectorIssueIncomplete, hash, d, -1, 0, fmt.Sprintf("%d of %d chunks embedded", d.seqs, d.maxSeq+1))
+		case d.totalChunks > d.seqs:
+			issue(VectorIssueIncomplete, hash, d, -1, 0, fmt.Sprintf("%d of %d chunks embedded", d.seqs, d.totalChunks))
+		}
+	}
+	return nil
+}
+
+// spaceDimensions 向量空间的预期维度：向量表声明的维度，向量表不存在时取最常见的维度
+func (s *Store) spaceDimensions(space vectorSpace) (int, error) {
+	if space.vecTable != "" {
+		var ddl string
+		err := s.db.QueryRow("SELECT sql FROM sqlite_master WHERE name = ?", space.vecTable).Scan(&ddl)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to read %s definition: %w", space.vecTable, err)
+		}
+		if m := vecDimensionsPattern.FindStringSubmatch(ddl); m != nil {
+			return strconv.Atoi(m[1])
+		}
+	}
+
+	query := "SELECT length(embedding) / 4 FROM " + space.metaTable + " WHERE length(embedding) > 0 AND length(embedding) % 4 = 0"
+	var args []interface{}
+	if space.model != "" {
+		query += " AND model = ?"
+		args = append(args, space.model)
+	}
+	var dims int
+	err := s.db.QueryRow(query+" GROUP BY 1 ORDER BY COUNT(*) DESC LIMIT 1", args...).Scan(&dims)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to measure vector dimensions: %w", err)
+	}
+	return dims, nil
+}
+
+// indexedVectors 向量表中已有的 hash_seq
+func (s *Store) indexedVectors(table string) (map[string]bool, error) {
+	indexed := make(map[string]bool)
+	if table == "" {
+		return indexed, nil
+	}
+	rows, err := s.db.Query("SELECT hash_seq FROM " + table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		indexed[key] = true
+	}
+	return indexed, rows.Err()
+}
+
+// nonFinite 第一个 NaN 或无穷大的下标，没有时返回 -1
+func nonFinite(v []float32) int {
+	for i, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return i
+		}
+	}
+	return -1
+}
+
+// QuarantineVectors 把损坏的向量行移到 vector_quarantine 保留原始数据，
+// 并删除受影响内容在该模型下的全部向量，之后 mmq embed 会重新生成；返回隔离的行数
+func (s *Store) QuarantineVectors(issues []VectorIssue) (int, error) {
+	// 先查好各模型的向量表，事务中不再访问其他连接
+	vecTables := make(map[string]string)
+	for _, issue := range issues {
+		if _, ok := vecTables[issue.Model]; ok || !issue.Corrupt() {
+			continue
+		}
+		table := "vectors_vec"
+		if issue.Model != "" {
+			var err error
+			if table, err = s.modelVectorTable(issue.Model); err != nil {
+				return 0, err
+			}
+		} else if exists, err := s.tableExists(table); err != nil {
+			return 0, err
+		} else if !exists {
+			table = ""
+		}
+		vecTables[issue.Model] = table
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	quarantined := 0
+	affected := make(map[[2]string]bool) // model, hash
+	for _, issue := range issues {
+		if !issue.Corrupt() {
+			continue
+		}
+		meta, where, args := "content_vectors", "hash = ? AND seq = ?", []interface{}{issue.Hash, issue.Seq}
+		if issue.Model != "" {
+			meta, where, args = "model_vectors", "model = ? AND hash = ? AND seq = ?", []interface{}{issue.Model, issue.Hash, issue.Seq}
+		}
+		res, err := tx.Exec(`
+			INSERT INTO vector_quarantine (source, model, hash, seq, pos, embedding, reason, quarantined_at)
+			SELECT ?, model, hash, seq, pos, embedding, ?, ? FROM `+meta+` WHERE `+where,
+			append([]interface{}{meta, issue.Kind + ": " + issue.Detail, now}, args...)...)
+		if err != nil {
+			return quarantined, fmt.Errorf("failed to quarantine vector: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		quarantined += int(n)
+		affected[[2]string{issue.Model, issue.Hash}] = true
+	}
+
+	for key := range affected {
+		model, hash := key[0], key[1]
+		meta, where, args := "content_vectors", "hash = ?", []interface{}{hash}
+		if model != "" {
+			meta, where, args = "model_vectors", "model = ? AND hash = ?", []interface{}{model, hash}
+		}
+		seqs, err := queryStrings(tx, "SELECT hash || '_' || seq FROM "+meta+" WHERE "+where, args...)
+		if err != nil {
+			return quarantined, fmt.Errorf("failed to list vectors of %s: %w", hash, err)
+		}
+		if table := vecTables[model]; table != "" {
+			for _, hashSeq := range seqs {
+				if _, err := tx.Exec("DELETE FROM "+table+" WHERE hash_seq = ?", hashSeq); err != nil {
+					return quarantined, fmt.Errorf("failed to delete from %s: %w", table, err)
+				}
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM "+meta+" WHERE "+where, args...); err != nil {
+			return quarantined, fmt.Errorf("failed to delete from %s: %w", meta, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return quarantined, fmt.Errorf("failed to commit quarantine: %w", err)
+	}
+	return quarantined, nil
+}